@@ -0,0 +1,251 @@
+// Package activation is the table of the heights the protocol changes of a
+// PegNet network activate at: the grading versions, the conversion rules, the
+// rcd types, and the assets of the V4 OPR. Every rule that depends on the
+// height consults the table of the active network, which is set once before
+// the node syncs.
+//
+// Custom networks set their own table in their network file, either in full
+// or as overrides of the table of a built-in network.
+package activation
+
+import (
+	"fmt"
+	"math"
+)
+
+// Version is the version of the table. A table of a later version has
+// features this pegnetd does not enforce, so it is refused. Version 2 added
+// the gradings after the V4 grading.
+const Version = 2
+
+// Feature is a protocol change of the table, its name is the json key of
+// its height
+type Feature string
+
+const (
+	// Pegnet is the first height of the pegnet
+	Pegnet Feature = "pegnet"
+	// GradingV2 grades the OPRs with the V2 grading
+	GradingV2 Feature = "gradingv2"
+	// TransactionConversion enables transactions and conversions
+	TransactionConversion Feature = "transactionconversion"
+	// PEGPricing prices PEG by the market cap equation
+	PEGPricing Feature = "pegpricing"
+	// OneWaypFCTConversions makes pFCT a 1 way conversion: pFCT->pXXX, but
+	// no asset can go into pFCT. The only way to acquire pFCT is to burn FCT.
+	OneWaypFCTConversions Feature = "onewaypfctconversions"
+	// ConversionLimit limits the PEG that can be converted to per block
+	ConversionLimit Feature = "conversionlimit"
+	// PEGFreeFloatingPrice prices PEG by the exchange price, and grades the
+	// OPRs with the V3 grading
+	PEGFreeFloatingPrice Feature = "pegfreefloatingprice"
+	// V4OPRUpdate adds the V4 assets, the V4 grading, and the bank
+	V4OPRUpdate Feature = "v4oprupdate"
+	// RCDE accepts rcd type 0x0e
+	RCDE Feature = "rcde"
+	// Multisig accepts rcd type 0x02, a M-of-N set of ed25519 keys
+	Multisig Feature = "multisig"
+	// TimeLock accepts the "notbefore" height of transaction batches
+	TimeLock Feature = "timelock"
+	// MinOutput accepts the "minoutput" of conversions
+	MinOutput Feature = "minoutput"
+)
+
+// Features are all features of the table in the order they were added
+var Features = []Feature{Pegnet, GradingV2, TransactionConversion, PEGPricing, OneWaypFCTConversions,
+	ConversionLimit, PEGFreeFloatingPrice, V4OPRUpdate, RCDE, Multisig, TimeLock, MinOutput}
+
+// Never is the height of a feature that is not scheduled
+const Never = math.MaxUint32
+
+// Table is the activation heights of a network. A height of 0 is active from
+// the start, heights left out of a network file are 0.
+type Table struct {
+	// Version is the version of the table, 0 is the first version
+	Version int `json:"version,omitempty"`
+
+	Pegnet                uint32 `json:"pegnet"`
+	GradingV2             uint32 `json:"gradingv2"`
+	TransactionConversion uint32 `json:"transactionconversion"`
+	PEGPricing            uint32 `json:"pegpricing"`
+	OneWaypFCTConversions uint32 `json:"onewaypfctconversions"`
+	ConversionLimit       uint32 `json:"conversionlimit"`
+	PEGFreeFloatingPrice  uint32 `json:"pegfreefloatingprice"`
+	V4OPRUpdate           uint32 `json:"v4oprupdate"`
+	RCDE                  uint32 `json:"rcde"`
+	Multisig              uint32 `json:"multisig"`
+	TimeLock              uint32 `json:"timelock"`
+	MinOutput             uint32 `json:"minoutput"`
+
+	// Gradings are the grading versions that follow the V4 grading, in the
+	// order of their heights
+	Gradings []Grading `json:"gradings,omitempty"`
+}
+
+// Grading is the height a grading version activates at. The version has to
+// be registered with the grading package of the node.
+type Grading struct {
+	Version uint8  `json:"version"`
+	Height  uint32 `json:"height"`
+}
+
+var (
+	// MainNet are the activations of MainNet
+	MainNet = Table{
+		Version:               Version,
+		Pegnet:                206421,
+		GradingV2:             210330,
+		TransactionConversion: 213237, // Oct 7, 2019 15 UTC
+		PEGPricing:            214287, // Oct 14 2019, 15:00:00 UTC
+		OneWaypFCTConversions: 220346, // Nov 25, 2019 17:47:00 UTC
+		ConversionLimit:       222270, // Dec 9, 2019, 17:00 UTC
+		PEGFreeFloatingPrice:  222270, // Dec 9, 2019, 17:00 UTC
+		V4OPRUpdate:           231620, // Feb 12, 2020, 18:00 UTC
+		RCDE:                  231620, // Feb 12, 2020, 18:00 UTC
+		Multisig:              Never,
+		TimeLock:              Never,
+		MinOutput:             Never,
+	}
+
+	// RegTest has every feature active from the start
+	RegTest = Table{Version: Version}
+)
+
+// current is the table of the active network
+var current = MainNet
+
+// Set sets the table of the active network. It has to be set before the node
+// syncs.
+func Set(t Table) {
+	current = t
+}
+
+// Current returns the table of the active network
+func Current() Table {
+	return current
+}
+
+// Height returns the activation height of the feature on the active network
+func Height(f Feature) uint32 {
+	return current.Height(f)
+}
+
+// Active returns true if the feature is active at the height on the active
+// network
+func Active(f Feature, height uint32) bool {
+	return current.Active(f, height)
+}
+
+// GradingVersion returns the grading version of the OPRs at the height on
+// the active network
+func GradingVersion(height uint32) uint8 {
+	return current.GradingVersion(height)
+}
+
+// field returns the height of the feature, it panics if the feature is not
+// part of the table
+func (t *Table) field(f Feature) *uint32 {
+	switch f {
+	case Pegnet:
+		return &t.Pegnet
+	case GradingV2:
+		return &t.GradingV2
+	case TransactionConversion:
+		return &t.TransactionConversion
+	case PEGPricing:
+		return &t.PEGPricing
+	case OneWaypFCTConversions:
+		return &t.OneWaypFCTConversions
+	case ConversionLimit:
+		return &t.ConversionLimit
+	case PEGFreeFloatingPrice:
+		return &t.PEGFreeFloatingPrice
+	case V4OPRUpdate:
+		return &t.V4OPRUpdate
+	case RCDE:
+		return &t.RCDE
+	case Multisig:
+		return &t.Multisig
+	case TimeLock:
+		return &t.TimeLock
+	case MinOutput:
+		return &t.MinOutput
+	}
+	panic(fmt.Sprintf("unknown feature %q", f))
+}
+
+// Height returns the activation height of the feature
+func (t Table) Height(f Feature) uint32 {
+	return *t.field(f)
+}
+
+// Active returns true if the feature is active at the height. A feature that
+// is Never scheduled is not active at any height.
+func (t Table) Active(f Feature, height uint32) bool {
+	act := t.Height(f)
+	return act != Never && height >= act
+}
+
+// With returns the table with the feature activated at the height
+func (t Table) With(f Feature, height uint32) Table {
+	*t.field(f) = height
+	return t
+}
+
+// All returns the table with every feature activated at the height
+func (t Table) All(height uint32) Table {
+	for _, f := range Features {
+		t = t.With(f, height)
+	}
+	return t
+}
+
+// gradings are the features that change the grading version, in order
+var gradings = []struct {
+	Feature Feature
+	Version uint8
+}{
+	{GradingV2, 2},
+	{PEGFreeFloatingPrice, 3},
+	{V4OPRUpdate, 4},
+}
+
+// GradingVersion returns the grading version of the OPRs at the height, the
+// V4 grading or one of the gradings after it from the V4 update
+func (t Table) GradingVersion(height uint32) uint8 {
+	ver := uint8(1)
+	for _, g := range gradings {
+		if t.Active(g.Feature, height) {
+			ver = g.Version
+		}
+	}
+	for _, g := range t.Gradings {
+		if height >= g.Height {
+			ver = g.Version
+		}
+	}
+	return ver
+}
+
+// Valid checks that the table can be enforced by this pegnetd
+func (t Table) Valid() error {
+	if t.Version > Version {
+		return fmt.Errorf("the activations are version %d, this pegnetd supports up to version %d", t.Version, Version)
+	}
+	for i := 1; i < len(gradings); i++ {
+		if t.Height(gradings[i-1].Feature) > t.Height(gradings[i].Feature) {
+			return fmt.Errorf("the grading versions must activate in order: %s <= %s", gradings[i-1].Feature, gradings[i].Feature)
+		}
+	}
+	prev := t.V4OPRUpdate
+	for _, g := range t.Gradings {
+		if g.Version <= 4 {
+			return fmt.Errorf("grading version %d is not after the V4 grading", g.Version)
+		}
+		if g.Height < prev {
+			return fmt.Errorf("grading version %d must activate after v4oprupdate and the gradings before it", g.Version)
+		}
+		prev = g.Height
+	}
+	return nil
+}
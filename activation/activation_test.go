@@ -0,0 +1,82 @@
+package activation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradingVersion(t *testing.T) {
+	for height, ver := range map[uint32]uint8{
+		0:      1,
+		210329: 1,
+		210330: 2,
+		222269: 2,
+		222270: 3,
+		231619: 3,
+		231620: 4,
+	} {
+		assert.Equal(t, ver, MainNet.GradingVersion(height), height)
+	}
+	assert.Equal(t, uint8(2), MainNet.With(PEGFreeFloatingPrice, Never).With(V4OPRUpdate, Never).GradingVersion(Never-1))
+	assert.Equal(t, uint8(4), RegTest.GradingVersion(0))
+
+	next := MainNet
+	next.Gradings = []Grading{{Version: 5, Height: 240000}, {Version: 6, Height: 250000}}
+	require.NoError(t, next.Valid())
+	assert.Equal(t, uint8(4), next.GradingVersion(239999))
+	assert.Equal(t, uint8(5), next.GradingVersion(240000))
+	assert.Equal(t, uint8(6), next.GradingVersion(250000))
+}
+
+func TestTable(t *testing.T) {
+	for _, f := range Features {
+		assert.Equal(t, uint32(0), RegTest.Height(f), f)
+		assert.Equal(t, uint32(7), MainNet.All(7).Height(f), f)
+	}
+	assert.Panics(t, func() { MainNet.Height("unknown") })
+
+	table := MainNet.With(Multisig, 300000)
+	assert.False(t, table.Active(Multisig, 299999))
+	assert.True(t, table.Active(Multisig, 300000))
+	assert.Equal(t, uint32(Never), MainNet.Multisig, "With must not change the table it is called on")
+
+	defer Set(Current())
+	Set(table)
+	assert.True(t, Active(Multisig, 300000))
+	assert.Equal(t, uint32(300000), Height(Multisig))
+}
+
+func TestNever(t *testing.T) {
+	heights := []uint32{0, 1, MainNet.V4OPRUpdate, math.MaxInt32, Never - 1, Never}
+	for _, f := range Features {
+		table := MainNet.With(f, Never)
+		for _, height := range heights {
+			assert.False(t, table.Active(f, height), "%s at %d", f, height)
+		}
+		assert.True(t, MainNet.With(f, 0).Active(f, Never), f)
+	}
+}
+
+func TestValid(t *testing.T) {
+	require.NoError(t, MainNet.Valid())
+	require.NoError(t, RegTest.Valid())
+
+	assert.EqualError(t, MainNet.With(V4OPRUpdate, 1).Valid(),
+		"the grading versions must activate in order: pegfreefloatingprice <= v4oprupdate")
+	for _, g := range [][]Grading{
+		{{Version: 4, Height: 240000}},
+		{{Version: 5, Height: 231619}},
+		{{Version: 5, Height: 250000}, {Version: 6, Height: 240000}},
+	} {
+		table := MainNet
+		table.Gradings = g
+		assert.Error(t, table.Valid(), g)
+	}
+
+	later := MainNet
+	later.Version = Version + 1
+	assert.Error(t, later.Valid())
+}
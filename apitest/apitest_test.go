@@ -0,0 +1,149 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"testing"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/pegnet/pegnetd/srv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSchema(t *testing.T) {
+	type inner struct {
+		Amount uint64 `json:"amount"`
+		Memo   string `json:"memo,omitempty"`
+	}
+	type schema struct {
+		Name   string           `json:"name"`
+		Inner  inner            `json:"inner"`
+		List   []inner          `json:"list"`
+		Map    map[string]inner `json:"map,omitempty"`
+		Ptr    *inner           `json:"ptr"`
+		Hidden int              `json:"-"`
+	}
+
+	vectors := []struct {
+		Name   string
+		Result string
+		Strict bool
+		Err    string
+	}{
+		{"complete", `{"name":"a","inner":{"amount":1},"list":[{"amount":2,"memo":"x"}],"ptr":null}`, true, ""},
+		{"omitempty missing", `{"name":"a","inner":{"amount":1},"list":[],"ptr":{"amount":1}}`, true, ""},
+		{"field missing", `{"name":"a","list":[],"ptr":null}`, false, "result.inner: is missing"},
+		{"field null", `{"name":null,"inner":{"amount":1},"list":[],"ptr":null}`, false, "result.name: is null, expected a string"},
+		{"nested missing", `{"name":"a","inner":{"amount":1},"list":[{}],"ptr":null}`, false, "result.list[0].amount: is missing"},
+		{"map value missing", `{"name":"a","inner":{"amount":1},"list":[],"map":{"k":{}},"ptr":null}`, false, "result.map.k.amount: is missing"},
+		{"wrong type", `{"name":1,"inner":{"amount":1},"list":[],"ptr":null}`, false, "json: cannot unmarshal"},
+		{"negative amount", `{"name":"a","inner":{"amount":-1},"list":[],"ptr":null}`, false, "json: cannot unmarshal"},
+		{"unknown field", `{"name":"a","inner":{"amount":1},"list":[],"ptr":null,"extra":1}`, false, ""},
+		{"unknown field strict", `{"name":"a","inner":{"amount":1},"list":[],"ptr":null,"extra":1}`, true, "unknown field"},
+		{"not an object", `[]`, false, "json: cannot unmarshal"},
+	}
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			err := CheckSchema(json.RawMessage(v.Result), schema{}, v.Strict)
+			if v.Err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), v.Err)
+		})
+	}
+
+	// Slices of a schema are checked element by element
+	assert.NoError(t, CheckSchema(json.RawMessage(`[{"amount":1}]`), []inner{}, true))
+	assert.Error(t, CheckSchema(json.RawMessage(`[{"memo":"x"}]`), []inner{}, true))
+}
+
+func TestCheck(t *testing.T) {
+	parse := func(s string) response {
+		var res response
+		require.NoError(t, json.Unmarshal([]byte(s), &res))
+		return res
+	}
+	result := valid("", nil, srv.ResultGetSyncStatus{})
+	errors := invalid("", nil, codeInvalidParams, codeNotFound)
+
+	vectors := []struct {
+		Name     string
+		Response string
+		Case     Case
+		Status   Status
+	}{
+		{"result", `{"jsonrpc":"2.0","id":1,"result":{"syncheight":1,"factomheight":1,"blockssincerate":0}}`, result, Pass},
+		{"accepted error", `{"jsonrpc":"2.0","id":1,"error":{"code":-32809,"message":"Not Found"}}`, errors, Pass},
+		{"other error", `{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"Internal error"}}`, errors, Fail},
+		{"unexpected result", `{"jsonrpc":"2.0","id":1,"result":{}}`, errors, Fail},
+		{"unexpected error", `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"Invalid params"}}`, result, Fail},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"result":{"syncheight":1,"factomheight":1,"blockssincerate":0}}`, result, Fail},
+		{"wrong id", `{"jsonrpc":"2.0","id":2,"result":{"syncheight":1,"factomheight":1,"blockssincerate":0}}`, result, Fail},
+		{"result and error", `{"jsonrpc":"2.0","id":1,"result":{},"error":{"code":-32809,"message":"Not Found"}}`, errors, Fail},
+		{"error without message", `{"jsonrpc":"2.0","id":1,"error":{"code":-32809}}`, errors, Fail},
+		{"result out of schema", `{"jsonrpc":"2.0","id":1,"result":{"syncheight":1}}`, result, Fail},
+	}
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			status, detail := check(json.RawMessage("1"), parse(v.Response), v.Case, false)
+			assert.Equal(t, v.Status, status, detail)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	methods := jrpc.MethodMap{
+		"properties": func(context.Context, json.RawMessage) interface{} {
+			return srv.PegnetdProperties{}
+		},
+		// A result that is missing fields
+		"get-sync-status": func(context.Context, json.RawMessage) interface{} {
+			return map[string]int{"syncheight": 10}
+		},
+	}
+	ts := httptest.NewServer(jrpc.HTTPRequestHandler(methods, log.New(ioutil.Discard, "", 0)))
+	defer ts.Close()
+
+	outcomes := Run(context.Background(), ts.URL, Options{})
+	statuses := make(map[string]Status)
+	for _, o := range outcomes {
+		statuses[o.Method+"/"+o.Case] = o.Status
+	}
+
+	for _, name := range []string{"parse error", "no method", "json-rpc 1.0", "unknown method", "empty batch", "batch", "notification"} {
+		assert.Equal(t, Pass, statuses["json-rpc/"+name], name)
+	}
+	assert.Equal(t, Pass, statuses["properties/no params"])
+	assert.Equal(t, Fail, statuses["get-sync-status/no params"])
+	// The stub has no rich list, so there is no address to sample
+	assert.Equal(t, Skip, statuses["get-transactions/address"])
+	assert.Equal(t, Fail, statuses["get-supply/no params"])
+
+	// Only the selected methods run, without the protocol cases
+	outcomes = Run(context.Background(), ts.URL, Options{Methods: []string{"properties"}})
+	require.Len(t, outcomes, 1)
+	assert.Equal(t, Pass, outcomes[0].Status)
+}
+
+func TestMethods(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, m := range Methods {
+		assert.False(t, seen[m.Name], "%s is listed twice", m.Name)
+		seen[m.Name] = true
+		for _, cs := range m.AllCases() {
+			assert.True(t, cs.Result != nil || len(cs.Errors) > 0, "%s/%s expects nothing", m.Name, cs.Name)
+		}
+	}
+	// Every rpc of the api has cases
+	for _, name := range srv.MethodNames() {
+		_, ok := Lookup(name)
+		assert.True(t, ok, "%s has no cases", name)
+	}
+	assert.Len(t, Methods, len(srv.MethodNames()))
+}
@@ -0,0 +1,370 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pegnet/pegnetd/srv"
+)
+
+// Status of a case
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Outcome of a case
+type Outcome struct {
+	Method   string
+	Case     string
+	Status   Status
+	Detail   string
+	Duration time.Duration
+}
+
+// Options of a run
+type Options struct {
+	AdminToken     string
+	SchedulerToken string
+	// Methods only runs the cases of the methods, all if empty
+	Methods []string
+	// Strict rejects fields of the results that are not in their types
+	Strict  bool
+	Timeout time.Duration
+}
+
+// Run runs the suite against the api of the target, the first outcomes are
+// of the json-rpc protocol, followed by the methods in the order of Methods.
+func Run(ctx context.Context, target string, opts Options) []Outcome {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	c := &client{
+		url:  strings.TrimSuffix(target, "/") + "/v1",
+		http: &http.Client{Timeout: opts.Timeout},
+	}
+
+	var outcomes []Outcome
+	if len(opts.Methods) == 0 {
+		outcomes = append(outcomes, c.protocol(ctx)...)
+	}
+
+	sample := c.sample(ctx)
+	sample.AdminToken = opts.AdminToken
+	sample.SchedulerToken = opts.SchedulerToken
+
+	for _, m := range Methods {
+		if !selected(m.Name, opts.Methods) {
+			continue
+		}
+		for _, cs := range m.AllCases() {
+			if ctx.Err() != nil {
+				return outcomes
+			}
+			outcomes = append(outcomes, c.run(ctx, m.Name, cs, sample, opts.Strict))
+		}
+	}
+	return outcomes
+}
+
+func selected(name string, methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+type client struct {
+	url  string
+	http *http.Client
+	id   int
+}
+
+// response is a json-rpc 2.0 response, the fields are raw to check the
+// envelope
+type response struct {
+	JSONRPC *string         `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    *int            `json:"code"`
+		Message *string         `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	} `json:"error"`
+}
+
+func (r response) code() int {
+	if r.Error == nil || r.Error.Code == nil {
+		return 0
+	}
+	return *r.Error.Code
+}
+
+// envelope checks the response against the json-rpc 2.0 spec
+func (r response) envelope(id json.RawMessage) error {
+	if r.JSONRPC == nil || *r.JSONRPC != "2.0" {
+		return fmt.Errorf(`"jsonrpc" is not "2.0"`)
+	}
+	if !bytes.Equal(compact(r.ID), compact(id)) {
+		return fmt.Errorf("id %s, expected %s", r.ID, id)
+	}
+	hasResult := len(r.Result) > 0
+	if hasResult == (r.Error != nil) {
+		return fmt.Errorf("expected exactly one of result and error")
+	}
+	if r.Error != nil && (r.Error.Code == nil || r.Error.Message == nil) {
+		return fmt.Errorf("error without code or message")
+	}
+	return nil
+}
+
+func compact(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// post sends the body and returns the http status and body of the response
+func (c *client) post(ctx context.Context, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// call sends a request of the method and returns its id and response
+func (c *client) call(ctx context.Context, method string, params interface{}) (json.RawMessage, response, error) {
+	c.id++
+	id := json.RawMessage(fmt.Sprint(c.id))
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return id, response{}, err
+	}
+	res, err := c.raw(ctx, body)
+	return id, res, err
+}
+
+func (c *client) raw(ctx context.Context, body []byte) (response, error) {
+	var res response
+	_, data, err := c.post(ctx, body)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("invalid response %q: %v", truncate(data), err)
+	}
+	return res, nil
+}
+
+// result calls the method and decodes the result into v
+func (c *client) result(ctx context.Context, method string, params, v interface{}) bool {
+	_, res, err := c.call(ctx, method, params)
+	if err != nil || res.Error != nil || len(res.Result) == 0 {
+		return false
+	}
+	return json.Unmarshal(res.Result, v) == nil
+}
+
+// sample finds the data of the cases with the api of the target
+func (c *client) sample(ctx context.Context) *Sample {
+	s := new(Sample)
+
+	var sync struct {
+		Height uint32 `json:"syncheight"`
+	}
+	if c.result(ctx, "get-sync-status", nil, &sync) {
+		s.Height = sync.Height
+	}
+
+	var rich []struct {
+		Address string `json:"address"`
+	}
+	if c.result(ctx, "get-rich-list", map[string]interface{}{"asset": "PEG", "count": 1}, &rich) && len(rich) > 0 {
+		s.Address = rich[0].Address
+	}
+	if s.Address == "" {
+		return s
+	}
+
+	var txs struct {
+		Actions []struct {
+			Hash string `json:"hash"`
+			TxID string `json:"txid"`
+		} `json:"actions"`
+	}
+	if c.result(ctx, "get-transactions", map[string]interface{}{"address": s.Address, "desc": true}, &txs) && len(txs.Actions) > 0 {
+		s.EntryHash = txs.Actions[0].Hash
+		s.TxID = txs.Actions[0].TxID
+	}
+	return s
+}
+
+func (c *client) run(ctx context.Context, method string, cs Case, s *Sample, strict bool) Outcome {
+	out := Outcome{Method: method, Case: cs.Name}
+	params, ok := cs.Params(s)
+	if !ok {
+		out.Status, out.Detail = Skip, "no sample of the target"
+		return out
+	}
+
+	start := time.Now()
+	id, res, err := c.call(ctx, method, params)
+	out.Duration = time.Since(start)
+	if err != nil {
+		out.Status, out.Detail = Fail, err.Error()
+		return out
+	}
+	out.Status, out.Detail = check(id, res, cs, strict)
+	return out
+}
+
+// check checks the response of a case
+func check(id json.RawMessage, res response, cs Case, strict bool) (Status, string) {
+	if err := res.envelope(id); err != nil {
+		return Fail, err.Error()
+	}
+	if res.Error != nil {
+		for _, code := range cs.Errors {
+			if res.code() == code {
+				return Pass, ""
+			}
+		}
+		return Fail, fmt.Sprintf("error %d %q, expected %s", res.code(), *res.Error.Message, expected(cs))
+	}
+	if cs.Result == nil {
+		return Fail, fmt.Sprintf("result %s, expected %s", truncate(res.Result), expected(cs))
+	}
+	if err := CheckSchema(res.Result, cs.Result, strict); err != nil {
+		return Fail, err.Error()
+	}
+	return Pass, ""
+}
+
+func expected(cs Case) string {
+	var exp []string
+	if cs.Result != nil {
+		exp = append(exp, "a result")
+	}
+	for _, code := range cs.Errors {
+		exp = append(exp, fmt.Sprint(code))
+	}
+	return strings.Join(exp, " or ")
+}
+
+func truncate(data []byte) string {
+	if len(data) > 200 {
+		return string(data[:200]) + "..."
+	}
+	return string(data)
+}
+
+// protocol checks the json-rpc 2.0 protocol errors, batches and
+// notifications
+func (c *client) protocol(ctx context.Context) []Outcome {
+	errorCase := func(name, body string, code int) Outcome {
+		out := Outcome{Method: "json-rpc", Case: name}
+		start := time.Now()
+		res, err := c.raw(ctx, []byte(body))
+		out.Duration = time.Since(start)
+		if err != nil {
+			out.Status, out.Detail = Fail, err.Error()
+			return out
+		}
+		// The id is null if it could not be read from the request
+		id := json.RawMessage("1")
+		if string(compact(res.ID)) == "null" {
+			id = res.ID
+		}
+		out.Status, out.Detail = check(id, res, invalid(name, nil, code), false)
+		return out
+	}
+
+	outcomes := []Outcome{
+		errorCase("parse error", `{`, codeParse),
+		errorCase("no method", `{"jsonrpc":"2.0","id":1}`, codeInvalidRequest),
+		errorCase("json-rpc 1.0", `{"jsonrpc":"1.0","id":1,"method":"properties"}`, codeInvalidRequest),
+		errorCase("unknown method", `{"jsonrpc":"2.0","id":1,"method":"pegnetd-test-api"}`, codeMethodNotFound),
+		errorCase("empty batch", `[]`, codeInvalidRequest),
+	}
+
+	// A batch is answered with a response of every request that is not a
+	// notification
+	out := Outcome{Method: "json-rpc", Case: "batch"}
+	start := time.Now()
+	_, data, err := c.post(ctx, []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"properties"},
+		{"jsonrpc":"2.0","method":"properties"},
+		{"jsonrpc":"2.0","id":2,"method":"pegnetd-test-api"}]`))
+	out.Duration = time.Since(start)
+	out.Status, out.Detail = checkBatch(data, err)
+	outcomes = append(outcomes, out)
+
+	// A notification is not answered
+	out = Outcome{Method: "json-rpc", Case: "notification"}
+	start = time.Now()
+	_, data, err = c.post(ctx, []byte(`{"jsonrpc":"2.0","method":"properties"}`))
+	out.Duration = time.Since(start)
+	out.Status = Pass
+	if err != nil {
+		out.Status, out.Detail = Fail, err.Error()
+	} else if len(bytes.TrimSpace(data)) > 0 {
+		out.Status, out.Detail = Fail, fmt.Sprintf("response %q to a notification", truncate(data))
+	}
+	return append(outcomes, out)
+}
+
+func checkBatch(data []byte, err error) (Status, string) {
+	if err != nil {
+		return Fail, err.Error()
+	}
+	var batch []response
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return Fail, fmt.Sprintf("invalid batch response %q: %v", truncate(data), err)
+	}
+	if len(batch) != 2 {
+		return Fail, fmt.Sprintf("%d responses, expected 2", len(batch))
+	}
+	for _, res := range batch {
+		switch string(compact(res.ID)) {
+		case "1":
+			if status, detail := check(res.ID, res, valid("", nil, srv.PegnetdProperties{}), false); status == Fail {
+				return status, "id 1: " + detail
+			}
+		case "2":
+			if status, detail := check(res.ID, res, invalid("", nil, codeMethodNotFound), false); status == Fail {
+				return status, "id 2: " + detail
+			}
+		default:
+			return Fail, fmt.Sprintf("response of id %s", res.ID)
+		}
+	}
+	return Pass, ""
+}
@@ -0,0 +1,146 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// CheckSchema checks the result against the go type of the schema: it must
+// decode into the type, and every field without omitempty must be present
+// and not null. Unknown fields are only rejected if strict.
+func CheckSchema(result json.RawMessage, schema interface{}, strict bool) error {
+	t := reflect.TypeOf(schema)
+	d := json.NewDecoder(bytes.NewReader(result))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+	if err := d.Decode(reflect.New(t).Interface()); err != nil {
+		return err
+	}
+
+	var generic interface{}
+	d = json.NewDecoder(bytes.NewReader(result))
+	d.UseNumber()
+	if err := d.Decode(&generic); err != nil {
+		return err
+	}
+	return required(t, generic, "result")
+}
+
+// required walks the decoded json along the type. The fields of types that
+// decode themselves are not walked.
+func required(t reflect.Type, v interface{}, path string) error {
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return nil
+		}
+		return fmt.Errorf("%s: is null, expected %s", path, kind(t))
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return required(t.Elem(), v, path)
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		return requiredFields(t, obj, path)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nil // base64
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		for i := range arr {
+			if err := required(t.Elem(), arr[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		for key, value := range obj {
+			if err := required(t.Elem(), value, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func requiredFields(t reflect.Type, obj map[string]interface{}, path string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := requiredFields(embedded, obj, path); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		value, ok := obj[name]
+		if !ok {
+			if strings.Contains(opts, "omitempty") {
+				continue
+			}
+			return fmt.Errorf("%s.%s: is missing", path, name)
+		}
+		if value == nil && strings.Contains(opts, "omitempty") {
+			continue
+		}
+		if err := required(f.Type, value, path+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func kind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct:
+		return "an object"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a bool"
+	}
+	return "a number"
+}
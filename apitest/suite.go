@@ -0,0 +1,520 @@
+// Package apitest is a conformance suite of the pegnetd api. It calls every
+// rpc of a remote node, or of a proxy in front of one, with valid and invalid
+// params, and checks the results against the result types of the srv package
+// and the errors against the codes pegnetd returns. The suite only reads: the
+// methods that change the state of the node are called with invalid params or
+// tokens, which are rejected before anything is changed.
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/srv"
+)
+
+// Sample is the data of the target the params of the cases are made of. It
+// is found with the target's own api, the cases that need a value it could
+// not find are skipped.
+type Sample struct {
+	Height uint32
+	// Address holds PEG, it is the top of the rich list
+	Address string
+	// EntryHash and TxID are of a transaction of the address
+	EntryHash string
+	TxID      string
+
+	// The tokens of the methods that need them, their valid cases are
+	// skipped without them
+	AdminToken     string
+	SchedulerToken string
+}
+
+// Params returns the params of a case, false skips the case
+type Params func(s *Sample) (interface{}, bool)
+
+// noParams omits the params of the request
+var noParams = static(nil)
+
+func static(params interface{}) Params {
+	return func(*Sample) (interface{}, bool) { return params, true }
+}
+
+// Case is a call of a method
+type Case struct {
+	Name   string
+	Params Params
+	// Result is a value of the type of the result, nil if only an error is
+	// expected
+	Result interface{}
+	// Errors are the codes of the accepted errors, empty if only a result
+	// is expected
+	Errors []int
+}
+
+func valid(name string, params Params, result interface{}, errors ...int) Case {
+	return Case{Name: name, Params: params, Result: result, Errors: errors}
+}
+
+func invalid(name string, params Params, errors ...int) Case {
+	return Case{Name: name, Params: params, Errors: errors}
+}
+
+// Method are the cases of an rpc. Every method that takes params is also
+// called with params of the wrong type and an unknown param.
+type Method struct {
+	Name     string
+	NoParams bool
+	Cases    []Case
+}
+
+// AllCases returns the cases of the method with the generic invalid params
+func (m Method) AllCases() []Case {
+	cases := append([]Case(nil), m.Cases...)
+	if m.NoParams {
+		return cases
+	}
+	return append(cases,
+		invalid("params of the wrong type", static(json.RawMessage(`[true]`)), codeInvalidParams),
+		invalid("an unknown param", static(map[string]interface{}{"pegnetdtestapi": 1}), codeInvalidParams))
+}
+
+const (
+	codeParse          = int(jrpc.ErrorCodeParse)
+	codeInvalidRequest = int(jrpc.ErrorCodeInvalidRequest)
+	codeMethodNotFound = int(jrpc.ErrorCodeMethodNotFound)
+	codeInvalidParams  = int(jrpc.ErrorCodeInvalidParams)
+
+	codeTransactionNotFound = -32803
+	codeNoEC                = -32806
+	codeAddressNotFound     = -32808
+	codeNotFound            = -32809
+	codeUnauthorized        = -32810
+	codeSchedulerDisabled   = -32811
+	codeAdminDisabled       = -32813
+	codeRegtestDisabled     = -32814
+	codeRateInjection       = -32815
+	codePriceCheckDisabled  = -32819
+)
+
+// invalidToken is never the token of a node
+const invalidToken = "pegnetd-test-api-invalid-token"
+
+// unknownAddress is an address that never held anything
+var unknownAddress = func() string {
+	var adr factom.FAAddress
+	copy(adr[:], "pegnetd test-api unknown address")
+	return adr.String()
+}()
+
+// unknownHash is an entry hash that is not a transaction
+const unknownHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func address(f func(adr string) interface{}) Params {
+	return func(s *Sample) (interface{}, bool) {
+		return f(s.Address), s.Address != ""
+	}
+}
+
+func entryHash(f func(hash string) interface{}) Params {
+	return func(s *Sample) (interface{}, bool) {
+		return f(s.EntryHash), s.EntryHash != ""
+	}
+}
+
+func height(f func(height uint32) interface{}) Params {
+	return func(s *Sample) (interface{}, bool) {
+		return f(s.Height), s.Height > 0
+	}
+}
+
+func adminToken(f func(token string) interface{}) Params {
+	return func(s *Sample) (interface{}, bool) {
+		return f(s.AdminToken), s.AdminToken != ""
+	}
+}
+
+func schedulerToken(f func(token string) interface{}) Params {
+	return func(s *Sample) (interface{}, bool) {
+		return f(s.SchedulerToken), s.SchedulerToken != ""
+	}
+}
+
+// since is a range of the last 100 heights
+func since(height uint32) int {
+	if height < 100 {
+		return 0
+	}
+	return int(height) - 100
+}
+
+// transactions is srv.ResultGetTransactions with the type of the actions
+type transactions struct {
+	Actions    []pegnet.HistoryTransaction `json:"actions"`
+	Count      int                         `json:"count"`
+	NextOffset int                         `json:"nextoffset"`
+}
+
+func repeat(s string, n int) []string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = s
+	}
+	return list
+}
+
+// Methods are the cases of every rpc of srv
+var Methods = []Method{
+	{Name: "properties", NoParams: true, Cases: []Case{
+		valid("no params", noParams, srv.PegnetdProperties{}),
+	}},
+	{Name: "get-sync-status", NoParams: true, Cases: []Case{
+		valid("no params", noParams, srv.ResultGetSyncStatus{}),
+	}},
+	{Name: "get-ec-status", NoParams: true, Cases: []Case{
+		valid("no params", noParams, node.ECStatus{}, codeNoEC),
+	}},
+	{Name: "get-price-checks", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetPriceChecks{}, codePriceCheckDisabled),
+		valid("flagged", static(srv.ParamsGetPriceChecks{Flagged: true, Limit: 10}), srv.ResultGetPriceChecks{}, codePriceCheckDisabled),
+		invalid("limit too large", static(srv.ParamsGetPriceChecks{Limit: pegnet.PriceChecksLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "get-pegnet-rates", Cases: []Case{
+		valid("latest", noParams, map[string]uint64{}, codeNotFound),
+		valid("synced height", height(func(h uint32) interface{} { return srv.ParamsGetPegnetRates{Height: h} }), map[string]uint64{}, codeNotFound),
+		valid("verbose", height(func(h uint32) interface{} { return srv.ParamsGetPegnetRates{Height: h, Verbose: true} }), srv.ResultGetPegnetRatesVerbose{}, codeNotFound),
+		valid("quote", height(func(h uint32) interface{} { return srv.ParamsGetPegnetRates{Height: h, Quote: "pXBT"} }), map[string]uint64{}, codeNotFound),
+		valid("verbose quote", height(func(h uint32) interface{} {
+			return srv.ParamsGetPegnetRates{Height: h, Verbose: true, Quote: "pEUR"}
+		}), srv.ResultGetPegnetRatesVerbose{}, codeNotFound),
+		invalid("invalid quote", static(srv.ParamsGetPegnetRates{Quote: "BTC"}), codeInvalidParams),
+		invalid("unsynced height", static(srv.ParamsGetPegnetRates{Height: 4e9}), codeNotFound),
+		invalid("negative height", static(map[string]int{"height": -1}), codeInvalidParams),
+	}},
+	{Name: "get-rate-gaps", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} { return srv.ParamsGetRateGaps{Start: since(h), Stop: int(h)} }), srv.ResultGetRateGaps{}),
+		invalid("stop before start", static(srv.ParamsGetRateGaps{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "get-rate-changes", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetRateChanges{}, codeNotFound),
+		valid("last 100 heights", height(func(h uint32) interface{} { return srv.ParamsGetRateChanges{From: since(h), To: int(h)} }), srv.ResultGetRateChanges{}, codeNotFound),
+		invalid("to before from", static(srv.ParamsGetRateChanges{From: 10, To: 5}), codeInvalidParams),
+	}},
+	{Name: "get-pegnet-balances", Cases: []Case{
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetPegnetBalances{Address: a} }), map[string]uint64{}),
+		valid("including pending", address(func(a string) interface{} {
+			return srv.ParamsGetPegnetBalances{Address: a, IncludePending: true}
+		}), srv.ResultPegnetBalancesPending{}),
+		valid("with usd", address(func(a string) interface{} {
+			return srv.ParamsGetPegnetBalances{Address: a, IncludeUSD: true}
+		}), srv.ResultPegnetBalancesUSD{}),
+		valid("with usd at height", height(func(h uint32) interface{} {
+			return srv.ParamsGetPegnetBalances{Address: unknownAddress, IncludeUSD: true, Height: h}
+		}), srv.ResultPegnetBalancesUSD{}, codeAddressNotFound),
+		valid("unknown address", static(srv.ParamsGetPegnetBalances{Address: unknownAddress}), map[string]uint64{}, codeAddressNotFound),
+		invalid("no address", static(srv.ParamsGetPegnetBalances{}), codeInvalidParams),
+		invalid("invalid address", static(srv.ParamsGetPegnetBalances{Address: "FA1"}), codeInvalidParams),
+		invalid("height without usd", static(srv.ParamsGetPegnetBalances{Address: unknownAddress, Height: 1}), codeInvalidParams),
+	}},
+	{Name: "get-account-balances", Cases: []Case{
+		valid("all accounts", noParams, srv.ResultGetAccountBalances{}),
+		invalid("unknown account", static(srv.ParamsGetAccountBalances{Account: "pegnetdtestapi"}), codeNotFound),
+	}},
+	{Name: "list-addresses", Cases: []Case{
+		valid("all accounts", noParams, []srv.WalletAddress{}),
+		invalid("unknown account", static(srv.ParamsGetAccountBalances{Account: "pegnetdtestapi"}), codeNotFound),
+	}},
+	{Name: "get-address-stats", Cases: []Case{
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetAddressStats{Address: a} }), srv.ResultGetAddressStats{}),
+		invalid("unknown address", static(srv.ParamsGetAddressStats{Address: unknownAddress}), codeAddressNotFound),
+		invalid("invalid address", static(srv.ParamsGetAddressStats{Address: "FA1"}), codeInvalidParams),
+	}},
+	{Name: "get-address-events", Cases: []Case{
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetAddressEvents{Address: a, Desc: true} }), srv.ResultGetAddressEvents{}),
+		invalid("invalid asset", address(func(a string) interface{} { return srv.ParamsGetAddressEvents{Address: a, Asset: "pXYZ"} }), codeInvalidParams),
+		invalid("negative offset", static(srv.ParamsGetAddressEvents{Address: unknownAddress, Offset: -1}), codeInvalidParams),
+	}},
+	{Name: "get-transactions", Cases: []Case{
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetPegnetTransaction{Address: a, Desc: true} }), transactions{}),
+		valid("entry hash", entryHash(func(h string) interface{} { return srv.ParamsGetPegnetTransaction{Hash: h} }), transactions{}),
+		valid("height", height(func(h uint32) interface{} { return srv.ParamsGetPegnetTransaction{Height: int(h)} }), transactions{}, codeTransactionNotFound),
+		valid("sent by amount", address(func(a string) interface{} {
+			return srv.ParamsGetPegnetTransaction{Address: a, Direction: pegnet.DirectionSent, Sort: pegnet.SortAmount}
+		}), transactions{}, codeTransactionNotFound),
+		invalid("unknown entry hash", static(srv.ParamsGetPegnetTransaction{Hash: unknownHash}), codeTransactionNotFound),
+		invalid("no filter", static(srv.ParamsGetPegnetTransaction{}), codeInvalidParams),
+		invalid("two filters", static(srv.ParamsGetPegnetTransaction{Address: unknownAddress, Hash: unknownHash}), codeInvalidParams),
+		invalid("invalid sort", static(srv.ParamsGetPegnetTransaction{Address: unknownAddress, Sort: "size"}), codeInvalidParams),
+		invalid("invalid txid", static(srv.ParamsGetPegnetTransaction{TxID: "0-xyz"}), codeInvalidParams),
+	}},
+	{Name: "get-transaction", Cases: []Case{
+		valid("txid", func(s *Sample) (interface{}, bool) {
+			return srv.ParamsGetPegnetTransaction{TxID: s.TxID}, s.TxID != ""
+		}, transactions{}),
+		invalid("no txid", static(srv.ParamsGetPegnetTransaction{Address: unknownAddress}), codeInvalidParams),
+		invalid("unknown txid", static(srv.ParamsGetPegnetTransaction{TxID: "0-" + unknownHash}), codeTransactionNotFound),
+	}},
+	{Name: "get-transactions-by-hashes", Cases: []Case{
+		valid("found and missing", entryHash(func(h string) interface{} {
+			return map[string][]string{"entryhashes": {h, unknownHash, h}}
+		}), srv.ResultGetTransactionsByHashes{}),
+		valid("missing", static(map[string][]string{"entryhashes": {unknownHash}}), srv.ResultGetTransactionsByHashes{}),
+		invalid("no entry hashes", static(map[string][]string{"entryhashes": {}}), codeInvalidParams),
+		invalid("too many entry hashes", static(map[string][]string{"entryhashes": repeat(unknownHash, pegnet.HashesLimit+1)}), codeInvalidParams),
+		invalid("invalid entry hash", static(map[string][]string{"entryhashes": {"00"}}), codeInvalidParams),
+	}},
+	{Name: "get-transaction-status", Cases: []Case{
+		valid("entry hash", entryHash(func(h string) interface{} { return map[string]string{"entryhash": h} }), srv.ResultGetTransactionStatus{}),
+		invalid("unknown entry hash", static(map[string]string{"entryhash": unknownHash}), codeTransactionNotFound),
+		invalid("no params", static(map[string]string{}), codeInvalidParams),
+		invalid("entry hash and txid", static(map[string]string{"entryhash": unknownHash, "txid": "0-" + unknownHash}), codeInvalidParams),
+	}},
+	{Name: "get-conversion-result", Cases: []Case{
+		valid("entry hash", entryHash(func(h string) interface{} { return map[string]string{"entryhash": h} }), srv.ResultGetConversionResult{}, codeInvalidParams),
+		invalid("unknown entry hash", static(map[string]string{"entryhash": unknownHash}), codeTransactionNotFound),
+		invalid("no entry hash", static(map[string]string{}), codeInvalidParams),
+	}},
+	{Name: "export-statement", Cases: []Case{
+		valid("csv", address(func(a string) interface{} { return srv.ParamsExportStatement{Address: a} }), srv.ResultExportStatement{}),
+		valid("ofx", address(func(a string) interface{} {
+			return srv.ParamsExportStatement{Address: a, Format: srv.StatementOFX, Asset: "PEG"}
+		}), srv.ResultExportStatement{}),
+		invalid("invalid format", static(srv.ParamsExportStatement{Address: unknownAddress, Format: "pdf"}), codeInvalidParams),
+		invalid("no address", static(srv.ParamsExportStatement{}), codeInvalidParams),
+	}},
+	{Name: "get-rich-list", Cases: []Case{
+		valid("PEG", static(srv.ParamsGetRichList{Asset: "PEG", Count: 10}), []srv.ResultGetRichList{}),
+		valid("pFCT in PEG", static(srv.ParamsGetRichList{Asset: "pFCT", Count: 10, Quote: "PEG"}), []srv.ResultGetRichList{}, codeInvalidParams),
+		valid("PEG at height", height(func(h uint32) interface{} {
+			return srv.ParamsGetRichList{Asset: "PEG", Count: 10, Height: h}
+		}), []srv.ResultGetRichList{}),
+		invalid("unsynced height", static(srv.ParamsGetRichList{Asset: "PEG", Height: 4e9}), codeInvalidParams),
+		invalid("invalid asset", static(srv.ParamsGetRichList{Asset: "pXYZ"}), codeInvalidParams),
+		invalid("negative count", static(srv.ParamsGetRichList{Asset: "PEG", Count: -1}), codeInvalidParams),
+	}},
+	{Name: "get-global-rich-list", Cases: []Case{
+		valid("top 10", static(srv.ParamsGetGlobalRichList{Count: 10}), []srv.ResultGlobalRichList{}),
+		invalid("invalid quote", static(srv.ParamsGetGlobalRichList{Quote: "pXYZ"}), codeInvalidParams),
+	}},
+	{Name: "get-holders", Cases: []Case{
+		valid("PEG", static(srv.ParamsGetHolders{Asset: "PEG", Limit: 10}), srv.ResultGetHolders{}),
+		invalid("invalid asset", static(srv.ParamsGetHolders{Asset: "pXYZ"}), codeInvalidParams),
+		invalid("limit too large", static(srv.ParamsGetHolders{Asset: "PEG", Limit: pegnet.HoldersLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "get-distribution-stats", Cases: []Case{
+		valid("all assets", noParams, srv.ResultGetDistributionStats{}),
+		valid("PEG", static(srv.ParamsGetDistributionStats{Asset: "PEG"}), srv.ResultGetDistributionStats{}),
+		invalid("invalid asset", static(srv.ParamsGetDistributionStats{Asset: "pXYZ"}), codeInvalidParams),
+	}},
+	{Name: "get-miner-distribution", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} {
+			return srv.ParamsGetMiningDominance{Start: since(h), Stop: int(h)}
+		}), pegnet.MinerDominanceResult{}),
+		invalid("stop before start", static(srv.ParamsGetMiningDominance{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "get-opr-stats", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} { return srv.ParamsGetOPRStats{Start: since(h), Stop: int(h)} }), pegnet.OPRStats{}),
+		invalid("stop before start", static(srv.ParamsGetOPRStats{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "get-miner", Cases: []Case{
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetMiner{Address: a, Limit: 10} }), srv.ResultGetMiner{}, codeNotFound),
+		valid("unknown address", static(srv.ParamsGetMiner{Address: unknownAddress}), srv.ResultGetMiner{}, codeNotFound),
+		invalid("invalid address", static(srv.ParamsGetMiner{Address: "FA1"}), codeInvalidParams),
+		invalid("limit too large", static(srv.ParamsGetMiner{Address: unknownAddress, Limit: pegnet.MinersLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "get-miners", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} { return srv.ParamsGetMiners{Start: since(h), Stop: int(h)} }), srv.ResultGetMiners{}),
+		invalid("stop before start", static(srv.ParamsGetMiners{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "get-bank", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetBank{}, codeInvalidParams, codeNotFound),
+		invalid("before the bank", static(srv.ParamsGetBank{Height: -1}), codeInvalidParams),
+	}},
+	{Name: "get-pegnet-issuance", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetIssuance{}),
+		valid("synced height", height(func(h uint32) interface{} { return srv.ParamsGetPegnetIssuance{Height: int(h)} }), srv.ResultGetIssuance{}, codeInvalidParams),
+		invalid("unsynced height", static(srv.ParamsGetPegnetIssuance{Height: 4e9}), codeInvalidParams),
+	}},
+	{Name: "get-supply", NoParams: true, Cases: []Case{
+		valid("no params", noParams, srv.ResultGetSupply{}),
+	}},
+	{Name: "get-supply-history", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} {
+			return srv.ParamsGetSupplyHistory{Start: since(h), Stop: int(h), Asset: "PEG"}
+		}), srv.ResultGetSupplyHistory{}),
+		invalid("invalid asset", static(srv.ParamsGetSupplyHistory{Asset: "pXYZ"}), codeInvalidParams),
+		invalid("stop before start", static(srv.ParamsGetSupplyHistory{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "get-supply-composition", Cases: []Case{
+		valid("all assets", noParams, srv.ResultGetSupplyComposition{}),
+		valid("PEG", static(srv.ParamsGetSupplyComposition{Asset: "PEG"}), srv.ResultGetSupplyComposition{}),
+		invalid("invalid asset", static(srv.ParamsGetSupplyComposition{Asset: "pXYZ"}), codeInvalidParams),
+	}},
+	{Name: "get-ledger", Cases: []Case{
+		valid("latest", static(srv.ParamsGetLedger{Limit: 10}), srv.ResultGetLedger{}),
+		invalid("invalid after", static(srv.ParamsGetLedger{After: "FA1"}), codeInvalidParams),
+		invalid("limit too large", static(srv.ParamsGetLedger{Limit: pegnet.LedgerLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "get-balance-history", Cases: []Case{
+		valid("PEG", address(func(adr string) interface{} {
+			return srv.ParamsGetBalanceHistory{Address: adr, Asset: "PEG", Interval: 1}
+		}), srv.ResultGetBalanceHistory{}),
+		invalid("no address", static(srv.ParamsGetBalanceHistory{Asset: "PEG"}), codeInvalidParams),
+		invalid("invalid asset", static(srv.ParamsGetBalanceHistory{Address: unknownAddress, Asset: "BTC"}), codeInvalidParams),
+		invalid("too many points", static(srv.ParamsGetBalanceHistory{Address: unknownAddress, Asset: "PEG", Start: 1, Stop: 2 + pegnet.BalanceHistoryLimit, Interval: 1}), codeInvalidParams),
+	}},
+	{Name: "get-network-stats", Cases: []Case{
+		valid("last 30 days", func(*Sample) (interface{}, bool) {
+			return srv.ParamsGetNetworkStats{Start: time.Now().AddDate(0, 0, -30).Format(srv.NetworkStatsDateFormat)}, true
+		}, []srv.ResultNetworkStats{}),
+		invalid("invalid start", static(srv.ParamsGetNetworkStats{Start: "01/02/2020"}), codeInvalidParams),
+		invalid("invalid interval", static(srv.ParamsGetNetworkStats{Start: "2020-01-01", Interval: "month"}), codeInvalidParams),
+	}},
+	{Name: "get-conversion-volume", Cases: []Case{
+		valid("last 100 heights", height(func(h uint32) interface{} {
+			return srv.ParamsGetConversionVolume{Start: since(h), Stop: int(h)}
+		}), srv.ResultGetConversionVolume{}),
+		invalid("invalid asset", static(srv.ParamsGetConversionVolume{Asset: "pXYZ"}), codeInvalidParams),
+	}},
+	{Name: "get-blocks", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetBlocks{}),
+		valid("last 100 heights", height(func(h uint32) interface{} {
+			return srv.ParamsGetBlocks{Start: since(h), Stop: int(h), Desc: true}
+		}), srv.ResultGetBlocks{}),
+		invalid("stop before start", static(srv.ParamsGetBlocks{Start: 10, Stop: 5}), codeInvalidParams),
+		invalid("range too large", static(srv.ParamsGetBlocks{Start: 1, Stop: 1 + pegnet.BlocksLimit}), codeInvalidParams),
+	}},
+	{Name: "get-block", Cases: []Case{
+		valid("synced height", height(func(h uint32) interface{} {
+			return srv.ParamsGetBlock{Height: int(h)}
+		}), srv.ResultGetBlock{}),
+		invalid("no params", noParams, codeInvalidParams),
+		invalid("height 0", static(srv.ParamsGetBlock{}), codeInvalidParams),
+		invalid("unsynced height", static(srv.ParamsGetBlock{Height: 4e9}), codeNotFound),
+	}},
+	{Name: "get-burns", Cases: []Case{
+		valid("all", noParams, srv.ResultGetBurns{}),
+		invalid("invalid address", static(srv.ParamsGetBurns{Address: "FA1"}), codeInvalidParams),
+		invalid("negative offset", static(srv.ParamsGetBurns{Offset: -1}), codeInvalidParams),
+	}},
+	{Name: "get-malformed-burns", Cases: []Case{
+		valid("all", noParams, srv.ResultGetMalformedBurns{}),
+		valid("address", address(func(a string) interface{} { return srv.ParamsGetMalformedBurns{Address: a} }), srv.ResultGetMalformedBurns{}),
+		invalid("invalid address", static(srv.ParamsGetMalformedBurns{Address: "FA1"}), codeInvalidParams),
+		invalid("stop before start", static(srv.ParamsGetMalformedBurns{Start: 10, Stop: 5}), codeInvalidParams),
+	}},
+	{Name: "search", Cases: []Case{
+		valid("address", address(func(adr string) interface{} { return srv.ParamsSearch{Query: adr} }), srv.ResultSearch{}),
+		valid("entry hash", entryHash(func(h string) interface{} { return srv.ParamsSearch{Query: h} }), srv.ResultSearch{}),
+		valid("height", height(func(h uint32) interface{} {
+			return srv.ParamsSearch{Query: fmt.Sprint(h)}
+		}), srv.ResultSearch{}),
+		invalid("no query", noParams, codeInvalidParams),
+		invalid("unknown query", static(srv.ParamsSearch{Query: "pegnet"}), codeInvalidParams),
+		invalid("unknown entry hash", static(srv.ParamsSearch{Query: unknownHash}), codeNotFound),
+		invalid("unsynced height", static(srv.ParamsSearch{Query: "4000000000"}), codeNotFound),
+	}},
+	{Name: "get-alerts", Cases: []Case{
+		valid("latest", noParams, srv.ResultGetAlerts{}),
+		invalid("limit too large", static(srv.ParamsGetAlerts{Limit: pegnet.AlertsLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "get-deposit-addresses", NoParams: true, Cases: []Case{
+		valid("no params", noParams, []pegnet.DepositAddress{}),
+	}},
+	{Name: "list-deposits", Cases: []Case{
+		valid("latest", noParams, srv.ResultListDeposits{}),
+		invalid("invalid address", static(srv.ParamsListDeposits{Address: "FA1"}), codeInvalidParams),
+		invalid("limit too large", static(srv.ParamsListDeposits{Limit: pegnet.DepositsLimit + 1}), codeInvalidParams),
+	}},
+	{Name: "add-deposit-address", Cases: []Case{
+		invalid("no address", static(srv.ParamsDepositAddress{}), codeInvalidParams),
+		invalid("invalid address", static(srv.ParamsDepositAddress{Address: "FA1"}), codeInvalidParams),
+	}},
+	{Name: "remove-deposit-address", Cases: []Case{
+		invalid("unwatched address", static(srv.ParamsDepositAddress{Address: unknownAddress}), codeAddressNotFound),
+		invalid("invalid address", static(srv.ParamsDepositAddress{Address: "FA1"}), codeInvalidParams),
+	}},
+	{Name: "send-transaction", Cases: []Case{
+		invalid("no entry", static(map[string]string{}), codeInvalidParams),
+		invalid("raw and content", static(map[string]interface{}{"raw": "00", "content": "00"}), codeInvalidParams),
+		invalid("invalid raw", static(map[string]string{"raw": "00"}), codeInvalidParams),
+	}},
+	{Name: "get-audit-log", Cases: []Case{
+		valid("admin token", adminToken(func(t string) interface{} { return srv.ParamsGetAuditLog{Token: t, Limit: 10} }), srv.ResultGetAuditLog{}),
+		invalid("invalid token", static(srv.ParamsGetAuditLog{Token: invalidToken}), codeUnauthorized, codeAdminDisabled),
+	}},
+	{Name: "reload-config", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsAdmin{Token: invalidToken}), codeUnauthorized, codeAdminDisabled),
+	}},
+	{Name: "get-rate-overrides", Cases: []Case{
+		valid("admin token", adminToken(func(t string) interface{} { return srv.ParamsAdmin{Token: t} }), srv.ResultRateOverrides{}, codeRateInjection),
+		invalid("invalid token", static(srv.ParamsAdmin{Token: invalidToken}), codeUnauthorized, codeAdminDisabled),
+	}},
+	{Name: "set-rate-override", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsSetRateOverride{Token: invalidToken, Height: 4e9, Rates: map[string]uint64{"PEG": 1}}),
+			codeUnauthorized, codeAdminDisabled, codeInvalidParams),
+		invalid("invalid asset", static(srv.ParamsSetRateOverride{Token: invalidToken, Height: 4e9, Rates: map[string]uint64{"pXYZ": 1}}), codeInvalidParams),
+	}},
+	{Name: "unlock-keystore", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsUnlockKeystore{Token: invalidToken, Passphrase: invalidToken}),
+			codeUnauthorized, codeAdminDisabled),
+		invalid("no passphrase", static(srv.ParamsUnlockKeystore{Token: invalidToken}), codeInvalidParams),
+	}},
+	{Name: "lock-keystore", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsAdmin{Token: invalidToken}), codeUnauthorized, codeAdminDisabled),
+	}},
+	{Name: "import-watch-address", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsImportWatchAddress{Token: invalidToken, Address: unknownAddress, Account: "cold"}),
+			codeUnauthorized, codeAdminDisabled),
+		invalid("no account", static(srv.ParamsImportWatchAddress{Token: invalidToken, Address: unknownAddress}), codeInvalidParams),
+		invalid("invalid account", static(srv.ParamsImportWatchAddress{Token: invalidToken, Address: unknownAddress, Account: "1st"}),
+			codeInvalidParams),
+		invalid("invalid address", static(srv.ParamsImportWatchAddress{Token: invalidToken, Address: "FA1", Account: "cold"}),
+			codeInvalidParams),
+	}},
+	{Name: "remove-watch-address", Cases: []Case{
+		invalid("unwatched address", adminToken(func(t string) interface{} { return srv.ParamsWatchAddress{Token: t, Address: unknownAddress} }),
+			codeAddressNotFound),
+		invalid("invalid token", static(srv.ParamsWatchAddress{Token: invalidToken, Address: unknownAddress}), codeUnauthorized, codeAdminDisabled),
+		invalid("invalid address", static(srv.ParamsWatchAddress{Token: invalidToken, Address: "FA1"}), codeInvalidParams),
+	}},
+	{Name: "list-schedules", Cases: []Case{
+		valid("scheduler token", schedulerToken(func(t string) interface{} { return srv.ParamsSchedules{Token: t} }), []pegnet.Schedule{}),
+		invalid("invalid token", static(srv.ParamsSchedules{Token: invalidToken}), codeUnauthorized, codeSchedulerDisabled),
+	}},
+	{Name: "add-schedule", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsAddSchedule{Token: invalidToken, Input: unknownAddress, Output: unknownAddress,
+			Asset: "PEG", Amount: 1, Interval: 1}), codeUnauthorized, codeSchedulerDisabled),
+		invalid("no interval", static(srv.ParamsAddSchedule{Token: invalidToken, Input: unknownAddress, Output: unknownAddress,
+			Asset: "PEG", Amount: 1}), codeInvalidParams),
+	}},
+	{Name: "remove-schedule", Cases: []Case{
+		invalid("invalid token", static(srv.ParamsRemoveSchedule{Token: invalidToken, ID: 1}), codeUnauthorized, codeSchedulerDisabled),
+		invalid("negative id", static(srv.ParamsRemoveSchedule{Token: invalidToken, ID: -1}), codeInvalidParams),
+		invalid("no id", static(srv.ParamsRemoveSchedule{Token: invalidToken}), codeInvalidParams),
+	}},
+	{Name: "regtest-mine", Cases: []Case{
+		invalid("invalid coinbase", static(srv.ParamsRegtestMine{Token: invalidToken, Coinbase: "FA1"}), codeInvalidParams),
+	}},
+	{Name: "regtest-set-rates", Cases: []Case{
+		invalid("no rates", static(srv.ParamsRegtestSetRates{Token: invalidToken}), codeInvalidParams, codeRegtestDisabled),
+	}},
+	{Name: "regtest-fund", Cases: []Case{
+		invalid("invalid address", static(srv.ParamsRegtestFund{Token: invalidToken, Address: "FA1", Amount: 1}), codeInvalidParams),
+	}},
+}
+
+// Lookup returns the method of the name
+func Lookup(name string) (Method, bool) {
+	for _, m := range Methods {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Method{}, false
+}
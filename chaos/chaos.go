@@ -0,0 +1,194 @@
+// Package chaos injects faults into a running node: factomd requests that
+// time out or return malformed responses, database writes that fail, and
+// crashes right before a block is committed. It verifies the retries,
+// rollbacks, and crash safety of the sync, and is never enabled unless the
+// hidden chaos section of the config is set.
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/metrics"
+	"github.com/spf13/viper"
+)
+
+// CrashExitCode is the exit code of an injected crash, so a supervisor can
+// tell it apart from a real failure
+const CrashExitCode = 3
+
+// ErrDBWrite is the error of an injected database write failure
+var ErrDBWrite = errors.New("chaos: injected database write error")
+
+// GlobalFaults counts the injected faults by kind
+var GlobalFaults = metrics.NewCounters()
+
+// Config are the probabilities of the faults, between 0 and 1
+type Config struct {
+	// FactomdTimeout is the chance a factomd request hangs for TimeoutDelay
+	// and fails with a timeout
+	FactomdTimeout float64
+	// FactomdMalformed is the chance the response of a factomd request is
+	// corrupted
+	FactomdMalformed float64
+	// DBWriteError is the chance a database write fails
+	DBWriteError float64
+	// Crash is the chance the process exits after a block is applied and
+	// before it is committed
+	Crash float64
+
+	TimeoutDelay time.Duration
+	// Seed of the faults, 0 seeds from the clock
+	Seed int64
+}
+
+func FromConfig(conf *viper.Viper) Config {
+	return Config{
+		FactomdTimeout:   conf.GetFloat64(config.ChaosFactomdTimeout),
+		FactomdMalformed: conf.GetFloat64(config.ChaosFactomdMalformed),
+		DBWriteError:     conf.GetFloat64(config.ChaosDBWriteError),
+		Crash:            conf.GetFloat64(config.ChaosCrash),
+		TimeoutDelay:     conf.GetDuration(config.ChaosTimeoutDelay),
+		Seed:             conf.GetInt64(config.ChaosSeed),
+	}
+}
+
+// Enabled is true if any fault can be injected
+func (c Config) Enabled() bool {
+	return c.FactomdTimeout > 0 || c.FactomdMalformed > 0 || c.DBWriteError > 0 || c.Crash > 0
+}
+
+// Injector rolls the faults of a config. A nil Injector injects nothing.
+type Injector struct {
+	Config Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New returns an injector of the config, nil if the config is not enabled.
+// The seed is set if it was 0, so the faults of a run can be repeated.
+func New(c Config) *Injector {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	return &Injector{Config: c, rng: rand.New(rand.NewSource(c.Seed))}
+}
+
+// roll returns true with the chance p
+func (i *Injector) roll(p float64) bool {
+	if i == nil || p <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < p
+}
+
+func (i *Injector) intn(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Intn(n)
+}
+
+// DBWrite returns ErrDBWrite with the chance of the config
+func (i *Injector) DBWrite() error {
+	if i == nil || !i.roll(i.Config.DBWriteError) {
+		return nil
+	}
+	GlobalFaults.Inc("db_writes")
+	return ErrDBWrite
+}
+
+// Crash returns true with the chance of the config, the caller exits with
+// CrashExitCode
+func (i *Injector) Crash() bool {
+	if i == nil || !i.roll(i.Config.Crash) {
+		return false
+	}
+	GlobalFaults.Inc("crashes")
+	return true
+}
+
+// Transport injects the factomd faults into the requests of the base
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if i == nil || (i.Config.FactomdTimeout <= 0 && i.Config.FactomdMalformed <= 0) {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, inj: i}
+}
+
+type transport struct {
+	base http.RoundTripper
+	inj  *Injector
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.inj.roll(t.inj.Config.FactomdTimeout) {
+		GlobalFaults.Inc("timeouts")
+		timer := time.NewTimer(t.inj.Config.TimeoutDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return nil, timeoutError{}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !t.inj.roll(t.inj.Config.FactomdMalformed) {
+		return resp, err
+	}
+	GlobalFaults.Inc("malformed")
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = t.inj.corrupt(body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// corrupt returns a malformed copy of the response: truncated, with flipped
+// bytes, or replaced by the error page of a proxy
+func (i *Injector) corrupt(body []byte) []byte {
+	switch i.intn(3) {
+	case 0:
+		if len(body) > 1 {
+			return body[:i.intn(len(body))]
+		}
+		return nil
+	case 1:
+		out := append([]byte(nil), body...)
+		for n := 0; n < 1+len(out)/64 && len(out) > 0; n++ {
+			out[i.intn(len(out))] ^= byte(1 + i.intn(255))
+		}
+		return out
+	default:
+		return []byte("<html><body><h1>502 Bad Gateway</h1></body></html>")
+	}
+}
+
+// timeoutError is a net.Error, so the clients treat it as a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "chaos: injected factomd timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
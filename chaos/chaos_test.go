@@ -0,0 +1,89 @@
+package chaos_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/pegnet/pegnetd/chaos"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	v := viper.New()
+	config.SetDefaults(v)
+	inj := New(FromConfig(v))
+	assert.Nil(t, inj)
+
+	// A nil injector injects nothing
+	base := http.DefaultTransport
+	assert.Equal(t, base, inj.Transport(base))
+	assert.NoError(t, inj.DBWrite())
+	assert.False(t, inj.Crash())
+}
+
+func TestInjector_Probabilities(t *testing.T) {
+	inj := New(Config{DBWriteError: 1, Seed: 1})
+	require.NotNil(t, inj)
+	assert.Equal(t, ErrDBWrite, inj.DBWrite())
+	assert.False(t, inj.Crash())
+
+	// The same seed rolls the same faults
+	a, b := New(Config{DBWriteError: 0.5, Seed: 7}), New(Config{DBWriteError: 0.5, Seed: 7})
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		err := a.DBWrite()
+		assert.Equal(t, err, b.DBWrite())
+		if err != nil {
+			failed++
+		}
+	}
+	assert.InDelta(t, 500, failed, 100)
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"directoryblockheight":1000,"leaderheight":1000}}`))
+	}))
+	defer srv.Close()
+
+	timeouts := &http.Client{Transport: New(Config{FactomdTimeout: 1, TimeoutDelay: time.Millisecond}).Transport(nil)}
+	_, err := timeouts.Get(srv.URL)
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.True(t, ok)
+	assert.True(t, netErr.Timeout())
+
+	// The request is cancelled with its context while it hangs
+	hang := &http.Client{Transport: New(Config{FactomdTimeout: 1, TimeoutDelay: time.Hour}).Transport(nil)}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err = hang.Do(req.WithContext(ctx))
+	assert.Error(t, err)
+
+	malformed := &http.Client{Transport: New(Config{FactomdMalformed: 1, Seed: 1}).Transport(nil)}
+	for i := 0; i < 20; i++ {
+		resp, err := malformed.Get(srv.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		var res struct {
+			Result struct {
+				Height int `json:"directoryblockheight"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(body, &res) == nil {
+			assert.NotEqual(t, 1000, res.Result.Height, "%s", body)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("addressbook", "$HOME/.pegnetd/addressbook.json", "The file of the address book with the aliases of addresses")
+	addressBookCmd.AddCommand(addressBookAdd)
+	addressBookCmd.AddCommand(addressBookRemove)
+	addressBookCmd.AddCommand(addressBookList)
+	rootCmd.AddCommand(addressBookCmd)
+}
+
+var addressBookCmd = &cobra.Command{
+	Use:   "addressbook <subcommand>",
+	Short: "Name addresses with aliases",
+	Long: "The address book is a local file of aliases for FA, Fe, and FE addresses. An alias can be used in " +
+		"place of the address in the commands, like 'pegnetd newtx EC... savings PEG 10 alice'.",
+}
+
+var addressBookAdd = &cobra.Command{
+	Use:              "add <name> <address>",
+	Short:            "Add an alias for an address",
+	Example:          "pegnetd addressbook add savings FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorAlias, ArgValidatorAddress(ADD_FA|ADD_FE|ADD_Fe)),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := addressBookPath(cmd)
+		book, err := readAddressBook(path)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if addr, ok := book[args[0]]; ok {
+			cmd.PrintErrf("%s is the alias of %s already, remove it first\n", args[0], addr)
+			os.Exit(1)
+		}
+		book[args[0]] = args[1]
+		if err := book.write(path); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %s as %s\n", args[1], args[0])
+	},
+}
+
+var addressBookRemove = &cobra.Command{
+	Use:              "remove <name>",
+	Short:            "Remove an alias",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := addressBookPath(cmd)
+		book, err := readAddressBook(path)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if _, ok := book[args[0]]; !ok {
+			cmd.PrintErrf("%s is not in the address book\n", args[0])
+			os.Exit(1)
+		}
+		delete(book, args[0])
+		if err := book.write(path); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+var addressBookList = &cobra.Command{
+	Use:              "list",
+	Short:            "List the aliases of the address book",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		book, err := readAddressBook(addressBookPath(cmd))
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		names := make([]string, 0, len(book))
+		for name := range book {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tADDRESS")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%s\n", name, book[name])
+		}
+		_ = w.Flush()
+	},
+}
+
+// addressBook maps the aliases to their addresses
+type addressBook map[string]string
+
+var aliasName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]{0,31}$`)
+
+// ArgValidatorAlias checks for a name that can be an alias. The name can not
+// be an address itself.
+func ArgValidatorAlias(cmd *cobra.Command, arg string) error {
+	if !aliasName.MatchString(arg) {
+		return fmt.Errorf("an alias starts with a letter and has up to 32 letters, digits, '_', '.', or '-'")
+	}
+	if _, err := underlyingFA(arg); err == nil {
+		return fmt.Errorf("an alias can not be an address")
+	}
+	return nil
+}
+
+// addressBookPath is the file of the "addressbook" flag
+func addressBookPath(cmd *cobra.Command) string {
+	path, _ := cmd.Flags().GetString("addressbook")
+	return os.ExpandEnv(path)
+}
+
+// readAddressBook reads the address book, the book of a file that does not
+// exist is empty
+func readAddressBook(path string) (addressBook, error) {
+	book := make(addressBook)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &book); err != nil {
+		return nil, fmt.Errorf("invalid address book %s: %v", path, err)
+	}
+	return book, nil
+}
+
+func (b addressBook) write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// lookupAlias returns the address of the alias in the address book
+func lookupAlias(cmd *cobra.Command, alias string) (string, bool) {
+	if !aliasName.MatchString(alias) {
+		return "", false
+	}
+	book, err := readAddressBook(addressBookPath(cmd))
+	if err != nil {
+		return "", false
+	}
+	addr, ok := book[alias]
+	return addr, ok
+}
+
+// resolveAlias returns the address of the alias, or the arg as it is if it is
+// not an alias
+func resolveAlias(cmd *cobra.Command, arg string) string {
+	if addr, ok := lookupAlias(cmd, arg); ok {
+		return addr
+	}
+	return arg
+}
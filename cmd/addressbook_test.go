@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestAddressBook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "addressbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := &cobra.Command{}
+	path := filepath.Join(dir, "book", "addressbook.json")
+	cmd.Flags().String("addressbook", path, "")
+
+	book, err := readAddressBook(path)
+	if err != nil || len(book) != 0 {
+		t.Fatalf("expected an empty book, got %v %v", book, err)
+	}
+	book["savings"] = "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q"
+	if err := book.write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if addr := resolveAlias(cmd, "savings"); addr != book["savings"] {
+		t.Errorf("savings resolved to %s", addr)
+	}
+	if addr := resolveAlias(cmd, "other"); addr != "other" {
+		t.Errorf("other resolved to %s", addr)
+	}
+
+	// Aliases are only replaced where the arg is not valid as it is
+	args := []string{"savings", "10"}
+	valid := CustomArgOrderValidationBuilder(true, ArgValidatorFCTAddress, ArgValidatorFCTAmount)
+	if err := valid(cmd, args); err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != book["savings"] || args[1] != "10" {
+		t.Errorf("unexpected args %v", args)
+	}
+	if err := valid(cmd, []string{"other", "10"}); err == nil {
+		t.Error("expected an error for an unknown alias")
+	}
+
+	for _, name := range []string{"", "1st", "with space", book["savings"]} {
+		if ArgValidatorAlias(cmd, name) == nil {
+			t.Errorf("expected %q to be an invalid alias", name)
+		}
+	}
+	if err := ArgValidatorAlias(cmd, "cold-wallet_2"); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"crypto/ed25519"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"github.com/pegnet/pegnet/modules/conversions"
 
 	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
 	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/fat/fat2"
 	"github.com/pegnet/pegnetd/node"
@@ -26,14 +28,21 @@ import (
 
 func init() {
 	rootCmd.AddCommand(balance)
+	balances.Flags().Bool("usd", false, "Include the pUSD value of the balances")
 	rootCmd.AddCommand(balances)
+	issuance.Flags().Int("height", 0, "Fetch the issuance at the end of a past height")
 	rootCmd.AddCommand(issuance)
 	rootCmd.AddCommand(status)
 	rootCmd.AddCommand(burn)
 	rich.Flags().Int("count", 100, "The top X address")
+	rich.Flags().String("quote", "", "Denominate the balances in this asset instead of pUSD")
+	rich.Flags().Uint32("height", 0, "List the holders of the asset as of a past height")
 	rootCmd.AddCommand(rich)
 
 	get.AddCommand(getTX)
+	get.AddCommand(getConversionResult)
+	getRates.Flags().Bool("verbose", false, "Include the quotes of the winning OPRs")
+	getRates.Flags().String("quote", "", "Price the rates in this asset instead of USD")
 	get.AddCommand(getRates)
 	getBank.Flags().Bool("raw", false, "Print the full json data")
 	get.AddCommand(getBank)
@@ -41,17 +50,55 @@ func init() {
 	getTXs.Flags().Bool("cvt", false, "Show converions")
 	getTXs.Flags().Bool("tran", false, "Show transfers")
 	getTXs.Flags().Bool("coin", false, "Show coinbases")
+	getTXs.Flags().Bool("refund", false, "Show the refunds of conversions instead")
 	getTXs.Flags().String("asset", "", "Filter by specific asset")
 	getTXs.Flags().Int("offset", 0, "Specify an offset for pagination")
+	getTXs.Flags().String("sort", "", "Sort by 'height' (default) or 'amount'")
+	getTXs.Flags().Bool("desc", false, "Sort in descending order")
+	getTXs.Flags().Int64("minamount", 0, "Only show actions with an amount of at least this many base units")
+	getTXs.Flags().Int64("maxamount", 0, "Only show actions with an amount of at most this many base units")
+	getTXs.Flags().String("direction", "", "Only show actions 'sent' or 'received' by the address")
 
 	get.AddCommand(getTXs)
+	getLedger.Flags().String("asset", "", "Only export a specific asset")
+	getLedger.Flags().String("format", "csv", "Output format, either 'csv' or 'json'")
+	get.AddCommand(getLedger)
+	getAlerts.Flags().Int("since", 0, "Only list alerts of actions applied at or after this height")
+	get.AddCommand(getAlerts)
+	getStatement.Flags().String("format", "csv", "Output format, either 'csv' or 'ofx'")
+	getStatement.Flags().String("asset", "", "Only export a specific asset")
+	getStatement.Flags().Int("start", 0, "Only export activity applied at or after this height")
+	getStatement.Flags().Int("stop", 0, "Only export activity applied at or before this height")
+	get.AddCommand(getStatement)
+	getECStatus.Flags().Bool("raw", false, "Print the full json data, with the balance history")
+	get.AddCommand(getECStatus)
+	getPriceChecks.Flags().Bool("flagged", false, "Only list the flagged heights")
+	getPriceChecks.Flags().Int("limit", 20, "The amount of checks to list")
+	getPriceChecks.Flags().Bool("raw", false, "Print the full json data, with the deviation of every asset")
+	get.AddCommand(getPriceChecks)
+	getMiner.Flags().Int("limit", 20, "The amount of heights to list")
+	getMiner.Flags().Bool("raw", false, "Print the full json data")
+	get.AddCommand(getMiner)
+	getMiners.Flags().Int("start", 0, "Only count submissions at or after this height")
+	getMiners.Flags().Int("stop", 0, "Only count submissions at or before this height")
+	getMiners.Flags().Int("limit", 50, "The amount of miners to list")
+	getMiners.Flags().Bool("raw", false, "Print the full json data")
+	get.AddCommand(getMiners)
+	getMalformedBurns.Flags().Int("start", 0, "Only list transactions at or after this height")
+	getMalformedBurns.Flags().Bool("raw", false, "Print the full json data")
+	get.AddCommand(getMalformedBurns)
 	rootCmd.AddCommand(get)
 
 	minerDistro.Flags().Bool("raw", false, "Print the full json data")
 	rootCmd.AddCommand(minerDistro)
 
-	//tx.Flags()
+	tx.Flags().String("metadata", "", "Attach metadata to the transaction, either json or a plain text memo")
+	tx.Flags().Uint32("notbefore", 0, "Only execute the transaction at or after this height, it stays pending until then")
 	rootCmd.AddCommand(tx)
+	conv.Flags().String("metadata", "", "Attach metadata to the conversion, either json or a plain text memo")
+	conv.Flags().String("minoutput", "", "Reject the conversion if it yields less than this amount of the destination asset")
+	conv.Flags().Float64("slippage", 0, "Reject the conversion if it yields this percentage less than at the latest rates")
+	conv.Flags().Uint32("notbefore", 0, "Only execute the conversion at or after this height, it stays pending until then")
 	rootCmd.AddCommand(conv)
 
 }
@@ -148,6 +195,17 @@ var rich = &cobra.Command{
 		if count == 0 {
 			count = 100
 		}
+		quote, _ := cmd.Flags().GetString("quote")
+		if quote != "" {
+			qt := fat2.StringToTicker(quote)
+			if qt == fat2.PTickerInvalid {
+				cmd.PrintErrln(fmt.Errorf("invalid quote asset specified"))
+				os.Exit(1)
+			}
+			quote = qt.String()
+		}
+
+		height, _ := cmd.Flags().GetUint32("height")
 
 		if len(args) > 0 {
 			ticker := fat2.StringToTicker(args[0])
@@ -156,17 +214,23 @@ var rich = &cobra.Command{
 				os.Exit(1)
 			}
 
-			assetRich(cl, ticker.String(), count)
+			assetRich(cl, ticker.String(), quote, count, height)
 		} else {
-			globalRich(cl, count)
+			if height != 0 {
+				cmd.PrintErrln(fmt.Errorf("the global rich list is only available at the synced height"))
+				os.Exit(1)
+			}
+			globalRich(cl, quote, count)
 		}
 	},
 }
 
-func assetRich(cl *srv.Client, asset string, count int) {
+func assetRich(cl *srv.Client, asset, quote string, count int, height uint32) {
 	var params srv.ParamsGetRichList
 	params.Asset = asset
 	params.Count = count
+	params.Quote = quote
+	params.Height = height
 
 	var res []srv.ResultGetRichList
 	err := cl.Request("get-rich-list", params, &res)
@@ -175,19 +239,31 @@ func assetRich(cl *srv.Client, asset string, count int) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Top %d %s Rich List\n", count, asset)
+	if height != 0 {
+		fmt.Printf("Top %d %s Rich List at height %d\n", count, asset, height)
+	} else {
+		fmt.Printf("Top %d %s Rich List\n", count, asset)
+	}
 	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(tw, "Pos\tAddress\t%s\tpUSD\t\n", asset)
-	fmt.Fprintf(tw, "---\t-------\t%s\t----\t\n", strings.Repeat("-", len(asset)))
+	if quote == "" {
+		quote = "pUSD"
+	}
+	fmt.Fprintf(tw, "Pos\tAddress\t%s\t%s\t\n", asset, quote)
+	fmt.Fprintf(tw, "---\t-------\t%s\t%s\t\n", strings.Repeat("-", len(asset)), strings.Repeat("-", len(quote)))
 	for i, e := range res {
-		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", i+1, e.Address, FactoshiToFactoid(int64(e.Amount)), FactoshiToFactoid(int64(e.Equiv)))
+		equiv := e.Equiv
+		if params.Quote != "" {
+			equiv = e.QuoteEquiv
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", i+1, e.Address, FactoshiToFactoid(int64(e.Amount)), FactoshiToFactoid(int64(equiv)))
 	}
 	tw.Flush()
 }
 
-func globalRich(cl *srv.Client, count int) {
+func globalRich(cl *srv.Client, quote string, count int) {
 	var params srv.ParamsGetGlobalRichList
 	params.Count = count
+	params.Quote = quote
 
 	var res []srv.ResultGlobalRichList
 	err := cl.Request("get-global-rich-list", params, &res)
@@ -198,10 +274,17 @@ func globalRich(cl *srv.Client, count int) {
 
 	fmt.Printf("Top %d Global Rich List\n", count)
 	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(tw, "Pos\tAddress\tpUSD\t\n")
-	fmt.Fprintf(tw, "---\t-------\t----\t\n")
+	if quote == "" {
+		quote = "pUSD"
+	}
+	fmt.Fprintf(tw, "Pos\tAddress\t%s\t\n", quote)
+	fmt.Fprintf(tw, "---\t-------\t%s\t\n", strings.Repeat("-", len(quote)))
 	for i, e := range res {
-		fmt.Fprintf(tw, "%d\t%s\t%s\t\n", i+1, e.Address, FactoshiToFactoid(int64(e.Equiv)))
+		equiv := e.Equiv
+		if params.Quote != "" {
+			equiv = e.QuoteEquiv
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t\n", i+1, e.Address, FactoshiToFactoid(int64(equiv)))
 	}
 	tw.Flush()
 }
@@ -349,8 +432,8 @@ var conv = &cobra.Command{
 
 		// Let's check the pXXX -> pFCT first
 		status := getStatus()
-		if (destAsset == "pFCT" || destAsset == "FCT") && uint32(status.Current) >= node.OneWaypFCTConversions {
-			cmd.PrintErrln(fmt.Sprintf("pXXX -> pFCT conversions are not allowed since block height %d. If you need to acquire pFCT, you have to burn FCT -> pFCT", node.OneWaypFCTConversions))
+		if (destAsset == "pFCT" || destAsset == "FCT") && activation.Active(activation.OneWaypFCTConversions, uint32(status.Current)) {
+			cmd.PrintErrln(fmt.Sprintf("pXXX -> pFCT conversions are not allowed since block height %d. If you need to acquire pFCT, you have to burn FCT -> pFCT", activation.Height(activation.OneWaypFCTConversions)))
 			os.Exit(1)
 		}
 
@@ -366,7 +449,17 @@ var conv = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err, commit, reveal := signAndSend(originalSource, &trans, cl, payment)
+		if err := setMinOutput(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+
+		if err := setMetadata(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+
+		err, commit, reveal := signAndSend(originalSource, &trans, cl, payment, notBefore(cmd))
 		if err != nil {
 			cmd.PrintErrln(err.Error())
 			os.Exit(1)
@@ -411,6 +504,11 @@ var tx = &cobra.Command{
 			os.Exit(1)
 		}
 
+		if err := setMetadata(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+
 		// Before we sign and send, check the in/out rules
 		err := addressRules(source, dest)
 		if err != nil {
@@ -418,7 +516,7 @@ var tx = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err, commit, reveal := signAndSend(source, &trans, cl, payment)
+		err, commit, reveal := signAndSend(source, &trans, cl, payment, notBefore(cmd))
 		if err != nil {
 			cmd.PrintErrln(err.Error())
 			os.Exit(1)
@@ -472,6 +570,12 @@ var balances = &cobra.Command{
 		CustomArgOrderValidationBuilder(true, ArgValidatorAddress(ADD_FA|ADD_FE|ADD_Fe)),
 		cobra.MinimumNArgs(1)),
 	Run: func(cmd *cobra.Command, args []string) {
+		if usd, _ := cmd.Flags().GetBool("usd"); usd {
+			printBalancesUSD(args[0])
+			printFeWarning(cmd, args[0])
+			return
+		}
+
 		res, err := queryBalances(args[0])
 		if err != nil {
 			fmt.Println(err)
@@ -504,7 +608,7 @@ func queryBalances(humanAddress string) (srv.ResultPegnetTickerMap, error) {
 	}
 
 	var res srv.ResultPegnetTickerMap
-	err = cl.Request("get-pegnet-balances", srv.ParamsGetPegnetBalances{addr.String()}, &res)
+	err = cl.Request("get-pegnet-balances", srv.ParamsGetPegnetBalances{Address: addr.String()}, &res)
 	if err != nil {
 		// TODO: Better error
 		fmt.Println("2", err)
@@ -514,16 +618,50 @@ func queryBalances(humanAddress string) (srv.ResultPegnetTickerMap, error) {
 	return res, nil
 }
 
+func printBalancesUSD(humanAddress string) {
+	cl := srv.NewClient()
+	cl.PegnetdServer = viper.GetString(config.Pegnetd)
+	addr, err := underlyingFA(humanAddress)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var res srv.ResultPegnetBalancesUSD
+	err = cl.Request("get-pegnet-balances", srv.ParamsGetPegnetBalances{Address: addr.String(), IncludeUSD: true}, &res)
+	if err != nil {
+		fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	// Change the units to be human readable
+	humanBals := make(map[string]map[string]string)
+	for k, bal := range res.Balances {
+		humanBals[k] = map[string]string{"balance": FactoshiToFactoid(int64(bal.Balance)), "usd": FactoshiToFactoid(int64(bal.USD))}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"rateheight": res.RateHeight,
+		"balances":   humanBals,
+		"total":      FactoshiToFactoid(int64(res.Total)),
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+}
+
 var issuance = &cobra.Command{
 	Use:              "issuance",
-	Short:            "Fetch the current issuance of all assets",
+	Short:            "Fetch the current or a past issuance of all assets",
 	PersistentPreRun: always,
 	PreRun:           SoftReadConfig,
 	Run: func(cmd *cobra.Command, args []string) {
 		cl := srv.NewClient()
 		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var params srv.ParamsGetPegnetIssuance
+		params.Height, _ = cmd.Flags().GetInt("height")
 		var res srv.ResultGetIssuance
-		err := cl.Request("get-pegnet-issuance", nil, &res)
+		err := cl.Request("get-pegnet-issuance", params, &res)
 		if err != nil {
 			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
 			os.Exit(1)
@@ -536,9 +674,11 @@ var issuance = &cobra.Command{
 		}
 		humanResult := struct {
 			SyncStatus srv.ResultGetSyncStatus `json:"sync-status"`
+			Height     uint32                  `json:"height,omitempty"`
 			Issuance   map[string]string       `json:"issuance"`
 		}{
 			SyncStatus: res.SyncStatus,
+			Height:     res.Height,
 			Issuance:   humanIssuance,
 		}
 
@@ -565,6 +705,242 @@ var status = &cobra.Command{
 	},
 }
 
+var getECStatus = &cobra.Command{
+	Use:              "ec-status",
+	Short:            "Fetch the balance and the usage of the EC keys that pay for the entries of the node",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var res node.ECStatus
+		if err := cl.Request("get-ec-status", nil, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("Balance:      %d EC over %d keys, checked %s\n", res.Balance, len(res.Keys), res.Checked.Format(time.RFC3339))
+		fmt.Printf("Transactions: about %d left at %.2f EC each\n", res.Remaining, res.TxCost)
+		if res.EmptyIn != "" {
+			fmt.Printf("Burn rate:    %.2f EC per hour, empty in %s\n", res.BurnRate, res.EmptyIn)
+		}
+		if res.Low {
+			fmt.Printf("LOW: below %d EC or %d transactions, top up the address\n", res.MinBalance, res.MinTransactions)
+		}
+		if res.Error != "" {
+			fmt.Printf("Last check failed: %s\n", res.Error)
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ADDRESS\tSTATE\tBALANCE\tENTRIES\tSPENT\tEXHAUSTED")
+		for _, key := range res.Keys {
+			state := ""
+			if key.Active {
+				state = "active"
+			}
+			if key.Locked {
+				state = "locked"
+			}
+			if key.Remote {
+				state = strings.TrimSpace(state + " signer")
+			}
+			if key.HSM {
+				state = strings.TrimSpace(state + " hsm")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", key.Address, state, key.Balance, key.Entries, key.Spent, key.Exhausted)
+		}
+		_ = w.Flush()
+	},
+}
+
+var getPriceChecks = &cobra.Command{
+	Use:              "price-checks",
+	Short:            "List the graded rates that were compared to the external reference prices, and the flagged heights",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var params srv.ParamsGetPriceChecks
+		params.Flagged, _ = cmd.Flags().GetBool("flagged")
+		params.Limit, _ = cmd.Flags().GetInt("limit")
+		var res srv.ResultGetPriceChecks
+		if err := cl.Request("get-price-checks", params, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("Sources:   %d, a deviation above %.2f%% flags a block, blocks older than %s are skipped\n", res.Sources, res.Threshold, res.MaxAge)
+		fmt.Printf("Checked:   height %d\n", res.Checked)
+		if res.Flags > 0 {
+			fmt.Printf("FLAGGED:   %d heights, the latest %d\n", res.Flags, res.LatestFlag)
+		}
+		if res.Error != "" {
+			fmt.Printf("Last check failed: %s\n", res.Error)
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HEIGHT\tCHECKED\tMAX DEVIATION\tFLAGGED ASSETS")
+		for _, c := range res.Checks {
+			var flagged []string
+			for _, a := range c.Assets {
+				if a.Flagged {
+					flagged = append(flagged, fmt.Sprintf("%s %.2f%%", a.Asset, a.Deviation))
+				}
+			}
+			fmt.Fprintf(w, "%d\t%s\t%.2f%%\t%s\n", c.Height, c.Checked.Format(time.RFC3339), c.MaxDeviation, strings.Join(flagged, ", "))
+		}
+		_ = w.Flush()
+	},
+}
+
+var getMiner = &cobra.Command{
+	Use:              "miner <coinbase address>",
+	Short:            "Get the submissions, graded placements, and rewards of a miner",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		params := srv.ParamsGetMiner{Address: args[0]}
+		params.Limit, _ = cmd.Flags().GetInt("limit")
+		var res srv.ResultGetMiner
+		if err := cl.Request("get-miner", params, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("Miner:       %s (%s)\n", res.Address, strings.Join(res.Identities, ", "))
+		fmt.Printf("Heights:     %d, from %d to %d\n", res.Blocks, res.FirstHeight, res.LastHeight)
+		fmt.Printf("Submissions: %d, %d graded, %d won\n", res.Submissions, res.Graded, res.Wins)
+		fmt.Printf("Reward:      %s PEG\n", FactoshiToFactoid(res.Reward))
+		fmt.Printf("Difficulty:  %d at best\n", res.BestDifficulty)
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HEIGHT\tSUBMISSIONS\tDIFFICULTY\tGRADED\tPOSITION\tREWARD")
+		for _, b := range res.History {
+			position := "-"
+			if b.Position != nil {
+				position = strconv.Itoa(*b.Position)
+			}
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t%s\n", b.Height, b.Submissions, b.Difficulty, b.Graded, position, FactoshiToFactoid(b.Reward))
+		}
+		_ = w.Flush()
+	},
+}
+
+var getMiners = &cobra.Command{
+	Use:              "miners",
+	Short:            "List the miners of a range of heights by their rewards",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var params srv.ParamsGetMiners
+		params.Start, _ = cmd.Flags().GetInt("start")
+		params.Stop, _ = cmd.Flags().GetInt("stop")
+		params.Limit, _ = cmd.Flags().GetInt("limit")
+		var res srv.ResultGetMiners
+		if err := cl.Request("get-miners", params, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Printf("Miners of the heights %d to %d\n\n", res.Start, res.Stop)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ADDRESS\t1ST ID\tHEIGHTS\tSUBMISSIONS\tGRADED\tWINS\tREWARD")
+		for _, m := range res.Miners {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", m.Address, m.Identities[0], m.Blocks, m.Submissions, m.Graded, m.Wins, FactoshiToFactoid(m.Reward))
+		}
+		_ = w.Flush()
+	},
+}
+
+var getMalformedBurns = &cobra.Command{
+	Use:              "malformed-burns [address]",
+	Short:            "List the transactions to the burn address that were not credited as a burn",
+	Long:             "List the transactions to the burn address that were not credited as a burn, eg: they have several inputs or outputs, or bought entry credits. No pFCT was credited for them.",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var params srv.ParamsGetMalformedBurns
+		if len(args) > 0 {
+			params.Address = args[0]
+		}
+		params.Start, _ = cmd.Flags().GetInt("start")
+		var res srv.ResultGetMalformedBurns
+		if err := cl.Request("get-malformed-burns", params, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		if res.Count == 0 {
+			fmt.Println("No malformed burns")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HEIGHT\tTXID\tADDRESS\tFCT\tREASON")
+		for _, b := range res.Burns {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", b.Height, b.TxID, b.Address, FactoshiToFactoid(b.Amount), b.Detail)
+		}
+		_ = w.Flush()
+		if res.NextOffset > 0 {
+			fmt.Printf("\n%d of %d listed\n", len(res.Burns), res.Count)
+		}
+	},
+}
+
 func getProperties() srv.PegnetdProperties {
 	cl := srv.NewClient()
 	cl.PegnetdServer = viper.GetString(config.Pegnetd)
@@ -627,6 +1003,36 @@ var getTX = &cobra.Command{
 	},
 }
 
+var getConversionResult = &cobra.Command{
+	Use:              "conversion-result <entryhash>",
+	Short:            "Fetch the execution height, rates, and outputs of the conversions in a transaction",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		hash := new(factom.Bytes32)
+		if err := hash.Set(args[0]); err != nil {
+			cmd.PrintErrf("entryhash is invalid: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var res srv.ResultGetConversionResult
+		err := cl.Request("get-conversion-result", srv.ParamsGetConversionResult{Hash: hash}, &res)
+		if err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		data, err := json.Marshal(res)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(data))
+	},
+}
+
 var getTXs = &cobra.Command{
 	Use:   "txs <entryhash | FA address | height>",
 	Short: "Fetch all transactions for an entryhash, FA address, or height",
@@ -652,7 +1058,7 @@ var getTXs = &cobra.Command{
 		}
 
 		// A factoid address maybe?
-		add, err = underlyingFA(args[0])
+		add, err = underlyingFA(resolveAlias(cmd, args[0]))
 		if err == nil {
 			// Place warning at the bottom
 			defer printFeWarning(cmd, args[0])
@@ -675,8 +1081,14 @@ var getTXs = &cobra.Command{
 		params.Burn, _ = cmd.Flags().GetBool("burn")
 		params.Transfer, _ = cmd.Flags().GetBool("tran")
 		params.Coinbase, _ = cmd.Flags().GetBool("coin")
+		params.Refund, _ = cmd.Flags().GetBool("refund")
 		params.Asset, _ = cmd.Flags().GetString("asset")
 		params.Offset, _ = cmd.Flags().GetInt("offset")
+		params.Sort, _ = cmd.Flags().GetString("sort")
+		params.Desc, _ = cmd.Flags().GetBool("desc")
+		params.MinAmount, _ = cmd.Flags().GetInt64("minamount")
+		params.MaxAmount, _ = cmd.Flags().GetInt64("maxamount")
+		params.Direction, _ = cmd.Flags().GetString("direction")
 
 		cl := srv.NewClient()
 		cl.PegnetdServer = viper.GetString(config.Pegnetd)
@@ -712,10 +1124,32 @@ var getRates = &cobra.Command{
 			}
 		}
 
+		quote, _ := cmd.Flags().GetString("quote")
+		if quote != "" && fat2.StringToTicker(quote) == fat2.PTickerInvalid {
+			cmd.PrintErrf("%s is not a pegnet asset\n", quote)
+			os.Exit(1)
+		}
+
 		cl := srv.NewClient()
 		cl.PegnetdServer = viper.GetString(config.Pegnetd)
-		res, err := getPegnetRates(uint32(height), cl)
-		if err != nil {
+		params := srv.ParamsGetPegnetRates{Height: uint32(height), Quote: quote}
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			var res srv.ResultGetPegnetRatesVerbose
+			params.Verbose = true
+			err := cl.Request("get-pegnet-rates", params, &res)
+			if err != nil {
+				fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+				os.Exit(1)
+			}
+			data, err := json.Marshal(res)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		var res srv.ResultPegnetTickerMap
+		if err := cl.Request("get-pegnet-rates", params, &res); err != nil {
 			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
 			os.Exit(1)
 		}
@@ -759,7 +1193,7 @@ var getBank = &cobra.Command{
 
 		cl := srv.NewClient()
 		cl.PegnetdServer = viper.GetString(config.Pegnetd)
-		var res pegnet.BankEntry
+		var res srv.ResultGetBank
 		err = cl.Request("get-bank", srv.ParamsGetBank{Height: int32(height)}, &res)
 		if err != nil {
 			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
@@ -784,6 +1218,14 @@ var getBank = &cobra.Command{
 		fmt.Printf("PEG in Bank   : %s PEG\n", FactoshiToFactoid(res.BankAmount))
 		fmt.Printf("PEG Consumed  : %s PEG\n", FactoshiToFactoid(res.BankUsed))
 		fmt.Printf("PEG Requested : %s PEG\n", FactoshiToFactoid(res.PEGRequested))
+		fmt.Printf("PEG Remaining : %s PEG\n", FactoshiToFactoid(res.Remaining))
+		fmt.Printf("Proration     : %.2f%%\n", res.Proration*100)
+		if len(args) == 0 {
+			fmt.Println("")
+			fmt.Println("Next block (estimated)")
+			fmt.Printf("PEG Pending   : %s PEG\n", FactoshiToFactoid(res.PendingRequested))
+			fmt.Printf("Proration     : %.2f%%\n", res.ExpectedProration*100)
+		}
 
 		rates, err := getPegnetRates(uint32(res.Height), cl)
 		if err == nil {
@@ -811,3 +1253,141 @@ func toP(asset string) string {
 	}
 	return asset
 }
+
+var getLedger = &cobra.Command{
+	Use:              "ledger <height>",
+	Short:            "Export all addresses with a non-zero balance at a given height. Put no height for the latest",
+	Long:             "Export all addresses with a non-zero balance at a given height. The csv format has one row per address and asset, the json format has one object per address and line.",
+	Example:          "pegnetd get ledger 220000 --asset=PEG --format=csv > ledger.csv",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var height int
+		var err error
+		if len(args) > 0 {
+			height, err = strconv.Atoi(args[0])
+			if height <= 0 || err != nil {
+				cmd.PrintErrf("height must be a number greater than 0")
+				os.Exit(1)
+			}
+		}
+
+		asset, _ := cmd.Flags().GetString("asset")
+		format, _ := cmd.Flags().GetString("format")
+		if format != "csv" && format != "json" {
+			cmd.PrintErrf("format must be either 'csv' or 'json'")
+			os.Exit(1)
+		}
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+
+		w := csv.NewWriter(os.Stdout)
+		enc := json.NewEncoder(os.Stdout)
+		if format == "csv" {
+			_ = w.Write([]string{"address", "asset", "balance"})
+		}
+
+		// The ledger is fetched and written one page at a time, so the
+		// full ledger is never held in memory
+		params := srv.ParamsGetLedger{Height: height, Asset: asset}
+		for {
+			var res srv.ResultGetLedger
+			err = cl.Request("get-ledger", params, &res)
+			if err != nil {
+				w.Flush()
+				cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+				os.Exit(1)
+			}
+			// Pin the height so all pages are from the same ledger
+			params.Height = int(res.Height)
+
+			for _, e := range res.Ledger {
+				if format == "json" {
+					if err := enc.Encode(e); err != nil {
+						panic(err)
+					}
+					continue
+				}
+
+				tickers := make([]fat2.PTicker, 0, len(e.Balances))
+				for ticker := range e.Balances {
+					tickers = append(tickers, ticker)
+				}
+				sort.Slice(tickers, func(i, j int) bool { return tickers[i] < tickers[j] })
+				for _, ticker := range tickers {
+					_ = w.Write([]string{e.Address, ticker.String(), FactoshiToFactoid(int64(e.Balances[ticker]))})
+				}
+			}
+			w.Flush()
+
+			if res.Next == "" {
+				break
+			}
+			params.After = res.Next
+		}
+	},
+}
+
+var getAlerts = &cobra.Command{
+	Use:              "alerts",
+	Short:            "List the actions that matched the alert rules of the node",
+	Example:          "pegnetd get alerts --since=230000",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetInt("since")
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Height\tRule\tAmount\tAddress\tTxID")
+		params := srv.ParamsGetAlerts{Since: since}
+		for {
+			var res srv.ResultGetAlerts
+			if err := cl.Request("get-alerts", params, &res); err != nil {
+				w.Flush()
+				cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+				os.Exit(1)
+			}
+			for _, a := range res.Alerts {
+				fmt.Fprintf(w, "%d\t%s\t%s %s\t%s\t%s\n", a.Height, a.Rule, FactoshiToFactoid(a.Amount), a.Asset, a.Address, a.TxID)
+			}
+			if res.Next == 0 {
+				break
+			}
+			params.After = res.Next
+		}
+		w.Flush()
+	},
+}
+
+var getStatement = &cobra.Command{
+	Use:              "statement <address>",
+	Short:            "Export the history of an address as a csv or ofx statement",
+	Long:             "Export the applied transfers, conversions, coinbases, and burns of an address in a format that can be imported by accounting software. The ofx statement has one account per asset.",
+	Example:          "pegnetd get statement FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q --format=ofx > statement.ofx",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorFCTAddress),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := srv.ParamsExportStatement{Address: args[0]}
+		params.Format, _ = cmd.Flags().GetString("format")
+		params.Asset, _ = cmd.Flags().GetString("asset")
+		params.Start, _ = cmd.Flags().GetInt("start")
+		params.Stop, _ = cmd.Flags().GetInt("stop")
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+
+		var res srv.ResultExportStatement
+		if err := cl.Request("export-statement", params, &res); err != nil {
+			cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(res.Content)
+	},
+}
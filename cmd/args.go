@@ -6,7 +6,7 @@ import (
 
 	"github.com/Factom-Asset-Tokens/factom"
 
-	"github.com/pegnet/pegnet/modules/opr"
+	"github.com/pegnet/pegnetd/fat/fat2"
 	"github.com/spf13/cobra"
 )
 
@@ -34,6 +34,8 @@ func CombineCobraArgs(funcs ...cobra.PositionalArgs) cobra.PositionalArgs {
 //		Params:
 //			strict		Enforce the number of args == number of validation funcs
 //			valids		Validation functions
+// An arg that is not valid but is an alias of the address book is replaced
+// by its address if the address is valid.
 func CustomArgOrderValidationBuilder(strict bool, valids ...func(cmd *cobra.Command, args string) error) cobra.PositionalArgs {
 	return func(cmd *cobra.Command, args []string) error {
 		if strict && len(valids) != len(args) {
@@ -42,6 +44,10 @@ func CustomArgOrderValidationBuilder(strict bool, valids ...func(cmd *cobra.Comm
 
 		for i, arg := range args {
 			if err := valids[i](cmd, arg); err != nil {
+				if addr, ok := lookupAlias(cmd, arg); ok && valids[i](cmd, addr) == nil {
+					args[i] = addr
+					continue
+				}
 				return err
 			}
 		}
@@ -51,14 +57,16 @@ func CustomArgOrderValidationBuilder(strict bool, valids ...func(cmd *cobra.Comm
 
 // ArgValidatorAssetAndAll checks for valid asset or 'all'
 func ArgValidatorAssetOrP(cmd *cobra.Command, arg string) error {
-	list := opr.V4Assets
-	for _, an := range list {
+	var list []string
+	for _, a := range fat2.Assets() {
+		an := strings.TrimPrefix(a.Name, "p")
 		if strings.ToLower(arg) == strings.ToLower(an) {
 			return nil
 		}
 		if strings.ToLower(arg) == strings.ToLower("p"+an) {
 			return nil
 		}
+		list = append(list, an)
 	}
 
 	errstr := fmt.Sprintf("not a valid asset. Options include: %v", list)
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/srv"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	benchRPC.Flags().String("target", "", "The url of the pegnetd endpoint to load, defaults to --pegnetd")
+	benchRPC.Flags().StringSlice("method", []string{benchMix}, "The methods to request, or 'mix' for the weighted mix of all of them")
+	benchRPC.Flags().Int("rate", 100, "The requests per second to send")
+	benchRPC.Flags().Duration("duration", 30*time.Second, "How long to send requests for")
+	benchRPC.Flags().Int("concurrency", 64, "The maximum of requests in flight, requests beyond it are dropped")
+	benchRPC.Flags().Int("addresses", 1000, "The amount of addresses to sample from the local database")
+	bench.AddCommand(benchRPC)
+	rootCmd.AddCommand(bench)
+}
+
+var bench = &cobra.Command{
+	Use:   "bench <subcommand>",
+	Short: "Benchmark pegnetd",
+}
+
+// benchMix selects the weighted mix of all bench methods
+const benchMix = "mix"
+
+// benchMethod generates the params of requests to a method
+type benchMethod struct {
+	Name string
+	// Weight is the share of the method in the mix
+	Weight int
+	Params func(r *rand.Rand, s *benchSample) interface{}
+}
+
+// benchSample is the data of the local database requests are generated from
+type benchSample struct {
+	Addresses []factom.FAAddress
+	Synced    uint32
+}
+
+func (s *benchSample) address(r *rand.Rand) string {
+	return s.Addresses[r.Intn(len(s.Addresses))].String()
+}
+
+// recentHeight is one of the last 100 synced heights, most clients ask for
+// the current rates
+func (s *benchSample) recentHeight(r *rand.Rand) uint32 {
+	back := uint32(r.Intn(100))
+	if back >= s.Synced {
+		return s.Synced
+	}
+	return s.Synced - back
+}
+
+// benchMethods is the mix of requests of wallets and explorers
+var benchMethods = []benchMethod{
+	{"get-pegnet-balances", 40, func(r *rand.Rand, s *benchSample) interface{} {
+		return srv.ParamsGetPegnetBalances{Address: s.address(r)}
+	}},
+	{"get-transactions", 20, func(r *rand.Rand, s *benchSample) interface{} {
+		return srv.ParamsGetPegnetTransaction{Address: s.address(r), Desc: true}
+	}},
+	{"get-sync-status", 15, func(r *rand.Rand, s *benchSample) interface{} {
+		return nil
+	}},
+	{"get-pegnet-rates", 15, func(r *rand.Rand, s *benchSample) interface{} {
+		return srv.ParamsGetPegnetRates{Height: s.recentHeight(r)}
+	}},
+	{"get-pegnet-issuance", 5, func(r *rand.Rand, s *benchSample) interface{} {
+		return nil
+	}},
+	{"get-rich-list", 5, func(r *rand.Rand, s *benchSample) interface{} {
+		return srv.ParamsGetRichList{Asset: "PEG", Count: 100}
+	}},
+}
+
+// selectBenchMethods returns the methods of the names, where 'mix' is all
+// methods at their weights and the other names are requested evenly
+func selectBenchMethods(names []string) ([]benchMethod, error) {
+	if len(names) == 1 && names[0] == benchMix {
+		return benchMethods, nil
+	}
+	var res []benchMethod
+	for _, name := range names {
+		found := false
+		for _, m := range benchMethods {
+			if m.Name == name {
+				m.Weight = 1
+				res = append(res, m)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var list []string
+			for _, m := range benchMethods {
+				list = append(list, m.Name)
+			}
+			return nil, fmt.Errorf("unknown method %q, the methods are 'mix' or %s", name, strings.Join(list, ", "))
+		}
+	}
+	return res, nil
+}
+
+func pickBenchMethod(r *rand.Rand, methods []benchMethod) benchMethod {
+	total := 0
+	for _, m := range methods {
+		total += m.Weight
+	}
+	n := r.Intn(total)
+	for _, m := range methods {
+		if n < m.Weight {
+			return m
+		}
+		n -= m.Weight
+	}
+	return methods[len(methods)-1]
+}
+
+// benchStats are the latencies and errors of the requests to a method
+type benchStats struct {
+	latencies []time.Duration
+	errors    int
+	lastError error
+}
+
+func (s *benchStats) add(latency time.Duration, err error) {
+	s.latencies = append(s.latencies, latency)
+	if err != nil {
+		s.errors++
+		s.lastError = err
+	}
+}
+
+func (s *benchStats) merge(o *benchStats) {
+	s.latencies = append(s.latencies, o.latencies...)
+	s.errors += o.errors
+}
+
+// percentile is the nearest rank percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+var benchRPC = &cobra.Command{
+	Use:   "rpc [--target <url>] [--method <method>] [--rate <req/s>]",
+	Short: "Load a pegnetd api with requests for the addresses of the local database",
+	Long: "Send requests at a fixed rate to a pegnetd api and report the latency percentiles of each method. " +
+		"The params are generated from a sample of the addresses and the synced height of the local database, " +
+		"so the target should serve the same network. The rate is held regardless of the response times, " +
+		"requests that would exceed --concurrency are dropped and counted.\n\n" +
+		"The mix is weighted like the traffic of wallets and explorers, a list of methods requests them evenly.",
+	Example:          "pegnetd bench rpc --target http://localhost:8070 --method get-pegnet-balances --rate 500",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		target, _ := cmd.Flags().GetString("target")
+		names, _ := cmd.Flags().GetStringSlice("method")
+		rate, _ := cmd.Flags().GetInt("rate")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		sampleSize, _ := cmd.Flags().GetInt("addresses")
+		if target == "" {
+			target = viper.GetString(config.Pegnetd)
+		}
+		if rate < 1 || concurrency < 1 || sampleSize < 1 {
+			cmd.PrintErrln("--rate, --concurrency, and --addresses must be greater than 0")
+			os.Exit(1)
+		}
+		methods, err := selectBenchMethods(names)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		p := pegnet.New(viper.GetViper())
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		var sample benchSample
+		sample.Addresses, err = p.SelectAddressSample(sampleSize)
+		if err != nil {
+			log.WithError(err).Fatal("failed to sample the addresses")
+		}
+		synced, err := p.SelectSynced(ctx, p.DB)
+		if err != nil {
+			log.WithError(err).Fatal("failed to find the synced height")
+		}
+		sample.Synced = synced.Synced
+		p.Close()
+		if len(sample.Addresses) == 0 {
+			cmd.PrintErrln("the local database has no addresses to request")
+			os.Exit(1)
+		}
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = target
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = concurrency
+		cl.Transport = transport
+
+		fmt.Printf("Sending %d req/s to %s for %s, %d addresses at height %d\n",
+			rate, target, duration, len(sample.Addresses), sample.Synced)
+
+		var mu sync.Mutex
+		stats := make(map[string]*benchStats)
+		jobs := make(chan benchMethod)
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+			go func() {
+				defer wg.Done()
+				for m := range jobs {
+					params := m.Params(r, &sample)
+					start := time.Now()
+					var res json.RawMessage
+					err := cl.Request(m.Name, params, &res)
+					latency := time.Since(start)
+
+					mu.Lock()
+					if stats[m.Name] == nil {
+						stats[m.Name] = new(benchStats)
+					}
+					stats[m.Name].add(latency, err)
+					mu.Unlock()
+				}
+			}()
+		}
+
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		tick := time.NewTicker(time.Second / time.Duration(rate))
+		stop := time.After(duration)
+		start := time.Now()
+		sent, dropped := 0, 0
+	Send:
+		for {
+			select {
+			case <-ctx.Done():
+				break Send
+			case <-stop:
+				break Send
+			case <-tick.C:
+			}
+			select {
+			case jobs <- pickBenchMethod(r, methods):
+				sent++
+			default:
+				dropped++
+			}
+		}
+		tick.Stop()
+		elapsed := time.Since(start)
+		close(jobs)
+		wg.Wait()
+
+		fmt.Printf("Sent %d requests in %s (%.1f req/s), dropped %d\n\n",
+			sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds(), dropped)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Method\tRequests\tErrors\tp50\tp90\tp99\tMax\t\n")
+		fmt.Fprintf(tw, "------\t--------\t------\t---\t---\t---\t---\t\n")
+		row := func(name string, s *benchStats) {
+			sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+			round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", name, len(s.latencies), s.errors,
+				round(percentile(s.latencies, 50)), round(percentile(s.latencies, 90)),
+				round(percentile(s.latencies, 99)), round(percentile(s.latencies, 100)))
+		}
+		total := new(benchStats)
+		for _, m := range methods {
+			if s, ok := stats[m.Name]; ok {
+				row(m.Name, s)
+				total.merge(s)
+			}
+		}
+		row("total", total)
+		tw.Flush()
+
+		for _, m := range methods {
+			if s, ok := stats[m.Name]; ok && s.lastError != nil {
+				if _, ok := s.lastError.(jrpc.Error); ok {
+					fmt.Printf("\n%s: rpc error: %v", m.Name, s.lastError)
+				} else {
+					fmt.Printf("\n%s: request failed: %v", m.Name, s.lastError)
+				}
+			}
+		}
+		if total.errors > 0 {
+			fmt.Println()
+		}
+	},
+}
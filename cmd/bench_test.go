@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/spf13/viper"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{{50, 50 * time.Millisecond}, {90, 90 * time.Millisecond}, {99, 99 * time.Millisecond}, {100, 100 * time.Millisecond}, {0, time.Millisecond}} {
+		if got := percentile(sorted, c.p); got != c.want {
+			t.Errorf("p%v: got %s, want %s", c.p, got, c.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("empty: got %s", got)
+	}
+	if got := percentile(sorted[:1], 99); got != time.Millisecond {
+		t.Errorf("single: got %s", got)
+	}
+}
+
+func TestSelectBenchMethods(t *testing.T) {
+	methods, err := selectBenchMethods([]string{benchMix})
+	if err != nil || len(methods) != len(benchMethods) {
+		t.Fatalf("mix: %v %v", methods, err)
+	}
+
+	methods, err = selectBenchMethods([]string{"get-pegnet-balances", "get-sync-status"})
+	if err != nil || len(methods) != 2 {
+		t.Fatalf("list: %v %v", methods, err)
+	}
+	r := rand.New(rand.NewSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[pickBenchMethod(r, methods).Name]++
+	}
+	if len(counts) != 2 || counts["get-pegnet-balances"] < 400 || counts["get-sync-status"] < 400 {
+		t.Errorf("methods are not requested evenly: %v", counts)
+	}
+
+	if _, err := selectBenchMethods([]string{"get-nothing"}); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
+
+func TestRunBenchDB(t *testing.T) {
+	p, done := benchScratchDB(t)
+	defer done()
+
+	opts := benchDBOptions{Sizes: []int{50, 20}, BlockTxs: 10, Addresses: 20, Queries: 3}
+	stages, err := runBenchDB(context.Background(), p, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 2 || stages[0].Transactions != 20 || stages[1].Transactions != 50 || stages[1].Blocks != 3 {
+		t.Fatalf("the stages do not grow to the sorted sizes: %+v", stages)
+	}
+	for _, s := range stages {
+		for _, q := range benchDBQueries {
+			if stats := s.Queries[q.Name]; len(stats.latencies) != opts.Queries || stats.lastError != nil {
+				t.Errorf("%s at %d: %d queries, %v", q.Name, s.Transactions, len(stats.latencies), stats.lastError)
+			}
+		}
+	}
+
+	// The workload is the same on every run
+	synced, err := p.SelectSynced(context.Background(), p.DB)
+	if err != nil || synced.Synced != 6 {
+		t.Errorf("synced: %v %v", synced, err)
+	}
+	hash, err := p.SelectStateHash(p.DB, 6, [32]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, done := benchScratchDB(t)
+	defer done()
+	if _, err := runBenchDB(context.Background(), again, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if hashAgain, err := again.SelectStateHash(again.DB, 6, [32]byte{}); err != nil || hashAgain != hash {
+		t.Errorf("the state differs between runs: %s != %s, %v", hash, hashAgain, err)
+	}
+}
+
+func benchScratchDB(t *testing.T) (*pegnet.Pegnet, func()) {
+	dir, err := ioutil.TempDir("", "pegnetd-bench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, filepath.Join(dir, "bench.db"))
+	p := pegnet.New(conf)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return p, func() {
+		p.Close()
+		os.RemoveAll(dir)
+	}
+}
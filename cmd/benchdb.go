@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	benchDB.Flags().String("dir", "", "The directory of the scratch database, defaults to the directory of the database of the config")
+	benchDB.Flags().IntSlice("sizes", []int{1000, 10000, 100000}, "The amounts of transactions in the history to measure at")
+	benchDB.Flags().Int("block-txs", 20, "The transactions applied per block")
+	benchDB.Flags().Int("addresses", 10000, "The amount of addresses the transactions are between")
+	benchDB.Flags().Int("queries", 100, "The queries of each kind to time at every size")
+	benchDB.Flags().Bool("keep", false, "Keep the scratch database instead of deleting it")
+	bench.AddCommand(benchDB)
+}
+
+// benchDBSeed makes the workload the same on every machine, so the numbers of
+// two runs compare
+const benchDBSeed = 1
+
+// benchDBOptions are the parameters of the workload
+type benchDBOptions struct {
+	Sizes     []int
+	BlockTxs  int
+	Addresses int
+	Queries   int
+}
+
+// benchDBStage is the apply throughput of the blocks that grew the history
+// to a size, and the latencies of the queries at that size
+type benchDBStage struct {
+	Transactions int
+	Blocks       int
+	Elapsed      time.Duration
+	Blocks50     time.Duration
+	Blocks99     time.Duration
+	Queries      map[string]*benchStats
+}
+
+// benchDBQuery times a query of the api against the database
+type benchDBQuery struct {
+	Name string
+	Run  func(r *rand.Rand, w *benchWorkload) error
+}
+
+var benchDBQueries = []benchDBQuery{
+	{"history by address", func(r *rand.Rand, w *benchWorkload) error {
+		_, _, err := w.p.SelectTransactionHistoryActionsByAddress(w.address(r), pegnet.HistoryQueryOptions{Desc: true})
+		return err
+	}},
+	{"history by height", func(r *rand.Rand, w *benchWorkload) error {
+		_, _, err := w.p.SelectTransactionHistoryActionsByHeight(w.first+uint32(r.Intn(int(w.height-w.first)))+1, pegnet.HistoryQueryOptions{})
+		return err
+	}},
+	{"balances", func(r *rand.Rand, w *benchWorkload) error {
+		_, err := w.p.SelectBalances(w.address(r))
+		return err
+	}},
+	{"rich list", func(r *rand.Rand, w *benchWorkload) error {
+		_, err := w.p.SelectRichList(fat2.PTickerPEG, 100)
+		return err
+	}},
+	{"global rich list", func(r *rand.Rand, w *benchWorkload) error {
+		_, err := w.p.SelectGlobalRichList(w.rates, 100)
+		return err
+	}},
+}
+
+// benchWorkload applies synthetic blocks through the same writes as the sync:
+// transfers and conversions between a pool of addresses, with their history,
+// relations, and address statistics, and the per block supply, statistics,
+// and balance journal
+type benchWorkload struct {
+	p         *pegnet.Pegnet
+	r         *rand.Rand
+	opts      benchDBOptions
+	addresses []factom.FAAddress
+	rates     map[fat2.PTicker]uint64
+	tickers   []fat2.PTicker
+
+	first, height uint32
+	txs           int
+	timestamp     time.Time
+}
+
+func newBenchWorkload(p *pegnet.Pegnet, opts benchDBOptions) *benchWorkload {
+	w := &benchWorkload{
+		p:         p,
+		r:         rand.New(rand.NewSource(benchDBSeed)),
+		opts:      opts,
+		rates:     make(map[fat2.PTicker]uint64),
+		first:     1,
+		height:    1,
+		timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < opts.Addresses; i++ {
+		var adr factom.FAAddress
+		w.r.Read(adr[:])
+		w.addresses = append(w.addresses, adr)
+	}
+	for i, a := range fat2.Assets() {
+		ticker := fat2.StringToTicker(a.Name)
+		w.rates[ticker] = uint64(i+1) * 1e7
+		w.tickers = append(w.tickers, ticker)
+	}
+	return w
+}
+
+func (w *benchWorkload) address(r *rand.Rand) *factom.FAAddress {
+	return &w.addresses[r.Intn(len(w.addresses))]
+}
+
+// genesis funds every address with 1M of every asset, so the supplies of a
+// large pool fit an int64
+func (w *benchWorkload) genesis(ctx context.Context) error {
+	tx, err := w.p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	values := make([]uint64, len(w.addresses))
+	for i := range values {
+		values[i] = 1e14
+	}
+	for _, ticker := range w.tickers {
+		if err := w.p.AddToBalances(tx, w.addresses, ticker, values); err != nil {
+			return err
+		}
+	}
+	if err := w.p.FinalizeBalanceJournal(tx, w.height); err != nil {
+		return err
+	}
+	if err := w.p.InsertSynced(tx, &pegnet.BlockSync{Synced: w.height}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyBlock applies and commits the next block
+func (w *benchWorkload) applyBlock(ctx context.Context) error {
+	w.height++
+	w.timestamp = w.timestamp.Add(10 * time.Minute)
+
+	tx, err := w.p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := w.p.InjectRates(tx, w.height, w.rates); err != nil {
+		return err
+	}
+	for i := 0; i < w.opts.BlockTxs; i++ {
+		batch := w.batch()
+		if err := w.p.InsertTransactionHistoryTxBatch(tx, i, batch, w.height); err != nil {
+			return err
+		}
+		if err := w.record(tx, batch); err != nil {
+			return err
+		}
+		w.txs++
+	}
+	if err := w.p.InsertSupplyHistory(tx, w.height); err != nil {
+		return err
+	}
+	if err := w.p.InsertNetworkStats(tx, w.height, w.timestamp); err != nil {
+		return err
+	}
+	if err := w.p.InsertConversionVolume(tx, w.height); err != nil {
+		return err
+	}
+	if err := w.p.InsertBlock(tx, w.height, w.timestamp); err != nil {
+		return err
+	}
+	if err := w.p.InsertSupplyTotals(tx, w.height); err != nil {
+		return err
+	}
+	if err := w.p.FinalizeBalanceJournal(tx, w.height); err != nil {
+		return err
+	}
+	if err := w.p.InsertBlockStateHash(tx, w.height, w.height == w.first+1); err != nil {
+		return err
+	}
+	if err := w.p.InsertSynced(tx, &pegnet.BlockSync{Synced: w.height}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// batch is a transfer to one to three addresses, or a conversion every fifth
+// time
+func (w *benchWorkload) batch() *fat2.TransactionBatch {
+	var hash factom.Bytes32
+	w.r.Read(hash[:])
+	input := fat2.TypedAddressAmountTuple{
+		Address: *w.address(w.r),
+		Type:    w.tickers[w.r.Intn(len(w.tickers))],
+		Amount:  uint64(1e8 + w.r.Intn(1e10)),
+	}
+	t := fat2.Transaction{Input: input}
+	if w.r.Intn(5) == 0 {
+		t.Conversion = w.tickers[w.r.Intn(len(w.tickers))]
+		for t.Conversion == t.Input.Type {
+			t.Conversion = w.tickers[w.r.Intn(len(w.tickers))]
+		}
+	} else {
+		outputs := 1 + w.r.Intn(3)
+		for i := 0; i < outputs; i++ {
+			amount := input.Amount / uint64(outputs)
+			if i == 0 {
+				amount += input.Amount % uint64(outputs)
+			}
+			t.Transfers = append(t.Transfers, fat2.AddressAmountTuple{Address: *w.address(w.r), Amount: amount})
+		}
+	}
+	return &fat2.TransactionBatch{
+		Version:      1,
+		Transactions: []fat2.Transaction{t},
+		Entry:        factom.Entry{Hash: &hash, ChainID: new(factom.Bytes32), Timestamp: w.timestamp},
+	}
+}
+
+// record applies the balance changes of the batch like recordBatch of the
+// sync does
+func (w *benchWorkload) record(tx *sql.Tx, batch *fat2.TransactionBatch) error {
+	changes := pegnet.NewBatchChanges(batch, w.height)
+	for index, t := range batch.Transactions {
+		changes.Debit(&t.Input.Address, t.Input.Type, t.Input.Amount)
+		changes.Relate(t.Input.Address, index, false, t.IsConversion())
+		changes.Activity(&t.Input.Address)
+
+		if t.IsConversion() {
+			output, err := conversions.Convert(int64(t.Input.Amount), w.rates[t.Input.Type], w.rates[t.Conversion])
+			if err != nil {
+				return err
+			}
+			changes.Converted(index, output, w.rates[t.Input.Type], w.rates[t.Conversion])
+			changes.Credit(&t.Input.Address, t.Conversion, uint64(output))
+			changes.Volume(&t.Input.Address, t.Input.Type, pegnet.ConvertedOut, int64(t.Input.Amount))
+			changes.Volume(&t.Input.Address, t.Conversion, pegnet.ConvertedIn, output)
+			continue
+		}
+
+		changes.Volume(&t.Input.Address, t.Input.Type, pegnet.TransferOut, int64(t.Input.Amount))
+		for _, transfer := range t.Transfers {
+			changes.Credit(&transfer.Address, t.Input.Type, transfer.Amount)
+			changes.Relate(transfer.Address, index, true, false)
+			changes.Activity(&transfer.Address)
+			changes.Volume(&transfer.Address, t.Input.Type, pegnet.TransferIn, int64(transfer.Amount))
+		}
+	}
+	return w.p.ApplyBatchChanges(tx, changes)
+}
+
+// runBenchDB grows the database to each of the sizes, and times the blocks
+// that got it there and the queries at the size
+func runBenchDB(ctx context.Context, p *pegnet.Pegnet, opts benchDBOptions, progress func(benchDBStage)) ([]benchDBStage, error) {
+	w := newBenchWorkload(p, opts)
+	if err := w.genesis(ctx); err != nil {
+		return nil, err
+	}
+
+	sizes := append([]int(nil), opts.Sizes...)
+	sort.Ints(sizes)
+	var stages []benchDBStage
+	for _, size := range sizes {
+		stage := benchDBStage{Queries: make(map[string]*benchStats)}
+		var blocks []time.Duration
+		start := time.Now()
+		for w.txs < size {
+			if ctx.Err() != nil {
+				return stages, ctx.Err()
+			}
+			blockStart := time.Now()
+			if err := w.applyBlock(ctx); err != nil {
+				return stages, fmt.Errorf("height %d: %v", w.height, err)
+			}
+			blocks = append(blocks, time.Since(blockStart))
+		}
+		stage.Elapsed = time.Since(start)
+		stage.Transactions = w.txs
+		stage.Blocks = len(blocks)
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+		stage.Blocks50, stage.Blocks99 = percentile(blocks, 50), percentile(blocks, 99)
+
+		r := rand.New(rand.NewSource(benchDBSeed + int64(size)))
+		for _, q := range benchDBQueries {
+			stats := new(benchStats)
+			for i := 0; i < opts.Queries; i++ {
+				queryStart := time.Now()
+				err := q.Run(r, w)
+				stats.add(time.Since(queryStart), err)
+			}
+			sort.Slice(stats.latencies, func(i, j int) bool { return stats.latencies[i] < stats.latencies[j] })
+			stage.Queries[q.Name] = stats
+		}
+		stages = append(stages, stage)
+		if progress != nil {
+			progress(stage)
+		}
+	}
+	return stages, nil
+}
+
+// benchDBFiles are the files sqlite keeps the database in
+func benchDBFiles(path string) []string {
+	return []string{path, path + "-wal", path + "-shm", path + "-journal"}
+}
+
+func benchDBSize(path string) int64 {
+	var size int64
+	for _, f := range benchDBFiles(path) {
+		if info, err := os.Stat(f); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+var benchDB = &cobra.Command{
+	Use:   "db [--dir <dir>] [--sizes <txs,...>]",
+	Short: "Benchmark the database operations of the sync and the api on the local storage",
+	Long: "Apply synthetic blocks to a scratch database until the transaction history reaches each of the sizes, " +
+		"and report the block apply throughput and the latencies of the history, balance, and rich list queries " +
+		"at every size. The blocks go through the same writes as the sync and the workload is seeded the same on " +
+		"every run, so the numbers compare across hardware and database modes.\n\n" +
+		"The scratch database is created next to the database of the config, or in --dir, and opened with the " +
+		"db.wal and db.mode of the config. The database of the node is not touched.",
+	Example:          "pegnetd bench db\npegnetd bench db --dir /mnt/nvme --sizes 100000,1000000 --dbmode \"_journal=WAL\"",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		dir, _ := cmd.Flags().GetString("dir")
+		keep, _ := cmd.Flags().GetBool("keep")
+		var opts benchDBOptions
+		opts.Sizes, _ = cmd.Flags().GetIntSlice("sizes")
+		opts.BlockTxs, _ = cmd.Flags().GetInt("block-txs")
+		opts.Addresses, _ = cmd.Flags().GetInt("addresses")
+		opts.Queries, _ = cmd.Flags().GetInt("queries")
+		if opts.BlockTxs < 1 || opts.Addresses < 1 || opts.Queries < 1 || len(opts.Sizes) == 0 {
+			cmd.PrintErrln("--sizes, --block-txs, --addresses, and --queries must be greater than 0")
+			os.Exit(1)
+		}
+		for _, size := range opts.Sizes {
+			if size < 1 {
+				cmd.PrintErrln("--sizes must be greater than 0")
+				os.Exit(1)
+			}
+		}
+		if dir == "" {
+			dir = filepath.Dir(viper.GetString(config.SqliteDBPath))
+		}
+
+		conf := viper.New()
+		config.SetDefaults(conf)
+		for _, key := range []string{config.SQLDBWalMode, config.CustomSQLDBMode, config.SlowQuery} {
+			conf.Set(key, viper.Get(key))
+		}
+		conf.Set(config.SqliteDBPath, filepath.Join(dir, fmt.Sprintf("bench-%d.db", os.Getpid())))
+		p := pegnet.New(conf)
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to create the scratch database")
+		}
+		path := pegnet.DBPath(conf)
+		defer func() {
+			p.Close()
+			if keep {
+				fmt.Printf("\nKept the scratch database %s\n", path)
+				return
+			}
+			for _, f := range benchDBFiles(path) {
+				_ = os.Remove(f)
+			}
+		}()
+
+		mode := "rollback journal"
+		if conf.GetBool(config.SQLDBWalMode) {
+			mode = "WAL"
+		}
+		if custom := conf.GetString(config.CustomSQLDBMode); custom != "" {
+			mode += ", " + custom
+		}
+		fmt.Printf("Benchmarking %s (%s), %d transactions per block between %d addresses\n\n",
+			path, mode, opts.BlockTxs, opts.Addresses)
+
+		round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+		stages, err := runBenchDB(ctx, p, opts, func(s benchDBStage) {
+			fmt.Printf("%d transactions in %d blocks, %.1f blocks/s, %.1f MB\n", s.Transactions, s.Blocks,
+				float64(s.Blocks)/s.Elapsed.Seconds(), float64(benchDBSize(path))/1e6)
+		})
+		if err != nil {
+			log.WithError(err).Error("the benchmark failed")
+			return
+		}
+
+		fmt.Println()
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Transactions\tBlocks\tBlocks/s\tTxs/s\tBlock p50\tBlock p99\t\n")
+		fmt.Fprintf(tw, "------------\t------\t--------\t-----\t---------\t---------\t\n")
+		for _, s := range stages {
+			fmt.Fprintf(tw, "%d\t%d\t%.1f\t%.0f\t%s\t%s\t\n", s.Transactions, s.Blocks,
+				float64(s.Blocks)/s.Elapsed.Seconds(), float64(s.Blocks*opts.BlockTxs)/s.Elapsed.Seconds(),
+				round(s.Blocks50), round(s.Blocks99))
+		}
+		tw.Flush()
+
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Query\tTransactions\tErrors\tp50\tp90\tp99\tMax\t\n")
+		fmt.Fprintf(tw, "-----\t------------\t------\t---\t---\t---\t---\t\n")
+		var failed []string
+		for _, q := range benchDBQueries {
+			for _, s := range stages {
+				stats := s.Queries[q.Name]
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", q.Name, s.Transactions, stats.errors,
+					round(percentile(stats.latencies, 50)), round(percentile(stats.latencies, 90)),
+					round(percentile(stats.latencies, 99)), round(percentile(stats.latencies, 100)))
+				if stats.lastError != nil {
+					failed = append(failed, fmt.Sprintf("%s at %d: %v", q.Name, s.Transactions, stats.lastError))
+				}
+			}
+		}
+		tw.Flush()
+		if len(failed) > 0 {
+			fmt.Printf("\n%s\n", strings.Join(failed, "\n"))
+		}
+	},
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/snapshot"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	exportSnapshot.Flags().String("out", "", "The file to write the snapshot to, compressed if it ends in '.gz'")
+	export.AddCommand(exportSnapshot)
+
+	initCmd.Flags().String("bootstrap", "", "The url or file of a snapshot to import, see 'pegnetd export snapshot'")
+	initCmd.Flags().String("statehash", "", "The trusted state hash of the snapshot, replaces bootstrap.statehash")
+	initCmd.Flags().StringSlice("node", nil, "A pegnetd api that has to report the state hash of the snapshot, replaces bootstrap.nodes")
+	initCmd.Flags().Bool("force", false, "Replace an existing database with the snapshot")
+	rootCmd.AddCommand(initCmd)
+}
+
+var exportSnapshot = &cobra.Command{
+	Use:   "snapshot --out <file>",
+	Short: "Export a snapshot of the local database for other nodes to bootstrap from",
+	Long: "Write a copy of the local database as of its synced height, which new nodes can import with " +
+		"'pegnetd init --bootstrap'. The tables of the node itself, like its deposit addresses, alerts, " +
+		"schedules, and send audit, are left empty. The snapshot can be taken while pegnetd syncs.\n\n" +
+		"Publish the printed state hash along with the snapshot, as the value to trust it by.",
+	Example:          "pegnetd export snapshot --out mainnet-snapshot.db.gz",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		out, _ := cmd.Flags().GetString("out")
+		if out == "" {
+			cmd.PrintErrln("--out must be specified")
+			os.Exit(1)
+		}
+
+		p := pegnet.New(viper.GetViper())
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		defer p.Close()
+
+		info, err := snapshot.Create(ctx, p, out)
+		if err != nil {
+			log.WithError(err).Fatal("failed to export the snapshot")
+		}
+		fmt.Printf("exported the snapshot of height %d to %s\nstate hash: %s\n", info.Height, out, info.StateHash)
+	},
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init [--bootstrap <url>]",
+	Short: "Create the config and the database, optionally from a snapshot",
+	Long: "Create the config and the database of the network. With --bootstrap the database is the snapshot " +
+		"of the url, and pegnetd syncs from the height of the snapshot instead of the first height of the pegnet.\n\n" +
+		"The snapshot is only imported once the state hash of every height is recomputed from its rows and " +
+		"the hash of its height is trusted: it has to be the --statehash, and the hash every --node reports " +
+		"at that height. Without the flags bootstrap.statehash and bootstrap.nodes of the config are used.",
+	Example: "pegnetd init --bootstrap https://example.com/mainnet-snapshot.db.gz --statehash <hash>\n" +
+		"pegnetd init --bootstrap mainnet-snapshot.db.gz --node https://pegnetd.example.com --node http://localhost:8070",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		conf := viper.GetViper()
+		if conf.GetBool(config.Regtest) {
+			cmd.PrintErrln("the regtest mode starts from a new database on every run")
+			os.Exit(1)
+		}
+		dbpath := pegnet.DBPath(conf)
+		src, _ := cmd.Flags().GetString("bootstrap")
+		force, _ := cmd.Flags().GetBool("force")
+		if _, err := os.Stat(dbpath); err == nil && (src == "" || !force) {
+			cmd.PrintErrf("the database %s already exists, --force replaces it with the snapshot\n", dbpath)
+			os.Exit(1)
+		}
+
+		if src != "" {
+			bootstrap(ctx, cmd, conf, src, dbpath)
+		}
+
+		p := pegnet.New(conf)
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		defer p.Close()
+		if err := p.CheckNetwork(nil, node.ActiveNetwork.Name); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		// A new database syncs from the first height of the pegnet
+		synced, err := p.SelectSynced(ctx, p.DB)
+		if err == sql.ErrNoRows {
+			synced, err = &pegnet.BlockSync{Synced: activation.Height(activation.Pegnet)}, nil
+		}
+		if err != nil {
+			log.WithError(err).Fatal("failed to find the synced height")
+		}
+		fmt.Printf("initialized the database %s of %s, pegnetd syncs from height %d\n", dbpath, node.ActiveNetwork.Name, synced.Synced+1)
+	},
+}
+
+// bootstrap downloads, verifies, and installs the snapshot as the database
+func bootstrap(ctx context.Context, cmd *cobra.Command, conf *viper.Viper, src, dbpath string) {
+	hash := conf.GetString(config.BootstrapStateHash)
+	if cmd.Flags().Changed("statehash") {
+		hash, _ = cmd.Flags().GetString("statehash")
+	}
+	var trusted *factom.Bytes32
+	if hash != "" {
+		trusted = new(factom.Bytes32)
+		if err := trusted.Set(hash); err != nil {
+			cmd.PrintErrf("invalid state hash: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	nodes := conf.GetStringSlice(config.BootstrapNodes)
+	if cmd.Flags().Changed("node") {
+		nodes, _ = cmd.Flags().GetStringSlice("node")
+	}
+	if trusted == nil && len(nodes) == 0 {
+		cmd.PrintErrln("a snapshot needs a trusted --statehash or --node to compare it to")
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbpath), 0777); err != nil {
+		log.WithError(err).Fatal("failed to create the database directory")
+	}
+	// The download is next to the database, so it is moved in place at once
+	tmp := dbpath + ".bootstrap"
+	_ = os.Remove(tmp)
+	fail := func(err error, msg string) {
+		_ = os.Remove(tmp)
+		log.WithError(err).Fatal(msg)
+	}
+
+	log.WithField("source", src).Info("downloading the snapshot")
+	if err := snapshot.Download(ctx, http.DefaultClient, src, tmp); err != nil {
+		fail(err, "failed to download the snapshot")
+	}
+	log.Info("recomputing the state hashes of the snapshot")
+	info, err := snapshot.Verify(ctx, tmp, node.ActiveNetwork.Name)
+	if err != nil {
+		fail(err, "the snapshot is invalid")
+	}
+	if err := snapshot.Trust(ctx, info, trusted, nodes); err != nil {
+		fail(err, "the snapshot is not trusted")
+	}
+
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbpath + suffix); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Fatal("failed to remove the database")
+		}
+	}
+	if err := os.Rename(tmp, dbpath); err != nil {
+		fail(err, "failed to import the snapshot")
+	}
+	log.WithFields(log.Fields{"height": info.Height, "statehash": info.StateHash}).Info("imported the snapshot")
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/srv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	depositsAdd.Flags().Int("confirmations", -1, "The confirmations required for a deposit, defaults to the node config")
+	deposits.AddCommand(depositsAdd)
+	deposits.AddCommand(depositsRemove)
+	deposits.AddCommand(depositsAddresses)
+	rootCmd.AddCommand(deposits)
+
+	listDeposits.Flags().Int("since", 0, "Only list deposits applied at or after this height")
+	listDeposits.Flags().String("address", "", "Only list deposits to this address")
+	listDeposits.Flags().Bool("raw", false, "Print the deposits as json, one per line")
+	rootCmd.AddCommand(listDeposits)
+}
+
+var deposits = &cobra.Command{
+	Use:   "deposits <subcommand>",
+	Short: "Manage the watch-list of deposit addresses",
+}
+
+var depositsAdd = &cobra.Command{
+	Use:              "add <address>",
+	Short:            "Record all deposits to the address, starting with the next block",
+	Example:          "pegnetd deposits add FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q --confirmations=6",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := srv.ParamsDepositAddress{Address: resolveAlias(cmd, args[0])}
+		if confirmations, _ := cmd.Flags().GetInt("confirmations"); confirmations >= 0 {
+			c := uint32(confirmations)
+			params.Confirmations = &c
+		}
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var res json.RawMessage
+		if err := cl.Request("add-deposit-address", params, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(res))
+	},
+}
+
+var depositsRemove = &cobra.Command{
+	Use:              "remove <address>",
+	Short:            "Stop recording deposits to the address. Recorded deposits are kept",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var res bool
+		if err := cl.Request("remove-deposit-address", srv.ParamsDepositAddress{Address: resolveAlias(cmd, args[0])}, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+var depositsAddresses = &cobra.Command{
+	Use:              "addresses",
+	Short:            "List the watched deposit addresses",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+		var res []struct {
+			Address       string `json:"address"`
+			Confirmations uint32 `json:"confirmations"`
+			Added         uint32 `json:"added"`
+		}
+		if err := cl.Request("get-deposit-addresses", nil, &res); err != nil {
+			fmt.Printf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Address\tConfirmations\tAdded")
+		for _, a := range res {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", a.Address, a.Confirmations, a.Added)
+		}
+		w.Flush()
+	},
+}
+
+var listDeposits = &cobra.Command{
+	Use:              "list-deposits",
+	Short:            "List the deposits to the watched addresses",
+	Example:          "pegnetd list-deposits --since=230000",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetInt("since")
+		address, _ := cmd.Flags().GetString("address")
+		address = resolveAlias(cmd, address)
+		raw, _ := cmd.Flags().GetBool("raw")
+
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+
+		enc := json.NewEncoder(os.Stdout)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if !raw {
+			fmt.Fprintln(w, "Height\tAddress\tAmount\tConfirmations\tStatus\tTxID")
+		}
+
+		params := srv.ParamsListDeposits{Since: since, Address: address}
+		for {
+			var res srv.ResultListDeposits
+			if err := cl.Request("list-deposits", params, &res); err != nil {
+				w.Flush()
+				cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+				os.Exit(1)
+			}
+
+			for _, d := range res.Deposits {
+				if raw {
+					if err := enc.Encode(d); err != nil {
+						panic(err)
+					}
+					continue
+				}
+				status := "pending"
+				if d.Confirmed > 0 {
+					status = "confirmed"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s %s\t%d/%d\t%s\t%s\n", d.Height, d.Address, FactoshiToFactoid(d.Amount), d.Asset,
+					d.Confirmations, d.RequiredConfirmations, status, d.TxID)
+			}
+			if res.Next == 0 {
+				break
+			}
+			params.After = res.Next
+		}
+		w.Flush()
+	},
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func init() {
+	exportAnalytics.Flags().String("out", "", "The directory to write the parquet files to")
+	exportAnalytics.Flags().Uint32("start", 0, "The first height to export")
+	exportAnalytics.Flags().Uint32("stop", 0, "The last height to export, 0 for the latest synced height")
+	exportAnalytics.Flags().Uint32("partition", 10000, "The amount of heights in each file")
+	export.AddCommand(exportAnalytics)
+	rootCmd.AddCommand(export)
+}
+
+var export = &cobra.Command{
+	Use:   "export <subcommand>",
+	Short: "Export data from the local pegnetd database",
+}
+
+var exportAnalytics = &cobra.Command{
+	Use:   "analytics --out <dir>",
+	Short: "Export transactions, conversions, burns, and rates as parquet files",
+	Long: "Export transactions, conversions, burns, and rates from the local database as parquet files. " +
+		"Each dataset is written to its own directory, with one file per height range, " +
+		"eg: 'out/conversions/0000210000-0000219999.parquet'.",
+	Example:          "pegnetd export analytics --out pegnet-analytics/",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		out, _ := cmd.Flags().GetString("out")
+		start, _ := cmd.Flags().GetUint32("start")
+		stop, _ := cmd.Flags().GetUint32("stop")
+		partition, _ := cmd.Flags().GetUint32("partition")
+		if out == "" {
+			cmd.PrintErrln("--out must be specified")
+			os.Exit(1)
+		}
+		if partition == 0 {
+			cmd.PrintErrln("--partition must be greater than 0")
+			os.Exit(1)
+		}
+
+		p := pegnet.New(viper.GetViper())
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		defer p.Close()
+
+		if stop == 0 {
+			synced, err := p.SelectSynced(ctx, p.DB)
+			if err != nil {
+				log.WithError(err).Fatal("failed to find the synced height")
+			}
+			stop = synced.Synced
+		}
+		if stop < start {
+			cmd.PrintErrln("--stop must be >= --start")
+			os.Exit(1)
+		}
+
+		for _, dataset := range []string{"transactions", "conversions", "burns", "rates"} {
+			if err := os.MkdirAll(filepath.Join(out, dataset), 0777); err != nil {
+				log.WithError(err).Fatal("failed to create the output directory")
+			}
+		}
+
+		// Align the partitions so repeated exports produce the same files
+		for from := start - start%partition; from <= stop; from += partition {
+			to := from + partition - 1
+			if to < from { // overflow
+				to = stop
+			}
+			if err := exportAnalyticsPartition(ctx, p, out, from, to); err != nil {
+				log.WithError(err).Fatalf("failed to export heights %d to %d", from, to)
+			}
+			log.Infof("Exported heights %d to %d", from, to)
+			if to >= stop {
+				break
+			}
+		}
+	},
+}
+
+// exportTransaction is a row of the "transactions" dataset.
+// Transfer outputs are json encoded.
+type exportTransaction struct {
+	Height    int64  `parquet:"name=height, type=INT64"`
+	Timestamp int64  `parquet:"name=timestamp, type=TIMESTAMP_MILLIS"`
+	EntryHash string `parquet:"name=entry_hash, type=UTF8"`
+	TxID      string `parquet:"name=txid, type=UTF8"`
+	TxIndex   int32  `parquet:"name=tx_index, type=INT32"`
+	Executed  int32  `parquet:"name=executed, type=INT32"`
+	Action    string `parquet:"name=action, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Address   string `parquet:"name=from_address, type=UTF8"`
+	FromAsset string `parquet:"name=from_asset, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	FromAmt   int64  `parquet:"name=from_amount, type=INT64"`
+	ToAsset   string `parquet:"name=to_asset, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	ToAmt     int64  `parquet:"name=to_amount, type=INT64"`
+	Outputs   string `parquet:"name=outputs, type=UTF8"`
+}
+
+// exportConversion is a row of the "conversions" dataset
+type exportConversion struct {
+	Height    int64  `parquet:"name=height, type=INT64"`
+	Executed  int32  `parquet:"name=executed, type=INT32"`
+	TxID      string `parquet:"name=txid, type=UTF8"`
+	Address   string `parquet:"name=address, type=UTF8"`
+	FromAsset string `parquet:"name=from_asset, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	FromAmt   int64  `parquet:"name=from_amount, type=INT64"`
+	FromRate  int64  `parquet:"name=from_rate, type=INT64"`
+	ToAsset   string `parquet:"name=to_asset, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	ToAmt     int64  `parquet:"name=to_amount, type=INT64"`
+	ToRate    int64  `parquet:"name=to_rate, type=INT64"`
+}
+
+// exportBurn is a row of the "burns" dataset
+type exportBurn struct {
+	Height  int64  `parquet:"name=height, type=INT64"`
+	TxID    string `parquet:"name=fct_txid, type=UTF8"`
+	Address string `parquet:"name=address, type=UTF8"`
+	FCT     int64  `parquet:"name=fct_burned, type=INT64"`
+	PFCT    int64  `parquet:"name=pfct_credited, type=INT64"`
+}
+
+// exportRate is a row of the "rates" dataset
+type exportRate struct {
+	Height int64  `parquet:"name=height, type=INT64"`
+	Asset  string `parquet:"name=asset, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Rate   int64  `parquet:"name=rate, type=INT64"`
+}
+
+func exportAnalyticsPartition(ctx context.Context, p *pegnet.Pegnet, out string, from, to uint32) error {
+	name := fmt.Sprintf("%010d-%010d.parquet", from, to)
+
+	history, err := p.SelectTransactionHistoryActionsByHeightRange(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	var txs, cvts, burns []interface{}
+	for _, h := range history {
+		outputs := ""
+		if len(h.Outputs) > 0 {
+			data, err := json.Marshal(h.Outputs)
+			if err != nil {
+				return err
+			}
+			outputs = string(data)
+		}
+		txs = append(txs, exportTransaction{
+			Height:    h.Height,
+			Timestamp: h.Timestamp.Unix() * 1000,
+			EntryHash: h.Hash.String(),
+			TxID:      h.TxID,
+			TxIndex:   int32(h.TxIndex),
+			Executed:  h.Executed,
+			Action:    exportActionName(h.TxAction),
+			Address:   h.FromAddress.String(),
+			FromAsset: h.FromAsset,
+			FromAmt:   h.FromAmount,
+			ToAsset:   h.ToAsset,
+			ToAmt:     h.ToAmount,
+			Outputs:   outputs,
+		})
+
+		switch h.TxAction {
+		case pegnet.Conversion:
+			cvts = append(cvts, exportConversion{
+				Height:    h.Height,
+				Executed:  h.Executed,
+				TxID:      h.TxID,
+				Address:   h.FromAddress.String(),
+				FromAsset: h.FromAsset,
+				FromAmt:   h.FromAmount,
+				FromRate:  int64(h.FromRate),
+				ToAsset:   h.ToAsset,
+				ToAmt:     h.ToAmount,
+				ToRate:    int64(h.ToRate),
+			})
+		case pegnet.FCTBurn:
+			burns = append(burns, exportBurn{
+				Height:  h.Height,
+				TxID:    h.Hash.String(),
+				Address: h.FromAddress.String(),
+				FCT:     h.FromAmount,
+				PFCT:    h.ToAmount,
+			})
+		}
+	}
+
+	rates, err := p.SelectRatesByHeightRange(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	heights := make([]uint32, 0, len(rates))
+	for height := range rates {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	var rateRows []interface{}
+	for _, height := range heights {
+		tokens := make([]string, 0, len(rates[height]))
+		for token := range rates[height] {
+			tokens = append(tokens, token)
+		}
+		sort.Strings(tokens)
+		for _, token := range tokens {
+			rateRows = append(rateRows, exportRate{Height: int64(height), Asset: token, Rate: int64(rates[height][token])})
+		}
+	}
+
+	for _, dataset := range []struct {
+		dir  string
+		obj  interface{}
+		rows []interface{}
+	}{
+		{"transactions", new(exportTransaction), txs},
+		{"conversions", new(exportConversion), cvts},
+		{"burns", new(exportBurn), burns},
+		{"rates", new(exportRate), rateRows},
+	} {
+		if err := writeParquet(filepath.Join(out, dataset.dir, name), dataset.obj, dataset.rows); err != nil {
+			return fmt.Errorf("%s: %v", dataset.dir, err)
+		}
+	}
+	return nil
+}
+
+// writeParquet writes the rows to a snappy compressed parquet file. Empty
+// partitions are not written.
+func writeParquet(path string, obj interface{}, rows []interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pw, err := writer.NewParquetWriterFromWriter(f, obj, 1)
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, row := range rows {
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func exportActionName(action pegnet.HistoryAction) string {
+	switch action {
+	case pegnet.Transfer:
+		return "transfer"
+	case pegnet.Conversion:
+		return "conversion"
+	case pegnet.Coinbase:
+		return "coinbase"
+	case pegnet.FCTBurn:
+		return "burn"
+	case pegnet.Refund:
+		return "refund"
+	}
+	return "invalid"
+}
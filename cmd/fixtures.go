@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/fixtures"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	genFixtures.Flags().String("out", "fixtures", "The directory to write the databases and entry sets to")
+	rootCmd.AddCommand(genFixtures)
+}
+
+func fixtureScenarios() string {
+	var list []string
+	for _, s := range fixtures.Scenarios {
+		list = append(list, fmt.Sprintf("  %-12s %s", s.Name, s.Description))
+	}
+	return strings.Join(list, "\n")
+}
+
+var genFixtures = &cobra.Command{
+	Use:   "gen-fixtures [scenario...] --out <dir>",
+	Short: "Generate deterministic databases and entry sets of common scenarios",
+	Long: "Generate the database and the entry set of each scenario, all scenarios if none are given. " +
+		"The scenarios are synced on the RegTest network from an embedded mock factomd with a fixed clock " +
+		"and keys derived from the account names, so every run produces the same entries and balances.\n\n" +
+		"Every scenario writes '<scenario>.db.v4', the database to set as the dbpath 'out/<scenario>.db', " +
+		"'<scenario>.json' with the accounts, their secrets, and the transaction entries by height, " +
+		"and '<scenario>.dump' with the blocks to replay with 'pegnetd replay'.\n\n" +
+		"Scenarios:\n" + fixtureScenarios(),
+	Example:          "pegnetd gen-fixtures prorated --out testdata/",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		out, _ := cmd.Flags().GetString("out")
+		scenarios := fixtures.Scenarios
+		if len(args) > 0 {
+			scenarios = nil
+			for _, name := range args {
+				s, ok := fixtures.Lookup(name)
+				if !ok {
+					cmd.PrintErrf("unknown scenario %q, the scenarios are:\n%s\n", name, fixtureScenarios())
+					os.Exit(1)
+				}
+				scenarios = append(scenarios, s)
+			}
+		}
+
+		for _, s := range scenarios {
+			fixture, err := fixtures.Generate(ctx, s, out)
+			if err != nil {
+				log.WithError(err).Fatal("failed to generate the fixture")
+			}
+			fmt.Printf("%s: height %d, %d accounts, %d entries, dbpath %s\n",
+				s.Name, fixture.Height, len(fixture.Accounts), len(fixture.Entries), fixtures.DBPath(out, s.Name))
+		}
+	},
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/fuzz"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/replay"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	fuzzSeed.Flags().String("workdir", "fuzzdata", "The workdir to write the corpus of every target to")
+	fuzzSeed.Flags().String("dump", "", "Seed from a dump of 'pegnetd export entries' instead of factomd")
+	fuzzSeed.Flags().Uint32("start", 0, "The first height to fetch from factomd, 100 heights before --stop if 0")
+	fuzzSeed.Flags().Uint32("stop", 0, "The last height to fetch from factomd, the latest height if 0")
+	fuzzSeed.Flags().Int("per-height", 4, "The most entries of a target to take from one height, 0 for all")
+	fuzzCmd.AddCommand(fuzzSeed)
+
+	fuzzRun.Flags().String("workdir", "fuzzdata", "The workdir with the corpus of the target")
+	fuzzRun.Flags().Duration("duration", 0, "How long to run, until interrupted if 0")
+	fuzzRun.Flags().Int("workers", runtime.NumCPU(), "The amount of inputs to execute at once")
+	fuzzRun.Flags().Int64("seed", 0, "The seed of the mutations, the current time if 0")
+	fuzzCmd.AddCommand(fuzzRun)
+
+	rootCmd.AddCommand(fuzzCmd)
+}
+
+func fuzzTargets() string {
+	var list []string
+	for _, t := range fuzz.Targets {
+		list = append(list, fmt.Sprintf("  %-14s %s", t.Name, t.Description))
+	}
+	return strings.Join(list, "\n")
+}
+
+func lookupFuzzTarget(cmd *cobra.Command, name string) fuzz.Target {
+	t, ok := fuzz.Lookup(name)
+	if !ok {
+		cmd.PrintErrf("unknown target %q, the targets are:\n%s\n", name, fuzzTargets())
+		os.Exit(1)
+	}
+	return t
+}
+
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz",
+	Short: "Fuzz the parsers of the transaction and OPR entries",
+	Long: "Fuzz the parsers of the chain entries pegnetd applies, so a malformed entry cannot crash the sync. " +
+		"An input of a target is the binary of a factom entry. 'pegnetd fuzz seed' writes the entries of the " +
+		"network to a corpus in the go-fuzz layout '<workdir>/<target>/corpus', and 'pegnetd fuzz run' mutates " +
+		"them with a built-in mutator. Inputs that panic are written to '<workdir>/<target>/crashers'.\n\n" +
+		"The harnesses are the Fuzz functions of the fuzz package, to run them with go-fuzz or libFuzzer:\n" +
+		"  go-fuzz-build -func FuzzTransactionBatch github.com/pegnet/pegnetd/fuzz\n" +
+		"  go-fuzz -bin fuzz-fuzz.zip -workdir fuzzdata/transactions\n" +
+		"  go-fuzz-build -libfuzzer -func FuzzOPR -o opr.a github.com/pegnet/pegnetd/fuzz\n\n" +
+		"Targets:\n" + fuzzTargets(),
+}
+
+var fuzzSeed = &cobra.Command{
+	Use:   "seed [target...] [--dump <file>] [--start <height>] [--stop <height>]",
+	Short: "Seed the corpus of the targets with the entries of the network",
+	Long: "Write the OPR and transaction entries of a range of heights, fetched from factomd or read from a " +
+		"dump of 'pegnetd export entries', to the corpus of the targets, of all targets if none are given. " +
+		"Entries are named by their sha1, seeding again only adds the new ones.",
+	Example:          "pegnetd fuzz seed --start 206422 --stop 240000 --per-height 2\npegnetd fuzz seed transactions --dump mainnet.dump.gz --per-height 0",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		workdir, _ := cmd.Flags().GetString("workdir")
+		dumpPath, _ := cmd.Flags().GetString("dump")
+		start, _ := cmd.Flags().GetUint32("start")
+		stop, _ := cmd.Flags().GetUint32("stop")
+		perHeight, _ := cmd.Flags().GetInt("per-height")
+
+		targets := fuzz.Targets
+		if len(args) > 0 {
+			targets = nil
+			for _, name := range args {
+				targets = append(targets, lookupFuzzTarget(cmd, name))
+			}
+		}
+
+		// next returns the blocks of the range, nil after the last one
+		var next func() (*replay.Block, error)
+		if dumpPath != "" {
+			dump, err := replay.Open(dumpPath)
+			if err != nil {
+				log.WithError(err).Fatal("failed to open the dump")
+			}
+			defer dump.Close()
+			if dump.Header.Network != node.ActiveNetwork.Name {
+				cmd.PrintErrf("the dump is of %s, the node is set to %s\n", dump.Header.Network, node.ActiveNetwork.Name)
+				os.Exit(1)
+			}
+			next = func() (*replay.Block, error) {
+				b, err := dump.Next()
+				if err == io.EOF {
+					return nil, nil
+				}
+				return b, err
+			}
+		} else {
+			cl := node.FactomClientFromConfig(viper.GetViper())
+			if stop == 0 {
+				heights := new(factom.Heights)
+				if err := heights.Get(ctx, cl); err != nil {
+					log.WithError(err).Fatal("failed to fetch the heights of factomd")
+				}
+				stop = heights.DirectoryBlock
+			}
+			if start == 0 && stop >= 100 {
+				start = stop - 99
+			}
+			if stop < start {
+				cmd.PrintErrln("--stop must be >= --start")
+				os.Exit(1)
+			}
+			var chains []factom.Bytes32
+			for _, t := range targets {
+				chains = append(chains, t.Chain())
+			}
+			height := start
+			next = func() (*replay.Block, error) {
+				if height > stop {
+					return nil, nil
+				}
+				height++
+				return replay.Fetch(ctx, cl, height-1, chains...)
+			}
+		}
+
+		written := make(map[string]int)
+		for {
+			b, err := next()
+			if err != nil {
+				log.WithError(err).Fatal("failed to read the block")
+			}
+			if b == nil {
+				break
+			}
+			for _, t := range targets {
+				entries, err := fuzz.Entries(b, t.Chain())
+				if err != nil {
+					log.WithError(err).Fatal("failed to read the entries of the block")
+				}
+				if perHeight > 0 && len(entries) > perHeight {
+					entries = entries[:perHeight]
+				}
+				for _, e := range entries {
+					if _, err := fuzz.WriteInput(fuzz.CorpusDir(workdir, t.Name), e); err != nil {
+						log.WithError(err).Fatal("failed to write the corpus")
+					}
+					written[t.Name]++
+				}
+			}
+		}
+
+		for _, t := range targets {
+			fmt.Printf("%s: %d entries in %s\n", t.Name, written[t.Name], fuzz.CorpusDir(workdir, t.Name))
+		}
+	},
+}
+
+var fuzzRun = &cobra.Command{
+	Use:   "run <target> [--workdir <dir>] [--duration <duration>]",
+	Short: "Run a target with the built-in mutator",
+	Long: "Mutate the corpus of the target and execute the harness until the duration passes or the command " +
+		"is interrupted. The mutator has no coverage feedback, go-fuzz explores deeper, see 'pegnetd fuzz --help'. " +
+		"Exits 1 if an input crashed the target.",
+	Example:          "pegnetd fuzz run transactions --duration 10m",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		t := lookupFuzzTarget(cmd, args[0])
+		workdir, _ := cmd.Flags().GetString("workdir")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		workers, _ := cmd.Flags().GetInt("workers")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+
+		report := func(s fuzz.Stats) {
+			log.WithFields(log.Fields{
+				"target":   t.Name,
+				"execs":    s.Execs,
+				"execs/s":  uint64(float64(s.Execs) / s.Elapsed.Seconds()),
+				"corpus":   s.Corpus,
+				"crashers": s.Crashers,
+			}).Info("fuzz stats")
+		}
+		stats, err := fuzz.Run(ctx, t, workdir, fuzz.Options{
+			Duration:       duration,
+			Workers:        workers,
+			Seed:           seed,
+			Report:         report,
+			ReportInterval: 15 * time.Second,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("failed to run the target")
+		}
+		report(stats)
+		if stats.Crashers > 0 {
+			cmd.PrintErrf("%d crashers in %s\n", stats.Crashers, fuzz.CrashersDir(workdir, t.Name))
+			os.Exit(1)
+		}
+	},
+}
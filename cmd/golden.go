@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pegnet/pegnetd/conformance"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	genGolden.Flags().String("dir", filepath.Join("conformance", "testdata"), "The directory of the golden files")
+	genGolden.Flags().Bool("check", false, "Only compare the golden files to the conversion engine, and exit 1 if any differ")
+	rootCmd.AddCommand(genGolden)
+}
+
+var genGolden = &cobra.Command{
+	Use:   "gen-golden [--dir <dir>] [--check]",
+	Short: "Regenerate the expected outputs of the conversion golden files",
+	Long: "Run the rates and pending conversions of every golden file through the conversions and the " +
+		"PEG conversion limit of this build, and write the outputs, PEG payouts, refunds, and rejections " +
+		"as the expected result of the file. A file is '<name>.json' with a case of the height, the network, " +
+		"the rates, and the conversions, new files can leave the expected result out.\n\n" +
+		"The golden files are tested with 'go test ./conformance'. Regenerate them only once a change of " +
+		"the protocol is agreed on, and review the diff of the expected results.",
+	Example:          "pegnetd gen-golden --check\npegnetd gen-golden --dir conformance/testdata",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		check, _ := cmd.Flags().GetBool("check")
+
+		if check {
+			files, err := conformance.Load(dir)
+			if err != nil {
+				log.WithError(err).Fatal("failed to read the golden files")
+			}
+			var failed int
+			for _, name := range conformance.Names(files) {
+				if err := files[name].Verify(); err != nil {
+					failed++
+					cmd.PrintErrf("%s: %v\n", name, err)
+				}
+			}
+			if failed > 0 {
+				cmd.PrintErrf("%d of %d golden files differ\n", failed, len(files))
+				os.Exit(1)
+			}
+			fmt.Printf("all %d golden files match\n", len(files))
+			return
+		}
+
+		changed, err := conformance.Regenerate(dir)
+		for _, name := range changed {
+			fmt.Printf("updated %s\n", filepath.Join(dir, name))
+		}
+		if err != nil {
+			log.WithError(err).Fatal("failed to regenerate the golden files")
+		}
+		if len(changed) == 0 {
+			fmt.Println("all golden files are up to date")
+		}
+	},
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/hd"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/srv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	hdNew.Flags().Int("words", 24, "The amount of words, 12, 15, 18, 21, or 24")
+	hdAddresses.Flags().Uint32("account", 0, "The BIP44 account of the addresses")
+	hdAddresses.Flags().Uint32("start", 0, "The index of the first address")
+	hdAddresses.Flags().Uint32("count", 10, "The amount of addresses")
+	hdAddresses.Flags().Bool("secret", false, "Also print the private keys")
+	hdScan.Flags().Uint32("gap", 20, "The amount of unused addresses in a row that ends an account")
+	hdScan.Flags().Bool("import", false, "Import the private keys of the used addresses into walletd")
+	for _, c := range []*cobra.Command{hdAddresses, hdScan} {
+		c.Flags().Bool("passphrase", false, "Prompt for the BIP39 passphrase of the mnemonic")
+	}
+	hdCmd.AddCommand(hdNew)
+	hdCmd.AddCommand(hdAddresses)
+	hdCmd.AddCommand(hdScan)
+	rootCmd.AddCommand(hdCmd)
+}
+
+var hdCmd = &cobra.Command{
+	Use:   "hd <subcommand>",
+	Short: "Derive FA addresses from a BIP39 mnemonic",
+	Long: "A mnemonic of 12 to 24 words backs up all addresses that are derived from it ('hd new'). The " +
+		"addresses are derived along the BIP44 path m/44'/131'/account'/0/index, like factom-walletd and the " +
+		"hardware wallets do ('hd addresses'). The addresses of a mnemonic that have a history on the node are " +
+		"found with an account discovery scan, which can import their keys into walletd ('hd scan'). The " +
+		"mnemonic is prompted for, or read from stdin if it is not a terminal.",
+}
+
+var hdNew = &cobra.Command{
+	Use:              "new",
+	Short:            "Generate a random mnemonic",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		words, _ := cmd.Flags().GetInt("words")
+		mnemonic, err := hd.NewMnemonic(words)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Println(mnemonic)
+		cmd.PrintErrln("Write the words down and keep them safe, anyone with them can spend the funds of all its addresses.")
+	},
+}
+
+var hdAddresses = &cobra.Command{
+	Use:              "addresses",
+	Short:            "Print the addresses of a mnemonic",
+	Example:          "pegnetd hd addresses --account 0 --start 0 --count 20",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		master := hdMasterKey(cmd)
+		account, _ := cmd.Flags().GetUint32("account")
+		start, _ := cmd.Flags().GetUint32("start")
+		count, _ := cmd.Flags().GetUint32("count")
+		secret, _ := cmd.Flags().GetBool("secret")
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i := start; i < start+count; i++ {
+			fs, err := hd.FactoidAddress(master, account, i)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t%v\n", hd.FormatPath(hd.FactoidPath(account, i)), err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s", hd.FormatPath(hd.FactoidPath(account, i)), fs.FAAddress())
+			if secret {
+				fmt.Fprintf(w, "\t%s", fs)
+			}
+			fmt.Fprintln(w)
+		}
+		_ = w.Flush()
+	},
+}
+
+var hdScan = &cobra.Command{
+	Use:   "scan",
+	Short: "Find the addresses of a mnemonic that have a history on the node",
+	Long: "Scan the accounts of the mnemonic in order, the addresses of an account until 'gap' unused addresses " +
+		"in a row. The scan ends at the first account without a used address. An address is used if it took " +
+		"part in a transaction, conversion, burn, or coinbase on the node.",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		master := hdMasterKey(cmd)
+		gap, _ := cmd.Flags().GetUint32("gap")
+		if gap == 0 {
+			cmd.PrintErrln("gap must be greater than 0")
+			os.Exit(1)
+		}
+		cl := srv.NewClient()
+		cl.PegnetdServer = viper.GetString(config.Pegnetd)
+
+		var used []factom.FsAddress
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PATH\tADDRESS\tTRANSACTIONS\tFIRST SEEN\tLAST ACTIVE")
+		for account := uint32(0); account < hd.Hardened; account++ {
+			found := 0
+			for i, unused := uint32(0), uint32(0); unused < gap && i < hd.Hardened; i++ {
+				fs, err := hd.FactoidAddress(master, account, i)
+				if err != nil {
+					continue // the index is skipped, like BIP32 says
+				}
+				var stats srv.ResultGetAddressStats
+				err = cl.Request("get-address-stats", srv.ParamsGetAddressStats{Address: fs.FAAddress().String()}, &stats)
+				if jerr, ok := err.(jrpc.Error); ok && jerr.Code == srv.ErrorAddressNotFound.Code {
+					unused++
+					continue
+				}
+				if err != nil {
+					_ = w.Flush()
+					cmd.PrintErrf("failed to get the stats of %s: %v\n", fs.FAAddress(), err)
+					os.Exit(1)
+				}
+				unused = 0
+				found++
+				used = append(used, fs)
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", hd.FormatPath(hd.FactoidPath(account, i)), stats.Address,
+					stats.Transactions, stats.FirstSeen, stats.LastActive)
+			}
+			if found == 0 {
+				break
+			}
+		}
+		_ = w.Flush()
+		fmt.Printf("\n%d used addresses\n", len(used))
+
+		if ok, _ := cmd.Flags().GetBool("import"); ok && len(used) > 0 {
+			if err := importWalletd(used); err != nil {
+				cmd.PrintErrf("failed to import the addresses into walletd: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Imported %d addresses into walletd\n", len(used))
+		}
+	},
+}
+
+// hdMasterKey prompts for the mnemonic and the passphrase, if the flag is
+// set, and returns the master key
+func hdMasterKey(cmd *cobra.Command) *hd.Key {
+	mnemonic, err := readSecret("Mnemonic: ")
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	var passphrase string
+	if ok, _ := cmd.Flags().GetBool("passphrase"); ok {
+		if passphrase, err = readSecret("Mnemonic passphrase: "); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+	}
+	seed, err := hd.Seed(mnemonic, passphrase)
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	master, err := hd.NewMasterKey(seed)
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	return master
+}
+
+// importWalletd imports the private keys into walletd, keys that are in the
+// wallet already are left as they are
+func importWalletd(keys []factom.FsAddress) error {
+	type secret struct {
+		Secret string `json:"secret"`
+	}
+	params := struct {
+		Addresses []secret `json:"addresses"`
+	}{}
+	for _, fs := range keys {
+		params.Addresses = append(params.Addresses, secret{Secret: fs.String()})
+	}
+	cl := node.FactomClientFromConfig(viper.GetViper())
+	return cl.WalletdRequest(nil, "import-addresses", params, nil)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/keystore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func init() {
+	keystoreCmd.AddCommand(keystoreNew)
+	keystoreCmd.AddCommand(keystoreAddress)
+	rootCmd.AddCommand(keystoreCmd)
+}
+
+var keystoreCmd = &cobra.Command{
+	Use:   "keystore <subcommand>",
+	Short: "Encrypt the EC key of the node with a passphrase",
+	Long: "The EC key that pays for the schedules can be kept in a keystore file that is encrypted with a passphrase, " +
+		"instead of app.ECPrivateKey. Set app.eckeystore to the file. On start, the keystore is unlocked with " +
+		"PEGNETD_APP_ECKEYSTOREPASSPHRASE, or the passphrase is prompted for on a terminal. Otherwise it stays " +
+		"locked until the unlock-keystore rpc, and the lock-keystore rpc locks it again.",
+}
+
+var keystoreNew = &cobra.Command{
+	Use:   "new <file>",
+	Short: "Write a keystore file with an encrypted EC key",
+	Long: "Encrypt the app.ECPrivateKey of the config, or the key that is prompted for if there is none, " +
+		"with a passphrase and write it to the file. Remove app.ECPrivateKey from the config once the " +
+		"keystore is set.",
+	Example:          "pegnetd keystore new $HOME/.pegnetd/ec.json",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(args[0]); err == nil {
+			cmd.PrintErrf("%s already exists\n", args[0])
+			os.Exit(1)
+		}
+
+		key := viper.GetString(config.ECPrivateKey)
+		if key == "" {
+			var err error
+			if key, err = readSecret("Es key: "); err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+		}
+		var es factom.EsAddress
+		if err := es.Set(key); err != nil {
+			cmd.PrintErrf("invalid ec private key: %v\n", err)
+			os.Exit(1)
+		}
+
+		passphrase, err := readSecret("Passphrase: ")
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		repeat, err := readSecret("Repeat passphrase: ")
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if passphrase != repeat {
+			cmd.PrintErrln("the passphrases do not match")
+			os.Exit(1)
+		}
+
+		f, err := keystore.Encrypt(es, passphrase)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if err := f.WriteFile(args[0]); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote the keystore of %s to %s\n", f.Address, args[0])
+	},
+}
+
+var keystoreAddress = &cobra.Command{
+	Use:              "address <file>",
+	Short:            "Print the EC address of a keystore file",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := keystore.ReadFile(args[0])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Println(f.Address)
+	},
+}
+
+// unlockKeystore prompts for the passphrase of a locked keystore if pegnetd
+// runs on a terminal
+func unlockKeystore(ks *keystore.Keystore) {
+	if ks == nil || !ks.Locked() || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		passphrase, err := readSecret(fmt.Sprintf("Passphrase of the ec keystore of %s: ", ks.Address()))
+		if err != nil {
+			return
+		}
+		if err := ks.Unlock(passphrase); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		return
+	}
+}
@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	multisigTx.Flags().String("metadata", "", "Attach metadata to the transaction, either json or a plain text memo")
+	multisigTx.Flags().Uint32("notbefore", 0, "Only execute the transaction at or after this height, it stays pending until then")
+	multisigConv.Flags().String("metadata", "", "Attach metadata to the conversion, either json or a plain text memo")
+	multisigConv.Flags().String("minoutput", "", "Reject the conversion if it yields less than this amount of the destination asset")
+	multisigConv.Flags().Float64("slippage", 0, "Reject the conversion if it yields this percentage less than at the latest rates")
+	multisigConv.Flags().Uint32("notbefore", 0, "Only execute the conversion at or after this height, it stays pending until then")
+	multisig.AddCommand(multisigPubkey)
+	multisig.AddCommand(multisigAddress)
+	multisig.AddCommand(multisigTx)
+	multisig.AddCommand(multisigConv)
+	multisig.AddCommand(multisigSign)
+	multisig.AddCommand(multisigSend)
+	rootCmd.AddCommand(multisig)
+}
+
+var multisig = &cobra.Command{
+	Use:   "multisig <subcommand>",
+	Short: "Build and sign transactions of M-of-N multisignature addresses",
+	Long: "Multisignature addresses require M of its N keys to sign a transaction. " +
+		"Every key holder shares their public key ('multisig pubkey'), the address is made from all public keys ('multisig address'). " +
+		"A transaction is written to a file ('multisig newtx'), that is passed around and signed by the key holders ('multisig sign'), " +
+		"and submitted by anyone once enough keys signed ('multisig send'). The signatures have to be gathered within 12 hours.",
+}
+
+var multisigPubkey = &cobra.Command{
+	Use:              "pubkey <FA-ADDRESS>",
+	Short:            "Print the public key of an address in the wallet, to be used in a multisig address",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorFCTAddress),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		key, err := multisigKey(cl, args[0])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Println(hex.EncodeToString(key.PublicKey()))
+	},
+}
+
+var multisigAddress = &cobra.Command{
+	Use:              "address <M> <PUBKEY> [PUBKEY...]",
+	Short:            "Print the address and rcd of the public keys of which M have to sign",
+	Example:          "pegnetd multisig address 2 <pubkey1> <pubkey2> <pubkey3>",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := strconv.Atoi(args[0])
+		if err != nil {
+			cmd.PrintErrln("M must be a number")
+			os.Exit(1)
+		}
+		keys := make([]ed25519.PublicKey, len(args)-1)
+		for i, arg := range args[1:] {
+			if keys[i], err = hex.DecodeString(arg); err != nil {
+				cmd.PrintErrf("public key %d is not hex: %v\n", i, err)
+				os.Exit(1)
+			}
+		}
+		rcd, err := fat2.NewRCDMultisig(m, keys...)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		fmt.Printf("%10s: %s\n", "Address", rcd.Address())
+		fmt.Printf("%10s: %s\n", "RCD", hex.EncodeToString(rcd.RCD()))
+	},
+}
+
+var multisigTx = &cobra.Command{
+	Use:              "newtx <RCD> <ASSET> <AMOUNT> <FA-DESTINATION>",
+	Short:            "Builds an unsigned pegnet transaction of a multisig address and prints it",
+	Example:          "pegnetd multisig newtx <rcd> PEG 200 FA32xV6SoPBSbAZAVyuiHWwyoMYhnSyMmAHZfK29H8dx7bJXFLja > tx.json",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args: CustomArgOrderValidationBuilder(true,
+		ArgValidatorMultisigRCD,
+		ArgValidatorAssetOrP,
+		ArgValidatorFCTAmount,
+		ArgValidatorAddress(ADD_FA|ADD_FE|ADD_Fe)),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		rcd, _ := decodeMultisigRCD(args[0]) // Already validated
+
+		var trans fat2.Transaction
+		if err := setTransactionInput(&trans, cl, rcd.Address().String(), args[1], args[2]); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		if err := setTransferOutput(&trans, cl, args[3], args[2]); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		if err := setMetadata(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		printMultisigTransaction(cmd, rcd, trans)
+	},
+}
+
+var multisigConv = &cobra.Command{
+	Use:              "newcvt <RCD> <SRC-ASSET> <AMOUNT> <DEST-ASSET>",
+	Short:            "Builds an unsigned pegnet conversion of a multisig address and prints it",
+	Example:          "pegnetd multisig newcvt <rcd> pFCT 100 pUSD > cvt.json",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args: CustomArgOrderValidationBuilder(true,
+		ArgValidatorMultisigRCD,
+		ArgValidatorAssetOrP,
+		ArgValidatorFCTAmount,
+		ArgValidatorAssetOrP),
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		rcd, _ := decodeMultisigRCD(args[0]) // Already validated
+
+		status := getStatus()
+		if (args[3] == "pFCT" || args[3] == "FCT") && activation.Active(activation.OneWaypFCTConversions, uint32(status.Current)) {
+			cmd.PrintErrln(fmt.Sprintf("pXXX -> pFCT conversions are not allowed since block height %d. If you need to acquire pFCT, you have to burn FCT -> pFCT", activation.Height(activation.OneWaypFCTConversions)))
+			os.Exit(1)
+		}
+
+		var trans fat2.Transaction
+		if err := setTransactionInput(&trans, cl, rcd.Address().String(), args[1], args[2]); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		if trans.Conversion, err = ticker(args[3]); err != nil {
+			cmd.PrintErrln("invalid ticker type")
+			os.Exit(1)
+		}
+		if err := setMinOutput(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		if err := setMetadata(cmd, &trans); err != nil {
+			cmd.PrintErrln(err.Error())
+			os.Exit(1)
+		}
+		printMultisigTransaction(cmd, rcd, trans)
+	},
+}
+
+var multisigSign = &cobra.Command{
+	Use:              "sign <FILE> <FA-ADDRESS>",
+	Short:            "Adds the signature of an address in the wallet to a multisig transaction file",
+	Example:          "pegnetd multisig sign tx.json FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorFile, ArgValidatorFCTAddress),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		msig, err := readMultisigTransaction(args[0])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		key, err := multisigKey(cl, args[1])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if err := msig.Sign(key); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		data, err := json.MarshalIndent(msig, "", "  ")
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		if err := ioutil.WriteFile(args[0], data, 0644); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		rcd, _ := msig.Multisig()
+		fmt.Printf("signed, %d of %d required signatures\n", len(msig.Signatures), rcd.M)
+	},
+}
+
+var multisigSend = &cobra.Command{
+	Use:              "send <ECAddress> <FILE>",
+	Short:            "Submits a multisig transaction file once enough keys signed",
+	Example:          "pegnetd multisig send EC3eX8VxGH64Xv3NFd9g4Y7PxSMnH3EGz5jQQrrQS8VZGnv4JY2K tx.json",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorECAddress, ArgValidatorFile),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		msig, err := readMultisigTransaction(args[1])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		entry, err := msig.Entry()
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		txBatch := fat2.TransactionBatch{Entry: entry}
+		if err := txBatch.UnmarshalJSON(entry.Content); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		// Multisig rcds are only accepted after the activation
+		if err := txBatch.Validate(int32(getStatus().Current) + 1); err != nil {
+			cmd.PrintErrf("invalid tx: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		ec, _ := factom.NewECAddress(args[0]) // Already validated
+		bal, err := ec.GetBalance(nil, cl)
+		if err != nil {
+			cmd.PrintErrf("failed to get ec balance: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if cost, err := entry.Cost(); err != nil || uint64(cost) > bal {
+			cmd.PrintErrln("not enough ec balance for the transaction")
+			os.Exit(1)
+		}
+		es, err := ec.GetEsAddress(nil, cl)
+		if err != nil {
+			cmd.PrintErrf("failed to get the ec private key: %s\n", err.Error())
+			os.Exit(1)
+		}
+		commit, err := entry.ComposeCreate(nil, cl, es)
+		if err != nil {
+			cmd.PrintErrf("failed to submit entry: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("transaction sent:\n")
+		fmt.Printf("\t%10s: %s\n", "EntryHash", entry.Hash)
+		fmt.Printf("\t%10s: %s\n", "Commit", commit)
+	},
+}
+
+// ArgValidatorMultisigRCD checks for a hex encoded multisig rcd
+func ArgValidatorMultisigRCD(cmd *cobra.Command, arg string) error {
+	_, err := decodeMultisigRCD(arg)
+	return err
+}
+
+// ArgValidatorFile checks for an existing file
+func ArgValidatorFile(cmd *cobra.Command, arg string) error {
+	if _, err := os.Stat(arg); err != nil {
+		return err
+	}
+	return nil
+}
+
+func decodeMultisigRCD(arg string) (*fat2.RCDMultisig, error) {
+	data, err := hex.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("the rcd must be hex: %v", err)
+	}
+	rcd := new(fat2.RCDMultisig)
+	if err := rcd.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("invalid multisig rcd: %v", err)
+	}
+	return rcd, nil
+}
+
+// multisigKey returns the private key of an address in the wallet
+func multisigKey(cl *factom.Client, addr string) (factom.FsAddress, error) {
+	fa, err := factom.NewFAAddress(addr)
+	if err != nil {
+		return factom.FsAddress{}, err
+	}
+	key, err := fa.GetFsAddress(nil, cl)
+	if err != nil {
+		return factom.FsAddress{}, fmt.Errorf("unable to get private key: %s", err.Error())
+	}
+	return key, nil
+}
+
+func printMultisigTransaction(cmd *cobra.Command, rcd *fat2.RCDMultisig, tx fat2.Transaction) {
+	var txBatch fat2.TransactionBatch
+	txBatch.Version = 1
+	txBatch.Transactions = []fat2.Transaction{tx}
+	txBatch.NotBefore = notBefore(cmd)
+	msig, err := fat2.NewMultisigTransaction(&txBatch, &node.TransactionChain, rcd)
+	if err != nil {
+		cmd.PrintErrf("invalid tx: %s\n", err.Error())
+		os.Exit(1)
+	}
+	data, err := json.MarshalIndent(msig, "", "  ")
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+func readMultisigTransaction(path string) (*fat2.MultisigTransaction, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	msig := new(fat2.MultisigTransaction)
+	if err := json.Unmarshal(data, msig); err != nil {
+		return nil, fmt.Errorf("invalid multisig transaction file: %v", err)
+	}
+	if _, err := msig.Multisig(); err != nil {
+		return nil, err
+	}
+	return msig, nil
+}
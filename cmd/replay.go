@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/replay"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	exportEntries.Flags().String("out", "", "The file to write the dump to, compressed if it ends in '.gz'")
+	exportEntries.Flags().Uint32("stop", 0, "The last height to export, 0 for the latest height of factomd")
+	exportEntries.Flags().Int("workers", 8, "The amount of heights to fetch at once")
+	export.AddCommand(exportEntries)
+
+	replayDump.Flags().String("out", "", "The dbpath of the replayed database, a temporary database if empty")
+	replayDump.Flags().String("hashes", "", "The file to write the state hashes to, stdout if empty")
+	replayDump.Flags().String("compare", "", "A file of state hashes to compare the replayed heights to")
+	rootCmd.AddCommand(replayDump)
+
+	stateHashes.Flags().Uint32("stop", 0, "The last height, 0 for the latest synced height")
+	stateHashes.Flags().String("hashes", "", "The file to write the state hashes to, stdout if empty")
+	rootCmd.AddCommand(stateHashes)
+}
+
+var exportEntries = &cobra.Command{
+	Use:   "entries --out <file>",
+	Short: "Export the blocks and entries the node syncs from factomd as a dump for replays",
+	Long: "Export the directory and factoid blocks, and the OPR and transaction entries of every height " +
+		"from the first height of the pegnet, as they are fetched from factomd. The dump can be replayed " +
+		"offline with 'pegnetd replay' by a node of the same network.",
+	Example:          "pegnetd export entries --out mainnet.dump.gz",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		out, _ := cmd.Flags().GetString("out")
+		stop, _ := cmd.Flags().GetUint32("stop")
+		workers, _ := cmd.Flags().GetInt("workers")
+		if out == "" {
+			cmd.PrintErrln("--out must be specified")
+			os.Exit(1)
+		}
+		if workers < 1 {
+			cmd.PrintErrln("--workers must be greater than 0")
+			os.Exit(1)
+		}
+
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		start := activation.Height(activation.Pegnet) + 1
+		if stop == 0 {
+			heights := new(factom.Heights)
+			if err := heights.Get(ctx, cl); err != nil {
+				log.WithError(err).Fatal("failed to fetch the heights of factomd")
+			}
+			stop = heights.DirectoryBlock
+		}
+		if stop < start {
+			cmd.PrintErrf("--stop must be >= %d, the first height of the pegnet\n", start)
+			os.Exit(1)
+		}
+
+		header := replay.Header{
+			Network: node.ActiveNetwork.Name,
+			Assets:  viper.GetStringSlice(config.Assets),
+			Start:   start,
+			Stop:    stop,
+		}
+		w, err := replay.Create(out, header)
+		if err != nil {
+			log.WithError(err).Fatal("failed to create the dump")
+		}
+
+		lastReport := time.Now()
+		for height := start; height <= stop; height += uint32(workers) {
+			// Heights are fetched at once and written in order
+			blocks := make([]*replay.Block, workers)
+			errs := make([]error, workers)
+			var wg sync.WaitGroup
+			for i := 0; i < workers && height+uint32(i) <= stop; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					blocks[i], errs[i] = replay.Fetch(ctx, cl, height+uint32(i), node.OPRChain, node.TransactionChain)
+				}(i)
+			}
+			wg.Wait()
+
+			for i, b := range blocks {
+				if errs[i] != nil {
+					log.WithError(errs[i]).WithField("height", height+uint32(i)).Fatal("failed to fetch the block")
+				}
+				if b == nil {
+					break
+				}
+				if err := w.Write(b); err != nil {
+					log.WithError(err).Fatal("failed to write the dump")
+				}
+			}
+			if time.Since(lastReport) > 15*time.Second {
+				lastReport = time.Now()
+				log.WithFields(log.Fields{"height": height, "stop": stop}).Info("export stats")
+			}
+		}
+		if err := w.Close(); err != nil {
+			log.WithError(err).Fatal("failed to write the dump")
+		}
+		fmt.Printf("exported heights %d to %d to %s\n", start, stop, out)
+	},
+}
+
+var replayDump = &cobra.Command{
+	Use:   "replay <dump> [--out <dbpath>] [--compare <hashes>]",
+	Short: "Replay a dump of blocks offline and print the state hash of every height",
+	Long: "Sync the blocks of a dump from 'pegnetd export entries' into a new database, through the same " +
+		"grading and transaction application as the sync from factomd, and print the state hash of every " +
+		"height as '<height> <hash>'. The hash of a height commits to its rates, OPR winners, balance changes, " +
+		"and executed and rejected transactions, and to the hash of the height before.\n\n" +
+		"To test a change of the grading or the conversions, write the hashes of a build and compare the " +
+		"replay of the new build to them. The first height with a different hash is where the builds diverge. " +
+		"'pegnetd state-hashes' prints the hashes of a synced database to compare to.",
+	Example:          "pegnetd replay mainnet.dump.gz --hashes before.txt\npegnetd replay mainnet.dump.gz --compare before.txt",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		dbpath, _ := cmd.Flags().GetString("out")
+		hashesPath, _ := cmd.Flags().GetString("hashes")
+		comparePath, _ := cmd.Flags().GetString("compare")
+
+		var expected map[uint32]factom.Bytes32
+		if comparePath != "" {
+			var err error
+			if expected, err = readStateHashes(comparePath); err != nil {
+				log.WithError(err).Fatal("failed to read the hashes to compare to")
+			}
+		}
+
+		dump, err := replay.Open(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("failed to open the dump")
+		}
+		defer dump.Close()
+
+		if dbpath == "" {
+			dir, err := ioutil.TempDir("", "pegnetd-replay")
+			if err != nil {
+				log.WithError(err).Fatal("failed to create the database")
+			}
+			defer os.RemoveAll(dir)
+			dbpath = filepath.Join(dir, "replay.db")
+		}
+
+		out, closeOut := hashesOutput(hashesPath)
+		defer closeOut()
+
+		var diverged []uint32
+		lastReport := time.Now()
+		height, err := replay.Replay(ctx, dump, dbpath, func(height uint32, hash factom.Bytes32) error {
+			if _, err := fmt.Fprintf(out, "%d %s\n", height, hash); err != nil {
+				return err
+			}
+			if expect, ok := expected[height]; ok && expect != hash {
+				diverged = append(diverged, height)
+			}
+			if time.Since(lastReport) > 15*time.Second {
+				lastReport = time.Now()
+				log.WithFields(log.Fields{"height": height, "stop": dump.Header.Stop}).Info("replay stats")
+			}
+			return nil
+		})
+		closeOut()
+		if err != nil {
+			log.WithError(err).WithField("height", height).Fatal("failed to replay the dump")
+		}
+
+		if comparePath != "" {
+			if len(diverged) > 0 {
+				cmd.PrintErrf("%d heights differ from %s, the first is %d\n", len(diverged), comparePath, diverged[0])
+				os.Exit(1)
+			}
+			cmd.PrintErrf("all hashes of %s match up to height %d\n", comparePath, height)
+		}
+	},
+}
+
+var stateHashes = &cobra.Command{
+	Use:              "state-hashes [--stop <height>]",
+	Short:            "Print the state hash of every synced height of the local database",
+	Long:             "Print the state hash of every height of the local database as '<height> <hash>', to compare a synced node to a replay. See 'pegnetd replay'.",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		stop, _ := cmd.Flags().GetUint32("stop")
+		hashesPath, _ := cmd.Flags().GetString("hashes")
+
+		p := pegnet.New(viper.GetViper())
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		defer p.Close()
+
+		synced, err := p.SelectSynced(ctx, p.DB)
+		if err != nil {
+			log.WithError(err).Fatal("failed to find the synced height")
+		}
+		if stop == 0 || stop > synced.Synced {
+			stop = synced.Synced
+		}
+
+		out, closeOut := hashesOutput(hashesPath)
+		defer closeOut()
+		err = replay.StateHashes(ctx, p, stop, func(height uint32, hash factom.Bytes32) error {
+			_, err := fmt.Fprintf(out, "%d %s\n", height, hash)
+			return err
+		})
+		if err != nil {
+			log.WithError(err).Fatal("failed to compute the state hashes")
+		}
+	},
+}
+
+// hashesOutput returns the file of the path, or stdout if the path is empty.
+// The close func can be called more than once.
+func hashesOutput(path string) (io.Writer, func()) {
+	if path == "" {
+		return os.Stdout, func() {}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create the hashes file")
+	}
+	buf := bufio.NewWriter(f)
+	var once sync.Once
+	return buf, func() {
+		once.Do(func() {
+			if err := buf.Flush(); err != nil {
+				log.WithError(err).Error("failed to write the hashes file")
+			}
+			_ = f.Close()
+		})
+	}
+}
+
+// readStateHashes reads the '<height> <hash>' lines of a hashes file
+func readStateHashes(path string) (map[uint32]factom.Bytes32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make(map[uint32]factom.Bytes32)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected '<height> <hash>'", line)
+		}
+		height, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		var hash factom.Bytes32
+		if err := hash.Set(fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		hashes[uint32(height)] = hash
+	}
+	return hashes, scanner.Err()
+}
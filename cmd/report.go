@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	reportTax.Flags().Int("year", time.Now().UTC().Year()-1, "The tax year, disposals are grouped by their UTC timestamp")
+	reportTax.Flags().String("method", taxMethodFIFO, "The order lots are sold in, either 'fifo', 'lifo', or 'hifo'")
+	report.AddCommand(reportTax)
+	rootCmd.AddCommand(report)
+}
+
+var report = &cobra.Command{
+	Use:   "report <subcommand>",
+	Short: "Generate reports from the local pegnetd database",
+}
+
+var reportTax = &cobra.Command{
+	Use:   "tax <address> [--year 2020] [--method fifo]",
+	Short: "Generate a capital gains csv for an address",
+	Long: "Walk the transfers, conversions, coinbases, and burns of an address and value each of them in USD " +
+		"at the rates of the height they were applied at. Received assets open a lot with their USD value as cost basis, " +
+		"sent and converted assets close lots in the order of the method. " +
+		"One row is written per closed (part of a) lot that was disposed of in the tax year.",
+	Example:          "pegnetd report tax FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q --year 2020 --method fifo > gains.csv",
+	PersistentPreRun: always,
+	PreRun:           ReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorFCTAddress),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		year, _ := cmd.Flags().GetInt("year")
+		method, _ := cmd.Flags().GetString("method")
+		if method != taxMethodFIFO && method != taxMethodLIFO && method != taxMethodHIFO {
+			cmd.PrintErrln("method must be either 'fifo', 'lifo', or 'hifo'")
+			os.Exit(1)
+		}
+
+		addr, err := factom.NewFAAddress(args[0])
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		p := pegnet.New(viper.GetViper())
+		if err := p.Init(); err != nil {
+			log.WithError(err).Fatal("failed to open the database")
+		}
+		defer p.Close()
+
+		history, err := p.SelectTransactionHistoryActionsByAddressExecuted(ctx, &addr)
+		if err != nil {
+			log.WithError(err).Fatal("failed to read the history")
+		}
+
+		book := newTaxBook(method)
+		rates := make(map[int32]map[fat2.PTicker]uint64)
+		for _, h := range history {
+			r, ok := rates[h.Executed]
+			if !ok {
+				// The most recent rates at or before the height, in case
+				// the height itself has no rates
+				r, _, err = p.SelectMostRecentRatesBeforeHeight(ctx, p.DB, uint32(h.Executed)+1)
+				if err != nil {
+					log.WithError(err).Fatalf("failed to read the rates of height %d", h.Executed)
+				}
+				rates[h.Executed] = r
+			}
+			book.apply(addr, h, r)
+		}
+
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"asset", "amount", "date_acquired", "date_sold", "height_acquired", "height_sold",
+			"proceeds_usd", "cost_basis_usd", "gain_usd", "term", "txid"})
+		for _, g := range book.gains {
+			if g.Sold.UTC().Year() != year {
+				continue
+			}
+			acquired, acquiredHeight := "", ""
+			if g.Lot.Height != 0 {
+				acquired = g.Lot.Acquired.UTC().Format(time.RFC3339)
+				acquiredHeight = strconv.FormatInt(g.Lot.Height, 10)
+			}
+			_ = w.Write([]string{
+				g.Lot.Asset,
+				FactoshiToFactoid(g.Amount),
+				acquired,
+				g.Sold.UTC().Format(time.RFC3339),
+				acquiredHeight,
+				strconv.FormatInt(g.Height, 10),
+				formatUSD(g.Proceeds),
+				formatUSD(g.Cost),
+				formatUSD(new(big.Int).Sub(g.Proceeds, g.Cost)),
+				g.Term(),
+				g.TxID,
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.WithError(err).Fatal("failed to write the report")
+		}
+
+		if book.unknown > 0 {
+			log.Warnf("%d disposals had no matching lots, they were reported with a cost basis of 0", book.unknown)
+		}
+	},
+}
+
+// The supported orders of selling lots
+const (
+	taxMethodFIFO = "fifo" // first in, first out
+	taxMethodLIFO = "lifo" // last in, first out
+	taxMethodHIFO = "hifo" // highest cost per unit first
+)
+
+// taxLot is an amount of an asset acquired at once. Cost is in USD
+// with 8 decimals.
+type taxLot struct {
+	Asset    string
+	Amount   int64
+	Cost     *big.Int
+	Acquired time.Time
+	Height   int64 // 0 if the lot is unknown
+}
+
+// taxGain is the disposal of (part of) a lot
+type taxGain struct {
+	Lot      taxLot
+	Amount   int64
+	Proceeds *big.Int
+	Cost     *big.Int
+	Sold     time.Time
+	Height   int64
+	TxID     string
+}
+
+// Term is "long" if the lot was held for more than a year
+func (g taxGain) Term() string {
+	if g.Lot.Height != 0 && g.Sold.After(g.Lot.Acquired.AddDate(1, 0, 0)) {
+		return "long"
+	}
+	return "short"
+}
+
+// taxBook keeps the open lots of an address
+type taxBook struct {
+	method  string
+	lots    map[string][]*taxLot
+	gains   []taxGain
+	unknown int
+}
+
+func newTaxBook(method string) *taxBook {
+	return &taxBook{method: method, lots: make(map[string][]*taxLot)}
+}
+
+// apply books an action of the history. Only the side of the address is
+// booked, transfers between outputs of the same address are ignored.
+func (b *taxBook) apply(addr factom.FAAddress, h pegnet.HistoryTransaction, rates map[fat2.PTicker]uint64) {
+	switch h.TxAction {
+	case pegnet.Transfer:
+		for _, out := range h.Outputs {
+			switch {
+			case *h.FromAddress == out.Address:
+			case *h.FromAddress == addr:
+				b.dispose(h, h.FromAsset, out.Amount, usdValue(out.Amount, rates[fat2.StringToTicker(h.FromAsset)]))
+			case out.Address == addr:
+				b.acquire(h, h.FromAsset, out.Amount, usdValue(out.Amount, rates[fat2.StringToTicker(h.FromAsset)]))
+			}
+		}
+	case pegnet.Conversion:
+		// Refunds of PEG conversion requests are not converted
+		amount := h.FromAmount
+		for _, out := range h.Outputs {
+			amount -= out.Amount
+		}
+		fromRate := h.FromRate
+		if fromRate == 0 {
+			fromRate = rates[fat2.StringToTicker(h.FromAsset)]
+		}
+		if amount <= 0 {
+			return
+		}
+		// The cost basis of the received asset is the value given up
+		value := usdValue(amount, fromRate)
+		b.dispose(h, h.FromAsset, amount, value)
+		if h.ToAmount > 0 {
+			b.acquire(h, h.ToAsset, h.ToAmount, value)
+		}
+	case pegnet.Coinbase, pegnet.FCTBurn:
+		b.acquire(h, h.ToAsset, h.ToAmount, usdValue(h.ToAmount, rates[fat2.StringToTicker(h.ToAsset)]))
+	}
+}
+
+func (b *taxBook) acquire(h pegnet.HistoryTransaction, asset string, amount int64, cost *big.Int) {
+	if amount <= 0 {
+		return
+	}
+	b.lots[asset] = append(b.lots[asset], &taxLot{
+		Asset:    asset,
+		Amount:   amount,
+		Cost:     cost,
+		Acquired: h.Timestamp,
+		Height:   int64(h.Executed),
+	})
+}
+
+// dispose closes lots in the order of the method. The proceeds are split
+// over the lots proportionally to the amount taken from each.
+func (b *taxBook) dispose(h pegnet.HistoryTransaction, asset string, amount int64, proceeds *big.Int) {
+	if amount <= 0 {
+		return
+	}
+	lots := b.lots[asset]
+	switch b.method {
+	case taxMethodLIFO:
+		sort.SliceStable(lots, func(i, j int) bool { return lots[i].Height > lots[j].Height })
+	case taxMethodHIFO:
+		// a.Cost / a.Amount > b.Cost / b.Amount
+		sort.SliceStable(lots, func(i, j int) bool {
+			x := new(big.Int).Mul(lots[i].Cost, big.NewInt(lots[j].Amount))
+			y := new(big.Int).Mul(lots[j].Cost, big.NewInt(lots[i].Amount))
+			return x.Cmp(y) > 0
+		})
+	default:
+		sort.SliceStable(lots, func(i, j int) bool { return lots[i].Height < lots[j].Height })
+	}
+
+	remaining := amount
+	for len(lots) > 0 && remaining > 0 {
+		lot := lots[0]
+		take := lot.Amount
+		if take > remaining {
+			take = remaining
+		}
+		cost := share(lot.Cost, take, lot.Amount)
+		b.gains = append(b.gains, taxGain{
+			Lot:      *lot,
+			Amount:   take,
+			Proceeds: share(proceeds, take, amount),
+			Cost:     cost,
+			Sold:     h.Timestamp,
+			Height:   int64(h.Executed),
+			TxID:     h.TxID,
+		})
+
+		lot.Amount -= take
+		lot.Cost = new(big.Int).Sub(lot.Cost, cost)
+		remaining -= take
+		if lot.Amount == 0 {
+			lots = lots[1:]
+		}
+	}
+	b.lots[asset] = lots
+
+	if remaining > 0 {
+		b.unknown++
+		b.gains = append(b.gains, taxGain{
+			Lot:      taxLot{Asset: asset, Cost: new(big.Int)},
+			Amount:   remaining,
+			Proceeds: share(proceeds, remaining, amount),
+			Cost:     new(big.Int),
+			Sold:     h.Timestamp,
+			Height:   int64(h.Executed),
+			TxID:     h.TxID,
+		})
+	}
+}
+
+// usdValue is the amount (8 decimals) times the rate (8 decimals) in USD
+// with 8 decimals
+func usdValue(amount int64, rate uint64) *big.Int {
+	v := new(big.Int).Mul(big.NewInt(amount), new(big.Int).SetUint64(rate))
+	return v.Quo(v, big.NewInt(1e8))
+}
+
+// share is value * part / total
+func share(value *big.Int, part, total int64) *big.Int {
+	v := new(big.Int).Mul(value, big.NewInt(part))
+	return v.Quo(v, big.NewInt(total))
+}
+
+// formatUSD formats an USD value with 8 decimals, rounded to cents
+func formatUSD(v *big.Int) string {
+	cents := new(big.Int).Set(v)
+	neg := cents.Sign() < 0
+	cents.Abs(cents)
+	cents.Add(cents, big.NewInt(5e5))
+	cents.Quo(cents, big.NewInt(1e6))
+	r := new(big.Int)
+	cents.QuoRem(cents, big.NewInt(100), r)
+	if neg && (cents.Sign() != 0 || r.Sign() != 0) {
+		return fmt.Sprintf("-%s.%02d", cents, r.Int64())
+	}
+	return fmt.Sprintf("%s.%02d", cents, r.Int64())
+}
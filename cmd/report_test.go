@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+func TestTaxBook(t *testing.T) {
+	var self, other factom.FAAddress
+	self[0], other[0] = 1, 2
+
+	day := func(d int) time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+	rates := func(peg uint64) map[fat2.PTicker]uint64 {
+		return map[fat2.PTicker]uint64{fat2.PTickerPEG: peg, fat2.PTickerUSD: 1e8}
+	}
+	history := []struct {
+		tx    pegnet.HistoryTransaction
+		rates map[fat2.PTicker]uint64
+	}{
+		// 100 PEG mined at $0.01, 100 PEG received at $0.02
+		{pegnet.HistoryTransaction{TxAction: pegnet.Coinbase, Executed: 10, Timestamp: day(0), FromAddress: &self, ToAsset: "PEG", ToAmount: 100e8}, rates(1e6)},
+		{pegnet.HistoryTransaction{TxAction: pegnet.Transfer, Executed: 20, Timestamp: day(1), FromAddress: &other, FromAsset: "PEG", Outputs: []pegnet.HistoryTransactionOutput{{Address: self, Amount: 100e8}}}, rates(2e6)},
+		// 150 PEG converted to pUSD at $0.04
+		{pegnet.HistoryTransaction{TxAction: pegnet.Conversion, Executed: 30, Timestamp: day(400), FromAddress: &self, FromAsset: "PEG", FromAmount: 150e8, FromRate: 4e6, ToAsset: "pUSD", ToAmount: 6e8, TxID: "0-conversion"}, rates(4e6)},
+		// 6 pUSD sent
+		{pegnet.HistoryTransaction{TxAction: pegnet.Transfer, Executed: 40, Timestamp: day(401), FromAddress: &self, FromAsset: "pUSD", Outputs: []pegnet.HistoryTransactionOutput{{Address: other, Amount: 6e8}}}, rates(4e6)},
+	}
+
+	for _, method := range []string{taxMethodFIFO, taxMethodLIFO} {
+		book := newTaxBook(method)
+		for _, h := range history {
+			book.apply(self, h.tx, h.rates)
+		}
+		if len(book.gains) != 3 || book.unknown != 0 {
+			t.Fatalf("%s: unexpected gains %+v", method, book.gains)
+		}
+
+		first, second := book.gains[0], book.gains[1]
+		if method == taxMethodFIFO {
+			// 100 mined PEG ($1 -> $4) and 50 received PEG ($1 -> $2)
+			expGain(t, method, first, 100e8, "4.00", "1.00", "long")
+			expGain(t, method, second, 50e8, "2.00", "1.00", "long")
+		} else {
+			expGain(t, method, first, 100e8, "4.00", "2.00", "long")
+			expGain(t, method, second, 50e8, "2.00", "0.50", "long")
+		}
+		// The pUSD kept the value of the conversion as basis
+		expGain(t, method, book.gains[2], 6e8, "6.00", "6.00", "short")
+		if len(book.lots["PEG"]) != 1 || book.lots["PEG"][0].Amount != 50e8 {
+			t.Errorf("%s: unexpected lots left %+v", method, book.lots["PEG"])
+		}
+	}
+}
+
+func expGain(t *testing.T, method string, g taxGain, amount int64, proceeds, cost, term string) {
+	t.Helper()
+	if g.Amount != amount || formatUSD(g.Proceeds) != proceeds || formatUSD(g.Cost) != cost || g.Term() != term {
+		t.Errorf("%s: exp %d %s %s %s, got %d %s %s %s", method, amount, proceeds, cost, term,
+			g.Amount, formatUSD(g.Proceeds), formatUSD(g.Cost), g.Term())
+	}
+}
+
+func TestFormatUSD(t *testing.T) {
+	for v, exp := range map[int64]string{0: "0.00", 1e8: "1.00", 123456789: "1.23", 99999999: "1.00", -150e6: "-1.50", -1: "0.00"} {
+		if got := formatUSD(big.NewInt(v)); got != exp {
+			t.Errorf("%d: exp %s, got %s", v, exp, got)
+		}
+	}
+}
@@ -3,20 +3,25 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/mattn/go-sqlite3"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/chaos"
 	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/exit"
-	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/metrics"
 	"github.com/pegnet/pegnetd/node"
-	"github.com/pegnet/pegnetd/node/pegnet"
 	"github.com/pegnet/pegnetd/srv"
+	"github.com/pegnet/pegnetd/tracing"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -24,16 +29,19 @@ import (
 
 func init() {
 	rootCmd.PersistentFlags().String("log", "info", "Change the logging level. Can choose from 'trace', 'debug', 'info', 'warn', 'error', or 'fatal'")
+	rootCmd.PersistentFlags().String("logformat", "text", "Change the logging format. Can choose from 'text' or 'json'")
 	rootCmd.PersistentFlags().StringP("server", "s", "http://localhost:8088/v2", "The url to the factomd endpoint without a trailing slash")
 	rootCmd.PersistentFlags().StringP("wallet", "w", "http://localhost:8089/v2", "The url to the factomd-wallet endpoint without a trailing slash")
 	rootCmd.PersistentFlags().String("walletuser", "", "The username for Wallet RPC")
 	rootCmd.PersistentFlags().String("walletpassword", "", "The password for Wallet RPC")
 	rootCmd.PersistentFlags().StringP("pegnetd", "p", "http://localhost:8070", "The url to the pegnetd endpoint without a trailing slash")
 	rootCmd.PersistentFlags().String("api", "8070", "Change the api listening port for the api")
+	rootCmd.PersistentFlags().String("network", "MainNet", "The network to sync. Can choose from 'MainNet', 'RegTest', or the path of a custom network file")
 	rootCmd.PersistentFlags().String("config", "", "Optional file location of the config file")
 
 	rootCmd.Flags().String("dbmode", "", "Turn on custom sqlite modes")
 	rootCmd.Flags().Bool("wal", false, "Turn on WAL mode for sqlite")
+	rootCmd.PersistentFlags().Bool("regtest", false, "Run against an embedded mock factomd on the RegTest network. Blocks are mined with the regtest-mine rpc")
 
 	rootCmd.PersistentFlags().BoolP("no-warn", "n", false, "Ignore all warnings/notices")
 	rootCmd.PersistentFlags().Bool("no-hf", false, "Disable the check that your node was updated before each hard fork. It will still print a warning")
@@ -67,15 +75,69 @@ var rootCmd = &cobra.Command{
 
 		// Get the config
 		conf := viper.GetViper()
+		if err := config.Validate(conf); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		log.Info("effective config:")
+		for _, line := range config.Effective(conf) {
+			log.Info("  " + line)
+		}
+
+		// Tracing has to be enabled before anything is traced
+		if endpoint := conf.GetString(config.TracingEndpoint); endpoint != "" {
+			tracer, err := tracing.NewTracer(conf.GetString(config.TracingService), endpoint, conf.GetDuration(config.TracingInterval), 4096)
+			if err != nil {
+				log.WithError(err).Errorf("invalid tracing config")
+				os.Exit(1)
+			}
+			tracing.Global = tracer
+			go tracer.Run(ctx)
+		}
+
 		node, err := node.NewPegnetd(ctx, conf)
 		if err != nil {
 			log.WithError(err).Errorf("failed to launch pegnet node")
 			os.Exit(1)
 		}
+		unlockKeystore(node.Keystore)
+		if node.Keystore != nil && node.Keystore.Locked() {
+			log.WithField("address", node.Keystore.Address()).Warn("The ec keystore is locked, unlock it with the unlock-keystore rpc")
+		}
 
 		apiserver := srv.NewAPIServer(conf, node)
 		go apiserver.Start(ctx.Done())
 
+		// Reload the config on SIGHUP
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if _, err := node.ReloadConfig(); err != nil {
+					log.WithError(err).Error("failed to reload the config")
+				}
+			}
+		}()
+
+		if u := conf.GetString(config.MetricsURL); u != "" {
+			pusher, err := metrics.NewPusher(conf.GetString(config.MetricsFormat), u, conf.GetDuration(config.MetricsInterval), conf.GetString(config.MetricsPrefix))
+			if err != nil {
+				log.WithError(err).Errorf("invalid metrics config")
+				os.Exit(1)
+			}
+			go pusher.Run(ctx, node.MetricPoints, func(context.Context) []metrics.Point {
+				return append(metrics.GlobalRPC.Collect("rpc", time.Now()),
+					metrics.GlobalSlow.Collect("slow", time.Now(), "queries", "blocks")...)
+			}, func(context.Context) []metrics.Point {
+				return append(metrics.GlobalPanics.Collect("rpc_panics", time.Now()),
+					chaos.GlobalFaults.Collect("chaos_faults", time.Now())...)
+			})
+		}
+
+		if conf.GetString(config.SchedulerToken) != "" {
+			go node.RunScheduler(ctx, conf.GetDuration(config.SchedulerPeriod))
+		}
+
 		// Run
 		node.DBlockSync(ctx)
 	},
@@ -102,6 +164,7 @@ var properties = &cobra.Command{
 		fmt.Printf(format, "Build Commit", config.CompiledInBuild)
 		fmt.Printf(format, "SQLite Version", sqliteVersion)
 		fmt.Printf(format, "Golang Version", runtime.Version())
+		fmt.Printf(format, "Network", node.ActiveNetwork.Name)
 
 		// Remote pegnetd properties. The cli and pegnetd daemon can differ
 		fmt.Println("\nRemote Pegnetd")
@@ -110,6 +173,7 @@ var properties = &cobra.Command{
 		fmt.Printf(format, "Build Commit", props.BuildCommit)
 		fmt.Printf(format, "SQLite Version", props.SQLiteVersion)
 		fmt.Printf(format, "Golang Version", props.GolangVersion)
+		fmt.Printf(format, "Network", props.Network)
 
 		// Factomd and walletd versions
 		fmt.Println()
@@ -139,25 +203,6 @@ var properties = &cobra.Command{
 
 // always is run before any command
 func always(cmd *cobra.Command, args []string) {
-	// See if we are in testing mode
-	if ok, _ := cmd.Flags().GetBool("testing"); ok {
-		log.Infof("in testing mode, activation heights are 0")
-		act, _ := cmd.Flags().GetInt("act")
-		if act <= 0 {
-			act = 0
-		}
-
-		// Set all activations for testing
-		node.SetAllActivations(uint32(act))
-	}
-
-	if testingact, _ := cmd.Flags().GetInt32("testingact"); testingact >= 0 {
-		fat2.Fat2RCDEActivation = uint32(testingact)
-		node.V4OPRUpdate = uint32(testingact)
-		// Also updaet hardfork
-		pegnet.Hardforks[1].ActivationHeight = uint32(testingact)
-	}
-
 	// Setup config reading
 	if cFilePath, _ := cmd.Flags().GetString("config"); cFilePath != "" {
 		base := filepath.Base(cFilePath)
@@ -173,20 +218,30 @@ func always(cmd *cobra.Command, args []string) {
 
 	// Setup global command line flag overrides
 	// This gets run before any command executes. It will init global flags to the config
-	_ = viper.BindPFlag(config.LoggingLevel, cmd.Flags().Lookup("log"))
-	_ = viper.BindPFlag(config.Server, cmd.Flags().Lookup("server"))
-	_ = viper.BindPFlag(config.Wallet, cmd.Flags().Lookup("wallet"))
-	_ = viper.BindPFlag(config.WalletUser, cmd.Flags().Lookup("walletuser"))
-	_ = viper.BindPFlag(config.WalletPass, cmd.Flags().Lookup("walletpassword"))
-	_ = viper.BindPFlag(config.Pegnetd, cmd.Flags().Lookup("pegnetd"))
-	_ = viper.BindPFlag(config.APIListen, cmd.Flags().Lookup("api"))
-	_ = viper.BindPFlag(config.SQLDBWalMode, cmd.Flags().Lookup("wal"))
-	_ = viper.BindPFlag(config.CustomSQLDBMode, cmd.Flags().Lookup("dbmode"))
-	_ = viper.BindPFlag(config.DisableHardForkCheck, cmd.Flags().Lookup("no-hf"))
-
-	// Also init some defaults
-	viper.SetDefault(config.DBlockSyncRetryPeriod, time.Second*5)
-	viper.SetDefault(config.SqliteDBPath, "$HOME/.pegnetd/mainnet/sql.db")
+	// The local flags of the root command do not exist on subcommands, and a
+	// nil flag would panic on every read of its key
+	for key, name := range map[string]string{
+		config.LoggingLevel:         "log",
+		config.LoggingFormat:        "logformat",
+		config.Server:               "server",
+		config.Wallet:               "wallet",
+		config.WalletUser:           "walletuser",
+		config.WalletPass:           "walletpassword",
+		config.Pegnetd:              "pegnetd",
+		config.APIListen:            "api",
+		config.Network:              "network",
+		config.Regtest:              "regtest",
+		config.SQLDBWalMode:         "wal",
+		config.CustomSQLDBMode:      "dbmode",
+		config.DisableHardForkCheck: "no-hf",
+	} {
+		if flag := cmd.Flags().Lookup(name); flag != nil {
+			_ = viper.BindPFlag(key, flag)
+		}
+	}
+
+	// Also init the defaults and the environment overrides
+	config.SetDefaults(viper.GetViper())
 
 	// Catch ctl+c
 	signalChan := make(chan os.Signal, 1)
@@ -247,9 +302,9 @@ func ReadConfig(cmd *cobra.Command, args []string) {
 	}
 
 	// Indicate which config was used
-	log.Infof("Using config from %s", viper.ConfigFileUsed())
-
 	initLogger()
+	log.WithField("config", viper.ConfigFileUsed()).Info("Using config")
+	initNetwork(cmd)
 }
 
 // SoftReadConfig will not fail. It can be used for a command that needs the config,
@@ -261,9 +316,12 @@ func SoftReadConfig(cmd *cobra.Command, args []string) {
 	}
 
 	initLogger()
+	initNetwork(cmd)
 }
 
-// TODO implement a dedicated logger
+// initLogger sets the level and the format of the logs. The json format has
+// one object per line with the fields of the entry, eg "height", "method",
+// "entryhash", and "duration", for log shippers.
 func initLogger() {
 	switch strings.ToLower(viper.GetString(config.LoggingLevel)) {
 	case "trace":
@@ -278,5 +336,80 @@ func initLogger() {
 		log.SetLevel(log.ErrorLevel)
 	case "fatal":
 		log.SetLevel(log.FatalLevel)
+	default:
+		log.Warnf("unknown log level %q, using %s", viper.GetString(config.LoggingLevel), log.GetLevel())
+	}
+
+	switch strings.ToLower(viper.GetString(config.LoggingFormat)) {
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	default:
+		log.Warnf("unknown log format %q, using text", viper.GetString(config.LoggingFormat))
+	}
+}
+
+// initNetwork applies the network of the config, and then the activation
+// heights of the testing flags. Networks other than MainNet default to their
+// own database.
+func initNetwork(cmd *cobra.Command) {
+	network, err := node.LoadNetwork(viper.GetString(config.Network))
+	if err != nil {
+		log.WithError(err).Fatal("invalid network")
+	}
+	if viper.GetBool(config.Regtest) {
+		network = node.RegTest
+		initRegtest()
+	}
+	if err := network.Apply(); err != nil {
+		log.WithError(err).Fatal("invalid network")
+	}
+	if network.Name != node.MainNet.Name {
+		if viper.GetString(config.SqliteDBPath) == config.DefaultDBPath {
+			viper.Set(config.SqliteDBPath, filepath.Join("$HOME", ".pegnetd", strings.ToLower(network.Name), "sql.db"))
+		}
+		log.WithField("network", network.Name).Info("Using network")
+	}
+
+	// See if we are in testing mode
+	if ok, _ := cmd.Flags().GetBool("testing"); ok {
+		log.Infof("in testing mode, activation heights are 0")
+		act, _ := cmd.Flags().GetInt("act")
+		if act <= 0 {
+			act = 0
+		}
+
+		// Set all activations for testing
+		node.SetAllActivations(uint32(act))
+	}
+
+	if testingact, _ := cmd.Flags().GetInt32("testingact"); testingact >= 0 {
+		// The rcd type 0x0e, the V4 assets, and the V4 hard fork
+		node.ApplyActivations(activation.Current().
+			With(activation.RCDE, uint32(testingact)).
+			With(activation.V4OPRUpdate, uint32(testingact)))
+	}
+}
+
+// initRegtest gives the regtest mode a fresh database that is removed on
+// exit, as the blocks of the mock factomd only exist in memory. The mock is
+// polled often, and the api signs with a throwaway EC key if none is set.
+func initRegtest() {
+	dir, err := ioutil.TempDir("", "pegnetd-regtest")
+	if err != nil {
+		log.WithError(err).Fatal("failed to create the regtest database")
+	}
+	viper.Set(config.SqliteDBPath, filepath.Join(dir, "sql.db"))
+	exit.GlobalExitHandler.AddExit(func() error { return os.RemoveAll(dir) })
+
+	viper.Set(config.DBlockSyncRetryPeriod, 100*time.Millisecond)
+	if !config.HasECKey(viper.GetViper()) {
+		es, err := factom.GenerateEsAddress()
+		if err != nil {
+			log.WithError(err).Fatal("failed to generate the regtest EC key")
+		}
+		viper.Set(config.ECPrivateKey, es.String())
 	}
+	log.WithField("database", dir).Info("Regtest mode, blocks are mined with the regtest-mine rpc")
 }
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/keystore"
+	"github.com/pegnet/pegnetd/node/signer"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	signerServe.Flags().String("listen", "", "The unix:///path/to/socket or http://host:port to listen on, defaults to signer.url")
+	signerServe.Flags().StringSlice("keystore", nil, "A keystore file with an EC key, see the keystore command")
+	signerServe.Flags().String("keys", "", "A file of Es and Fs private keys, one per line")
+	signerServe.Flags().Bool("pkcs11", false, "Serve the pkcs11.keys of the PKCS#11 token of the config")
+	signerCmd.AddCommand(signerServe)
+	rootCmd.AddCommand(signerCmd)
+}
+
+var signerCmd = &cobra.Command{
+	Use:   "signer <subcommand>",
+	Short: "Run a signer daemon that keeps the private keys off the node",
+	Long: "The node delegates its signatures to the signer configured with signer.url and signer.token: the entry " +
+		"commits of the EC keys in signer.ecaddresses, and the transfers of the scheduler. The signer only signs the " +
+		"canonical payloads of a commit or a FAT-103 transaction, and the node checks every signature it gets back.",
+}
+
+var signerServe = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the signing api with the keys of keystores and a key file",
+	Long: "Serve the signing api on a unix socket, which is only accessible by the user, or a local http port. " +
+		"The requests must carry signer.token. The passphrases of the keystores are prompted for, the keys of a " +
+		"PKCS#11 token are used with the pkcs11 pin of the config.",
+	Example:          "pegnetd signer serve --listen unix://$HOME/.pegnetd/signer.sock --keystore $HOME/.pegnetd/ec.json --keys fs.keys",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token := viper.GetString(config.SignerToken)
+		if token == "" {
+			cmd.PrintErrf("%s is required\n", config.SignerToken)
+			os.Exit(1)
+		}
+		s := signer.NewServer(token)
+
+		keystores, _ := cmd.Flags().GetStringSlice("keystore")
+		for _, path := range keystores {
+			es, err := openSignerKeystore(path)
+			if err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+			s.AddECKey(es)
+		}
+		if path, _ := cmd.Flags().GetString("keys"); path != "" {
+			if err := readSignerKeys(s, path); err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+		}
+		if hsm, _ := cmd.Flags().GetBool("pkcs11"); hsm {
+			h, err := node.OpenHSM(viper.GetViper())
+			if err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+			if h == nil {
+				cmd.PrintErrf("%s is required with --pkcs11\n", config.PKCS11Module)
+				os.Exit(1)
+			}
+			defer h.Token.Close()
+			for _, r := range h.Refs {
+				k, _ := h.Key(r.Address)
+				kind := signer.KindFAT103
+				if r.IsEC() {
+					kind = signer.KindECCommit
+				}
+				s.AddKey(kind, k.PublicKey(), k.Sign)
+			}
+		}
+		if len(s.Keys()) == 0 {
+			cmd.PrintErrln("the signer has no keys, use --keystore, --keys, or --pkcs11")
+			os.Exit(1)
+		}
+
+		listen, _ := cmd.Flags().GetString("listen")
+		if listen == "" {
+			listen = viper.GetString(config.SignerURL)
+		}
+		l, cleanup, err := signerListener(listen)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+		defer cleanup()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		srv := &http.Server{Handler: s.Handler()}
+		go func() {
+			<-interrupt
+			_ = srv.Close()
+		}()
+
+		for _, k := range s.Keys() {
+			log.WithField("address", k.Address).Info("signer: serving key")
+		}
+		log.WithField("listen", listen).Info("signer: listening")
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			cmd.PrintErrln(err)
+			cleanup()
+			os.Exit(1)
+		}
+	},
+}
+
+// openSignerKeystore prompts for the passphrase of the keystore and returns
+// its key
+func openSignerKeystore(path string) (factom.EsAddress, error) {
+	ks, err := keystore.Open(path)
+	if err != nil {
+		return factom.EsAddress{}, err
+	}
+	passphrase, err := readSecret(fmt.Sprintf("Passphrase of the ec keystore of %s: ", ks.Address()))
+	if err != nil {
+		return factom.EsAddress{}, err
+	}
+	if err := ks.Unlock(passphrase); err != nil {
+		return factom.EsAddress{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return ks.Key()
+}
+
+// readSignerKeys adds the Es and Fs keys of the file, one per line. Empty
+// lines and lines starting with '#' are skipped.
+func readSignerKeys(s *signer.Server, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		log.WithField("file", path).Warn("signer: the key file is readable by other users, chmod 600 it")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The key is not part of the errors, it is a secret
+		switch {
+		case strings.HasPrefix(line, "Es"):
+			var es factom.EsAddress
+			if err := es.Set(line); err != nil {
+				return fmt.Errorf("%s:%d: invalid Es key", path, n)
+			}
+			s.AddECKey(es)
+		case strings.HasPrefix(line, "Fs"):
+			var fs factom.FsAddress
+			if err := fs.Set(line); err != nil {
+				return fmt.Errorf("%s:%d: invalid Fs key", path, n)
+			}
+			s.AddFAKey(fs)
+		default:
+			return fmt.Errorf("%s:%d: expected an Es or Fs key", path, n)
+		}
+	}
+	return scanner.Err()
+}
+
+// signerListener listens on a unix socket that only the user can access, or
+// on the host and port of an http url. TLS is left to a proxy, the signer is
+// meant to be local.
+func signerListener(listen string) (net.Listener, func(), error) {
+	u, err := url.Parse(listen)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "unix":
+		if info, err := os.Lstat(u.Path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			// A socket left behind by a signer that did not shut down
+			if err := os.Remove(u.Path); err != nil {
+				return nil, nil, err
+			}
+		}
+		l, err := net.Listen("unix", u.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := os.Chmod(u.Path, 0600); err != nil {
+			l.Close()
+			return nil, nil, err
+		}
+		return l, func() { os.Remove(u.Path) }, nil
+	case "http":
+		l, err := net.Listen("tcp", u.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		return l, func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("listen on unix:///path/to/socket or http://host:port, not %q", listen)
+	}
+}
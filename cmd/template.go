@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	txTemplateCmd.Flags().String("template", "", "The template of the batch, see the help for the format")
+	txTemplateCmd.Flags().String("csv", "", "Build one batch per row, the header names the placeholders")
+	txTemplateCmd.Flags().StringArray("set", nil, "Fill a placeholder with a value as name=value, can be repeated")
+	txTemplateCmd.Flags().Bool("dry-run", false, "Print the batches instead of submitting them")
+	txTemplateCmd.Flags().Uint32("notbefore", 0, "Only execute the batches at or after this height, they stay pending until then")
+	rootCmd.AddCommand(txTemplateCmd)
+}
+
+var txTemplateCmd = &cobra.Command{
+	Use:   "tx <ECAddress> --template <file> [--csv <file>] [--set name=value]",
+	Short: "Builds and submits pegnet batches from a template",
+	Long: "Builds one batch from a template and the --set values, or one batch per row of a csv. " +
+		"Placeholders are written as {{name}} anywhere in the template and filled from the --set values " +
+		"and the columns of the csv, the csv takes precedence. Amounts are in whole units, the input amount of a " +
+		"transfer is the sum of its outputs. A template looks like:\n\n" +
+		`{
+  "input": "{{source}}",
+  "transactions": [
+    {"asset": "pUSD", "transfers": [
+      {"address": "{{employee}}", "amount": "{{salary}}"}
+    ]},
+    {"asset": "PEG", "amount": "{{convert}}", "conversion": "pUSD"}
+  ],
+  "metadata": {"memo": "payroll {{month}}"}
+}`,
+	Example:          "pegnetd tx EC3eX8VxGH64Xv3NFd9g4Y7PxSMnH3EGz5jQQrrQS8VZGnv4JY2K --template payroll.json --csv payroll.csv --set month=2020-03",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorECAddress),
+	Run: func(cmd *cobra.Command, args []string) {
+		cl := node.FactomClientFromConfig(viper.GetViper())
+		payment := args[0]
+
+		path, _ := cmd.Flags().GetString("template")
+		if path == "" {
+			cmd.PrintErrln("a --template is required")
+			os.Exit(1)
+		}
+		template, err := ioutil.ReadFile(path)
+		if err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		sets, _ := cmd.Flags().GetStringArray("set")
+		vars := make(map[string]string)
+		for _, set := range sets {
+			parts := strings.SplitN(set, "=", 2)
+			if len(parts) != 2 {
+				cmd.PrintErrf("invalid --set %q, expected name=value\n", set)
+				os.Exit(1)
+			}
+			vars[parts[0]] = parts[1]
+		}
+
+		rows := []map[string]string{vars}
+		if csvPath, _ := cmd.Flags().GetString("csv"); csvPath != "" {
+			f, err := os.Open(csvPath)
+			if err != nil {
+				cmd.PrintErrln(err)
+				os.Exit(1)
+			}
+			rows, err = templateRows(f, vars)
+			f.Close()
+			if err != nil {
+				cmd.PrintErrf("%s: %v\n", csvPath, err)
+				os.Exit(1)
+			}
+		}
+
+		batches := make([]*txBatchTemplate, len(rows))
+		for i, row := range rows {
+			if batches[i], err = parseTxTemplate(string(template), row); err != nil {
+				cmd.PrintErrf("batch %d: %v\n", i+1, err)
+				os.Exit(1)
+			}
+		}
+
+		if dry, _ := cmd.Flags().GetBool("dry-run"); dry {
+			for _, b := range batches {
+				data, err := json.Marshal(fat2.TransactionBatch{Version: 1, Transactions: b.Transactions, NotBefore: notBefore(cmd)})
+				if err != nil {
+					cmd.PrintErrln(err)
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+			}
+			return
+		}
+
+		// Check all balances up front, so no batch is sent if one of them
+		// can't be
+		if err := checkTemplateBalances(batches); err != nil {
+			cmd.PrintErrln(err)
+			os.Exit(1)
+		}
+
+		for i, b := range batches {
+			err, commit, reveal := signAndSendBatch(b.Input, b.Transactions, cl, payment, notBefore(cmd))
+			if err != nil {
+				cmd.PrintErrf("batch %d: %v\n", i+1, err)
+				os.Exit(1)
+			}
+			fmt.Printf("batch %d sent:\n", i+1)
+			fmt.Printf("\t%10s: %s\n", "EntryHash", reveal)
+			fmt.Printf("\t%10s: %s\n", "Commit", commit)
+		}
+	},
+}
+
+// txTemplate is the json format of a template after the placeholders are
+// filled
+type txTemplate struct {
+	Input        string `json:"input"`
+	Transactions []struct {
+		Asset     string `json:"asset"`
+		Amount    string `json:"amount"`
+		Transfers []struct {
+			Address string `json:"address"`
+			Amount  string `json:"amount"`
+		} `json:"transfers"`
+		Conversion string          `json:"conversion"`
+		MinOutput  string          `json:"minoutput"`
+		Metadata   json.RawMessage `json:"metadata"`
+	} `json:"transactions"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// txBatchTemplate is a filled template
+type txBatchTemplate struct {
+	Input        string
+	Transactions []fat2.Transaction
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// fillTemplate replaces the placeholders with their json escaped values
+func fillTemplate(template string, vars map[string]string) (string, error) {
+	var missing []string
+	filled := templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		name := templatePlaceholder.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		escaped, _ := json.Marshal(value)
+		return string(escaped[1 : len(escaped)-1])
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for the placeholders %s", strings.Join(missing, ", "))
+	}
+	return filled, nil
+}
+
+// parseTxTemplate fills the template and builds its transactions
+func parseTxTemplate(template string, vars map[string]string) (*txBatchTemplate, error) {
+	filled, err := fillTemplate(template, vars)
+	if err != nil {
+		return nil, err
+	}
+	var t txTemplate
+	if err := json.Unmarshal([]byte(filled), &t); err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	if len(t.Transactions) == 0 {
+		return nil, fmt.Errorf("the template has no transactions")
+	}
+
+	input, err := underlyingFA(t.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %v", err)
+	}
+
+	b := &txBatchTemplate{Input: t.Input}
+	for i, ttx := range t.Transactions {
+		var tx fat2.Transaction
+		tx.Input.Address = input
+		if tx.Input.Type, err = ticker(ttx.Asset); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, strings.TrimSpace(err.Error()))
+		}
+
+		if ttx.Conversion != "" {
+			if tx.Conversion, err = ticker(ttx.Conversion); err != nil {
+				return nil, fmt.Errorf("transaction %d: conversion: %v", i, strings.TrimSpace(err.Error()))
+			}
+			if tx.Input.Amount, err = FactoidToFactoshi(ttx.Amount); err != nil {
+				return nil, fmt.Errorf("transaction %d: invalid amount %q", i, ttx.Amount)
+			}
+			if ttx.MinOutput != "" {
+				if tx.MinOutput, err = FactoidToFactoshi(ttx.MinOutput); err != nil {
+					return nil, fmt.Errorf("transaction %d: invalid minoutput %q", i, ttx.MinOutput)
+				}
+			}
+		}
+		for j, transfer := range ttx.Transfers {
+			var out fat2.AddressAmountTuple
+			if out.Address, err = underlyingFA(transfer.Address); err != nil {
+				return nil, fmt.Errorf("transaction %d: transfer %d: %v", i, j, err)
+			}
+			if err := addressRules(t.Input, transfer.Address); err != nil {
+				return nil, fmt.Errorf("transaction %d: transfer %d: %v", i, j, err)
+			}
+			if out.Amount, err = FactoidToFactoshi(transfer.Amount); err != nil {
+				return nil, fmt.Errorf("transaction %d: transfer %d: invalid amount %q", i, j, transfer.Amount)
+			}
+			tx.Transfers = append(tx.Transfers, out)
+			tx.Input.Amount += out.Amount
+		}
+
+		metadata := ttx.Metadata
+		if metadata == nil {
+			metadata = t.Metadata
+		}
+		if metadata != nil {
+			tx.Metadata = metadata
+		}
+		if err := tx.Validate(); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		if err := tx.ValidMetadata(); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		b.Transactions = append(b.Transactions, tx)
+	}
+	return b, nil
+}
+
+// templateRows reads the csv rows as placeholder values on top of the
+// defaults
+func templateRows(r io.Reader, defaults map[string]string) ([]map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("a header and at least one row are required")
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(defaults)+len(header))
+		for k, v := range defaults {
+			row[k] = v
+		}
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// checkTemplateBalances makes sure the inputs can cover all batches
+func checkTemplateBalances(batches []*txBatchTemplate) error {
+	needed := make(map[string]map[fat2.PTicker]uint64)
+	for _, b := range batches {
+		if needed[b.Input] == nil {
+			needed[b.Input] = make(map[fat2.PTicker]uint64)
+		}
+		for _, tx := range b.Transactions {
+			needed[b.Input][tx.Input.Type] += tx.Input.Amount
+		}
+	}
+
+	for input, assets := range needed {
+		bals, err := queryBalances(input)
+		if err != nil {
+			return fmt.Errorf("failed to get asset balance: %s", err.Error())
+		}
+		for asset, amount := range assets {
+			if bals[asset] < amount {
+				return fmt.Errorf("%s: not enough %s to cover the batches, %s required", input, asset, FactoshiToFactoid(int64(amount)))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+const testTxTemplate = `{
+  "input": "{{source}}",
+  "transactions": [
+    {"asset": "pUSD", "transfers": [
+      {"address": "{{employee}}", "amount": "{{salary}}"},
+      {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "amount": "1.5"}
+    ]},
+    {"asset": "PEG", "amount": "{{convert}}", "conversion": "pUSD", "minoutput": "2"}
+  ],
+  "metadata": {"memo": "payroll {{month}}"}
+}`
+
+func TestParseTxTemplate(t *testing.T) {
+	rows, err := templateRows(strings.NewReader("employee,salary\nFA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC,100\nFA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q, 50.25\n"),
+		map[string]string{"source": "FA3EPZYqodgyEGXNMbiZKE5TS2x2J9wF8J9MvPZb52iGR78xMgCb", "month": `"march"`, "convert": "10", "salary": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1]["salary"] != "50.25" || rows[0]["month"] != `"march"` {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	b, err := parseTxTemplate(testTxTemplate, rows[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(b.Transactions))
+	}
+	transfer, conversion := b.Transactions[0], b.Transactions[1]
+	if transfer.Input.Type != fat2.PTickerUSD || transfer.Input.Amount != 5175e6 || len(transfer.Transfers) != 2 {
+		t.Errorf("unexpected transfer %+v", transfer)
+	}
+	if conversion.Input.Type != fat2.PTickerPEG || conversion.Input.Amount != 10e8 || conversion.Conversion != fat2.PTickerUSD || conversion.MinOutput != 2e8 {
+		t.Errorf("unexpected conversion %+v", conversion)
+	}
+	// Values are escaped inside of json strings
+	if string(transfer.Metadata.(json.RawMessage)) != `{"memo": "payroll \"march\""}` {
+		t.Errorf("unexpected metadata %s", transfer.Metadata)
+	}
+
+	if _, err := parseTxTemplate(testTxTemplate, map[string]string{"source": "FA3EPZYqodgyEGXNMbiZKE5TS2x2J9wF8J9MvPZb52iGR78xMgCb"}); err == nil ||
+		err.Error() != "no value for the placeholders employee, salary, convert, month" {
+		t.Errorf("expected missing placeholders, got %v", err)
+	}
+	rows[0]["salary"] = "abc"
+	if _, err := parseTxTemplate(testTxTemplate, rows[0]); err == nil {
+		t.Error("expected an invalid amount")
+	}
+}
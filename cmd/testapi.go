@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pegnet/pegnetd/apitest"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/exit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	testAPI.Flags().String("target", "", "The url of the api to test, defaults to the pegnetd of the config")
+	testAPI.Flags().String("admin-token", "", "The token of the admin rpcs, defaults to api.admintoken of the config")
+	testAPI.Flags().String("scheduler-token", "", "The token of the schedule rpcs, defaults to scheduler.token of the config")
+	testAPI.Flags().StringSlice("method", nil, "Only test these methods")
+	testAPI.Flags().Bool("strict", false, "Fail results with fields that are not in the api")
+	testAPI.Flags().Duration("timeout", 30*time.Second, "The timeout of a request")
+	testAPI.Flags().BoolP("verbose", "v", false, "Also list the cases that passed")
+	rootCmd.AddCommand(testAPI)
+}
+
+var testAPI = &cobra.Command{
+	Use:   "test-api [--target <url>]",
+	Short: "Run a conformance suite against the api of a pegnetd node",
+	Long: "Call every rpc of the api with valid and invalid params, and check the results against the result types " +
+		"of this version and the errors against the expected error codes. The json-rpc protocol errors, batches, " +
+		"and notifications are checked too. Methods that change the node are only called with params or tokens " +
+		"they reject, so the suite is safe to run against a live node or a proxy in front of one.\n\n" +
+		"The valid params are made of the data of the target, like the address at the top of the PEG rich list " +
+		"and its last transaction; the cases are skipped if the target has none. The admin and schedule rpcs " +
+		"are only called with a valid token if one is set. Exits with 1 if a case failed.",
+	Example:          "pegnetd test-api\npegnetd test-api --target https://api.pegnetd.com --method get-transactions,get-rich-list",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		exit.GlobalExitHandler.AddCancel(cancel)
+
+		target, _ := cmd.Flags().GetString("target")
+		if target == "" {
+			target = viper.GetString(config.Pegnetd)
+		}
+		var opts apitest.Options
+		opts.AdminToken, _ = cmd.Flags().GetString("admin-token")
+		if opts.AdminToken == "" {
+			opts.AdminToken = viper.GetString(config.APIAdminToken)
+		}
+		opts.SchedulerToken, _ = cmd.Flags().GetString("scheduler-token")
+		if opts.SchedulerToken == "" {
+			opts.SchedulerToken = viper.GetString(config.SchedulerToken)
+		}
+		opts.Methods, _ = cmd.Flags().GetStringSlice("method")
+		for _, m := range opts.Methods {
+			if _, ok := apitest.Lookup(m); !ok {
+				cmd.PrintErrf("%s is not a method of the api\n", m)
+				os.Exit(1)
+			}
+		}
+		opts.Strict, _ = cmd.Flags().GetBool("strict")
+		opts.Timeout, _ = cmd.Flags().GetDuration("timeout")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+
+		outcomes := apitest.Run(ctx, target, opts)
+		counts := make(map[apitest.Status]int)
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METHOD\tCASE\tSTATUS\tTIME\tDETAIL")
+		for _, o := range outcomes {
+			counts[o.Status]++
+			if o.Status == apitest.Pass && !verbose {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.Method, o.Case, o.Status, o.Duration.Round(time.Millisecond), o.Detail)
+		}
+		_ = w.Flush()
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s: %d passed, %d failed, %d skipped\n",
+			target, counts[apitest.Pass], counts[apitest.Fail], counts[apitest.Skip])
+		if counts[apitest.Fail] > 0 {
+			os.Exit(1)
+		}
+	},
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/fat/fat2"
 	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/srv"
 )
 
 func addressRules(input string, output string) error {
@@ -39,25 +44,36 @@ func addressRules(input string, output string) error {
 	return nil
 }
 
-func signAndSend(source string, tx *fat2.Transaction, cl *factom.Client, payment string) (err error, commit *factom.Bytes32, reveal *factom.Bytes32) {
+func signAndSend(source string, tx *fat2.Transaction, cl *factom.Client, payment string, notBefore uint32) (err error, commit *factom.Bytes32, reveal *factom.Bytes32) {
+	return signAndSendBatch(source, []fat2.Transaction{*tx}, cl, payment, notBefore)
+}
+
+// signAndSendBatch signs the transactions of the source as one batch and
+// submits it. All transactions must have the source as input.
+func signAndSendBatch(source string, txs []fat2.Transaction, cl *factom.Client, payment string, notBefore uint32) (err error, commit *factom.Bytes32, reveal *factom.Bytes32) {
+	input, err := underlyingFA(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse input: %s\n", err.Error()), nil, nil
+	}
+
 	// Get out private key
 	// If the source is an Fe/FE address, we use the eth secret
 	var priv factom.RCDSigner
 
 	switch source[:2] {
 	case "FA":
-		priv, err = tx.Input.Address.GetFsAddress(nil, cl)
+		priv, err = input.GetFsAddress(nil, cl)
 		if err != nil {
 			return fmt.Errorf("[FA] unable to get private key: %s\n", err.Error()), nil, nil
 		}
 	case "Fe":
-		addr := factom.FeAddress(tx.Input.Address)
+		addr := factom.FeAddress(input)
 		priv, err = addr.GetEthSecret(nil, cl)
 		if err != nil {
 			return fmt.Errorf("[Fe] unable to get private key: %s\n", err.Error()), nil, nil
 		}
 	case "FE":
-		addr := factom.FEGatewayAddress(tx.Input.Address)
+		addr := factom.FEGatewayAddress(input)
 		priv, err = addr.GetEthSecret(nil, cl)
 		if err != nil {
 			return fmt.Errorf("[FE] unable to get private key: %s\n", err.Error()), nil, nil
@@ -66,7 +82,8 @@ func signAndSend(source string, tx *fat2.Transaction, cl *factom.Client, payment
 
 	var txBatch fat2.TransactionBatch
 	txBatch.Version = 1
-	txBatch.Transactions = []fat2.Transaction{*tx}
+	txBatch.Transactions = txs
+	txBatch.NotBefore = notBefore
 	txBatch.Entry.ChainID = &node.TransactionChain
 
 	// Sign the tx and make an entry
@@ -152,6 +169,66 @@ func setTransactionInput(tx *fat2.Transaction, cl *factom.Client, source, asset,
 	return nil
 }
 
+// setMetadata sets the metadata of the transaction from the "metadata"
+// flag. Valid json is used as is, anything else as a json string.
+func setMetadata(cmd *cobra.Command, tx *fat2.Transaction) error {
+	metadata, _ := cmd.Flags().GetString("metadata")
+	if metadata == "" {
+		return nil
+	}
+	if json.Valid([]byte(metadata)) {
+		tx.Metadata = json.RawMessage(metadata)
+	} else {
+		tx.Metadata = metadata
+	}
+	return tx.ValidMetadata()
+}
+
+// setMinOutput sets the minimum output of the conversion from either the
+// "minoutput" flag, or the "slippage" flag as a percentage below the
+// output at the latest rates.
+func setMinOutput(cmd *cobra.Command, tx *fat2.Transaction) error {
+	minOutput, _ := cmd.Flags().GetString("minoutput")
+	slippage, _ := cmd.Flags().GetFloat64("slippage")
+	if minOutput != "" && slippage != 0 {
+		return fmt.Errorf("only one of minoutput and slippage can be set")
+	}
+
+	if minOutput != "" {
+		amount, err := FactoidToFactoshi(minOutput)
+		if err != nil {
+			return fmt.Errorf("invalid minimum output specified: %s", err.Error())
+		}
+		tx.MinOutput = uint64(amount)
+		return nil
+	}
+
+	if slippage == 0 {
+		return nil
+	}
+	if slippage < 0 || slippage >= 100 {
+		return fmt.Errorf("slippage must be a percentage between 0 and 100")
+	}
+	cl := srv.NewClient()
+	cl.PegnetdServer = viper.GetString(config.Pegnetd)
+	rates, err := getPegnetRates(0, cl)
+	if err != nil {
+		return fmt.Errorf("failed to get the rates: %s", err.Error())
+	}
+	output, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
+	if err != nil {
+		return fmt.Errorf("failed to convert at the latest rates: %s", err.Error())
+	}
+	tx.MinOutput = uint64(float64(output) * (100 - slippage) / 100)
+	return nil
+}
+
+// notBefore returns the time lock height of the "notbefore" flag
+func notBefore(cmd *cobra.Command) uint32 {
+	height, _ := cmd.Flags().GetUint32("notbefore")
+	return height
+}
+
 func ticker(asset string) (fat2.PTicker, error) {
 	// No asset starts with a 'p', so we can do the quick check
 	// if the start is a p for if it is already in 'p' form.
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"math"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // TODO: We should really have a simple module for these
@@ -53,3 +57,22 @@ func FactoidToFactoshi(amt string) (uint64, error) {
 
 	return total, nil
 }
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readSecret prompts for a secret on the terminal without echoing it. If
+// stdin is not a terminal, a line is read from it instead.
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading the %s: %v", strings.TrimSuffix(strings.ToLower(prompt), ": "), err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(data), err
+}
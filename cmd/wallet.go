@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/srv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	walletCmd.PersistentFlags().String("api-key", "", "The X-Api-Key of the requests, for nodes that restrict the accounts to api keys")
+	for _, c := range []*cobra.Command{walletWatch, walletUnwatch} {
+		c.Flags().String("admin-token", "", "The token of the admin rpcs, defaults to api.admintoken of the config")
+	}
+	walletWatch.Flags().String("account", "", "The wallet account of the address")
+	walletWatch.Flags().String("label", "", "An optional label of the address")
+	for _, c := range []*cobra.Command{walletAddresses, walletDashboard} {
+		c.Flags().String("account", "", "Only this wallet account")
+	}
+	walletCmd.AddCommand(walletWatch)
+	walletCmd.AddCommand(walletUnwatch)
+	walletCmd.AddCommand(walletAddresses)
+	walletCmd.AddCommand(walletDashboard)
+	rootCmd.AddCommand(walletCmd)
+}
+
+var walletCmd = &cobra.Command{
+	Use:   "wallet <subcommand>",
+	Short: "List the accounts of the node wallet and their balances",
+	Long: "The accounts of the wallet are configured in wallet.accounts. Addresses the node has no key for, like " +
+		"cold storage, can be added to an account as watch-only addresses. Their funds are part of the balances " +
+		"of the account, but the node can not spend them.",
+}
+
+var walletWatch = &cobra.Command{
+	Use:              "watch <address> --account <account>",
+	Short:            "Import an address as a watch-only address of a wallet account",
+	Example:          "pegnetd wallet watch FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q --account cold --label \"paper wallet\"",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorAddress(ADD_FA|ADD_FE|ADD_Fe)),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := srv.ParamsImportWatchAddress{Token: walletAdminToken(cmd), Address: args[0]}
+		params.Account, _ = cmd.Flags().GetString("account")
+		params.Label, _ = cmd.Flags().GetString("label")
+
+		var res pegnet.WatchAddress
+		if err := walletClient(cmd).Request("import-watch-address", params, &res); err != nil {
+			cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Watching %s in the account %s\n", res.Address, res.Account)
+	},
+}
+
+var walletUnwatch = &cobra.Command{
+	Use:              "unwatch <address>",
+	Short:            "Remove a watch-only address",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             CustomArgOrderValidationBuilder(true, ArgValidatorAddress(ADD_FA|ADD_FE|ADD_Fe)),
+	Run: func(cmd *cobra.Command, args []string) {
+		params := srv.ParamsWatchAddress{Token: walletAdminToken(cmd), Address: args[0]}
+		var res bool
+		if err := walletClient(cmd).Request("remove-watch-address", params, &res); err != nil {
+			cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+var walletAddresses = &cobra.Command{
+	Use:              "addresses",
+	Short:            "List the addresses of the wallet accounts",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var params srv.ParamsGetAccountBalances
+		params.Account, _ = cmd.Flags().GetString("account")
+		var res []srv.WalletAddress
+		if err := walletClient(cmd).Request("list-addresses", params, &res); err != nil {
+			cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tADDRESS\tWATCH-ONLY\tLABEL")
+		for _, a := range res {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", a.Account, a.Address, a.WatchOnly, a.Label)
+		}
+		_ = w.Flush()
+	},
+}
+
+var walletDashboard = &cobra.Command{
+	Use:              "dashboard",
+	Short:            "Print the balances of the wallet accounts",
+	Long:             "Print the balances of every account, split into the funds the node can spend and the watch-only funds. Assets without a balance are left out.",
+	PersistentPreRun: always,
+	PreRun:           SoftReadConfig,
+	Args:             cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var params srv.ParamsGetAccountBalances
+		params.Account, _ = cmd.Flags().GetString("account")
+		var res srv.ResultGetAccountBalances
+		if err := walletClient(cmd).Request("get-account-balances", params, &res); err != nil {
+			cmd.PrintErrf("Failed to make RPC request\nDetails:\n%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Balances as of height %d\n\n", res.Height)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tADDRESSES\tASSET\tSPENDABLE\tWATCH-ONLY\tTOTAL")
+		for _, account := range res.Accounts {
+			tickers := make([]fat2.PTicker, 0, len(account.Total))
+			for ticker, total := range account.Total {
+				if total > 0 {
+					tickers = append(tickers, ticker)
+				}
+			}
+			sort.Slice(tickers, func(i, j int) bool { return tickers[i] < tickers[j] })
+			if len(tickers) == 0 {
+				fmt.Fprintf(w, "%s\t%d\t-\t0\t0\t0\n", account.Name, len(account.Addresses))
+			}
+			for i, ticker := range tickers {
+				name, addresses := "", ""
+				if i == 0 {
+					name, addresses = account.Name, fmt.Sprint(len(account.Addresses))
+				}
+				total, watchOnly := account.Total[ticker], account.WatchOnly[ticker]
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, addresses, ticker,
+					FactoshiToFactoid(int64(total-watchOnly)), FactoshiToFactoid(int64(watchOnly)), FactoshiToFactoid(int64(total)))
+			}
+		}
+		_ = w.Flush()
+	},
+}
+
+// walletClient is a pegnetd client that sends the "api-key" flag
+func walletClient(cmd *cobra.Command) *srv.Client {
+	cl := srv.NewClient()
+	cl.PegnetdServer = viper.GetString(config.Pegnetd)
+	if key, _ := cmd.Flags().GetString("api-key"); key != "" {
+		cl.Header = http.Header{srv.APIKeyHeader: []string{key}}
+	}
+	return cl
+}
+
+// walletAdminToken is the "admin-token" flag, or the token of the config
+func walletAdminToken(cmd *cobra.Command) string {
+	if token, _ := cmd.Flags().GetString("admin-token"); token != "" {
+		return token
+	}
+	return viper.GetString(config.APIAdminToken)
+}
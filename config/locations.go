@@ -3,14 +3,208 @@ package config
 // A list of config locations
 const (
 	LoggingLevel = "app.loglevel"
-	SqliteDBPath = "app.dbpath"
-	APIListen    = "app.APIListen"
+	// LoggingFormat is "text" or "json", one object per line
+	LoggingFormat = "app.logformat"
+	SqliteDBPath  = "app.dbpath"
+	APIListen     = "app.APIListen"
+	// Network is "MainNet", or the path of the JSON file of a custom
+	// network
+	Network = "app.network"
+	// Regtest runs the node against the embedded mock factomd on the
+	// RegTest network, with a fresh database
+	Regtest = "app.regtest"
+	// DevRatesFile is the path of a JSON file of rates that replace the
+	// graded rates at heights, it is rejected on MainNet
+	DevRatesFile = "app.devrates"
+	// ECKeystore is the path of a keystore file with the encrypted EC key,
+	// see the keystore command. It replaces ECPrivateKey.
+	ECKeystore = "app.eckeystore"
+	// ECKeystorePassphrase unlocks the keystore on start, it is meant to be
+	// set in the environment. Without it, the passphrase is prompted for on
+	// a terminal, or the keystore stays locked until the unlock-keystore rpc.
+	ECKeystorePassphrase = "app.eckeystorepassphrase"
+	// ECPrivateKeys are more EC keys that pay for the entries once the
+	// balance of ECPrivateKey or the keystore is exhausted
+	ECPrivateKeys = "app.ecprivatekeys"
+
+	// SignerURL is the signer daemon that signs for the node, like
+	// "unix:///run/pegnetd/signer.sock", see the signer command. The
+	// scheduler signs its transfers with it instead of walletd.
+	SignerURL   = "signer.url"
+	SignerToken = "signer.token"
+	// SignerECAddresses are the EC keys of the signer that pay for the
+	// entries, after the local keys
+	SignerECAddresses = "signer.ecaddresses"
+
+	// PKCS11Module is the PKCS#11 module of a hardware token, like
+	// yubihsm_pkcs11.so, that holds the ed25519 keys of PKCS11Keys
+	PKCS11Module = "pkcs11.module"
+	// PKCS11Token is the label of the token, the first token if empty
+	PKCS11Token = "pkcs11.token"
+	PKCS11PIN   = "pkcs11.pin"
+	// PKCS11Keys are the EC and FA keys on the token, like
+	// "EC2... label=pegnet-ec" or "FA2... id=0102"
+	PKCS11Keys = "pkcs11.keys"
 
 	// DBlockSync Stuff
 	DBlockSyncRetryPeriod = "dblocksync.retry"
+	// SupplyHistoryInterval is how often (in blocks) the supply is recorded
+	SupplyHistoryInterval = "dblocksync.supplyinterval"
+	// DBlockSyncPrefetch is how many blocks are fetched and graded ahead of
+	// the block being applied
+	DBlockSyncPrefetch = "dblocksync.prefetch"
+	// SlowBlock is how long grading or applying a block may take before it
+	// is logged, 0 disables it
+	SlowBlock = "dblocksync.slowblock"
+
+	// WatchdogStalled is how long the sync can stay at the same height while
+	// factomd is ahead before the node is unhealthy, 0 disables the watchdog
+	WatchdogStalled  = "watchdog.stalled"
+	WatchdogInterval = "watchdog.interval"
+
+	// ECMonitorTransactions is the amount of transactions the EC balance
+	// should still pay for before it is low, 0 only checks notify.ecbalance
+	ECMonitorTransactions = "ecmonitor.transactions"
+	ECMonitorInterval     = "ecmonitor.interval"
+
+	// PriceCheckSources are the external apis the graded rates are compared
+	// to, "<asset> <url> <json path> [inverse]"
+	PriceCheckSources = "pricecheck.sources"
+	// PriceCheckThreshold is the deviation in percent from the reference
+	// price that flags a block
+	PriceCheckThreshold = "pricecheck.threshold"
+	PriceCheckInterval  = "pricecheck.interval"
+	// PriceCheckMaxAge is how old a block can be to be compared to the
+	// current reference prices
+	PriceCheckMaxAge = "pricecheck.maxage"
+
+	// BootstrapStateHash is the trusted state hash of the snapshot of
+	// 'pegnetd init --bootstrap' at its height
+	BootstrapStateHash = "bootstrap.statehash"
+	// BootstrapNodes are pegnetd apis that have to report the state hash of
+	// the snapshot at its height
+	BootstrapNodes = "bootstrap.nodes"
+
+	// EventQueueSize is the amount of blocks buffered for the event sinks
+	EventQueueSize = "events.queue"
+	NATSURL        = "events.natsurl"
+	NATSSubject    = "events.natssubject"
+	KafkaRESTProxy = "events.kafkaproxy"
+	KafkaTopic     = "events.kafkatopic"
+	// The ZMQ endpoints, eg: "tcp://127.0.0.1:28332"
+	ZMQPubHeight  = "events.zmqpubheight"
+	ZMQPubHashTx  = "events.zmqpubhashtx"
+	ZMQPubRawTx   = "events.zmqpubrawtx"
+	ZMQPubAddress = "events.zmqpubaddress"
+	MQTTURL       = "events.mqtturl"
+	MQTTPrefix    = "events.mqttprefix"
+	MQTTClientID  = "events.mqttclientid"
+
+	// DepositConfirmations is the default for new deposit addresses
+	DepositConfirmations = "deposits.confirmations"
+	DepositWebhook       = "deposits.webhook"
+
+	// Assets are registered in addition to the assets in code, like
+	// "pXAF 250000 XAF"
+	Assets = "assets.register"
+
+	// AlertRules are like "transfer > 1000000 pUSD"
+	AlertRules   = "alerts.rules"
+	AlertWebhook = "alerts.webhook"
+
+	NotifyTelegramToken  = "notify.telegramtoken"
+	NotifyTelegramChat   = "notify.telegramchat"
+	NotifyDiscordWebhook = "notify.discordwebhook"
+	NotifyTriggers       = "notify.triggers"
+	NotifyAddresses      = "notify.addresses"
+	// NotifyStalled is how long the sync can not progress before notifying
+	NotifyStalled = "notify.stalled"
+	// NotifyECBalance is the entry credit balance that is considered low
+	NotifyECBalance = "notify.ecbalance"
+	// NotifyBehind is how many blocks the sync can fall behind factomd
+	NotifyBehind = "notify.behind"
+	// NotifyUnreachable is how long factomd can be unreachable
+	NotifyUnreachable = "notify.unreachable"
+	// NotifyThrottle is the minimum time between messages of the same rule
+	NotifyThrottle     = "notify.throttle"
+	NotifySMTPServer   = "notify.smtpserver"
+	NotifySMTPUser     = "notify.smtpuser"
+	NotifySMTPPassword = "notify.smtppassword"
+	NotifyEmailFrom    = "notify.emailfrom"
+	NotifyEmailTo      = "notify.emailto"
+	// The email templates are text/templates of a notify.Message
+	NotifyEmailSubject = "notify.emailsubject"
+	NotifyEmailBody    = "notify.emailbody"
+
+	// MetricsFormat is either "influx" or "statsd", metrics are only pushed
+	// if the url is set
+	MetricsFormat   = "metrics.format"
+	MetricsURL      = "metrics.url"
+	MetricsInterval = "metrics.interval"
+	MetricsPrefix   = "metrics.prefix"
+
+	// TracingEndpoint is the OTLP/HTTP traces endpoint of a collector, eg
+	// "http://localhost:4318/v1/traces". Tracing is disabled if it is empty.
+	TracingEndpoint = "tracing.endpoint"
+	TracingService  = "tracing.service"
+	TracingInterval = "tracing.interval"
+
+	// WalletAccounts group the addresses of the wallet into named accounts,
+	// like "trading FA... FA..."
+	WalletAccounts = "wallet.accounts"
+	// WalletAPIKeys restrict the signing rpcs of an X-Api-Key to the
+	// addresses of accounts, like "<key> trading payroll"
+	WalletAPIKeys = "wallet.apikeys"
+	// WalletPolicies restrict what the node signs for an address or the
+	// addresses of an account, like "payroll daily=1000:pUSD to=staff"
+	WalletPolicies = "wallet.policies"
+
+	// SchedulerToken authenticates the schedule rpcs, the scheduler only
+	// runs if it is set
+	SchedulerToken = "scheduler.token"
+	// SchedulerPeriod is how often the due schedules are submitted
+	SchedulerPeriod = "scheduler.period"
+
+	// APIWorkers is how many expensive rpcs, like the rich lists and the
+	// ledger, are computed at the same time
+	APIWorkers = "api.workers"
+	// APIWorkerWait is how long an expensive rpc waits for a free worker
+	APIWorkerWait = "api.workerwait"
+	// APIGzip compresses the responses of clients that accept gzip
+	APIGzip = "api.gzip"
+	// APICacheSize is how many read results are cached until the sync
+	// height advances, 0 disables the cache
+	APICacheSize = "api.cachesize"
+	// APIAdminToken authenticates the admin rpcs, like get-audit-log. They
+	// are disabled if it is empty.
+	APIAdminToken = "api.admintoken"
+
+	// The chaos options inject faults to test the retries, rollbacks, and
+	// crash safety of the daemon. They are left out of the example config
+	// and the effective config unless they are set. The probabilities are
+	// per factomd request, per database write, and per synced block.
+	ChaosFactomdTimeout   = "chaos.factomdtimeout"
+	ChaosFactomdMalformed = "chaos.factomdmalformed"
+	ChaosDBWriteError     = "chaos.dbwriteerror"
+	ChaosCrash            = "chaos.crash"
+	// ChaosTimeoutDelay is how long an injected timeout hangs the request
+	ChaosTimeoutDelay = "chaos.timeoutdelay"
+	// ChaosSeed makes the faults repeatable, 0 seeds from the clock
+	ChaosSeed = "chaos.seed"
 
 	CustomSQLDBMode = "db.mode"
 	SQLDBWalMode    = "db.wal"
+	// SlowQuery is how long a query may take before it is logged with its
+	// parameters, 0 disables it
+	SlowQuery = "db.slowquery"
+	// DBColumnKey encrypts the private columns of the operator tables, eg:
+	// the send audit, the watched and watch-only addresses, the schedules,
+	// and the policy spends. The database file is not encrypted. It is 32
+	// bytes, hex or base64 encoded.
+	DBColumnKey = "db.columnkey"
+	// DBColumnKeyCommand is run on start instead to fetch the key, eg from a
+	// KMS. It prints the key to stdout.
+	DBColumnKeyCommand = "db.columnkeycommand"
 
 	Server               = "app.Server"
 	Wallet               = "app.Wallet"
@@ -0,0 +1,657 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of the environment variables of the options
+const EnvPrefix = "PEGNETD_"
+
+// DefaultDBPath is the database of MainNet, the other networks default to a
+// directory of their name
+const DefaultDBPath = "$HOME/.pegnetd/mainnet/sql.db"
+
+// Kind is the type of the value of an option
+type Kind int
+
+const (
+	String Kind = iota
+	Bool
+	Int
+	Uint
+	Duration
+	StringSlice
+	Float
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Bool:
+		return "bool"
+	case Int:
+		return "int"
+	case Uint:
+		return "uint"
+	case Duration:
+		return "duration"
+	case StringSlice:
+		return "list"
+	case Float:
+		return "float"
+	}
+	return "string"
+}
+
+// Option is a single config value
+type Option struct {
+	Key     string
+	Kind    Kind
+	Default interface{}
+	// Env is the environment variable that overrides the config file. It is
+	// EnvPrefix and the key, eg "PEGNETD_API_WORKERS", unless it is set. "-"
+	// disables it, for lists whose items contain spaces.
+	Env string
+	// Secret values are redacted from the effective config
+	Secret bool
+	// Hidden options are only in the effective config if they are set
+	Hidden bool
+	// Check validates the parsed value, it is not called for empty values
+	Check func(value interface{}) error
+}
+
+// Options are all the config values of pegnetd
+var Options = []Option{
+	{Key: LoggingLevel, Kind: String, Default: "info", Check: oneOf("trace", "debug", "info", "warn", "warning", "error", "fatal")},
+	{Key: LoggingFormat, Kind: String, Default: "text", Check: oneOf("text", "json")},
+	{Key: SqliteDBPath, Kind: String, Default: DefaultDBPath},
+	{Key: Network, Kind: String, Default: "MainNet"},
+	{Key: Regtest, Kind: Bool, Default: false},
+	{Key: DevRatesFile, Kind: String},
+	{Key: APIListen, Kind: String, Default: "8070", Check: listenAddr},
+	{Key: Server, Kind: String, Default: "http://localhost:8088/v2", Check: urlScheme("http", "https")},
+	{Key: Wallet, Kind: String, Default: "http://localhost:8089/v2", Check: urlScheme("http", "https")},
+	{Key: WalletUser, Kind: String},
+	{Key: WalletPass, Kind: String, Secret: true},
+	{Key: Pegnetd, Kind: String, Default: "http://localhost:8070", Check: urlScheme("http", "https")},
+	{Key: ECPrivateKey, Kind: String, Secret: true, Check: ecPrivateKey},
+	{Key: ECPrivateKeys, Kind: StringSlice, Env: "-", Secret: true, Check: each(ecPrivateKey)},
+	{Key: ECKeystore, Kind: String},
+	{Key: ECKeystorePassphrase, Kind: String, Secret: true},
+	{Key: DisableHardForkCheck, Kind: Bool, Default: false},
+
+	{Key: SignerURL, Kind: String, Check: urlScheme("unix", "http", "https")},
+	{Key: SignerToken, Kind: String, Secret: true},
+	{Key: SignerECAddresses, Kind: StringSlice, Env: "-", Check: each(ecAddress)},
+
+	{Key: PKCS11Module, Kind: String},
+	{Key: PKCS11Token, Kind: String},
+	{Key: PKCS11PIN, Kind: String, Secret: true},
+	{Key: PKCS11Keys, Kind: StringSlice, Env: "-"},
+
+	{Key: DBlockSyncRetryPeriod, Kind: Duration, Default: 5 * time.Second, Check: positive},
+	{Key: SupplyHistoryInterval, Kind: Uint, Default: 1},
+	{Key: DBlockSyncPrefetch, Kind: Uint, Default: 8},
+	{Key: SlowBlock, Kind: Duration, Default: 10 * time.Second},
+
+	{Key: WatchdogStalled, Kind: Duration, Default: 30 * time.Minute},
+	{Key: WatchdogInterval, Kind: Duration, Default: time.Minute, Check: positive},
+
+	{Key: ECMonitorTransactions, Kind: Uint, Default: 100},
+	{Key: ECMonitorInterval, Kind: Duration, Default: time.Minute, Check: positive},
+
+	{Key: PriceCheckSources, Kind: StringSlice, Env: "-"},
+	{Key: PriceCheckThreshold, Kind: Float, Default: 5.0, Check: positive},
+	{Key: PriceCheckInterval, Kind: Duration, Default: time.Minute, Check: positive},
+	{Key: PriceCheckMaxAge, Kind: Duration, Default: 30 * time.Minute, Check: positive},
+
+	{Key: BootstrapStateHash, Kind: String, Check: stateHash},
+	{Key: BootstrapNodes, Kind: StringSlice, Check: each(urlScheme("http", "https"))},
+
+	{Key: EventQueueSize, Kind: Uint, Default: 1000},
+	{Key: NATSURL, Kind: String, Check: urlHost},
+	{Key: NATSSubject, Kind: String, Default: "pegnet"},
+	{Key: KafkaRESTProxy, Kind: String, Check: urlScheme("http", "https")},
+	{Key: KafkaTopic, Kind: String, Default: "pegnet"},
+	{Key: ZMQPubHeight, Kind: String, Check: urlScheme("tcp")},
+	{Key: ZMQPubHashTx, Kind: String, Check: urlScheme("tcp")},
+	{Key: ZMQPubRawTx, Kind: String, Check: urlScheme("tcp")},
+	{Key: ZMQPubAddress, Kind: String, Check: urlScheme("tcp")},
+	{Key: MQTTURL, Kind: String, Check: urlScheme("tcp", "mqtt")},
+	{Key: MQTTPrefix, Kind: String, Default: "pegnet"},
+	{Key: MQTTClientID, Kind: String},
+
+	{Key: DepositConfirmations, Kind: Uint, Default: 1},
+	{Key: DepositWebhook, Kind: String, Check: urlScheme("http", "https")},
+
+	{Key: Assets, Kind: StringSlice, Env: "-"},
+
+	{Key: AlertRules, Kind: StringSlice, Env: "-"},
+	{Key: AlertWebhook, Kind: String, Check: urlScheme("http", "https")},
+
+	{Key: NotifyTelegramToken, Kind: String, Secret: true},
+	{Key: NotifyTelegramChat, Kind: String},
+	{Key: NotifyDiscordWebhook, Kind: String, Secret: true, Check: urlScheme("http", "https")},
+	{Key: NotifyTriggers, Kind: StringSlice, Default: []string{"address", "stalled", "behind", "unreachable", "ecbalance", "pricedeviation", "malformedburn"},
+		Check: each(oneOf("address", "stalled", "behind", "unreachable", "ecbalance", "pricedeviation", "malformedburn"))},
+	{Key: NotifyAddresses, Kind: StringSlice, Check: each(faAddress)},
+	{Key: NotifyStalled, Kind: Duration, Default: 30 * time.Minute},
+	{Key: NotifyECBalance, Kind: Uint, Default: 100},
+	{Key: NotifyBehind, Kind: Uint, Default: 10},
+	{Key: NotifyUnreachable, Kind: Duration, Default: 10 * time.Minute},
+	{Key: NotifyThrottle, Kind: Duration, Default: 5 * time.Minute},
+	{Key: NotifySMTPServer, Kind: String, Check: hostPort},
+	{Key: NotifySMTPUser, Kind: String},
+	{Key: NotifySMTPPassword, Kind: String, Secret: true},
+	{Key: NotifyEmailFrom, Kind: String},
+	{Key: NotifyEmailTo, Kind: StringSlice},
+	{Key: NotifyEmailSubject, Kind: String},
+	{Key: NotifyEmailBody, Kind: String},
+
+	{Key: MetricsFormat, Kind: String, Default: "influx", Check: oneOf("influx", "statsd")},
+	{Key: MetricsURL, Kind: String, Check: urlScheme("udp", "http", "https")},
+	{Key: MetricsInterval, Kind: Duration, Default: 10 * time.Second, Check: positive},
+	{Key: MetricsPrefix, Kind: String, Default: "pegnetd"},
+
+	{Key: TracingEndpoint, Kind: String, Check: urlScheme("http", "https")},
+	{Key: TracingService, Kind: String, Default: "pegnetd"},
+	{Key: TracingInterval, Kind: Duration, Default: 5 * time.Second, Check: positive},
+
+	{Key: WalletAccounts, Kind: StringSlice, Env: "-"},
+	{Key: WalletAPIKeys, Kind: StringSlice, Env: "-", Secret: true},
+	{Key: WalletPolicies, Kind: StringSlice, Env: "-"},
+
+	{Key: SchedulerToken, Kind: String, Secret: true},
+	{Key: SchedulerPeriod, Kind: Duration, Default: 30 * time.Second, Check: positive},
+
+	{Key: APIWorkers, Kind: Uint, Default: 4, Check: positive},
+	{Key: APIWorkerWait, Kind: Duration, Default: 30 * time.Second, Check: positive},
+	{Key: APIGzip, Kind: Bool, Default: true},
+	{Key: APICacheSize, Kind: Uint, Default: 1000},
+	{Key: APIAdminToken, Kind: String, Secret: true},
+
+	{Key: ChaosFactomdTimeout, Kind: Float, Hidden: true, Check: probability},
+	{Key: ChaosFactomdMalformed, Kind: Float, Hidden: true, Check: probability},
+	{Key: ChaosDBWriteError, Kind: Float, Hidden: true, Check: probability},
+	{Key: ChaosCrash, Kind: Float, Hidden: true, Check: probability},
+	{Key: ChaosTimeoutDelay, Kind: Duration, Default: 5 * time.Second, Hidden: true, Check: positive},
+	{Key: ChaosSeed, Kind: Int, Hidden: true},
+
+	{Key: CustomSQLDBMode, Kind: String},
+	{Key: SQLDBWalMode, Kind: Bool, Default: false},
+	{Key: SlowQuery, Kind: Duration, Default: time.Second},
+	{Key: DBColumnKey, Kind: String, Secret: true, Check: columnKey},
+	{Key: DBColumnKeyCommand, Kind: String, Secret: true},
+}
+
+func init() {
+	for i := range Options {
+		if Options[i].Env == "" {
+			Options[i].Env = EnvPrefix + strings.ToUpper(strings.Replace(Options[i].Key, ".", "_", -1))
+		}
+	}
+}
+
+// combinations are the checks of options that depend on each other
+var combinations = []func(v *viper.Viper) error{
+	func(v *viper.Viper) error {
+		if v.GetString(NotifyTelegramToken) != "" && v.GetString(NotifyTelegramChat) == "" {
+			return fmt.Errorf("%s is required with %s", NotifyTelegramChat, NotifyTelegramToken)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(NotifySMTPServer) == "" {
+			return nil
+		}
+		if v.GetString(NotifyEmailFrom) == "" || len(v.GetStringSlice(NotifyEmailTo)) == 0 {
+			return fmt.Errorf("%s and %s are required with %s", NotifyEmailFrom, NotifyEmailTo, NotifySMTPServer)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(SchedulerToken) != "" && !HasECKey(v) {
+			return fmt.Errorf("%s, %s, %s, %s, or an EC key in %s is required to pay for the schedules of %s", ECPrivateKey, ECKeystore,
+				ECPrivateKeys, SignerECAddresses, PKCS11Keys, SchedulerToken)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(PKCS11Module) == "" && len(v.GetStringSlice(PKCS11Keys)) > 0 {
+			return fmt.Errorf("%s is required with %s", PKCS11Module, PKCS11Keys)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(SignerURL) == "" && len(v.GetStringSlice(SignerECAddresses)) > 0 {
+			return fmt.Errorf("%s is required with %s", SignerURL, SignerECAddresses)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(ECPrivateKey) != "" && v.GetString(ECKeystore) != "" {
+			return fmt.Errorf("%s and %s can't be combined", ECPrivateKey, ECKeystore)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		u, err := url.Parse(v.GetString(MetricsURL))
+		if err != nil || u.Scheme == "" || u.Scheme == "udp" || v.GetString(MetricsFormat) != "statsd" {
+			return nil
+		}
+		return fmt.Errorf("%s: statsd metrics are only sent over udp://", MetricsURL)
+	},
+	func(v *viper.Viper) error {
+		if v.GetBool(SQLDBWalMode) && strings.Contains(v.GetString(CustomSQLDBMode), "_journal") {
+			return fmt.Errorf("%s can't be combined with a journal mode in %s", SQLDBWalMode, CustomSQLDBMode)
+		}
+		return nil
+	},
+	func(v *viper.Viper) error {
+		if v.GetString(DBColumnKey) != "" && v.GetString(DBColumnKeyCommand) != "" {
+			return fmt.Errorf("%s can't be combined with %s", DBColumnKey, DBColumnKeyCommand)
+		}
+		return nil
+	},
+}
+
+// DecodeColumnKey decodes a column encryption key of 32 bytes, as 64 hex
+// characters or as base64
+func DecodeColumnKey(str string) ([]byte, error) {
+	str = strings.TrimSpace(str)
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(str); err != nil {
+			return nil, fmt.Errorf("expected hex or base64")
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("expected 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// HasECKey is true if an EC key pays for the entries of the node: a local
+// key, a key of the signer, or an EC key on the PKCS#11 token
+func HasECKey(v *viper.Viper) bool {
+	if v.GetString(ECPrivateKey) != "" || v.GetString(ECKeystore) != "" || len(v.GetStringSlice(ECPrivateKeys)) > 0 ||
+		len(v.GetStringSlice(SignerECAddresses)) > 0 {
+		return true
+	}
+	for _, key := range v.GetStringSlice(PKCS11Keys) {
+		if strings.HasPrefix(key, "EC") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDefaults sets the defaults of the options and binds their environment
+// variables. The environment overrides the config file, the flags override
+// the environment. Lists are separated by spaces in the environment.
+func SetDefaults(v *viper.Viper) {
+	for _, o := range Options {
+		if o.Default != nil {
+			v.SetDefault(o.Key, o.Default)
+		}
+		if o.Env != "-" {
+			_ = v.BindEnv(o.Key, o.Env)
+		}
+	}
+}
+
+// Validate checks the type and the value of every option, and the options
+// that depend on each other. All problems are returned in a single error.
+func Validate(v *viper.Viper) error {
+	var problems []string
+	for _, o := range Options {
+		value, err := o.parse(v.Get(o.Key))
+		if err == nil && o.Check != nil && !empty(value) {
+			err = o.Check(value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", o.Key, err))
+		}
+	}
+	// The combinations read the values, so they are only checked if the
+	// values are valid
+	if len(problems) == 0 {
+		for _, check := range combinations {
+			if err := check(v); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// Effective returns every option as "key = value", sorted by key. Secrets
+// are redacted, as are the passwords of urls.
+func Effective(v *viper.Viper) []string {
+	lines := make([]string, 0, len(Options))
+	for _, o := range Options {
+		value, err := o.parse(v.Get(o.Key))
+		if def, _ := o.parse(o.Default); o.Hidden && err == nil && reflect.DeepEqual(value, def) {
+			continue
+		}
+		var str string
+		switch {
+		case err != nil:
+			str = fmt.Sprintf("%v (invalid)", v.Get(o.Key))
+		case o.Secret && !empty(value):
+			str = "<redacted>"
+		case o.Kind == StringSlice:
+			items := value.([]string)
+			quoted := make([]string, len(items))
+			for i := range items {
+				quoted[i] = strconv.Quote(redactURL(items[i]))
+			}
+			str = "[" + strings.Join(quoted, ", ") + "]"
+		case o.Kind == String:
+			str = strconv.Quote(redactURL(value.(string)))
+		default:
+			str = fmt.Sprint(value)
+		}
+		if _, ok := os.LookupEnv(o.Env); ok && o.Env != "-" {
+			str += " (" + o.Env + ")"
+		}
+		lines = append(lines, o.Key+" = "+str)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// parse converts the value to the kind of the option. Values of the config
+// file and the environment are converted the same way viper does, plain
+// numbers are nanoseconds for durations.
+func (o Option) parse(value interface{}) (interface{}, error) {
+	switch o.Kind {
+	case String:
+		if value == nil {
+			return "", nil
+		}
+		switch value.(type) {
+		case []interface{}, []string, map[string]interface{}:
+			return nil, fmt.Errorf("must be a string")
+		}
+		return fmt.Sprint(value), nil
+	case Bool:
+		switch b := value.(type) {
+		case nil:
+			return false, nil
+		case bool:
+			return b, nil
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return nil, fmt.Errorf("must be true or false, not %q", b)
+			}
+			return parsed, nil
+		}
+	case Int, Uint:
+		var n int64
+		switch i := value.(type) {
+		case nil:
+		case int:
+			n = int64(i)
+		case int32:
+			n = int64(i)
+		case int64:
+			n = i
+		case uint32:
+			n = int64(i)
+		case uint64:
+			n = int64(i)
+		case float64:
+			if i != math.Trunc(i) {
+				return nil, fmt.Errorf("must be a whole number, not %v", i)
+			}
+			n = int64(i)
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(i), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number, not %q", i)
+			}
+			n = parsed
+		default:
+			return nil, fmt.Errorf("must be a number")
+		}
+		if o.Kind == Uint && n < 0 {
+			return nil, fmt.Errorf("can't be negative")
+		}
+		return n, nil
+	case Duration:
+		switch d := value.(type) {
+		case nil:
+			return time.Duration(0), nil
+		case time.Duration:
+			return d, nil
+		case int:
+			return time.Duration(d), nil
+		case int64:
+			return time.Duration(d), nil
+		case float64:
+			return time.Duration(d), nil
+		case string:
+			if n, err := strconv.ParseInt(d, 10, 64); err == nil {
+				return time.Duration(n), nil
+			}
+			parsed, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("must be a duration like \"30s\", not %q", d)
+			}
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("must be a duration like \"30s\"")
+	case Float:
+		switch f := value.(type) {
+		case nil:
+			return float64(0), nil
+		case float64:
+			return f, nil
+		case int:
+			return float64(f), nil
+		case int64:
+			return float64(f), nil
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return nil, fmt.Errorf("must be a number, not %q", f)
+			}
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("must be a number")
+	case StringSlice:
+		switch s := value.(type) {
+		case nil:
+			return []string{}, nil
+		case []string:
+			return s, nil
+		case string:
+			return strings.Fields(s), nil
+		case []interface{}:
+			items := make([]string, len(s))
+			for i := range s {
+				items[i] = fmt.Sprint(s[i])
+			}
+			return items, nil
+		}
+		return nil, fmt.Errorf("must be a list")
+	}
+	return nil, fmt.Errorf("must be a %s", o.Kind)
+}
+
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// redactURL replaces the password of a url
+func redactURL(str string) string {
+	u, err := url.Parse(str)
+	if err != nil || u.User == nil {
+		return str
+	}
+	if _, ok := u.User.Password(); !ok {
+		return str
+	}
+	u.User = url.UserPassword(u.User.Username(), "redacted")
+	return u.String()
+}
+
+func oneOf(values ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		str := strings.ToLower(value.(string))
+		for _, v := range values {
+			if str == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, not %q", strings.Join(values, ", "), value)
+	}
+}
+
+func each(check func(interface{}) error) func(interface{}) error {
+	return func(value interface{}) error {
+		for _, item := range value.([]string) {
+			if err := check(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func urlScheme(schemes ...string) func(interface{}) error {
+	return func(value interface{}) error {
+		u, err := url.Parse(value.(string))
+		if err != nil {
+			return err
+		}
+		for _, s := range schemes {
+			if u.Scheme == s {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: must start with %s://", value, strings.Join(schemes, "://, "))
+	}
+}
+
+func urlHost(value interface{}) error {
+	u, err := url.Parse(value.(string))
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%s: requires a host", value)
+	}
+	return nil
+}
+
+func positive(value interface{}) error {
+	switch v := value.(type) {
+	case int64:
+		if v > 0 {
+			return nil
+		}
+	case time.Duration:
+		if v > 0 {
+			return nil
+		}
+	case float64:
+		if v > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be more than 0")
+}
+
+// probability is a chance between 0 and 1
+func probability(value interface{}) error {
+	if p := value.(float64); p < 0 || p > 1 {
+		return fmt.Errorf("must be between 0 and 1")
+	}
+	return nil
+}
+
+// listenAddr is a port or a host and a port, like the api flag
+func listenAddr(value interface{}) error {
+	str := value.(string)
+	if !strings.Contains(str, ":") {
+		str = ":" + str
+	}
+	_, port, err := net.SplitHostPort(str)
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return fmt.Errorf("%q is not a valid port", port)
+	}
+	return nil
+}
+
+func hostPort(value interface{}) error {
+	if _, _, err := net.SplitHostPort(value.(string)); err != nil {
+		return fmt.Errorf("must be host:port, not %q", value)
+	}
+	return nil
+}
+
+func ecPrivateKey(value interface{}) error {
+	var es factom.EsAddress
+	if err := es.Set(value.(string)); err != nil {
+		return fmt.Errorf("not an entry credit private key: %v", err)
+	}
+	return nil
+}
+
+func ecAddress(value interface{}) error {
+	var ec factom.ECAddress
+	if err := ec.Set(value.(string)); err != nil {
+		return fmt.Errorf("%s: %v", value, err)
+	}
+	return nil
+}
+
+func stateHash(value interface{}) error {
+	var hash factom.Bytes32
+	if err := hash.Set(value.(string)); err != nil {
+		return fmt.Errorf("not a state hash: %v", err)
+	}
+	return nil
+}
+
+func columnKey(value interface{}) error {
+	if _, err := DecodeColumnKey(value.(string)); err != nil {
+		return fmt.Errorf("not a column key: %v", err)
+	}
+	return nil
+}
+
+func faAddress(value interface{}) error {
+	if _, err := factom.NewFAAddress(value.(string)); err != nil {
+		return fmt.Errorf("%s: %v", value, err)
+	}
+	return nil
+}
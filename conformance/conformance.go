@@ -0,0 +1,283 @@
+// Package conformance runs the conversion engine of pegnetd against golden
+// files. A golden file holds the rates and the pending conversions of one
+// height, and the outputs, PEG payouts, and refunds the reference behavior
+// yields for them. A change to the conversions or to the conversion limit
+// is checked against the corpus, and a protocol change that is agreed on is
+// recorded by regenerating the expected outputs.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/fat/fat2/validator"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// Case is the input of a golden file: the conversions executed at a height
+// of a network, at the rates of the height
+type Case struct {
+	Description string `json:"description"`
+	// Network is MainNet, RegTest, or the path of a network file,
+	// MainNet if empty. The activations of the network decide the rules.
+	Network string `json:"network,omitempty"`
+	Height  uint32 `json:"height"`
+	// Bank is the PEG the requests of the height share, the base bank if
+	// it is not set
+	Bank *uint64 `json:"bank,omitempty"`
+	// Rates are the pUSD rates of the height by ticker, assets left out
+	// have a rate of 0
+	Rates       map[string]uint64 `json:"rates"`
+	Conversions []Conversion      `json:"conversions"`
+}
+
+// Conversion is a pending conversion of a transaction batch
+type Conversion struct {
+	// TxID is the [TxIndex]-[EntryHash] of the conversion
+	TxID      string `json:"txid"`
+	Input     string `json:"input"`
+	Amount    uint64 `json:"amount"`
+	Output    string `json:"output"`
+	MinOutput uint64 `json:"minoutput,omitempty"`
+}
+
+// Result is the expected output of a golden file
+type Result struct {
+	// Bank is only set once the conversion limit is active
+	Bank        *Bank     `json:"bank,omitempty"`
+	Conversions []Outcome `json:"conversions"`
+}
+
+// Bank is the use of the bank by the PEG requests of the height
+type Bank struct {
+	Amount    uint64 `json:"amount"`
+	Requested uint64 `json:"requested"`
+	Paid      uint64 `json:"paid"`
+}
+
+// Outcome is the result of a conversion, in the order of the case
+type Outcome struct {
+	TxID string `json:"txid"`
+	// Rejected is the reason the conversion is not executed
+	Rejected string `json:"rejected,omitempty"`
+	Output   uint64 `json:"output"`
+	// Requested is the PEG a request yields without the conversion limit
+	Requested uint64 `json:"requested,omitempty"`
+	Refund    int64  `json:"refund,omitempty"`
+}
+
+// File is a golden file. Files without an expected result are filled in when
+// the corpus is regenerated.
+type File struct {
+	Case
+	Expected *Result `json:"expected,omitempty"`
+}
+
+// Run computes the result of the case with the rules of pegnetd. The
+// activations of the case network are applied while it runs, and the
+// network that was active before is applied again after.
+func Run(c Case) (*Result, error) {
+	network, err := node.LoadNetwork(c.Network)
+	if err != nil {
+		return nil, err
+	}
+	prev := node.ActiveNetwork
+	if err := network.Apply(); err != nil {
+		return nil, err
+	}
+	defer func() { _ = prev.Apply() }()
+
+	rates := make(map[fat2.PTicker]uint64, len(c.Rates))
+	for ticker, rate := range c.Rates {
+		t := fat2.StringToTicker(ticker)
+		if t == fat2.PTickerInvalid {
+			return nil, fmt.Errorf("rates: invalid ticker %q", ticker)
+		}
+		rates[t] = rate
+	}
+	limited := activation.Active(activation.ConversionLimit, c.Height)
+
+	res := &Result{Conversions: make([]Outcome, len(c.Conversions))}
+	var requests []node.PEGRequest
+	index := make(map[string]int)
+	for i, conv := range c.Conversions {
+		tx, err := conv.transaction()
+		if err != nil {
+			return nil, fmt.Errorf("conversion %d: %v", i, err)
+		}
+		if _, ok := index[conv.TxID]; ok {
+			return nil, fmt.Errorf("conversion %d: duplicate txid %s", i, conv.TxID)
+		}
+		index[conv.TxID] = i
+		res.Conversions[i].TxID = conv.TxID
+
+		// The input always covers the conversion, only the rates and the
+		// rules of the height can reject it
+		batch := &fat2.TransactionBatch{Transactions: []fat2.Transaction{tx}}
+		if err := validator.CheckBalances(funded{tx}, batch, c.Height, rates); err != nil {
+			res.Conversions[i].Rejected = err.Error()
+			continue
+		}
+
+		if limited && tx.IsPEGRequest() {
+			requests = append(requests, node.PEGRequest{TxID: conv.TxID, Tx: tx})
+			continue
+		}
+		output, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
+		if err != nil {
+			return nil, fmt.Errorf("conversion %d: %v", i, err)
+		}
+		res.Conversions[i].Output = uint64(output)
+	}
+
+	if limited {
+		bank := pegnet.BankBaseAmount
+		if c.Bank != nil {
+			bank = *c.Bank
+		}
+		payouts, requested, err := node.PayoutPEGRequests(requests, rates, bank)
+		if err != nil {
+			return nil, err
+		}
+		res.Bank = &Bank{Amount: bank, Requested: requested}
+		for _, payout := range payouts {
+			o := &res.Conversions[index[payout.TxID]]
+			o.Output, o.Requested, o.Refund = payout.Yield, payout.Requested, payout.Refund
+			res.Bank.Paid += payout.Yield
+		}
+	}
+	return res, nil
+}
+
+func (conv Conversion) transaction() (fat2.Transaction, error) {
+	var tx fat2.Transaction
+	if tx.Input.Type = fat2.StringToTicker(conv.Input); tx.Input.Type == fat2.PTickerInvalid {
+		return tx, fmt.Errorf("invalid input ticker %q", conv.Input)
+	}
+	if tx.Conversion = fat2.StringToTicker(conv.Output); tx.Conversion == fat2.PTickerInvalid {
+		return tx, fmt.Errorf("invalid output ticker %q", conv.Output)
+	}
+	tx.Input.Amount = conv.Amount
+	tx.MinOutput = conv.MinOutput
+	return tx, nil
+}
+
+// funded is a balance source where the input address holds the input of the
+// conversion
+type funded struct {
+	tx fat2.Transaction
+}
+
+func (f funded) Balances(factom.FAAddress) (map[fat2.PTicker]uint64, error) {
+	return map[fat2.PTicker]uint64{f.tx.Input.Type: f.tx.Input.Amount}, nil
+}
+
+// Verify runs the case of the file and returns an error if the result is not
+// the expected one
+func (f *File) Verify() error {
+	if f.Expected == nil {
+		return fmt.Errorf("the file has no expected result")
+	}
+	res, err := Run(f.Case)
+	if err != nil {
+		return err
+	}
+	return diff(f.Expected, res)
+}
+
+// diff describes the first difference of the results
+func diff(expected, actual *Result) error {
+	if !reflect.DeepEqual(expected.Bank, actual.Bank) {
+		return fmt.Errorf("bank: expected %s, got %s", marshal(expected.Bank), marshal(actual.Bank))
+	}
+	if len(expected.Conversions) != len(actual.Conversions) {
+		return fmt.Errorf("expected %d conversions, got %d", len(expected.Conversions), len(actual.Conversions))
+	}
+	for i := range expected.Conversions {
+		if expected.Conversions[i] != actual.Conversions[i] {
+			return fmt.Errorf("conversion %d: expected %s, got %s", i, marshal(expected.Conversions[i]), marshal(actual.Conversions[i]))
+		}
+	}
+	return nil
+}
+
+func marshal(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// Regenerate runs the case of every golden file of the directory and writes
+// its result as the expected one. Returns the names of the files whose
+// expected result changed.
+func Regenerate(dir string) ([]string, error) {
+	files, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, name := range Names(files) {
+		f := files[name]
+		res, err := Run(f.Case)
+		if err != nil {
+			return changed, fmt.Errorf("%s: %v", name, err)
+		}
+		if f.Expected != nil && diff(f.Expected, res) == nil {
+			continue
+		}
+		f.Expected = res
+		if err := Write(filepath.Join(dir, name), f); err != nil {
+			return changed, err
+		}
+		changed = append(changed, name)
+	}
+	return changed, nil
+}
+
+// Load reads the golden files of the directory, by file name
+func Load(dir string) (map[string]*File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*File, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f := new(File)
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		files[filepath.Base(path)] = f
+	}
+	return files, nil
+}
+
+// Names returns the file names of the corpus in order
+func Names(files map[string]*File) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write writes the golden file to the path
+func Write(path string, f *File) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,89 @@
+package conformance
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGolden(t *testing.T) {
+	files, err := Load("testdata")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, name := range Names(files) {
+		f := files[name]
+		t.Run(name, func(t *testing.T) {
+			require.NotNil(t, f.Expected, "run 'pegnetd gen-golden' to fill in the expected result")
+			require.NoError(t, f.Verify())
+		})
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-golden")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files, err := Load("testdata")
+	require.NoError(t, err)
+	f := files["limit-oversubscribed.json"]
+	require.NotNil(t, f)
+
+	// A new file, and a file with an outdated result
+	fresh := *f
+	fresh.Expected = nil
+	require.NoError(t, Write(filepath.Join(dir, "fresh.json"), &fresh))
+	outdated := *f
+	outdated.Expected = &Result{Bank: f.Expected.Bank, Conversions: append([]Outcome{}, f.Expected.Conversions...)}
+	outdated.Expected.Conversions[0].Refund++
+	require.NoError(t, Write(filepath.Join(dir, "outdated.json"), &outdated))
+	require.Error(t, outdated.Verify())
+
+	changed, err := Regenerate(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fresh.json", "outdated.json"}, changed)
+
+	regenerated, err := Load(dir)
+	require.NoError(t, err)
+	for _, name := range changed {
+		require.Equal(t, f.Expected, regenerated[name].Expected)
+	}
+
+	changed, err = Regenerate(dir)
+	require.NoError(t, err)
+	require.Empty(t, changed)
+}
+
+func TestNetworkFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-network")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files, err := Load("testdata")
+	require.NoError(t, err)
+	f := files["limit-oversubscribed.json"]
+	require.NotNil(t, f)
+
+	// A network file overrides only the activations it sets of its base
+	write := func(name, activations string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"name": "MainNet", "base": "MainNet",
+			"burnaddress": "EC2BURNFCT2PEGNETooo1oooo1oooo1oooo1oooo1oooo19wthin", "activations": `+activations+`}`), 0644))
+		return path
+	}
+
+	c := f.Case
+	c.Network = write("base.json", `{}`)
+	res, err := Run(c)
+	require.NoError(t, err)
+	require.Equal(t, f.Expected, res)
+
+	c.Network = write("nolimit.json", `{"conversionlimit": 4294967295}`)
+	res, err = Run(c)
+	require.NoError(t, err)
+	require.Nil(t, res.Bank, "the conversion limit is not active on the network")
+}
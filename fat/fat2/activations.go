@@ -1,7 +0,0 @@
-package fat2
-
-var (
-	// Fat2RCDEActivation is when rcd type 0x0e is valid and accepted.
-	// Estimated to be  Feb 12, 2020, 18:00 UTC
-	Fat2RCDEActivation uint32 = 231620
-)
@@ -0,0 +1,163 @@
+package fat2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pegnet/pegnetd/activation"
+)
+
+// Asset is a PegNet asset and the height it is tracked from
+type Asset struct {
+	Ticker PTicker
+	Name   string
+	// Currency is the ISO 4217 code of a pegged fiat or metal, empty for
+	// assets without a currency
+	Currency   string
+	Activation uint32
+}
+
+// IsActive returns true if the asset is tracked at the height. Batches of an
+// asset that is not active yet are still valid, they are rejected by the
+// rates and the balances of the height.
+func (a Asset) IsActive(height uint32) bool {
+	return a.Activation <= height
+}
+
+// assets is the registry of all assets, the ticker of an asset is its index
+// + 1. New assets can only be appended, the tickers are stored in the
+// database.
+var assets []Asset
+
+var validPTickers = make(map[string]PTicker)
+
+func init() {
+	// The V4 assets are tracked from the V4 update of MainNet until
+	// SetAssetActivations sets the update of the network
+	v4 := activation.MainNet.V4OPRUpdate
+	for _, a := range []Asset{
+		{Name: "PEG"},
+		{Name: "pUSD", Currency: "USD"},
+		{Name: "pEUR", Currency: "EUR"},
+		{Name: "pJPY", Currency: "JPY"},
+		{Name: "pGBP", Currency: "GBP"},
+		{Name: "pCAD", Currency: "CAD"},
+		{Name: "pCHF", Currency: "CHF"},
+		{Name: "pINR", Currency: "INR"},
+		{Name: "pSGD", Currency: "SGD"},
+		{Name: "pCNY", Currency: "CNY"},
+		{Name: "pHKD", Currency: "HKD"},
+		{Name: "pKRW", Currency: "KRW"},
+		{Name: "pBRL", Currency: "BRL"},
+		{Name: "pPHP", Currency: "PHP"},
+		{Name: "pMXN", Currency: "MXN"},
+		{Name: "pXAU", Currency: "XAU"},
+		{Name: "pXAG", Currency: "XAG"},
+		{Name: "pXBT"},
+		{Name: "pETH"},
+		{Name: "pLTC"},
+		{Name: "pRVN"},
+		{Name: "pXBC"},
+		{Name: "pFCT"},
+		{Name: "pBNB"},
+		{Name: "pXLM"},
+		{Name: "pADA"},
+		{Name: "pXMR"},
+		{Name: "pDASH"},
+		{Name: "pZEC"},
+		{Name: "pDCR"},
+		// V4 Additions
+		{Name: "pAUD", Currency: "AUD", Activation: v4},
+		{Name: "pNZD", Currency: "NZD", Activation: v4},
+		{Name: "pSEK", Currency: "SEK", Activation: v4},
+		{Name: "pNOK", Currency: "NOK", Activation: v4},
+		{Name: "pRUB", Currency: "RUB", Activation: v4},
+		{Name: "pZAR", Currency: "ZAR", Activation: v4},
+		{Name: "pTRY", Currency: "TRY", Activation: v4},
+		{Name: "pEOS", Activation: v4},
+		{Name: "pLINK", Activation: v4},
+		{Name: "pATOM", Activation: v4},
+		{Name: "pBAT", Activation: v4},
+		{Name: "pXTZ", Activation: v4},
+	} {
+		if _, err := RegisterAsset(a.Name, a.Currency, a.Activation); err != nil {
+			panic(err)
+		}
+	}
+	if PTickerMax != ptickerBuiltin {
+		panic("the asset registry does not match the PTicker constants")
+	}
+}
+
+// RegisterAsset adds a new asset tracked from the activation height and
+// returns its ticker. Assets must be registered before the database is
+// opened, and in the order of their activation.
+func RegisterAsset(name, currency string, activation uint32) (PTicker, error) {
+	if name != "PEG" && (len(name) < 3 || name[0] != 'p') {
+		return PTickerInvalid, fmt.Errorf("invalid asset name %q, must start with a 'p'", name)
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return PTickerInvalid, fmt.Errorf("invalid asset name %q, must be alphanumeric", name)
+		}
+	}
+	if _, ok := validPTickers[name]; ok {
+		return PTickerInvalid, fmt.Errorf("asset %s is already registered", name)
+	}
+	for _, a := range assets {
+		if strings.EqualFold(a.Name, name) {
+			return PTickerInvalid, fmt.Errorf("asset %s conflicts with %s", name, a.Name)
+		}
+	}
+	if len(assets) > 0 && activation < assets[len(assets)-1].Activation {
+		return PTickerInvalid, fmt.Errorf("asset %s activates before %s", name, assets[len(assets)-1].Name)
+	}
+
+	ticker := PTicker(len(assets) + 1)
+	assets = append(assets, Asset{Ticker: ticker, Name: name, Currency: currency, Activation: activation})
+	validPTickers[name] = ticker
+	PTickerMax = ticker + 1
+	return ticker, nil
+}
+
+// ParseAsset parses an asset from the config, formatted as
+// "<name> <activation> [currency]", eg: "pXAF 250000 XAF"
+func ParseAsset(str string) (Asset, error) {
+	fields := strings.Fields(str)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Asset{}, fmt.Errorf("asset %q must be \"<name> <activation> [currency]\"", str)
+	}
+	act, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return Asset{}, fmt.Errorf("invalid activation height %q", fields[1])
+	}
+	a := Asset{Name: fields[0], Activation: uint32(act)}
+	if len(fields) == 3 {
+		a.Currency = strings.ToUpper(fields[2])
+	}
+	return a, nil
+}
+
+// SetAssetActivations sets the activation of all assets that are not tracked
+// from the start, used for testing
+func SetAssetActivations(act uint32) {
+	for i := range assets {
+		if assets[i].Activation > 0 {
+			assets[i].Activation = act
+		}
+	}
+}
+
+// Assets returns all registered assets, ordered by their ticker
+func Assets() []Asset {
+	return append([]Asset(nil), assets...)
+}
+
+// Asset returns the registry entry of the ticker
+func (t PTicker) Asset() (Asset, bool) {
+	if t <= PTickerInvalid || PTickerMax <= t {
+		return Asset{}, false
+	}
+	return assets[int(t)-1], true
+}
@@ -0,0 +1,55 @@
+package fat2_test
+
+import (
+	"testing"
+
+	"github.com/pegnet/pegnetd/activation"
+	. "github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssets(t *testing.T) {
+	assets := Assets()
+	require.Len(t, assets, int(PTickerMax)-1)
+	for i, a := range assets {
+		assert.Equal(t, PTicker(i+1), a.Ticker)
+		assert.Equal(t, a.Name, a.Ticker.String())
+		assert.Equal(t, a.Ticker, StringToTicker(a.Name))
+	}
+
+	a, ok := PTickerEUR.Asset()
+	assert.True(t, ok)
+	assert.Equal(t, "EUR", a.Currency)
+	_, ok = PTickerInvalid.Asset()
+	assert.False(t, ok)
+
+	for _, a := range assets {
+		assert.Equal(t, a.Ticker <= PTickerDCR, a.IsActive(0), a.Name)
+		assert.True(t, a.IsActive(activation.MainNet.V4OPRUpdate), a.Name)
+	}
+}
+
+func TestRegisterAssetInvalid(t *testing.T) {
+	for _, name := range []string{"pUSD", "pusd", "XAF", "p", "pX-F"} {
+		_, err := RegisterAsset(name, "", activation.MainNet.V4OPRUpdate)
+		assert.Error(t, err, name)
+	}
+	_, err := RegisterAsset("pXAF", "XAF", 0)
+	assert.Error(t, err, "activates before the last asset")
+}
+
+func TestParseAsset(t *testing.T) {
+	a, err := ParseAsset("pXAF 250000 xaf")
+	require.NoError(t, err)
+	assert.Equal(t, Asset{Name: "pXAF", Currency: "XAF", Activation: 250000}, a)
+
+	a, err = ParseAsset("pDOT 250000")
+	require.NoError(t, err)
+	assert.Equal(t, Asset{Name: "pDOT", Activation: 250000}, a)
+
+	for _, str := range []string{"pDOT", "pDOT -1", "pDOT 1 DOT extra"} {
+		_, err := ParseAsset(str)
+		assert.Error(t, err, str)
+	}
+}
@@ -0,0 +1,287 @@
+package fat2
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+const (
+	// RCDTypeMultisig is the type byte of a M-of-N ed25519 RCD
+	RCDTypeMultisig byte = 0x02
+	// MaxMultisigKeys is the maximum N of a multisignature RCD
+	MaxMultisigKeys = 16
+
+	multisigSigSize = 1 + ed25519.SignatureSize
+)
+
+// RCDMultisig is a set of N ed25519 public keys of which M have to sign a
+// transaction. The address of the input is the sha256d of the RCD, like any
+// other RCD. The RCD is encoded as
+//
+// [Type byte (0x02)] + [M (1 byte)] + [N (1 byte)] + [N ed25519 pubkeys (32 bytes each)]
+//
+// The signature block is M or more signatures with strictly increasing key
+// indexes:
+//
+// [key index (1 byte)] + [ed25519 signature (64 bytes)]
+type RCDMultisig struct {
+	M    int
+	Keys []ed25519.PublicKey
+}
+
+// NewRCDMultisig returns a M-of-N RCD of the keys, in the given order
+func NewRCDMultisig(m int, keys ...ed25519.PublicKey) (*RCDMultisig, error) {
+	r := &RCDMultisig{M: m, Keys: keys}
+	if err := r.valid(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RCDMultisig) valid() error {
+	if len(r.Keys) == 0 || len(r.Keys) > MaxMultisigKeys {
+		return fmt.Errorf("a multisig rcd requires 1 to %d keys", MaxMultisigKeys)
+	}
+	if r.M < 1 || r.M > len(r.Keys) {
+		return fmt.Errorf("the required signatures must be between 1 and %d", len(r.Keys))
+	}
+	for i, key := range r.Keys {
+		if len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("key %d: invalid public key size", i)
+		}
+		for _, other := range r.Keys[:i] {
+			if bytes.Equal(key, other) {
+				return fmt.Errorf("key %d: duplicate public key", i)
+			}
+		}
+	}
+	return nil
+}
+
+// RCD returns the binary encoding of the RCD
+func (r *RCDMultisig) RCD() []byte {
+	data := make([]byte, 3, 3+len(r.Keys)*ed25519.PublicKeySize)
+	data[0] = RCDTypeMultisig
+	data[1] = byte(r.M)
+	data[2] = byte(len(r.Keys))
+	for _, key := range r.Keys {
+		data = append(data, key...)
+	}
+	return data
+}
+
+// UnmarshalBinary decodes an encoded RCD
+func (r *RCDMultisig) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != RCDTypeMultisig {
+		return fmt.Errorf("invalid RCD type")
+	}
+	n := int(data[2])
+	if len(data) != 3+n*ed25519.PublicKeySize {
+		return fmt.Errorf("invalid RCD size")
+	}
+	r.M = int(data[1])
+	r.Keys = make([]ed25519.PublicKey, n)
+	for i := range r.Keys {
+		start := 3 + i*ed25519.PublicKeySize
+		r.Keys[i] = ed25519.PublicKey(append([]byte{}, data[start:start+ed25519.PublicKeySize]...))
+	}
+	return r.valid()
+}
+
+// Address is the address of the inputs signed by the RCD
+func (r *RCDMultisig) Address() factom.FAAddress {
+	first := sha256.Sum256(r.RCD())
+	return factom.FAAddress(sha256.Sum256(first[:]))
+}
+
+// Index returns the index of the key, or -1 if it is not part of the RCD
+func (r *RCDMultisig) Index(key ed25519.PublicKey) int {
+	for i, k := range r.Keys {
+		if bytes.Equal(k, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate returns nil if the signature block contains at least M valid
+// signatures of the message
+func (r *RCDMultisig) Validate(msg, sig []byte) error {
+	if len(sig) == 0 || len(sig)%multisigSigSize != 0 {
+		return fmt.Errorf("invalid signature size")
+	}
+	count := len(sig) / multisigSigSize
+	if count < r.M {
+		return fmt.Errorf("%d of %d required signatures", count, r.M)
+	}
+	last := -1
+	for i := 0; i < count; i++ {
+		s := sig[i*multisigSigSize : (i+1)*multisigSigSize]
+		index := int(s[0])
+		if index <= last || index >= len(r.Keys) {
+			return fmt.Errorf("signature %d: invalid key index", i)
+		}
+		if !ed25519.Verify(r.Keys[index], msg, s[1:]) {
+			return fmt.Errorf("signature %d: invalid signature", i)
+		}
+		last = index
+	}
+	return nil
+}
+
+// validateMultisigExtIDs is fat103.Validate with support for multisig RCDs.
+// The other RCD types are validated by the factom library.
+func validateMultisigExtIDs(e factom.Entry, expected map[factom.Bytes32]struct{}, flag int) error {
+	if len(expected) == 0 || len(e.ExtIDs) != 2*len(expected)+1 {
+		return fmt.Errorf("invalid number of ExtIDs")
+	}
+
+	sec, err := strconv.ParseInt(string(e.ExtIDs[0]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("ExtIDs[0]: timestamp salt: %w", err)
+	}
+	diff := e.Timestamp.Sub(time.Unix(sec, 0))
+	if -12*time.Hour > diff || diff > 12*time.Hour {
+		return fmt.Errorf("ExtIDs[0]: timestamp salt: expired")
+	}
+
+	rcdSigs := e.ExtIDs[1:]
+	for i := 0; i < len(rcdSigs); i += 2 {
+		rcd, sig := rcdSigs[i], rcdSigs[i+1]
+		msgHash := multisigMessage(i/2, e)
+
+		var rcdHash factom.Bytes32
+		if len(rcd) > 0 && rcd[0] == RCDTypeMultisig {
+			var multisig RCDMultisig
+			if err := multisig.UnmarshalBinary(rcd); err != nil {
+				return fmt.Errorf("ExtIDs[%v]: %w", i+1, err)
+			}
+			if err := multisig.Validate(msgHash, sig); err != nil {
+				return fmt.Errorf("ExtIDs[%v]: %w", i+1, err)
+			}
+			rcdHash = factom.Bytes32(multisig.Address())
+		} else if rcdHash, err = factom.ValidateRCD(rcd, sig, msgHash, flag); err != nil {
+			return fmt.Errorf("ExtIDs[%v]: %w", i+1, err)
+		}
+
+		if _, ok := expected[rcdHash]; !ok {
+			return fmt.Errorf("ExtIDs[%v]: unexpected or duplicate RCD Hash", i+1)
+		}
+		delete(expected, rcdHash)
+	}
+	return nil
+}
+
+// multisigMessage is the hash signed by the RCD/signature pair according
+// to fat103: sha512(rcdSigID + timestamp salt + chain id + content)
+func multisigMessage(rcdSigID int, e factom.Entry) []byte {
+	salt := strconv.Itoa(rcdSigID)
+	msg := make([]byte, 0, len(salt)+len(e.ExtIDs[0])+len(e.ChainID)+len(e.Content))
+	msg = append(msg, salt...)
+	msg = append(msg, e.ExtIDs[0]...)
+	msg = append(msg, e.ChainID[:]...)
+	msg = append(msg, e.Content...)
+	hash := sha512.Sum512(msg)
+	return hash[:]
+}
+
+// MultisigSigner is a key that can sign for a multisig RCD, eg: a
+// factom.FsAddress
+type MultisigSigner interface {
+	PublicKey() ed25519.PublicKey
+	Sign(msg []byte) []byte
+}
+
+// MultisigTransaction is a transaction batch with a multisig input that is
+// signed one key at a time, so the signatures can be gathered across
+// machines. The timestamp salt expires 12 hours after it was created.
+type MultisigTransaction struct {
+	ChainID       *factom.Bytes32      `json:"chainid"`
+	Content       factom.Bytes         `json:"content"`
+	TimestampSalt string               `json:"timestampsalt"`
+	RCD           factom.Bytes         `json:"rcd"`
+	Signatures    map[int]factom.Bytes `json:"signatures"`
+}
+
+// NewMultisigTransaction returns an unsigned transaction of the batch. The
+// batch must have exactly one input: the address of the rcd.
+func NewMultisigTransaction(batch *TransactionBatch, chainID *factom.Bytes32, rcd *RCDMultisig) (*MultisigTransaction, error) {
+	if err := batch.ValidData(); err != nil {
+		return nil, err
+	}
+	if batch.Transactions[0].Input.Address != rcd.Address() {
+		return nil, fmt.Errorf("the input is not the address of the multisig rcd")
+	}
+	content, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigTransaction{
+		ChainID:       chainID,
+		Content:       content,
+		TimestampSalt: strconv.FormatInt(time.Now().Unix(), 10),
+		RCD:           rcd.RCD(),
+		Signatures:    make(map[int]factom.Bytes),
+	}, nil
+}
+
+func (m *MultisigTransaction) entry() factom.Entry {
+	return factom.Entry{ChainID: m.ChainID, Content: m.Content, ExtIDs: []factom.Bytes{factom.Bytes(m.TimestampSalt)}}
+}
+
+// Multisig returns the decoded RCD
+func (m *MultisigTransaction) Multisig() (*RCDMultisig, error) {
+	rcd := new(RCDMultisig)
+	if err := rcd.UnmarshalBinary(m.RCD); err != nil {
+		return nil, err
+	}
+	return rcd, nil
+}
+
+// Sign adds the signature of the key
+func (m *MultisigTransaction) Sign(key MultisigSigner) error {
+	rcd, err := m.Multisig()
+	if err != nil {
+		return err
+	}
+	index := rcd.Index(key.PublicKey())
+	if index < 0 {
+		return fmt.Errorf("the key is not part of the multisig rcd")
+	}
+	if m.Signatures == nil {
+		m.Signatures = make(map[int]factom.Bytes)
+	}
+	m.Signatures[index] = key.Sign(multisigMessage(0, m.entry()))
+	return nil
+}
+
+// Entry returns the entry of the fully signed transaction
+func (m *MultisigTransaction) Entry() (factom.Entry, error) {
+	rcd, err := m.Multisig()
+	if err != nil {
+		return factom.Entry{}, err
+	}
+	if len(m.Signatures) < rcd.M {
+		return factom.Entry{}, fmt.Errorf("%d of %d required signatures", len(m.Signatures), rcd.M)
+	}
+
+	var sig []byte
+	for i := range rcd.Keys {
+		if s, ok := m.Signatures[i]; ok {
+			sig = append(sig, byte(i))
+			sig = append(sig, s...)
+		}
+	}
+	e := m.entry()
+	e.ExtIDs = append(e.ExtIDs, m.RCD, sig)
+	e.Timestamp = time.Now()
+	return e, nil
+}
@@ -0,0 +1,65 @@
+package fat2_test
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	. "github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultisigTransaction(t *testing.T) {
+	keys := make([]factom.FsAddress, 3)
+	pubs := make([]ed25519.PublicKey, 3)
+	for i := range keys {
+		keys[i][0] = byte(i + 1)
+		pubs[i] = keys[i].PublicKey()
+	}
+	rcd, err := NewRCDMultisig(2, pubs...)
+	require.NoError(t, err)
+
+	_, err = NewRCDMultisig(4, pubs...)
+	assert.Error(t, err, "more required signatures than keys")
+	_, err = NewRCDMultisig(1, pubs[0], pubs[0])
+	assert.Error(t, err, "duplicate keys")
+
+	var decoded RCDMultisig
+	require.NoError(t, decoded.UnmarshalBinary(rcd.RCD()))
+	assert.Equal(t, rcd.Address(), decoded.Address())
+
+	var chain factom.Bytes32
+	batch := &TransactionBatch{Version: 1, Transactions: []Transaction{{
+		Input:     TypedAddressAmountTuple{Address: rcd.Address(), Amount: 10, Type: PTickerPEG},
+		Transfers: []AddressAmountTuple{{Address: keys[0].FAAddress(), Amount: 10}},
+	}}}
+	msig, err := NewMultisigTransaction(batch, &chain, rcd)
+	require.NoError(t, err)
+
+	var outsider factom.FsAddress
+	outsider[0] = 9
+	assert.Error(t, msig.Sign(outsider))
+
+	require.NoError(t, msig.Sign(keys[2]))
+	_, err = msig.Entry()
+	assert.EqualError(t, err, "1 of 2 required signatures")
+	require.NoError(t, msig.Sign(keys[0]))
+
+	entry, err := msig.Entry()
+	require.NoError(t, err)
+	signed := TransactionBatch{Entry: entry}
+	require.NoError(t, signed.UnmarshalJSON(entry.Content))
+	require.NoError(t, signed.Validate(-1))
+
+	// Multisig RCDs are rejected before the activation
+	defer activation.Set(activation.Current())
+	activation.Set(activation.Current().With(activation.Multisig, 100))
+	assert.Error(t, signed.Validate(50))
+	assert.NoError(t, signed.Validate(101))
+
+	// A tampered signature is invalid
+	entry.ExtIDs[2][10] ^= 0xff
+	assert.Error(t, signed.Validate(101))
+}
@@ -54,62 +54,13 @@ const (
 	PTickerATOM
 	PTickerBAT
 	PTickerXTZ
-	PTickerMax
+	// ptickerBuiltin is the end of the tickers defined in code, later tickers
+	// come from RegisterAsset
+	ptickerBuiltin
 )
 
-var validPTickerStrings = []string{
-	"PEG",
-	"pUSD",
-	"pEUR",
-	"pJPY",
-	"pGBP",
-	"pCAD",
-	"pCHF",
-	"pINR",
-	"pSGD",
-	"pCNY",
-	"pHKD",
-	"pKRW",
-	"pBRL",
-	"pPHP",
-	"pMXN",
-	"pXAU",
-	"pXAG",
-	"pXBT",
-	"pETH",
-	"pLTC",
-	"pRVN",
-	"pXBC",
-	"pFCT",
-	"pBNB",
-	"pXLM",
-	"pADA",
-	"pXMR",
-	"pDASH",
-	"pZEC",
-	"pDCR",
-	// V4 Additions
-	"pAUD",
-	"pNZD",
-	"pSEK",
-	"pNOK",
-	"pRUB",
-	"pZAR",
-	"pTRY",
-	"pEOS",
-	"pLINK",
-	"pATOM",
-	"pBAT",
-	"pXTZ",
-}
-
-var validPTickers = func() map[string]PTicker {
-	pTickers := make(map[string]PTicker, len(validPTickerStrings))
-	for i, str := range validPTickerStrings {
-		pTickers[str] = PTicker(i + 1)
-	}
-	return pTickers
-}()
+// PTickerMax is one past the last registered ticker
+var PTickerMax = PTickerInvalid + 1
 
 func StringToTicker(str string) PTicker {
 	return validPTickers[str]
@@ -151,5 +102,5 @@ func (t PTicker) String() string {
 	if t <= PTickerInvalid || PTickerMax <= t {
 		return fmt.Errorf("invalid token type").Error()
 	}
-	return validPTickerStrings[int(t)-1]
+	return assets[int(t)-1].Name
 }
@@ -86,7 +86,11 @@ type Transaction struct {
 	Input      TypedAddressAmountTuple `json:"input"`
 	Transfers  []AddressAmountTuple    `json:"transfers,omitempty"`
 	Conversion PTicker                 `json:"conversion,omitempty"`
-	Metadata   interface{}             `json:"metadata,omitempty"`
+	// MinOutput is the minimum amount a conversion has to yield at the rates
+	// of its execution height. Conversions below it are rejected, leaving the
+	// input untouched. 0 means any output is accepted.
+	MinOutput uint64      `json:"minoutput,omitempty"`
+	Metadata  interface{} `json:"metadata,omitempty"`
 }
 
 // UnmarshalJSON unmarshals the bytes of JSON into a Transaction
@@ -97,6 +101,7 @@ func (t *Transaction) UnmarshalJSON(data []byte) error {
 		Input      json.RawMessage `json:"input"`
 		Transfers  json.RawMessage `json:"transfers,omitempty"`
 		Conversion json.RawMessage `json:"conversion,omitempty"`
+		MinOutput  json.RawMessage `json:"minoutput,omitempty"`
 		Metadata   json.RawMessage `json:"metadata,omitempty"`
 	}{}
 	if err := json.Unmarshal(data, &tRaw); err != nil {
@@ -115,12 +120,20 @@ func (t *Transaction) UnmarshalJSON(data []byte) error {
 			return fmt.Errorf("%T.Conversion: %v", t, err)
 		}
 	}
+	if 0 < len(tRaw.MinOutput) {
+		if err := json.Unmarshal(tRaw.MinOutput, &t.MinOutput); err != nil {
+			return fmt.Errorf("%T.MinOutput: %v", t, err)
+		}
+	}
 	t.Metadata = tRaw.Metadata
 
 	var expectedJSONLen int
 	if tRaw.Metadata != nil {
 		expectedJSONLen += len(`,"metadata":`) + len(tRaw.Metadata)
 	}
+	if tRaw.MinOutput != nil {
+		expectedJSONLen += len(`,"minoutput":`) + len(tRaw.MinOutput)
+	}
 	if t.IsConversion() {
 		expectedJSONLen += len(`{"input":,"conversion":}`) +
 			len(tRaw.Input) + len(tRaw.Conversion)
@@ -167,6 +180,30 @@ func (t *Transaction) Validate() error {
 	if t.IsConversion() && t.Input.Type == t.Conversion {
 		return fmt.Errorf("conversion cannot to be the same type")
 	}
+	if t.MinOutput > 0 && !t.IsConversion() {
+		return fmt.Errorf("minimum output requires a conversion")
+	}
+	return nil
+}
+
+// MaxMetadataLength is the maximum length of the json encoded metadata of a
+// transaction composed or submitted through pegnetd. It is not a consensus
+// rule, transactions with larger metadata are still applied.
+const MaxMetadataLength = 512
+
+// ValidMetadata returns an error if the json encoded metadata is longer
+// than MaxMetadataLength
+func (t *Transaction) ValidMetadata() error {
+	if t.Metadata == nil {
+		return nil
+	}
+	data, err := json.Marshal(t.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid metadata: %v", err)
+	}
+	if len(data) > MaxMetadataLength {
+		return fmt.Errorf("metadata is %d bytes, the maximum is %d", len(data), MaxMetadataLength)
+	}
 	return nil
 }
 
@@ -2,6 +2,8 @@ package fat2_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	. "github.com/pegnet/pegnetd/fat/fat2"
@@ -122,6 +124,22 @@ var transactionUnmarshalJSONTests = []struct {
 		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
 		"conversion": "pUSD"
 	}`,
+}, {
+	Name: "valid conversion with minimum output",
+	TxJSON: `{
+		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+		"conversion": "pUSD",
+		"minoutput": 10
+	}`,
+}, {
+	Name:  "double minimum output",
+	Error: "*fat2.Transaction: unexpected JSON length",
+	TxJSON: `{
+		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+		"conversion": "pUSD",
+		"minoutput": 10,
+		"minoutput": 10
+	}`,
 }, {
 	Name:   "empty",
 	Error:  "*fat2.Transaction.Input: unexpected end of JSON input",
@@ -245,6 +263,14 @@ var transactionValidationTests = []struct {
 		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
 		"transfers": [{"address": "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC", "amount": 25}]
 	}`,
+}, {
+	Name:  "minimum output of a transfer",
+	Error: "minimum output requires a conversion",
+	TxJSON: `{
+		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+		"transfers": [{"address": "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC", "amount": 50}],
+		"minoutput": 10
+	}`,
 }}
 
 // TestTransaction_Validate tests that the Validate function on a Transaction struct is able to catch all
@@ -265,3 +291,14 @@ func TestTransaction_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestTransaction_ValidMetadata(t *testing.T) {
+	var tx Transaction
+	require.NoError(t, tx.ValidMetadata())
+
+	tx.Metadata = json.RawMessage(`{"memo":"deposit 1234"}`)
+	require.NoError(t, tx.ValidMetadata())
+
+	tx.Metadata = strings.Repeat("a", MaxMetadataLength)
+	assert.EqualError(t, tx.ValidMetadata(), fmt.Sprintf("metadata is %d bytes, the maximum is %d", MaxMetadataLength+2, MaxMetadataLength))
+}
@@ -1,12 +1,16 @@
 package fat2
 
 import (
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/Factom-Asset-Tokens/factom/fat103"
 	"github.com/Factom-Asset-Tokens/factom/jsonlen"
+	"github.com/pegnet/pegnetd/activation"
 )
 
 // TransactionBatch represents a fat2 entry, which can be a list of one or more
@@ -14,6 +18,9 @@ import (
 type TransactionBatch struct {
 	Version      uint          `json:"version"`
 	Transactions []Transaction `json:"transactions"`
+	// NotBefore is the first height the batch can be executed at. Batches
+	// entered before it stay pending until then. 0 means no time lock.
+	NotBefore uint32 `json:"notbefore,omitempty"`
 
 	Metadata json.RawMessage `json:"metadata,omitempty"`
 	Entry    factom.Entry    `json:"-"`
@@ -46,6 +53,7 @@ func (t *TransactionBatch) UnmarshalJSON(data []byte) error {
 	tRaw := struct {
 		Version      json.RawMessage `json:"version"`
 		Transactions json.RawMessage `json:"transactions"`
+		NotBefore    json.RawMessage `json:"notbefore,omitempty"`
 		Metadata     json.RawMessage `json:"metadata,omitempty"`
 	}{}
 	if err := json.Unmarshal(data, &tRaw); err != nil {
@@ -62,6 +70,12 @@ func (t *TransactionBatch) UnmarshalJSON(data []byte) error {
 
 	expectedJSONLen := len(`{"version":,"transactions":}`) +
 		len(tRaw.Version) + len(tRaw.Transactions)
+	if len(tRaw.NotBefore) > 0 {
+		if err := json.Unmarshal(tRaw.NotBefore, &t.NotBefore); err != nil {
+			return fmt.Errorf("%T.NotBefore: %v", t, err)
+		}
+		expectedJSONLen += len(`,"notbefore":`) + len(tRaw.NotBefore)
+	}
 	if expectedJSONLen != len(data) {
 		return fmt.Errorf("%T: unexpected JSON length", t)
 	}
@@ -97,15 +111,58 @@ func (t TransactionBatch) Sign(signingSet ...factom.RCDSigner) (factom.Entry, er
 	return t.Entry, nil
 }
 
+// SignAt signs like Sign, with the timestamp salt of the given time instead of
+// the current time and a random offset. The entry is the same for the same
+// batch, time, and signers, and it is only valid at heights whose timestamp
+// is within 12 hours of the time.
+func (t TransactionBatch) SignAt(timestamp time.Time, signingSet ...factom.RCDSigner) (factom.Entry, error) {
+	e := t.Entry
+	content, err := json.Marshal(t)
+	if err != nil {
+		return e, err
+	}
+	e.Content = content
+	e.Timestamp = timestamp
+
+	// The same layout as fat103.Sign: RCD/Sig ID salt + timestamp salt +
+	// chain id + content
+	timeSalt := []byte(strconv.FormatInt(timestamp.Unix(), 10))
+	maxRcdSigIDSaltStrLen := jsonlen.Uint64(uint64(len(signingSet)))
+	msg := make(factom.Bytes, maxRcdSigIDSaltStrLen+len(timeSalt)+len(e.ChainID)+len(e.Content))
+	i := maxRcdSigIDSaltStrLen
+	i += copy(msg[i:], timeSalt)
+	i += copy(msg[i:], e.ChainID[:])
+	copy(msg[i:], e.Content)
+
+	e.ExtIDs = make([]factom.Bytes, 1, len(signingSet)*2+1)
+	e.ExtIDs[0] = timeSalt
+	for rcdSigID, a := range signingSet {
+		rcdSigIDSalt := strconv.FormatUint(uint64(rcdSigID), 10)
+		start := maxRcdSigIDSaltStrLen - len(rcdSigIDSalt)
+		copy(msg[start:], rcdSigIDSalt)
+		msgHash := sha512.Sum512(msg[start:])
+		e.ExtIDs = append(e.ExtIDs, a.RCD(), a.Sign(msgHash[:]))
+	}
+	return e, nil
+}
+
 // Validate performs all validation checks and returns nil if it is a valid
 // batch. This function assumes the struct's entry field is populated.
 // Validate requires a height for rcd signature validation.
-// Not all rcd types are valid for all heights
+// Not all rcd types are valid for all heights, and time locks and minimum
+// outputs are only valid after their activation.
 func (t *TransactionBatch) Validate(height int32) error {
 	err := t.ValidData()
 	if err != nil {
 		return err
 	}
+	// < 0 means accept all features
+	if t.NotBefore > 0 && height >= 0 && !activation.Active(activation.TimeLock, uint32(height)) {
+		return fmt.Errorf("time locks are not active")
+	}
+	if t.HasMinOutput() && height >= 0 && !activation.Active(activation.MinOutput, uint32(height)) {
+		return fmt.Errorf("minimum conversion outputs are not active")
+	}
 	if err = t.ValidExtIDs(height); err != nil {
 		return err
 	}
@@ -151,7 +208,8 @@ func (t TransactionBatch) ValidExtIDs(height int32) error {
 	}
 
 	flag := factom.R_RCD1
-	if height > int32(Fat2RCDEActivation) {
+	// The rcd type 0x0e is accepted after its activation height
+	if height > 0 && activation.Active(activation.RCDE, uint32(height)-1) {
 		flag = flag | factom.R_RCDe
 	}
 	// < 0 means accept all rcd types
@@ -159,6 +217,9 @@ func (t TransactionBatch) ValidExtIDs(height int32) error {
 		flag = flag | factom.R_ALL
 	}
 
+	if height < 0 || (height > 0 && activation.Active(activation.Multisig, uint32(height)-1)) {
+		return validateMultisigExtIDs(t.Entry, uniqueInputs, flag)
+	}
 	if err := fat103.Validate(t.Entry, uniqueInputs, flag); err != nil {
 		return err
 	}
@@ -166,6 +227,11 @@ func (t TransactionBatch) ValidExtIDs(height int32) error {
 	return nil
 }
 
+// IsTimeLocked returns true if the batch cannot be executed at the height
+func (t *TransactionBatch) IsTimeLocked(height uint32) bool {
+	return height < t.NotBefore
+}
+
 // HasConversions returns true if this batch contains at least one transaction
 // with a conversion input/output pair. This function assumes that
 // TransactionBatch.Valid() returns nil
@@ -178,6 +244,17 @@ func (t *TransactionBatch) HasConversions() bool {
 	return false
 }
 
+// HasMinOutput returns true if this batch contains at least one conversion
+// with a minimum output
+func (t *TransactionBatch) HasMinOutput() bool {
+	for _, tx := range t.Transactions {
+		if tx.MinOutput > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // HasPEGRequest returns if the tx batch has a conversion request into PEG
 func (t *TransactionBatch) HasPEGRequest() bool {
 	for _, tx := range t.Transactions {
@@ -3,9 +3,11 @@ package fat2_test
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/Factom-Asset-Tokens/factom"
 
+	"github.com/pegnet/pegnetd/activation"
 	. "github.com/pegnet/pegnetd/fat/fat2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +32,28 @@ var transactionBatchUnmarshalJSONTests = []struct {
 }{{
 	Name:   "valid batch",
 	TxJSON: validTransactionBatchJSON,
+}, {
+	Name: "valid time-locked batch",
+	TxJSON: `{
+		"version": 1,
+		"transactions": [{
+			"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+			"transfers": [{"address": "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC", "amount": 50}]
+		}],
+		"notbefore": 250000
+	}`,
+}, {
+	Name:  "double notbefore",
+	Error: "*fat2.TransactionBatch: unexpected JSON length",
+	TxJSON: `{
+		"version": 1,
+		"transactions": [{
+			"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+			"transfers": [{"address": "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC", "amount": 50}]
+		}],
+		"notbefore": 250000,
+		"notbefore": 250000
+	}`,
 }, {
 	Name:  "double version",
 	Error: "*fat2.TransactionBatch: unexpected JSON length",
@@ -157,3 +181,60 @@ func TestTransactionBatch_Validate(t *testing.T) {
 		})
 	}
 }
+
+// TestTransactionBatch_NotBefore tests that time locks round trip and are
+// only valid after their activation
+func TestTransactionBatch_NotBefore(t *testing.T) {
+	assert := assert.New(t)
+	var txBatch TransactionBatch
+	require.NoError(t, json.Unmarshal([]byte(validTransactionBatchJSON), &txBatch))
+	txBatch.NotBefore = 250000
+
+	c := factom.NewBytes32("00000000000000000000000000000000")
+	txBatch.Entry.ChainID = &c
+	key := factom.FsAddress{}
+	require.NoError(t, key.Set("Fs3E9gV6DXsYzf7Fqx1fVBQPQXV695eP3k5XbmHEZVRLkMdD9qCK"))
+	ent, err := txBatch.Sign(key)
+	require.NoError(t, err)
+
+	parsed := TransactionBatch{Entry: ent}
+	require.NoError(t, parsed.UnmarshalJSON(ent.Content))
+	assert.Equal(uint32(250000), parsed.NotBefore)
+	assert.True(parsed.IsTimeLocked(249999))
+	assert.False(parsed.IsTimeLocked(250000))
+
+	defer activation.Set(activation.Current())
+	activation.Set(activation.Current().With(activation.TimeLock, 240000))
+	assert.NoError(parsed.Validate(-1))
+	assert.NoError(parsed.Validate(240000))
+	assert.EqualError(parsed.Validate(239999), "time locks are not active")
+}
+
+// TestTransactionBatch_SignAt tests that the entries signed at a time are
+// reproducible and valid
+func TestTransactionBatch_SignAt(t *testing.T) {
+	assert := assert.New(t)
+	var txBatch TransactionBatch
+	require.NoError(t, json.Unmarshal([]byte(validTransactionBatchJSON), &txBatch))
+
+	c := factom.NewBytes32("00000000000000000000000000000000")
+	txBatch.Entry.ChainID = &c
+	key := factom.FsAddress{}
+	require.NoError(t, key.Set("Fs3E9gV6DXsYzf7Fqx1fVBQPQXV695eP3k5XbmHEZVRLkMdD9qCK"))
+
+	at := time.Unix(1580000000, 0)
+	ent, err := txBatch.SignAt(at, key)
+	require.NoError(t, err)
+	again, err := txBatch.SignAt(at, key)
+	require.NoError(t, err)
+	assert.Equal(ent.ExtIDs, again.ExtIDs)
+	assert.Equal("1580000000", string(ent.ExtIDs[0]))
+
+	parsed := TransactionBatch{Entry: ent}
+	require.NoError(t, parsed.UnmarshalJSON(ent.Content))
+	assert.NoError(parsed.Validate(-1))
+
+	// Outside of the 12 hours of the timestamp salt
+	parsed.Entry.Timestamp = at.Add(13 * time.Hour)
+	assert.Error(parsed.Validate(-1))
+}
@@ -0,0 +1,159 @@
+// Package validator checks fat2 transaction batches against the state of the
+// pegnet the same way pegnetd does before it applies them. The state is
+// provided through interfaces, so wallets and exchanges can validate a batch
+// against the balances they track (or query from a pegnetd node) before
+// they broadcast it.
+package validator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// The reasons a valid batch is rejected when it is applied
+var (
+	InsufficientBalanceErr = errors.New("insufficient balance")
+	PFCTOneWayError        = errors.New("pFCT conversions are one way only at this height, they cannot be a conversion destination")
+	ZeroRatesError         = errors.New("an asset in the conversion has a rate of 0, and not allowed to be used for conversions")
+	ReplayError            = errors.New("replay: the entry was already applied")
+	MinOutputError         = errors.New("the conversion yields less than its minimum output at the current rates")
+
+	// InvalidConversionError is a conversion that cannot be computed, eg: it
+	// overflows. pegnetd skips these batches without recording them.
+	InvalidConversionError = errors.New("the conversion output cannot be computed")
+)
+
+// BalanceSource returns the balances of an address, including everything
+// applied before the batch. Assets without a balance can be left out.
+type BalanceSource interface {
+	Balances(addr factom.FAAddress) (map[fat2.PTicker]uint64, error)
+}
+
+// ReplaySource returns true if the entry hash was already applied
+type ReplaySource interface {
+	IsReplay(entryHash *factom.Bytes32) (bool, error)
+}
+
+// State is the view of the pegnet a batch is validated against
+type State interface {
+	BalanceSource
+	ReplaySource
+}
+
+// Validate runs all checks pegnetd runs on a batch that is executed at the
+// height: the batch data and signatures, replays, and the balances of its
+// inputs. The rates of the height are required for conversions.
+//
+// A batch that returns nil is applied by pegnetd if the state does not
+// change before the batch is executed. Time-locked batches are checked as if
+// they were executed at the height.
+func Validate(state State, batch *fat2.TransactionBatch, height uint32, rates map[fat2.PTicker]uint64) error {
+	if err := batch.Validate(int32(height)); err != nil {
+		return err
+	}
+	if err := CheckReplay(state, batch); err != nil {
+		return err
+	}
+	return CheckBalances(state, batch, height, rates)
+}
+
+// CheckReplay returns ReplayError if the entry of the batch was already
+// applied
+func CheckReplay(state ReplaySource, batch *fat2.TransactionBatch) error {
+	isReplay, err := state.IsReplay(batch.Entry.Hash)
+	if err != nil {
+		return err
+	} else if isReplay {
+		return ReplayError
+	}
+	return nil
+}
+
+// CheckBalances returns an error if the inputs of the batch cannot cover all
+// of its transactions at the height, applied in order. Conversions also
+// have to be valid at the rates of the height.
+//
+// The errors of this package reject the batch, any other error comes from
+// the balance source or missing rates.
+func CheckBalances(state BalanceSource, batch *fat2.TransactionBatch, height uint32, rates map[fat2.PTicker]uint64) error {
+	balances := make(map[factom.FAAddress]map[fat2.PTicker]uint64)
+
+	// We need to do all checks up front, then apply the tx
+	for _, tx := range batch.Transactions {
+		// First check the input address has the funds
+		bals, err := state.Balances(tx.Input.Address)
+		if err != nil {
+			return err
+		}
+		if bals == nil {
+			bals = make(map[fat2.PTicker]uint64)
+		}
+
+		balances[tx.Input.Address] = bals
+		if tx.Input.Amount > bals[tx.Input.Type] {
+			return InsufficientBalanceErr
+		}
+
+		if tx.IsConversion() {
+			if err := checkConversion(tx, height, rates); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Now check the batch does not drive an input negative
+	for _, tx := range batch.Transactions {
+		if balances[tx.Input.Address][tx.Input.Type] < tx.Input.Amount {
+			return InsufficientBalanceErr
+		}
+		balances[tx.Input.Address][tx.Input.Type] -= tx.Input.Amount
+
+		if tx.IsConversion() {
+			outputAmount, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
+			if err != nil {
+				return err
+			}
+			balances[tx.Input.Address][tx.Conversion] += uint64(outputAmount)
+		} else {
+			for _, transfer := range tx.Transfers {
+				// If it is one of our inputs
+				if _, ok := balances[transfer.Address]; ok {
+					balances[transfer.Address][tx.Input.Type] += transfer.Amount
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkConversion(tx fat2.Transaction, height uint32, rates map[fat2.PTicker]uint64) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("rates must exist if TransactionBatch contains conversions")
+	}
+	if rates[tx.Input.Type] == 0 || rates[tx.Conversion] == 0 {
+		return ZeroRatesError
+	}
+
+	// pXXX -> pFCT conversions are disabled at the activation height
+	if activation.Active(activation.OneWaypFCTConversions, height) && tx.Conversion == fat2.PTickerFCT {
+		return PFCTOneWayError
+	}
+
+	outputAmount, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
+	if err != nil {
+		return InvalidConversionError
+	}
+
+	// The rates moved too far since the conversion was submitted.
+	// PEG requests are checked before the conversion limit, and their
+	// prorated yield again when the bank is paid out.
+	if uint64(outputAmount) < tx.MinOutput {
+		return MinOutputError
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package validator_test
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/fat/fat2/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testState struct {
+	balances map[factom.FAAddress]map[fat2.PTicker]uint64
+	applied  map[factom.Bytes32]bool
+}
+
+func (s testState) Balances(addr factom.FAAddress) (map[fat2.PTicker]uint64, error) {
+	bals := make(map[fat2.PTicker]uint64)
+	for k, v := range s.balances[addr] {
+		bals[k] = v
+	}
+	return bals, nil
+}
+
+func (s testState) IsReplay(entryHash *factom.Bytes32) (bool, error) {
+	return s.applied[*entryHash], nil
+}
+
+func TestCheckBalances(t *testing.T) {
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	state := testState{balances: map[factom.FAAddress]map[fat2.PTicker]uint64{
+		a: {fat2.PTickerPEG: 100, fat2.PTickerUSD: 10},
+	}}
+	rates := map[fat2.PTicker]uint64{fat2.PTickerPEG: 1e8, fat2.PTickerUSD: 2e8, fat2.PTickerFCT: 0}
+
+	transfer := func(amount uint64) fat2.Transaction {
+		return fat2.Transaction{
+			Input:     fat2.TypedAddressAmountTuple{Address: a, Amount: amount, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: amount}},
+		}
+	}
+	conversion := func(amount uint64, to fat2.PTicker, minOutput uint64) fat2.Transaction {
+		return fat2.Transaction{
+			Input:      fat2.TypedAddressAmountTuple{Address: a, Amount: amount, Type: fat2.PTickerPEG},
+			Conversion: to,
+			MinOutput:  minOutput,
+		}
+	}
+
+	tests := []struct {
+		Name  string
+		Txs   []fat2.Transaction
+		Error error
+	}{
+		{"transfer", []fat2.Transaction{transfer(100)}, nil},
+		{"insufficient", []fat2.Transaction{transfer(101)}, InsufficientBalanceErr},
+		{"insufficient batch", []fat2.Transaction{transfer(60), transfer(60)}, InsufficientBalanceErr},
+		{"conversion", []fat2.Transaction{conversion(100, fat2.PTickerUSD, 50)}, nil},
+		{"below minimum output", []fat2.Transaction{conversion(100, fat2.PTickerUSD, 51)}, MinOutputError},
+		{"zero rates", []fat2.Transaction{conversion(100, fat2.PTickerFCT, 0)}, ZeroRatesError},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			batch := &fat2.TransactionBatch{Version: 1, Transactions: test.Txs}
+			assert.Equal(t, test.Error, CheckBalances(state, batch, 1, rates))
+		})
+	}
+
+	batch := &fat2.TransactionBatch{Version: 1, Transactions: []fat2.Transaction{conversion(100, fat2.PTickerUSD, 0)}}
+	assert.EqualError(t, CheckBalances(state, batch, 1, nil), "rates must exist if TransactionBatch contains conversions")
+
+	rates[fat2.PTickerFCT] = 1e8
+	batch = &fat2.TransactionBatch{Version: 1, Transactions: []fat2.Transaction{conversion(100, fat2.PTickerFCT, 0)}}
+	assert.Equal(t, PFCTOneWayError, CheckBalances(state, batch, activation.MainNet.OneWaypFCTConversions, rates))
+}
+
+func TestCheckReplay(t *testing.T) {
+	hash := factom.Bytes32{1}
+	state := testState{applied: map[factom.Bytes32]bool{hash: true}}
+
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash}}
+	require.Equal(t, ReplayError, CheckReplay(state, batch))
+
+	other := factom.Bytes32{2}
+	batch.Entry.Hash = &other
+	require.NoError(t, CheckReplay(state, batch))
+}
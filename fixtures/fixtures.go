@@ -0,0 +1,292 @@
+// Package fixtures generates the databases and entry sets of common scenarios
+// for integration tests and bug reports. A node syncs every scenario from the
+// regtest mock with a fixed clock and keys derived from the account names, so
+// every run produces the same entries and the same balances.
+package fixtures
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/regtest"
+	"github.com/pegnet/pegnetd/replay"
+	"github.com/spf13/viper"
+)
+
+// Start is the timestamp of the genesis block of every scenario
+var Start = time.Date(2020, time.February, 12, 18, 0, 0, 0, time.UTC)
+
+// SyncWait is how long a mined block may take to sync
+const SyncWait = time.Minute
+
+// Fixture is the entry set of a generated scenario, written next to its
+// database
+type Fixture struct {
+	Scenario    string `json:"scenario"`
+	Description string `json:"description"`
+	Network     string `json:"network"`
+	// Height is the last synced height of the database
+	Height   uint32    `json:"height"`
+	Accounts []Account `json:"accounts"`
+	Entries  []Entry   `json:"entries"`
+}
+
+// Account is an address of the scenario with its secret, the keys are derived
+// from the name and hold no value outside of the fixtures
+type Account struct {
+	Name    string           `json:"name"`
+	Address factom.FAAddress `json:"address"`
+	Secret  factom.FsAddress `json:"secret"`
+}
+
+// Entry is a submitted transaction entry and the height it was mined at
+type Entry struct {
+	Height  uint32         `json:"height"`
+	ChainID factom.Bytes32 `json:"chainid"`
+	Hash    factom.Bytes32 `json:"entryhash"`
+	ExtIDs  []factom.Bytes `json:"extids"`
+	Content factom.Bytes   `json:"content"`
+}
+
+// Scenario is a sequence of burns, transactions, and blocks
+type Scenario struct {
+	Name        string
+	Description string
+	// Network is the network the scenario is synced on, RegTest if nil. It
+	// has to be applied to replay the dump of the scenario.
+	Network *node.Network
+	run     func(ctx context.Context, g *generator) error
+}
+
+// Lookup returns the scenario of the name
+func Lookup(name string) (Scenario, bool) {
+	for _, s := range Scenarios {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// AccountKey is the secret of the account of the name
+func AccountKey(name string) factom.FsAddress {
+	return factom.FsAddress(sha256.Sum256([]byte("pegnetd fixtures " + name)))
+}
+
+// DBPath is the dbpath to configure for the database of the scenario. The
+// file on disk has the version suffix of the database, eg "rich.db.v4".
+func DBPath(dir, scenario string) string {
+	return filepath.Join(dir, scenario+".db")
+}
+
+// DumpPath is the path of the dump of all blocks of the scenario, which can
+// be replayed into a new database
+func DumpPath(dir, scenario string) string {
+	return filepath.Join(dir, scenario+".dump")
+}
+
+// Generate syncs the scenario into a new database in the directory, and
+// writes its entry set as "<scenario>.json" and its blocks as
+// "<scenario>.dump". Existing files of the scenario
+// are replaced. It applies the network of the scenario and the fixed clock of
+// the mock, so it can not run next to a node of another network.
+func Generate(ctx context.Context, s Scenario, dir string) (*Fixture, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	dbpath := DBPath(dir, s.Name)
+	for _, suffix := range []string{".v4", ".v4-wal", ".v4-shm", ".v4-journal"} {
+		if err := os.Remove(dbpath + suffix); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	network := node.RegTest
+	if s.Network != nil {
+		network = *s.Network
+	}
+	if err := network.Apply(); err != nil {
+		return nil, err
+	}
+	regtest.Clock = func() time.Time { return Start }
+
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.Regtest, true)
+	conf.Set(config.SqliteDBPath, dbpath)
+	conf.Set(config.DBlockSyncRetryPeriod, 10*time.Millisecond)
+	conf.Set(config.WatchdogStalled, 0)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d, err := node.NewPegnetd(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		d.DBlockSync(ctx)
+		close(done)
+	}()
+
+	g := &generator{
+		node:    d,
+		es:      factom.EsAddress(sha256.Sum256([]byte("pegnetd fixtures ec"))),
+		fixture: &Fixture{Scenario: s.Name, Description: s.Description, Network: network.Name},
+	}
+	runErr := s.run(ctx, g)
+	if runErr == nil && len(g.pending) > 0 {
+		runErr = g.mine(ctx, 1)
+	}
+	if runErr == nil {
+		// The conversions of the last block are executed in the next one
+		runErr = g.mine(ctx, 1)
+	}
+	if runErr == nil {
+		runErr = g.writeDump(ctx, DumpPath(dir, s.Name))
+	}
+	cancel()
+	<-done
+	if err := d.Pegnet.Close(); err != nil && runErr == nil {
+		runErr = err
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("scenario %s: %v", s.Name, runErr)
+	}
+
+	g.fixture.Height = d.GetCurrentSync()
+	sort.Slice(g.fixture.Accounts, func(i, j int) bool { return g.fixture.Accounts[i].Name < g.fixture.Accounts[j].Name })
+	data, err := json.MarshalIndent(g.fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, s.Name+".json"), data, 0644); err != nil {
+		return nil, err
+	}
+	return g.fixture, nil
+}
+
+// generator submits the entries of a scenario to the mock and waits for the
+// node to sync the mined blocks
+type generator struct {
+	node     *node.Pegnetd
+	es       factom.EsAddress
+	fixture  *Fixture
+	accounts map[string]factom.FsAddress
+	// pending are the submitted entries that are not mined yet
+	pending []Entry
+}
+
+// account returns the address of the name, and adds it to the fixture
+func (g *generator) account(name string) factom.FAAddress {
+	if g.accounts == nil {
+		g.accounts = make(map[string]factom.FsAddress)
+	}
+	key, ok := g.accounts[name]
+	if !ok {
+		key = AccountKey(name)
+		g.accounts[name] = key
+		g.fixture.Accounts = append(g.fixture.Accounts, Account{Name: name, Address: key.FAAddress(), Secret: key})
+	}
+	return key.FAAddress()
+}
+
+// fund burns the FCT of the account in the next block
+func (g *generator) fund(name string, amount uint64) error {
+	_, err := g.node.Regtest.Fund(g.account(name), amount)
+	return err
+}
+
+// balance is the synced balance of the account
+func (g *generator) balance(name string, ticker fat2.PTicker) (uint64, error) {
+	adr := g.account(name)
+	return g.node.Pegnet.SelectBalance(&adr, ticker)
+}
+
+// submit signs the transactions with the key of the account as one batch,
+// and submits it for the next block. The inputs of all transactions must be
+// the account.
+func (g *generator) submit(ctx context.Context, name string, txs ...fat2.Transaction) error {
+	adr, key := g.account(name), g.accounts[name]
+	for i := range txs {
+		txs[i].Input.Address = adr
+	}
+	batch := fat2.TransactionBatch{Version: 1, Transactions: txs}
+	batch.Entry.ChainID = &node.TransactionChain
+
+	// Signed at the timestamp of the next block
+	at := Start.Add(time.Duration(g.node.Regtest.Height()+1) * time.Minute)
+	e, err := batch.SignAt(at, key)
+	if err != nil {
+		return err
+	}
+	batch.Entry = e
+	if err := batch.Validate(-1); err != nil {
+		return fmt.Errorf("invalid transaction of %s: %v", name, err)
+	}
+	if _, err := e.ComposeCreate(ctx, g.node.FactomClient, g.es); err != nil {
+		return err
+	}
+	g.pending = append(g.pending, Entry{ChainID: *e.ChainID, Hash: *e.Hash, ExtIDs: e.ExtIDs, Content: e.Content})
+	return nil
+}
+
+// writeDump writes all mined blocks of the mock
+func (g *generator) writeDump(ctx context.Context, path string) error {
+	height := g.node.Regtest.Height()
+	header := replay.Header{Network: node.ActiveNetwork.Name, Start: activation.Height(activation.Pegnet) + 1, Stop: height}
+	w, err := replay.Create(path, header)
+	if err != nil {
+		return err
+	}
+	for h := header.Start; h <= height; h++ {
+		b, err := replay.Fetch(ctx, g.node.FactomClient, h, node.OPRChain, node.TransactionChain)
+		if err != nil {
+			_ = w.Close()
+			return err
+		}
+		if err := w.Write(b); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// mine mines count blocks and waits until the node synced them
+func (g *generator) mine(ctx context.Context, count int) error {
+	height, err := g.node.Regtest.Mine(count, regtest.DefaultCoinbase())
+	if err != nil {
+		return err
+	}
+	for _, e := range g.pending {
+		e.Height = height - uint32(count) + 1
+		g.fixture.Entries = append(g.fixture.Entries, e)
+	}
+	g.pending = nil
+
+	ctx, cancel := context.WithTimeout(ctx, SyncWait)
+	defer cancel()
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for g.node.GetCurrentSync() < height {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the node did not sync height %d: %v", height, ctx.Err())
+		case <-tick.C:
+		}
+	}
+	return nil
+}
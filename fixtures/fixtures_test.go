@@ -0,0 +1,126 @@
+package fixtures_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/fixtures"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_Deterministic(t *testing.T) {
+	s, ok := Lookup("prorated")
+	require.True(t, ok)
+
+	var sets [][]byte
+	var dirs []string
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "pegnetd-fixtures")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		fixture, err := Generate(context.Background(), s, dir)
+		require.NoError(t, err)
+		assert.Len(t, fixture.Accounts, 10)
+		assert.Len(t, fixture.Entries, 11)
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, s.Name+".json"))
+		require.NoError(t, err)
+		sets = append(sets, data)
+		dirs = append(dirs, dir)
+	}
+	assert.True(t, bytes.Equal(sets[0], sets[1]), "the entry sets differ")
+
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, DBPath(dirs[0], s.Name))
+	p := pegnet.New(conf)
+	require.NoError(t, p.Init())
+	defer p.Close()
+
+	// The PEG bank of the block is split in proportion to the requests, the
+	// request of 1 pFCT in the next block is paid out in full
+	var total uint64
+	for i := 1; i <= 10; i++ {
+		adr := AccountKey(fmt.Sprintf("requester%02d", i)).FAAddress()
+		peg, err := p.SelectBalance(&adr, fat2.PTickerPEG)
+		require.NoError(t, err)
+		total += peg
+	}
+	full := uint64(3.5 / 0.004 * 1e8)
+	// The share of the last request is below its minimum output, it is
+	// refunded and the other requests share the whole bank
+	assert.InDelta(t, pegnet.BankBaseAmount+full, total, 10)
+
+	last := AccountKey("requester10").FAAddress()
+	balances, err := p.SelectBalances(&last)
+	require.NoError(t, err)
+	assert.Zero(t, balances[fat2.PTickerPEG])
+	assert.Equal(t, uint64(1000e8), balances[fat2.PTickerFCT])
+	refunds, _, err := p.SelectTransactionHistoryActionsByAddress(&last, pegnet.HistoryQueryOptions{Refund: true})
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, int64(100e8), refunds[0].FromAmount)
+	assert.Equal(t, pegnet.RefundReasonMinOutput, refunds[0].Reason)
+}
+
+func TestGenerate_PreV4(t *testing.T) {
+	s, ok := Lookup("prev4")
+	require.True(t, ok)
+	defer node.RegTest.Apply()
+
+	dir, err := ioutil.TempDir("", "pegnetd-fixtures")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fixture, err := Generate(context.Background(), s, dir)
+	require.NoError(t, err)
+	assert.Equal(t, s.Network.Name, fixture.Network)
+
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, DBPath(dir, s.Name))
+	p := pegnet.New(conf)
+	require.NoError(t, p.Init())
+	defer p.Close()
+
+	// Both conversions are executed without a bank, and rcd type 0x0e is
+	// never active
+	trader := AccountKey("trader").FAAddress()
+	balances, err := p.SelectBalances(&trader)
+	require.NoError(t, err)
+	assert.NotZero(t, balances[fat2.PTickerPEG])
+	assert.NotZero(t, balances[fat2.PTickerUSD])
+	assert.Equal(t, uint64(980e8), balances[fat2.PTickerFCT])
+	assert.Zero(t, balances[fat2.PTickerAUD])
+	assert.False(t, activation.Active(activation.RCDE, fixture.Height))
+
+	// The batch converting into pAUD before its activation is recorded and
+	// rejected for its missing rate
+	require.Len(t, fixture.Entries, 2)
+	aud := fixture.Entries[1].Hash
+	height, executed, err := p.SelectTransactionHistoryStatus(&aud)
+	require.NoError(t, err)
+	assert.Equal(t, fixture.Entries[1].Height, height)
+	assert.Equal(t, int32(pegnet.ZeroRatesErrorInt), executed)
+	rejection, err := p.SelectTransactionRejection(&aud)
+	require.NoError(t, err)
+	require.NotNil(t, rejection)
+	assert.Equal(t, pegnet.ZeroRatesErrorInt, rejection.Code)
+	refunds, _, err := p.SelectTransactionHistoryActionsByHash(&aud, pegnet.HistoryQueryOptions{Refund: true})
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, int64(10e8), refunds[0].FromAmount)
+	assert.Equal(t, pegnet.RefundReasonMissingRate, refunds[0].Reason)
+}
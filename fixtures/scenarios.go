@@ -0,0 +1,191 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node"
+)
+
+// Scenarios are all scenarios that can be generated
+var Scenarios = []Scenario{
+	{
+		Name:        "rich",
+		Description: "An address that converts into many assets and pays out to 50 holders",
+		run:         richAddress,
+	},
+	{
+		Name:        "conversions",
+		Description: "20 traders that convert between assets over 6 blocks",
+		run:         heavyConversions,
+	},
+	{
+		Name:        "prorated",
+		Description: "A block whose PEG conversions exceed the bank and are prorated, one below its minimum output",
+		run:         proratedConversions,
+	},
+	{
+		Name:        "prev4",
+		Description: "PEG conversions on a network that never reaches the V4 update and its bank, and a conversion into a V4 asset",
+		Network:     &preV4Rules,
+		run:         preV4Conversions,
+	},
+}
+
+// preV4Rules are the activations of a network of the mock that stays on the
+// V2 grading, the features from the free floating PEG price on are never
+// active
+var preV4Rules = node.Network{
+	Name:        "RegTest-PreV4",
+	BurnAddress: node.RegTest.BurnAddress,
+	Activations: activation.RegTest.With(activation.PEGFreeFloatingPrice, activation.Never).
+		With(activation.V4OPRUpdate, activation.Never).With(activation.RCDE, activation.Never).
+		With(activation.Multisig, activation.Never).With(activation.TimeLock, activation.Never).
+		With(activation.MinOutput, activation.Never),
+}
+
+// fct is an amount of whole units in factoshis
+func fct(amount uint64) uint64 {
+	return amount * 1e8
+}
+
+// convertAssets are the assets the scenarios convert pFCT into
+var convertAssets = []fat2.PTicker{
+	fat2.PTickerUSD, fat2.PTickerEUR, fat2.PTickerJPY, fat2.PTickerGBP, fat2.PTickerXAU,
+	fat2.PTickerXBT, fat2.PTickerETH, fat2.PTickerCNY, fat2.PTickerXAG, fat2.PTickerLTC,
+}
+
+func conversion(from fat2.PTicker, amount uint64, to fat2.PTicker) fat2.Transaction {
+	return fat2.Transaction{
+		Input:      fat2.TypedAddressAmountTuple{Amount: amount, Type: from},
+		Conversion: to,
+	}
+}
+
+func richAddress(ctx context.Context, g *generator) error {
+	if err := g.fund("rich", fct(1000000)); err != nil {
+		return err
+	}
+	if err := g.mine(ctx, 1); err != nil {
+		return err
+	}
+
+	var txs []fat2.Transaction
+	for _, asset := range convertAssets {
+		txs = append(txs, conversion(fat2.PTickerFCT, fct(1000), asset))
+	}
+	if err := g.submit(ctx, "rich", txs...); err != nil {
+		return err
+	}
+	if err := g.mine(ctx, 2); err != nil {
+		return err
+	}
+
+	payout := fat2.Transaction{Input: fat2.TypedAddressAmountTuple{Type: fat2.PTickerUSD}}
+	for i := 1; i <= 50; i++ {
+		adr := g.account(fmt.Sprintf("holder%02d", i))
+		payout.Transfers = append(payout.Transfers, fat2.AddressAmountTuple{Address: adr, Amount: fct(uint64(i))})
+		payout.Input.Amount += fct(uint64(i))
+	}
+	if err := g.submit(ctx, "rich", payout); err != nil {
+		return err
+	}
+	return g.mine(ctx, 1)
+}
+
+func heavyConversions(ctx context.Context, g *generator) error {
+	const traders = 20
+	name := func(i int) string { return fmt.Sprintf("trader%02d", i+1) }
+	for i := 0; i < traders; i++ {
+		if err := g.fund(name(i), fct(10000)); err != nil {
+			return err
+		}
+	}
+	if err := g.mine(ctx, 1); err != nil {
+		return err
+	}
+
+	// Every round, each trader converts pFCT into an asset, and half of the
+	// asset of two rounds before into pUSD. The conversions of a round are
+	// executed in the block of the next round.
+	for round := 0; round < 6; round++ {
+		for i := 0; i < traders; i++ {
+			txs := []fat2.Transaction{
+				conversion(fat2.PTickerFCT, fct(uint64(10+i)), convertAssets[(i+round)%len(convertAssets)]),
+			}
+			if round > 1 {
+				prev := convertAssets[(i+round-2)%len(convertAssets)]
+				bal, err := g.balance(name(i), prev)
+				if err != nil {
+					return err
+				}
+				if prev != fat2.PTickerUSD && bal/2 > 0 {
+					txs = append(txs, conversion(prev, bal/2, fat2.PTickerUSD))
+				}
+			}
+			if err := g.submit(ctx, name(i), txs...); err != nil {
+				return err
+			}
+		}
+		if err := g.mine(ctx, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func proratedConversions(ctx context.Context, g *generator) error {
+	const requesters = 10
+	name := func(i int) string { return fmt.Sprintf("requester%02d", i+1) }
+	for i := 0; i < requesters; i++ {
+		if err := g.fund(name(i), fct(1000)); err != nil {
+			return err
+		}
+	}
+	if err := g.mine(ctx, 1); err != nil {
+		return err
+	}
+
+	// Far more PEG is requested than the bank of a block allows. The last
+	// request asks for more PEG than its share of the bank, so it is refunded.
+	for i := 0; i < requesters; i++ {
+		tx := conversion(fat2.PTickerFCT, fct(uint64(10*(i+1))), fat2.PTickerPEG)
+		if i == requesters-1 {
+			tx.MinOutput = fct(1000)
+		}
+		if err := g.submit(ctx, name(i), tx); err != nil {
+			return err
+		}
+	}
+	if err := g.mine(ctx, 2); err != nil {
+		return err
+	}
+
+	// A single request below the bank is paid out in full
+	return g.submit(ctx, name(0), conversion(fat2.PTickerFCT, fct(1), fat2.PTickerPEG))
+}
+
+func preV4Conversions(ctx context.Context, g *generator) error {
+	if err := g.fund("trader", fct(1000)); err != nil {
+		return err
+	}
+	if err := g.mine(ctx, 1); err != nil {
+		return err
+	}
+
+	// The PEG request is limited by the bank of the height before the V4
+	// update, which has no bank entry
+	txs := []fat2.Transaction{
+		conversion(fat2.PTickerFCT, fct(10), fat2.PTickerPEG),
+		conversion(fat2.PTickerFCT, fct(10), fat2.PTickerUSD),
+	}
+	if err := g.submit(ctx, "trader", txs...); err != nil {
+		return err
+	}
+
+	// The V4 assets are not tracked yet, the conversion has no rate and is
+	// rejected like on a node before the V4 update
+	return g.submit(ctx, "trader", conversion(fat2.PTickerFCT, fct(10), fat2.PTickerAUD))
+}
@@ -0,0 +1,89 @@
+package fuzz
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/replay"
+)
+
+// CorpusDir is the corpus of the target in a workdir, the layout of go-fuzz
+func CorpusDir(workdir, target string) string {
+	return filepath.Join(workdir, target, "corpus")
+}
+
+// CrashersDir is where the inputs that crash the target are written
+func CrashersDir(workdir, target string) string {
+	return filepath.Join(workdir, target, "crashers")
+}
+
+// Entries returns the binaries of the entries of the chain in the block of a
+// dump, in the order of the entry block
+func Entries(b *replay.Block, chain factom.Bytes32) ([]factom.Bytes, error) {
+	objects := make(map[factom.Bytes32]factom.Bytes, len(b.Objects))
+	for _, o := range b.Objects {
+		objects[o.Hash] = o.Data
+	}
+
+	var dblock factom.DBlock
+	if err := dblock.UnmarshalBinary(b.DBlock); err != nil {
+		return nil, fmt.Errorf("dblock %d: %v", b.Height, err)
+	}
+	eblock := dblock.EBlock(chain)
+	if eblock == nil {
+		return nil, nil
+	}
+	data, ok := objects[*eblock.KeyMR]
+	if !ok {
+		return nil, fmt.Errorf("height %d: the block has no eblock %s", b.Height, eblock.KeyMR)
+	}
+	if err := eblock.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("eblock %s: %v", eblock.KeyMR, err)
+	}
+
+	entries := make([]factom.Bytes, 0, len(eblock.Entries))
+	for _, e := range eblock.Entries {
+		data, ok := objects[*e.Hash]
+		if !ok {
+			return nil, fmt.Errorf("height %d: the block has no entry %s", b.Height, e.Hash)
+		}
+		entries = append(entries, data)
+	}
+	return entries, nil
+}
+
+// WriteInput writes the input to the directory, named by its sha1 like the
+// inputs of go-fuzz. Returns the path.
+func WriteInput(dir string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	path := filepath.Join(dir, hex.EncodeToString(sum[:]))
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadCorpus reads the inputs of the directory
+func LoadCorpus(dir string) ([][]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var corpus [][]byte
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		corpus = append(corpus, data)
+	}
+	return corpus, nil
+}
@@ -0,0 +1,207 @@
+// Package fuzz has the fuzz harnesses of the chain entries pegnetd parses
+// when it applies a block: the fat2 transaction batches of the transaction
+// chain, and the OPRs of the OPR chain. An input is the binary of a factom
+// entry, so a corpus is seeded with the entries of a network as they are
+// stored on chain.
+//
+// The harnesses have the signature of go-fuzz, so they build with
+// go-fuzz-build and its libFuzzer mode:
+//
+//	go-fuzz-build -func FuzzTransactionBatch github.com/pegnet/pegnetd/fuzz
+//	go-fuzz -bin fuzz-fuzz.zip -workdir fuzzdata/transactions
+//
+// 'pegnetd fuzz run' runs them with a built-in mutator when go-fuzz is not
+// around.
+package fuzz
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/pegnet/pegnet/modules/opr"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/fat/fat2/validator"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/grading"
+)
+
+// Target is a harness and the chain its corpus is seeded from
+type Target struct {
+	Name        string
+	Description string
+	Func        func(data []byte) int
+	// Chain returns the chain of the entries of the corpus, it depends on
+	// the network the node is set to
+	Chain func() factom.Bytes32
+}
+
+// Targets are the harnesses of the parsers
+var Targets = []Target{
+	{
+		Name:        "transactions",
+		Description: "fat2 transaction batches of the transaction chain, at every rule set",
+		Func:        FuzzTransactionBatch,
+		Chain:       func() factom.Bytes32 { return node.TransactionChain },
+	},
+	{
+		Name:        "opr",
+		Description: "OPRs of the OPR chain at every grading version, and the grading of a block of them",
+		Func:        FuzzOPR,
+		Chain:       func() factom.Bytes32 { return node.OPRChain },
+	},
+}
+
+// Lookup returns the target of the name
+func Lookup(name string) (Target, bool) {
+	for _, t := range Targets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Target{}, false
+}
+
+// unmarshalEntry decodes the binary of an input. The factom package panics on
+// some malformed binaries, those are not entries like any other input that
+// does not decode.
+func unmarshalEntry(e *factom.Entry, data []byte) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("invalid entry binary: %v", v)
+		}
+	}()
+	return e.UnmarshalBinary(data)
+}
+
+// FuzzTransactionBatch parses the entry as a transaction batch the same way
+// the sync does, at a height before and after every fat2 activation, and
+// checks the balances of a parsed batch against funded inputs. Returns 1 if
+// the entry is a batch, which makes it a better input to mutate.
+func FuzzTransactionBatch(data []byte) int {
+	var entry factom.Entry
+	if err := unmarshalEntry(&entry, data); err != nil {
+		return 0
+	}
+
+	var batch fat2.TransactionBatch
+	if err := batch.UnmarshalJSON(entry.Content); err != nil {
+		return 0
+	}
+	batch.Entry = entry
+
+	for _, height := range batchHeights() {
+		_, _ = fat2.NewTransactionBatch(entry, height)
+		_ = batch.ValidExtIDs(height)
+	}
+	if err := batch.ValidData(); err != nil {
+		return 0
+	}
+	_ = batch.HasConversions()
+	_ = batch.HasPEGRequest()
+	_, _ = batch.MarshalJSON()
+
+	// The signatures of a mutated batch are never valid, so the balances are
+	// checked without them
+	for _, height := range batchHeights() {
+		if height >= 0 {
+			_ = validator.CheckBalances(rich{}, &batch, uint32(height), fuzzRates())
+		}
+	}
+	return 1
+}
+
+// batchHeights are -1, which accepts every feature, and the heights around
+// the fat2 activations
+func batchHeights() []int32 {
+	heights := []int32{-1, 0}
+	for _, f := range []activation.Feature{activation.OneWaypFCTConversions, activation.RCDE, activation.Multisig,
+		activation.TimeLock, activation.MinOutput} {
+		act := activation.Height(f)
+		if act < math.MaxInt32 {
+			heights = append(heights, int32(act), int32(act)+1)
+		}
+	}
+	return heights
+}
+
+// fuzzRates returns rates for every asset, so conversions reach the math
+func fuzzRates() map[fat2.PTicker]uint64 {
+	rates := make(map[fat2.PTicker]uint64)
+	for i, a := range fat2.Assets() {
+		rates[fat2.StringToTicker(a.Name)] = uint64(i+1) * 1e7
+	}
+	return rates
+}
+
+// rich is a balance source where every address holds the maximum of every
+// asset
+type rich struct{}
+
+func (rich) Balances(factom.FAAddress) (map[fat2.PTicker]uint64, error) {
+	bals := make(map[fat2.PTicker]uint64)
+	for _, a := range fat2.Assets() {
+		bals[fat2.StringToTicker(a.Name)] = math.MaxUint64
+	}
+	return bals, nil
+}
+
+var initLX sync.Once
+
+// FuzzOPR adds the entry to a grader of every grading version and grades a
+// block of copies of it. The grader is set to the height and the previous
+// winners the OPR reports, so a mutated OPR is not rejected for them alone.
+// Returns 1 if a version accepts the OPR.
+func FuzzOPR(data []byte) int {
+	var entry factom.Entry
+	if err := unmarshalEntry(&entry, data); err != nil {
+		return 0
+	}
+	initLX.Do(func() {
+		// The grading only hashes the OPRs, a small LXR map makes it fast
+		if os.Getenv("LXRBITSIZE") == "" {
+			_ = os.Setenv("LXRBITSIZE", "10")
+		}
+		grader.InitLX()
+	})
+
+	extids := make([][]byte, len(entry.ExtIDs))
+	for i := range entry.ExtIDs {
+		extids[i] = entry.ExtIDs[i]
+	}
+	height, winners := int32(1), []string(nil)
+	if o, err := opr.Parse(entry.Content); err == nil {
+		height, winners = o.GetHeight(), o.GetPreviousWinners()
+	}
+
+	res := 0
+	for _, ver := range grading.Versions() {
+		g, err := grading.New(ver, height, winners)
+		if err != nil {
+			if g, err = grading.New(ver, 1, nil); err != nil {
+				continue
+			}
+		}
+		// Enough copies for a block to have winners
+		for i := 0; i < oprCopies; i++ {
+			hash := *entry.Hash
+			hash[0], hash[1] = byte(i), byte(i>>8)
+			if err := g.AddOPR(hash[:], extids, entry.Content); err != nil {
+				break
+			}
+			res = 1
+		}
+		block := g.Grade()
+		_ = block.Winners()
+		_ = block.WinnersShortHashes()
+	}
+	return res
+}
+
+// oprCopies is the least amount of OPRs every grading version picks winners
+// from
+const oprCopies = 25
@@ -0,0 +1,100 @@
+package fuzz_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fixtures"
+	. "github.com/pegnet/pegnetd/fuzz"
+	"github.com/pegnet/pegnetd/replay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargets_Fixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-fuzz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, ok := fixtures.Lookup("conversions")
+	require.True(t, ok)
+	_, err = fixtures.Generate(context.Background(), s, dir)
+	require.NoError(t, err)
+
+	dump, err := replay.Open(fixtures.DumpPath(dir, s.Name))
+	require.NoError(t, err)
+	defer dump.Close()
+
+	// Every entry the node synced is accepted by its target
+	found := make(map[string]int)
+	for {
+		b, err := dump.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		for _, target := range Targets {
+			entries, err := Entries(b, target.Chain())
+			require.NoError(t, err)
+			for _, e := range entries {
+				assert.Equal(t, 1, target.Func(e), "%s at height %d", target.Name, b.Height)
+				_, err := WriteInput(CorpusDir(dir, target.Name), e)
+				require.NoError(t, err)
+				found[target.Name]++
+			}
+		}
+	}
+	for _, target := range Targets {
+		assert.NotZero(t, found[target.Name], target.Name)
+		assert.Equal(t, 0, target.Func([]byte("not an entry")), target.Name)
+
+		// The built-in mutator runs the seeded corpus without crashers
+		stats, err := Run(context.Background(), target, dir, Options{Duration: 500 * time.Millisecond, Workers: 2, Seed: 1})
+		require.NoError(t, err)
+		assert.NotZero(t, stats.Execs, target.Name)
+		assert.Zero(t, stats.Crashers, target.Name)
+	}
+}
+
+func TestRun_Crashers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-fuzz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	target := Target{Name: "panics", Func: func(data []byte) int {
+		var e factom.Entry
+		if e.UnmarshalBinary(data) == nil && len(e.Content) != 4 {
+			panic("content is not 4 bytes")
+		}
+		return 1
+	}}
+	_, err = Run(context.Background(), target, dir, Options{Duration: time.Millisecond})
+	assert.Error(t, err, "the corpus is empty")
+
+	entry := factom.Entry{ChainID: new(factom.Bytes32), Content: factom.Bytes("seed")}
+	data, err := entry.MarshalBinary()
+	require.NoError(t, err)
+	_, err = WriteInput(CorpusDir(dir, target.Name), data)
+	require.NoError(t, err)
+
+	stats, err := Run(context.Background(), target, dir, Options{Duration: 200 * time.Millisecond, Workers: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Crashers)
+
+	outputs, err := filepath.Glob(filepath.Join(CrashersDir(dir, target.Name), "*.output"))
+	require.NoError(t, err)
+	require.Len(t, outputs, 1)
+	output, err := ioutil.ReadFile(outputs[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "panic: content is not 4 bytes")
+
+	crasher, err := ioutil.ReadFile(outputs[0][:len(outputs[0])-len(".output")])
+	require.NoError(t, err)
+	assert.Panics(t, func() { target.Func(crasher) })
+}
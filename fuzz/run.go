@@ -0,0 +1,270 @@
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Options of a run of the built-in mutator
+type Options struct {
+	// Duration of the run, 0 runs until the context is done
+	Duration time.Duration
+	Workers  int
+	// Seed of the mutations, a run with the same seed and one worker
+	// executes the same inputs
+	Seed int64
+	// Report is called with the stats every ReportInterval
+	Report         func(Stats)
+	ReportInterval time.Duration
+}
+
+// Stats of a run
+type Stats struct {
+	Execs    uint64
+	Corpus   int
+	Crashers int
+	Elapsed  time.Duration
+}
+
+// maxCorpus caps the inputs a run keeps in memory
+const maxCorpus = 10000
+
+// Run executes mutations of the corpus of the target in the workdir, and
+// writes every input that panics to the crashers next to it with an .output
+// of the panic and the stack. Crashers of the same panic are written once.
+//
+// The mutator has no coverage feedback, it mutates the raw entry as well as
+// its content and external ids, and keeps a share of the inputs the target
+// accepts to mutate further. go-fuzz finds deeper bugs, see the package doc.
+func Run(ctx context.Context, t Target, workdir string, opts Options) (Stats, error) {
+	corpus, err := LoadCorpus(CorpusDir(workdir, t.Name))
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(corpus) == 0 {
+		return Stats{}, fmt.Errorf("the corpus of %s is empty, seed it with 'pegnetd fuzz seed'", t.Name)
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	r := &runner{target: t, crashers: CrashersDir(workdir, t.Name), corpus: corpus, seen: make(map[string]bool)}
+	start := time.Now()
+	stats := func() Stats {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return Stats{Execs: atomic.LoadUint64(&r.execs), Corpus: len(r.corpus), Crashers: len(r.seen), Elapsed: time.Since(start)}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			r.work(ctx, rng)
+		}(rand.New(rand.NewSource(opts.Seed + int64(i))))
+	}
+
+	if opts.Report != nil && opts.ReportInterval > 0 {
+		ticker := time.NewTicker(opts.ReportInterval)
+		defer ticker.Stop()
+		done := make(chan struct{})
+		go func() { wg.Wait(); close(done) }()
+	report:
+		for {
+			select {
+			case <-ticker.C:
+				opts.Report(stats())
+			case <-done:
+				break report
+			}
+		}
+	}
+	wg.Wait()
+	return stats(), r.err
+}
+
+type runner struct {
+	target   Target
+	crashers string
+	execs    uint64
+
+	mu     sync.Mutex
+	corpus [][]byte
+	seen   map[string]bool
+	err    error
+}
+
+func (r *runner) work(ctx context.Context, rng *rand.Rand) {
+	for !isDone(ctx) {
+		r.mu.Lock()
+		input := r.corpus[rng.Intn(len(r.corpus))]
+		other := r.corpus[rng.Intn(len(r.corpus))]
+		r.mu.Unlock()
+
+		data := append([]byte(nil), input...)
+		for i := rng.Intn(4); i >= 0; i-- {
+			data = mutate(rng, data, other)
+		}
+
+		res, crash := execute(r.target.Func, data)
+		atomic.AddUint64(&r.execs, 1)
+		if crash != "" {
+			if err := r.crashed(data, crash); err != nil {
+				r.mu.Lock()
+				r.err = err
+				r.mu.Unlock()
+				return
+			}
+			continue
+		}
+		if res == 1 && rng.Intn(16) == 0 {
+			r.keep(rng, data)
+		}
+	}
+}
+
+// crashed writes the input of a new panic to the crashers
+func (r *runner) crashed(data []byte, crash string) error {
+	// The first line is the panic value
+	key := strings.SplitN(crash, "\n", 2)[0]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen[key] {
+		return nil
+	}
+	r.seen[key] = true
+
+	path, err := WriteInput(r.crashers, data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".output", []byte(crash), 0644)
+}
+
+func (r *runner) keep(rng *rand.Rand, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.corpus) < maxCorpus {
+		r.corpus = append(r.corpus, data)
+		return
+	}
+	r.corpus[rng.Intn(len(r.corpus))] = data
+}
+
+// execute runs the target and returns the panic and its stack, if any
+func execute(f func([]byte) int, data []byte) (res int, crash string) {
+	defer func() {
+		if v := recover(); v != nil {
+			crash = fmt.Sprintf("panic: %v\n\n%s", v, debug.Stack())
+		}
+	}()
+	return f(data), ""
+}
+
+// mutate returns a mutation of the entry. Most mutations change the content
+// or an external id and encode the entry again, so the length prefixes of the
+// binary stay valid.
+func mutate(rng *rand.Rand, data, other []byte) []byte {
+	var e factom.Entry
+	if rng.Intn(4) == 0 || unmarshalEntry(&e, data) != nil {
+		return mutateBytes(rng, data, other)
+	}
+
+	var o factom.Entry
+	if unmarshalEntry(&o, other) == nil && len(o.Content) > 0 {
+		other = o.Content
+	}
+	if n := len(e.ExtIDs); n > 0 && rng.Intn(4) == 0 {
+		i := rng.Intn(n)
+		e.ExtIDs[i] = mutateBytes(rng, append([]byte(nil), e.ExtIDs[i]...), other)
+	} else {
+		e.Content = mutateBytes(rng, e.Content, other)
+	}
+	e.Hash = nil
+	out, err := e.MarshalBinary()
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// tokens are spliced into the json and protobuf of the entries
+var tokens = [][]byte{
+	[]byte(`"`), []byte(`{`), []byte(`}`), []byte(`[`), []byte(`]`), []byte(`,`), []byte(`:`),
+	[]byte(`null`), []byte(`true`), []byte(`-1`), []byte(`0`), []byte(`1e309`), []byte(`18446744073709551616`),
+	[]byte(`9223372036854775807`), []byte(`"amount":`), []byte(`"conversion":`), []byte(`"minoutput":`),
+	[]byte(`"notbefore":`), []byte(`"transfers":[]`), []byte(`"PEG"`), []byte(`"pFCT"`), []byte(`"pUSD"`),
+	{0x00}, {0xff}, {0x7f}, {0x80}, {0xff, 0xff, 0xff, 0xff, 0x0f},
+}
+
+func mutateBytes(rng *rand.Rand, data, other []byte) []byte {
+	if len(data) == 0 {
+		return append(data, tokens[rng.Intn(len(tokens))]...)
+	}
+	i := rng.Intn(len(data))
+	switch rng.Intn(7) {
+	case 0: // flip a bit
+		data[i] ^= 1 << uint(rng.Intn(8))
+	case 1: // set a byte
+		data[i] = byte(rng.Intn(256))
+	case 2: // delete a range
+		n := 1 + rng.Intn(min(len(data)-i, 16))
+		data = append(data[:i], data[i+n:]...)
+	case 3: // duplicate a range
+		n := 1 + rng.Intn(min(len(data)-i, 16))
+		data = insert(data, i, append([]byte(nil), data[i:i+n]...))
+	case 4: // insert a token
+		data = insert(data, i, tokens[rng.Intn(len(tokens))])
+	case 5: // splice in a range of the other input
+		if len(other) > 0 {
+			j := rng.Intn(len(other))
+			n := 1 + rng.Intn(min(len(other)-j, 64))
+			data = insert(data, i, other[j:j+n])
+		}
+	case 6: // replace a digit
+		if data[i] >= '0' && data[i] <= '9' {
+			data[i] = "0123456789"[rng.Intn(10)]
+		} else {
+			data[i] = '9'
+		}
+	}
+	return data
+}
+
+func insert(data []byte, i int, b []byte) []byte {
+	out := make([]byte, 0, len(data)+len(b))
+	out = append(out, data[:i]...)
+	out = append(out, b...)
+	return append(out, data[i:]...)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
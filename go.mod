@@ -5,13 +5,16 @@ go 1.13
 require (
 	github.com/AdamSLevy/jsonrpc2/v13 v13.0.1
 	github.com/Factom-Asset-Tokens/factom v0.0.0-20191114224337-71de98ff5b3e
+	github.com/ethereum/go-ethereum v1.9.9
 	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/pegnet/pegnet v0.4.1-0.20200203165724-3fc45a9a417a
 	github.com/rs/cors v1.7.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/viper v1.4.0
-	github.com/stretchr/testify v1.4.0
+	github.com/stretchr/testify v1.5.1
+	github.com/xitongsys/parquet-go v1.5.4
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 )
 
 replace github.com/Factom-Asset-Tokens/factom => github.com/Emyrk/factom v0.0.0-20200113153851-17d98c31e1bd
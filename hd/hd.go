@@ -0,0 +1,212 @@
+// Package hd derives the keys of FA addresses from a BIP39 mnemonic, so a
+// single seed phrase backs up every address. The keys are derived with BIP32
+// along the BIP44 path m/44'/131'/account'/0/index, the path of
+// factom-walletd and the hardware wallets, and the derived private keys are
+// the seeds of the ed25519 keys.
+package hd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Hardened is added to the index of a hardened child key
+	Hardened uint32 = 0x80000000
+	// Purpose is the BIP44 purpose
+	Purpose = 44
+	// CoinFactoid is the registered BIP44 coin type of factoid addresses
+	CoinFactoid = 131
+)
+
+// wordlist is the english wordlist and the index of every word
+var wordlist = strings.Fields(english)
+var wordIndex = make(map[string]int, len(wordlist))
+
+func init() {
+	for i, w := range wordlist {
+		wordIndex[w] = i
+	}
+}
+
+// NewMnemonic returns a random mnemonic of 12, 15, 18, 21, or 24 words
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("a mnemonic has 12, 15, 18, 21, or 24 words")
+	}
+	entropy := make([]byte, words/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyMnemonic(entropy)
+}
+
+// EntropyMnemonic returns the mnemonic of 16 to 32 bytes of entropy
+func EntropyMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("the entropy must be 16 to 32 bytes, in steps of 4")
+	}
+	// The checksum are the first bits of the hash, one bit per 4 bytes
+	hash := sha256.Sum256(entropy)
+	bits := new(big.Int).SetBytes(entropy)
+	checksum := len(entropy) / 4
+	bits.Lsh(bits, uint(checksum))
+	bits.Or(bits, big.NewInt(int64(hash[0]>>uint(8-checksum))))
+
+	words := make([]string, (len(entropy)*8+checksum)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = wordlist[new(big.Int).And(bits, mask).Int64()]
+		bits.Rsh(bits, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// ParseMnemonic normalizes the case and the spacing of the mnemonic, and
+// checks its words and its checksum
+func ParseMnemonic(mnemonic string) (string, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return "", fmt.Errorf("a mnemonic has 12, 15, 18, 21, or 24 words, not %d", len(words))
+	}
+
+	bits := new(big.Int)
+	for _, w := range words {
+		i, ok := wordIndex[w]
+		if !ok {
+			return "", fmt.Errorf("%q is not a word of the mnemonic wordlist", w)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(i)))
+	}
+	checksum := len(words) / 3
+	entropy := new(big.Int).Rsh(bits, uint(checksum)).Bytes()
+	padded := make([]byte, checksum*4)
+	copy(padded[len(padded)-len(entropy):], entropy)
+
+	normalized := strings.Join(words, " ")
+	if expected, _ := EntropyMnemonic(padded); expected != normalized {
+		return "", fmt.Errorf("invalid mnemonic checksum")
+	}
+	return normalized, nil
+}
+
+// Seed returns the BIP39 seed of the mnemonic, the passphrase is optional
+func Seed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic, err := ParseMnemonic(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New), nil
+}
+
+// Key is an extended BIP32 private key
+type Key struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMasterKey returns the master key of the seed
+func NewMasterKey(seed []byte) (*Key, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	if !validKey(sum[:32]) {
+		return nil, fmt.Errorf("the seed does not make a valid key")
+	}
+	return &Key{Key: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at the index, add Hardened for a hardened key
+func (k *Key) Child(index uint32) (*Key, error) {
+	var data []byte
+	if index >= Hardened {
+		data = append([]byte{0}, k.Key...)
+	} else {
+		data = k.publicKey()
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	if !validKey(sum[:32]) {
+		return nil, fmt.Errorf("the child %d is invalid, use the next index", index)
+	}
+
+	n := crypto.S256().Params().N
+	child := new(big.Int).SetBytes(sum[:32])
+	child.Add(child, new(big.Int).SetBytes(k.Key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, fmt.Errorf("the child %d is invalid, use the next index", index)
+	}
+	key := make([]byte, 32)
+	b := child.Bytes()
+	copy(key[32-len(b):], b)
+	return &Key{Key: key, ChainCode: sum[32:]}, nil
+}
+
+// Derive derives the child keys of the path in turn
+func (k *Key) Derive(path ...uint32) (*Key, error) {
+	var err error
+	for _, index := range path {
+		if k, err = k.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// publicKey is the compressed secp256k1 public key
+func (k *Key) publicKey() []byte {
+	priv, _ := crypto.ToECDSA(k.Key)
+	return crypto.CompressPubkey(&priv.PublicKey)
+}
+
+// validKey is false for keys that are 0 or not below the curve order
+func validKey(key []byte) bool {
+	i := new(big.Int).SetBytes(key)
+	return i.Sign() > 0 && i.Cmp(crypto.S256().Params().N) < 0
+}
+
+// FactoidPath is the BIP44 path of the address at the index of the account
+func FactoidPath(account, index uint32) []uint32 {
+	return []uint32{Purpose + Hardened, CoinFactoid + Hardened, account + Hardened, 0, index}
+}
+
+// FormatPath formats the path like m/44'/131'/0'/0/0
+func FormatPath(path []uint32) string {
+	parts := []string{"m"}
+	for _, index := range path {
+		if index >= Hardened {
+			parts = append(parts, fmt.Sprintf("%d'", index-Hardened))
+		} else {
+			parts = append(parts, fmt.Sprint(index))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// FactoidAddress derives the private key of the address at the index of the
+// account
+func FactoidAddress(master *Key, account, index uint32) (factom.FsAddress, error) {
+	var fs factom.FsAddress
+	k, err := master.Derive(FactoidPath(account, index)...)
+	if err != nil {
+		return fs, err
+	}
+	copy(fs[:], k.Key)
+	return fs, nil
+}
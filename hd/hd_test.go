@@ -0,0 +1,103 @@
+package hd_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	. "github.com/pegnet/pegnetd/hd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return data
+}
+
+// The vectors of BIP39 with the passphrase "TREZOR"
+func TestMnemonic(t *testing.T) {
+	vectors := []struct {
+		Entropy  string
+		Mnemonic string
+		Seed     string
+	}{
+		{"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+			"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+	}
+	for _, v := range vectors {
+		mnemonic, err := EntropyMnemonic(unhex(t, v.Entropy))
+		require.NoError(t, err)
+		assert.Equal(t, v.Mnemonic, mnemonic)
+
+		seed, err := Seed(strings.ToUpper(v.Mnemonic)+" ", "TREZOR")
+		require.NoError(t, err)
+		assert.Equal(t, v.Seed, hex.EncodeToString(seed))
+	}
+
+	_, err := ParseMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	assert.Error(t, err, "checksum")
+	_, err = ParseMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon pegnet")
+	assert.Error(t, err, "unknown word")
+	_, err = ParseMnemonic("abandon about")
+	assert.Error(t, err, "too short")
+
+	for _, words := range []int{12, 24} {
+		mnemonic, err := NewMnemonic(words)
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(mnemonic), words)
+		_, err = ParseMnemonic(mnemonic)
+		assert.NoError(t, err)
+	}
+	_, err = NewMnemonic(13)
+	assert.Error(t, err)
+}
+
+// Test vector 1 of BIP32
+func TestKey_Derive(t *testing.T) {
+	master, err := NewMasterKey(unhex(t, "000102030405060708090a0b0c0d0e0f"))
+	require.NoError(t, err)
+	assert.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", hex.EncodeToString(master.Key))
+	assert.Equal(t, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", hex.EncodeToString(master.ChainCode))
+
+	k, err := master.Derive(Hardened)
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", hex.EncodeToString(k.Key))
+	assert.Equal(t, "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", hex.EncodeToString(k.ChainCode))
+
+	k, err = master.Derive(Hardened, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", hex.EncodeToString(k.Key))
+	assert.Equal(t, "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19", hex.EncodeToString(k.ChainCode))
+}
+
+func TestFactoidAddress(t *testing.T) {
+	assert.Equal(t, "m/44'/131'/2'/0/7", FormatPath(FactoidPath(2, 7)))
+
+	seed, err := Seed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	require.NoError(t, err)
+	master, err := NewMasterKey(seed)
+	require.NoError(t, err)
+
+	first, err := FactoidAddress(master, 0, 0)
+	require.NoError(t, err)
+	second, err := FactoidAddress(master, 0, 1)
+	require.NoError(t, err)
+	other, err := FactoidAddress(master, 1, 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.NotEqual(t, first, other)
+
+	// The derivation is deterministic
+	again, err := FactoidAddress(master, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, second, again)
+}
@@ -0,0 +1,129 @@
+// Package metrics pushes time-series statistics of the node to a push based
+// monitoring stack, either as InfluxDB line protocol or as statsd gauges.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Point is a single measurement at a time
+type Point struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]float64
+	Time   time.Time
+}
+
+// GlobalRPC records the latencies of the rpc methods. It has to be
+// importable from the api server and the pusher.
+var GlobalRPC = NewLatencies()
+
+// Latencies aggregates the durations of calls per method until they are
+// collected
+type Latencies struct {
+	mu      sync.Mutex
+	methods map[string]*latency
+}
+
+type latency struct {
+	count int
+	sum   time.Duration
+	max   time.Duration
+}
+
+func NewLatencies() *Latencies {
+	return &Latencies{methods: make(map[string]*latency)}
+}
+
+// Observe records the duration of a call
+func (l *Latencies) Observe(method string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, ok := l.methods[method]
+	if !ok {
+		m = new(latency)
+		l.methods[method] = m
+	}
+	m.count++
+	m.sum += d
+	if d > m.max {
+		m.max = d
+	}
+}
+
+// Collect returns one point per method called since the last collection,
+// with the call count and the mean and max latencies in milliseconds, and
+// resets the aggregates
+func (l *Latencies) Collect(name string, now time.Time) []Point {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	methods := make([]string, 0, len(l.methods))
+	for method := range l.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	points := make([]Point, 0, len(methods))
+	for _, method := range methods {
+		m := l.methods[method]
+		points = append(points, Point{
+			Name: name,
+			Tags: map[string]string{"method": method},
+			Fields: map[string]float64{
+				"count":   float64(m.count),
+				"mean_ms": float64(m.sum) / float64(m.count) / float64(time.Millisecond),
+				"max_ms":  float64(m.max) / float64(time.Millisecond),
+			},
+			Time: now,
+		})
+	}
+	l.methods = make(map[string]*latency)
+	return points
+}
+
+// GlobalSlow counts the queries and blocks that took longer than their
+// thresholds. It has to be importable from the database and the pusher.
+var GlobalSlow = NewCounters()
+
+// GlobalPanics counts the panics of the rpc methods by method
+var GlobalPanics = NewCounters()
+
+// Counters counts events by name until they are collected
+type Counters struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewCounters() *Counters {
+	return &Counters{counts: make(map[string]int)}
+}
+
+// Inc counts one event
+func (c *Counters) Inc(name string) {
+	c.mu.Lock()
+	c.counts[name]++
+	c.mu.Unlock()
+}
+
+// Collect returns a point with a field per counter, counters without events
+// since the last collection are zero. The counters are reset.
+func (c *Counters) Collect(name string, now time.Time, counters ...string) []Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fields := make(map[string]float64, len(counters))
+	for _, counter := range counters {
+		fields[counter] = float64(c.counts[counter])
+	}
+	for counter, n := range c.counts {
+		fields[counter] = float64(n)
+	}
+	c.counts = make(map[string]int)
+	if len(fields) == 0 {
+		return nil
+	}
+	return []Point{{Name: name, Fields: fields, Time: now}}
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/pegnet/pegnetd/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+var testPoint = Point{
+	Name:   "asset_volume",
+	Tags:   map[string]string{"asset": "pUSD"},
+	Fields: map[string]float64{"transfer": 1.5, "converted_in": 2},
+	Time:   time.Unix(100, 0),
+}
+
+func TestLatencies_Collect(t *testing.T) {
+	l := NewLatencies()
+	l.Observe("get-sync-status", 10*time.Millisecond)
+	l.Observe("get-sync-status", 30*time.Millisecond)
+	l.Observe("get-rates", time.Millisecond)
+
+	points := l.Collect("rpc", time.Unix(1, 0))
+	require.Len(t, points, 2)
+	require.Equal(t, "get-rates", points[0].Tags["method"])
+	require.Equal(t, "get-sync-status", points[1].Tags["method"])
+	require.Equal(t, float64(2), points[1].Fields["count"])
+	require.Equal(t, float64(20), points[1].Fields["mean_ms"])
+	require.Equal(t, float64(30), points[1].Fields["max_ms"])
+
+	require.Empty(t, l.Collect("rpc", time.Unix(2, 0)), "collect resets")
+}
+
+func TestCounters_Collect(t *testing.T) {
+	c := NewCounters()
+	c.Inc("queries")
+	c.Inc("queries")
+
+	points := c.Collect("slow", time.Unix(1, 0), "queries", "blocks")
+	require.Len(t, points, 1)
+	require.Equal(t, map[string]float64{"queries": 2, "blocks": 0}, points[0].Fields)
+
+	points = c.Collect("slow", time.Unix(2, 0), "queries")
+	require.Len(t, points, 1)
+	require.Equal(t, float64(0), points[0].Fields["queries"], "collect resets")
+	require.Empty(t, c.Collect("slow", time.Unix(3, 0)))
+}
+
+func TestNewPusher(t *testing.T) {
+	_, err := NewPusher("prometheus", "udp://localhost:8125", time.Second, "")
+	require.Error(t, err)
+	_, err = NewPusher(FormatStatsd, "http://localhost:8086/write", time.Second, "")
+	require.Error(t, err, "statsd over http")
+	_, err = NewPusher(FormatInflux, "udp://localhost:8089", 0, "")
+	require.Error(t, err)
+	_, err = NewPusher(FormatInflux, "http://localhost:8086/write?db=pegnet", time.Second, "")
+	require.NoError(t, err)
+}
+
+func TestPusher_Statsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	p, err := NewPusher(FormatStatsd, "udp://"+conn.LocalAddr().String(), time.Second, "pegnetd")
+	require.NoError(t, err)
+	require.NoError(t, p.Push(context.Background(), []Point{testPoint}))
+
+	buf := make([]byte, 1500)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "pegnetd.asset_volume.pUSD.converted_in:2|g\npegnetd.asset_volume.pUSD.transfer:1.5|g\n", string(buf[:n]))
+}
+
+func TestPusher_InfluxHTTP(t *testing.T) {
+	body := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body <- string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	p, err := NewPusher(FormatInflux, ts.URL+"/write?db=pegnet", time.Second, "pegnetd")
+	require.NoError(t, err)
+	require.NoError(t, p.Push(context.Background(), []Point{testPoint}))
+	require.Equal(t, "pegnetd_asset_volume,asset=pUSD converted_in=2,transfer=1.5 100000000000\n", <-body)
+
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	})
+	err = p.Push(context.Background(), []Point{testPoint})
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "database not found"))
+}
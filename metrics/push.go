@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The supported formats
+const (
+	FormatInflux = "influx"
+	FormatStatsd = "statsd"
+)
+
+// udpPacketSize keeps the datagrams below the usual MTU
+const udpPacketSize = 1400
+
+// Source returns the points of one push
+type Source func(ctx context.Context) []Point
+
+// Pusher sends the points of its sources every interval. InfluxDB line
+// protocol is sent either over udp or to the http write endpoint, statsd
+// only over udp.
+type Pusher struct {
+	Format   string
+	URL      *url.URL
+	Interval time.Duration
+	// Prefix is prepended to all measurement names
+	Prefix string
+	Client *http.Client
+}
+
+// NewPusher creates a pusher for an url like "udp://localhost:8125" or
+// "http://localhost:8086/write?db=pegnet"
+func NewPusher(format, rawurl string, interval time.Duration, prefix string) (*Pusher, error) {
+	if format != FormatInflux && format != FormatStatsd {
+		return nil, fmt.Errorf("metrics format must be either %q or %q", FormatInflux, FormatStatsd)
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case u.Scheme == "udp":
+	case (u.Scheme == "http" || u.Scheme == "https") && format == FormatInflux:
+	default:
+		return nil, fmt.Errorf("metrics url %s: unsupported scheme for %s", rawurl, format)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("metrics interval must be positive")
+	}
+	return &Pusher{Format: format, URL: u, Interval: interval, Prefix: prefix, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Run pushes the points of the sources until the context is cancelled.
+// Failed pushes are logged and dropped.
+func (p *Pusher) Run(ctx context.Context, sources ...Source) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var points []Point
+		for _, source := range sources {
+			points = append(points, source(ctx)...)
+		}
+		if err := p.Push(ctx, points); err != nil {
+			log.WithError(err).WithField("url", p.URL.Host).Warn("failed to push metrics")
+		}
+	}
+}
+
+// Push sends the points
+func (p *Pusher) Push(ctx context.Context, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, point := range points {
+		if p.Format == FormatInflux {
+			lines = append(lines, p.influxLine(point))
+		} else {
+			lines = append(lines, p.statsdLines(point)...)
+		}
+	}
+
+	if p.URL.Scheme == "udp" {
+		return p.sendUDP(lines)
+	}
+	return p.sendHTTP(ctx, lines)
+}
+
+func (p *Pusher) name(name, sep string) string {
+	if p.Prefix == "" {
+		return name
+	}
+	return p.Prefix + sep + name
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxLine formats a point as "name,tag=value field=1 <unix nanoseconds>"
+func (p *Pusher) influxLine(point Point) string {
+	var sb strings.Builder
+	sb.WriteString(influxEscaper.Replace(p.name(point.Name, "_")))
+	for _, k := range sortedKeys(point.Tags) {
+		fmt.Fprintf(&sb, ",%s=%s", influxEscaper.Replace(k), influxEscaper.Replace(point.Tags[k]))
+	}
+	for i, k := range sortedFields(point.Fields) {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&sb, "%s%s=%s", sep, influxEscaper.Replace(k), strconv.FormatFloat(point.Fields[k], 'f', -1, 64))
+	}
+	fmt.Fprintf(&sb, " %d", point.Time.UnixNano())
+	return sb.String()
+}
+
+var statsdEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", " ", "_", ".", "_")
+
+// statsdLines formats every field of a point as a gauge, the tag values are
+// part of the name: "prefix.name.tagvalue.field:1|g"
+func (p *Pusher) statsdLines(point Point) []string {
+	name := p.name(point.Name, ".")
+	for _, k := range sortedKeys(point.Tags) {
+		name += "." + statsdEscaper.Replace(point.Tags[k])
+	}
+	lines := make([]string, 0, len(point.Fields))
+	for _, k := range sortedFields(point.Fields) {
+		lines = append(lines, fmt.Sprintf("%s.%s:%s|g", name, statsdEscaper.Replace(k), strconv.FormatFloat(point.Fields[k], 'f', -1, 64)))
+	}
+	return lines
+}
+
+// sendUDP sends the lines in as few datagrams as possible
+func (p *Pusher) sendUDP(lines []string) error {
+	conn, err := net.Dial("udp", p.URL.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > udpPacketSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		packet.WriteString(line)
+		packet.WriteByte('\n')
+	}
+	_, err = conn.Write(packet.Bytes())
+	return err
+}
+
+func (p *Pusher) sendHTTP(ctx context.Context, lines []string) error {
+	req, err := http.NewRequest(http.MethodPost, p.URL.String(), strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("influxdb returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFields(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
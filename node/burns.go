@@ -1,15 +1,54 @@
 package node
 
-import "encoding/hex"
+import (
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
 
 var (
-	// BurnAddress is the mainnet burn address
-	BurnAddress = "EC2BURNFCT2PEGNETooo1oooo1oooo1oooo1oooo1oooo19wthin"
+	// BurnAddress is the burn address of the network, set by Network.Apply
+	BurnAddress = MainNet.BurnAddress
 	// BurnRCD is the rcd representation of the burn address
 	BurnRCD = [32]byte{}
 )
 
 func init() {
-	mr, _ := hex.DecodeString("37399721298d77984585040ea61055377039a4c3f3e2cd48c46ff643d50fd64f")
-	copy(BurnRCD[:], mr[:])
+	burn, _ := factom.NewECAddress(BurnAddress)
+	BurnRCD = burn
+}
+
+// checkBurn checks a factoid transaction against the requirements of a burn:
+// a single input, and a single entry credit output of 0 to the burn address.
+// toBurn is false if the transaction does not pay to the burn address at all,
+// the problem is empty if it is a valid burn.
+func checkBurn(tx factom.FactoidTransaction) (problem pegnet.BurnProblem, detail string, toBurn bool) {
+	var ecBurn, fctBurn int
+	for _, out := range tx.ECOutputs {
+		if BurnRCD == out.Address {
+			ecBurn++
+		}
+	}
+	for _, out := range tx.FCTOutputs {
+		if BurnRCD == out.Address {
+			fctBurn++
+		}
+	}
+	if ecBurn == 0 && fctBurn == 0 {
+		return "", "", false
+	}
+
+	switch {
+	case ecBurn == 0:
+		return pegnet.BurnFCTOutput, "the factoids were sent to the burn address as a factoid output, a burn is an entry credit output", true
+	case len(tx.FCTInputs) != 1:
+		return pegnet.BurnInputs, fmt.Sprintf("the transaction has %d inputs, a burn has exactly 1", len(tx.FCTInputs)), true
+	case len(tx.ECOutputs) != 1 || len(tx.FCTOutputs) > 0:
+		return pegnet.BurnOutputs, fmt.Sprintf("the transaction has %d entry credit and %d factoid outputs, a burn has only the entry credit output to the burn address",
+			len(tx.ECOutputs), len(tx.FCTOutputs)), true
+	case tx.ECOutputs[0].Amount != 0:
+		return pegnet.BurnECAmount, fmt.Sprintf("%d factoshis bought entry credits at the burn address, the output of a burn is 0", tx.ECOutputs[0].Amount), true
+	}
+	return "", "", true
 }
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"sort"
+
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// PEGRequest is a conversion into PEG that is paid out of the bank
+type PEGRequest struct {
+	// TxID is the [TxIndex]-[EntryHash] of the conversion, it decides who
+	// gets the dust
+	TxID string
+	Tx   fat2.Transaction
+}
+
+// PEGPayout is the result of a PEG request under the conversion limit
+type PEGPayout struct {
+	TxID string
+	// Requested is the PEG the conversion yields without the limit
+	Requested uint64
+	Yield     uint64
+	// Refund is in the input asset of the conversion
+	Refund int64
+	// BelowMinOutput is set if the prorated yield is below the minimum
+	// output of the conversion. Nothing is paid and the full input is
+	// refunded.
+	BelowMinOutput bool
+}
+
+// PayoutPEGRequests splits the bank between the PEG requests in proportion to
+// the PEG they request at the rates, and computes the refund of every
+// request. The requests have to be valid conversions at the rates. The
+// payouts are sorted by txid, along with the total PEG requested.
+//
+// A request whose prorated yield is below its minimum output is refunded its
+// full input instead. Its share goes back to the bank, which is split again
+// between the other requests, and its PEG is not part of the total
+// requested.
+func PayoutPEGRequests(requests []PEGRequest, rates map[fat2.PTicker]uint64, bank uint64) ([]PEGPayout, uint64, error) {
+	requested := make(map[string]uint64, len(requests))
+	txs := make(map[string]fat2.Transaction, len(requests))
+	for _, req := range requests {
+		// The conversion was checked before, so we can ignore the error
+		pegAmt, _ := conversions.Convert(int64(req.Tx.Input.Amount), rates[req.Tx.Input.Type], rates[req.Tx.Conversion])
+		requested[req.TxID], txs[req.TxID] = uint64(pegAmt), req.Tx
+	}
+
+	payouts := make([]PEGPayout, 0, len(requests))
+	// The share of a request only grows when others are removed, so this
+	// ends once every remaining request is paid at least its minimum
+	for {
+		// limit calculates how much each PEG each tx is allocted
+		limit := conversions.NewConversionSupply(bank)
+		for _, req := range requests {
+			if err := limit.AddConversion(req.TxID, requested[req.TxID]); err != nil {
+				return nil, 0, err
+			}
+		}
+		shares := limit.Payouts()
+
+		remaining := requests[:0:0]
+		for _, req := range requests {
+			if shares[req.TxID] < req.Tx.MinOutput {
+				payouts = append(payouts, PEGPayout{TxID: req.TxID, Requested: requested[req.TxID],
+					Refund: int64(req.Tx.Input.Amount), BelowMinOutput: true})
+			} else {
+				remaining = append(remaining, req)
+			}
+		}
+		if len(remaining) < len(requests) {
+			requests = remaining
+			continue
+		}
+
+		for txid, pegYield := range shares {
+			tx := txs[txid]
+			payouts = append(payouts, PEGPayout{TxID: txid, Requested: requested[txid], Yield: pegYield,
+				Refund: conversions.Refund(int64(tx.Input.Amount), int64(pegYield), rates[tx.Input.Type], rates[tx.Conversion])})
+		}
+		sort.Slice(payouts, func(i, j int) bool { return payouts[i].TxID < payouts[j].TxID })
+		return payouts, limit.TotalRequested(), nil
+	}
+}
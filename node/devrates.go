@@ -0,0 +1,123 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// RateOverrides are the rates injected at heights, by ticker. The rates are
+// in the units of the synced rates, 1e8 per USD.
+type RateOverrides map[uint32]map[fat2.PTicker]uint64
+
+// ParseRateOverride converts the rates of a request or a rates file to
+// tickers. The assets are named like "pUSD", "USD", or "PEG".
+func ParseRateOverride(rates map[string]uint64) (map[fat2.PTicker]uint64, error) {
+	res := make(map[fat2.PTicker]uint64, len(rates))
+	for name, value := range rates {
+		ticker := fat2.StringToTicker(name)
+		if ticker == fat2.PTickerInvalid {
+			ticker = fat2.StringToTicker("p" + name)
+		}
+		if ticker == fat2.PTickerInvalid {
+			return nil, fmt.Errorf("%s is not an asset", name)
+		}
+		res[ticker] = value
+	}
+	return res, nil
+}
+
+// LoadRateOverrides reads a JSON file of the rates to inject by height, like
+// {"1000": {"pUSD": 100000000, "PEG": 250000}}
+func LoadRateOverrides(path string) (RateOverrides, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file map[uint32]map[string]uint64
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("rates file %s: %v", path, err)
+	}
+	res := make(RateOverrides, len(file))
+	for height, rates := range file {
+		parsed, err := ParseRateOverride(rates)
+		if err != nil {
+			return nil, fmt.Errorf("rates file %s, height %d: %v", path, height, err)
+		}
+		res[height] = parsed
+	}
+	return res, nil
+}
+
+// Heights returns the heights with injected rates in ascending order
+func (o RateOverrides) Heights() []uint32 {
+	heights := make([]uint32, 0, len(o))
+	for height := range o {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+// CanInjectRates is false if the node syncs a chain of MainNet, whatever the
+// name of its network is. The rates of every other network can be injected
+// for development.
+func CanInjectRates() bool {
+	opr, transactions := MainNet.Chains()
+	return OPRChain != opr && TransactionChain != transactions
+}
+
+// SetRateOverride injects the rates at a height that is not synced yet. They
+// replace the graded rates of the height, assets that are left out keep their
+// graded rate. A height without an OPR winner gets the most recent rates with
+// the injected ones applied, and its conversions are executed. Empty rates
+// remove the override of the height.
+func (d *Pegnetd) SetRateOverride(height uint32, rates map[fat2.PTicker]uint64) error {
+	if !CanInjectRates() {
+		return fmt.Errorf("rates cannot be injected on %s", MainNet.Name)
+	}
+	if synced := d.GetCurrentSync(); height <= synced {
+		return fmt.Errorf("height %d is already synced, the next height is %d", height, synced+1)
+	}
+
+	d.overridesMu.Lock()
+	defer d.overridesMu.Unlock()
+	if len(rates) == 0 {
+		delete(d.rateOverrides, height)
+		return nil
+	}
+	if d.rateOverrides == nil {
+		d.rateOverrides = make(RateOverrides)
+	}
+	d.rateOverrides[height] = rates
+	return nil
+}
+
+// RateOverrides returns a copy of the injected rates that are not synced yet
+func (d *Pegnetd) RateOverrides() RateOverrides {
+	synced := d.GetCurrentSync()
+	d.overridesMu.RLock()
+	defer d.overridesMu.RUnlock()
+	res := make(RateOverrides)
+	for height, rates := range d.rateOverrides {
+		if height <= synced {
+			continue
+		}
+		cpy := make(map[fat2.PTicker]uint64, len(rates))
+		for ticker, value := range rates {
+			cpy[ticker] = value
+		}
+		res[height] = cpy
+	}
+	return res
+}
+
+// rateOverride returns the injected rates of the height, nil if there are none
+func (d *Pegnetd) rateOverride(height uint32) map[fat2.PTicker]uint64 {
+	d.overridesMu.RLock()
+	defer d.overridesMu.RUnlock()
+	return d.rateOverrides[height]
+}
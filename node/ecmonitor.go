@@ -0,0 +1,202 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pegnet/pegnetd/node/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+// ECHistoryLimit is the amount of balance samples the ECMonitor keeps
+const ECHistoryLimit = 1440
+
+// ECCostWindow is how far back the submitted entries are averaged for the
+// cost of a transaction
+const ECCostWindow = 7 * 24 * time.Hour
+
+// ECMonitor tracks the balance of the EC keys that pay for the entries of the
+// node. A balance below MinBalance, or that only pays for less than
+// MinTransactions more transactions, is logged and sent to the notifiers with
+// the "ecbalance" trigger, before send-transaction starts failing for a lack
+// of EC.
+type ECMonitor struct {
+	// Balance returns the total balance of the keys
+	Balance func(ctx context.Context) (uint64, error)
+	// MinTransactions is the amount of transactions the balance should
+	// still pay for, 0 disables the warning
+	MinTransactions uint64
+	// MinBalance is the balance in EC that is considered low, 0 disables
+	// the warning
+	MinBalance uint64
+	// Notifier is nil if no notifiers are configured
+	Notifier *notify.Service
+	// Cost returns the average EC cost of a transaction, nil or a cost
+	// below 1 counts 1 EC
+	Cost func() float64
+
+	mu      sync.Mutex
+	history []ECSample
+	cost    float64
+	err     string
+	low     bool
+}
+
+// ECSample is the balance of the EC keys at a time
+type ECSample struct {
+	Time    time.Time `json:"time"`
+	Balance uint64    `json:"balance"`
+}
+
+// ECStatus is the total balance of the EC keys as seen by the last check.
+// The burn rate is the EC spent per hour over the history, top ups are not
+// counted.
+type ECStatus struct {
+	Balance         uint64       `json:"balance"`
+	Checked         time.Time    `json:"checked,omitempty"`
+	TxCost          float64      `json:"txcost"`
+	Remaining       uint64       `json:"remainingtransactions"`
+	MinTransactions uint64       `json:"mintransactions"`
+	MinBalance      uint64       `json:"minbalance"`
+	Low             bool         `json:"low"`
+	BurnRate        float64      `json:"burnrate"`
+	EmptyIn         string       `json:"emptyin,omitempty"`
+	Error           string       `json:"error,omitempty"`
+	Keys            []ECKeyStats `json:"keys"`
+	History         []ECSample   `json:"history"`
+}
+
+// Run checks the balance every interval until the context is cancelled
+func (m *ECMonitor) Run(ctx context.Context, interval time.Duration) {
+	m.Poll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.Poll(ctx)
+	}
+}
+
+// Poll fetches the balance and checks it. A failed fetch is kept as the error
+// of the status, the last balance stays.
+func (m *ECMonitor) Poll(ctx context.Context) {
+	balance, err := m.Balance(ctx)
+	if err != nil {
+		m.mu.Lock()
+		m.err = err.Error()
+		m.mu.Unlock()
+		log.WithError(err).Debug("ec monitor: failed to fetch the balance")
+		return
+	}
+	cost := 1.0
+	if m.Cost != nil {
+		cost = m.Cost()
+	}
+	m.Check(ctx, balance, cost, time.Now())
+}
+
+// Check records the balance and reports when the amount of transactions it
+// still pays for drops below MinTransactions, and when it is topped up
+func (m *ECMonitor) Check(ctx context.Context, balance uint64, cost float64, now time.Time) {
+	m.mu.Lock()
+	m.history = append(m.history, ECSample{Time: now, Balance: balance})
+	if len(m.history) > ECHistoryLimit {
+		m.history = m.history[len(m.history)-ECHistoryLimit:]
+	}
+	m.cost, m.err = math.Max(cost, 1), ""
+	remaining := m.remaining(balance)
+	low := m.MinTransactions > 0 && remaining < m.MinTransactions || balance < m.MinBalance
+	changed := low != m.low
+	m.low = low
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	fields := log.Fields{"balance": balance, "remaining": remaining}
+	if low {
+		log.WithFields(fields).Warn("EC BALANCE LOW: top up the ec keys before the transactions fail")
+		if m.notify() {
+			m.Notifier.Send(ctx, notify.TriggerECBalance, fmt.Sprintf("pegnetd: entry credit balance is low: %d EC, about %d transactions left",
+				balance, remaining))
+		}
+		return
+	}
+	log.WithFields(fields).Info("ec balance topped up")
+	if m.notify() {
+		m.Notifier.Resolved(ctx, notify.TriggerECBalance, fmt.Sprintf("pegnetd: entry credit balance is back to %d EC, about %d transactions left",
+			balance, remaining))
+	}
+}
+
+func (m *ECMonitor) notify() bool {
+	return m.Notifier != nil && m.Notifier.Triggers[notify.TriggerECBalance]
+}
+
+// remaining is the amount of transactions the balance pays for, the lock
+// must be held
+func (m *ECMonitor) remaining(balance uint64) uint64 {
+	return uint64(float64(balance) / m.cost)
+}
+
+// burnRate is the EC spent per hour over the history, the lock must be held
+func (m *ECMonitor) burnRate() float64 {
+	if len(m.history) < 2 {
+		return 0
+	}
+	var spent uint64
+	for i := 1; i < len(m.history); i++ {
+		if prev, cur := m.history[i-1].Balance, m.history[i].Balance; cur < prev {
+			spent += prev - cur
+		}
+	}
+	hours := m.history[len(m.history)-1].Time.Sub(m.history[0].Time).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return float64(spent) / hours
+}
+
+// Status returns the balance of the last check with its history, oldest
+// first
+func (m *ECMonitor) Status() ECStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := ECStatus{
+		TxCost:          m.cost,
+		MinTransactions: m.MinTransactions,
+		MinBalance:      m.MinBalance,
+		Low:             m.low,
+		BurnRate:        m.burnRate(),
+		Error:           m.err,
+		History:         append([]ECSample{}, m.history...),
+	}
+	if len(m.history) == 0 {
+		return status
+	}
+	last := m.history[len(m.history)-1]
+	status.Balance, status.Checked = last.Balance, last.Time
+	status.Remaining = m.remaining(last.Balance)
+	// A balance that lasts for years has no estimate, it would overflow
+	if hours := float64(last.Balance) / status.BurnRate; status.BurnRate > 0 && hours < 24*365 {
+		status.EmptyIn = time.Duration(hours * float64(time.Hour)).Round(time.Minute).String()
+	}
+	return status
+}
+
+// transactionCost is the average EC cost of the entries submitted within the
+// ECCostWindow
+func (d *Pegnetd) transactionCost() float64 {
+	avg, count, err := d.Pegnet.SelectSendAuditCost(nil, time.Now().Add(-ECCostWindow))
+	if err != nil || count == 0 {
+		return 1
+	}
+	return avg
+}
@@ -0,0 +1,272 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/keystore"
+	"github.com/pegnet/pegnetd/node/pkcs11"
+	"github.com/pegnet/pegnetd/node/signer"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrECExhausted is returned if no EC key of the pool has the balance for an
+// entry
+var ErrECExhausted = errors.New("no ec key has the balance for the entry")
+
+// ECKeyStats are the usage of an EC key of the pool since the node started.
+// A remote key is held by the signer, an HSM key by the PKCS#11 token. The
+// balance is the one of the last check, and Exhausted how often the key
+// did not have the balance for an entry while it was active.
+type ECKeyStats struct {
+	Address   factom.ECAddress `json:"address"`
+	Active    bool             `json:"active"`
+	Locked    bool             `json:"locked,omitempty"`
+	Remote    bool             `json:"remote,omitempty"`
+	HSM       bool             `json:"hsm,omitempty"`
+	Balance   uint64           `json:"balance"`
+	Checked   time.Time        `json:"checked,omitempty"`
+	Entries   uint64           `json:"entries"`
+	Spent     uint64           `json:"spent"`
+	Exhausted uint64           `json:"exhausted"`
+	LastUsed  time.Time        `json:"lastused,omitempty"`
+}
+
+// ecPool keeps the active EC key and the usage of the keys. The keys are read
+// from the config on every use, so they can be rotated with reload-config.
+type ecPool struct {
+	mu     sync.Mutex
+	active factom.ECAddress
+	stats  map[factom.ECAddress]*ECKeyStats
+}
+
+func (p *ecPool) statsOf(ec factom.ECAddress) *ECKeyStats {
+	if p.stats == nil {
+		p.stats = make(map[factom.ECAddress]*ECKeyStats)
+	}
+	s, ok := p.stats[ec]
+	if !ok {
+		s = &ECKeyStats{Address: ec}
+		p.stats[ec] = s
+	}
+	return s
+}
+
+// ECKey pays for entries, the key is either local or held by the signer
+type ECKey interface {
+	ECAddress() factom.ECAddress
+	// ComposeCreate commits and reveals the entry, see
+	// factom.Entry.ComposeCreate
+	ComposeCreate(ctx context.Context, c *factom.Client, e *factom.Entry) (factom.Bytes32, error)
+}
+
+// localECKey is an EC key the node holds
+type localECKey factom.EsAddress
+
+func (k localECKey) ECAddress() factom.ECAddress {
+	return factom.EsAddress(k).ECAddress()
+}
+
+func (k localECKey) ComposeCreate(ctx context.Context, c *factom.Client, e *factom.Entry) (factom.Bytes32, error) {
+	return e.ComposeCreate(ctx, c, factom.EsAddress(k))
+}
+
+// ecKey is a key of the pool, the key of a locked keystore is not usable. A
+// remote key is held by the signer, an hsm key by the PKCS#11 token.
+type ecKey struct {
+	address factom.ECAddress
+	es      factom.EsAddress
+	locked  bool
+	remote  bool
+	hsm     *pkcs11.Key
+}
+
+// ecKeys returns the keys of the pool in order: the keystore or ECPrivateKey,
+// ECPrivateKeys, the keys of the token, then the keys of the signer. A key is
+// only listed once.
+func (d *Pegnetd) ecKeys() ([]ecKey, error) {
+	var keys []ecKey
+	seen := make(map[factom.ECAddress]bool)
+	add := func(k ecKey) {
+		if !seen[k.address] {
+			seen[k.address] = true
+			keys = append(keys, k)
+		}
+	}
+
+	if d.Keystore != nil {
+		es, err := d.Keystore.Key()
+		if err != nil && err != keystore.ErrLocked {
+			return nil, err
+		}
+		add(ecKey{address: d.Keystore.Address(), es: es, locked: err == keystore.ErrLocked})
+	} else if d.ConfigString(config.ECPrivateKey) != "" {
+		es, err := d.ECPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		add(ecKey{address: es.ECAddress(), es: es})
+	}
+	for _, str := range d.ConfigStrings(config.ECPrivateKeys) {
+		var es factom.EsAddress
+		if err := es.Set(str); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", config.ECPrivateKeys, err)
+		}
+		add(ecKey{address: es.ECAddress(), es: es})
+	}
+	if d.HSM != nil {
+		for _, r := range d.HSM.Refs {
+			if k, ok := d.HSM.Key(r.Address); ok && r.IsEC() {
+				add(ecKey{address: factom.ECAddress(bytes32(k.PublicKey())), hsm: k})
+			}
+		}
+	}
+	for _, str := range d.ConfigStrings(config.SignerECAddresses) {
+		var ec factom.ECAddress
+		if err := ec.Set(str); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", config.SignerECAddresses, err)
+		}
+		add(ecKey{address: ec, remote: true})
+	}
+	return keys, nil
+}
+
+// ECAddresses are the addresses of the EC keys of the pool, including the
+// key of a locked keystore
+func (d *Pegnetd) ECAddresses() []factom.ECAddress {
+	keys, err := d.ecKeys()
+	if err != nil {
+		return nil
+	}
+	addresses := make([]factom.ECAddress, len(keys))
+	for i, k := range keys {
+		addresses[i] = k.address
+	}
+	return addresses
+}
+
+// PickECKey returns the key that pays for an entry of the cost. The active
+// key is used until its balance is exhausted, then the pool rotates to the
+// next key with enough balance. Returns keystore.ErrLocked if the locked
+// keystore is the only key, and ErrECExhausted if no key has the balance.
+func (d *Pegnetd) PickECKey(ctx context.Context, cost uint8) (ECKey, error) {
+	keys, err := d.ecKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no %s, %s, %s, or %s is configured", config.ECPrivateKey, config.ECKeystore, config.ECPrivateKeys,
+			config.SignerECAddresses)
+	}
+
+	d.ecPool.mu.Lock()
+	active := d.ecPool.active
+	d.ecPool.mu.Unlock()
+	start := 0
+	for i, k := range keys {
+		if k.address == active {
+			start = i
+		}
+	}
+
+	locked := 0
+	for i := range keys {
+		k := keys[(start+i)%len(keys)]
+		if k.locked {
+			locked++
+			continue
+		}
+		balance, err := k.address.GetBalance(ctx, d.FactomClient)
+		if err != nil {
+			return nil, err
+		}
+		d.ecPool.mu.Lock()
+		s := d.ecPool.statsOf(k.address)
+		s.Balance, s.Checked = balance, time.Now()
+		if balance < uint64(cost) {
+			if k.address == d.ecPool.active {
+				s.Exhausted++
+			}
+			d.ecPool.mu.Unlock()
+			continue
+		}
+		d.ecPool.mu.Unlock()
+
+		key := ECKey(localECKey(k.es))
+		if k.hsm != nil {
+			key = signer.NewECKey(k.hsm.PublicKey(), hsmSign(k.hsm))
+		}
+		if k.remote {
+			// The signer must hold the key before the pool switches to it
+			if key, err = d.signerECKey(ctx, k.address); err != nil {
+				return nil, err
+			}
+		}
+
+		d.ecPool.mu.Lock()
+		rotated := d.ecPool.active != k.address
+		previous := d.ecPool.active
+		d.ecPool.active = k.address
+		d.ecPool.mu.Unlock()
+
+		if rotated && previous != (factom.ECAddress{}) {
+			log.WithFields(log.Fields{"from": previous, "to": k.address, "balance": balance}).Warn("rotated the ec key, the previous one is exhausted")
+		}
+		return key, nil
+	}
+	if locked == len(keys) {
+		return nil, keystore.ErrLocked
+	}
+	return nil, ErrECExhausted
+}
+
+// RecordECUse counts an entry the key paid for
+func (d *Pegnetd) RecordECUse(ec factom.ECAddress, cost uint8) {
+	d.ecPool.mu.Lock()
+	defer d.ecPool.mu.Unlock()
+	s := d.ecPool.statsOf(ec)
+	s.Entries++
+	s.Spent += uint64(cost)
+	s.LastUsed = time.Now()
+	if s.Balance >= uint64(cost) {
+		s.Balance -= uint64(cost)
+	}
+}
+
+// ECKeyStats returns the usage of the keys of the pool, in the order of the
+// pool
+func (d *Pegnetd) ECKeyStats() []ECKeyStats {
+	keys, _ := d.ecKeys()
+	d.ecPool.mu.Lock()
+	defer d.ecPool.mu.Unlock()
+	stats := make([]ECKeyStats, 0, len(keys))
+	for _, k := range keys {
+		s := *d.ecPool.statsOf(k.address)
+		s.Active, s.Locked, s.Remote, s.HSM = k.address == d.ecPool.active, k.locked, k.remote, k.hsm != nil
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// ecBalance fetches the balances of every key of the pool and returns their
+// sum, it is the balance of the ECMonitor
+func (d *Pegnetd) ecBalance(ctx context.Context) (uint64, error) {
+	var total uint64
+	for _, ec := range d.ECAddresses() {
+		balance, err := ec.GetBalance(ctx, d.FactomClient)
+		if err != nil {
+			return 0, err
+		}
+		d.ecPool.mu.Lock()
+		s := d.ecPool.statsOf(ec)
+		s.Balance, s.Checked = balance, time.Now()
+		d.ecPool.mu.Unlock()
+		total += balance
+	}
+	return total, nil
+}
@@ -0,0 +1,227 @@
+// Package events publishes the activity of every synced block to external
+// systems, so consumers do not have to poll the RPC.
+//
+// Delivery is at most once: events that can not be delivered are logged and
+// dropped, consumers that need every record can backfill through the RPC.
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The types of events
+const (
+	TypeBlock       = "block"
+	TypeTransaction = "transaction"
+	TypeConversion  = "conversion"
+	TypeCoinbase    = "coinbase"
+	TypeBurn        = "burn"
+	TypeDeposit     = "deposit"
+	TypeAlert       = "alert"
+	// TypeMalformedBurn is a transaction to the burn address that was not
+	// credited
+	TypeMalformedBurn = "malformedburn"
+)
+
+// Block is the summary of a synced block. Rates are only set if the block
+// was graded.
+type Block struct {
+	Height    uint32            `json:"height"`
+	Timestamp time.Time         `json:"timestamp"`
+	Rates     map[string]uint64 `json:"rates,omitempty"`
+	Actions   int               `json:"actions"`
+}
+
+// BlockEvents is everything that was applied in a synced block
+type BlockEvents struct {
+	Block   Block
+	Actions []pegnet.HistoryTransaction
+	// Deposits are the deposits to watched addresses that were confirmed
+	Deposits []pegnet.Deposit
+	// Alerts are the actions that matched an alert rule
+	Alerts []pegnet.Alert
+	// MalformedBurns are the inputs of the transactions to the burn address
+	// that are not a valid burn
+	MalformedBurns []pegnet.MalformedBurn
+}
+
+// Event is a single published message
+type Event struct {
+	Type   string      `json:"type"`
+	Height uint32      `json:"height"`
+	Data   interface{} `json:"data"`
+}
+
+// ActionType returns the event type of a history action
+func ActionType(action pegnet.HistoryAction) string {
+	switch action {
+	case pegnet.Conversion:
+		return TypeConversion
+	case pegnet.Coinbase:
+		return TypeCoinbase
+	case pegnet.FCTBurn:
+		return TypeBurn
+	}
+	return TypeTransaction
+}
+
+// Addresses returns the addresses touched by an action, the sender first
+func Addresses(a pegnet.HistoryTransaction) []string {
+	touched := make([]string, 0, len(a.Outputs)+1)
+	if a.FromAddress != nil {
+		touched = append(touched, a.FromAddress.String())
+	}
+	for _, o := range a.Outputs {
+		touched = append(touched, o.Address.String())
+	}
+	return touched
+}
+
+// Events flattens the block into messages, the block itself is first
+// followed by the actions, the confirmed deposits, the alerts, and the
+// malformed burns
+func (b *BlockEvents) Events() []Event {
+	evts := make([]Event, 0, len(b.Actions)+len(b.Deposits)+len(b.Alerts)+len(b.MalformedBurns)+1)
+	evts = append(evts, Event{Type: TypeBlock, Height: b.Block.Height, Data: b.Block})
+	for _, a := range b.Actions {
+		evts = append(evts, Event{Type: ActionType(a.TxAction), Height: b.Block.Height, Data: a})
+	}
+	for _, d := range b.Deposits {
+		evts = append(evts, Event{Type: TypeDeposit, Height: b.Block.Height, Data: d})
+	}
+	for _, a := range b.Alerts {
+		evts = append(evts, Event{Type: TypeAlert, Height: b.Block.Height, Data: a})
+	}
+	for _, m := range b.MalformedBurns {
+		evts = append(evts, Event{Type: TypeMalformedBurn, Height: b.Block.Height, Data: m})
+	}
+	return evts
+}
+
+// Sink is an external system the events are published to
+type Sink interface {
+	// Name is used to identify the sink in logs
+	Name() string
+	// Publish delivers the events of one block
+	Publish(ctx context.Context, b *BlockEvents) error
+	Close() error
+}
+
+// Dispatcher hands the events to all sinks in its own routine, so a slow or
+// unreachable sink does not hold up the sync
+type Dispatcher struct {
+	sinks []Sink
+	queue chan *BlockEvents
+}
+
+// NewDispatcher creates a dispatcher that buffers up to `size` blocks
+func NewDispatcher(size int, sinks ...Sink) *Dispatcher {
+	if size <= 0 {
+		size = 1
+	}
+	return &Dispatcher{sinks: sinks, queue: make(chan *BlockEvents, size)}
+}
+
+// Sinks returns the sinks the events are published to
+func (d *Dispatcher) Sinks() []Sink {
+	return d.sinks
+}
+
+// Publish queues the events of a block. If the queue is full, the block is
+// dropped.
+func (d *Dispatcher) Publish(b *BlockEvents) {
+	select {
+	case d.queue <- b:
+	default:
+		log.WithField("height", b.Block.Height).Warn("event queue full, dropping block events")
+	}
+}
+
+// Run delivers the queued events until the context is cancelled, then closes
+// all sinks
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer func() {
+		for _, s := range d.sinks {
+			if err := s.Close(); err != nil {
+				log.WithError(err).WithField("sink", s.Name()).Warn("failed to close event sink")
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b := <-d.queue:
+			for _, s := range d.sinks {
+				if err := s.Publish(ctx, b); err != nil {
+					log.WithError(err).WithFields(log.Fields{"sink": s.Name(), "height": b.Block.Height}).Error("failed to publish block events")
+				}
+			}
+		}
+	}
+}
+
+// SinksFromConfig creates all sinks that are enabled in the config
+func SinksFromConfig(conf *viper.Viper) ([]Sink, error) {
+	var sinks []Sink
+	if u := conf.GetString(config.NATSURL); u != "" {
+		n, err := NewNATS(u, conf.GetString(config.NATSSubject))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, n)
+	}
+	if proxy := conf.GetString(config.KafkaRESTProxy); proxy != "" {
+		k, err := NewKafka(proxy, conf.GetString(config.KafkaTopic))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, k)
+	}
+	if u := conf.GetString(config.MQTTURL); u != "" {
+		m, err := NewMQTT(u, conf.GetString(config.MQTTPrefix), conf.GetString(config.MQTTClientID))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, m)
+	}
+	if u := conf.GetString(config.DepositWebhook); u != "" {
+		w, err := NewWebhook(u, TypeDeposit)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, w)
+	}
+	if u := conf.GetString(config.AlertWebhook); u != "" {
+		w, err := NewWebhook(u, TypeAlert, TypeMalformedBurn)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, w)
+	}
+
+	endpoints := map[string]string{
+		ZMQTopicHeight:  conf.GetString(config.ZMQPubHeight),
+		ZMQTopicHashTx:  conf.GetString(config.ZMQPubHashTx),
+		ZMQTopicRawTx:   conf.GetString(config.ZMQPubRawTx),
+		ZMQTopicAddress: conf.GetString(config.ZMQPubAddress),
+	}
+	for _, endpoint := range endpoints {
+		if endpoint != "" {
+			z, err := NewZMQ(endpoints)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, z)
+			break
+		}
+	}
+	return sinks, nil
+}
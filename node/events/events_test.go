@@ -0,0 +1,299 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	. "github.com/pegnet/pegnetd/node/events"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBlock() *BlockEvents {
+	return &BlockEvents{
+		Block: Block{Height: 10, Actions: 2},
+		Actions: []pegnet.HistoryTransaction{
+			{TxAction: pegnet.Conversion, FromAsset: "pUSD", FromAmount: 5},
+			{TxAction: pegnet.FCTBurn, FromAsset: "FCT", FromAmount: 7},
+		},
+	}
+}
+
+func TestBlockEvents_Events(t *testing.T) {
+	b := testBlock()
+	b.MalformedBurns = []pegnet.MalformedBurn{{Height: 10, Amount: 3, Reason: pegnet.BurnInputs}}
+	evts := b.Events()
+	require.Len(t, evts, 4)
+	assert.Equal(t, TypeBlock, evts[0].Type)
+	assert.Equal(t, TypeConversion, evts[1].Type)
+	assert.Equal(t, TypeBurn, evts[2].Type)
+	assert.Equal(t, TypeMalformedBurn, evts[3].Type)
+	for _, e := range evts {
+		assert.Equal(t, uint32(10), e.Height)
+	}
+}
+
+func TestNATS_Publish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	subjects := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {}\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB"):
+				subjects <- strings.Fields(line)[1]
+				if _, err := r.ReadString('\n'); err != nil { // payload
+					return
+				}
+			}
+		}
+	}()
+
+	n, err := NewNATS("nats://"+l.Addr().String(), "pegnet")
+	require.NoError(t, err)
+	defer n.Close()
+	require.NoError(t, n.Publish(context.Background(), testBlock()))
+
+	assert.Equal(t, "pegnet.block", <-subjects)
+	assert.Equal(t, "pegnet.conversion", <-subjects)
+	assert.Equal(t, "pegnet.burn", <-subjects)
+}
+
+func TestKafka_Publish(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/pegnet", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(data, &body))
+	}))
+	defer srv.Close()
+
+	k, err := NewKafka(srv.URL+"/", "pegnet")
+	require.NoError(t, err)
+	require.NoError(t, k.Publish(context.Background(), testBlock()))
+	require.Len(t, body.Records, 3)
+	assert.Equal(t, "10", body.Records[0].Key)
+	assert.Equal(t, TypeBlock, body.Records[0].Value.Type)
+	assert.Equal(t, TypeBurn, body.Records[2].Value.Type)
+
+	k.Topic = "missing"
+	srv.Config.Handler = http.NotFoundHandler()
+	assert.Error(t, k.Publish(context.Background(), testBlock()))
+}
+
+// zmqSub is a minimal ZMTP 3.0 SUB socket
+func zmqSub(t *testing.T, addr string, topic string) *bufio.Reader {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9], greeting[10] = 0xFF, 0x7F, 3
+	copy(greeting[12:], "NULL")
+	_, err = conn.Write(greeting)
+	require.NoError(t, err)
+
+	ready := append([]byte{0x04, 25, 5}, "READY"...)
+	ready = append(ready, 11)
+	ready = append(ready, "Socket-Type"...)
+	ready = append(ready, 0, 0, 0, 3)
+	ready = append(ready, "SUB"...)
+	_, err = conn.Write(ready)
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	peer := make([]byte, 64)
+	_, err = io.ReadFull(r, peer)
+	require.NoError(t, err)
+	// READY of the publisher
+	_, err = r.ReadByte()
+	require.NoError(t, err)
+	size, err := r.ReadByte()
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, make([]byte, size))
+	require.NoError(t, err)
+
+	sub := append([]byte{0x00, byte(len(topic) + 1), 0x01}, topic...)
+	_, err = conn.Write(sub)
+	require.NoError(t, err)
+	return r
+}
+
+func TestZMQ_Publish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	z, err := NewZMQ(map[string]string{ZMQTopicHeight: "tcp://" + addr, ZMQTopicHashTx: "tcp://" + addr})
+	require.NoError(t, err)
+	defer z.Close()
+
+	r := zmqSub(t, addr, ZMQTopicHeight)
+	// The subscription is processed asynchronously, so publish until the
+	// first message arrives
+	received := make(chan [][]byte)
+	go func() {
+		var frames [][]byte
+		for {
+			flags, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			size, _ := r.ReadByte()
+			body := make([]byte, size)
+			io.ReadFull(r, body)
+			frames = append(frames, body)
+			if flags&0x01 == 0 {
+				received <- frames
+				return
+			}
+		}
+	}()
+
+	var frames [][]byte
+	for deadline := time.Now().Add(5 * time.Second); frames == nil; {
+		require.True(t, time.Now().Before(deadline), "no message received")
+		require.NoError(t, z.Publish(context.Background(), testBlock()))
+		select {
+		case frames = <-received:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	require.Len(t, frames, 3)
+	assert.Equal(t, ZMQTopicHeight, string(frames[0]))
+	assert.Equal(t, []byte{10, 0, 0, 0}, frames[1])
+	assert.Len(t, frames[2], 4)
+}
+
+func TestMQTT_Publish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	type publish struct {
+		topic   string
+		payload string
+		retain  bool
+	}
+	received := make(chan publish, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			header, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			var size, shift int
+			for {
+				b, _ := r.ReadByte()
+				size |= int(b&0x7F) << shift
+				shift += 7
+				if b&0x80 == 0 {
+					break
+				}
+			}
+			body := make([]byte, size)
+			io.ReadFull(r, body)
+			switch header & 0xF0 {
+			case 0x10: // CONNECT
+				conn.Write([]byte{0x20, 2, 0, 0})
+			case 0x30: // PUBLISH
+				n := int(body[0])<<8 | int(body[1])
+				received <- publish{string(body[2 : 2+n]), string(body[2+n:]), header&0x01 != 0}
+			}
+		}
+	}()
+
+	m, err := NewMQTT("tcp://"+l.Addr().String(), "pegnet", "")
+	require.NoError(t, err)
+	defer m.Close()
+
+	var fa factom.FAAddress
+	b := &BlockEvents{
+		Block:   Block{Height: 10, Rates: map[string]uint64{"pUSD": 1e8 + 5}},
+		Actions: []pegnet.HistoryTransaction{{TxAction: pegnet.Conversion, FromAddress: &fa}},
+	}
+	require.NoError(t, m.Publish(context.Background(), b))
+
+	p := <-received
+	assert.Equal(t, "pegnet/address/"+fa.String()+"/tx", p.topic)
+	assert.False(t, p.retain)
+	var evt Event
+	require.NoError(t, json.Unmarshal([]byte(p.payload), &evt))
+	assert.Equal(t, TypeConversion, evt.Type)
+
+	p = <-received
+	assert.Equal(t, publish{"pegnet/rates/pUSD", "1.00000005", true}, p)
+}
+
+func TestWebhook_Publish(t *testing.T) {
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var evt Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&evt))
+		received = append(received, evt)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL, TypeDeposit)
+	require.NoError(t, err)
+	b := testBlock()
+	b.Deposits = []pegnet.Deposit{{Amount: 5}, {Amount: 7}}
+	require.NoError(t, w.Publish(context.Background(), b))
+	require.Len(t, received, 2)
+	assert.Equal(t, TypeDeposit, received[0].Type)
+	assert.Equal(t, uint32(10), received[1].Height)
+
+	_, err = NewWebhook("ftp://localhost", TypeDeposit)
+	assert.Error(t, err)
+
+	// The url can be changed while the node runs
+	var moved int
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moved++
+	}))
+	defer other.Close()
+	require.Error(t, w.SetURL("ftp://localhost"))
+	require.NoError(t, w.SetURL(other.URL))
+	require.NoError(t, w.Publish(context.Background(), b))
+	assert.Equal(t, 2, moved)
+	assert.Len(t, received, 2)
+}
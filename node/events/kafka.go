@@ -0,0 +1,80 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kafka publishes the events of a block to a topic through a Kafka REST
+// proxy, in a single request. The records are keyed by the block height, so
+// all events of a block end up in the same partition and stay in order.
+type Kafka struct {
+	Proxy  string
+	Topic  string
+	Client *http.Client
+}
+
+// NewKafka creates a Kafka sink for the REST proxy at the url,
+// eg: "http://localhost:8082"
+func NewKafka(proxy, topic string) (*Kafka, error) {
+	if proxy == "" {
+		return nil, fmt.Errorf("kafka rest proxy url must not be empty")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("kafka topic must not be empty")
+	}
+	return &Kafka{
+		Proxy:  strings.TrimRight(proxy, "/"),
+		Topic:  topic,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (k *Kafka) Name() string { return "kafka" }
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+func (k *Kafka) Publish(ctx context.Context, b *BlockEvents) error {
+	evts := b.Events()
+	records := make([]kafkaRecord, len(evts))
+	key := fmt.Sprintf("%d", b.Block.Height)
+	for i, evt := range evts {
+		records[i] = kafkaRecord{Key: key, Value: evt}
+	}
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.Proxy+"/topics/"+k.Topic, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("kafka proxy returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (k *Kafka) Close() error { return nil }
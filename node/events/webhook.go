@@ -0,0 +1,122 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookAttempts is how often the delivery of an event is attempted before
+// it is dropped
+const webhookAttempts = 3
+
+// Webhook POSTs every event of the given types as json to an url, one
+// request per event. Any 2xx response is a successful delivery, failed
+// deliveries are retried with a backoff.
+type Webhook struct {
+	URL    string
+	Types  map[string]bool
+	Client *http.Client
+
+	// mu guards the URL, which can be changed while the node runs
+	mu sync.RWMutex
+}
+
+// NewWebhook creates a webhook for the events of the types
+func NewWebhook(rawurl string, types ...string) (*Webhook, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook url %s: must be http:// or https://", rawurl)
+	}
+	w := &Webhook{URL: rawurl, Types: make(map[string]bool), Client: &http.Client{Timeout: 10 * time.Second}}
+	for _, t := range types {
+		w.Types[t] = true
+	}
+	return w, nil
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+// SetURL changes where the following events are delivered to
+func (w *Webhook) SetURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url %s: must be http:// or https://", rawurl)
+	}
+	w.mu.Lock()
+	w.URL = rawurl
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *Webhook) target() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.URL
+}
+
+// Publish delivers all events, a failed event does not stop the delivery of
+// the others. The last error is returned.
+func (w *Webhook) Publish(ctx context.Context, b *BlockEvents) error {
+	var failed error
+	for _, evt := range b.Events() {
+		if !w.Types[evt.Type] {
+			continue
+		}
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+
+		for attempt := 1; ; attempt++ {
+			err = w.post(ctx, data)
+			if err == nil || attempt == webhookAttempts {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		if err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func (w *Webhook) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.target(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (w *Webhook) Close() error { return nil }
@@ -0,0 +1,359 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The ZMQ topics, modeled after the notifications of bitcoind
+const (
+	// ZMQTopicHeight is the height of every synced block, as 4 bytes little endian
+	ZMQTopicHeight = "height"
+	// ZMQTopicHashTx is the 32 byte entry hash of every applied transaction
+	ZMQTopicHashTx = "hashtx"
+	// ZMQTopicRawTx is the json of every applied action
+	ZMQTopicRawTx = "rawtx"
+	// ZMQTopicAddress is every address touched by an action, as a string
+	ZMQTopicAddress = "address"
+)
+
+// ZMQ publishes notifications on ZMQ PUB sockets. Every message has three
+// frames: the topic, the body, and a 4 byte little endian sequence number
+// per topic. Topics can share an endpoint, subscribers filter by topic.
+//
+// The sockets speak ZMTP 3.0 with the NULL mechanism, which is supported by
+// all current ZMQ libraries.
+type ZMQ struct {
+	publishers map[string]*zmqPublisher // by endpoint
+	topics     map[string]*zmqPublisher // by topic
+}
+
+// NewZMQ binds the endpoints of the topics, eg:
+// {"height": "tcp://127.0.0.1:28332"}. Topics without an endpoint are not
+// published.
+func NewZMQ(endpoints map[string]string) (*ZMQ, error) {
+	z := &ZMQ{publishers: make(map[string]*zmqPublisher), topics: make(map[string]*zmqPublisher)}
+	for topic, endpoint := range endpoints {
+		if endpoint == "" {
+			continue
+		}
+		pub, ok := z.publishers[endpoint]
+		if !ok {
+			addr := strings.TrimPrefix(endpoint, "tcp://")
+			if addr == endpoint {
+				z.Close()
+				return nil, fmt.Errorf("zmq endpoint %s: only tcp:// is supported", endpoint)
+			}
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				z.Close()
+				return nil, err
+			}
+			pub = &zmqPublisher{listener: l, seq: make(map[string]uint32)}
+			go pub.accept()
+			z.publishers[endpoint] = pub
+		}
+		z.topics[topic] = pub
+	}
+	return z, nil
+}
+
+func (z *ZMQ) Name() string { return "zmq" }
+
+func (z *ZMQ) Publish(ctx context.Context, b *BlockEvents) error {
+	if pub, ok := z.topics[ZMQTopicHeight]; ok {
+		height := make([]byte, 4)
+		binary.LittleEndian.PutUint32(height, b.Block.Height)
+		pub.send(ZMQTopicHeight, height)
+	}
+
+	hashes := make(map[string]bool)
+	addresses := make(map[string]bool)
+	for _, a := range b.Actions {
+		if pub, ok := z.topics[ZMQTopicHashTx]; ok && a.Hash != nil && !hashes[a.Hash.String()] {
+			hashes[a.Hash.String()] = true
+			pub.send(ZMQTopicHashTx, a.Hash[:])
+		}
+		if pub, ok := z.topics[ZMQTopicRawTx]; ok {
+			data, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			pub.send(ZMQTopicRawTx, data)
+		}
+		if pub, ok := z.topics[ZMQTopicAddress]; ok {
+			for _, adr := range Addresses(a) {
+				if !addresses[adr] {
+					addresses[adr] = true
+					pub.send(ZMQTopicAddress, []byte(adr))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (z *ZMQ) Close() error {
+	for _, pub := range z.publishers {
+		pub.close()
+	}
+	return nil
+}
+
+// zmqPublisher is a bound PUB socket
+type zmqPublisher struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	subs []*zmqSubscriber
+	seq  map[string]uint32
+}
+
+func (p *zmqPublisher) accept() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return // closed
+		}
+		go func() {
+			sub, err := zmqHandshake(conn)
+			if err != nil {
+				log.WithError(err).WithField("sink", "zmq").Debug("zmq handshake failed")
+				conn.Close()
+				return
+			}
+			p.mu.Lock()
+			p.subs = append(p.subs, sub)
+			p.mu.Unlock()
+			sub.read()
+			p.remove(sub)
+		}()
+	}
+}
+
+// send delivers the message to all matching subscribers. Subscribers that
+// can not keep up are disconnected.
+func (p *zmqPublisher) send(topic string, body []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, p.seq[topic])
+	p.seq[topic]++
+
+	var msg bytes.Buffer
+	zmqWriteFrame(&msg, 0x01, []byte(topic))
+	zmqWriteFrame(&msg, 0x01, body)
+	zmqWriteFrame(&msg, 0x00, seq)
+
+	for _, sub := range p.subs {
+		if !sub.subscribed([]byte(topic)) {
+			continue
+		}
+		_ = sub.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err := sub.conn.Write(msg.Bytes()); err != nil {
+			sub.conn.Close() // read() returns and removes it
+		}
+	}
+}
+
+func (p *zmqPublisher) remove(sub *zmqSubscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.subs {
+		if p.subs[i] == sub {
+			p.subs = append(p.subs[:i], p.subs[i+1:]...)
+			break
+		}
+	}
+	sub.conn.Close()
+}
+
+func (p *zmqPublisher) close() {
+	p.listener.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, sub := range p.subs {
+		sub.conn.Close()
+	}
+}
+
+type zmqSubscriber struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu            sync.Mutex
+	subscriptions [][]byte
+}
+
+func (s *zmqSubscriber) subscribed(topic []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, prefix := range s.subscriptions {
+		if bytes.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// read processes the (un)subscriptions of the subscriber until the
+// connection is closed
+func (s *zmqSubscriber) read() {
+	for {
+		flags, body, err := zmqReadFrame(s.r)
+		if err != nil {
+			return
+		}
+
+		var subscribe bool
+		var prefix []byte
+		switch {
+		case flags&0x04 != 0: // ZMTP 3.1 commands
+			name, data := zmqParseCommand(body)
+			if name != "SUBSCRIBE" && name != "CANCEL" {
+				continue
+			}
+			subscribe, prefix = name == "SUBSCRIBE", data
+		case len(body) > 0: // ZMTP 3.0 messages
+			subscribe, prefix = body[0] == 0x01, body[1:]
+		default:
+			continue
+		}
+
+		s.mu.Lock()
+		if subscribe {
+			s.subscriptions = append(s.subscriptions, append([]byte{}, prefix...))
+		} else {
+			for i := range s.subscriptions {
+				if bytes.Equal(s.subscriptions[i], prefix) {
+					s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+					break
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// zmqHandshake exchanges the greeting and READY commands as a PUB socket
+func zmqHandshake(conn net.Conn) (*zmqSubscriber, error) {
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xFF, 0x7F
+	greeting[10], greeting[11] = 3, 0 // version 3.0
+	copy(greeting[12:], "NULL")
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xFF || peer[9] != 0x7F || peer[10] < 3 {
+		return nil, fmt.Errorf("unsupported zmtp greeting")
+	}
+	if string(bytes.TrimRight(peer[12:32], "\x00")) != "NULL" {
+		return nil, fmt.Errorf("unsupported zmtp mechanism")
+	}
+
+	var ready bytes.Buffer
+	ready.WriteByte(5)
+	ready.WriteString("READY")
+	zmqWriteProperty(&ready, "Socket-Type", "PUB")
+	var frame bytes.Buffer
+	zmqWriteFrame(&frame, 0x04, ready.Bytes())
+	if _, err := conn.Write(frame.Bytes()); err != nil {
+		return nil, err
+	}
+
+	flags, body, err := zmqReadFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if name, _ := zmqParseCommand(body); flags&0x04 == 0 || name != "READY" {
+		return nil, fmt.Errorf("expected zmtp READY command")
+	}
+	if !bytes.Contains(body, []byte("SUB")) {
+		return nil, fmt.Errorf("peer is not a SUB socket")
+	}
+	return &zmqSubscriber{conn: conn, r: r}, nil
+}
+
+// zmqWriteFrame writes a frame with the flags, the size flag is added if
+// needed
+func zmqWriteFrame(w *bytes.Buffer, flags byte, body []byte) {
+	if len(body) > 255 {
+		w.WriteByte(flags | 0x02)
+		size := make([]byte, 8)
+		binary.BigEndian.PutUint64(size, uint64(len(body)))
+		w.Write(size)
+	} else {
+		w.WriteByte(flags)
+		w.WriteByte(byte(len(body)))
+	}
+	w.Write(body)
+}
+
+// zmqMaxFrame limits the frames accepted from subscribers
+const zmqMaxFrame = 1 << 16
+
+func zmqReadFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&0x02 != 0 {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf)
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > zmqMaxFrame {
+		return 0, nil, fmt.Errorf("zmtp frame too large")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+func zmqParseCommand(body []byte) (string, []byte) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil
+	}
+	return string(body[1 : 1+body[0]]), body[1+body[0]:]
+}
+
+func zmqWriteProperty(w *bytes.Buffer, name, value string) {
+	w.WriteByte(byte(len(name)))
+	w.WriteString(name)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(value)))
+	w.Write(size)
+	w.WriteString(value)
+}
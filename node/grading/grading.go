@@ -0,0 +1,93 @@
+// Package grading is the registry of the OPR grading versions. Every version
+// is an implementation of grader.BlockGrader, the node grades a block with
+// the version the activation table selects for its height.
+//
+// The versions of the pegnet grader module are registered from the start.
+// The next version, or an experimental one for a private network, is added
+// with Register and activated at a height by the "gradings" of the network.
+package grading
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pegnet/pegnet/modules/grader"
+)
+
+// Factory returns the grader of a block at the height, it is given the
+// winners of the previous graded block
+type Factory func(height int32, prevWinners []string) (grader.BlockGrader, error)
+
+var (
+	mu       sync.RWMutex
+	versions = make(map[uint8]Factory)
+)
+
+func init() {
+	for ver := uint8(1); ver <= 4; ver++ {
+		ver := ver
+		if err := Register(ver, func(height int32, prevWinners []string) (grader.BlockGrader, error) {
+			return grader.NewGrader(ver, height, prevWinners)
+		}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds the factory of a grading version. A version can only be
+// registered once.
+func Register(version uint8, f Factory) error {
+	if version == 0 {
+		return fmt.Errorf("grading version 0 is invalid")
+	}
+	if f == nil {
+		return fmt.Errorf("grading version %d has no factory", version)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := versions[version]; ok {
+		return fmt.Errorf("grading version %d is already registered", version)
+	}
+	versions[version] = f
+	return nil
+}
+
+// Registered returns true if the grading version is registered
+func Registered(version uint8) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := versions[version]
+	return ok
+}
+
+// Versions returns the registered grading versions in order
+func Versions() []uint8 {
+	mu.RLock()
+	defer mu.RUnlock()
+	vers := make([]uint8, 0, len(versions))
+	for ver := range versions {
+		vers = append(vers, ver)
+	}
+	sort.Slice(vers, func(i, j int) bool { return vers[i] < vers[j] })
+	return vers
+}
+
+// New returns the grader of the version for a block at the height
+func New(version uint8, height int32, prevWinners []string) (grader.BlockGrader, error) {
+	mu.RLock()
+	f, ok := versions[version]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("grading version %d is not registered", version)
+	}
+	g, err := f(height, prevWinners)
+	if err != nil {
+		return nil, err
+	}
+	// The version is stored with the graded block
+	if g.Version() != version {
+		return nil, fmt.Errorf("the grader of grading version %d reports version %d", version, g.Version())
+	}
+	return g, nil
+}
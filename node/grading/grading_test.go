@@ -0,0 +1,58 @@
+package grading
+
+import (
+	"testing"
+
+	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// experimental grades like the V4 grading, under its own version
+type experimental struct {
+	grader.BlockGrader
+	version uint8
+}
+
+func (e experimental) Version() uint8 { return e.version }
+
+func TestBuiltin(t *testing.T) {
+	require.Equal(t, []uint8{1, 2, 3, 4}, Versions()[:4])
+	for _, ver := range []uint8{1, 2, 3, 4} {
+		g, err := New(ver, 100, nil)
+		require.NoError(t, err)
+		assert.Equal(t, ver, g.Version())
+		assert.Equal(t, int32(100), g.Height())
+	}
+	_, err := New(4, -1, nil)
+	assert.Error(t, err)
+	_, err = New(200, 100, nil)
+	assert.EqualError(t, err, "grading version 200 is not registered")
+}
+
+func TestRegister(t *testing.T) {
+	assert.Error(t, Register(0, func(int32, []string) (grader.BlockGrader, error) { return nil, nil }))
+	assert.Error(t, Register(4, func(int32, []string) (grader.BlockGrader, error) { return nil, nil }))
+	assert.Error(t, Register(5, nil))
+
+	newExperimental := func(version uint8) Factory {
+		return func(height int32, prevWinners []string) (grader.BlockGrader, error) {
+			g, err := grader.NewGrader(4, height, prevWinners)
+			if err != nil {
+				return nil, err
+			}
+			return experimental{BlockGrader: g, version: version}, nil
+		}
+	}
+	require.NoError(t, Register(250, newExperimental(250)))
+	assert.True(t, Registered(250))
+	assert.Contains(t, Versions(), uint8(250))
+	g, err := New(250, 100, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(250), g.Version())
+
+	// The grader has to report the version it is registered as
+	require.NoError(t, Register(251, newExperimental(4)))
+	_, err = New(251, 100, nil)
+	assert.EqualError(t, err, "the grader of grading version 251 reports version 4")
+}
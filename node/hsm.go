@@ -0,0 +1,79 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/pkcs11"
+	"github.com/pegnet/pegnetd/node/signer"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// HSM holds the keys of PKCS11Keys on the PKCS#11 token
+type HSM struct {
+	Token *pkcs11.Token
+	// Refs are the keys in the order of the config
+	Refs []pkcs11.KeyRef
+	keys map[string]*pkcs11.Key
+}
+
+// OpenHSM logs in to the token of the config and finds its keys, the public
+// key of every key must be the key of its address. Returns nil if no module
+// is configured.
+func OpenHSM(conf *viper.Viper) (*HSM, error) {
+	module := conf.GetString(config.PKCS11Module)
+	if module == "" {
+		return nil, nil
+	}
+	var refs []pkcs11.KeyRef
+	for _, str := range conf.GetStringSlice(config.PKCS11Keys) {
+		r, err := pkcs11.ParseKeyRef(str)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+
+	token, err := pkcs11.Open(module, conf.GetString(config.PKCS11Token), conf.GetString(config.PKCS11PIN))
+	if err != nil {
+		return nil, err
+	}
+	h := &HSM{Token: token, Refs: refs, keys: make(map[string]*pkcs11.Key)}
+	for _, r := range refs {
+		k, err := token.Key(r)
+		if err == nil {
+			err = r.Check(k.PublicKey())
+		}
+		if err != nil {
+			token.Close()
+			return nil, fmt.Errorf("%s: %v", r.Address, err)
+		}
+		h.keys[r.Address] = k
+		log.WithFields(log.Fields{"address": r.Address, "key": r.String()}).Info("Found the key on the pkcs11 token")
+	}
+	return h, nil
+}
+
+// Key returns the key of the EC or FA address, false if the token does not
+// hold it
+func (h *HSM) Key(address string) (*pkcs11.Key, bool) {
+	if h == nil {
+		return nil, false
+	}
+	k, ok := h.keys[address]
+	return k, ok
+}
+
+// hsmSign signs with the key of the token
+func hsmSign(k *pkcs11.Key) signer.SignFunc {
+	return func(_ context.Context, payload []byte) ([]byte, error) {
+		return k.Sign(payload)
+	}
+}
+
+func bytes32(b []byte) (out [32]byte) {
+	copy(out[:], b)
+	return
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/keystore"
+	log "github.com/sirupsen/logrus"
+)
+
+// openKeystore opens the keystore of the config, and unlocks it if the
+// passphrase is configured
+func (d *Pegnetd) openKeystore() error {
+	path := d.Config.GetString(config.ECKeystore)
+	if path == "" {
+		return nil
+	}
+	ks, err := keystore.Open(path)
+	if err != nil {
+		return err
+	}
+	if passphrase := d.Config.GetString(config.ECKeystorePassphrase); passphrase != "" {
+		if err := ks.Unlock(passphrase); err != nil {
+			return fmt.Errorf("%s: %v", config.ECKeystorePassphrase, err)
+		}
+	}
+	d.Keystore = ks
+	log.WithFields(log.Fields{"file": path, "address": ks.Address(), "locked": ks.Locked()}).Info("Opened the ec keystore")
+	return nil
+}
+
+// ECPrivateKey returns the key that pays for the entries of the node, the
+// ECPrivateKey of the config or the key of the keystore
+func (d *Pegnetd) ECPrivateKey() (factom.EsAddress, error) {
+	if d.Keystore != nil {
+		return d.Keystore.Key()
+	}
+	var es factom.EsAddress
+	if err := es.Set(d.ConfigString(config.ECPrivateKey)); err != nil {
+		return es, fmt.Errorf("invalid ECPrivateKey: %s", err.Error())
+	}
+	return es, nil
+}
@@ -0,0 +1,147 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+// The scrypt parameters of the Ethereum V3 keystores. The light parameters
+// are only used for the tests.
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// CryptoJSON is the encrypted data of an Ethereum V3 keystore, only the
+// scrypt kdf and the aes-128-ctr cipher are supported
+type CryptoJSON struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams CipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    ScryptParams `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+// CipherParams are the parameters of the aes-128-ctr cipher
+type CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// ScryptParams are the parameters of the scrypt kdf
+type ScryptParams struct {
+	DKLen int    `json:"dklen"`
+	N     int    `json:"n"`
+	P     int    `json:"p"`
+	R     int    `json:"r"`
+	Salt  string `json:"salt"`
+}
+
+// encryptData encrypts the data with a key derived from the passphrase by
+// scrypt. The MAC is the keccak256 of the second half of the derived key and
+// the ciphertext.
+func encryptData(data, passphrase []byte, scryptN, scryptP int) (CryptoJSON, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return CryptoJSON{}, err
+	}
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return CryptoJSON{}, err
+	}
+	derived, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return CryptoJSON{}, err
+	}
+	cipherText, err := aesCTR(derived[:16], data, iv)
+	if err != nil {
+		return CryptoJSON{}, err
+	}
+	return CryptoJSON{
+		Cipher:       "aes-128-ctr",
+		CipherText:   hex.EncodeToString(cipherText),
+		CipherParams: CipherParams{IV: hex.EncodeToString(iv)},
+		KDF:          "scrypt",
+		KDFParams:    ScryptParams{DKLen: scryptDKLen, N: scryptN, P: scryptP, R: scryptR, Salt: hex.EncodeToString(salt)},
+		MAC:          hex.EncodeToString(keystoreMAC(derived, cipherText)),
+	}, nil
+}
+
+// decryptData returns the data, ErrPassphrase if the MAC does not match
+func decryptData(c CryptoJSON, passphrase []byte) ([]byte, error) {
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported cipher %q", c.Cipher)
+	}
+	if c.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported kdf %q", c.KDF)
+	}
+	decode := func(name, value string) ([]byte, error) {
+		data, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return data, nil
+	}
+	mac, err := decode("mac", c.MAC)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := decode("iv", c.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := decode("ciphertext", c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := decode("salt", c.KDFParams.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if c.KDFParams.DKLen < 32 {
+		return nil, fmt.Errorf("the derived key is %d bytes, at least 32 are needed", c.KDFParams.DKLen)
+	}
+
+	p := c.KDFParams
+	derived, err := scrypt.Key(passphrase, salt, p.N, p.R, p.P, p.DKLen)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(keystoreMAC(derived, cipherText), mac) != 1 {
+		return nil, ErrPassphrase
+	}
+	return aesCTR(derived[:16], cipherText, iv)
+}
+
+func keystoreMAC(derived, cipherText []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(bytes.Join([][]byte{derived[16:32], cipherText}, nil))
+	return h.Sum(nil)
+}
+
+func aesCTR(key, in, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("the iv is %d bytes, not %d", len(iv), aes.BlockSize)
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
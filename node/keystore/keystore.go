@@ -0,0 +1,156 @@
+// Package keystore keeps the entry credit key of the node in a file that is
+// encrypted with a passphrase, so the Es key does not have to be stored in
+// the config. The file uses the encryption of the Ethereum V3 keystores,
+// scrypt and AES-128-CTR with a MAC, and is unlocked once the node runs.
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Version is the version of the file format
+const Version = 1
+
+var (
+	// ErrLocked is returned for the key of a keystore that is not unlocked
+	ErrLocked = errors.New("the ec keystore is locked")
+	// ErrPassphrase is returned if the passphrase does not decrypt the file
+	ErrPassphrase = errors.New("invalid passphrase")
+)
+
+// File is the content of a keystore file. The EC address is not encrypted,
+// so the balance of the key can be checked while it is locked.
+type File struct {
+	Version int        `json:"version"`
+	Address string     `json:"address"`
+	Crypto  CryptoJSON `json:"crypto"`
+}
+
+// Encrypt returns the file of the key encrypted with the passphrase, using
+// the standard scrypt parameters
+func Encrypt(es factom.EsAddress, passphrase string) (*File, error) {
+	return encrypt(es, passphrase, StandardScryptN, StandardScryptP)
+}
+
+func encrypt(es factom.EsAddress, passphrase string, scryptN, scryptP int) (*File, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("the passphrase is empty")
+	}
+	crypto, err := encryptData(es[:], []byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Version: Version, Address: es.ECAddress().String(), Crypto: crypto}, nil
+}
+
+// Decrypt returns the key of the file
+func (f *File) Decrypt(passphrase string) (factom.EsAddress, error) {
+	var es factom.EsAddress
+	data, err := decryptData(f.Crypto, []byte(passphrase))
+	if err != nil {
+		return es, err
+	}
+	if len(data) != len(es) {
+		return es, fmt.Errorf("the keystore holds %d bytes, not a key", len(data))
+	}
+	copy(es[:], data)
+	if es.ECAddress().String() != f.Address {
+		return es, fmt.Errorf("the key does not match the address %s", f.Address)
+	}
+	return es, nil
+}
+
+// ReadFile reads and checks the keystore file at the path
+func ReadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := new(File)
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported keystore version %d", path, f.Version)
+	}
+	if _, err := factom.NewECAddress(f.Address); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// WriteFile writes the keystore to the path, only readable by the owner
+func (f *File) WriteFile(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Keystore holds the key of a keystore file once it is unlocked. It is safe
+// for concurrent use.
+type Keystore struct {
+	file *File
+
+	mu  sync.RWMutex
+	key *factom.EsAddress
+}
+
+// Open reads the keystore file, the keystore starts locked
+func Open(path string) (*Keystore, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{file: f}, nil
+}
+
+// Address is the EC address of the key, it is known while locked
+func (k *Keystore) Address() factom.ECAddress {
+	ec, _ := factom.NewECAddress(k.file.Address)
+	return ec
+}
+
+// Unlock decrypts the key with the passphrase. A keystore that is already
+// unlocked still checks the passphrase.
+func (k *Keystore) Unlock(passphrase string) error {
+	es, err := k.file.Decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.key = &es
+	k.mu.Unlock()
+	return nil
+}
+
+// Lock forgets the decrypted key
+func (k *Keystore) Lock() {
+	k.mu.Lock()
+	k.key = nil
+	k.mu.Unlock()
+}
+
+// Locked is true until the keystore is unlocked
+func (k *Keystore) Locked() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.key == nil
+}
+
+// Key returns the decrypted key, ErrLocked if the keystore is locked
+func (k *Keystore) Key() (factom.EsAddress, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.key == nil {
+		return factom.EsAddress{}, ErrLocked
+	}
+	return *k.key, nil
+}
@@ -0,0 +1,72 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	es := factom.EsAddress{1, 2, 3}
+	f, err := encrypt(es, "secret", LightScryptN, LightScryptP)
+	require.NoError(t, err)
+	assert.Equal(t, es.ECAddress().String(), f.Address)
+
+	dir, err := ioutil.TempDir("", "pegnetd-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ec.json")
+	require.NoError(t, f.WriteFile(path))
+	k, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, es.ECAddress(), k.Address())
+	assert.True(t, k.Locked())
+	_, err = k.Key()
+	assert.Equal(t, ErrLocked, err)
+
+	assert.Equal(t, ErrPassphrase, k.Unlock("wrong"))
+	require.NoError(t, k.Unlock("secret"))
+	key, err := k.Key()
+	require.NoError(t, err)
+	assert.Equal(t, es, key)
+
+	k.Lock()
+	assert.True(t, k.Locked())
+
+	// The address must match the key
+	f.Address = factom.EsAddress{4}.ECAddress().String()
+	_, err = f.Decrypt("secret")
+	assert.Error(t, err)
+
+	_, err = encrypt(es, "", LightScryptN, LightScryptP)
+	assert.Error(t, err)
+}
+
+// The scrypt test vector of the Web3 Secret Storage Definition, so files
+// written by Ethereum tools can be read
+func TestDecryptData(t *testing.T) {
+	c := CryptoJSON{
+		Cipher:       "aes-128-ctr",
+		CipherText:   "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+		CipherParams: CipherParams{IV: "83dbcc02d8ccb40e466191a123791e0e"},
+		KDF:          "scrypt",
+		KDFParams: ScryptParams{DKLen: 32, N: 262144, R: 1, P: 8,
+			Salt: "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},
+		MAC: "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097",
+	}
+	data, err := decryptData(c, []byte("testpassword"))
+	require.NoError(t, err)
+	assert.Equal(t, "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d", hex.EncodeToString(data))
+
+	_, err = decryptData(c, []byte("wrong"))
+	assert.Equal(t, ErrPassphrase, err)
+	c.KDF = "pbkdf2"
+	_, err = decryptData(c, []byte("testpassword"))
+	assert.EqualError(t, err, `unsupported kdf "pbkdf2"`)
+}
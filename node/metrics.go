@@ -0,0 +1,103 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/metrics"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// MetricPoints is the metrics source of the node: the sync lag, the EC
+// balance, the deviations of the last price check, and the volumes of every
+// asset of the current day in whole units
+func (d *Pegnetd) MetricPoints(ctx context.Context) []metrics.Point {
+	now := time.Now()
+	synced := d.GetCurrentSync()
+	sync := metrics.Point{Name: "sync", Fields: map[string]float64{"height": float64(synced)}, Time: now}
+	heights := new(factom.Heights)
+	if err := heights.Get(nil, d.FactomClient); err == nil {
+		sync.Fields["factomheight"] = float64(heights.DirectoryBlock)
+		sync.Fields["lag"] = float64(int64(heights.DirectoryBlock) - int64(synced))
+	}
+	points := []metrics.Point{sync}
+
+	if d.ECMonitor != nil {
+		if ec := d.ECMonitor.Status(); !ec.Checked.IsZero() {
+			points = append(points, metrics.Point{
+				Name: "ec_balance",
+				Fields: map[string]float64{
+					"balance":   float64(ec.Balance),
+					"remaining": float64(ec.Remaining),
+					"burnrate":  ec.BurnRate,
+				},
+				Time: now,
+			})
+		}
+		for _, key := range d.ECKeyStats() {
+			points = append(points, metrics.Point{
+				Name: "ec_key",
+				Tags: map[string]string{"address": key.Address.String()},
+				Fields: map[string]float64{
+					"balance": float64(key.Balance),
+					"entries": float64(key.Entries),
+					"spent":   float64(key.Spent),
+				},
+				Time: now,
+			})
+		}
+	}
+
+	if d.PriceChecker != nil {
+		if last := d.PriceChecker.Status().Last; last != nil {
+			flagged := 0.0
+			if last.Flagged {
+				flagged = 1
+			}
+			points = append(points, metrics.Point{
+				Name: "price_check",
+				Fields: map[string]float64{
+					"height":       float64(last.Height),
+					"flagged":      flagged,
+					"maxdeviation": last.MaxDeviation,
+				},
+				Time: now,
+			})
+			for _, a := range last.Assets {
+				points = append(points, metrics.Point{
+					Name: "price_deviation",
+					Tags: map[string]string{"asset": a.Asset},
+					Fields: map[string]float64{
+						"rate":      a.Rate,
+						"reference": a.Reference,
+						"deviation": a.Deviation,
+					},
+					Time: now,
+				})
+			}
+		}
+		if count, _, err := d.Pegnet.SelectPriceFlags(ctx); err == nil {
+			points = append(points, metrics.Point{Name: "price_flags", Fields: map[string]float64{"count": float64(count)}, Time: now})
+		}
+	}
+
+	day := pegnet.UnixDay(now)
+	stats, err := d.Pegnet.SelectNetworkStats(ctx, day, day, false)
+	if err != nil || len(stats) == 0 {
+		return points
+	}
+	for ticker, volume := range stats[0].Assets {
+		points = append(points, metrics.Point{
+			Name: "asset_volume",
+			Tags: map[string]string{"asset": ticker.String()},
+			Fields: map[string]float64{
+				"transfer":      float64(volume.TransferVolume) / 1e8,
+				"converted_in":  float64(volume.ConvertedIn) / 1e8,
+				"converted_out": float64(volume.ConvertedOut) / 1e8,
+			},
+			Time: now,
+		})
+	}
+	return points
+}
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/grading"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// Network is the definition of a PegNet network: its chains, its burn
+// address, and the heights its features activate at. Custom networks are
+// read from a JSON file of this struct.
+type Network struct {
+	// Name is part of the chain ids, eg "MainNet"
+	Name string `json:"name"`
+	// The chains are computed from the name if they are not set
+	OPRChain         *factom.Bytes32 `json:"oprchain,omitempty"`
+	TransactionChain *factom.Bytes32 `json:"transactionchain,omitempty"`
+	// BurnAddress is the EC address FCT are burned to for pFCT
+	BurnAddress string `json:"burnaddress"`
+	// Base is the built-in network a network file starts from, its
+	// activations only override the heights of the base that it sets
+	Base        string           `json:"base,omitempty"`
+	Activations activation.Table `json:"activations"`
+}
+
+var (
+	MainNet = Network{
+		Name:        "MainNet",
+		BurnAddress: "EC2BURNFCT2PEGNETooo1oooo1oooo1oooo1oooo1oooo19wthin",
+		Activations: activation.MainNet,
+	}
+
+	// RegTest is the network of the regtest mode, it runs against the
+	// embedded mock factomd with every feature active from the start
+	RegTest = Network{
+		Name:        "RegTest",
+		BurnAddress: "EC3b1dYKScWQxDQHyaqb2vWauaCALmmpmgUmiJriebb8Mo628ca4",
+		Activations: activation.RegTest,
+	}
+
+	// ActiveNetwork is the network the activations and chains are set to
+	ActiveNetwork = MainNet
+)
+
+// LoadNetwork returns the network of the selection, either "MainNet",
+// "RegTest", or the path of a JSON file with a custom network. Other public
+// networks, like a testnet, are selected by their network file.
+func LoadNetwork(selection string) (Network, error) {
+	if n, err := builtinNetwork(selection); err == nil {
+		return n, nil
+	}
+
+	data, err := ioutil.ReadFile(selection)
+	if err != nil {
+		return Network{}, fmt.Errorf("network %q is not MainNet, RegTest, or a network file: %v", selection, err)
+	}
+	var n Network
+	if err := json.Unmarshal(data, &n); err != nil {
+		return Network{}, fmt.Errorf("network file %s: %v", selection, err)
+	}
+	if n.Base != "" {
+		// The heights of the file are unmarshaled again over the base
+		base, err := builtinNetwork(n.Base)
+		if err != nil {
+			return Network{}, fmt.Errorf("network file %s: %v", selection, err)
+		}
+		n.Activations = base.Activations
+		if err := json.Unmarshal(data, &n); err != nil {
+			return Network{}, fmt.Errorf("network file %s: %v", selection, err)
+		}
+	}
+	if err := n.Valid(); err != nil {
+		return Network{}, fmt.Errorf("network file %s: %v", selection, err)
+	}
+	return n, nil
+}
+
+// builtinNetwork returns the built-in network of the name
+func builtinNetwork(name string) (Network, error) {
+	switch strings.ToLower(name) {
+	case "", "mainnet":
+		return MainNet, nil
+	case "regtest":
+		return RegTest, nil
+	}
+	return Network{}, fmt.Errorf("%q is not MainNet or RegTest", name)
+}
+
+// Valid checks that the network can be applied
+func (n Network) Valid() error {
+	if n.Name == "" {
+		return fmt.Errorf("the network requires a name")
+	}
+	if _, err := factom.NewECAddress(n.BurnAddress); err != nil {
+		return fmt.Errorf("invalid burn address %q: %v", n.BurnAddress, err)
+	}
+	if err := n.Activations.Valid(); err != nil {
+		return err
+	}
+	for _, g := range n.Activations.Gradings {
+		if !grading.Registered(g.Version) {
+			return fmt.Errorf("grading version %d is not part of this pegnetd", g.Version)
+		}
+	}
+	return nil
+}
+
+// Chains returns the OPR and the transaction chain of the network
+func (n Network) Chains() (opr, transactions factom.Bytes32) {
+	opr = ComputeChainIDFromStrings([]string{"PegNet", n.Name, "OraclePriceRecords"})
+	if n.OPRChain != nil {
+		opr = *n.OPRChain
+	}
+	transactions = ComputeChainIDFromStrings([]string{"PegNet", n.Name, "Transactions"})
+	if n.TransactionChain != nil {
+		transactions = *n.TransactionChain
+	}
+	return opr, transactions
+}
+
+// Apply sets the chains, the burn address, and all activation heights to the
+// network. It has to be applied before the database is opened, and before
+// the assets of the config are registered.
+func (n Network) Apply() error {
+	if err := n.Valid(); err != nil {
+		return err
+	}
+	burn, _ := factom.NewECAddress(n.BurnAddress)
+
+	OPRChain, TransactionChain = n.Chains()
+	BurnAddress, BurnRCD = n.BurnAddress, burn
+
+	ApplyActivations(n.Activations)
+	ActiveNetwork = n
+	return nil
+}
+
+// ApplyActivations sets the activation table, and the activations of the V4
+// assets and the V4 hard fork, which follow the V4 update
+func ApplyActivations(t activation.Table) {
+	activation.Set(t)
+	fat2.SetAssetActivations(t.V4OPRUpdate)
+	pegnet.Hardforks[1].ActivationHeight = t.V4OPRUpdate
+}
@@ -4,89 +4,144 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/Factom-Asset-Tokens/factom"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/chaos"
 	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/events"
+	"github.com/pegnet/pegnetd/node/keystore"
+	"github.com/pegnet/pegnetd/node/notify"
 	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/node/regtest"
+	"github.com/pegnet/pegnetd/tracing"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-var (
-	OPRChain         = factom.NewBytes32("a642a8674f46696cc47fdb6b65f9c87b2a19c5ea8123b3d2f0c13b6f33a9d5ef")
-	TransactionChain = factom.NewBytes32("cffce0f409ebba4ed236d49d89c70e4bd1f1367d86402a3363366683265a242d")
+// The chains of the network, they are set by Network.Apply. The defaults are
+// MainNet.
+var OPRChain, TransactionChain = MainNet.Chains()
 
-	// Acivation Heights
+// SetAllActivations activates every feature at the height, used for testing
+func SetAllActivations(act uint32) {
+	ApplyActivations(activation.Current().All(act))
+}
 
-	PegnetActivation    uint32 = 206421
-	GradingV2Activation uint32 = 210330
+type Pegnetd struct {
+	FactomClient *factom.Client
+	Config       *viper.Viper
 
-	// TransactionConversionActivation indicates when tx/conversions go live on mainnet.
-	// Target Activation Height is Oct 7, 2019 15 UTC
-	TransactionConversionActivation uint32 = 213237
+	Sync   *pegnet.BlockSync
+	Pegnet *pegnet.Pegnet
 
-	// This is when PEG is priced by the market cap equation
-	// Estimated to be Oct 14 2019, 15:00:00 UTC
-	PEGPricingActivation uint32 = 214287
+	// AlertRules are evaluated against every synced block
+	AlertRules []pegnet.AlertRule
+	// Accounts group the addresses of the wallet and restrict the signing
+	// rpcs of the api keys
+	Accounts *pegnet.Accounts
+	// Policies restrict what the node signs for the addresses of the wallet
+	Policies   *pegnet.Policies
+	policiesMu sync.Mutex
 
-	// OneWaypFCTConversions makes pFCT a 1 way conversion. This means pFCT->pXXX,
-	// but no asset can go into pFCT. AKA pXXX -/> pFCT.
-	// The only way to aquire pFCT is to burn FCT. The burn command will remain.
-	// Estimated to be Nov 25, 2019 17:47:00 UTC
-	OneWaypFCTConversions uint32 = 220346
+	// pending are the pending transaction batches of the next height
+	pending pendingCache
 
-	// Once this is activated, a maximum amount of PEG of 5,000 can be
-	// converted per block. At a future height, a dynamic bank should be used.
-	// Estimated to be  Dec 9, 2019, 17:00 UTC
-	PegnetConversionLimitActivation uint32 = 222270
+	// rateOverrides are the rates injected for development, they are never
+	// set on MainNet
+	overridesMu   sync.RWMutex
+	rateOverrides RateOverrides
 
-	// This is when PEG price is determined by the exchange price
-	// Estimated to be  Dec 9, 2019, 17:00 UTC
-	PEGFreeFloatingPriceActivation uint32 = 222270
+	// Watchdog is nil if the sync is not watched
+	Watchdog *Watchdog
+	// ECMonitor is nil if the node has no EC key
+	ECMonitor *ECMonitor
+	// PriceChecker is nil if no price check sources are configured
+	PriceChecker *PriceChecker
+	// ecPool picks the EC key that pays for an entry
+	ecPool ecPool
+	// Notifier is nil if no notifiers are configured
+	Notifier *notify.Service
+	// Keystore holds the encrypted EC key, nil if the key is in the config
+	Keystore *keystore.Keystore
+	// HSM holds the keys on a PKCS#11 token, nil if no module is configured
+	HSM *HSM
 
-	// V4OPRUpdate indicates the activation of additional currencies and ecdsa keys.
-	// Estimated to be  Feb 12, 2020, 18:00 UTC
-	V4OPRUpdate uint32 = 231620
-)
+	// factomd routes the factom client to the factomd server of the config
+	factomd *endpointTransport
+	// Regtest is the mock factomd the node syncs in the regtest mode, nil
+	// otherwise
+	Regtest *regtest.Factomd
 
-func SetAllActivations(act uint32) {
-	PegnetActivation = act
-	GradingV2Activation = act
-	TransactionConversionActivation = act
-	PEGPricingActivation = act
-	OneWaypFCTConversions = act
-	PegnetConversionLimitActivation = act
-	PEGFreeFloatingPriceActivation = act
-	fat2.Fat2RCDEActivation = act
-	V4OPRUpdate = act
-}
+	// configMu guards the reloadable values of the Config, see ReloadConfig
+	configMu sync.RWMutex
+	// configFile are the reloadable values of the config file as of the
+	// last read, a reload only replaces the values the file changed
+	configFile map[string]interface{}
 
-type Pegnetd struct {
-	FactomClient *factom.Client
-	Config       *viper.Viper
+	// chaos injects the faults of the chaos mode, nil if it is off
+	chaos *chaos.Injector
 
-	Sync   *pegnet.BlockSync
-	Pegnet *pegnet.Pegnet
+	// Events is nil if no event sinks are configured
+	Events *events.Dispatcher
+	// blockEvents are collected while syncing a block and published once
+	// the block is committed
+	blockEvents *events.BlockEvents
 }
 
 func NewPegnetd(ctx context.Context, conf *viper.Viper) (*Pegnetd, error) {
 	// TODO : Update emyrk's factom library
 	n := new(Pegnetd)
 	n.FactomClient = FactomClientFromConfig(conf)
+	if conf.GetBool(config.Regtest) {
+		mock, err := regtest.New(OPRChain, TransactionChain, BurnRCD)
+		if err != nil {
+			return nil, err
+		}
+		n.Regtest = mock
+		n.FactomClient.Factomd.Transport = tracing.Transport(mock, "factomd")
+	}
+	n.chaos = chaos.New(chaos.FromConfig(conf))
+	n.FactomClient.Factomd.Transport = n.chaos.Transport(n.FactomClient.Factomd.Transport)
+	n.factomd = newEndpointTransport(n.FactomClient.Factomd.Transport, n.FactomClient.FactomdServer)
+	n.FactomClient.Factomd.Transport = n.factomd
 	n.Config = conf
+	if file, err := readConfigFile(conf.ConfigFileUsed()); err == nil {
+		n.configFile = reloadableValues(file)
+	}
+
+	// The assets must be registered before the database is opened
+	for _, str := range conf.GetStringSlice(config.Assets) {
+		a, err := fat2.ParseAsset(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset config: %s", err.Error())
+		}
+		if _, err := fat2.RegisterAsset(a.Name, a.Currency, a.Activation); err != nil {
+			return nil, fmt.Errorf("invalid asset config: %s", err.Error())
+		}
+	}
 
 	n.Pegnet = pegnet.New(conf)
 	if err := n.Pegnet.Init(); err != nil {
 		return nil, err
 	}
+	if err := n.Pegnet.CheckNetwork(nil, ActiveNetwork.Name); err != nil {
+		return nil, err
+	}
 
 	if sync, err := n.Pegnet.SelectSynced(ctx, n.Pegnet.DB); err != nil {
 		if err == sql.ErrNoRows {
 			n.Sync = new(pegnet.BlockSync)
-			n.Sync.Synced = PegnetActivation
+			n.Sync.Synced = activation.Height(activation.Pegnet)
+			if err := n.Pegnet.MarkSyncStart(n.Pegnet.DB, n.Sync.Synced); err != nil {
+				return nil, err
+			}
 			log.Debug("connected to a fresh database")
 		} else {
 			return nil, err
@@ -95,6 +150,10 @@ func NewPegnetd(ctx context.Context, conf *viper.Viper) (*Pegnetd, error) {
 		n.Sync = sync
 	}
 
+	if err := n.recoverPartialHeight(ctx); err != nil {
+		return nil, err
+	}
+
 	err := n.Pegnet.CheckHardForks(n.Pegnet.DB)
 	if err != nil {
 		err = fmt.Errorf("pegnetd database hardfork check failed: %s", err.Error())
@@ -105,14 +164,150 @@ func NewPegnetd(ctx context.Context, conf *viper.Viper) (*Pegnetd, error) {
 		}
 	}
 
+	for _, rule := range conf.GetStringSlice(config.AlertRules) {
+		r, err := pegnet.ParseAlertRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert config: %s", err.Error())
+		}
+		n.AlertRules = append(n.AlertRules, r)
+	}
+
+	n.Accounts, err = pegnet.NewAccounts(conf.GetStringSlice(config.WalletAccounts), conf.GetStringSlice(config.WalletAPIKeys))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %s", err.Error())
+	}
+	n.Policies, err = pegnet.NewPolicies(conf.GetStringSlice(config.WalletPolicies), n.Accounts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet config: %s", err.Error())
+	}
+
+	if path := conf.GetString(config.DevRatesFile); path != "" {
+		if !CanInjectRates() {
+			return nil, fmt.Errorf("invalid rates file config: rates cannot be injected on %s", MainNet.Name)
+		}
+		overrides, err := LoadRateOverrides(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rates file config: %s", err.Error())
+		}
+		n.rateOverrides = overrides
+		log.WithFields(log.Fields{"file": path, "heights": len(overrides)}).Info("Injecting rates")
+	}
+
+	if err := n.openKeystore(); err != nil {
+		return nil, fmt.Errorf("invalid ec keystore config: %s", err.Error())
+	}
+	if n.HSM, err = OpenHSM(conf); err != nil {
+		return nil, fmt.Errorf("invalid pkcs11 config: %s", err.Error())
+	}
+
+	sinks, err := events.SinksFromConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event config: %s", err.Error())
+	}
+	notifier, err := notify.NewFromConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify config: %s", err.Error())
+	}
+	if stalled := conf.GetDuration(config.WatchdogStalled); stalled > 0 {
+		n.Watchdog = &Watchdog{StalledAfter: stalled, Notifier: notifier}
+		go n.Watchdog.Run(ctx, conf.GetDuration(config.WatchdogInterval), n.GetCurrentSync, n.FactomClient)
+	}
+	if len(n.ECAddresses()) > 0 {
+		n.ECMonitor = &ECMonitor{
+			Balance:         n.ecBalance,
+			MinTransactions: conf.GetUint64(config.ECMonitorTransactions),
+			MinBalance:      conf.GetUint64(config.NotifyECBalance),
+			Notifier:        notifier,
+			Cost:            n.transactionCost,
+		}
+		go n.ECMonitor.Run(ctx, conf.GetDuration(config.ECMonitorInterval))
+	}
+	if n.PriceChecker, err = NewPriceChecker(conf); err != nil {
+		return nil, fmt.Errorf("invalid pricecheck config: %s", err.Error())
+	}
+	if n.PriceChecker != nil {
+		n.PriceChecker.Notifier = notifier
+		n.PriceChecker.Latest = n.latestRates
+		n.PriceChecker.Record = func(c pegnet.PriceCheck) error { return n.Pegnet.InsertPriceCheck(nil, c) }
+		go n.PriceChecker.Run(ctx, conf.GetDuration(config.PriceCheckInterval))
+	}
+	if notifier != nil {
+		n.Notifier = notifier
+		notifier.SyncWatchdog = n.Watchdog != nil
+		notifier.ECMonitor = n.ECMonitor != nil
+		sinks = append(sinks, notifier)
+		go notifier.Monitor(ctx, time.Minute, n.GetCurrentSync, n.FactomClient)
+	}
+	if len(sinks) > 0 {
+		n.Events = events.NewDispatcher(conf.GetInt(config.EventQueueSize), sinks...)
+		go n.Events.Run(ctx)
+	}
+
 	grader.InitLX()
+
+	// The database faults start once the node is set up, so they hit the
+	// sync and not the migrations
+	if n.chaos != nil {
+		if n.chaos.Config.DBWriteError > 0 {
+			pegnet.InjectWriteFaults(n.chaos.DBWrite)
+		}
+		c := n.chaos.Config
+		log.WithFields(log.Fields{
+			"factomd_timeout":   c.FactomdTimeout,
+			"factomd_malformed": c.FactomdMalformed,
+			"db_write_error":    c.DBWriteError,
+			"crash":             c.Crash,
+			"seed":              c.Seed,
+		}).Warn("chaos mode is on, faults are injected")
+	}
 	return n, nil
 }
 
+// chaosCrash exits the process if the chaos mode rolls a crash, it is called
+// right before a synced block is committed
+func (d *Pegnetd) chaosCrash(height uint32) {
+	if d.chaos.Crash() {
+		log.WithField("height", height).Error("chaos: crashing before the commit")
+		os.Exit(chaos.CrashExitCode)
+	}
+}
+
+// recoverPartialHeight rolls back the state of a height that was only
+// partially applied when the node stopped
+func (d *Pegnetd) recoverPartialHeight(ctx context.Context) error {
+	partial, err := d.Pegnet.SelectPartialHeight(ctx, nil, d.Sync.Synced)
+	if err != nil {
+		return fmt.Errorf("failed to check for a partially applied height: %s", err.Error())
+	}
+	if !partial.Found() {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"height":   d.Sync.Synced,
+		"rows":     partial.Rows,
+		"balances": partial.Balances,
+	}).Warn("found a partially applied height, rolling it back")
+
+	tx, err := d.Pegnet.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := d.Pegnet.RollbackPartialHeight(tx, d.Sync.Synced); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to roll back the partially applied height: %s", err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.WithField("height", d.Sync.Synced).Warn("rolled back to the synced height, the statistics may include the partial height")
+	return nil
+}
+
 func FactomClientFromConfig(conf *viper.Viper) *factom.Client {
 	cl := factom.NewClient()
 	cl.FactomdServer = conf.GetString(config.Server)
 	cl.WalletdServer = conf.GetString(config.Wallet)
+	cl.Factomd.Transport = tracing.Transport(cl.Factomd.Transport, "factomd")
 	if config.WalletUser != "" {
 		cl.Walletd.BasicAuth = true
 		cl.Walletd.User = conf.GetString(config.WalletUser)
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Telegram sends the messages through a bot to a chat, the chat is either
+// the numeric id or "@channelname"
+type Telegram struct {
+	Token  string
+	ChatID string
+	// APIURL defaults to the public bot api
+	APIURL string
+	Client *http.Client
+}
+
+func NewTelegram(token, chat string) *Telegram {
+	return &Telegram{Token: token, ChatID: chat, APIURL: "https://api.telegram.org", Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+func (t *Telegram) Notify(ctx context.Context, m Message) error {
+	err := postJSON(ctx, t.Client, t.APIURL+"/bot"+t.Token+"/sendMessage", map[string]interface{}{
+		"chat_id":                  t.ChatID,
+		"text":                     truncate(m.String(), 4096),
+		"disable_web_page_preview": true,
+	})
+	// The url contains the token, which should not end up in the logs
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
+
+// Discord sends the messages to a channel webhook
+type Discord struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func NewDiscord(webhook string) *Discord {
+	return &Discord{WebhookURL: webhook, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (d *Discord) Name() string { return "discord" }
+
+func (d *Discord) Notify(ctx context.Context, m Message) error {
+	return postJSON(ctx, d.Client, d.WebhookURL, map[string]interface{}{
+		"username": "pegnetd",
+		"content":  truncate(m.String(), 2000),
+	})
+}
+
+// truncate limits the text to the maximum message length of a service
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max-3] + "..."
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The default templates of the email, they are executed with the Message
+const (
+	DefaultEmailSubject = "pegnetd: {{.Trigger}}"
+	DefaultEmailBody    = `{{.Text}}
+{{if .Suppressed}}
+{{.Suppressed}} similar notifications were throttled since the last email.
+{{end}}
+Sent by pegnetd at {{.Time.UTC.Format "2006-01-02 15:04:05 MST"}}
+`
+)
+
+// Email sends the messages through a SMTP server. The connection is
+// upgraded with STARTTLS if the server supports it.
+type Email struct {
+	Server  string // host:port
+	Auth    smtp.Auth
+	From    string
+	To      []string
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// NewEmail creates an email notifier. Without a username the server is used
+// without authentication. Empty templates use the defaults.
+func NewEmail(server, username, password, from string, to []string, subject, body string) (*Email, error) {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("smtp server must be host:port: %v", err)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("email notifications require a sender and at least one recipient")
+	}
+	if subject == "" {
+		subject = DefaultEmailSubject
+	}
+	if body == "" {
+		body = DefaultEmailBody
+	}
+
+	e := &Email{Server: server, From: from, To: to}
+	if username != "" {
+		e.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	if e.Subject, err = template.New("subject").Parse(subject); err != nil {
+		return nil, fmt.Errorf("invalid email subject template: %v", err)
+	}
+	if e.Body, err = template.New("body").Parse(body); err != nil {
+		return nil, fmt.Errorf("invalid email body template: %v", err)
+	}
+	return e, nil
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Notify(_ context.Context, m Message) error {
+	var subject, body bytes.Buffer
+	if err := e.Subject.Execute(&subject, m); err != nil {
+		return err
+	}
+	if err := e.Body.Execute(&body, m); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	// Headers can not contain line breaks
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.Join(strings.Fields(subject.String()), " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", m.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	return smtp.SendMail(e.Server, e.Auth, e.From, e.To, msg.Bytes())
+}
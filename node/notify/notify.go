@@ -0,0 +1,412 @@
+// Package notify pushes messages to chat services and email when something
+// needs the attention of the node operator: activity of watched addresses, a
+// stalled or lagging sync, an unreachable factomd, a low entry credit
+// balance, graded rates that deviate from the external reference prices, or
+// a burn that was not credited.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/events"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// The triggers that can be enabled
+const (
+	TriggerAddress        = "address"
+	TriggerStalled        = "stalled"
+	TriggerBehind         = "behind"
+	TriggerUnreachable    = "unreachable"
+	TriggerECBalance      = "ecbalance"
+	TriggerPriceDeviation = "pricedeviation"
+	TriggerMalformedBurn  = "malformedburn"
+)
+
+// Message is a single notification
+type Message struct {
+	Trigger string
+	Text    string
+	Time    time.Time
+	// Suppressed is the amount of messages of the same rule that were
+	// throttled since the last message
+	Suppressed int
+}
+
+// String is the text with a note about throttled messages
+func (m Message) String() string {
+	if m.Suppressed > 0 {
+		return fmt.Sprintf("%s (%d similar notifications were throttled)", m.Text, m.Suppressed)
+	}
+	return m.Text
+}
+
+// Notifier is a service the messages are pushed to
+type Notifier interface {
+	// Name is used to identify the notifier in logs
+	Name() string
+	Notify(ctx context.Context, m Message) error
+}
+
+// Service sends the messages of the enabled triggers to all notifiers. It is
+// an event sink for the address activity and the malformed burns, the other
+// triggers are checked by Monitor.
+type Service struct {
+	Notifiers []Notifier
+	Triggers  map[string]bool
+	Addresses map[factom.FAAddress]bool
+
+	// StalledAfter is how long the sync can stay at the same height while
+	// factomd is ahead
+	StalledAfter time.Duration
+	// SyncWatchdog is set if the stalled sync is reported by the watchdog of
+	// the node instead of Monitor
+	SyncWatchdog bool
+	// ECMonitor is set if the EC balance is reported by the EC monitor of
+	// the node instead of Monitor
+	ECMonitor bool
+	// BehindBlocks is how far the sync can fall behind factomd
+	BehindBlocks uint32
+	// UnreachableAfter is how long factomd can be unreachable
+	UnreachableAfter time.Duration
+	// ECAddress is checked against ECBalance if it is not nil
+	ECAddress *factom.ECAddress
+	ECBalance uint64
+	// Throttle is the minimum time between two messages of the same rule,
+	// every watched address is its own rule
+	Throttle time.Duration
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time
+	suppressed map[string]int
+
+	// The state of the checks, so every problem is only reported when it
+	// starts and when it is resolved
+	lastHeight   uint32
+	lastProgress time.Time
+	stalled      bool
+	behind       bool
+	lastReached  time.Time
+	unreachable  bool
+	lowBalance   bool
+}
+
+// NewFromConfig creates the service of the notifiers in the config. Returns
+// nil if no notifier is configured.
+func NewFromConfig(conf *viper.Viper) (*Service, error) {
+	s := &Service{
+		Triggers:         make(map[string]bool),
+		Addresses:        make(map[factom.FAAddress]bool),
+		StalledAfter:     conf.GetDuration(config.NotifyStalled),
+		BehindBlocks:     conf.GetUint32(config.NotifyBehind),
+		UnreachableAfter: conf.GetDuration(config.NotifyUnreachable),
+		ECBalance:        conf.GetUint64(config.NotifyECBalance),
+		Throttle:         conf.GetDuration(config.NotifyThrottle),
+	}
+	if token := conf.GetString(config.NotifyTelegramToken); token != "" {
+		chat := conf.GetString(config.NotifyTelegramChat)
+		if chat == "" {
+			return nil, fmt.Errorf("a telegram chat is required for the telegram bot")
+		}
+		s.Notifiers = append(s.Notifiers, NewTelegram(token, chat))
+	}
+	if webhook := conf.GetString(config.NotifyDiscordWebhook); webhook != "" {
+		s.Notifiers = append(s.Notifiers, NewDiscord(webhook))
+	}
+	if server := conf.GetString(config.NotifySMTPServer); server != "" {
+		e, err := NewEmail(server, conf.GetString(config.NotifySMTPUser), conf.GetString(config.NotifySMTPPassword),
+			conf.GetString(config.NotifyEmailFrom), conf.GetStringSlice(config.NotifyEmailTo),
+			conf.GetString(config.NotifyEmailSubject), conf.GetString(config.NotifyEmailBody))
+		if err != nil {
+			return nil, err
+		}
+		s.Notifiers = append(s.Notifiers, e)
+	}
+	if len(s.Notifiers) == 0 {
+		return nil, nil
+	}
+
+	for _, trigger := range conf.GetStringSlice(config.NotifyTriggers) {
+		switch trigger {
+		case TriggerAddress, TriggerStalled, TriggerBehind, TriggerUnreachable, TriggerECBalance, TriggerPriceDeviation, TriggerMalformedBurn:
+			s.Triggers[trigger] = true
+		default:
+			return nil, fmt.Errorf("unknown notify trigger %q", trigger)
+		}
+	}
+	for _, a := range conf.GetStringSlice(config.NotifyAddresses) {
+		adr, err := factom.NewFAAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("notify address %s: %v", a, err)
+		}
+		s.Addresses[adr] = true
+	}
+	if key := conf.GetString(config.ECPrivateKey); key != "" {
+		var es factom.EsAddress
+		if err := es.Set(key); err != nil {
+			return nil, fmt.Errorf("invalid ec private key: %v", err)
+		}
+		ec := es.ECAddress()
+		s.ECAddress = &ec
+	}
+	return s, nil
+}
+
+// SetNotifiers replaces the notifiers the following messages are pushed to
+func (s *Service) SetNotifiers(notifiers []Notifier) {
+	s.mu.Lock()
+	s.Notifiers = notifiers
+	s.mu.Unlock()
+}
+
+// Send pushes the message of a trigger to all notifiers
+func (s *Service) Send(ctx context.Context, trigger, text string) {
+	s.send(ctx, trigger, trigger, text)
+}
+
+// Resolved pushes the message that the problem of a trigger is resolved
+func (s *Service) Resolved(ctx context.Context, trigger, text string) {
+	s.send(ctx, trigger+":resolved", trigger, text)
+}
+
+// send pushes the message to all notifiers unless a message of the same rule
+// was sent less than Throttle ago. The resolution of a problem is its own
+// rule, so it is not throttled by the problem itself.
+func (s *Service) send(ctx context.Context, rule, trigger, text string) {
+	now := time.Now()
+	s.mu.Lock()
+	if s.lastSent == nil {
+		s.lastSent, s.suppressed = make(map[string]time.Time), make(map[string]int)
+	}
+	if last, ok := s.lastSent[rule]; ok && now.Sub(last) < s.Throttle {
+		s.suppressed[rule]++
+		s.mu.Unlock()
+		return
+	}
+	m := Message{Trigger: trigger, Text: text, Time: now, Suppressed: s.suppressed[rule]}
+	s.lastSent[rule] = now
+	delete(s.suppressed, rule)
+	notifiers := s.Notifiers
+	s.mu.Unlock()
+
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, m); err != nil {
+			log.WithError(err).WithField("notifier", n.Name()).Error("failed to send notification")
+		}
+	}
+}
+
+func (s *Service) Name() string { return "notify" }
+
+// Publish sends a message for every action of a watched address and every
+// malformed burn
+func (s *Service) Publish(ctx context.Context, b *events.BlockEvents) error {
+	if s.Triggers[TriggerAddress] && len(s.Addresses) > 0 {
+		for _, a := range b.Actions {
+			if adr := s.watched(a); adr != nil {
+				s.send(ctx, TriggerAddress+":"+adr.String(), TriggerAddress, FormatAction(a))
+			}
+		}
+	}
+	if s.Triggers[TriggerMalformedBurn] {
+		for _, m := range b.MalformedBurns {
+			s.send(ctx, fmt.Sprintf("%s:%s:%d", TriggerMalformedBurn, m.TxID, m.Input), TriggerMalformedBurn, FormatMalformedBurn(m))
+		}
+	}
+	return nil
+}
+
+// watched returns the first watched address of the action, or nil
+func (s *Service) watched(a pegnet.HistoryTransaction) *factom.FAAddress {
+	if a.FromAddress != nil && s.Addresses[*a.FromAddress] {
+		return a.FromAddress
+	}
+	for i := range a.Outputs {
+		if s.Addresses[a.Outputs[i].Address] {
+			return &a.Outputs[i].Address
+		}
+	}
+	return nil
+}
+
+func (s *Service) Close() error { return nil }
+
+// Monitor runs the checks of the operational triggers every interval until
+// the context is cancelled
+func (s *Service) Monitor(ctx context.Context, interval time.Duration, synced func() uint32, client *factom.Client) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		heights := new(factom.Heights)
+		err := heights.Get(nil, client)
+		s.CheckFactomd(ctx, err == nil, time.Now())
+		if err == nil {
+			if !s.SyncWatchdog {
+				s.CheckSync(ctx, synced(), heights.DirectoryBlock, time.Now())
+			}
+			s.CheckBehind(ctx, synced(), heights.DirectoryBlock)
+		}
+		if s.Triggers[TriggerECBalance] && s.ECAddress != nil && !s.ECMonitor {
+			if balance, err := s.ECAddress.GetBalance(nil, client); err == nil {
+				s.CheckECBalance(ctx, balance)
+			}
+		}
+	}
+}
+
+// CheckSync reports a sync that did not progress for StalledAfter while
+// factomd is ahead, and when it progresses again
+func (s *Service) CheckSync(ctx context.Context, synced, factomHeight uint32, now time.Time) {
+	if !s.Triggers[TriggerStalled] {
+		return
+	}
+	if synced != s.lastHeight || s.lastProgress.IsZero() {
+		if s.stalled {
+			s.stalled = false
+			s.send(ctx, TriggerStalled+":resolved", TriggerStalled, fmt.Sprintf("pegnetd: sync resumed, now at height %d", synced))
+		}
+		s.lastHeight, s.lastProgress = synced, now
+		return
+	}
+	if !s.stalled && factomHeight > synced && now.Sub(s.lastProgress) >= s.StalledAfter {
+		s.stalled = true
+		s.Send(ctx, TriggerStalled, fmt.Sprintf("pegnetd: sync stalled at height %d for %s, factomd is at height %d",
+			synced, now.Sub(s.lastProgress).Round(time.Second), factomHeight))
+	}
+}
+
+// CheckBehind reports a sync that is more than BehindBlocks behind factomd,
+// and when it caught up again
+func (s *Service) CheckBehind(ctx context.Context, synced, factomHeight uint32) {
+	if !s.Triggers[TriggerBehind] {
+		return
+	}
+	behind := factomHeight > synced && factomHeight-synced > s.BehindBlocks
+	switch {
+	case behind && !s.behind:
+		s.Send(ctx, TriggerBehind, fmt.Sprintf("pegnetd: sync is %d blocks behind factomd, at height %d of %d",
+			factomHeight-synced, synced, factomHeight))
+	case !behind && s.behind:
+		s.send(ctx, TriggerBehind+":resolved", TriggerBehind, fmt.Sprintf("pegnetd: sync caught up with factomd, at height %d", synced))
+	}
+	s.behind = behind
+}
+
+// CheckFactomd reports factomd being unreachable for UnreachableAfter, and
+// when it is reachable again
+func (s *Service) CheckFactomd(ctx context.Context, reachable bool, now time.Time) {
+	if !s.Triggers[TriggerUnreachable] {
+		return
+	}
+	if reachable || s.lastReached.IsZero() {
+		if reachable && s.unreachable {
+			s.unreachable = false
+			s.send(ctx, TriggerUnreachable+":resolved", TriggerUnreachable, fmt.Sprintf("pegnetd: factomd is reachable again after %s",
+				now.Sub(s.lastReached).Round(time.Second)))
+		}
+		s.lastReached = now
+		return
+	}
+	if !s.unreachable && now.Sub(s.lastReached) >= s.UnreachableAfter {
+		s.unreachable = true
+		s.Send(ctx, TriggerUnreachable, fmt.Sprintf("pegnetd: factomd is unreachable for %s",
+			now.Sub(s.lastReached).Round(time.Second)))
+	}
+}
+
+// CheckECBalance reports a balance below ECBalance, and when it is topped up
+func (s *Service) CheckECBalance(ctx context.Context, balance uint64) {
+	switch low := balance < s.ECBalance; {
+	case low && !s.lowBalance:
+		s.Send(ctx, TriggerECBalance, fmt.Sprintf("pegnetd: entry credit balance of %s is low: %d EC", s.ECAddress, balance))
+	case !low && s.lowBalance:
+		s.send(ctx, TriggerECBalance+":resolved", TriggerECBalance, fmt.Sprintf("pegnetd: entry credit balance of %s is back to %d EC", s.ECAddress, balance))
+	}
+	s.lowBalance = balance < s.ECBalance
+}
+
+// FormatAction describes an action in a single line
+func FormatAction(a pegnet.HistoryTransaction) string {
+	from := ""
+	if a.FromAddress != nil {
+		from = a.FromAddress.String()
+	}
+	switch a.TxAction {
+	case pegnet.Transfer:
+		outputs := make([]string, len(a.Outputs))
+		for i, o := range a.Outputs {
+			outputs[i] = fmt.Sprintf("%s %s to %s", FormatAmount(o.Amount), a.FromAsset, o.Address)
+		}
+		return fmt.Sprintf("pegnetd: transfer from %s at height %d: %s (%s)", from, a.Height, strings.Join(outputs, ", "), a.TxID)
+	case pegnet.Conversion:
+		return fmt.Sprintf("pegnetd: %s converted %s %s to %s %s at height %d (%s)", from,
+			FormatAmount(a.FromAmount), a.FromAsset, FormatAmount(a.ToAmount), a.ToAsset, a.Height, a.TxID)
+	case pegnet.Coinbase:
+		return fmt.Sprintf("pegnetd: %s mined %s PEG at height %d", from, FormatAmount(a.ToAmount), a.Height)
+	case pegnet.FCTBurn:
+		return fmt.Sprintf("pegnetd: %s burned %s FCT at height %d", from, FormatAmount(a.FromAmount), a.Height)
+	}
+	return fmt.Sprintf("pegnetd: action of %s at height %d (%s)", from, a.Height, a.TxID)
+}
+
+// FormatMalformedBurn describes a malformed burn in a single line
+func FormatMalformedBurn(m pegnet.MalformedBurn) string {
+	return fmt.Sprintf("pegnetd: %s sent %s FCT to the burn address at height %d, but no pFCT was credited: %s (%s)",
+		m.Address, FormatAmount(m.Amount), m.Height, m.Detail, m.TxID)
+}
+
+// FormatAmount formats factoshis with up to 8 decimals
+func FormatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := fmt.Sprintf("%s%d", sign, amount/1e8)
+	if r := strings.TrimRight(fmt.Sprintf("%08d", amount%1e8), "0"); r != "" {
+		s += "." + r
+	}
+	return s
+}
+
+// postJSON posts the json of v and fails on any non 2xx status
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
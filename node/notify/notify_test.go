@@ -0,0 +1,215 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/events"
+	. "github.com/pegnet/pegnetd/node/notify"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct{ messages []string }
+
+func (r *recorder) Name() string { return "recorder" }
+func (r *recorder) Notify(_ context.Context, m Message) error {
+	r.messages = append(r.messages, m.String())
+	return nil
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "0", FormatAmount(0))
+	assert.Equal(t, "1.5", FormatAmount(15e7))
+	assert.Equal(t, "-0.00000001", FormatAmount(-1))
+}
+
+func TestService_Publish(t *testing.T) {
+	var watched, other factom.FAAddress
+	watched[0], other[0] = 1, 2
+	r := new(recorder)
+	s := &Service{
+		Notifiers: []Notifier{r},
+		Triggers:  map[string]bool{TriggerAddress: true},
+		Addresses: map[factom.FAAddress]bool{watched: true},
+	}
+
+	b := &events.BlockEvents{Actions: []pegnet.HistoryTransaction{
+		{TxAction: pegnet.Transfer, Height: 5, FromAddress: &other, FromAsset: "PEG",
+			Outputs: []pegnet.HistoryTransactionOutput{{Address: watched, Amount: 1e8}}},
+		{TxAction: pegnet.Conversion, FromAddress: &other},
+	}}
+	require.NoError(t, s.Publish(context.Background(), b))
+	require.Len(t, r.messages, 1)
+	assert.Contains(t, r.messages[0], "1 PEG to "+watched.String())
+
+	// Malformed burns are only sent with their trigger
+	b = &events.BlockEvents{MalformedBurns: []pegnet.MalformedBurn{{Height: 7, Address: other, Amount: 5e8, Detail: "bought entry credits"}}}
+	require.NoError(t, s.Publish(context.Background(), b))
+	require.Len(t, r.messages, 1)
+	s.Triggers[TriggerMalformedBurn] = true
+	require.NoError(t, s.Publish(context.Background(), b))
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[1], other.String()+" sent 5 FCT to the burn address at height 7")
+}
+
+func TestService_CheckSync(t *testing.T) {
+	r := new(recorder)
+	s := &Service{Notifiers: []Notifier{r}, Triggers: map[string]bool{TriggerStalled: true}, StalledAfter: 10 * time.Minute}
+	now := time.Now()
+
+	s.CheckSync(context.Background(), 100, 105, now)
+	s.CheckSync(context.Background(), 100, 105, now.Add(5*time.Minute))
+	assert.Empty(t, r.messages)
+	s.CheckSync(context.Background(), 100, 105, now.Add(10*time.Minute))
+	s.CheckSync(context.Background(), 100, 105, now.Add(15*time.Minute))
+	require.Len(t, r.messages, 1)
+	assert.Contains(t, r.messages[0], "stalled at height 100")
+
+	s.CheckSync(context.Background(), 101, 105, now.Add(16*time.Minute))
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[1], "resumed")
+}
+
+func TestService_CheckBehind(t *testing.T) {
+	r := new(recorder)
+	s := &Service{Notifiers: []Notifier{r}, Triggers: map[string]bool{TriggerBehind: true}, BehindBlocks: 10}
+	for _, synced := range []uint32{95, 80, 85, 100} {
+		s.CheckBehind(context.Background(), synced, 100)
+	}
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[0], "20 blocks behind")
+	assert.Contains(t, r.messages[1], "caught up")
+}
+
+func TestService_CheckFactomd(t *testing.T) {
+	r := new(recorder)
+	s := &Service{Notifiers: []Notifier{r}, Triggers: map[string]bool{TriggerUnreachable: true}, UnreachableAfter: 10 * time.Minute}
+	now := time.Now()
+	s.CheckFactomd(context.Background(), true, now)
+	s.CheckFactomd(context.Background(), false, now.Add(5*time.Minute))
+	assert.Empty(t, r.messages)
+	s.CheckFactomd(context.Background(), false, now.Add(10*time.Minute))
+	s.CheckFactomd(context.Background(), false, now.Add(11*time.Minute))
+	s.CheckFactomd(context.Background(), true, now.Add(12*time.Minute))
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[0], "unreachable for 10m0s")
+	assert.Contains(t, r.messages[1], "reachable again after 12m0s")
+}
+
+func TestService_Throttle(t *testing.T) {
+	var watched factom.FAAddress
+	r := new(recorder)
+	s := &Service{
+		Notifiers: []Notifier{r},
+		Triggers:  map[string]bool{TriggerAddress: true},
+		Addresses: map[factom.FAAddress]bool{watched: true},
+		Throttle:  50 * time.Millisecond,
+	}
+	b := &events.BlockEvents{Actions: []pegnet.HistoryTransaction{{TxAction: pegnet.FCTBurn, FromAddress: &watched}}}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Publish(context.Background(), b))
+	}
+	require.Len(t, r.messages, 1)
+
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, s.Publish(context.Background(), b))
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[1], "2 similar notifications were throttled")
+}
+
+func TestEmail_Notify(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "DATA":
+				conn.Write([]byte("354 go ahead\r\n"))
+				var body strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					body.WriteString(line)
+				}
+				data <- body.String()
+				conn.Write([]byte("250 ok\r\n"))
+			case "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+	}()
+
+	e, err := NewEmail(l.Addr().String(), "", "", "node@example.com", []string{"ops@example.com"}, "", "{{.Trigger}}: {{.Text}}")
+	require.NoError(t, err)
+	require.NoError(t, e.Notify(context.Background(), Message{Trigger: TriggerBehind, Text: "lagging", Time: time.Now()}))
+
+	msg := <-data
+	assert.Contains(t, msg, "Subject: pegnetd: behind\r\n")
+	assert.Contains(t, msg, "To: ops@example.com\r\n")
+	assert.True(t, strings.HasSuffix(msg, "behind: lagging\r\n"), msg)
+
+	_, err = NewEmail("localhost", "", "", "node@example.com", []string{"ops@example.com"}, "", "")
+	assert.Error(t, err)
+}
+
+func TestService_CheckECBalance(t *testing.T) {
+	r := new(recorder)
+	s := &Service{Notifiers: []Notifier{r}, ECAddress: new(factom.ECAddress), ECBalance: 100}
+	for _, balance := range []uint64{200, 50, 40, 150} {
+		s.CheckECBalance(context.Background(), balance)
+	}
+	require.Len(t, r.messages, 2)
+	assert.Contains(t, r.messages[0], "low: 50 EC")
+	assert.Contains(t, r.messages[1], "back to 150 EC")
+}
+
+func TestChat_Notify(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer srv.Close()
+
+	tg := NewTelegram("token", "@chat")
+	tg.APIURL = srv.URL
+	require.NoError(t, tg.Notify(context.Background(), Message{Text: "hello"}))
+	assert.Equal(t, "/bottoken/sendMessage", path)
+	assert.Equal(t, "@chat", body["chat_id"])
+	assert.Equal(t, "hello", body["text"])
+
+	d := NewDiscord(srv.URL + "/webhook")
+	require.NoError(t, d.Notify(context.Background(), Message{Text: "hello"}))
+	assert.Equal(t, "/webhook", path)
+	assert.Equal(t, "hello", body["content"])
+}
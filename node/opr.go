@@ -3,32 +3,22 @@ package node
 import (
 	"context"
 	"database/sql"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/pegnet/pegnet/modules/opr"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/node/grading"
+	"github.com/pegnet/pegnetd/node/pegnet"
 )
 
-func (d *Pegnetd) Grade(ctx context.Context, block *factom.EBlock) (grader.GradedBlock, error) {
+// Grade grades the OPR block, it also returns the valid OPRs that were
+// submitted
+func (d *Pegnetd) Grade(ctx context.Context, block *factom.EBlock) (grader.GradedBlock, []pegnet.OPRSubmission, error) {
 	if block == nil {
-		// TODO: Handle the case where there is no opr block.
-		// 		Must delay conversions if this happens
-		return nil, nil
-	}
-
-	if *block.ChainID != OPRChain {
-		return nil, fmt.Errorf("trying to grade a non-opr chain")
-	}
-
-	ver := uint8(1)
-	if block.Height >= GradingV2Activation {
-		ver = 2
-	}
-	if block.Height >= PEGFreeFloatingPriceActivation {
-		ver = 3
-	}
-	if block.Height >= V4OPRUpdate {
-		ver = 4
+		return nil, nil, nil
 	}
 
 	var prevWinners []string = nil
@@ -36,17 +26,35 @@ func (d *Pegnetd) Grade(ctx context.Context, block *factom.EBlock) (grader.Grade
 	// assume that error means it's below genesis for now
 	if err != nil {
 		if err != sql.ErrNoRows {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		prevWinners = prev
 	}
+	return d.gradeOPRs(block, prevWinners)
+}
 
-	g, err := grader.NewGrader(ver, int32(block.Height), prevWinners)
+// gradeOPRs grades the OPR block with the winners of the previous graded
+// block. The valid OPRs are returned for the miner statistics.
+func (d *Pegnetd) gradeOPRs(block *factom.EBlock, prevWinners []string) (grader.GradedBlock, []pegnet.OPRSubmission, error) {
+	if block == nil {
+		// TODO: Handle the case where there is no opr block.
+		// 		Must delay conversions if this happens
+		return nil, nil, nil
+	}
+
+	if *block.ChainID != OPRChain {
+		return nil, nil, fmt.Errorf("trying to grade a non-opr chain")
+	}
+
+	ver := activation.GradingVersion(block.Height)
+	g, err := grading.New(ver, int32(block.Height), prevWinners)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	var submissions []pegnet.OPRSubmission
+
 	for _, entry := range block.Entries {
 		extids := make([][]byte, len(entry.ExtIDs))
 		for i := range entry.ExtIDs {
@@ -57,8 +65,28 @@ func (d *Pegnetd) Grade(ctx context.Context, block *factom.EBlock) (grader.Grade
 		if err != nil {
 			// This is a noisy debug print
 			//logrus.WithError(err).WithFields(logrus.Fields{"hash": entry.Hash.String()}).Debug("failed to add opr")
+			continue
+		}
+		if s, ok := submission(entry); ok {
+			submissions = append(submissions, s)
 		}
 	}
 
-	return g.Grade(), nil
+	return g.Grade(), submissions, nil
+}
+
+// submission decodes the miner of an OPR that the grader accepted
+func submission(entry factom.Entry) (pegnet.OPRSubmission, bool) {
+	if len(entry.ExtIDs) < 2 || len(entry.ExtIDs[1]) != 8 {
+		return pegnet.OPRSubmission{}, false
+	}
+	o, err := opr.Parse(entry.Content)
+	if err != nil {
+		return pegnet.OPRSubmission{}, false
+	}
+	return pegnet.OPRSubmission{
+		MinerID:    o.GetID(),
+		Address:    o.GetAddress(),
+		Difficulty: binary.BigEndian.Uint64(entry.ExtIDs[1]),
+	}, true
 }
@@ -0,0 +1,197 @@
+package pegnet
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Account is a named group of the addresses of the wallet, eg: "trading",
+// "cold", or "payroll". An address can be in several accounts.
+type Account struct {
+	Name      string
+	Addresses []factom.FAAddress
+}
+
+var accountName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*$`)
+
+// ValidAccountName checks that the name starts with a letter and has only
+// letters, digits, '_', '.', or '-'
+func ValidAccountName(name string) error {
+	if !accountName.MatchString(name) {
+		return fmt.Errorf("the name must start with a letter and have only letters, digits, '_', '.', or '-'")
+	}
+	return nil
+}
+
+// ParseAccount parses an account of the form "<name> <address> [address...]"
+func ParseAccount(str string) (Account, error) {
+	fields := strings.Fields(str)
+	if len(fields) < 2 {
+		return Account{}, fmt.Errorf("account %q: expected '<name> <address> [address...]'", str)
+	}
+	if err := ValidAccountName(fields[0]); err != nil {
+		return Account{}, fmt.Errorf("account %q: %v", str, err)
+	}
+	a := Account{Name: fields[0]}
+	for _, field := range fields[1:] {
+		addr, err := factom.NewFAAddress(field)
+		if err != nil {
+			return Account{}, fmt.Errorf("account %q: invalid address %q: %v", str, field, err)
+		}
+		a.Addresses = append(a.Addresses, addr)
+	}
+	return a, nil
+}
+
+// Has is true if the address is in the account
+func (a Account) Has(addr factom.FAAddress) bool {
+	for _, other := range a.Addresses {
+		if other == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKey is an api key and the names of the accounts it can sign for
+type apiKey struct {
+	Key      string
+	Accounts []string
+}
+
+// Accounts are the accounts of the wallet, and the api keys the signing rpcs
+// are restricted to. Without api keys, the accounts only group the balances.
+type Accounts struct {
+	List []Account
+	keys []apiKey
+}
+
+// NewAccounts parses the accounts, and the api keys of the form
+// "<key> <account> [account...]". The accounts of a key must exist.
+func NewAccounts(accounts, keys []string) (*Accounts, error) {
+	a := new(Accounts)
+	names := make(map[string]bool)
+	for _, str := range accounts {
+		account, err := ParseAccount(str)
+		if err != nil {
+			return nil, err
+		}
+		if names[account.Name] {
+			return nil, fmt.Errorf("account %q is defined twice", account.Name)
+		}
+		names[account.Name] = true
+		a.List = append(a.List, account)
+	}
+
+	for _, str := range keys {
+		fields := strings.Fields(str)
+		if len(fields) < 2 {
+			// The key is not part of the error, it is a secret
+			return nil, fmt.Errorf("api key: expected '<key> <account> [account...]'")
+		}
+		for _, name := range fields[1:] {
+			if !names[name] {
+				return nil, fmt.Errorf("api key: unknown account %q", name)
+			}
+		}
+		a.keys = append(a.keys, apiKey{Key: fields[0], Accounts: fields[1:]})
+	}
+	return a, nil
+}
+
+// Of returns the name of the first account the address is in, false if it
+// is in none
+func (a *Accounts) Of(addr factom.FAAddress) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	for _, account := range a.List {
+		if account.Has(addr) {
+			return account.Name, true
+		}
+	}
+	return "", false
+}
+
+// addresses resolves an address or the name of an account
+func (a *Accounts) addresses(target string) ([]factom.FAAddress, error) {
+	if adr, err := factom.NewFAAddress(target); err == nil {
+		return []factom.FAAddress{adr}, nil
+	}
+	if a != nil {
+		for _, account := range a.List {
+			if account.Name == target {
+				return account.Addresses, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%q is neither an address nor an account", target)
+}
+
+// Restricted is true if the signing rpcs are restricted to the accounts of
+// the api keys
+func (a *Accounts) Restricted() bool {
+	return a != nil && len(a.keys) > 0
+}
+
+// keyAccounts returns the account names of the api key, the key is compared
+// in constant time
+func (a *Accounts) keyAccounts(key string) ([]string, bool) {
+	var accounts []string
+	found := false
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			accounts, found = k.Accounts, true
+		}
+	}
+	return accounts, found
+}
+
+// Visible returns the accounts the api key can see. That is every account
+// if the rpcs are not restricted, and otherwise only the accounts of the key.
+func (a *Accounts) Visible(key string) []Account {
+	if a == nil {
+		return nil
+	}
+	if !a.Restricted() {
+		return a.List
+	}
+	names, _ := a.keyAccounts(key)
+	var visible []Account
+	for _, account := range a.List {
+		for _, name := range names {
+			if account.Name == name {
+				visible = append(visible, account)
+				break
+			}
+		}
+	}
+	return visible
+}
+
+// CanSign checks if the api key can sign for the address. A known key can
+// only sign for the addresses of its accounts, and requests without a known
+// key can not sign for the addresses of any account.
+func (a *Accounts) CanSign(key string, addr factom.FAAddress) error {
+	if !a.Restricted() {
+		return nil
+	}
+	if _, known := a.keyAccounts(key); known {
+		for _, account := range a.Visible(key) {
+			if account.Has(addr) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s is not in an account of the api key", addr)
+	}
+	for _, account := range a.List {
+		if account.Has(addr) {
+			return fmt.Errorf("%s is in the account %q, which requires an api key", addr, account.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package pegnet_test
+
+import (
+	"testing"
+
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	accountAddrA = "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q"
+	accountAddrB = "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC"
+	accountAddrC = "FA3EPZYqodgyEGXNMbiZKE5TS2x2J9wF8J9MvPZb52iGR78xMgCb"
+)
+
+func TestParseAccount(t *testing.T) {
+	a, err := ParseAccount("trading  " + accountAddrA + " " + accountAddrB)
+	require.NoError(t, err)
+	assert.Equal(t, "trading", a.Name)
+	require.Len(t, a.Addresses, 2)
+	assert.Equal(t, accountAddrB, a.Addresses[1].String())
+
+	for _, bad := range []string{"trading", "1st " + accountAddrA, "trading FA2jK2", "cold " + accountAddrA + " EC1"} {
+		_, err := ParseAccount(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	accounts := []string{"trading " + accountAddrA, "cold " + accountAddrB}
+
+	open, err := NewAccounts(accounts, nil)
+	require.NoError(t, err)
+	assert.False(t, open.Restricted())
+	assert.Len(t, open.Visible(""), 2)
+	a, b, c := open.List[0].Addresses[0], open.List[1].Addresses[0], open.List[0].Addresses[0]
+	assert.NoError(t, open.CanSign("", b))
+
+	restricted, err := NewAccounts(accounts, []string{"secret trading"})
+	require.NoError(t, err)
+	assert.True(t, restricted.Restricted())
+	visible := restricted.Visible("secret")
+	require.Len(t, visible, 1)
+	assert.Equal(t, "trading", visible[0].Name)
+	assert.Empty(t, restricted.Visible("wrong"))
+
+	assert.NoError(t, restricted.CanSign("secret", a))
+	assert.Error(t, restricted.CanSign("secret", b), "not an account of the key")
+	assert.Error(t, restricted.CanSign("", b), "an account needs a key")
+	c[0]++ // not in any account
+	assert.NoError(t, restricted.CanSign("", c))
+	assert.Error(t, restricted.CanSign("secret", c), "the key is limited to its accounts")
+
+	_, err = NewAccounts(accounts, []string{"secret payroll"})
+	assert.Error(t, err, "unknown account")
+	_, err = NewAccounts(append(accounts, "cold "+accountAddrC), nil)
+	assert.Error(t, err, "duplicate account")
+}
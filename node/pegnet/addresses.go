@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/pegnet/pegnetd/fat/fat2"
+	log "github.com/sirupsen/logrus"
 )
 
 const createTableAddresses = `CREATE TABLE IF NOT EXISTS "pn_addresses" (
@@ -101,74 +103,74 @@ const createTableAddresses = `CREATE TABLE IF NOT EXISTS "pn_addresses" (
 CREATE INDEX IF NOT EXISTS "idx_address_balances_address_id" ON "pn_addresses"("address");
 `
 
-// Add additional columns if they do not exist
-const v4migrationNeeded = `
-SELECT CASE
-   -- If the new currencies do not already exist, run the query to add the columns.
-    WHEN (SELECT COUNT(*) FROM pragma_table_info('pn_addresses') WHERE name = 'pxtz_balance') > 0 THEN
-        false -- No migration needed
-    ELSE
-        true
-END AS migrate;
-`
-
-const addressTableV4Migration = `
-ALTER TABLE pn_addresses
-        ADD "paud_balance"  INTEGER NOT NULL DEFAULT 0
-            CONSTRAINT "insufficient balance" CHECK ("paud_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "pnzd_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("pnzd_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "psek_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("psek_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "pnok_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("pnok_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "prub_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("prub_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "pzar_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("pzar_balance" >= 0);
+// addressColumnMigration adds the balance column of an asset that was
+// registered after the database was created
+const addressColumnMigration = `
 ALTER TABLE pn_addresses
-        ADD "ptry_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("ptry_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "peos_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("peos_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "plink_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("plink_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "patom_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("patom_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "pbat_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("pbat_balance" >= 0);
-ALTER TABLE pn_addresses
-        ADD "pxtz_balance"  INTEGER NOT NULL DEFAULT 0
-			CONSTRAINT "insufficient balance" CHECK ("pxtz_balance" >= 0);
+        ADD "%[1]s"  INTEGER NOT NULL DEFAULT 0
+			CONSTRAINT "insufficient balance" CHECK ("%[1]s" >= 0);
 `
 
 // Use addressSelectCols instead of '*' to ensure the order is always the same
 var addressSelectCols = ``
 
-func init() {
+// addressBalanceIndexes creates an index on every balance column, used to
+// look up the holders of an asset. They are created in the migrations, after
+// all balance columns exist.
+var addressBalanceIndexes = ``
+
+// buildAddressQueries builds the queries above from the registered assets
+func buildAddressQueries() {
 	// +2 for 2 extra cols, -1 since the max is +1
 	cols := make([]string, fat2.PTickerMax+2-1)
 	cols[0] = "id"
 	cols[1] = "address"
+	var indexes strings.Builder
 	for i := 1; i < int(fat2.PTickerMax); i++ {
 		cols[i+1] = strings.ToLower(fat2.PTicker(i).String()) + "_balance"
+		indexes.WriteString(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "idx_address_%[1]s" ON "pn_addresses"("%[1]s", "address");`+"\n", cols[i+1]))
 	}
 	addressSelectCols = strings.Join(cols, ",") + " "
+	addressBalanceIndexes = indexes.String()
 }
 
-func (p *Pegnet) v4MigrationNeeded() (migrate bool, err error) {
-	row := p.DB.QueryRow(v4migrationNeeded)
-	err = row.Scan(&migrate)
-	return
+// addressColumnsMigrate adds the missing balance columns of newly
+// registered assets. The journal trigger on inserts lists every column, so it
+// is dropped to be recreated by balanceJournalMigrate.
+func addressColumnsMigrate(p *Pegnet) error {
+	for _, asset := range fat2.Assets() {
+		col := balanceColumn(asset.Ticker)
+		exists, err := p.columnExists("pn_addresses", col)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		log.Infof("Adding the balance column of %s", asset.Name)
+		if _, err := p.DB.Exec(fmt.Sprintf(addressColumnMigration, col)); err != nil {
+			return err
+		}
+		if _, err := p.DB.Exec(`DROP TRIGGER IF EXISTS "trg_balance_journal_insert";`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// balanceColumn is the column of the ticker in "pn_addresses"
+func balanceColumn(ticker fat2.PTicker) string {
+	return strings.ToLower(ticker.String()) + "_balance"
+}
+
+// balanceScanDest returns the scan destinations of the balance columns of
+// addressSelectCols, in ticker order
+func balanceScanDest(balances []uint64) []interface{} {
+	dest := make([]interface{}, 0, len(balances)-1)
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		dest = append(dest, &balances[i])
+	}
+	return dest
 }
 
 func (p *Pegnet) CreateTableAddresses() error {
@@ -186,7 +188,7 @@ func (p *Pegnet) AddToBalance(tx *sql.Tx, adr *factom.FAAddress, ticker fat2.PTi
                 ("address", "%[1]s_balance") VALUES (?, ?)
                 ON CONFLICT("address") DO
                 UPDATE SET "%[1]s_balance" = "%[1]s_balance" + "excluded"."%[1]s_balance";`
-	stmt, err := tx.Prepare(fmt.Sprintf(stmtStringFmt, strings.ToLower(ticker.String())))
+	stmt, err := p.prepare(tx, fmt.Sprintf(stmtStringFmt, strings.ToLower(ticker.String())))
 	if err != nil {
 		return 0, err
 	}
@@ -201,6 +203,18 @@ func (p *Pegnet) AddToBalance(tx *sql.Tx, adr *factom.FAAddress, ticker fat2.PTi
 	return lastID, nil
 }
 
+// AddToBalances adds the values to the typed balances of the addresses at
+// the same index with a single statement, creating the rows that do not
+// exist yet.
+func (p *Pegnet) AddToBalances(tx *sql.Tx, adrs []factom.FAAddress, ticker fat2.PTicker, values []uint64) error {
+	batch := newRowBatch(fmt.Sprintf(`INSERT INTO "pn_addresses" ("address", "%s_balance") VALUES`, strings.ToLower(ticker.String())), 2,
+		fmt.Sprintf(`ON CONFLICT("address") DO UPDATE SET "%[1]s_balance" = "%[1]s_balance" + "excluded"."%[1]s_balance";`, strings.ToLower(ticker.String())))
+	for i := range adrs {
+		batch.add(adrs[i][:], values[i])
+	}
+	return batch.exec(p, tx)
+}
+
 // SubFromBalance subtracts value from the typed balance of adr, creating a new row in
 // "pn_addresses" if it does not exist and value is 0. If successful, the row id is returned,
 // otherwise 0. If subtracting sub would result in a negative balance, txErr is not nil
@@ -224,7 +238,7 @@ func (p *Pegnet) SubFromBalance(tx *sql.Tx, adr *factom.FAAddress, ticker fat2.P
 
 	stmtStringFmt := `UPDATE pn_addresses SET %[1]s_balance = %[1]s_balance - ? WHERE address = ?;`
 	tickerLower := strings.ToLower(ticker.String())
-	stmt, err := tx.Prepare(fmt.Sprintf(stmtStringFmt, tickerLower))
+	stmt, err := p.prepare(tx, fmt.Sprintf(stmtStringFmt, tickerLower))
 	if err != nil {
 		return 0, nil, err
 	}
@@ -248,8 +262,11 @@ func (p *Pegnet) SelectPendingBalance(tx *sql.Tx, adr *factom.FAAddress, ticker
 	}
 	var balance uint64
 	stmtStringFmt := `SELECT %s_balance FROM pn_addresses WHERE address = ?;`
-	stmt := fmt.Sprintf(stmtStringFmt, strings.ToLower(ticker.String()))
-	err := tx.QueryRow(stmt, adr[:]).Scan(&balance)
+	stmt, err := p.prepare(tx, fmt.Sprintf(stmtStringFmt, strings.ToLower(ticker.String())))
+	if err != nil {
+		return 0, err
+	}
+	err = stmt.QueryRow(adr[:]).Scan(&balance)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, nil
@@ -285,6 +302,9 @@ type BalancePair struct {
 type BalancesPair struct {
 	Address  *factom.FAAddress
 	Balances []uint64
+	// EthAddress is the linked ethereum address of an RCD-e address, only
+	// set by SelectGlobalRichList
+	EthAddress *common.Address
 }
 
 // SelectRichList returns the balance of all addresses for a given ticker
@@ -325,6 +345,84 @@ func (p *Pegnet) SelectRichList(ticker fat2.PTicker, count int) ([]BalancePair,
 	return res, nil
 }
 
+// HoldersLimit is the maximum amount of holders to return in one query
+const HoldersLimit = 1000
+
+// SelectHolders returns the number of addresses holding the given ticker, and
+// up to `limit` holders starting at the offset, ordered by balance descending.
+func (p *Pegnet) SelectHolders(ticker fat2.PTicker, offset, limit int) (int, []BalancePair, error) {
+	if ticker <= fat2.PTickerInvalid || fat2.PTickerMax <= ticker {
+		return 0, nil, fmt.Errorf("invalid token type")
+	}
+	if offset < 0 {
+		return 0, nil, fmt.Errorf("invalid offset")
+	}
+	if limit <= 0 || limit > HoldersLimit {
+		limit = HoldersLimit
+	}
+	col := strings.ToLower(ticker.String()) + "_balance"
+
+	var count int
+	err := p.DB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM pn_addresses WHERE %s > 0;`, col)).Scan(&count)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := p.DB.Query(fmt.Sprintf(`SELECT address, %[1]s FROM pn_addresses WHERE %[1]s > 0
+		ORDER BY %[1]s DESC, address ASC LIMIT ? OFFSET ?;`, col), limit, offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var res []BalancePair
+	for rows.Next() {
+		var pair BalancePair
+		var adr []byte
+		if err := rows.Scan(&adr, &pair.Balance); err != nil {
+			return 0, nil, err
+		}
+
+		var fa factom.FAAddress
+		copy(fa[:], adr)
+		pair.Address = &fa
+
+		res = append(res, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return count, res, nil
+}
+
+// SelectAddressSample returns up to `limit` random addresses that have held
+// a balance
+func (p *Pegnet) SelectAddressSample(limit int) ([]factom.FAAddress, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+	rows, err := p.DB.Query(`SELECT address FROM pn_addresses ORDER BY RANDOM() LIMIT ?;`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []factom.FAAddress
+	for rows.Next() {
+		var adr []byte
+		if err := rows.Scan(&adr); err != nil {
+			return nil, err
+		}
+		var fa factom.FAAddress
+		copy(fa[:], adr)
+		res = append(res, fa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // SelectPendingBalances returns a map of all valid PTickers and their associated
 // balances for the given address. If the address is not in the database,
 // the map will contain 0 for all valid PTickers. This works on the pending tx
@@ -352,53 +450,7 @@ func (Pegnet) selectBalances(q QueryAble, adr *factom.FAAddress) (map[fat2.PTick
 	var id int
 	var address []byte
 	query := fmt.Sprintf(`SELECT %s FROM pn_addresses WHERE address = ?;`, addressSelectCols)
-	err := q.QueryRow(query, adr[:]).Scan(
-		&id,
-		&address,
-		&balances[fat2.PTickerPEG],
-		&balances[fat2.PTickerUSD],
-		&balances[fat2.PTickerEUR],
-		&balances[fat2.PTickerJPY],
-		&balances[fat2.PTickerGBP],
-		&balances[fat2.PTickerCAD],
-		&balances[fat2.PTickerCHF],
-		&balances[fat2.PTickerINR],
-		&balances[fat2.PTickerSGD],
-		&balances[fat2.PTickerCNY],
-		&balances[fat2.PTickerHKD],
-		&balances[fat2.PTickerKRW],
-		&balances[fat2.PTickerBRL],
-		&balances[fat2.PTickerPHP],
-		&balances[fat2.PTickerMXN],
-		&balances[fat2.PTickerXAU],
-		&balances[fat2.PTickerXAG],
-		&balances[fat2.PTickerXBT],
-		&balances[fat2.PTickerETH],
-		&balances[fat2.PTickerLTC],
-		&balances[fat2.PTickerRVN],
-		&balances[fat2.PTickerXBC],
-		&balances[fat2.PTickerFCT],
-		&balances[fat2.PTickerBNB],
-		&balances[fat2.PTickerXLM],
-		&balances[fat2.PTickerADA],
-		&balances[fat2.PTickerXMR],
-		&balances[fat2.PTickerDASH],
-		&balances[fat2.PTickerZEC],
-		&balances[fat2.PTickerDCR],
-		// V4 Additions
-		&balances[fat2.PTickerAUD],
-		&balances[fat2.PTickerNZD],
-		&balances[fat2.PTickerSEK],
-		&balances[fat2.PTickerNOK],
-		&balances[fat2.PTickerRUB],
-		&balances[fat2.PTickerZAR],
-		&balances[fat2.PTickerTRY],
-		&balances[fat2.PTickerEOS],
-		&balances[fat2.PTickerLINK],
-		&balances[fat2.PTickerATOM],
-		&balances[fat2.PTickerBAT],
-		&balances[fat2.PTickerXTZ],
-	)
+	err := q.QueryRow(query, adr[:]).Scan(append([]interface{}{&id, &address}, balanceScanDest(balances)...)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return balanceMap, nil
@@ -432,53 +484,7 @@ func (p *Pegnet) SelectAllBalances() ([]BalancesPair, error) {
 
 		var id int
 		var address []byte
-		err = rows.Scan(
-			&id,
-			&address,
-			&bp.Balances[fat2.PTickerPEG],
-			&bp.Balances[fat2.PTickerUSD],
-			&bp.Balances[fat2.PTickerEUR],
-			&bp.Balances[fat2.PTickerJPY],
-			&bp.Balances[fat2.PTickerGBP],
-			&bp.Balances[fat2.PTickerCAD],
-			&bp.Balances[fat2.PTickerCHF],
-			&bp.Balances[fat2.PTickerINR],
-			&bp.Balances[fat2.PTickerSGD],
-			&bp.Balances[fat2.PTickerCNY],
-			&bp.Balances[fat2.PTickerHKD],
-			&bp.Balances[fat2.PTickerKRW],
-			&bp.Balances[fat2.PTickerBRL],
-			&bp.Balances[fat2.PTickerPHP],
-			&bp.Balances[fat2.PTickerMXN],
-			&bp.Balances[fat2.PTickerXAU],
-			&bp.Balances[fat2.PTickerXAG],
-			&bp.Balances[fat2.PTickerXBT],
-			&bp.Balances[fat2.PTickerETH],
-			&bp.Balances[fat2.PTickerLTC],
-			&bp.Balances[fat2.PTickerRVN],
-			&bp.Balances[fat2.PTickerXBC],
-			&bp.Balances[fat2.PTickerFCT],
-			&bp.Balances[fat2.PTickerBNB],
-			&bp.Balances[fat2.PTickerXLM],
-			&bp.Balances[fat2.PTickerADA],
-			&bp.Balances[fat2.PTickerXMR],
-			&bp.Balances[fat2.PTickerDASH],
-			&bp.Balances[fat2.PTickerZEC],
-			&bp.Balances[fat2.PTickerDCR],
-			// V4 Additions
-			&bp.Balances[fat2.PTickerAUD],
-			&bp.Balances[fat2.PTickerNZD],
-			&bp.Balances[fat2.PTickerSEK],
-			&bp.Balances[fat2.PTickerNOK],
-			&bp.Balances[fat2.PTickerRUB],
-			&bp.Balances[fat2.PTickerZAR],
-			&bp.Balances[fat2.PTickerTRY],
-			&bp.Balances[fat2.PTickerEOS],
-			&bp.Balances[fat2.PTickerLINK],
-			&bp.Balances[fat2.PTickerATOM],
-			&bp.Balances[fat2.PTickerBAT],
-			&bp.Balances[fat2.PTickerXTZ],
-		)
+		err = rows.Scan(append([]interface{}{&id, &address}, balanceScanDest(bp.Balances)...)...)
 		if err != nil {
 			return nil, err
 		}
@@ -492,7 +498,77 @@ func (p *Pegnet) SelectAllBalances() ([]BalancesPair, error) {
 	return res, nil
 }
 
+// SelectGlobalRichList returns the `count` addresses with the highest value
+// of all their balances, valued at the given rates. The ranking is done in SQL
+// with floating point values, so the exact value of the balances is left to
+// the caller. Assets without a rate are not valued. The linked ethereum
+// addresses are joined in.
+func (p *Pegnet) SelectGlobalRichList(rates map[fat2.PTicker]uint64, count int) ([]BalancesPair, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid count")
+	}
+
+	var terms []string
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		if rates[i] == 0 {
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("CAST(%s_balance AS REAL) * %d", strings.ToLower(i.String()), rates[i]))
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	value := strings.Join(terms, " + ")
+
+	query := fmt.Sprintf(`SELECT r.*, e."eth_address" FROM
+		(SELECT %s, %s AS "rank" FROM pn_addresses WHERE %s > 0 ORDER BY %s DESC LIMIT ?) AS r
+		LEFT JOIN "pn_eth_addresses" AS e ON e."address" = r."address" ORDER BY r."rank" DESC;`, addressSelectCols, value, value, value)
+	rows, err := p.DB.Query(query, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []BalancesPair
+	for rows.Next() {
+		var bp BalancesPair
+		bp.Balances = make([]uint64, int(fat2.PTickerMax))
+
+		var id int
+		var rank float64
+		var address, eth []byte
+		dest := append([]interface{}{&id, &address}, balanceScanDest(bp.Balances)...)
+		err = rows.Scan(append(dest, &rank, &eth)...)
+		if err != nil {
+			return nil, err
+		}
+
+		var fa factom.FAAddress
+		copy(fa[:], address)
+		bp.Address = &fa
+		if eth != nil {
+			e := common.BytesToAddress(eth)
+			bp.EthAddress = &e
+		}
+
+		res = append(res, bp)
+	}
+	return res, rows.Err()
+}
+
+// SelectIssuances returns a map of all valid PTickers and the total amount
+// of each that is held by all addresses.
 func (p *Pegnet) SelectIssuances() (map[fat2.PTicker]uint64, error) {
+	return p.selectIssuances(p.DB)
+}
+
+// SelectPendingIssuances returns the same as SelectIssuances, but in the
+// context of the pending tx
+func (p *Pegnet) SelectPendingIssuances(tx *sql.Tx) (map[fat2.PTicker]uint64, error) {
+	return p.selectIssuances(tx)
+}
+
+func (Pegnet) selectIssuances(q QueryAble) (map[fat2.PTicker]uint64, error) {
 	issuanceMap := make(map[fat2.PTicker]uint64, int(fat2.PTickerMax))
 	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
 		issuanceMap[i] = 0
@@ -507,51 +583,7 @@ func (p *Pegnet) SelectIssuances() (map[fat2.PTicker]uint64, error) {
 	}
 	tickerLower := strings.ToLower((fat2.PTickerMax - 1).String())
 	sb.WriteString(fmt.Sprintf("IFNULL(SUM(%s_balance), 0) ", tickerLower))
-	err := p.DB.QueryRow(fmt.Sprintf(queryFmt, sb.String())).Scan(
-		&issuances[fat2.PTickerPEG],
-		&issuances[fat2.PTickerUSD],
-		&issuances[fat2.PTickerEUR],
-		&issuances[fat2.PTickerJPY],
-		&issuances[fat2.PTickerGBP],
-		&issuances[fat2.PTickerCAD],
-		&issuances[fat2.PTickerCHF],
-		&issuances[fat2.PTickerINR],
-		&issuances[fat2.PTickerSGD],
-		&issuances[fat2.PTickerCNY],
-		&issuances[fat2.PTickerHKD],
-		&issuances[fat2.PTickerKRW],
-		&issuances[fat2.PTickerBRL],
-		&issuances[fat2.PTickerPHP],
-		&issuances[fat2.PTickerMXN],
-		&issuances[fat2.PTickerXAU],
-		&issuances[fat2.PTickerXAG],
-		&issuances[fat2.PTickerXBT],
-		&issuances[fat2.PTickerETH],
-		&issuances[fat2.PTickerLTC],
-		&issuances[fat2.PTickerRVN],
-		&issuances[fat2.PTickerXBC],
-		&issuances[fat2.PTickerFCT],
-		&issuances[fat2.PTickerBNB],
-		&issuances[fat2.PTickerXLM],
-		&issuances[fat2.PTickerADA],
-		&issuances[fat2.PTickerXMR],
-		&issuances[fat2.PTickerDASH],
-		&issuances[fat2.PTickerZEC],
-		&issuances[fat2.PTickerDCR],
-		// V4 Additions
-		&issuances[fat2.PTickerAUD],
-		&issuances[fat2.PTickerNZD],
-		&issuances[fat2.PTickerSEK],
-		&issuances[fat2.PTickerNOK],
-		&issuances[fat2.PTickerRUB],
-		&issuances[fat2.PTickerZAR],
-		&issuances[fat2.PTickerTRY],
-		&issuances[fat2.PTickerEOS],
-		&issuances[fat2.PTickerLINK],
-		&issuances[fat2.PTickerATOM],
-		&issuances[fat2.PTickerBAT],
-		&issuances[fat2.PTickerXTZ],
-	)
+	err := q.QueryRow(fmt.Sprintf(queryFmt, sb.String())).Scan(balanceScanDest(issuances)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return issuanceMap, nil
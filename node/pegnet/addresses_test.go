@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/ethereum/go-ethereum/common"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pegnet/pegnetd/fat/fat2"
 	. "github.com/pegnet/pegnetd/node/pegnet"
@@ -106,6 +107,40 @@ func TestPegnet_AddToBalance(t *testing.T) {
 	assert.Equal(t, uint64(100), balance, "Incorrect finalized balance after tx.Commit()")
 }
 
+func TestPegnet_AddToBalances(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+
+	tx, err := p.DB.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+
+	// more rows than fit in one statement, and an address listed twice
+	var adrs []factom.FAAddress
+	var values []uint64
+	for i := 0; i < 1200; i++ {
+		var adr factom.FAAddress
+		adr[0], adr[1] = byte(i>>8), byte(i)
+		adrs = append(adrs, adr)
+		values = append(values, uint64(i))
+	}
+	adrs = append(adrs, adrs[7])
+	values = append(values, 100)
+
+	require.NoError(t, p.AddToBalances(tx, adrs, fat2.PTickerPEG, values))
+	require.NoError(t, tx.Commit())
+
+	for i := 0; i < 1200; i++ {
+		balance, err := p.SelectBalance(&adrs[i], fat2.PTickerPEG)
+		require.NoError(t, err)
+		expected := uint64(i)
+		if i == 7 {
+			expected += 100
+		}
+		assert.Equal(t, expected, balance)
+	}
+}
+
 func TestPegnet_SubFromBalance(t *testing.T) {
 	p, err := setupPegnet()
 	require.NoError(t, err)
@@ -140,3 +175,113 @@ func TestPegnet_SubFromBalance(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, uint64(50), balance, "Incorrect finalized balance after tx.Commit()")
 }
+
+func TestPegnet_SelectHolders(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	adrs := make([]factom.FAAddress, 4)
+	for i := range adrs {
+		adrs[i][0] = byte(i + 1)
+		_, err = p.AddToBalance(tx, &adrs[i], fat2.PTickerPEG, uint64(i*10))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	count, holders, err := p.SelectHolders(fat2.PTickerPEG, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, holders, 2)
+	assert.Equal(t, adrs[3], *holders[0].Address)
+	assert.Equal(t, uint64(30), holders[0].Balance)
+	assert.Equal(t, adrs[2], *holders[1].Address)
+
+	count, holders, err = p.SelectHolders(fat2.PTickerPEG, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, holders, 1)
+	assert.Equal(t, adrs[1], *holders[0].Address)
+
+	count, holders, err = p.SelectHolders(fat2.PTickerUSD, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Empty(t, holders)
+
+	_, _, err = p.SelectHolders(fat2.PTickerInvalid, 0, 0)
+	assert.EqualError(t, err, "invalid token type")
+}
+
+func TestPegnet_SelectGlobalRichList(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	adrs := make([]factom.FAAddress, 3)
+	for i := range adrs {
+		adrs[i][0] = byte(i + 1)
+	}
+	_, err = p.AddToBalance(tx, &adrs[0], fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &adrs[1], fat2.PTickerUSD, 20)
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &adrs[2], fat2.PTickerEUR, 1000) // no rate
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.NoError(t, p.CreateTableEthAddresses())
+	eth := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	_, err = p.DB.Exec(`INSERT INTO "pn_eth_addresses" ("address", "eth_address", "height") VALUES (?, ?, 1);`, adrs[0][:], eth[:])
+	require.NoError(t, err)
+
+	rates := map[fat2.PTicker]uint64{fat2.PTickerPEG: 1e6, fat2.PTickerUSD: 1e8}
+	rich, err := p.SelectGlobalRichList(rates, 10)
+	require.NoError(t, err)
+	require.Len(t, rich, 2)
+	assert.Equal(t, adrs[1], *rich[0].Address)
+	assert.Equal(t, uint64(20), rich[0].Balances[fat2.PTickerUSD])
+	assert.Nil(t, rich[0].EthAddress)
+	assert.Equal(t, adrs[0], *rich[1].Address)
+	assert.Equal(t, &eth, rich[1].EthAddress)
+
+	rich, err = p.SelectGlobalRichList(rates, 1)
+	require.NoError(t, err)
+	require.Len(t, rich, 1)
+
+	_, err = p.SelectGlobalRichList(rates, 0)
+	assert.EqualError(t, err, "invalid count")
+}
+
+func TestPegnet_SelectAddressSample(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+
+	sample, err := p.SelectAddressSample(10)
+	require.NoError(t, err)
+	assert.Empty(t, sample)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	adrs := make([]factom.FAAddress, 5)
+	for i := range adrs {
+		adrs[i][0] = byte(i + 1)
+		_, err = p.AddToBalance(tx, &adrs[i], fat2.PTickerPEG, 100)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	sample, err = p.SelectAddressSample(3)
+	require.NoError(t, err)
+	assert.Len(t, sample, 3)
+
+	sample, err = p.SelectAddressSample(10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, adrs, sample)
+
+	_, err = p.SelectAddressSample(0)
+	assert.EqualError(t, err, "invalid limit")
+}
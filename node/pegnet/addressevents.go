@@ -0,0 +1,132 @@
+package pegnet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// The causes of a balance change in the address event feed
+const (
+	EventCauseTransfer      = "transfer"
+	EventCauseConversionIn  = "conversion-in"
+	EventCauseConversionOut = "conversion-out"
+	EventCauseRefund        = "refund"
+	EventCauseBurn          = "burn"
+	EventCauseCoinbase      = "coinbase"
+)
+
+// AddressEvent is a single change of the balance of an address in one asset.
+// The height is the height the change was applied at, which for conversions
+// is the height they were executed at.
+type AddressEvent struct {
+	Height       uint32            `json:"height"`
+	Timestamp    time.Time         `json:"timestamp"`
+	TxID         string            `json:"txid"`
+	Asset        string            `json:"asset"`
+	Delta        int64             `json:"delta"`
+	Cause        string            `json:"cause"`
+	Counterparty *factom.FAAddress `json:"counterparty,omitempty"`
+}
+
+// SelectAddressEvents returns the balance changes of an address caused by the
+// executed actions it is involved in. The offset and count refer to the
+// actions, not the events; an action can cause several events. If the asset is
+// not empty, only the events of that asset are returned.
+func (p *Pegnet) SelectAddressEvents(ctx context.Context, adr *factom.FAAddress, asset string, offset int, desc bool) ([]AddressEvent, int, error) {
+	from := "pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx"
+	where := "lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND " +
+		"batch.entry_hash = tx.entry_hash AND batch.executed > 0"
+	if asset != "" {
+		if fat2.StringToTicker(asset) == fat2.PTickerInvalid {
+			return nil, 0, fmt.Errorf("invalid asset specified")
+		}
+		where += fmt.Sprintf(" AND (tx.from_asset = '%[1]s' OR tx.to_asset = '%[1]s')", asset)
+	}
+	order := "ORDER BY batch.executed ASC, batch.history_id ASC, tx.tx_index ASC"
+	if desc {
+		order = "ORDER BY batch.executed DESC, batch.history_id DESC, tx.tx_index DESC"
+	}
+
+	var count int
+	err := p.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, where), adr[:]).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+	if offset > count {
+		return nil, 0, fmt.Errorf("offset too big")
+	}
+
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s %s LIMIT %d OFFSET %d",
+		historyQueryFields, from, where, order, QueryLimit, offset), adr[:])
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	actions, err := turnRowsIntoHistoryTransactions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var events []AddressEvent
+	for _, action := range actions {
+		for _, e := range actionEvents(adr, action) {
+			if asset == "" || e.Asset == asset {
+				events = append(events, e)
+			}
+		}
+	}
+	return events, count, nil
+}
+
+// actionEvents turns an executed action into the balance changes it caused
+// for the address
+func actionEvents(adr *factom.FAAddress, action HistoryTransaction) []AddressEvent {
+	base := AddressEvent{Height: uint32(action.Executed), Timestamp: action.Timestamp, TxID: action.TxID}
+	event := func(asset string, delta int64, cause string, counterparty *factom.FAAddress) AddressEvent {
+		e := base
+		e.Asset, e.Delta, e.Cause, e.Counterparty = asset, delta, cause, counterparty
+		return e
+	}
+
+	var events []AddressEvent
+	isInput := *action.FromAddress == *adr
+	switch action.TxAction {
+	case Transfer:
+		for i := range action.Outputs {
+			out := action.Outputs[i]
+			if isInput {
+				events = append(events, event(action.FromAsset, -out.Amount, EventCauseTransfer, &out.Address))
+			}
+			if out.Address == *adr {
+				events = append(events, event(action.FromAsset, out.Amount, EventCauseTransfer, action.FromAddress))
+			}
+		}
+	case Conversion:
+		if !isInput {
+			break
+		}
+		events = append(events, event(action.FromAsset, -action.FromAmount, EventCauseConversionOut, nil))
+		if action.ToAmount > 0 {
+			events = append(events, event(action.ToAsset, action.ToAmount, EventCauseConversionIn, nil))
+		}
+		// PEG requests refund the input not covered by the bank as an output
+		for _, out := range action.Outputs {
+			if out.Amount > 0 {
+				events = append(events, event(action.FromAsset, out.Amount, EventCauseRefund, nil))
+			}
+		}
+	case Coinbase:
+		events = append(events, event(action.ToAsset, action.ToAmount, EventCauseCoinbase, nil))
+	case FCTBurn:
+		events = append(events, event(action.ToAsset, action.ToAmount, EventCauseBurn, nil))
+	}
+	return events
+}
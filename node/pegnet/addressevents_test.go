@@ -0,0 +1,70 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_AddressEvents(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var executed, pending factom.Bytes32
+	executed[0], pending[0] = 1, 2
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &executed, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+	pendingBatch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &pending, Timestamp: time.Unix(100, 0)}}
+	pendingBatch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 1, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 1}}},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 1, pendingBatch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 101))
+	require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 1, 20, 1, 4))
+	require.NoError(t, tx.Commit())
+
+	// the pending transfer is not part of the feed
+	events, count, err := p.SelectAddressEvents(context.Background(), &a, "", 0, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Len(t, events, 3)
+	assert.Equal(t, AddressEvent{Height: 101, Timestamp: time.Unix(100, 0), TxID: FormatTxID(0, executed.String()),
+		Asset: "PEG", Delta: 10, Cause: EventCauseTransfer, Counterparty: &b}, events[0])
+	assert.Equal(t, int64(-5), events[1].Delta)
+	assert.Equal(t, EventCauseConversionOut, events[1].Cause)
+	assert.Equal(t, "pUSD", events[2].Asset)
+	assert.Equal(t, int64(20), events[2].Delta)
+	assert.Equal(t, EventCauseConversionIn, events[2].Cause)
+
+	events, _, err = p.SelectAddressEvents(context.Background(), &a, "pUSD", 0, false)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventCauseConversionIn, events[0].Cause)
+
+	events, count, err = p.SelectAddressEvents(context.Background(), &b, "", 0, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(-10), events[0].Delta)
+	assert.Equal(t, &a, events[0].Counterparty)
+}
@@ -0,0 +1,183 @@
+package pegnet
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableAddressStats is a SQL string that creates the
+// "pn_address_stats" and "pn_address_asset_stats" tables.
+//
+// The tables hold aggregates of the activity of an address and are updated
+// as blocks are synced, so they do not require a scan of the history.
+// Databases synced before the tables existed only have the activity since
+// the upgrade.
+const createTableAddressStats = `CREATE TABLE IF NOT EXISTS "pn_address_stats" (
+	"address"		BLOB PRIMARY KEY,
+	"tx_count"		INTEGER NOT NULL DEFAULT 0,
+	"first_seen"	INTEGER NOT NULL, -- height of the first activity
+	"last_active"	INTEGER NOT NULL  -- height of the latest activity
+);
+
+CREATE TABLE IF NOT EXISTS "pn_address_asset_stats" (
+	"address"		BLOB NOT NULL,
+	"token"			TEXT NOT NULL,
+	"transfer_in"	INTEGER NOT NULL DEFAULT 0,
+	"transfer_out"	INTEGER NOT NULL DEFAULT 0,
+	"converted_in"	INTEGER NOT NULL DEFAULT 0,
+	"converted_out"	INTEGER NOT NULL DEFAULT 0,
+
+	PRIMARY KEY("address", "token")
+);
+`
+
+// AddressVolume is one of the volume aggregates of an address
+type AddressVolume int
+
+const (
+	// TransferIn is the amount received through transfers
+	TransferIn AddressVolume = iota
+	// TransferOut is the amount sent through transfers
+	TransferOut
+	// ConvertedIn is the amount received through conversions
+	ConvertedIn
+	// ConvertedOut is the amount consumed by conversions
+	ConvertedOut
+)
+
+func (v AddressVolume) column() string {
+	switch v {
+	case TransferIn:
+		return "transfer_in"
+	case TransferOut:
+		return "transfer_out"
+	case ConvertedIn:
+		return "converted_in"
+	case ConvertedOut:
+		return "converted_out"
+	}
+	return ""
+}
+
+// AddressAssetStats are the volume aggregates of an address for one asset
+type AddressAssetStats struct {
+	TransferIn   int64 `json:"transferin"`
+	TransferOut  int64 `json:"transferout"`
+	ConvertedIn  int64 `json:"convertedin"`
+	ConvertedOut int64 `json:"convertedout"`
+}
+
+// AddressStats are the aggregates of the activity of an address
+type AddressStats struct {
+	TxCount    int64
+	FirstSeen  uint32
+	LastActive uint32
+	Assets     map[fat2.PTicker]AddressAssetStats
+}
+
+// CreateTableAddressStats is used to expose this table for unit tests
+func (p *Pegnet) CreateTableAddressStats() error {
+	_, err := p.DB.Exec(createTableAddressStats)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddAddressActivity counts a transaction for the address at the given height
+func (p *Pegnet) AddAddressActivity(tx *sql.Tx, adr *factom.FAAddress, height uint32) error {
+	return p.AddAddressActivities(tx, []factom.FAAddress{*adr}, height)
+}
+
+// AddAddressActivities counts a transaction at the height for every address,
+// an address that is listed twice is counted twice
+func (p *Pegnet) AddAddressActivities(tx *sql.Tx, adrs []factom.FAAddress, height uint32) error {
+	batch := newRowBatch(`INSERT INTO "pn_address_stats" ("address", "tx_count", "first_seen", "last_active") VALUES`, 4,
+		`ON CONFLICT("address") DO UPDATE SET
+			"tx_count" = "tx_count" + "excluded"."tx_count",
+			"first_seen" = MIN("first_seen", "excluded"."first_seen"),
+			"last_active" = MAX("last_active", "excluded"."last_active");`)
+	for i := range adrs {
+		batch.add(adrs[i][:], 1, height, height)
+	}
+	return batch.exec(p, tx)
+}
+
+// AddressVolumeChange is an amount to add to a volume aggregate of an address
+type AddressVolumeChange struct {
+	Address factom.FAAddress
+	Ticker  fat2.PTicker
+	Volume  AddressVolume
+	Amount  int64
+}
+
+// AddAddressVolume adds the amount to the volume aggregate of the address.
+// The amount can be negative, for example to account for refunds.
+func (p *Pegnet) AddAddressVolume(tx *sql.Tx, adr *factom.FAAddress, ticker fat2.PTicker, volume AddressVolume, amount int64) error {
+	return p.AddAddressVolumes(tx, []AddressVolumeChange{{Address: *adr, Ticker: ticker, Volume: volume, Amount: amount}})
+}
+
+// AddAddressVolumes adds the changes to the volume aggregates with a single
+// statement, the changes of an address and asset are summed
+func (p *Pegnet) AddAddressVolumes(tx *sql.Tx, changes []AddressVolumeChange) error {
+	batch := newRowBatch(`INSERT INTO "pn_address_asset_stats"
+		("address", "token", "transfer_in", "transfer_out", "converted_in", "converted_out") VALUES`, 6,
+		`ON CONFLICT("address", "token") DO UPDATE SET
+			"transfer_in" = "transfer_in" + "excluded"."transfer_in",
+			"transfer_out" = "transfer_out" + "excluded"."transfer_out",
+			"converted_in" = "converted_in" + "excluded"."converted_in",
+			"converted_out" = "converted_out" + "excluded"."converted_out";`)
+	for i := range changes {
+		change := &changes[i]
+		if change.Volume.column() == "" {
+			return fmt.Errorf("invalid address volume")
+		}
+		amounts := make([]interface{}, 4)
+		for v := TransferIn; v <= ConvertedOut; v++ {
+			amounts[v] = int64(0)
+		}
+		amounts[change.Volume] = change.Amount
+		batch.add(append([]interface{}{change.Address[:], change.Ticker.String()}, amounts...)...)
+	}
+	return batch.exec(p, tx)
+}
+
+// SelectAddressStats returns the aggregates of the address. If the address
+// has no recorded activity, nil is returned.
+func (p *Pegnet) SelectAddressStats(adr *factom.FAAddress) (*AddressStats, error) {
+	stats := new(AddressStats)
+	err := p.DB.QueryRow(`SELECT "tx_count", "first_seen", "last_active" FROM "pn_address_stats" WHERE "address" = ?;`, adr[:]).
+		Scan(&stats.TxCount, &stats.FirstSeen, &stats.LastActive)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rows, err := p.DB.Query(`SELECT "token", "transfer_in", "transfer_out", "converted_in", "converted_out"
+		FROM "pn_address_asset_stats" WHERE "address" = ?;`, adr[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats.Assets = make(map[fat2.PTicker]AddressAssetStats)
+	for rows.Next() {
+		var token string
+		var asset AddressAssetStats
+		if err := rows.Scan(&token, &asset.TransferIn, &asset.TransferOut, &asset.ConvertedIn, &asset.ConvertedOut); err != nil {
+			return nil, err
+		}
+		if t := fat2.StringToTicker(token); t != fat2.PTickerInvalid {
+			stats.Assets[t] = asset
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,44 @@
+package pegnet_test
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_AddressStats(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableAddressStats())
+
+	var adr factom.FAAddress
+	stats, err := p.SelectAddressStats(&adr)
+	require.NoError(t, err)
+	assert.Nil(t, stats)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.AddAddressActivity(tx, &adr, 20))
+	require.NoError(t, p.AddAddressActivity(tx, &adr, 10))
+	require.NoError(t, p.AddAddressActivity(tx, &adr, 30))
+	require.NoError(t, p.AddAddressVolume(tx, &adr, fat2.PTickerPEG, TransferOut, 100))
+	require.NoError(t, p.AddAddressVolume(tx, &adr, fat2.PTickerPEG, TransferOut, 50))
+	require.NoError(t, p.AddAddressVolume(tx, &adr, fat2.PTickerPEG, ConvertedOut, 40))
+	require.NoError(t, p.AddAddressVolume(tx, &adr, fat2.PTickerPEG, ConvertedOut, -10))
+	require.NoError(t, p.AddAddressVolume(tx, &adr, fat2.PTickerUSD, ConvertedIn, 7))
+	require.NoError(t, tx.Commit())
+
+	stats, err = p.SelectAddressStats(&adr)
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(3), stats.TxCount)
+	assert.Equal(t, uint32(10), stats.FirstSeen)
+	assert.Equal(t, uint32(30), stats.LastActive)
+	assert.Equal(t, AddressAssetStats{TransferOut: 150, ConvertedOut: 30}, stats.Assets[fat2.PTickerPEG])
+	assert.Equal(t, AddressAssetStats{ConvertedIn: 7}, stats.Assets[fat2.PTickerUSD])
+}
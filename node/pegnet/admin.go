@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/pegnet/pegnetd/activation"
 )
 
 // This file can be used for node administration related functions
@@ -33,9 +35,8 @@ var (
 		// If the pegnet node syncs a hardfork height with any height less than
 		// the minimum version, the node will not start.
 		//
-		// V4 OPR Update
-		// Estimated to be  Feb 12, 2020, 18:00 UTC
-		{ActivationHeight: 231620, MinimumVersion: 1},
+		// V4 OPR Update, it follows the V4 update of the network
+		{ActivationHeight: activation.MainNet.V4OPRUpdate, MinimumVersion: 1},
 	}
 )
 
@@ -82,6 +83,17 @@ func (Pegnet) markHeightSyncedVersion(tx QueryAble, height uint32, version int)
 	return nil
 }
 
+// MarkSyncStart marks the height a fresh database starts to sync from as
+// synced by this version. A hardfork at the start height is not synced itself,
+// and would be taken for a fork that was synced before the versions were
+// tracked.
+func (Pegnet) MarkSyncStart(tx QueryAble, height uint32) error {
+	_, err := tx.Exec(`INSERT OR IGNORE INTO "pn_sync_version"
+			("height", "version", "unix_timestamp")
+			VALUES (?, ?, ?);`, height, PegnetdSyncVersion, time.Now().Unix())
+	return err
+}
+
 func (p Pegnet) HighestSynced(tx QueryAble) (uint32, error) {
 	return p.synced("max", tx)
 }
@@ -149,9 +161,15 @@ func (p Pegnet) CheckHardForks(tx QueryAble) error {
 		return err
 	}
 
+	// A fresh database has not synced past any fork, not even one at 0
+	var rows int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM "pn_sync_version";`).Scan(&rows); err != nil {
+		return err
+	}
+
 	for _, event := range Hardforks {
 		// If the event is not synced past, then we do not need to check
-		if event.ActivationHeight <= top {
+		if rows > 0 && event.ActivationHeight <= top {
 			version, err := p.FetchMinSyncedVersion(tx, event.ActivationHeight)
 			if err != nil {
 				return err
@@ -25,6 +25,13 @@ func TestPegnet_CheckHardForks(t *testing.T) {
 		}
 	})
 
+	t.Run("test blank db with a fork at 0", func(t *testing.T) {
+		pegnet.Hardforks = []pegnet.ForkEvent{{ActivationHeight: 0, MinimumVersion: 1}}
+		if err := p.CheckHardForks(p.DB); err != nil {
+			t.Errorf("blank db error: %v", err)
+		}
+	})
+
 	pegnet.Hardforks = []pegnet.ForkEvent{
 		{ActivationHeight: 0, MinimumVersion: 0},
 		{ActivationHeight: 1, MinimumVersion: 1},
@@ -70,3 +77,47 @@ func TestPegnet_CheckHardForks(t *testing.T) {
 		}
 	})
 }
+
+func TestPegnet_CheckHardForks_AtSyncStart(t *testing.T) {
+	p, err := setupPegnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tearDownPegnet(p)
+	if err := p.CreateTableSyncVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateTableMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(forks []pegnet.ForkEvent) { pegnet.Hardforks = forks }(pegnet.Hardforks)
+	pegnet.Hardforks = []pegnet.ForkEvent{{ActivationHeight: 0, MinimumVersion: pegnet.PegnetdSyncVersion}}
+
+	// A database that syncs from 0 with the fork at 0 passes the check
+	// after a restart
+	if err := p.MarkSyncStart(p.DB, 0); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := p.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := uint32(1); h <= 5; h++ {
+		if err := p.InsertSynced(tx, &pegnet.BlockSync{Synced: h}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.CheckHardForks(p.DB); err != nil {
+			t.Errorf("restart %d: %v", i, err)
+		}
+	}
+	// Marking the start again is a no-op
+	if err := p.MarkSyncStart(p.DB, 0); err != nil {
+		t.Error(err)
+	}
+}
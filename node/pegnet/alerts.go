@@ -0,0 +1,213 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableAlerts is a SQL string that creates the table holding the
+// actions that matched an alert rule. An action matching several rules is
+// recorded once per rule.
+const createTableAlerts = `CREATE TABLE IF NOT EXISTS "pn_alerts" (
+	"id"			INTEGER PRIMARY KEY,
+	"height"		INTEGER NOT NULL, -- height the action was applied at
+	"rule"			TEXT NOT NULL,
+	"entry_hash"	BLOB NOT NULL,
+	"tx_index"		INTEGER NOT NULL,
+	"action_type"	INTEGER NOT NULL,
+	"address"		BLOB NOT NULL,
+	"token"			TEXT NOT NULL,
+	"amount"		INTEGER NOT NULL,
+
+	UNIQUE("rule", "entry_hash", "tx_index", "height")
+);
+CREATE INDEX IF NOT EXISTS "idx_alerts_height" ON "pn_alerts"("height");
+`
+
+// AlertsLimit is the maximum amount of alerts that can be queried at once
+const AlertsLimit = 1000
+
+// AlertRule matches actions that move more than a threshold of an asset,
+// eg: "transfer > 1000000 pUSD". The action is one of "transfer",
+// "conversion", "coinbase", "burn", or "any", and the comparison either ">"
+// or ">=". Conversions match both the converted and the received asset.
+type AlertRule struct {
+	Rule      string
+	Action    HistoryAction // 0 for any action
+	OrEqual   bool
+	Threshold int64 // in factoshis
+	Asset     string
+}
+
+// ParseAlertRule parses a rule of the form "<action> <op> <amount> <asset>"
+func ParseAlertRule(rule string) (AlertRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) != 4 {
+		return AlertRule{}, fmt.Errorf("alert rule %q: expected '<action> <op> <amount> <asset>'", rule)
+	}
+	r := AlertRule{Rule: strings.Join(fields, " "), Asset: fields[3]}
+
+	switch strings.ToLower(fields[0]) {
+	case "transfer":
+		r.Action = Transfer
+	case "conversion":
+		r.Action = Conversion
+	case "coinbase":
+		r.Action = Coinbase
+	case "burn":
+		r.Action = FCTBurn
+	case "any":
+	default:
+		return AlertRule{}, fmt.Errorf("alert rule %q: unknown action %q", rule, fields[0])
+	}
+
+	switch fields[1] {
+	case ">":
+	case ">=":
+		r.OrEqual = true
+	default:
+		return AlertRule{}, fmt.Errorf("alert rule %q: unknown comparison %q", rule, fields[1])
+	}
+
+	amount, ok := new(big.Rat).SetString(fields[2])
+	if !ok || amount.Sign() < 0 {
+		return AlertRule{}, fmt.Errorf("alert rule %q: invalid amount %q", rule, fields[2])
+	}
+	amount.Mul(amount, big.NewRat(1e8, 1))
+	if !amount.IsInt() || !amount.Num().IsInt64() {
+		return AlertRule{}, fmt.Errorf("alert rule %q: amount %q has too many decimals", rule, fields[2])
+	}
+	r.Threshold = amount.Num().Int64()
+	return r, nil
+}
+
+// Match returns the amount of the rule's asset the action moved, and false
+// if the action does not match the rule
+func (r AlertRule) Match(a HistoryTransaction) (int64, bool) {
+	if r.Action != 0 && r.Action != a.TxAction {
+		return 0, false
+	}
+	for _, moved := range []struct {
+		asset  string
+		amount int64
+	}{{a.FromAsset, a.FromAmount}, {a.ToAsset, a.ToAmount}} {
+		if moved.asset != r.Asset {
+			continue
+		}
+		if moved.amount > r.Threshold || (r.OrEqual && moved.amount == r.Threshold) {
+			return moved.amount, true
+		}
+	}
+	return 0, false
+}
+
+// Alert is an action that matched an alert rule
+type Alert struct {
+	ID       int64            `json:"id"`
+	Height   uint32           `json:"height"`
+	Rule     string           `json:"rule"`
+	Hash     factom.Bytes32   `json:"hash"`
+	TxID     string           `json:"txid"` // [TxIndex]-[BatchHash]
+	TxAction HistoryAction    `json:"txaction"`
+	Address  factom.FAAddress `json:"address"`
+	Asset    string           `json:"asset"`
+	Amount   int64            `json:"amount"`
+}
+
+// CreateTableAlerts is used to expose this table for unit tests
+func (p *Pegnet) CreateTableAlerts() error {
+	_, err := p.DB.Exec(createTableAlerts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertAlerts evaluates the rules against everything that was executed at
+// the height. Must be called after all actions of the height are recorded in
+// the history.
+func (p *Pegnet) InsertAlerts(tx *sql.Tx, height uint32, rules []AlertRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	actions, err := p.SelectTransactionHistoryActionsExecuted(tx, height)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO "pn_alerts"
+		("height", "rule", "entry_hash", "tx_index", "action_type", "address", "token", "amount")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, a := range actions {
+		for _, r := range rules {
+			amount, ok := r.Match(a)
+			if !ok {
+				continue
+			}
+			if _, err := stmt.Exec(height, r.Rule, a.Hash[:], a.TxIndex, a.TxAction, a.FromAddress[:], r.Asset, amount); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+const alertQueryFields = `"id", "height", "rule", "entry_hash", "tx_index", "action_type", "address", "token", "amount"`
+
+// SelectAlertsAt returns the alerts of the actions applied at the height
+func (p *Pegnet) SelectAlertsAt(q QueryAble, height uint32) ([]Alert, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(fmt.Sprintf(`SELECT %s FROM "pn_alerts" WHERE "height" = ? ORDER BY "id";`, alertQueryFields), height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAlerts(rows)
+}
+
+// SelectAlerts returns up to `limit` alerts of actions applied at or after
+// the height with an id greater than `after`, in the order they were
+// recorded
+func (p *Pegnet) SelectAlerts(ctx context.Context, since uint32, after int64, limit int) ([]Alert, error) {
+	if limit <= 0 || limit > AlertsLimit {
+		limit = AlertsLimit
+	}
+
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM "pn_alerts"
+		WHERE "height" >= ? AND "id" > ? ORDER BY "id" LIMIT ?;`, alertQueryFields), since, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAlerts(rows)
+}
+
+func scanAlerts(rows *sql.Rows) ([]Alert, error) {
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		var hash, adr []byte
+		var index int
+		if err := rows.Scan(&a.ID, &a.Height, &a.Rule, &hash, &index, &a.TxAction, &adr, &a.Asset, &a.Amount); err != nil {
+			return nil, err
+		}
+		copy(a.Hash[:], hash)
+		copy(a.Address[:], adr)
+		a.TxID = FormatTxID(index, a.Hash.String())
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,79 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	r, err := ParseAlertRule("transfer  > 1000000.5 pUSD")
+	require.NoError(t, err)
+	assert.Equal(t, AlertRule{Rule: "transfer > 1000000.5 pUSD", Action: Transfer, Threshold: 100000050000000, Asset: "pUSD"}, r)
+
+	r, err = ParseAlertRule("any >= 10 PEG")
+	require.NoError(t, err)
+	assert.Equal(t, HistoryAction(0), r.Action)
+	assert.True(t, r.OrEqual)
+
+	for _, bad := range []string{"transfer > 10", "mint > 10 PEG", "transfer < 10 PEG", "transfer > -1 PEG", "transfer > 0.000000001 PEG"} {
+		_, err := ParseAlertRule(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPegnet_Alerts(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableAlerts())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{
+		Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)},
+		Transactions: []fat2.Transaction{
+			{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10e8, Type: fat2.PTickerUSD},
+				Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10e8}}},
+			{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5e8, Type: fat2.PTickerUSD},
+				Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 5e8}}},
+		},
+	}
+
+	var rules []AlertRule
+	for _, rule := range []string{"transfer > 5 pUSD", "any >= 5 pUSD", "transfer > 1 PEG"} {
+		r, err := ParseAlertRule(rule)
+		require.NoError(t, err)
+		rules = append(rules, r)
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 100))
+	require.NoError(t, p.InsertAlerts(tx, 100, rules))
+	alerts, err := p.SelectAlertsAt(tx, 100)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	require.Len(t, alerts, 3)
+	assert.Equal(t, "transfer > 5 pUSD", alerts[0].Rule)
+	assert.Equal(t, int64(10e8), alerts[0].Amount)
+	assert.Equal(t, a, alerts[0].Address)
+	assert.Equal(t, "any >= 5 pUSD", alerts[2].Rule)
+	assert.Equal(t, int64(5e8), alerts[2].Amount)
+
+	page, err := p.SelectAlerts(context.Background(), 0, alerts[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, alerts[1], page[0])
+}
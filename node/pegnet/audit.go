@@ -0,0 +1,147 @@
+package pegnet
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableSendAudit is a SQL string that creates the table of every
+// send-transaction attempt. The triggers keep the table append-only.
+const createTableSendAudit = `CREATE TABLE IF NOT EXISTS "pn_send_audit" (
+	"id"			INTEGER PRIMARY KEY,
+	"time"			INTEGER NOT NULL, -- unix seconds
+	"caller"		TEXT NOT NULL, -- remote address of the request
+	"apikey"		TEXT NOT NULL DEFAULT '', -- fingerprint of the api key, if any
+	"entry_hash"	BLOB,
+	"dry_run"		BOOLEAN NOT NULL,
+	"ec_cost"		INTEGER NOT NULL DEFAULT 0,
+	"outcome"		TEXT NOT NULL,
+	"error"			TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS "idx_send_audit_time" ON "pn_send_audit"("time");
+CREATE TRIGGER IF NOT EXISTS "trg_send_audit_update" BEFORE UPDATE ON "pn_send_audit"
+BEGIN
+	SELECT RAISE(ABORT, 'pn_send_audit is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS "trg_send_audit_delete" BEFORE DELETE ON "pn_send_audit"
+BEGIN
+	SELECT RAISE(ABORT, 'pn_send_audit is append-only');
+END;
+`
+
+// AuditLimit is the maximum amount of audit entries that can be queried at
+// once
+const AuditLimit = 1000
+
+// The outcomes of a send-transaction attempt
+const (
+	AuditSubmitted = "submitted"
+	AuditDryRun    = "dryrun"
+	AuditRejected  = "rejected"
+	AuditFailed    = "failed"
+	AuditDenied    = "denied"
+)
+
+// SendAudit is a single send-transaction attempt
+type SendAudit struct {
+	ID        int64           `json:"id"`
+	Time      time.Time       `json:"time"`
+	Caller    string          `json:"caller"`
+	APIKey    string          `json:"apikey,omitempty"`
+	EntryHash *factom.Bytes32 `json:"entryhash,omitempty"`
+	DryRun    bool            `json:"dryrun"`
+	ECCost    uint8           `json:"eccost"`
+	Outcome   string          `json:"outcome"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// CreateTableSendAudit is used to expose this table for unit tests
+func (p *Pegnet) CreateTableSendAudit() error {
+	_, err := p.DB.Exec(createTableSendAudit)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertSendAudit appends the attempt to the audit log and returns its id
+func (p *Pegnet) InsertSendAudit(q QueryAble, a SendAudit) (int64, error) {
+	if q == nil {
+		q = p.DB
+	}
+	var hash []byte
+	if a.EntryHash != nil {
+		hash = a.EntryHash[:]
+	}
+	private, err := p.sealAll("pn_send_audit", []string{"caller", "apikey", "entry_hash", "error"}, a.Caller, a.APIKey, hash, a.Error)
+	if err != nil {
+		return -1, err
+	}
+	res, err := q.Exec(`INSERT INTO "pn_send_audit"
+		("time", "caller", "apikey", "entry_hash", "dry_run", "ec_cost", "outcome", "error") VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?);`,
+		a.Time.Unix(), private[0], private[1], private[2], a.DryRun, a.ECCost, a.Outcome, private[3])
+	if err != nil {
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// SelectSendAudit returns up to limit attempts, newest first, and the total
+// amount of attempts. Only attempts at or after since are included.
+func (p *Pegnet) SelectSendAudit(q QueryAble, since time.Time, offset, limit int) ([]SendAudit, int, error) {
+	if q == nil {
+		q = p.DB
+	}
+	var total int
+	err := q.QueryRow(`SELECT COUNT(*) FROM "pn_send_audit" WHERE "time" >= ?;`, since.Unix()).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := q.Query(`SELECT "id", "time", "caller", "apikey", "entry_hash", "dry_run", "ec_cost", "outcome", "error"
+		FROM "pn_send_audit" WHERE "time" >= ? ORDER BY "id" DESC LIMIT ? OFFSET ?;`,
+		since.Unix(), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := p.scanSendAudit(rows)
+	return entries, total, err
+}
+
+// SelectSendAuditCost returns the average EC cost of the entries submitted
+// at or after since, and how many were submitted
+func (p *Pegnet) SelectSendAuditCost(q QueryAble, since time.Time) (float64, int, error) {
+	if q == nil {
+		q = p.DB
+	}
+	var avg float64
+	var count int
+	err := q.QueryRow(`SELECT IFNULL(AVG("ec_cost"), 0), COUNT(*) FROM "pn_send_audit"
+		WHERE "outcome" = ? AND "time" >= ?;`, AuditSubmitted, since.Unix()).Scan(&avg, &count)
+	return avg, count, err
+}
+
+func (p *Pegnet) scanSendAudit(rows *sql.Rows) ([]SendAudit, error) {
+	defer rows.Close()
+
+	var entries []SendAudit
+	for rows.Next() {
+		var a SendAudit
+		var ts int64
+		var hash []byte
+		if err := rows.Scan(&a.ID, &ts, p.sealed("pn_send_audit.caller", &a.Caller), p.sealed("pn_send_audit.apikey", &a.APIKey),
+			p.sealed("pn_send_audit.entry_hash", &hash), &a.DryRun, &a.ECCost, &a.Outcome, p.sealed("pn_send_audit.error", &a.Error)); err != nil {
+			return nil, err
+		}
+		a.Time = time.Unix(ts, 0)
+		if len(hash) > 0 {
+			a.EntryHash = new(factom.Bytes32)
+			copy(a.EntryHash[:], hash)
+		}
+		entries = append(entries, a)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,58 @@
+package pegnet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SendAudit(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableSendAudit())
+
+	start := time.Unix(1600000000, 0)
+	hash := factom.Bytes32{1}
+	_, err = p.InsertSendAudit(nil, SendAudit{Time: start, Caller: "10.0.0.1", EntryHash: &hash, DryRun: true, ECCost: 1, Outcome: AuditDryRun})
+	require.NoError(t, err)
+	id, err := p.InsertSendAudit(nil, SendAudit{Time: start.Add(time.Minute), Caller: "10.0.0.2", APIKey: "abcd", Outcome: AuditRejected, Error: "No Entry Credits"})
+	require.NoError(t, err)
+
+	entries, total, err := p.SelectSendAudit(nil, time.Unix(0, 0), 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, entries, 2)
+	assert.Equal(t, id, entries[0].ID, "newest first")
+	assert.Equal(t, "abcd", entries[0].APIKey)
+	assert.Nil(t, entries[0].EntryHash)
+	assert.Equal(t, hash, *entries[1].EntryHash)
+	assert.True(t, entries[1].DryRun)
+	assert.Equal(t, uint8(1), entries[1].ECCost)
+
+	entries, total, err = p.SelectSendAudit(nil, start.Add(time.Second), 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, AuditRejected, entries[0].Outcome)
+
+	// Only the submitted entries count towards the cost
+	_, err = p.InsertSendAudit(nil, SendAudit{Time: start, Caller: "10.0.0.1", EntryHash: &hash, ECCost: 1, Outcome: AuditSubmitted})
+	require.NoError(t, err)
+	_, err = p.InsertSendAudit(nil, SendAudit{Time: start, Caller: "10.0.0.1", EntryHash: &hash, ECCost: 2, Outcome: AuditSubmitted})
+	require.NoError(t, err)
+	avg, count, err := p.SelectSendAuditCost(nil, time.Unix(0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 1.5, avg)
+
+	// The log can't be changed
+	_, err = p.DB.Exec(`UPDATE "pn_send_audit" SET "outcome" = 'submitted';`)
+	assert.Error(t, err)
+	_, err = p.DB.Exec(`DELETE FROM "pn_send_audit";`)
+	assert.Error(t, err)
+}
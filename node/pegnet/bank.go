@@ -58,6 +58,24 @@ func (p *Pegnet) CreateTableBank() error {
 	return nil
 }
 
+// Remaining is the amount of PEG of the bank that was not consumed. Returns
+// the full bank if the usage was not recorded.
+func (e BankEntry) Remaining() int64 {
+	if e.BankUsed < 0 {
+		return e.BankAmount
+	}
+	return e.BankAmount - e.BankUsed
+}
+
+// Proration is the fraction of the requested PEG that was paid out. It is 1
+// if the requests fit into the bank and conversions were not prorated.
+func (e BankEntry) Proration() float64 {
+	if e.PEGRequested <= 0 || e.PEGRequested <= e.BankAmount {
+		return 1
+	}
+	return float64(e.BankUsed) / float64(e.PEGRequested)
+}
+
 func (p Pegnet) SelectBankEntry(q QueryAble, height int32) (entry BankEntry, err error) {
 	if q == nil {
 		q = p.DB // nil defaults to db
@@ -58,3 +58,22 @@ func TestPegnet_BankTable(t *testing.T) {
 		}
 	})
 }
+
+func TestBankEntry_Proration(t *testing.T) {
+	for _, c := range []struct {
+		entry     pegnet.BankEntry
+		remaining int64
+		proration float64
+	}{
+		{pegnet.BankEntry{BankAmount: 5000, BankUsed: -1, PEGRequested: -1}, 5000, 1},
+		{pegnet.BankEntry{BankAmount: 5000, BankUsed: 2000, PEGRequested: 2000}, 3000, 1},
+		{pegnet.BankEntry{BankAmount: 5000, BankUsed: 5000, PEGRequested: 20000}, 0, 0.25},
+	} {
+		if r := c.entry.Remaining(); r != c.remaining {
+			t.Errorf("expected %d remaining, got %d", c.remaining, r)
+		}
+		if p := c.entry.Proration(); p != c.proration {
+			t.Errorf("expected %f proration, got %f", c.proration, p)
+		}
+	}
+}
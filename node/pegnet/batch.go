@@ -0,0 +1,133 @@
+package pegnet
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxBatchVariables is the most bound parameters of a single statement.
+// SQLite builds before 3.32 are limited to 999.
+const maxBatchVariables = 999
+
+// rowBatch collects the rows of a multi-row INSERT, so the rows of a block
+// are written with a few statements instead of one per row
+type rowBatch struct {
+	insert string // the statement up to and including VALUES
+	suffix string // the clause after the rows, eg an ON CONFLICT clause
+	cols   int
+	args   []interface{}
+}
+
+func newRowBatch(insert string, cols int, suffix string) *rowBatch {
+	return &rowBatch{insert: insert, cols: cols, suffix: suffix}
+}
+
+// add appends a row, the values must match the columns of the insert
+func (b *rowBatch) add(values ...interface{}) {
+	b.args = append(b.args, values...)
+}
+
+// exec writes all rows added so far, with as many rows per statement as the
+// parameter limit allows
+func (b *rowBatch) exec(p *Pegnet, tx *sql.Tx) error {
+	perStatement := maxBatchVariables / b.cols
+	row := "(?" + strings.Repeat(", ?", b.cols-1) + ")"
+	for len(b.args) > 0 {
+		rows := len(b.args) / b.cols
+		if rows > perStatement {
+			rows = perStatement
+		}
+		query := b.insert + " " + row + strings.Repeat(", "+row, rows-1) + " " + b.suffix
+		args := b.args[:rows*b.cols]
+		if rows < perStatement {
+			if _, err := tx.Exec(query, args...); err != nil {
+				return err
+			}
+		} else {
+			// full statements have the same text every time and are worth
+			// caching
+			stmt, err := p.prepare(tx, query)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.Exec(args...); err != nil {
+				return err
+			}
+		}
+		b.args = b.args[rows*b.cols:]
+	}
+	return nil
+}
+
+// caseUpdate collects the rows of an UPDATE that sets a value per key, so the
+// rows are updated with a few statements instead of one per row
+type caseUpdate struct {
+	update    string   // the statement up to and including SET
+	sets      []string // the assignments, each with a %s for the CASE expression
+	key       string   // the column that selects the row
+	where     string   // an additional condition, eg on another key column
+	whereArgs []interface{}
+	keys      []interface{}
+	values    []interface{}
+}
+
+// add appends a row, there must be a value for each assignment and a key
+// must not be added twice
+func (u *caseUpdate) add(key interface{}, values ...interface{}) {
+	u.keys = append(u.keys, key)
+	u.values = append(u.values, values...)
+}
+
+// exec updates all rows added so far and returns the number of rows that
+// were updated
+func (u *caseUpdate) exec(p *Pegnet, tx *sql.Tx) (int64, error) {
+	perStatement := (maxBatchVariables - len(u.whereArgs)) / (2*len(u.sets) + 1)
+	var updated int64
+	for len(u.keys) > 0 {
+		rows := len(u.keys)
+		if rows > perStatement {
+			rows = perStatement
+		}
+
+		var args []interface{}
+		sets := make([]string, len(u.sets))
+		expr := `CASE "` + u.key + `"` + strings.Repeat(" WHEN ? THEN ?", rows) + " END"
+		for i, set := range u.sets {
+			sets[i] = fmt.Sprintf(set, expr)
+			for r := 0; r < rows; r++ {
+				args = append(args, u.keys[r], u.values[r*len(u.sets)+i])
+			}
+		}
+		query := u.update + " " + strings.Join(sets, ", ") + " WHERE "
+		if u.where != "" {
+			query += u.where + " AND "
+			args = append(args, u.whereArgs...)
+		}
+		query += `"` + u.key + `" IN (?` + strings.Repeat(", ?", rows-1) + ");"
+		args = append(args, u.keys[:rows]...)
+
+		var res sql.Result
+		var err error
+		if rows < perStatement {
+			res, err = tx.Exec(query, args...)
+		} else {
+			var stmt *sql.Stmt
+			if stmt, err = p.prepare(tx, query); err == nil {
+				res, err = stmt.Exec(args...)
+			}
+		}
+		if err != nil {
+			return updated, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return updated, err
+		}
+		updated += n
+
+		u.keys = u.keys[rows:]
+		u.values = u.values[rows*len(u.sets):]
+	}
+	return updated, nil
+}
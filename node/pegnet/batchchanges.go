@@ -0,0 +1,184 @@
+package pegnet
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// BatchChanges collects the changes of a transaction batch that is being
+// applied, so they are written with a few multi-row statements by
+// ApplyBatchChanges instead of one statement per row.
+//
+// The changes of a batch must be applied before the balances of the next
+// batch are checked.
+type BatchChanges struct {
+	Batch  *fat2.TransactionBatch
+	Height uint32
+
+	order   []balanceKey
+	seen    map[balanceKey]bool
+	credits map[balanceKey]uint64
+	debits  map[balanceKey]uint64
+
+	relations  []transactionRelation
+	converted  []convertedAmount
+	activities []factom.FAAddress
+	volumes    []AddressVolumeChange
+}
+
+type balanceKey struct {
+	adr    factom.FAAddress
+	ticker fat2.PTicker
+}
+
+type transactionRelation struct {
+	adr        factom.FAAddress
+	txIndex    int
+	to         bool
+	conversion bool
+}
+
+type convertedAmount struct {
+	txIndex          int
+	amount           int64
+	fromRate, toRate uint64
+}
+
+// NewBatchChanges returns an empty set of changes for the batch, executed at
+// the height
+func NewBatchChanges(batch *fat2.TransactionBatch, height uint32) *BatchChanges {
+	return &BatchChanges{
+		Batch:   batch,
+		Height:  height,
+		seen:    make(map[balanceKey]bool),
+		credits: make(map[balanceKey]uint64),
+		debits:  make(map[balanceKey]uint64),
+	}
+}
+
+func (c *BatchChanges) key(adr *factom.FAAddress, ticker fat2.PTicker) balanceKey {
+	k := balanceKey{adr: *adr, ticker: ticker}
+	if !c.seen[k] {
+		c.seen[k] = true
+		c.order = append(c.order, k)
+	}
+	return k
+}
+
+// Credit adds value to the typed balance of adr. The row of the address is
+// created even if value is 0.
+func (c *BatchChanges) Credit(adr *factom.FAAddress, ticker fat2.PTicker, value uint64) {
+	c.credits[c.key(adr, ticker)] += value
+}
+
+// Debit subtracts value from the typed balance of adr. Like SubFromBalance,
+// a debit of 0 creates the row of the address.
+func (c *BatchChanges) Debit(adr *factom.FAAddress, ticker fat2.PTicker, value uint64) {
+	if value == 0 {
+		c.Credit(adr, ticker, 0)
+		return
+	}
+	c.debits[c.key(adr, ticker)] += value
+}
+
+// Relate relates the address to the transaction of the batch, see
+// InsertTransactionRelation
+func (c *BatchChanges) Relate(adr factom.FAAddress, txIndex int, to bool, isConversion bool) {
+	c.relations = append(c.relations, transactionRelation{adr: adr, txIndex: txIndex, to: to, conversion: isConversion})
+}
+
+// Converted records the output of a conversion and the rates used to
+// calculate it, see SetTransactionHistoryConvertedAmount
+func (c *BatchChanges) Converted(txIndex int, amount int64, fromRate, toRate uint64) {
+	c.converted = append(c.converted, convertedAmount{txIndex: txIndex, amount: amount, fromRate: fromRate, toRate: toRate})
+}
+
+// Activity counts a transaction for the address, see AddAddressActivity
+func (c *BatchChanges) Activity(adr *factom.FAAddress) {
+	c.activities = append(c.activities, *adr)
+}
+
+// Volume adds the amount to a volume aggregate of the address, see
+// AddAddressVolume
+func (c *BatchChanges) Volume(adr *factom.FAAddress, ticker fat2.PTicker, volume AddressVolume, amount int64) {
+	c.volumes = append(c.volumes, AddressVolumeChange{Address: *adr, Ticker: ticker, Volume: volume, Amount: amount})
+}
+
+// ApplyBatchChanges writes the changes and marks the batch as executed.
+//
+// The credits of an asset are written before its debits. As the balances of
+// the batch were checked as a whole, with earlier outputs funding later
+// inputs, no balance is taken below 0 on the way. If a debit has no row to
+// subtract from, InsufficientBalanceErr is returned.
+func (p *Pegnet) ApplyBatchChanges(tx *sql.Tx, c *BatchChanges) error {
+	for ticker := fat2.PTickerInvalid + 1; ticker < fat2.PTickerMax; ticker++ {
+		var adrs []factom.FAAddress
+		var values []uint64
+		col := balanceColumn(ticker)
+		debits := &caseUpdate{
+			update: `UPDATE "pn_addresses" SET`,
+			sets:   []string{fmt.Sprintf(`"%[1]s" = "%[1]s" - %%s`, col)},
+			key:    "address",
+		}
+		for i := range c.order {
+			k := &c.order[i]
+			if k.ticker != ticker {
+				continue
+			}
+			if value, ok := c.credits[*k]; ok {
+				adrs = append(adrs, k.adr)
+				values = append(values, value)
+			}
+			if value, ok := c.debits[*k]; ok {
+				debits.add(k.adr[:], value)
+			}
+		}
+		if err := p.AddToBalances(tx, adrs, ticker, values); err != nil {
+			return err
+		}
+		expected := int64(len(debits.keys))
+		if updated, err := debits.exec(p, tx); err != nil {
+			return err
+		} else if updated != expected {
+			return InsufficientBalanceErr
+		}
+	}
+
+	if err := p.SetTransactionHistoryExecuted(tx, c.Batch, int64(c.Height)); err != nil {
+		return err
+	}
+
+	// If an address is the sender and the receiver, then only the first
+	// relation is recorded, see InsertTransactionRelation
+	relations := newRowBatch(`INSERT INTO "pn_address_transactions"
+                ("entry_hash", "address", "tx_index", "to", "conversion") VALUES`, 5, "ON CONFLICT DO NOTHING")
+	for i := range c.relations {
+		r := &c.relations[i]
+		relations.add(c.Batch.Entry.Hash[:], r.adr[:], r.txIndex, r.to || r.conversion, r.conversion)
+	}
+	if err := relations.exec(p, tx); err != nil {
+		return err
+	}
+
+	converted := &caseUpdate{
+		update:    `UPDATE "pn_history_transaction" SET`,
+		sets:      []string{`"to_amount" = %s`, `"from_rate" = %s`, `"to_rate" = %s`},
+		key:       "tx_index",
+		where:     `"entry_hash" = ?`,
+		whereArgs: []interface{}{c.Batch.Entry.Hash[:]},
+	}
+	for _, conv := range c.converted {
+		converted.add(conv.txIndex, conv.amount, conv.fromRate, conv.toRate)
+	}
+	if _, err := converted.exec(p, tx); err != nil {
+		return err
+	}
+
+	if err := p.AddAddressActivities(tx, c.activities, c.Height); err != nil {
+		return err
+	}
+	return p.AddAddressVolumes(tx, c.volumes)
+}
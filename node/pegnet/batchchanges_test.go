@@ -0,0 +1,114 @@
+package pegnet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_ApplyBatchChanges(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTables())
+
+	var a, b, c factom.FAAddress
+	a[0], b[0], c[0] = 1, 2, 3
+	var hash factom.Bytes32
+	hash[0] = 1
+	// a spends the PEG it gets back from b later in the batch
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 8, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 8}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+		{Input: fat2.TypedAddressAmountTuple{Address: c, Amount: 0, Type: fat2.PTickerEUR},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 0}}},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &a, fat2.PTickerPEG, 10)
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+
+	changes := NewBatchChanges(batch, 101)
+	for i, ttx := range batch.Transactions {
+		changes.Debit(&ttx.Input.Address, ttx.Input.Type, ttx.Input.Amount)
+		changes.Relate(ttx.Input.Address, i, false, ttx.IsConversion())
+		changes.Activity(&ttx.Input.Address)
+		if ttx.IsConversion() {
+			changes.Converted(i, 7, 2, 3)
+			changes.Credit(&ttx.Input.Address, ttx.Conversion, 7)
+			changes.Volume(&ttx.Input.Address, ttx.Conversion, ConvertedIn, 7)
+			continue
+		}
+		for _, transfer := range ttx.Transfers {
+			changes.Credit(&transfer.Address, ttx.Input.Type, transfer.Amount)
+			changes.Relate(transfer.Address, i, true, false)
+			changes.Volume(&transfer.Address, ttx.Input.Type, TransferIn, int64(transfer.Amount))
+		}
+	}
+	require.NoError(t, p.ApplyBatchChanges(tx, changes))
+	require.NoError(t, tx.Commit())
+
+	balances, err := p.SelectBalances(&a)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), balances[fat2.PTickerPEG])
+	assert.Equal(t, uint64(7), balances[fat2.PTickerUSD])
+	balances, err = p.SelectBalances(&b)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), balances[fat2.PTickerPEG])
+	// the zero transfer creates the row of its sender
+	_, err = p.SelectBalances(&c)
+	require.NoError(t, err)
+
+	_, executed, err := p.SelectTransactionHistoryStatus(&hash)
+	require.NoError(t, err)
+	assert.Equal(t, int32(101), executed)
+	actions, _, err := p.SelectTransactionHistoryActionsByHash(&hash, HistoryQueryOptions{})
+	require.NoError(t, err)
+	require.Len(t, actions, 4)
+	assert.Equal(t, int64(7), actions[2].ToAmount)
+	assert.Equal(t, int64(0), actions[0].ToAmount)
+
+	isReplay, err := func() (bool, error) {
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		return p.IsReplayTransaction(tx, &hash)
+	}()
+	require.NoError(t, err)
+	assert.True(t, isReplay)
+	var relations int
+	require.NoError(t, p.DB.QueryRow(`SELECT COUNT(*) FROM "pn_address_transactions";`).Scan(&relations))
+	assert.Equal(t, 3, relations) // an address is related once to a batch
+
+	stats, err := p.SelectAddressStats(&a)
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(2), stats.TxCount)
+	assert.Equal(t, AddressAssetStats{TransferIn: 8}, stats.Assets[fat2.PTickerPEG])
+	assert.Equal(t, AddressAssetStats{ConvertedIn: 7}, stats.Assets[fat2.PTickerUSD])
+
+	// A debit without a row or beyond the balance is not applied
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	var d factom.FAAddress
+	d[0] = 4
+	changes = NewBatchChanges(batch, 102)
+	changes.Debit(&d, fat2.PTickerPEG, 1)
+	assert.Equal(t, InsufficientBalanceErr, p.ApplyBatchChanges(tx, changes))
+	changes = NewBatchChanges(batch, 102)
+	changes.Debit(&b, fat2.PTickerPEG, 3)
+	assert.Error(t, p.ApplyBatchChanges(tx, changes))
+}
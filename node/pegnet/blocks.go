@@ -0,0 +1,215 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableBlocks is a SQL string that creates the "pn_blocks" table. The
+// table holds a summary of every synced height, so a range of blocks can be
+// listed without going through their transactions. Databases synced before
+// the table existed only have the blocks since the upgrade. The state hashes
+// are chained from the first height of the pegnet, only databases synced
+// from there have them.
+const createTableBlocks = `CREATE TABLE IF NOT EXISTS "pn_blocks" (
+	"height"		INTEGER PRIMARY KEY,
+	"timestamp"		INTEGER NOT NULL, -- of the dblock
+	"transactions"	INTEGER NOT NULL DEFAULT 0, -- all executed actions
+	"transfers"		INTEGER NOT NULL DEFAULT 0,
+	"conversions"	INTEGER NOT NULL DEFAULT 0,
+	"coinbases"		INTEGER NOT NULL DEFAULT 0,
+	"burns"			INTEGER NOT NULL DEFAULT 0,
+	"volume"		INTEGER NOT NULL DEFAULT 0, -- pUSD value of the transfers and conversions
+	"rates"			INTEGER NOT NULL DEFAULT 0, -- 1 if the height has rates
+	"state_hash"	BLOB -- null if the previous height has none
+);
+`
+
+// BlocksLimit is the maximum amount of blocks that can be queried at once
+const BlocksLimit = 1000
+
+// Block is the summary of a synced height. The counts are of the actions
+// executed at the height.
+type Block struct {
+	Height       uint32    `json:"height"`
+	Timestamp    time.Time `json:"timestamp"`
+	Transactions int64     `json:"transactions"`
+	Transfers    int64     `json:"transfers"`
+	Conversions  int64     `json:"conversions"`
+	Coinbases    int64     `json:"coinbases"`
+	Burns        int64     `json:"burns"`
+	// Volume is the pUSD value of the transfers and the inputs of the
+	// conversions at the rates of the height, 0 if it has no rates
+	Volume uint64 `json:"volume"`
+	Rates  bool   `json:"rates"`
+	// StateHash is the state hash of the height, see SelectStateHash
+	StateHash *factom.Bytes32 `json:"statehash,omitempty"`
+}
+
+// CreateTableBlocks is used to expose this table for unit tests
+func (p *Pegnet) CreateTableBlocks() error {
+	_, err := p.DB.Exec(createTableBlocks)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertBlock records the summary of the height. Must be called after all
+// actions of the height are recorded in the history.
+func (p *Pegnet) InsertBlock(tx *sql.Tx, height uint32, timestamp time.Time) error {
+	b := Block{Height: height, Timestamp: timestamp}
+	err := tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*),
+			IFNULL(SUM(tx.action_type = %[1]d), 0),
+			IFNULL(SUM(tx.action_type = %[2]d), 0),
+			IFNULL(SUM(tx.action_type = %[3]d), 0),
+			IFNULL(SUM(tx.action_type = %[4]d), 0)
+		FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ?;`,
+		Transfer, Conversion, Coinbase, FCTBurn), height).
+		Scan(&b.Transactions, &b.Transfers, &b.Conversions, &b.Coinbases, &b.Burns)
+	if err != nil {
+		return err
+	}
+
+	rates, err := p.SelectPendingRates(nil, tx, height)
+	if err != nil {
+		return err
+	}
+	b.Rates = len(rates) > 0
+	if b.Rates && b.Transfers+b.Conversions > 0 {
+		if b.Volume, err = blockVolume(tx, height, rates); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO "pn_blocks"
+		("height", "timestamp", "transactions", "transfers", "conversions", "coinbases", "burns", "volume", "rates")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		b.Height, b.Timestamp.Unix(), b.Transactions, b.Transfers, b.Conversions, b.Coinbases, b.Burns, b.Volume, b.Rates)
+	return err
+}
+
+// InsertBlockStateHash records the state hash of the height, chained to the
+// hash of the previous height. The chain starts at the first height of the
+// pegnet, a height without a previous hash has none. Must be called after
+// the balance journal of the height is finalized.
+func (p *Pegnet) InsertBlockStateHash(tx *sql.Tx, height uint32, first bool) error {
+	var prev factom.Bytes32
+	if !first {
+		var data []byte
+		err := tx.QueryRow(`SELECT "state_hash" FROM "pn_blocks" WHERE "height" = ?;`, height-1).Scan(&data)
+		if err == sql.ErrNoRows || err == nil && data == nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		copy(prev[:], data)
+	}
+
+	hash, err := p.SelectStateHash(tx, height, prev)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE "pn_blocks" SET "state_hash" = ? WHERE "height" = ?;`, hash[:], height)
+	return err
+}
+
+// blockVolume sums the transfers and conversion inputs executed at the
+// height in pUSD. Assets without a rate are not included.
+func blockVolume(tx *sql.Tx, height uint32, rates map[fat2.PTicker]uint64) (uint64, error) {
+	rows, err := tx.Query(`SELECT tx.from_asset, SUM(tx.from_amount)
+		FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ? AND tx.action_type IN (?, ?)
+		GROUP BY tx.from_asset;`, height, Transfer, Conversion)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var volume uint64
+	for rows.Next() {
+		var token string
+		var amount int64
+		if err := rows.Scan(&token, &amount); err != nil {
+			return 0, err
+		}
+		ticker := fat2.StringToTicker(token)
+		if rates[ticker] == 0 || rates[fat2.PTickerUSD] == 0 {
+			continue
+		}
+		value, err := conversions.Convert(amount, rates[ticker], rates[fat2.PTickerUSD])
+		if err != nil {
+			continue // an overflow does not stop the sync
+		}
+		volume += uint64(value)
+	}
+	return volume, rows.Err()
+}
+
+// SelectBlocks returns the summaries of the synced heights in the range
+// [start, stop], ordered by height. Heights that were synced before the
+// blocks were recorded are not returned.
+func (p *Pegnet) SelectBlocks(ctx context.Context, start, stop uint32, desc bool) ([]Block, error) {
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if stop-start >= BlocksLimit {
+		return nil, fmt.Errorf("range is limited to %d blocks", BlocksLimit)
+	}
+
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s
+		FROM "pn_blocks" WHERE "height" >= ? AND "height" <= ? ORDER BY "height" %s;`, blockFields, order), start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Block{}
+	for rows.Next() {
+		b, err := scanBlock(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *b)
+	}
+	return res, rows.Err()
+}
+
+// SelectBlock returns the summary of the height, nil if it was not recorded
+func (p *Pegnet) SelectBlock(ctx context.Context, height uint32) (*Block, error) {
+	b, err := scanBlock(p.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT %s FROM "pn_blocks" WHERE "height" = ?;`, blockFields), height))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+const blockFields = `"height", "timestamp", "transactions", "transfers", "conversions", "coinbases", "burns", "volume", "rates", "state_hash"`
+
+func scanBlock(row interface{ Scan(...interface{}) error }) (*Block, error) {
+	b := new(Block)
+	var timestamp int64
+	var hash []byte
+	if err := row.Scan(&b.Height, &timestamp, &b.Transactions, &b.Transfers, &b.Conversions,
+		&b.Coinbases, &b.Burns, &b.Volume, &b.Rates, &hash); err != nil {
+		return nil, err
+	}
+	b.Timestamp = time.Unix(timestamp, 0).UTC()
+	if hash != nil {
+		b.StateHash = new(factom.Bytes32)
+		copy(b.StateHash[:], hash)
+	}
+	return b, nil
+}
@@ -0,0 +1,126 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_Blocks(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableGrade())
+	require.NoError(t, p.CreateTableBlocks())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{
+		Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)},
+		Transactions: []fat2.Transaction{
+			{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 1e8, Type: fat2.PTickerPEG},
+				Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 1e8}}},
+			{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 2e8, Type: fat2.PTickerUSD},
+				Conversion: fat2.PTickerEUR},
+		},
+	}
+
+	// 100 has the batch and rates, 101 has nothing
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InjectRates(tx, 100, map[fat2.PTicker]uint64{fat2.PTickerPEG: 5e7, fat2.PTickerUSD: 1e8, fat2.PTickerEUR: 11e7}))
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 100))
+	require.NoError(t, p.InsertBlock(tx, 100, time.Unix(1000, 0)))
+	require.NoError(t, p.InsertBlock(tx, 101, time.Unix(1600, 0)))
+	require.NoError(t, tx.Commit())
+
+	blocks, err := p.SelectBlocks(context.Background(), 0, 200, false)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, Block{
+		Height:       100,
+		Timestamp:    time.Unix(1000, 0).UTC(),
+		Transactions: 2,
+		Transfers:    1,
+		Conversions:  1,
+		Volume:       25e7, // 1 PEG at $0.5 and 2 pUSD
+		Rates:        true,
+	}, blocks[0])
+	assert.Equal(t, Block{Height: 101, Timestamp: time.Unix(1600, 0).UTC()}, blocks[1])
+
+	blocks, err = p.SelectBlocks(context.Background(), 100, 101, true)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, uint32(101), blocks[0].Height)
+
+	blocks, err = p.SelectBlocks(context.Background(), 102, 110, false)
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+
+	_, err = p.SelectBlocks(context.Background(), 101, 100, false)
+	assert.Error(t, err)
+	_, err = p.SelectBlocks(context.Background(), 0, BlocksLimit, false)
+	assert.Error(t, err)
+}
+
+func TestPegnet_BlockStateHash(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTables())
+
+	// 10 is the first height, 12 follows a height that was not recorded
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for _, height := range []uint32{10, 11, 13} {
+		require.NoError(t, p.InsertBlock(tx, height, time.Unix(int64(height), 0)))
+		require.NoError(t, p.InsertBlockStateHash(tx, height, height == 10))
+	}
+	require.NoError(t, p.InsertTransactionRejection(tx, &factom.Bytes32{2}, 11, ReplayErrorInt, "replay"))
+	require.NoError(t, p.InsertTransactionRejection(tx, &factom.Bytes32{1}, 11, ReplayErrorInt, "replay"))
+	require.NoError(t, tx.Commit())
+
+	first, err := p.SelectStateHash(nil, 10, factom.Bytes32{})
+	require.NoError(t, err)
+	second, err := p.SelectStateHash(nil, 11, first)
+	require.NoError(t, err)
+
+	block, err := p.SelectBlock(context.Background(), 10)
+	require.NoError(t, err)
+	require.NotNil(t, block.StateHash)
+	assert.Equal(t, first, *block.StateHash)
+
+	// The hash of 11 is recorded before its rejections, the state hash
+	// commits to everything of the height once it is synced
+	block, err = p.SelectBlock(context.Background(), 11)
+	require.NoError(t, err)
+	require.NotNil(t, block.StateHash)
+	assert.NotEqual(t, second, *block.StateHash)
+
+	block, err = p.SelectBlock(context.Background(), 13)
+	require.NoError(t, err)
+	assert.Nil(t, block.StateHash)
+
+	block, err = p.SelectBlock(context.Background(), 12)
+	require.NoError(t, err)
+	assert.Nil(t, block)
+
+	rejected, err := p.SelectTransactionRejectionsAt(context.Background(), 11)
+	require.NoError(t, err)
+	require.Len(t, rejected, 2)
+	assert.Equal(t, factom.Bytes32{1}, *rejected[0].EntryHash)
+	assert.Equal(t, "replay", rejected[0].Reason)
+	rejected, err = p.SelectTransactionRejectionsAt(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+}
@@ -0,0 +1,72 @@
+package pegnet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Burn is an FCT -> pFCT burn as recorded in the history
+type Burn struct {
+	TxID      factom.Bytes32   `json:"txid"` // the factoid transaction id
+	Height    uint32           `json:"height"`
+	Timestamp time.Time        `json:"timestamp"`
+	Address   factom.FAAddress `json:"address"`
+	Burned    int64            `json:"burned"`   // FCT
+	Credited  int64            `json:"credited"` // pFCT
+}
+
+// SelectBurns returns the burns in the height range [start, stop], ordered
+// by height. If adr is not nil, only the burns of that address are returned.
+// At most QueryLimit burns are returned, starting at the offset. The total
+// number of burns that match is returned as well.
+func (p *Pegnet) SelectBurns(ctx context.Context, adr *factom.FAAddress, start, stop uint32, offset int) ([]Burn, int, error) {
+	if stop < start {
+		return nil, 0, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset")
+	}
+
+	from := "pn_history_txbatch batch, pn_history_transaction tx"
+	where := "batch.entry_hash = tx.entry_hash AND tx.action_type = ? AND batch.height >= ? AND batch.height <= ?"
+	args := []interface{}{FCTBurn, start, stop}
+	if adr != nil {
+		from += ", pn_history_lookup lookup"
+		where += " AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND lookup.address = ?"
+		args = append(args, adr[:])
+	}
+
+	var count int
+	err := p.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, where), args...).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT batch.entry_hash, batch.height, batch.timestamp, tx.from_address, tx.from_amount, tx.to_amount
+		FROM %s WHERE %s ORDER BY batch.history_id ASC LIMIT %d OFFSET %d`, from, where, QueryLimit, offset), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var burns []Burn
+	for rows.Next() {
+		var b Burn
+		var hash, from []byte
+		var ts int64
+		if err := rows.Scan(&hash, &b.Height, &ts, &from, &b.Burned, &b.Credited); err != nil {
+			return nil, 0, err
+		}
+		copy(b.TxID[:], hash)
+		copy(b.Address[:], from)
+		b.Timestamp = time.Unix(ts, 0)
+		burns = append(burns, b)
+	}
+	return burns, count, rows.Err()
+}
@@ -0,0 +1,59 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectBurns(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.Bytes32
+	a[0], b[0] = 1, 2
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		var id factom.Bytes32
+		id[0] = byte(i + 1)
+		burn := factom.FactoidTransaction{
+			FactoidTransactionHeader: factom.FactoidTransactionHeader{TransactionID: &id, TimestampSalt: time.Now()},
+			FCTInputs:                []factom.FactoidTransactionIO{{Amount: uint64(100 * (i + 1)), Address: a}},
+		}
+		if i == 2 {
+			burn.FCTInputs[0].Address = b
+		}
+		require.NoError(t, p.InsertFCTBurn(tx, &id, burn, uint32(10+i)))
+	}
+	require.NoError(t, tx.Commit())
+
+	burns, count, err := p.SelectBurns(context.Background(), nil, 0, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, burns, 3)
+	assert.Equal(t, uint32(10), burns[0].Height)
+	assert.Equal(t, int64(100), burns[0].Burned)
+	assert.Equal(t, int64(100), burns[0].Credited)
+	assert.Equal(t, byte(1), burns[0].TxID[0])
+
+	adr := factom.FAAddress(a)
+	burns, count, err = p.SelectBurns(context.Background(), &adr, 0, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	require.Len(t, burns, 2)
+	assert.Equal(t, adr, burns[1].Address)
+
+	burns, count, err = p.SelectBurns(context.Background(), nil, 11, 11, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.Len(t, burns, 1)
+	assert.Equal(t, int64(200), burns[0].Burned)
+}
@@ -0,0 +1,54 @@
+package pegnet
+
+import (
+	"context"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// ConversionResult is the outcome of a conversion in a batch. The amounts and
+// rates are 0 until the batch is executed. `ToAmount` is the amount credited,
+// the refund is the part of the input that was returned.
+type ConversionResult struct {
+	TxID         string `json:"txid"`
+	TxIndex      int    `json:"txindex"`
+	Executed     int32  `json:"executed"`
+	FromAsset    string `json:"fromasset"`
+	FromAmount   int64  `json:"fromamount"`
+	ToAsset      string `json:"toasset"`
+	ToAmount     int64  `json:"toamount"`
+	FromRate     uint64 `json:"fromrate"`
+	ToRate       uint64 `json:"torate"`
+	Prorated     bool   `json:"prorated"`
+	Refunded     int64  `json:"refunded,omitempty"`
+	RefundReason string `json:"refundreason,omitempty"`
+}
+
+// SelectConversionResults returns the results of the conversions in the
+// batch, ordered by their index. Batches without conversions return nil.
+func (p *Pegnet) SelectConversionResults(ctx context.Context, hash *factom.Bytes32) ([]ConversionResult, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT tx.tx_index, batch.executed, tx.from_asset, tx.from_amount, tx.to_asset, tx.to_amount,
+		tx.from_rate, tx.to_rate, IFNULL(refund.amount, 0), IFNULL(refund.reason, '')
+		FROM pn_history_txbatch batch, pn_history_transaction tx
+		LEFT JOIN pn_history_refund refund ON refund.entry_hash = tx.entry_hash AND refund.tx_index = tx.tx_index
+		WHERE batch.entry_hash = tx.entry_hash AND tx.entry_hash = ? AND tx.action_type = ?
+		ORDER BY tx.tx_index ASC`, hash[:], Conversion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ConversionResult
+	for rows.Next() {
+		var r ConversionResult
+		err := rows.Scan(&r.TxIndex, &r.Executed, &r.FromAsset, &r.FromAmount, &r.ToAsset, &r.ToAmount,
+			&r.FromRate, &r.ToRate, &r.Refunded, &r.RefundReason)
+		if err != nil {
+			return nil, err
+		}
+		r.TxID = FormatTxID(r.TxIndex, hash.String())
+		r.Prorated = r.RefundReason == RefundReasonConversionLimit
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,99 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableConversionVolume is a SQL string that creates the
+// "pn_conversion_volume" table. The table holds the amount of every asset
+// that was converted in and out at each height, so the demand flow of a range
+// can be summed without going through all conversions.
+const createTableConversionVolume = `CREATE TABLE IF NOT EXISTS "pn_conversion_volume" (
+	"height"		INTEGER NOT NULL, -- height the conversions were executed at
+	"token"			TEXT NOT NULL,
+	"converted_in"	INTEGER NOT NULL DEFAULT 0,
+	"converted_out"	INTEGER NOT NULL DEFAULT 0, -- includes refunds of PEG conversions
+
+	PRIMARY KEY("height", "token")
+);
+`
+
+// ConversionVolume is the amount of an asset converted in and out
+type ConversionVolume struct {
+	ConvertedIn  int64 `json:"convertedin"`
+	ConvertedOut int64 `json:"convertedout"`
+}
+
+// CreateTableConversionVolume is used to expose this table for unit tests
+func (p *Pegnet) CreateTableConversionVolume() error {
+	_, err := p.DB.Exec(createTableConversionVolume)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertConversionVolume records the volume of all conversions that were
+// executed at the given height. Must be called after all conversions of the
+// height are recorded in the history.
+func (p *Pegnet) InsertConversionVolume(tx *sql.Tx, height uint32) error {
+	for _, volume := range []struct {
+		column, asset, amount string
+	}{
+		{"converted_out", "from_asset", "from_amount"},
+		{"converted_in", "to_asset", "to_amount"},
+	} {
+		_, err := tx.Exec(fmt.Sprintf(`INSERT INTO "pn_conversion_volume" ("height", "token", "%[1]s")
+			SELECT ?, tx.%[2]s, SUM(tx.%[3]s)
+			FROM pn_history_txbatch batch, pn_history_transaction tx
+			WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ? AND tx.action_type = ?
+			GROUP BY tx.%[2]s
+			ON CONFLICT("height", "token") DO UPDATE SET "%[1]s" = "%[1]s" + "excluded"."%[1]s";`,
+			volume.column, volume.asset, volume.amount), height, height, Conversion)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectConversionVolume returns the total conversion volume of every asset
+// in the height range [start, stop]. If the ticker is not invalid, only that
+// asset is returned.
+func (p *Pegnet) SelectConversionVolume(ctx context.Context, start, stop uint32, ticker fat2.PTicker) (map[fat2.PTicker]ConversionVolume, error) {
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+
+	query := `SELECT "token", SUM("converted_in"), SUM("converted_out") FROM "pn_conversion_volume"
+		WHERE "height" >= ? AND "height" <= ?`
+	args := []interface{}{start, stop}
+	if ticker != fat2.PTickerInvalid {
+		query += ` AND "token" = ?`
+		args = append(args, ticker.String())
+	}
+	query += ` GROUP BY "token";`
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[fat2.PTicker]ConversionVolume)
+	for rows.Next() {
+		var token string
+		var v ConversionVolume
+		if err := rows.Scan(&token, &v.ConvertedIn, &v.ConvertedOut); err != nil {
+			return nil, err
+		}
+		if t := fat2.StringToTicker(token); t != fat2.PTickerInvalid {
+			res[t] = v
+		}
+	}
+	return res, rows.Err()
+}
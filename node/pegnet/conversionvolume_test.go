@@ -0,0 +1,56 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_ConversionVolume(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableConversionVolume())
+
+	var a factom.FAAddress
+	for i := 0; i < 3; i++ {
+		height := uint32(100 + i)
+		var hash factom.Bytes32
+		hash[0] = byte(i + 1)
+		batch := &fat2.TransactionBatch{
+			Entry: factom.Entry{Hash: &hash, Timestamp: time.Now()},
+			Transactions: []fat2.Transaction{
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG}, Conversion: fat2.PTickerUSD},
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 3, Type: fat2.PTickerUSD}, Conversion: fat2.PTickerEUR},
+			},
+		}
+
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, height))
+		require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, int64(height)))
+		require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 0, 20, 4, 1))
+		require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 1, 2, 1, 1))
+		require.NoError(t, p.InsertConversionVolume(tx, height))
+		require.NoError(t, tx.Commit())
+	}
+
+	volume, err := p.SelectConversionVolume(context.Background(), 101, 102, fat2.PTickerInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, map[fat2.PTicker]ConversionVolume{
+		fat2.PTickerPEG: {ConvertedOut: 10},
+		fat2.PTickerUSD: {ConvertedIn: 40, ConvertedOut: 6},
+		fat2.PTickerEUR: {ConvertedIn: 4},
+	}, volume)
+
+	volume, err = p.SelectConversionVolume(context.Background(), 0, 1000, fat2.PTickerUSD)
+	require.NoError(t, err)
+	assert.Equal(t, map[fat2.PTicker]ConversionVolume{fat2.PTickerUSD: {ConvertedIn: 60, ConvertedOut: 9}}, volume)
+}
@@ -0,0 +1,285 @@
+package pegnet
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableDeposits is a SQL string that creates the tables used to track
+// the deposits to a watch-list of addresses.
+//
+// A deposit is every credit from outside the address: transfer outputs,
+// coinbases, and burns. Conversions only move funds within the address and
+// are not deposits. Deposits are only recorded from the height the address
+// was added to the watch-list.
+const createTableDeposits = `CREATE TABLE IF NOT EXISTS "pn_deposit_addresses" (
+	"address"		BLOB PRIMARY KEY,
+	"confirmations"	INTEGER NOT NULL, -- required before a deposit is confirmed
+	"added"			INTEGER NOT NULL, -- height the address was added at
+	"sealed_address"	TEXT NOT NULL DEFAULT '' -- with a column key, see privateTable
+);
+
+CREATE TABLE IF NOT EXISTS "pn_deposits" (
+	"id"				INTEGER PRIMARY KEY,
+	"address"			BLOB NOT NULL,
+	"height"			INTEGER NOT NULL, -- height the deposit was applied at
+	"entry_hash"		BLOB NOT NULL,
+	"tx_index"			INTEGER NOT NULL,
+	"action_type"		INTEGER NOT NULL,
+	"from_address"		BLOB NOT NULL,
+	"token"				TEXT NOT NULL,
+	"amount"			INTEGER NOT NULL,
+	"confirmations"		INTEGER NOT NULL, -- required, copied from the watch-list
+	"confirmed"			INTEGER NOT NULL DEFAULT 0, -- height it was confirmed at, 0 if pending
+	"sealed_address"	TEXT NOT NULL DEFAULT '',
+	"sealed_entry_hash"	TEXT NOT NULL DEFAULT '',
+
+	UNIQUE("address", "entry_hash", "tx_index", "height")
+);
+CREATE INDEX IF NOT EXISTS "idx_deposits_height" ON "pn_deposits"("height");
+CREATE INDEX IF NOT EXISTS "idx_deposits_confirmed" ON "pn_deposits"("confirmed");
+`
+
+// DepositsLimit is the maximum amount of deposits that can be queried at once
+const DepositsLimit = 1000
+
+// DepositAddress is an address on the watch-list
+type DepositAddress struct {
+	Address       factom.FAAddress `json:"address"`
+	Confirmations uint32           `json:"confirmations"`
+	Added         uint32           `json:"added"`
+}
+
+// Deposit is a credit to a watched address. An address that is credited
+// multiple times by the same action has the amounts combined.
+type Deposit struct {
+	ID          int64            `json:"id"`
+	Address     factom.FAAddress `json:"address"`
+	Height      uint32           `json:"height"`
+	Hash        factom.Bytes32   `json:"hash"`
+	TxID        string           `json:"txid"` // [TxIndex]-[BatchHash]
+	TxAction    HistoryAction    `json:"txaction"`
+	FromAddress factom.FAAddress `json:"fromaddress"`
+	Asset       string           `json:"asset"`
+	Amount      int64            `json:"amount"`
+	// RequiredConfirmations are the confirmations of the watch-list at the
+	// time of the deposit
+	RequiredConfirmations uint32 `json:"requiredconfirmations"`
+	Confirmed             uint32 `json:"confirmed"` // height it was confirmed at, 0 if pending
+}
+
+// CreateTableDeposits is used to expose this table for unit tests
+func (p *Pegnet) CreateTableDeposits() error {
+	_, err := p.DB.Exec(createTableDeposits)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertDepositAddress adds the address to the watch-list or updates the
+// required confirmations if it is already watched. Deposits that are still
+// pending keep the confirmations they were recorded with.
+func (p *Pegnet) InsertDepositAddress(q QueryAble, adr factom.FAAddress, confirmations, height uint32) error {
+	if q == nil {
+		q = p.DB
+	}
+	indexed, sealed, err := p.index("pn_deposit_addresses.address", adr[:])
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT INTO "pn_deposit_addresses" ("address", "sealed_address", "confirmations", "added") VALUES (?, ?, ?, ?)
+		ON CONFLICT("address") DO UPDATE SET "confirmations" = "excluded"."confirmations";`,
+		indexed, sealed, confirmations, height)
+	return err
+}
+
+// DeleteDepositAddress removes the address from the watch-list. The recorded
+// deposits are kept. Returns false if the address was not watched.
+func (p *Pegnet) DeleteDepositAddress(q QueryAble, adr factom.FAAddress) (bool, error) {
+	if q == nil {
+		q = p.DB
+	}
+	res, err := q.Exec(`DELETE FROM "pn_deposit_addresses" WHERE "address" = ?;`, p.indexKey("pn_deposit_addresses.address", adr[:]))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SelectDepositAddresses returns the watch-list, in the order the addresses
+// were added
+func (p *Pegnet) SelectDepositAddresses(q QueryAble) ([]DepositAddress, error) {
+	if q == nil {
+		q = p.DB
+	}
+	// The addresses can be encrypted, they are sorted once decrypted
+	rows, err := q.Query(`SELECT ` + selectIndexed("address") + `, "confirmations", "added" FROM "pn_deposit_addresses";`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []DepositAddress
+	for rows.Next() {
+		var a DepositAddress
+		var adr []byte
+		if err := rows.Scan(p.sealed("pn_deposit_addresses.address", &adr), &a.Confirmations, &a.Added); err != nil {
+			return nil, err
+		}
+		copy(a.Address[:], adr)
+		addresses = append(addresses, a)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if addresses[i].Added != addresses[j].Added {
+			return addresses[i].Added < addresses[j].Added
+		}
+		return bytes.Compare(addresses[i].Address[:], addresses[j].Address[:]) < 0
+	})
+	return addresses, rows.Err()
+}
+
+// InsertDeposits records the credits to watched addresses of everything
+// that was executed at the height, then confirms all pending deposits that
+// reached their confirmations. Must be called after all actions of the
+// height are recorded in the history.
+func (p *Pegnet) InsertDeposits(tx *sql.Tx, height uint32) error {
+	watched, err := p.SelectDepositAddresses(tx)
+	if err != nil {
+		return err
+	}
+
+	if len(watched) > 0 {
+		confirmations := make(map[factom.FAAddress]uint32, len(watched))
+		for _, w := range watched {
+			confirmations[w.Address] = w.Confirmations
+		}
+
+		actions, err := p.SelectTransactionHistoryActionsExecuted(tx, height)
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.Prepare(`INSERT OR IGNORE INTO "pn_deposits"
+			("address", "sealed_address", "height", "entry_hash", "sealed_entry_hash", "tx_index", "action_type",
+			"from_address", "token", "amount", "confirmations")
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, a := range actions {
+			credits := make(map[factom.FAAddress]int64)
+			var asset string
+			switch a.TxAction {
+			case Transfer:
+				asset = a.FromAsset
+				for _, o := range a.Outputs {
+					credits[o.Address] += o.Amount
+				}
+			case Coinbase, FCTBurn:
+				asset = a.ToAsset
+				credits[*a.FromAddress] += a.ToAmount
+			default:
+				continue
+			}
+
+			for adr, amount := range credits {
+				required, ok := confirmations[adr]
+				if !ok {
+					continue
+				}
+				indexed, sealed, err := p.index("pn_deposits.address", adr[:])
+				if err != nil {
+					return err
+				}
+				hash, sealedHash, err := p.index("pn_deposits.entry_hash", a.Hash[:])
+				if err != nil {
+					return err
+				}
+				private, err := p.sealAll("pn_deposits", []string{"from_address", "token", "amount"}, a.FromAddress[:], asset, amount)
+				if err != nil {
+					return err
+				}
+				if _, err := stmt.Exec(indexed, sealed, height, hash, sealedHash, a.TxIndex, a.TxAction,
+					private[0], private[1], private[2], required); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// A deposit has one confirmation in the block it was applied in
+	_, err = tx.Exec(`UPDATE "pn_deposits" SET "confirmed" = ?
+		WHERE "confirmed" = 0 AND ? - "height" + 1 >= "confirmations";`, height, height)
+	return err
+}
+
+var depositQueryFields = `"id", ` + selectIndexed("address") + `, "height", ` + selectIndexed("entry_hash") +
+	`, "tx_index", "action_type", "from_address", "token", "amount", "confirmations", "confirmed"`
+
+func (p *Pegnet) scanDeposits(rows *sql.Rows) ([]Deposit, error) {
+	var deposits []Deposit
+	for rows.Next() {
+		var d Deposit
+		var adr, hash, from []byte
+		var index int
+		if err := rows.Scan(&d.ID, p.sealed("pn_deposits.address", &adr), &d.Height, p.sealed("pn_deposits.entry_hash", &hash),
+			&index, &d.TxAction, p.sealed("pn_deposits.from_address", &from), p.sealed("pn_deposits.token", &d.Asset),
+			p.sealed("pn_deposits.amount", &d.Amount), &d.RequiredConfirmations, &d.Confirmed); err != nil {
+			return nil, err
+		}
+		copy(d.Address[:], adr)
+		copy(d.Hash[:], hash)
+		copy(d.FromAddress[:], from)
+		d.TxID = FormatTxID(index, d.Hash.String())
+		deposits = append(deposits, d)
+	}
+	return deposits, rows.Err()
+}
+
+// SelectDepositsConfirmed returns the deposits that were confirmed at the
+// height
+func (p *Pegnet) SelectDepositsConfirmed(q QueryAble, height uint32) ([]Deposit, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(fmt.Sprintf(`SELECT %s FROM "pn_deposits" WHERE "confirmed" = ? ORDER BY "id";`, depositQueryFields), height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return p.scanDeposits(rows)
+}
+
+// SelectDeposits returns up to `limit` deposits applied at or after the
+// height with an id greater than `after`, in the order they were recorded.
+// If the address is not nil, only the deposits to that address are returned.
+func (p *Pegnet) SelectDeposits(ctx context.Context, since uint32, adr *factom.FAAddress, after int64, limit int) ([]Deposit, error) {
+	if limit <= 0 || limit > DepositsLimit {
+		limit = DepositsLimit
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM "pn_deposits" WHERE "height" >= ? AND "id" > ?`, depositQueryFields)
+	args := []interface{}{since, after}
+	if adr != nil {
+		query += ` AND "address" = ?`
+		args = append(args, p.indexKey("pn_deposits.address", adr[:]))
+	}
+	query += ` ORDER BY "id" LIMIT ?;`
+	args = append(args, limit)
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return p.scanDeposits(rows)
+}
@@ -0,0 +1,89 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_Deposits(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableDeposits())
+
+	var a, b, c factom.FAAddress
+	a[0], b[0], c[0] = 1, 2, 3
+	require.NoError(t, p.InsertDepositAddress(nil, b, 2, 100))
+	require.NoError(t, p.InsertDepositAddress(nil, c, 1, 100))
+
+	for i := 0; i < 3; i++ {
+		height := uint32(100 + i)
+		var hash factom.Bytes32
+		hash[0] = byte(i + 1)
+		batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(int64(height), 0)}}
+		if i == 0 {
+			batch.Transactions = []fat2.Transaction{
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 30, Type: fat2.PTickerPEG},
+					Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}, {Address: b, Amount: 5}, {Address: c, Amount: 15}}},
+				{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 5, Type: fat2.PTickerPEG},
+					Conversion: fat2.PTickerUSD},
+			}
+		}
+
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		if len(batch.Transactions) > 0 {
+			require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, height))
+			require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, int64(height)))
+		}
+		require.NoError(t, p.InsertDeposits(tx, height))
+
+		confirmed, err := p.SelectDepositsConfirmed(tx, height)
+		require.NoError(t, err)
+		switch height {
+		case 100: // c only requires one confirmation
+			require.Len(t, confirmed, 1)
+			assert.Equal(t, c, confirmed[0].Address)
+			assert.Equal(t, Transfer, confirmed[0].TxAction)
+		case 101:
+			require.Len(t, confirmed, 1)
+			assert.Equal(t, b, confirmed[0].Address)
+			assert.Equal(t, int64(15), confirmed[0].Amount)
+			assert.Equal(t, "PEG", confirmed[0].Asset)
+			assert.Equal(t, a, confirmed[0].FromAddress)
+		default:
+			assert.Empty(t, confirmed)
+		}
+		require.NoError(t, tx.Commit())
+	}
+
+	deposits, err := p.SelectDeposits(context.Background(), 0, nil, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, deposits, 2)
+
+	deposits, err = p.SelectDeposits(context.Background(), 0, &b, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, uint32(2), deposits[0].RequiredConfirmations)
+	assert.Equal(t, uint32(101), deposits[0].Confirmed)
+
+	deposits, err = p.SelectDeposits(context.Background(), 101, nil, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, deposits)
+
+	removed, err := p.DeleteDepositAddress(nil, b)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	watched, err := p.SelectDepositAddresses(nil)
+	require.NoError(t, err)
+	require.Len(t, watched, 1)
+	assert.Equal(t, c, watched[0].Address)
+}
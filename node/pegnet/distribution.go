@@ -0,0 +1,113 @@
+package pegnet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// DistributionBuckets are the bounds of the balance buckets of the
+// distribution in whole units. A bucket holds the balances from its bound up
+// to the next one, the last bucket holds everything above.
+var DistributionBuckets = []uint64{0, 1, 10, 100, 1e3, 1e4, 1e5, 1e6}
+
+// DistributionBucket are the holders with a balance in [min, max) in base
+// units. The last bucket has no max.
+type DistributionBucket struct {
+	Min     uint64 `json:"min"`
+	Max     uint64 `json:"max,omitempty"`
+	Holders int    `json:"holders"`
+	Balance uint64 `json:"balance"`
+}
+
+// Distribution is how the balances of an asset are spread over its holders.
+// The shares of the top holders are fractions of the held supply.
+type Distribution struct {
+	Holders int                  `json:"holders"`
+	Supply  uint64               `json:"supply"`
+	Buckets []DistributionBucket `json:"buckets"`
+	Gini    float64              `json:"gini"`
+	Top1    float64              `json:"top1"`
+	Top10   float64              `json:"top10"`
+	Top100  float64              `json:"top100"`
+}
+
+// SelectDistribution returns the distribution of the current balances of the
+// ticker
+func (p *Pegnet) SelectDistribution(ctx context.Context, ticker fat2.PTicker) (*Distribution, error) {
+	if ticker <= fat2.PTickerInvalid || fat2.PTickerMax <= ticker {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	col := strings.ToLower(ticker.String()) + "_balance"
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %[1]s FROM pn_addresses WHERE %[1]s > 0 ORDER BY %[1]s DESC;`, col))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []uint64
+	for rows.Next() {
+		var balance uint64
+		if err := rows.Scan(&balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newDistribution(balances), nil
+}
+
+// newDistribution computes the distribution of the balances, which are
+// ordered by size descending
+func newDistribution(balances []uint64) *Distribution {
+	d := &Distribution{Holders: len(balances), Buckets: make([]DistributionBucket, len(DistributionBuckets))}
+	for i, bound := range DistributionBuckets {
+		d.Buckets[i].Min = bound * 1e8
+		if i+1 < len(DistributionBuckets) {
+			d.Buckets[i].Max = DistributionBuckets[i+1] * 1e8
+		}
+	}
+
+	// The gini coefficient of the balances ordered ascending is
+	// 2 * sum(i * x_i) / (n * sum(x_i)) - (n + 1) / n
+	var weighted float64
+	n := len(balances)
+	for i, balance := range balances {
+		d.Supply += balance
+		weighted += float64(n-i) * float64(balance)
+		switch i {
+		case 0:
+			d.Top1 = float64(d.Supply)
+		case 9:
+			d.Top10 = float64(d.Supply)
+		case 99:
+			d.Top100 = float64(d.Supply)
+		}
+
+		b := len(d.Buckets) - 1
+		for b > 0 && balance < d.Buckets[b].Min {
+			b--
+		}
+		d.Buckets[b].Holders++
+		d.Buckets[b].Balance += balance
+	}
+	if d.Supply == 0 {
+		return d
+	}
+
+	// Fewer holders than the top hold everything
+	if n < 10 {
+		d.Top10 = float64(d.Supply)
+	}
+	if n < 100 {
+		d.Top100 = float64(d.Supply)
+	}
+	supply := float64(d.Supply)
+	d.Top1, d.Top10, d.Top100 = d.Top1/supply, d.Top10/supply, d.Top100/supply
+	d.Gini = 2*weighted/(float64(n)*supply) - float64(n+1)/float64(n)
+	return d
+}
@@ -0,0 +1,72 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectDistribution(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+
+	d, err := p.SelectDistribution(context.Background(), fat2.PTickerPEG)
+	require.NoError(t, err)
+	assert.Zero(t, d.Holders)
+	assert.Zero(t, d.Gini)
+
+	// 4 equal holders, then 1 holder with everything
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		var adr factom.FAAddress
+		adr[0] = byte(i + 1)
+		_, err = p.AddToBalance(tx, &adr, fat2.PTickerPEG, 5e8)
+		require.NoError(t, err)
+	}
+	var whale factom.FAAddress
+	whale[0] = 10
+	_, err = p.AddToBalance(tx, &whale, fat2.PTickerUSD, 2e14)
+	require.NoError(t, err)
+	// 2 unequal holders, 1 and 3 pEUR
+	for i, amount := range []uint64{1e8, 3e8} {
+		var adr factom.FAAddress
+		adr[0] = byte(i + 20)
+		_, err = p.AddToBalance(tx, &adr, fat2.PTickerEUR, amount)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	d, err = p.SelectDistribution(context.Background(), fat2.PTickerPEG)
+	require.NoError(t, err)
+	assert.Equal(t, 4, d.Holders)
+	assert.Equal(t, uint64(20e8), d.Supply)
+	assert.InDelta(t, 0, d.Gini, 1e-9)
+	assert.InDelta(t, 0.25, d.Top1, 1e-9)
+	assert.InDelta(t, 1, d.Top10, 1e-9)
+	assert.Equal(t, 4, d.Buckets[1].Holders) // [1, 10)
+	assert.Equal(t, uint64(1e8), d.Buckets[1].Min)
+	assert.Equal(t, uint64(10e8), d.Buckets[1].Max)
+
+	d, err = p.SelectDistribution(context.Background(), fat2.PTickerUSD)
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Holders)
+	assert.InDelta(t, 0, d.Gini, 1e-9)
+	assert.InDelta(t, 1, d.Top1, 1e-9)
+	last := d.Buckets[len(d.Buckets)-1]
+	assert.Equal(t, 1, last.Holders)
+	assert.Zero(t, last.Max)
+
+	d, err = p.SelectDistribution(context.Background(), fat2.PTickerEUR)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.25, d.Gini, 1e-9)
+	assert.InDelta(t, 0.75, d.Top1, 1e-9)
+
+	_, err = p.SelectDistribution(context.Background(), fat2.PTickerInvalid)
+	assert.Error(t, err)
+}
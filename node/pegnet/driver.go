@@ -0,0 +1,205 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pegnet/pegnetd/metrics"
+	"github.com/pegnet/pegnetd/tracing"
+	log "github.com/sirupsen/logrus"
+)
+
+// tracedDriverName is the sqlite3 driver that records a span for every query
+// made with a traced context, and logs the slow queries
+const tracedDriverName = "sqlite3-pegnet"
+
+func init() {
+	sql.Register(tracedDriverName, &tracedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// slowQuery is the duration in nanoseconds after which a query is logged
+// with its parameters, 0 disables it. The driver is shared by all databases,
+// so it is set when a database is opened.
+var slowQuery int64
+
+func setSlowQuery(d time.Duration) {
+	atomic.StoreInt64(&slowQuery, int64(d))
+}
+
+// writeFault is the func() error that fails writes before they reach sqlite,
+// set by InjectWriteFaults
+var writeFault atomic.Value
+
+// InjectWriteFaults makes every write of every database call fault first and
+// fail with its error if it returns one. It injects the write errors of the
+// chaos mode, nil stops it.
+func InjectWriteFaults(fault func() error) {
+	writeFault.Store(fault)
+}
+
+func injectedWriteFault() error {
+	if fault, _ := writeFault.Load().(func() error); fault != nil {
+		return fault()
+	}
+	return nil
+}
+
+// queryObserver traces and times a single query
+type queryObserver struct {
+	span  *tracing.Span
+	start time.Time
+	query string
+	args  []driver.NamedValue
+}
+
+// observeQuery starts observing a query, it returns nil if the query is
+// neither traced nor timed
+func observeQuery(ctx context.Context, query string, args []driver.NamedValue) *queryObserver {
+	_, span := tracing.StartChild(ctx, "sqlite", tracing.KindClient)
+	if span == nil && atomic.LoadInt64(&slowQuery) <= 0 {
+		return nil
+	}
+	if span != nil {
+		statement := query
+		if len(statement) > 200 {
+			statement = statement[:200] + "..."
+		}
+		span.SetAttribute("db.system", "sqlite")
+		span.SetAttribute("db.statement", statement)
+	}
+	return &queryObserver{span: span, start: time.Now(), query: query, args: args}
+}
+
+// finish ends the span of the query and logs the query if it was slow
+func (o *queryObserver) finish(err error) {
+	if o == nil {
+		return
+	}
+	o.span.SetError(err)
+	o.span.Finish()
+
+	threshold := time.Duration(atomic.LoadInt64(&slowQuery))
+	if elapsed := time.Since(o.start); threshold > 0 && elapsed > threshold {
+		metrics.GlobalSlow.Inc("queries")
+		log.WithFields(log.Fields{
+			"duration": elapsed,
+			"query":    o.query,
+			"args":     queryArgs(o.args),
+		}).WithError(err).Warn("slow query")
+	}
+}
+
+// queryArgs formats the parameters of a query for the log, blobs are hex
+func queryArgs(args []driver.NamedValue) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.Value.(type) {
+		case []byte:
+			out[i] = "x'" + hex.EncodeToString(v) + "'"
+		case string:
+			out[i] = strconv.Quote(v)
+		default:
+			out[i] = fmt.Sprint(v)
+		}
+		if arg.Name != "" {
+			out[i] = arg.Name + "=" + out[i]
+		}
+	}
+	return out
+}
+
+type tracedDriver struct {
+	driver.Driver
+}
+
+func (d *tracedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// tracedConn forwards everything to the sqlite connection
+type tracedConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{stmt.(*sqlite3.SQLiteStmt), query}, nil
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := injectedWriteFault(); err != nil {
+		return nil, err
+	}
+	o := observeQuery(ctx, query, args)
+	res, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	o.finish(err)
+	return res, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	o := observeQuery(ctx, query, args)
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	return tracedQuery(o, rows, err)
+}
+
+type tracedStmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := injectedWriteFault(); err != nil {
+		return nil, err
+	}
+	o := observeQuery(ctx, s.query, args)
+	res, err := s.SQLiteStmt.ExecContext(ctx, args)
+	o.finish(err)
+	return res, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	o := observeQuery(ctx, s.query, args)
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	return tracedQuery(o, rows, err)
+}
+
+// tracedQuery finishes observing a query once its rows are closed, sqlite
+// only runs the query while the rows are read
+func tracedQuery(o *queryObserver, rows driver.Rows, err error) (driver.Rows, error) {
+	if o == nil {
+		return rows, err
+	}
+	if err != nil {
+		o.finish(err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, observer: o}, nil
+}
+
+type tracedRows struct {
+	driver.Rows
+	observer *queryObserver
+}
+
+func (r *tracedRows) Close() error {
+	r.observer.finish(nil)
+	return r.Rows.Close()
+}
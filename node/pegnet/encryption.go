@@ -0,0 +1,443 @@
+package pegnet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pegnet/pegnetd/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// privateTable lists the columns of an operator table that link the node to
+// its users and its own funds. With a column key they are stored encrypted
+// with AES-256-GCM, bound to their column. The chain tables, the addresses,
+// balances, and history of everyone, are public and stay in plain text. The
+// database file itself, its schema, and the heights and times of the rows
+// are not encrypted.
+//
+// A sealed column holds its value encrypted. SQLite keeps the text of the
+// sealed value as is in a BLOB or INTEGER column.
+//
+// An indexed column is looked up, it holds a keyed hash of the value that
+// is the same for the same value, and its "sealed_" column holds the value
+// encrypted.
+type privateTable struct {
+	Table   string
+	Sealed  []string
+	Indexed []string
+}
+
+var privateTables = []privateTable{
+	{Table: "pn_send_audit", Sealed: []string{"caller", "apikey", "entry_hash", "error"}},
+	{Table: "pn_watch_addresses", Sealed: []string{"account", "label"}, Indexed: []string{"address"}},
+	{Table: "pn_deposit_addresses", Indexed: []string{"address"}},
+	{Table: "pn_deposits", Sealed: []string{"from_address", "token", "amount"}, Indexed: []string{"address", "entry_hash"}},
+	{Table: "pn_schedules", Sealed: []string{"input", "token", "amount", "output", "last_entry_hash", "last_error"}},
+	{Table: "pn_policy_spends", Sealed: []string{"amount", "entry_hash"}, Indexed: []string{"address", "token"}},
+}
+
+// sealedPrefix marks an encrypted value. Values without it were written
+// before the columns were encrypted.
+const sealedPrefix = "enc1:"
+
+// keyCheck is sealed into the metadata, so the database only opens with the
+// key that encrypted it
+const keyCheck = "pegnetd"
+
+// ColumnKey returns the column encryption key of the config, from the key
+// command if it is set, or nil if there is none
+func ColumnKey(conf *viper.Viper) ([]byte, error) {
+	str := conf.GetString(config.DBColumnKey)
+	if command := conf.GetString(config.DBColumnKeyCommand); command != "" {
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(shell, flag, command)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v: %s", config.DBColumnKeyCommand, err, strings.TrimSpace(stderr.String()))
+		}
+		str = string(out)
+	}
+	if str == "" {
+		return nil, nil
+	}
+	key, err := config.DecodeColumnKey(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid column key: %v", err)
+	}
+	return key, nil
+}
+
+// fieldCipher encrypts the values of the private columns
+type fieldCipher struct {
+	aead cipher.AEAD
+	// index is the key of the hashes of the indexed columns
+	index []byte
+}
+
+func newFieldCipher(key []byte) (*fieldCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("pegnetd column index"))
+	return &fieldCipher{aead: aead, index: mac.Sum(nil)}, nil
+}
+
+func (c *fieldCipher) seal(column, value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := c.aead.Seal(nonce, nonce, []byte(value), []byte(column))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (c *fieldCipher) open(column, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", fmt.Errorf("%s: invalid encrypted value", column)
+	}
+	size := c.aead.NonceSize()
+	plain, err := c.aead.Open(nil, data[:size], data[size:], []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", column, err)
+	}
+	return string(plain), nil
+}
+
+func (c *fieldCipher) hash(column string, value []byte) []byte {
+	mac := hmac.New(sha256.New, c.index)
+	mac.Write([]byte(column))
+	mac.Write([]byte{0})
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+// plainText returns the text that is encrypted for a value of a column
+func plainText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	}
+	return "", fmt.Errorf("a %T can't be encrypted", value)
+}
+
+// seal returns the value to write to the sealed column, the value itself if
+// the columns are not encrypted. A NULL stays NULL.
+func (p *Pegnet) seal(column string, value interface{}) (interface{}, error) {
+	if p.cipher == nil {
+		return value, nil
+	}
+	if b, ok := value.([]byte); ok && b == nil {
+		return nil, nil
+	}
+	text, err := plainText(value)
+	if err != nil {
+		return nil, err
+	}
+	return p.cipher.seal(column, text)
+}
+
+// sealAll seals the values of the columns of the table
+func (p *Pegnet) sealAll(table string, columns []string, values ...interface{}) ([]interface{}, error) {
+	sealed := make([]interface{}, len(values))
+	for i, col := range columns {
+		var err error
+		if sealed[i], err = p.seal(table+"."+col, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+// index returns the value to write to the indexed column and to its sealed
+// column. Without a column key the value is written as is.
+func (p *Pegnet) index(column string, value interface{}) (indexed interface{}, sealed string, err error) {
+	if p.cipher == nil {
+		return value, "", nil
+	}
+	text, err := plainText(value)
+	if err != nil {
+		return nil, "", err
+	}
+	if sealed, err = p.cipher.seal(column, text); err != nil {
+		return nil, "", err
+	}
+	return p.cipher.hash(column, []byte(text)), sealed, nil
+}
+
+// indexKey returns the value to look up in the indexed column
+func (p *Pegnet) indexKey(column string, value interface{}) interface{} {
+	if p.cipher == nil {
+		return value
+	}
+	text, _ := plainText(value)
+	return p.cipher.hash(column, []byte(text))
+}
+
+// selectIndexed is the expression that selects the value of an indexed
+// column, to scan with sealed
+func selectIndexed(column string) string {
+	return fmt.Sprintf(`IFNULL(NULLIF("sealed_%[1]s", ''), "%[1]s")`, column)
+}
+
+// sealed returns a scanner of a column that can hold an encrypted value into
+// dest, a *string, *[]byte, *int64, or *uint64
+func (p *Pegnet) sealed(column string, dest interface{}) sql.Scanner {
+	return sealedScanner{p: p, column: column, dest: dest}
+}
+
+type sealedScanner struct {
+	p      *Pegnet
+	column string
+	dest   interface{}
+}
+
+func (s sealedScanner) Scan(src interface{}) error {
+	if b, ok := src.([]byte); ok && bytes.HasPrefix(b, []byte(sealedPrefix)) {
+		src = string(b)
+	}
+	if str, ok := src.(string); ok && strings.HasPrefix(str, sealedPrefix) {
+		if s.p.cipher == nil {
+			return fmt.Errorf("%s is encrypted, the database was opened without its column key", s.column)
+		}
+		plain, err := s.p.cipher.open(s.column, str)
+		if err != nil {
+			return err
+		}
+		src = plain
+	}
+
+	var err error
+	switch dest := s.dest.(type) {
+	case *string:
+		*dest, err = plainText(src)
+	case *[]byte:
+		switch v := src.(type) {
+		case nil:
+			*dest = nil
+		case []byte:
+			*dest = append([]byte(nil), v...)
+		case string:
+			*dest = []byte(v)
+		default:
+			err = fmt.Errorf("a %T is not a blob", src)
+		}
+	case *int64:
+		switch v := src.(type) {
+		case int64:
+			*dest = v
+		case string:
+			*dest, err = strconv.ParseInt(v, 10, 64)
+		default:
+			err = fmt.Errorf("a %T is not an integer", src)
+		}
+	case *uint64:
+		switch v := src.(type) {
+		case int64:
+			*dest = uint64(v)
+		case string:
+			*dest, err = strconv.ParseUint(v, 10, 64)
+		default:
+			err = fmt.Errorf("a %T is not an integer", src)
+		}
+	default:
+		err = fmt.Errorf("can't scan into a %T", s.dest)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.column, err)
+	}
+	return nil
+}
+
+// privateColumnsMigrate adds the sealed columns of the indexed columns to
+// the tables created before they were indexed
+func privateColumnsMigrate(p *Pegnet) error {
+	for _, t := range privateTables {
+		for _, col := range t.Indexed {
+			exists, err := p.columnExists(t.Table, "sealed_"+col)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if _, err := p.DB.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "sealed_%s" TEXT NOT NULL DEFAULT '';`, t.Table, col)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// initEncryption checks the key against the database. The first time a
+// database is opened with a key, the private columns written so far are
+// encrypted. A database with encrypted columns does not open without its
+// key.
+func (p *Pegnet) initEncryption(key []byte) error {
+	var stored string
+	err := p.DB.QueryRow(`SELECT "value" FROM "pn_metadata" WHERE "name" = 'column_encryption';`).Scan(&stored)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	encrypted := err == nil
+	if key == nil {
+		if encrypted {
+			return fmt.Errorf("the private columns of the database are encrypted, set %s or %s", config.DBColumnKey, config.DBColumnKeyCommand)
+		}
+		return nil
+	}
+
+	c, err := newFieldCipher(key)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		if check, err := c.open("pn_metadata.column_encryption", stored); err != nil || check != keyCheck {
+			return fmt.Errorf("the column key is not the key of the database")
+		}
+		p.cipher = c
+		return nil
+	}
+
+	if err := p.encryptColumns(c); err != nil {
+		return fmt.Errorf("failed to encrypt the private columns: %v", err)
+	}
+	p.cipher = c
+	return nil
+}
+
+// encryptColumns encrypts the values of the private columns, and seals the
+// key check into the metadata
+func (p *Pegnet) encryptColumns(c *fieldCipher) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The audit is append-only, the trigger is recreated after the update
+	if _, err := tx.Exec(`DROP TRIGGER IF EXISTS "trg_send_audit_update";`); err != nil {
+		return err
+	}
+	var total int
+	for _, t := range privateTables {
+		n, err := encryptTable(tx, c, t)
+		if err != nil {
+			return fmt.Errorf("%s: %v", t.Table, err)
+		}
+		total += n
+	}
+	if _, err := tx.Exec(createTableSendAudit); err != nil {
+		return err
+	}
+
+	check, err := c.seal("pn_metadata.column_encryption", keyCheck)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO "pn_metadata" ("name", "value") VALUES ('column_encryption', ?);`, check); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.WithField("rows", total).Info("Encrypted the private columns of the database")
+	return nil
+}
+
+// encryptTable encrypts the private columns of every row of the table and
+// returns the amount of rows
+func encryptTable(tx *sql.Tx, c *fieldCipher, t privateTable) (int, error) {
+	columns := append(append([]string(nil), t.Sealed...), t.Indexed...)
+	rows, err := tx.Query(fmt.Sprintf(`SELECT "rowid", "%s" FROM "%s";`, strings.Join(columns, `", "`), t.Table))
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id     int64
+		values []interface{}
+	}
+	var all []row
+	for rows.Next() {
+		r := row{values: make([]interface{}, len(columns))}
+		dest := []interface{}{&r.id}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var sets []string
+	for _, col := range t.Sealed {
+		sets = append(sets, fmt.Sprintf(`"%s" = ?`, col))
+	}
+	for _, col := range t.Indexed {
+		sets = append(sets, fmt.Sprintf(`"%[1]s" = ?, "sealed_%[1]s" = ?`, col))
+	}
+	update := fmt.Sprintf(`UPDATE "%s" SET %s WHERE "rowid" = ?;`, t.Table, strings.Join(sets, ", "))
+	for _, r := range all {
+		var args []interface{}
+		for i, col := range columns {
+			column := t.Table + "." + col
+			value := r.values[i]
+			if value == nil {
+				args = append(args, nil)
+				continue
+			}
+			text, err := plainText(value)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %v", col, err)
+			}
+			sealed, err := c.seal(column, text)
+			if err != nil {
+				return 0, err
+			}
+			if i < len(t.Sealed) {
+				args = append(args, sealed)
+			} else {
+				args = append(args, c.hash(column, []byte(text)), sealed)
+			}
+		}
+		if _, err := tx.Exec(update, append(args, r.id)...); err != nil {
+			return 0, err
+		}
+	}
+	return len(all), nil
+}
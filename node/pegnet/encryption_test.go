@@ -0,0 +1,171 @@
+package pegnet_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_Encryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-encryption")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := hex.EncodeToString([]byte(strings.Repeat("k", 32)))
+	open := func(set func(conf *viper.Viper)) (*Pegnet, error) {
+		conf := viper.New()
+		config.SetDefaults(conf)
+		conf.Set(config.SqliteDBPath, filepath.Join(dir, "pegnet.db"))
+		set(conf)
+		p := New(conf)
+		return p, p.Init()
+	}
+
+	var adr, cold, payee factom.FAAddress
+	adr[0], cold[0], payee[0] = 1, 2, 3
+	var hash, spend factom.Bytes32
+	hash[0], spend[0] = 1, 2
+	audit := SendAudit{Time: time.Unix(100, 0), Caller: "127.0.0.1", APIKey: "ops", EntryHash: &spend, Outcome: AuditRejected, Error: "insufficient balance"}
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{{Input: fat2.TypedAddressAmountTuple{Address: adr, Amount: 30, Type: fat2.PTickerPEG},
+		Transfers: []fat2.AddressAmountTuple{{Address: cold, Amount: 30}}}}
+	deposit := func(p *Pegnet) {
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		require.NoError(t, p.InsertDeposits(tx, 100))
+		require.NoError(t, tx.Commit())
+	}
+
+	// The rows written before the key are encrypted on the first start with it
+	p, err := open(func(*viper.Viper) {})
+	require.NoError(t, err)
+	_, err = p.InsertSendAudit(nil, audit)
+	require.NoError(t, err)
+	require.NoError(t, p.InsertWatchAddress(nil, WatchAddress{Address: cold, Account: "cold", Label: "vault", Added: 10}))
+	require.NoError(t, p.InsertDepositAddress(nil, cold, 1, 10))
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 100))
+	require.NoError(t, tx.Commit())
+	deposit(p)
+	_, err = p.InsertSchedule(nil, Schedule{Input: adr, Asset: "pUSD", Amount: 500, Output: payee, Interval: 10, Next: 110, Remaining: -1, Created: 100})
+	require.NoError(t, err)
+	require.NoError(t, p.InsertPolicySpends(nil, time.Now(), spend, []PolicySpend{{Address: adr, Asset: "pUSD", Amount: 500}}))
+	p.Close()
+
+	p, err = open(func(conf *viper.Viper) { conf.Set(config.DBColumnKey, key) })
+	require.NoError(t, err)
+	require.NoError(t, p.InsertWatchAddress(nil, WatchAddress{Address: adr, Account: "archive", Added: 20}))
+	require.NoError(t, p.InsertPolicySpends(nil, time.Now(), spend, []PolicySpend{{Address: adr, Asset: "pUSD", Amount: 250}}))
+	schedules, err := p.SelectSchedules(nil)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	schedules[0].Advance(110)
+	schedules[0].LastEntryHash = &spend
+	schedules[0].LastError = "failed to submit"
+	require.NoError(t, p.UpdateSchedule(nil, schedules[0]))
+	// The indexed columns hash the same value to the same key, so the
+	// deposit is not recorded twice
+	deposit(p)
+
+	for _, c := range []struct {
+		table   string
+		sealed  []string
+		indexed []string
+	}{
+		{"pn_send_audit", []string{"caller", "apikey", "entry_hash", "error"}, nil},
+		{"pn_watch_addresses", []string{"account", "label"}, []string{"address"}},
+		{"pn_deposit_addresses", nil, []string{"address"}},
+		{"pn_deposits", []string{"from_address", "token", "amount"}, []string{"address", "entry_hash"}},
+		{"pn_schedules", []string{"input", "token", "amount", "output", "last_entry_hash", "last_error"}, nil},
+		{"pn_policy_spends", []string{"amount", "entry_hash"}, []string{"address", "token"}},
+	} {
+		var rows int
+		require.NoError(t, p.DB.QueryRow(`SELECT COUNT(*) FROM "`+c.table+`";`).Scan(&rows))
+		require.NotZero(t, rows, c.table)
+		for _, col := range c.sealed {
+			var plain int
+			require.NoError(t, p.DB.QueryRow(`SELECT COUNT(*) FROM "`+c.table+`" WHERE "`+col+`" NOT LIKE 'enc1:%';`).Scan(&plain))
+			assert.Zero(t, plain, c.table+"."+col)
+		}
+		for _, col := range c.indexed {
+			var plain int
+			require.NoError(t, p.DB.QueryRow(`SELECT COUNT(*) FROM "`+c.table+`" WHERE "sealed_`+col+`" NOT LIKE 'enc1:%'
+				OR "`+col+`" IN (?, ?, ?, 'pUSD');`, adr[:], cold[:], hash[:]).Scan(&plain))
+			assert.Zero(t, plain, c.table+"."+col)
+		}
+	}
+	p.Close()
+
+	// The database does not open without its key
+	_, err = open(func(*viper.Viper) {})
+	assert.Error(t, err)
+	other := hex.EncodeToString([]byte(strings.Repeat("x", 32)))
+	_, err = open(func(conf *viper.Viper) { conf.Set(config.DBColumnKey, other) })
+	assert.Error(t, err)
+
+	p, err = open(func(conf *viper.Viper) { conf.Set(config.DBColumnKeyCommand, "echo "+key) })
+	require.NoError(t, err)
+	defer p.Close()
+	audits, _, err := p.SelectSendAudit(nil, time.Unix(0, 0), 0, 10)
+	require.NoError(t, err)
+	require.Len(t, audits, 1)
+	assert.Equal(t, audit, SendAudit{Time: audits[0].Time, Caller: audits[0].Caller, APIKey: audits[0].APIKey,
+		EntryHash: audits[0].EntryHash, Outcome: audits[0].Outcome, Error: audits[0].Error})
+
+	all, err := p.SelectWatchAddresses(nil)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, WatchAddress{Address: adr, Account: "archive", Added: 20}, all[0])
+	assert.Equal(t, WatchAddress{Address: cold, Account: "cold", Label: "vault", Added: 10}, all[1])
+	watched, err := p.IsWatchAddress(nil, cold)
+	require.NoError(t, err)
+	assert.True(t, watched)
+
+	addresses, err := p.SelectDepositAddresses(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []DepositAddress{{Address: cold, Confirmations: 1, Added: 10}}, addresses)
+	deposits, err := p.SelectDeposits(context.Background(), 0, &cold, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, hash, deposits[0].Hash)
+	assert.Equal(t, adr, deposits[0].FromAddress)
+	assert.Equal(t, "PEG", deposits[0].Asset)
+	assert.Equal(t, int64(30), deposits[0].Amount)
+	assert.Equal(t, uint32(100), deposits[0].Confirmed)
+
+	schedules, err = p.SelectSchedules(nil)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, adr, schedules[0].Input)
+	assert.Equal(t, payee, schedules[0].Output)
+	assert.Equal(t, "pUSD", schedules[0].Asset)
+	assert.Equal(t, uint64(500), schedules[0].Amount)
+	assert.Equal(t, &spend, schedules[0].LastEntryHash)
+	assert.Equal(t, "failed to submit", schedules[0].LastError)
+
+	spent, err := p.SelectPolicySpent(nil, adr, "pUSD", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(750), spent)
+
+	removed, err := p.DeleteWatchAddress(nil, cold)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = p.DeleteDepositAddress(nil, cold)
+	require.NoError(t, err)
+	assert.True(t, removed)
+}
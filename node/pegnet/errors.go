@@ -1,17 +1,22 @@
 package pegnet
 
-import "errors"
+import "github.com/pegnet/pegnetd/fat/fat2/validator"
 
 var (
-	InsufficientBalanceErr          = errors.New("insufficient balance")
+	InsufficientBalanceErr          = validator.InsufficientBalanceErr
 	InsufficientBalanceErrInt int64 = -1
 
-	// -2 is an invalid tx. Usually by timestamps
+	// -2 is an invalid tx. Usually by timestamps, signatures, or a malformed entry
+	InvalidTransactionErrInt int64 = -2
 
-	PFCTOneWayError          = errors.New("pFCT conversions are one way only at this height, they cannot be a conversion destination")
+	PFCTOneWayError          = validator.PFCTOneWayError
 	PFCTOneWayErrorInt int64 = -3
-	ZeroRatesError           = errors.New("an asset in the conversion has a rate of 0, and not allowed to be used for conversions")
+	ZeroRatesError           = validator.ZeroRatesError
 	ZeroRatesErrorInt  int64 = -4
+	ReplayError              = validator.ReplayError
+	ReplayErrorInt     int64 = -5
+	MinOutputError           = validator.MinOutputError
+	MinOutputErrorInt  int64 = -6
 )
 
 // IsRejectedTx takes an error, and returns the integer form of that error
@@ -30,5 +35,11 @@ func IsRejectedTx(err error) (int64, error) {
 	if err == ZeroRatesError {
 		return ZeroRatesErrorInt, nil
 	}
+	if err == ReplayError {
+		return ReplayErrorInt, nil
+	}
+	if err == MinOutputError {
+		return MinOutputErrorInt, nil
+	}
 	return 0, err
 }
@@ -0,0 +1,121 @@
+package pegnet
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The ethereum address of an RCD-e address can only be derived from the
+// public key, so the link is recorded the first time the address signs a
+// transaction.
+const createTableEthAddresses = `CREATE TABLE IF NOT EXISTS "pn_eth_addresses" (
+	"address"	BLOB NOT NULL UNIQUE,
+	"eth_address"	BLOB NOT NULL UNIQUE,
+	"height"	INTEGER NOT NULL,
+
+	PRIMARY KEY("address")
+);
+`
+
+// CreateTableEthAddresses is used to expose this table for unit tests
+func (p *Pegnet) CreateTableEthAddresses() error {
+	_, err := p.DB.Exec(createTableEthAddresses)
+	return err
+}
+
+// EthAddressOfRCD returns the linked ethereum address of an RCD-e, which is
+// the 0x0e type byte followed by the uncompressed secp256k1 public key
+func EthAddressOfRCD(rcd []byte) (common.Address, bool) {
+	if len(rcd) != 65 || rcd[0] != factom.RCDType0e {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(crypto.Keccak256(rcd[1:])[12:]), true
+}
+
+// ParseEthAddress parses the "0x" prefixed hex representation of an
+// ethereum address
+func ParseEthAddress(str string) (common.Address, error) {
+	if len(str) != 42 || !strings.HasPrefix(strings.ToLower(str), "0x") {
+		return common.Address{}, fmt.Errorf("ethereum address must be 0x followed by 40 hex characters")
+	}
+	data, err := hex.DecodeString(str[2:])
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid ethereum address: %s", err.Error())
+	}
+	return common.BytesToAddress(data), nil
+}
+
+// InsertEthAddresses links the RCD-e inputs of an entry to their ethereum
+// addresses. The rcd/signature pairs follow the timestamp in the external ids.
+func (p *Pegnet) InsertEthAddresses(tx *sql.Tx, entry factom.Entry, height uint32) error {
+	for i := 1; i < len(entry.ExtIDs); i += 2 {
+		eth, ok := EthAddressOfRCD(entry.ExtIDs[i])
+		if !ok {
+			continue
+		}
+		hash := sha256.Sum256(entry.ExtIDs[i])
+		adr := factom.FAAddress(sha256.Sum256(hash[:]))
+		_, err := tx.Exec(`INSERT OR IGNORE INTO "pn_eth_addresses" ("address", "eth_address", "height") VALUES (?, ?, ?);`,
+			adr[:], eth[:], height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectEthAddress returns the linked ethereum address of an address, false
+// if the address has not been seen signing with an RCD-e
+func (p *Pegnet) SelectEthAddress(adr *factom.FAAddress) (common.Address, bool, error) {
+	var data []byte
+	err := p.DB.QueryRow(`SELECT "eth_address" FROM "pn_eth_addresses" WHERE "address" = ?;`, adr[:]).Scan(&data)
+	if err == sql.ErrNoRows {
+		return common.Address{}, false, nil
+	}
+	if err != nil {
+		return common.Address{}, false, err
+	}
+	return common.BytesToAddress(data), true, nil
+}
+
+// SelectEthAddresses returns the linked ethereum addresses of all addresses
+// that have one
+func (p *Pegnet) SelectEthAddresses() (map[factom.FAAddress]common.Address, error) {
+	rows, err := p.DB.Query(`SELECT "address", "eth_address" FROM "pn_eth_addresses";`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[factom.FAAddress]common.Address)
+	for rows.Next() {
+		var adr, eth []byte
+		if err := rows.Scan(&adr, &eth); err != nil {
+			return nil, err
+		}
+		var fa factom.FAAddress
+		copy(fa[:], adr)
+		links[fa] = common.BytesToAddress(eth)
+	}
+	return links, rows.Err()
+}
+
+// SelectAddressByEth returns the address linked to an ethereum address, or
+// sql.ErrNoRows
+func (p *Pegnet) SelectAddressByEth(eth common.Address) (factom.FAAddress, error) {
+	var data []byte
+	var adr factom.FAAddress
+	err := p.DB.QueryRow(`SELECT "address" FROM "pn_eth_addresses" WHERE "eth_address" = ?;`, eth[:]).Scan(&data)
+	if err != nil {
+		return adr, err
+	}
+	copy(adr[:], data)
+	return adr, nil
+}
@@ -0,0 +1,70 @@
+package pegnet_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_EthAddresses(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableEthAddresses())
+
+	secret, err := factom.GenerateEthSecret()
+	require.NoError(t, err)
+	fs, err := factom.GenerateFsAddress()
+	require.NoError(t, err)
+
+	eth, ok := EthAddressOfRCD(secret.RCD())
+	require.True(t, ok)
+	assert.True(t, strings.EqualFold(secret.EthAddress(), eth.String()))
+	_, ok = EthAddressOfRCD(fs.RCD())
+	assert.False(t, ok)
+
+	entry := factom.Entry{ExtIDs: []factom.Bytes{{0}, secret.RCD(), {1}, fs.RCD(), {2}}}
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertEthAddresses(tx, entry, 100))
+	require.NoError(t, p.InsertEthAddresses(tx, entry, 101)) // ignored
+	require.NoError(t, tx.Commit())
+
+	fa := secret.FAAddress()
+	linked, ok, err := p.SelectEthAddress(&fa)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, eth, linked)
+
+	other := fs.FAAddress()
+	_, ok, err = p.SelectEthAddress(&other)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	adr, err := p.SelectAddressByEth(eth)
+	require.NoError(t, err)
+	assert.Equal(t, fa, adr)
+
+	parsed, err := ParseEthAddress(strings.ToLower(eth.String()))
+	require.NoError(t, err)
+	_, err = p.SelectAddressByEth(parsed)
+	require.NoError(t, err)
+	parsed[0]++
+	_, err = p.SelectAddressByEth(parsed)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	links, err := p.SelectEthAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, map[factom.FAAddress]common.Address{fa: eth}, links)
+
+	for _, str := range []string{"", "0x12", eth.String()[2:], "0x" + strings.Repeat("z", 40)} {
+		_, err := ParseEthAddress(str)
+		assert.Error(t, err, str)
+	}
+}
@@ -43,6 +43,21 @@ const createTableWinners = `CREATE TABLE IF NOT EXISTS "pn_winners" (
 	UNIQUE("height", "position")
 );`
 
+// createTableWinnerRates is a SQL string that creates the "pn_winner_rates"
+// table. The table holds the quotes of every winning OPR, as the rates of a
+// block are taken from the winning set. The tokens use the same names as in
+// "pn_rate". Databases synced before the table existed only have the quotes
+// since the upgrade.
+const createTableWinnerRates = `CREATE TABLE IF NOT EXISTS "pn_winner_rates" (
+	"height" INTEGER NOT NULL,
+	"position" INTEGER NOT NULL, -- position in "pn_winners"
+	"token" TEXT NOT NULL,
+	"value" INTEGER NOT NULL,
+
+	UNIQUE("height", "position", "token")
+);
+`
+
 const createTableRate = `CREATE TABLE IF NOT EXISTS "pn_rate" (
 	"height" INTEGER NOT NULL,
 	"token" TEXT,
@@ -52,10 +67,13 @@ const createTableRate = `CREATE TABLE IF NOT EXISTS "pn_rate" (
 );
 `
 
-func (p *Pegnet) insertRate(tx *sql.Tx, height uint32, tickerString string, rate uint64) error {
-	_, err := tx.Exec("INSERT INTO pn_rate (height, token, value) VALUES ($1, $2, $3)", height, tickerString, rate)
-	if err != nil {
-		return err
+// CreateTableGrade is used to expose the grade, winners, and rate tables for
+// unit tests
+func (p *Pegnet) CreateTableGrade() error {
+	for _, table := range []string{createTableGrade, createTableWinners, createTableWinnerRates, createTableRate} {
+		if _, err := p.DB.Exec(table); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -80,6 +98,7 @@ func (p *Pegnet) InsertRates(tx *sql.Tx, height uint32, rates []opr.AssetUint, p
 		return fmt.Errorf("undefined PEG phase")
 	}
 
+	batch := newRowBatch("INSERT INTO pn_rate (height, token, value) VALUES", 3, "")
 	ratePEG := new(big.Int)
 	for i := range rates {
 		if rates[i].Name == "PEG" {
@@ -89,11 +108,7 @@ func (p *Pegnet) InsertRates(tx *sql.Tx, height uint32, rates []opr.AssetUint, p
 
 		// Correct rates to use `pAsset`
 		rates[i].Name = "p" + rates[i].Name
-
-		err := p.insertRate(tx, height, rates[i].Name, rates[i].Value)
-		if err != nil {
-			return err
-		}
+		batch.add(height, rates[i].Name, rates[i].Value)
 	}
 
 	// Now to insert the PEG rate. All other rates are set above.
@@ -127,10 +142,37 @@ func (p *Pegnet) InsertRates(tx *sql.Tx, height uint32, rates []opr.AssetUint, p
 	case PEGPriceIsFloating: // Rate in opr is the rate
 	}
 
-	err := p.insertRate(tx, height, fat2.PTickerPEG.String(), ratePEG.Uint64())
+	batch.add(height, fat2.PTickerPEG.String(), ratePEG.Uint64())
+	return batch.exec(p, tx)
+}
+
+// InjectRates overrides the rates of the height with the given rates. A
+// height without graded rates starts from the most recent rates before it.
+// The PEG rate is not recomputed from the injected rates.
+func (p *Pegnet) InjectRates(tx *sql.Tx, height uint32, rates map[fat2.PTicker]uint64) error {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM "pn_rate" WHERE "height" = ?;`, height).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		_, err := tx.Exec(`INSERT INTO "pn_rate" ("height", "token", "value")
+			SELECT ?, "token", "value" FROM "pn_rate" WHERE "height" = (
+				SELECT MAX("height") FROM "pn_rate" WHERE "height" < ?
+			);`, height, height)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt, err := p.prepare(tx, `INSERT OR REPLACE INTO "pn_rate" ("height", "token", "value") VALUES (?, ?, ?);`)
 	if err != nil {
 		return err
 	}
+	for ticker, value := range rates {
+		if _, err := stmt.Exec(height, ticker.String(), value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -147,15 +189,29 @@ func (p *Pegnet) InsertGradeBlock(tx *sql.Tx, eblock *factom.EBlock, graded grad
 
 	// No winners? Then don't insert
 	if len(graded.Winners()) > 0 {
+		winners := newRowBatch(`INSERT INTO pn_winners (height, entryhash, oprhash, payout, grade, nonce, difficulty, position, minerid, address) VALUES`, 10, "")
 		for _, o := range graded.Graded() {
 			diff := make([]byte, 8)
 			binary.BigEndian.PutUint64(diff, o.SelfReportedDifficulty)
-			_, err = tx.Exec(`INSERT INTO pn_winners (height, entryhash, oprhash, payout, grade, nonce, difficulty, position, minerid, address) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-				eblock.Height, o.EntryHash, o.OPRHash, o.Payout(), o.Grade, o.Nonce, diff, o.Position(), o.OPR.GetID(), o.OPR.GetAddress())
-			if err != nil {
-				return fmt.Errorf("ht %d, pos %d :%s", eblock.Height, o.Position(), err)
+			winners.add(eblock.Height, o.EntryHash, o.OPRHash, o.Payout(), o.Grade, o.Nonce, diff, o.Position(), o.OPR.GetID(), o.OPR.GetAddress())
+		}
+		if err := winners.exec(p, tx); err != nil {
+			return fmt.Errorf("ht %d :%s", eblock.Height, err)
+		}
+
+		rates := newRowBatch(`INSERT INTO pn_winner_rates (height, position, token, value) VALUES`, 4, "")
+		for _, o := range graded.Winners() {
+			for _, asset := range o.OPR.GetOrderedAssetsUint() {
+				name := asset.Name
+				if name != "PEG" {
+					name = "p" + name // same as InsertRates
+				}
+				rates.add(eblock.Height, o.Position(), name, asset.Value)
 			}
 		}
+		if err := rates.exec(p, tx); err != nil {
+			return fmt.Errorf("ht %d :%s", eblock.Height, err)
+		}
 	}
 
 	return nil
@@ -194,7 +250,11 @@ func (p *Pegnet) SelectReferenceRates(ctx context.Context, tx QueryAble, height
 }
 
 func (p *Pegnet) SelectPendingRates(ctx context.Context, tx *sql.Tx, height uint32) (map[fat2.PTicker]uint64, error) {
-	rows, err := tx.Query("SELECT token, value FROM pn_rate WHERE height = $1", height)
+	stmt, err := p.prepare(tx, "SELECT token, value FROM pn_rate WHERE height = $1")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(height)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +269,75 @@ func (p *Pegnet) SelectRates(ctx context.Context, height uint32) (map[fat2.PTick
 	return _extractAssets(rows)
 }
 
+// SelectRatesByHeightRange returns the rates of all heights in the range
+// [start, stop] that have rates. The tokens are returned as they are stored,
+// so the reference rates are included with the PAssetExchangePrefix.
+func (p *Pegnet) SelectRatesByHeightRange(ctx context.Context, start, stop uint32) (map[uint32]map[string]uint64, error) {
+	rows, err := p.DB.QueryContext(ctx, "SELECT height, token, value FROM pn_rate WHERE height >= $1 AND height <= $2", start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[uint32]map[string]uint64)
+	for rows.Next() {
+		var height uint32
+		var token string
+		var value uint64
+		if err := rows.Scan(&height, &token, &value); err != nil {
+			return nil, err
+		}
+		if res[height] == nil {
+			res[height] = make(map[string]uint64)
+		}
+		res[height][token] = value
+	}
+	return res, rows.Err()
+}
+
+// WinningQuotes are the quotes of the winning OPRs of a block, ordered by
+// position. The quotes of every asset are in the same order as the entry
+// hashes.
+type WinningQuotes struct {
+	EntryHashes []factom.Bytes32
+	Quotes      map[fat2.PTicker][]uint64
+}
+
+// SelectWinningQuotes returns the quotes of the winning OPRs at the height.
+// If there are no recorded quotes, the result is empty.
+func (p *Pegnet) SelectWinningQuotes(ctx context.Context, height uint32) (*WinningQuotes, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT r.position, w.entryhash, r.token, r.value
+		FROM pn_winner_rates r, pn_winners w
+		WHERE r.height = $1 AND w.height = r.height AND w.position = r.position
+		ORDER BY r.position ASC`, height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &WinningQuotes{Quotes: make(map[fat2.PTicker][]uint64)}
+	last := -1
+	for rows.Next() {
+		var position int
+		var hash []byte
+		var token string
+		var value uint64
+		if err := rows.Scan(&position, &hash, &token, &value); err != nil {
+			return nil, err
+		}
+		if position != last {
+			var eh factom.Bytes32
+			copy(eh[:], hash)
+			res.EntryHashes = append(res.EntryHashes, eh)
+			last = position
+		}
+		if ticker := fat2.StringToTicker(token); ticker != fat2.PTickerInvalid {
+			res.Quotes[ticker] = append(res.Quotes[ticker], value)
+		}
+	}
+	return res, rows.Err()
+}
+
 func (p *Pegnet) SelectRatesByKeyMR(ctx context.Context, keymr *factom.Bytes32) (map[fat2.PTicker]uint64, error) {
 	rows, err := p.DB.Query("SELECT token, value FROM pn_rate WHERE height = (SELECT height FROM pn_grade WHERE keymr = $1)", keymr)
 	if err != nil {
@@ -225,7 +354,17 @@ func (p *Pegnet) SelectMostRecentRatesBeforeHeight(ctx context.Context, tx Query
                         SELECT MAX("height")
                         FROM "pn_rate" WHERE "height" < ?
                     );`
-	rows, err := tx.Query(queryString, height)
+	var rows *sql.Rows
+	var err error
+	if sqlTx, ok := tx.(*sql.Tx); ok {
+		var stmt *sql.Stmt
+		if stmt, err = p.prepare(sqlTx, queryString); err != nil {
+			return nil, 0, err
+		}
+		rows, err = stmt.Query(height)
+	} else {
+		rows, err = tx.Query(queryString, height)
+	}
 	if err != nil {
 		return nil, 0, err
 	}
@@ -244,6 +383,60 @@ func (p *Pegnet) SelectMostRecentRatesBeforeHeight(ctx context.Context, tx Query
 	return assets, rateHeight, nil
 }
 
+// SelectLatestRateHeight returns the most recent height with rates, 0 if
+// there are no rates
+func (p *Pegnet) SelectLatestRateHeight(ctx context.Context, tx QueryAble) (uint32, error) {
+	if tx == nil {
+		tx = p.DB
+	}
+	var height uint32
+	err := tx.QueryRow(`SELECT COALESCE(MAX("height"), 0) FROM "pn_rate";`).Scan(&height)
+	return height, err
+}
+
+// RateGapsLimit is the maximum amount of heights that can be queried at once
+const RateGapsLimit = 10000
+
+// RateGap is a height without rates. `Submitted` is the amount of valid OPRs
+// that were submitted, -1 if the height had no OPR entries at all.
+type RateGap struct {
+	Height    uint32 `json:"height"`
+	Submitted int    `json:"submitted"`
+}
+
+// SelectRateGaps returns all heights in the range [start, stop] that have no
+// rates, ordered by height. Conversions in holding are delayed until the
+// next height with rates.
+func (p *Pegnet) SelectRateGaps(ctx context.Context, start, stop uint32) ([]RateGap, error) {
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if stop-start >= RateGapsLimit {
+		return nil, fmt.Errorf("range is limited to %d heights", RateGapsLimit)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `WITH RECURSIVE h(height) AS (
+			SELECT $1 UNION ALL SELECT height + 1 FROM h WHERE height < $2
+		)
+		SELECT h.height, COALESCE(g.count, -1) FROM h LEFT JOIN pn_grade g ON g.height = h.height
+		WHERE NOT EXISTS (SELECT 1 FROM pn_rate r WHERE r.height = h.height)
+		ORDER BY h.height ASC`, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := []RateGap{}
+	for rows.Next() {
+		var gap RateGap
+		if err := rows.Scan(&gap.Height, &gap.Submitted); err != nil {
+			return nil, err
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps, rows.Err()
+}
+
 func _extractAssets(rows *sql.Rows) (map[fat2.PTicker]uint64, error) {
 	return _extractAssetsWithPrefix(rows, "")
 }
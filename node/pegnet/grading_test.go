@@ -0,0 +1,74 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectRateGaps(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableGrade())
+
+	height, err := p.SelectLatestRateHeight(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), height)
+
+	// 100, 102 have rates, 101 has too few oprs, 103 has none
+	for _, h := range []uint32{100, 101, 102} {
+		_, err := p.DB.Exec(`INSERT INTO pn_grade (height, count) VALUES (?, ?)`, h, 3)
+		require.NoError(t, err)
+	}
+	for _, h := range []uint32{100, 102} {
+		_, err := p.DB.Exec(`INSERT INTO pn_rate (height, token, value) VALUES (?, 'pUSD', 1)`, h)
+		require.NoError(t, err)
+	}
+
+	height, err = p.SelectLatestRateHeight(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(102), height)
+
+	gaps, err := p.SelectRateGaps(context.Background(), 100, 103)
+	require.NoError(t, err)
+	assert.Equal(t, []RateGap{{Height: 101, Submitted: 3}, {Height: 103, Submitted: -1}}, gaps)
+
+	gaps, err = p.SelectRateGaps(context.Background(), 102, 102)
+	require.NoError(t, err)
+	assert.Empty(t, gaps)
+
+	_, err = p.SelectRateGaps(context.Background(), 0, RateGapsLimit)
+	assert.Error(t, err)
+}
+
+func TestPegnet_InjectRates(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableGrade())
+
+	_, err = p.DB.Exec(`INSERT INTO pn_rate (height, token, value) VALUES (100, 'pUSD', 1), (100, 'PEG', 2), (101, 'pUSD', 3)`)
+	require.NoError(t, err)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	// 101 has graded rates, 103 starts from the rates of 101
+	require.NoError(t, p.InjectRates(tx, 101, map[fat2.PTicker]uint64{fat2.PTickerPEG: 5}))
+	require.NoError(t, p.InjectRates(tx, 103, map[fat2.PTicker]uint64{fat2.PTickerFCT: 7}))
+	require.NoError(t, tx.Commit())
+
+	rates, err := p.SelectRates(context.Background(), 101)
+	require.NoError(t, err)
+	assert.Equal(t, map[fat2.PTicker]uint64{fat2.PTickerUSD: 3, fat2.PTickerPEG: 5}, rates)
+	rates, err = p.SelectRates(context.Background(), 103)
+	require.NoError(t, err)
+	assert.Equal(t, map[fat2.PTicker]uint64{fat2.PTickerUSD: 3, fat2.PTickerPEG: 5, fat2.PTickerFCT: 7}, rates)
+	rates, err = p.SelectRates(context.Background(), 102)
+	require.NoError(t, err)
+	assert.Empty(t, rates)
+}
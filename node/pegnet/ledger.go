@@ -0,0 +1,390 @@
+package pegnet
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	log "github.com/sirupsen/logrus"
+)
+
+// createTableBalanceJournal is a SQL string that creates the
+// "pn_balance_journal" table.
+//
+// The journal holds the resulting balance of an address every time it
+// changes, which allows the balances of all addresses to be reconstructed
+// as of any height. Changes are written with a height of 0 by the triggers on
+// "pn_addresses" and receive their height once the block is finished syncing.
+const createTableBalanceJournal = `CREATE TABLE IF NOT EXISTS "pn_balance_journal" (
+	"height"	INTEGER NOT NULL, -- 0 while the block is being synced
+	"address"	BLOB NOT NULL,
+	"token"		TEXT NOT NULL,
+	"balance"	INTEGER NOT NULL,
+
+	UNIQUE("address", "token", "height")
+);
+CREATE INDEX IF NOT EXISTS "idx_balance_journal_height" ON "pn_balance_journal"("height");
+`
+
+// balanceJournalTriggers creates the triggers that write into the journal.
+// They are created in the migrations, after all balance columns exist.
+var balanceJournalTriggers = ``
+
+// buildBalanceJournalTriggers builds the triggers from the registered assets
+func buildBalanceJournalTriggers() {
+	var sb strings.Builder
+	sb.WriteString(`CREATE TRIGGER IF NOT EXISTS "trg_balance_journal_insert" AFTER INSERT ON "pn_addresses" BEGIN` + "\n")
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		col := strings.ToLower(i.String()) + "_balance"
+		sb.WriteString(fmt.Sprintf(`	INSERT OR REPLACE INTO "pn_balance_journal" ("height", "address", "token", "balance")
+		SELECT 0, NEW."address", '%s', NEW."%s" WHERE NEW."%s" > 0;`+"\n", i.String(), col, col))
+	}
+	sb.WriteString("END;\n")
+
+	// The update triggers fire from the upserts of the balances, which
+	// override the conflict resolution of the statements in the trigger. A
+	// second change of the same address in a block has to replace the
+	// pending row explicitly. Databases with the older triggers get them
+	// recreated.
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		col := strings.ToLower(i.String()) + "_balance"
+		sb.WriteString(fmt.Sprintf(`DROP TRIGGER IF EXISTS "trg_balance_journal_%[1]s";
+CREATE TRIGGER "trg_balance_journal_%[1]s" AFTER UPDATE OF "%[1]s" ON "pn_addresses"
+	WHEN NEW."%[1]s" != OLD."%[1]s" BEGIN
+	DELETE FROM "pn_balance_journal" WHERE "height" = 0 AND "address" = NEW."address" AND "token" = '%[2]s';
+	INSERT INTO "pn_balance_journal" ("height", "address", "token", "balance")
+		VALUES (0, NEW."address", '%[2]s', NEW."%[1]s");
+END;
+`, col, i.String()))
+	}
+	balanceJournalTriggers = sb.String()
+}
+
+// LedgerLimit is the maximum amount of addresses to return in one query
+const LedgerLimit = 1000
+
+// LedgerEntry are the non-zero balances of an address at a height
+type LedgerEntry struct {
+	Address  factom.FAAddress
+	Balances map[fat2.PTicker]uint64
+}
+
+// CreateTableBalanceJournal is used to expose this table and its triggers for
+// unit tests
+func (p *Pegnet) CreateTableBalanceJournal() error {
+	if _, err := p.DB.Exec(createTableBalanceJournal); err != nil {
+		return err
+	}
+	if _, err := p.DB.Exec(balanceJournalTriggers); err != nil {
+		return err
+	}
+	return nil
+}
+
+// balanceJournalMigrate creates the triggers and, for databases that were
+// synced before the journal existed, seeds the journal with the current
+// balances. The ledger is not available below the seeded height.
+func balanceJournalMigrate(p *Pegnet) error {
+	if _, err := p.DB.Exec(balanceJournalTriggers); err != nil {
+		return err
+	}
+
+	var tmp []byte
+	err := p.DB.QueryRow(`SELECT "value" FROM "pn_metadata" WHERE "name" = 'balancejournal';`).Scan(&tmp)
+	if err == nil {
+		return nil // Already set up
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var start uint32
+	synced, err := p.SelectSynced(context.Background(), tx)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if synced != nil && synced.Synced > 0 {
+		start = synced.Synced
+		for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+			col := strings.ToLower(i.String()) + "_balance"
+			_, err := tx.Exec(fmt.Sprintf(`INSERT OR REPLACE INTO "pn_balance_journal" ("height", "address", "token", "balance")
+				SELECT ?, "address", ?, "%[1]s" FROM "pn_addresses" WHERE "%[1]s" > 0;`, col), start, i.String())
+			if err != nil {
+				return err
+			}
+		}
+		log.Infof("Successful DB Migration balanceJournalMigrate seeded at height %d", start)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO "pn_metadata" ("name", "value") VALUES ('balancejournal', ?);`, start); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SelectBalanceJournalStart returns the lowest height the ledger can be
+// reconstructed at
+func (p *Pegnet) SelectBalanceJournalStart() (uint32, error) {
+	return p.selectBalanceJournalStart(p.DB)
+}
+
+// FinalizeBalanceJournal assigns the height to all balance changes made in
+// the pending tx. Must be called after all balances of the height are updated.
+func (p *Pegnet) FinalizeBalanceJournal(tx *sql.Tx, height uint32) error {
+	_, err := tx.Exec(`UPDATE "pn_balance_journal" SET "height" = ? WHERE "height" = 0;`, height)
+	return err
+}
+
+// SelectBalanceMismatches returns the amount of balances of "pn_addresses"
+// that differ from the latest balance of the journal, and of addresses of the
+// journal that have no row. The journal is what the state hash commits to,
+// so a database that was not modified outside of the sync has none.
+func (p *Pegnet) SelectBalanceMismatches(ctx context.Context) (int, error) {
+	var total int
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		col := strings.ToLower(i.String()) + "_balance"
+		// An asset registered after the database was synced has no column
+		if exists, err := p.columnExists("pn_addresses", col); err != nil {
+			return 0, err
+		} else if !exists {
+			continue
+		}
+		var n int
+		err := p.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "pn_addresses" a
+			WHERE a."%s" != COALESCE((SELECT j."balance" FROM "pn_balance_journal" j
+				WHERE j."address" = a."address" AND j."token" = ? ORDER BY j."height" DESC LIMIT 1), 0);`, col),
+			i.String()).Scan(&n)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+
+	var missing int
+	err := p.DB.QueryRowContext(ctx, `SELECT COUNT(DISTINCT j."address") FROM "pn_balance_journal" j
+		WHERE NOT EXISTS (SELECT 1 FROM "pn_addresses" a WHERE a."address" = j."address");`).Scan(&missing)
+	return total + missing, err
+}
+
+// SelectLedger returns the non-zero balances of all addresses at the given
+// height, ordered by address. Only addresses that come after the `after`
+// address are returned, at most `limit` of them. If the ticker is not invalid,
+// only that asset is returned.
+//
+// The last returned value is the address to continue from, nil if there are
+// no more addresses.
+func (p *Pegnet) SelectLedger(ctx context.Context, height uint32, ticker fat2.PTicker, after *factom.FAAddress, limit int) ([]LedgerEntry, *factom.FAAddress, error) {
+	if limit <= 0 || limit > LedgerLimit {
+		limit = LedgerLimit
+	}
+	start, err := p.SelectBalanceJournalStart()
+	if err != nil {
+		return nil, nil, err
+	}
+	if height < start {
+		return nil, nil, fmt.Errorf("the ledger is only available from height %d", start)
+	}
+
+	cursor := []byte{}
+	if after != nil {
+		cursor = after[:]
+	}
+
+	// Select the page of addresses first, so a page never splits the
+	// balances of an address
+	rows, err := p.DB.QueryContext(ctx, `SELECT DISTINCT "address" FROM "pn_balance_journal"
+		WHERE "address" > ? AND "height" > 0 AND "height" <= ? ORDER BY "address" ASC LIMIT ?;`,
+		cursor, height, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	var page [][]byte
+	for rows.Next() {
+		var adr []byte
+		if err := rows.Scan(&adr); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		page = append(page, adr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(page) == 0 {
+		return nil, nil, nil
+	}
+
+	query := `SELECT j."address", j."token", j."balance" FROM "pn_balance_journal" j
+		WHERE j."address" >= ? AND j."address" <= ? AND j."height" = (
+			SELECT MAX("height") FROM "pn_balance_journal"
+			WHERE "address" = j."address" AND "token" = j."token" AND "height" > 0 AND "height" <= ?
+		) AND j."balance" > 0`
+	args := []interface{}{page[0], page[len(page)-1], height}
+	if ticker != fat2.PTickerInvalid {
+		query += ` AND j."token" = ?`
+		args = append(args, ticker.String())
+	}
+	query += ` ORDER BY j."address" ASC;`
+
+	rows, err = p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var res []LedgerEntry
+	for rows.Next() {
+		var adr []byte
+		var token string
+		var balance uint64
+		if err := rows.Scan(&adr, &token, &balance); err != nil {
+			return nil, nil, err
+		}
+
+		if len(res) == 0 || !bytes.Equal(res[len(res)-1].Address[:], adr) {
+			var entry LedgerEntry
+			copy(entry.Address[:], adr)
+			entry.Balances = make(map[fat2.PTicker]uint64)
+			res = append(res, entry)
+		}
+		if t := fat2.StringToTicker(token); t != fat2.PTickerInvalid {
+			res[len(res)-1].Balances[t] = balance
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *factom.FAAddress
+	if len(page) == limit {
+		next = new(factom.FAAddress)
+		copy(next[:], page[len(page)-1])
+	}
+	return res, next, nil
+}
+
+// BalanceHistoryLimit is the maximum amount of points of a balance history
+const BalanceHistoryLimit = 1000
+
+// BalancePoint is the balance of an address in one asset as of a height
+type BalancePoint struct {
+	Height  uint32 `json:"height"`
+	Balance uint64 `json:"balance"`
+}
+
+// SelectBalanceHistory returns the balance of the address in the asset every
+// `interval` heights, ending at stop and not going below start. The points
+// are ordered by height. The balances come from the journal, so start can
+// not be below the height the journal starts at.
+func (p *Pegnet) SelectBalanceHistory(ctx context.Context, adr *factom.FAAddress, ticker fat2.PTicker, start, stop, interval uint32) ([]BalancePoint, error) {
+	if interval == 0 {
+		return nil, fmt.Errorf("invalid interval, must be > 0")
+	}
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+	count := (stop-start)/interval + 1
+	if count > BalanceHistoryLimit {
+		return nil, fmt.Errorf("the history is limited to %d points", BalanceHistoryLimit)
+	}
+	journal, err := p.SelectBalanceJournalStart()
+	if err != nil {
+		return nil, err
+	}
+	if start < journal {
+		return nil, fmt.Errorf("the balance history is only available from height %d", journal)
+	}
+
+	points := make([]BalancePoint, count)
+	for i := range points {
+		points[i].Height = stop - (count-1-uint32(i))*interval
+	}
+
+	// The balance as of the first point, followed by every change after it
+	var balance uint64
+	err = p.DB.QueryRowContext(ctx, `SELECT "balance" FROM "pn_balance_journal"
+		WHERE "address" = ? AND "token" = ? AND "height" > 0 AND "height" <= ?
+		ORDER BY "height" DESC LIMIT 1;`, adr[:], ticker.String(), points[0].Height).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	rows, err := p.DB.QueryContext(ctx, `SELECT "height", "balance" FROM "pn_balance_journal"
+		WHERE "address" = ? AND "token" = ? AND "height" > ? AND "height" <= ?
+		ORDER BY "height" ASC;`, adr[:], ticker.String(), points[0].Height, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	i := 0
+	for rows.Next() {
+		var height uint32
+		var next uint64
+		if err := rows.Scan(&height, &next); err != nil {
+			return nil, err
+		}
+		for ; points[i].Height < height; i++ {
+			points[i].Balance = balance
+		}
+		balance = next
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for ; i < len(points); i++ {
+		points[i].Balance = balance
+	}
+	return points, nil
+}
+
+// SelectRichListAt returns the `count` addresses with the highest balance of
+// the ticker as of the height, reconstructed from the journal
+func (p *Pegnet) SelectRichListAt(ctx context.Context, ticker fat2.PTicker, height uint32, count int) ([]BalancePair, error) {
+	if ticker <= fat2.PTickerInvalid || fat2.PTickerMax <= ticker {
+		return nil, fmt.Errorf("invalid token type")
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("invalid count")
+	}
+	start, err := p.SelectBalanceJournalStart()
+	if err != nil {
+		return nil, err
+	}
+	if height < start {
+		return nil, fmt.Errorf("the rich list is only available from height %d", start)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `SELECT j."address", j."balance" FROM "pn_balance_journal" j
+		WHERE j."token" = ? AND j."height" = (
+			SELECT MAX("height") FROM "pn_balance_journal"
+			WHERE "address" = j."address" AND "token" = j."token" AND "height" > 0 AND "height" <= ?
+		) AND j."balance" > 0
+		ORDER BY j."balance" DESC, j."address" ASC LIMIT ?;`, ticker.String(), height, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []BalancePair
+	for rows.Next() {
+		var pair BalancePair
+		var adr []byte
+		if err := rows.Scan(&adr, &pair.Balance); err != nil {
+			return nil, err
+		}
+		pair.Address = new(factom.FAAddress)
+		copy(pair.Address[:], adr)
+		res = append(res, pair)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,235 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectLedger(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableBalanceJournal())
+
+	adrs := make([]factom.FAAddress, 3)
+	for i := range adrs {
+		adrs[i][0] = byte(i + 1)
+	}
+
+	// height 10: all addresses get 100 PEG, the first also gets 5 pUSD
+	// height 11: the first address sends all PEG to the second
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for i := range adrs {
+		_, err = p.AddToBalance(tx, &adrs[i], fat2.PTickerPEG, 100)
+		require.NoError(t, err)
+	}
+	_, err = p.AddToBalance(tx, &adrs[0], fat2.PTickerUSD, 5)
+	require.NoError(t, err)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	_, txErr, err := p.SubFromBalance(tx, &adrs[0], fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	require.NoError(t, txErr)
+	_, err = p.AddToBalance(tx, &adrs[1], fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 11))
+	require.NoError(t, tx.Commit())
+
+	ledger, next, err := p.SelectLedger(context.Background(), 10, fat2.PTickerInvalid, nil, 0)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	require.Len(t, ledger, 3)
+	assert.Equal(t, adrs[0], ledger[0].Address)
+	assert.Equal(t, map[fat2.PTicker]uint64{fat2.PTickerPEG: 100, fat2.PTickerUSD: 5}, ledger[0].Balances)
+
+	ledger, _, err = p.SelectLedger(context.Background(), 11, fat2.PTickerPEG, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, ledger, 2)
+	assert.Equal(t, adrs[1], ledger[0].Address)
+	assert.Equal(t, uint64(200), ledger[0].Balances[fat2.PTickerPEG])
+	assert.Equal(t, adrs[2], ledger[1].Address)
+
+	// Pagination
+	ledger, next, err = p.SelectLedger(context.Background(), 11, fat2.PTickerInvalid, nil, 2)
+	require.NoError(t, err)
+	require.NotNil(t, next)
+	require.Len(t, ledger, 2)
+	assert.Equal(t, adrs[0], ledger[0].Address)
+	assert.Equal(t, map[fat2.PTicker]uint64{fat2.PTickerUSD: 5}, ledger[0].Balances)
+	ledger, next, err = p.SelectLedger(context.Background(), 11, fat2.PTickerInvalid, next, 2)
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	require.Len(t, ledger, 1)
+	assert.Equal(t, adrs[2], ledger[0].Address)
+}
+
+func TestPegnet_BalanceJournal_SameHeight(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableBalanceJournal())
+
+	var adr factom.FAAddress
+	adr[0] = 1
+
+	// An address that changes several times in one height only keeps the
+	// last balance of the height
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &adr, fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &adr, fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	require.NoError(t, p.AddToBalances(tx, []factom.FAAddress{adr, adr}, fat2.PTickerPEG, []uint64{1, 2}))
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	ledger, _, err := p.SelectLedger(context.Background(), 10, fat2.PTickerPEG, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, ledger, 1)
+	assert.Equal(t, uint64(203), ledger[0].Balances[fat2.PTickerPEG])
+}
+
+func TestPegnet_SelectBalanceMismatches(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableBalanceJournal())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &a, fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &b, fat2.PTickerUSD, 5)
+	require.NoError(t, err)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	n, err := p.SelectBalanceMismatches(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// A balance changed outside of a block is only pending in the journal
+	_, err = p.DB.Exec(`UPDATE "pn_addresses" SET "peg_balance" = 1000 WHERE "address" = ?;`, a[:])
+	require.NoError(t, err)
+	_, err = p.DB.Exec(`DELETE FROM "pn_addresses" WHERE "address" = ?;`, b[:])
+	require.NoError(t, err)
+	n, err = p.SelectBalanceMismatches(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestPegnet_SelectBalanceHistory(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableBalanceJournal())
+
+	var adr factom.FAAddress
+	adr[0] = 1
+
+	// The balance is 100 at 10, 150 at 14, and 0 at 15
+	for _, change := range []struct {
+		Height uint32
+		Delta  int64
+	}{{10, 100}, {14, 50}, {15, -150}} {
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		if change.Delta > 0 {
+			_, err = p.AddToBalance(tx, &adr, fat2.PTickerPEG, uint64(change.Delta))
+			require.NoError(t, err)
+		} else {
+			_, txErr, err := p.SubFromBalance(tx, &adr, fat2.PTickerPEG, uint64(-change.Delta))
+			require.NoError(t, err)
+			require.NoError(t, txErr)
+		}
+		require.NoError(t, p.FinalizeBalanceJournal(tx, change.Height))
+		require.NoError(t, tx.Commit())
+	}
+
+	points, err := p.SelectBalanceHistory(context.Background(), &adr, fat2.PTickerPEG, 8, 17, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []BalancePoint{{9, 0}, {11, 100}, {13, 100}, {15, 0}, {17, 0}}, points)
+
+	points, err = p.SelectBalanceHistory(context.Background(), &adr, fat2.PTickerPEG, 14, 14, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []BalancePoint{{14, 150}}, points)
+
+	points, err = p.SelectBalanceHistory(context.Background(), &adr, fat2.PTickerUSD, 10, 15, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []BalancePoint{{10, 0}, {15, 0}}, points)
+
+	_, err = p.SelectBalanceHistory(context.Background(), &adr, fat2.PTickerPEG, 1, BalanceHistoryLimit+1, 1)
+	assert.Error(t, err)
+	_, err = p.SelectBalanceHistory(context.Background(), &adr, fat2.PTickerPEG, 1, 10, 0)
+	assert.Error(t, err)
+}
+
+func TestPegnet_SelectRichListAt(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableBalanceJournal())
+
+	adrs := make([]factom.FAAddress, 3)
+	for i := range adrs {
+		adrs[i][0] = byte(i + 1)
+	}
+
+	// height 10: the addresses get 100, 200, and 300 PEG
+	// height 11: the third address sends all of its PEG to the first
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for i := range adrs {
+		_, err = p.AddToBalance(tx, &adrs[i], fat2.PTickerPEG, uint64(100*(i+1)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	_, txErr, err := p.SubFromBalance(tx, &adrs[2], fat2.PTickerPEG, 300)
+	require.NoError(t, err)
+	require.NoError(t, txErr)
+	_, err = p.AddToBalance(tx, &adrs[0], fat2.PTickerPEG, 300)
+	require.NoError(t, err)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 11))
+	require.NoError(t, tx.Commit())
+
+	rich, err := p.SelectRichListAt(context.Background(), fat2.PTickerPEG, 10, 2)
+	require.NoError(t, err)
+	require.Len(t, rich, 2)
+	assert.Equal(t, BalancePair{Address: &adrs[2], Balance: 300}, rich[0])
+	assert.Equal(t, BalancePair{Address: &adrs[1], Balance: 200}, rich[1])
+
+	rich, err = p.SelectRichListAt(context.Background(), fat2.PTickerPEG, 11, 10)
+	require.NoError(t, err)
+	require.Len(t, rich, 2)
+	assert.Equal(t, BalancePair{Address: &adrs[0], Balance: 400}, rich[0])
+	assert.Equal(t, BalancePair{Address: &adrs[1], Balance: 200}, rich[1])
+
+	rich, err = p.SelectRichListAt(context.Background(), fat2.PTickerPEG, 9, 10)
+	require.NoError(t, err)
+	assert.Empty(t, rich)
+
+	_, err = p.SelectRichListAt(context.Background(), fat2.PTickerInvalid, 11, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableMalformedBurns is a SQL string that creates the table of the
+// factoid transactions that pay to the burn address but are not a valid
+// burn, so no pFCT was credited. A transaction has a row per input, so every
+// sender can find it by their address.
+const createTableMalformedBurns = `CREATE TABLE IF NOT EXISTS "pn_malformed_burns" (
+	"height"	INTEGER NOT NULL,
+	"txid"		BLOB NOT NULL, -- the factoid transaction id
+	"input"		INTEGER NOT NULL, -- index of the input
+	"address"	BLOB NOT NULL, -- FA address of the input
+	"amount"	INTEGER NOT NULL, -- FCT of the input
+	"timestamp"	INTEGER NOT NULL,
+	"reason"	TEXT NOT NULL,
+	"detail"	TEXT NOT NULL,
+
+	UNIQUE("txid", "input")
+);
+CREATE INDEX IF NOT EXISTS "idx_malformed_burns_height" ON "pn_malformed_burns"("height");
+CREATE INDEX IF NOT EXISTS "idx_malformed_burns_address" ON "pn_malformed_burns"("address");
+`
+
+// BurnProblem is why a transaction to the burn address is not a valid burn
+type BurnProblem string
+
+const (
+	// BurnFCTOutput sends factoids to the burn address instead of burning
+	// them with an entry credit output
+	BurnFCTOutput BurnProblem = "fctoutput"
+	// BurnInputs has more than the single input of a burn
+	BurnInputs BurnProblem = "inputs"
+	// BurnOutputs has outputs besides the entry credit output to the burn
+	// address
+	BurnOutputs BurnProblem = "outputs"
+	// BurnECAmount buys entry credits at the burn address, a burn has an
+	// output amount of 0
+	BurnECAmount BurnProblem = "ecamount"
+)
+
+// MalformedBurn is an input of a transaction to the burn address that was
+// not credited
+type MalformedBurn struct {
+	TxID      factom.Bytes32   `json:"txid"`
+	Input     int              `json:"input"`
+	Height    uint32           `json:"height"`
+	Timestamp time.Time        `json:"timestamp"`
+	Address   factom.FAAddress `json:"address"`
+	Amount    int64            `json:"amount"` // FCT
+	Reason    BurnProblem      `json:"reason"`
+	Detail    string           `json:"detail"`
+}
+
+// CreateTableMalformedBurns is used to expose this table for unit tests
+func (p *Pegnet) CreateTableMalformedBurns() error {
+	_, err := p.DB.Exec(createTableMalformedBurns)
+	return err
+}
+
+// InsertMalformedBurn records every input of the transaction with the reason
+// it is not a valid burn
+func (p *Pegnet) InsertMalformedBurn(tx *sql.Tx, height uint32, burn factom.FactoidTransaction, reason BurnProblem, detail string) error {
+	stmt, err := p.prepare(tx, `INSERT OR IGNORE INTO "pn_malformed_burns"
+		("height", "txid", "input", "address", "amount", "timestamp", "reason", "detail") VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return err
+	}
+	for i, in := range burn.FCTInputs {
+		if _, err := stmt.Exec(height, burn.TransactionID[:], i, in.Address[:], in.Amount,
+			burn.TimestampSalt.Unix(), string(reason), detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectMalformedBurns returns the malformed burns in the height range
+// [start, stop], ordered by height. If adr is not nil, only the inputs of
+// that address are returned. At most QueryLimit burns are returned, starting
+// at the offset. The total number of burns that match is returned as well.
+func (p *Pegnet) SelectMalformedBurns(ctx context.Context, adr *factom.FAAddress, start, stop uint32, offset int) ([]MalformedBurn, int, error) {
+	if stop < start {
+		return nil, 0, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset")
+	}
+
+	where := `"height" >= ? AND "height" <= ?`
+	args := []interface{}{start, stop}
+	if adr != nil {
+		where += ` AND "address" = ?`
+		args = append(args, adr[:])
+	}
+
+	var count int
+	err := p.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM "pn_malformed_burns" WHERE `+where, args...).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT "height", "txid", "input", "address", "amount", "timestamp", "reason", "detail"
+		FROM "pn_malformed_burns" WHERE %s ORDER BY "height" ASC, "txid" ASC, "input" ASC LIMIT %d OFFSET %d`, where, QueryLimit, offset), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	burns, err := scanMalformedBurns(rows)
+	return burns, count, err
+}
+
+// SelectMalformedBurnsAt returns the malformed burns of the height
+func (p *Pegnet) SelectMalformedBurnsAt(q QueryAble, height uint32) ([]MalformedBurn, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(`SELECT "height", "txid", "input", "address", "amount", "timestamp", "reason", "detail"
+		FROM "pn_malformed_burns" WHERE "height" = ? ORDER BY "txid" ASC, "input" ASC;`, height)
+	if err != nil {
+		return nil, err
+	}
+	return scanMalformedBurns(rows)
+}
+
+func scanMalformedBurns(rows *sql.Rows) ([]MalformedBurn, error) {
+	defer rows.Close()
+	var burns []MalformedBurn
+	for rows.Next() {
+		var b MalformedBurn
+		var hash, adr []byte
+		var ts int64
+		var reason string
+		if err := rows.Scan(&b.Height, &hash, &b.Input, &adr, &b.Amount, &ts, &reason, &b.Detail); err != nil {
+			return nil, err
+		}
+		copy(b.TxID[:], hash)
+		copy(b.Address[:], adr)
+		b.Timestamp = time.Unix(ts, 0)
+		b.Reason = BurnProblem(reason)
+		burns = append(burns, b)
+	}
+	return burns, rows.Err()
+}
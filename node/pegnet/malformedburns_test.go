@@ -0,0 +1,60 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_MalformedBurns(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMalformedBurns())
+
+	var a, b, one, two factom.Bytes32
+	a[0], b[0], one[0], two[0] = 1, 2, 1, 2
+	ts := time.Unix(1600000000, 0)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertMalformedBurn(tx, 10, factom.FactoidTransaction{
+		FactoidTransactionHeader: factom.FactoidTransactionHeader{TransactionID: &one, TimestampSalt: ts},
+		FCTInputs:                []factom.FactoidTransactionIO{{Amount: 100, Address: a}, {Amount: 200, Address: b}},
+	}, BurnInputs, "2 inputs"))
+	require.NoError(t, p.InsertMalformedBurn(tx, 12, factom.FactoidTransaction{
+		FactoidTransactionHeader: factom.FactoidTransactionHeader{TransactionID: &two, TimestampSalt: ts},
+		FCTInputs:                []factom.FactoidTransactionIO{{Amount: 300, Address: a}},
+	}, BurnECAmount, "bought entry credits"))
+	require.NoError(t, tx.Commit())
+
+	burns, count, err := p.SelectMalformedBurns(context.Background(), nil, 0, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, burns, 3)
+	assert.Equal(t, MalformedBurn{TxID: one, Input: 1, Height: 10, Timestamp: ts, Address: factom.FAAddress(b),
+		Amount: 200, Reason: BurnInputs, Detail: "2 inputs"}, burns[1])
+
+	adr := factom.FAAddress(a)
+	burns, count, err = p.SelectMalformedBurns(context.Background(), &adr, 11, 100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.Len(t, burns, 1)
+	assert.Equal(t, BurnECAmount, burns[0].Reason)
+	assert.Equal(t, int64(300), burns[0].Amount)
+
+	burns, err = p.SelectMalformedBurnsAt(nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, burns, 2)
+	burns, err = p.SelectMalformedBurnsAt(nil, 11)
+	require.NoError(t, err)
+	assert.Empty(t, burns)
+
+	_, _, err = p.SelectMalformedBurns(context.Background(), nil, 10, 5, 0)
+	assert.Error(t, err)
+}
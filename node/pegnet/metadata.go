@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 )
 
 const createTableMetadata = `CREATE TABLE IF NOT EXISTS "pn_metadata" (
@@ -14,6 +15,15 @@ const createTableMetadata = `CREATE TABLE IF NOT EXISTS "pn_metadata" (
 );
 `
 
+// CreateTableMetadata is used to expose this table for unit tests
+func (p *Pegnet) CreateTableMetadata() error {
+	_, err := p.DB.Exec(createTableMetadata)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 type BlockSync struct {
 	Synced uint32
 }
@@ -55,3 +65,24 @@ func (Pegnet) SelectSynced(ctx context.Context, tx QueryAble) (*BlockSync, error
 
 	return bs, nil
 }
+
+// CheckNetwork records the network of a new database, and fails if the
+// database was synced on another network
+func (p *Pegnet) CheckNetwork(q QueryAble, network string) error {
+	if q == nil {
+		q = p.DB
+	}
+	var stored string
+	err := q.QueryRow(`SELECT "value" FROM "pn_metadata" WHERE "name" = 'network';`).Scan(&stored)
+	if err == sql.ErrNoRows {
+		_, err = q.Exec(`INSERT INTO "pn_metadata" ("name", "value") VALUES ('network', ?);`, network)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if stored != network {
+		return fmt.Errorf("the database was synced on the network %s, not %s", stored, network)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package pegnet_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_CheckNetwork(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+
+	require.NoError(t, p.CheckNetwork(nil, "MainNet"))
+	require.NoError(t, p.CheckNetwork(nil, "MainNet"))
+	require.Error(t, p.CheckNetwork(nil, "MyNet"))
+}
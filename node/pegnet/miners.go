@@ -0,0 +1,209 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pegnet/pegnet/modules/grader"
+)
+
+// createTableMinerBlocks is a SQL string that creates the table of the OPRs
+// every miner submitted per height. A miner is a coinbase address, the rows
+// are split by the identities the address used. Unlike "pn_winners" it
+// includes the valid OPRs that did not make the graded set. Databases synced
+// before the table existed only have the submissions since the upgrade.
+const createTableMinerBlocks = `CREATE TABLE IF NOT EXISTS "pn_miner_blocks" (
+	"height"		INTEGER NOT NULL,
+	"address"		TEXT NOT NULL, -- coinbase address
+	"minerid"		TEXT NOT NULL,
+	"submissions"	INTEGER NOT NULL, -- valid OPRs
+	"difficulty"	BLOB NOT NULL, -- highest self reported difficulty, bigendian 8 bytes like "pn_winners"
+	"graded"		INTEGER NOT NULL, -- OPRs in the graded set
+	"wins"			INTEGER NOT NULL, -- OPRs with a payout
+	"position"		INTEGER, -- best position in the graded set, NULL if none was graded
+	"reward"		INTEGER NOT NULL, -- total payout
+
+	UNIQUE("height", "address", "minerid")
+);
+CREATE INDEX IF NOT EXISTS "idx_miner_blocks_address" ON "pn_miner_blocks"("address", "height");
+`
+
+// MinersLimit is the maximum amount of miners or miner heights that can be
+// queried at once
+const MinersLimit = 1000
+
+// OPRSubmission is a valid OPR of a block, before grading
+type OPRSubmission struct {
+	MinerID string
+	Address string
+	// Difficulty is the self reported difficulty
+	Difficulty uint64
+}
+
+// MinerStats are the totals of a coinbase address. Blocks is the amount of
+// heights the miner submitted at.
+type MinerStats struct {
+	Address        string   `json:"address"`
+	Identities     []string `json:"identities"`
+	Blocks         int      `json:"blocks"`
+	Submissions    int      `json:"submissions"`
+	Graded         int      `json:"graded"`
+	Wins           int      `json:"wins"`
+	Reward         int64    `json:"reward"`
+	BestDifficulty uint64   `json:"bestdifficulty"`
+	FirstHeight    uint32   `json:"firstheight"`
+	LastHeight     uint32   `json:"lastheight"`
+}
+
+// MinerBlock are the submissions of a miner at a height. Position is the best
+// placement in the graded set, 0 is the winner, and is left out if no OPR of
+// the miner was graded.
+type MinerBlock struct {
+	Height      uint32   `json:"height"`
+	Identities  []string `json:"identities"`
+	Submissions int      `json:"submissions"`
+	Difficulty  uint64   `json:"difficulty"`
+	Graded      int      `json:"graded"`
+	Wins        int      `json:"wins"`
+	Position    *int     `json:"position,omitempty"`
+	Reward      int64    `json:"reward"`
+}
+
+// CreateTableMinerBlocks is used to expose this table for unit tests
+func (p *Pegnet) CreateTableMinerBlocks() error {
+	_, err := p.DB.Exec(createTableMinerBlocks)
+	return err
+}
+
+// InsertMinerBlocks adds the submissions of every miner at the height. The
+// graded set only counts if the block has winners, as "pn_winners" does.
+func (p *Pegnet) InsertMinerBlocks(tx *sql.Tx, height uint32, submissions []OPRSubmission, graded grader.GradedBlock) error {
+	type key struct{ address, minerid string }
+	rows := make(map[key]*MinerBlock)
+	var order []key
+	for _, s := range submissions {
+		k := key{s.Address, s.MinerID}
+		r, ok := rows[k]
+		if !ok {
+			r = new(MinerBlock)
+			rows[k] = r
+			order = append(order, k)
+		}
+		r.Submissions++
+		if s.Difficulty > r.Difficulty {
+			r.Difficulty = s.Difficulty
+		}
+	}
+
+	if graded != nil && len(graded.Winners()) > 0 {
+		for _, o := range graded.Graded() {
+			r, ok := rows[key{o.OPR.GetAddress(), o.OPR.GetID()}]
+			if !ok {
+				continue
+			}
+			r.Graded++
+			if o.Payout() > 0 {
+				r.Wins++
+				r.Reward += o.Payout()
+			}
+			if pos := o.Position(); r.Position == nil || pos < *r.Position {
+				r.Position = &pos
+			}
+		}
+	}
+
+	batch := newRowBatch(`INSERT INTO "pn_miner_blocks" ("height", "address", "minerid", "submissions", "difficulty", "graded", "wins", "position", "reward") VALUES`, 9, "")
+	for _, k := range order {
+		r := rows[k]
+		diff := make([]byte, 8)
+		binary.BigEndian.PutUint64(diff, r.Difficulty)
+		batch.add(height, k.address, k.minerid, r.Submissions, diff, r.Graded, r.Wins, r.Position, r.Reward)
+	}
+	return batch.exec(p, tx)
+}
+
+// minerStatsColumns are the aggregates of MinerStats, scanned by scanMinerStats
+const minerStatsColumns = `"address", group_concat(DISTINCT "minerid"), COUNT(DISTINCT "height"),
+	SUM("submissions"), SUM("graded"), SUM("wins"), SUM("reward"), MAX("difficulty"), MIN("height"), MAX("height")`
+
+func scanMinerStats(row interface{ Scan(...interface{}) error }) (MinerStats, error) {
+	var m MinerStats
+	var ids string
+	var diff []byte
+	err := row.Scan(&m.Address, &ids, &m.Blocks, &m.Submissions, &m.Graded, &m.Wins, &m.Reward, &diff, &m.FirstHeight, &m.LastHeight)
+	m.Identities = strings.Split(ids, ",")
+	m.BestDifficulty = difficulty(diff)
+	return m, err
+}
+
+// difficulty decodes a stored difficulty, invalid ones are 0
+func difficulty(data []byte) uint64 {
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// SelectMiner returns the totals of the coinbase address and up to limit of
+// its heights below the height before, newest first. A before of 0 starts at
+// the latest height. If the address never submitted, sql.ErrNoRows is
+// returned.
+func (p *Pegnet) SelectMiner(ctx context.Context, address string, before uint32, limit int) (MinerStats, []MinerBlock, error) {
+	m, err := scanMinerStats(p.DB.QueryRowContext(ctx, `SELECT `+minerStatsColumns+`
+		FROM "pn_miner_blocks" WHERE "address" = ? GROUP BY "address";`, address))
+	if err != nil {
+		return m, nil, err
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `SELECT "height", group_concat(DISTINCT "minerid"), SUM("submissions"),
+		MAX("difficulty"), SUM("graded"), SUM("wins"), MIN("position"), SUM("reward")
+		FROM "pn_miner_blocks" WHERE "address" = ? AND (? = 0 OR "height" < ?)
+		GROUP BY "height" ORDER BY "height" DESC LIMIT ?;`, address, before, before, limit)
+	if err != nil {
+		return m, nil, err
+	}
+	defer rows.Close()
+
+	history := []MinerBlock{}
+	for rows.Next() {
+		var b MinerBlock
+		var ids string
+		var diff []byte
+		var position sql.NullInt64
+		if err := rows.Scan(&b.Height, &ids, &b.Submissions, &diff, &b.Graded, &b.Wins, &position, &b.Reward); err != nil {
+			return m, nil, err
+		}
+		b.Identities = strings.Split(ids, ",")
+		b.Difficulty = difficulty(diff)
+		if position.Valid {
+			pos := int(position.Int64)
+			b.Position = &pos
+		}
+		history = append(history, b)
+	}
+	return m, history, rows.Err()
+}
+
+// SelectMiners returns the totals of up to limit miners in the height range
+// [start, stop], ordered by reward and then by submissions
+func (p *Pegnet) SelectMiners(ctx context.Context, start, stop uint32, limit int) ([]MinerStats, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT `+minerStatsColumns+`
+		FROM "pn_miner_blocks" WHERE "height" >= ? AND "height" <= ? GROUP BY "address"
+		ORDER BY SUM("reward") DESC, SUM("submissions") DESC, "address" ASC LIMIT ?;`, start, stop, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	miners := []MinerStats{}
+	for rows.Next() {
+		m, err := scanMinerStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		miners = append(miners, m)
+	}
+	return miners, rows.Err()
+}
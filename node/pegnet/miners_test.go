@@ -0,0 +1,76 @@
+package pegnet_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"testing"
+
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_MinerBlocks(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMinerBlocks())
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	// Without winners the graded set does not count
+	require.NoError(t, p.InsertMinerBlocks(tx, 100, []OPRSubmission{
+		{MinerID: "alice", Address: "FA-alice", Difficulty: 5},
+		{MinerID: "alice", Address: "FA-alice", Difficulty: 9},
+		{MinerID: "alice2", Address: "FA-alice", Difficulty: 7},
+		{MinerID: "bob", Address: "FA-bob", Difficulty: 3},
+	}, nil))
+	require.NoError(t, tx.Commit())
+
+	diff := make([]byte, 8)
+	binary.BigEndian.PutUint64(diff, 1<<40)
+	_, err = p.DB.Exec(`INSERT INTO pn_miner_blocks (height, address, minerid, submissions, difficulty, graded, wins, position, reward)
+		VALUES (101, 'FA-bob', 'bob', 2, ?, 2, 1, 0, 800), (101, 'FA-bob', 'bob2', 1, ?, 1, 0, 30, 0)`, diff, diff)
+	require.NoError(t, err)
+
+	m, history, err := p.SelectMiner(context.Background(), "FA-alice", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Blocks)
+	assert.Equal(t, 3, m.Submissions)
+	assert.Equal(t, uint64(9), m.BestDifficulty)
+	assert.ElementsMatch(t, []string{"alice", "alice2"}, m.Identities)
+	require.Len(t, history, 1)
+	assert.Equal(t, uint64(9), history[0].Difficulty)
+	assert.Nil(t, history[0].Position)
+
+	m, history, err = p.SelectMiner(context.Background(), "FA-bob", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, MinerStats{Address: "FA-bob", Identities: m.Identities, Blocks: 2, Submissions: 4, Graded: 3, Wins: 1,
+		Reward: 800, BestDifficulty: 1 << 40, FirstHeight: 100, LastHeight: 101}, m)
+	require.Len(t, history, 2)
+	assert.Equal(t, uint32(101), history[0].Height)
+	require.NotNil(t, history[0].Position)
+	assert.Equal(t, 0, *history[0].Position)
+	assert.Equal(t, 3, history[0].Submissions)
+	assert.Equal(t, int64(800), history[0].Reward)
+
+	_, history, err = p.SelectMiner(context.Background(), "FA-bob", 101, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, uint32(100), history[0].Height)
+
+	_, _, err = p.SelectMiner(context.Background(), "FA-carol", 0, 10)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	miners, err := p.SelectMiners(context.Background(), 0, 200, 10)
+	require.NoError(t, err)
+	require.Len(t, miners, 2)
+	assert.Equal(t, "FA-bob", miners[0].Address)
+	assert.Equal(t, "FA-alice", miners[1].Address)
+
+	miners, err = p.SelectMiners(context.Background(), 101, 200, 10)
+	require.NoError(t, err)
+	require.Len(t, miners, 1)
+	assert.Equal(t, 3, miners[0].Submissions)
+}
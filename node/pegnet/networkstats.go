@@ -0,0 +1,230 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableNetworkStats is a SQL string that creates the tables holding
+// the daily network aggregates. A day is the number of days since the unix
+// epoch of the dblock timestamp.
+//
+// The aggregates are computed from the history of every synced height, so
+// databases synced before the tables existed only have the statistics since
+// the upgrade.
+const createTableNetworkStats = `CREATE TABLE IF NOT EXISTS "pn_network_stats" (
+	"day"			INTEGER PRIMARY KEY,
+	"transactions"	INTEGER NOT NULL DEFAULT 0, -- all executed actions
+	"transfers"		INTEGER NOT NULL DEFAULT 0,
+	"conversions"	INTEGER NOT NULL DEFAULT 0,
+	"coinbases"		INTEGER NOT NULL DEFAULT 0,
+	"burns"			INTEGER NOT NULL DEFAULT 0,
+	"burned"		INTEGER NOT NULL DEFAULT 0 -- FCT burned
+);
+
+CREATE TABLE IF NOT EXISTS "pn_network_asset_stats" (
+	"day"				INTEGER NOT NULL,
+	"token"				TEXT NOT NULL,
+	"transfer_volume"	INTEGER NOT NULL DEFAULT 0,
+	"converted_in"		INTEGER NOT NULL DEFAULT 0,
+	"converted_out"		INTEGER NOT NULL DEFAULT 0, -- includes refunds of PEG conversions
+
+	PRIMARY KEY("day", "token")
+);
+
+CREATE TABLE IF NOT EXISTS "pn_network_active" (
+	"day"		INTEGER NOT NULL,
+	"address"	BLOB NOT NULL,
+
+	PRIMARY KEY("day", "address")
+);
+`
+
+// NetworkStatsLimit is the maximum amount of days that can be queried at once
+const NetworkStatsLimit = 1000
+
+// NetworkAssetStats are the volumes of one asset in a period
+type NetworkAssetStats struct {
+	TransferVolume int64 `json:"transfervolume"`
+	ConvertedIn    int64 `json:"convertedin"`
+	ConvertedOut   int64 `json:"convertedout"`
+}
+
+// NetworkStats are the network aggregates of a period
+type NetworkStats struct {
+	// Start is the first day of the period
+	Start           time.Time
+	Transactions    int64
+	Transfers       int64
+	Conversions     int64
+	Coinbases       int64
+	Burns           int64
+	Burned          int64
+	ActiveAddresses int64
+	Assets          map[fat2.PTicker]NetworkAssetStats
+}
+
+// CreateTableNetworkStats is used to expose this table for unit tests
+func (p *Pegnet) CreateTableNetworkStats() error {
+	_, err := p.DB.Exec(createTableNetworkStats)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnixDay returns the day of the timestamp used by the network statistics
+func UnixDay(t time.Time) int64 {
+	return t.Unix() / 86400
+}
+
+// InsertNetworkStats adds everything that was executed at the given height
+// to the aggregates of the day of the timestamp. Must be called after all
+// actions of the height are recorded in the history.
+func (p *Pegnet) InsertNetworkStats(tx *sql.Tx, height uint32, timestamp time.Time) error {
+	day := UnixDay(timestamp)
+
+	// The "WHERE true" is needed by sqlite to parse the upsert of a select
+	_, err := tx.Exec(fmt.Sprintf(`INSERT INTO "pn_network_stats"
+		("day", "transactions", "transfers", "conversions", "coinbases", "burns", "burned")
+		SELECT ?, COUNT(*),
+			IFNULL(SUM(tx.action_type = %[1]d), 0),
+			IFNULL(SUM(tx.action_type = %[2]d), 0),
+			IFNULL(SUM(tx.action_type = %[3]d), 0),
+			IFNULL(SUM(tx.action_type = %[4]d), 0),
+			IFNULL(SUM(CASE WHEN tx.action_type = %[4]d THEN tx.from_amount ELSE 0 END), 0)
+		FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ? AND true
+		ON CONFLICT("day") DO UPDATE SET
+			"transactions" = "transactions" + "excluded"."transactions",
+			"transfers" = "transfers" + "excluded"."transfers",
+			"conversions" = "conversions" + "excluded"."conversions",
+			"coinbases" = "coinbases" + "excluded"."coinbases",
+			"burns" = "burns" + "excluded"."burns",
+			"burned" = "burned" + "excluded"."burned";`,
+		Transfer, Conversion, Coinbase, FCTBurn), day, height)
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range []struct {
+		column, asset string
+		amount        string
+		action        HistoryAction
+	}{
+		{"transfer_volume", "from_asset", "from_amount", Transfer},
+		{"converted_out", "from_asset", "from_amount", Conversion},
+		{"converted_in", "to_asset", "to_amount", Conversion},
+	} {
+		_, err := tx.Exec(fmt.Sprintf(`INSERT INTO "pn_network_asset_stats" ("day", "token", "%[1]s")
+			SELECT ?, tx.%[2]s, SUM(tx.%[3]s)
+			FROM pn_history_txbatch batch, pn_history_transaction tx
+			WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ? AND tx.action_type = ?
+			GROUP BY tx.%[2]s
+			ON CONFLICT("day", "token") DO UPDATE SET "%[1]s" = "%[1]s" + "excluded"."%[1]s";`,
+			volume.column, volume.asset, volume.amount), day, height, volume.action)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Miners receiving a coinbase are not counted as active
+	_, err = tx.Exec(`INSERT OR IGNORE INTO "pn_network_active" ("day", "address")
+		SELECT DISTINCT ?, lookup.address
+		FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.executed = ? AND batch.entry_hash = tx.entry_hash AND tx.action_type != ?
+			AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index;`,
+		day, height, Coinbase)
+	return err
+}
+
+// SelectNetworkStats returns the aggregates of all days in the range
+// [start, stop], either per day or per week. Weeks start on monday and
+// are only partially included if the range does not cover the full week.
+func (p *Pegnet) SelectNetworkStats(ctx context.Context, start, stop int64, weekly bool) ([]NetworkStats, error) {
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if stop-start >= NetworkStatsLimit {
+		return nil, fmt.Errorf("range is limited to %d days", NetworkStatsLimit)
+	}
+
+	// unix day 0 is a thursday
+	period := `"day"`
+	if weekly {
+		period = `("day" + 3) / 7 * 7 - 3`
+	}
+
+	var res []NetworkStats
+	index := make(map[int64]int)
+	get := func(day int64) *NetworkStats {
+		if i, ok := index[day]; ok {
+			return &res[i]
+		}
+		index[day] = len(res)
+		res = append(res, NetworkStats{Start: time.Unix(day*86400, 0).UTC(), Assets: make(map[fat2.PTicker]NetworkAssetStats)})
+		return &res[len(res)-1]
+	}
+
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s AS "period", SUM("transactions"), SUM("transfers"),
+		SUM("conversions"), SUM("coinbases"), SUM("burns"), SUM("burned")
+		FROM "pn_network_stats" WHERE "day" >= ? AND "day" <= ? GROUP BY "period" ORDER BY "period" ASC;`, period), start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day int64
+		var s NetworkStats
+		if err := rows.Scan(&day, &s.Transactions, &s.Transfers, &s.Conversions, &s.Coinbases, &s.Burns, &s.Burned); err != nil {
+			return nil, err
+		}
+		stats := get(day)
+		s.Start, s.Assets = stats.Start, stats.Assets
+		*stats = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	assetRows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s AS "period", "token", SUM("transfer_volume"),
+		SUM("converted_in"), SUM("converted_out")
+		FROM "pn_network_asset_stats" WHERE "day" >= ? AND "day" <= ? GROUP BY "period", "token";`, period), start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer assetRows.Close()
+	for assetRows.Next() {
+		var day int64
+		var token string
+		var a NetworkAssetStats
+		if err := assetRows.Scan(&day, &token, &a.TransferVolume, &a.ConvertedIn, &a.ConvertedOut); err != nil {
+			return nil, err
+		}
+		if t := fat2.StringToTicker(token); t != fat2.PTickerInvalid {
+			get(day).Assets[t] = a
+		}
+	}
+	if err := assetRows.Err(); err != nil {
+		return nil, err
+	}
+
+	activeRows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s AS "period", COUNT(DISTINCT "address")
+		FROM "pn_network_active" WHERE "day" >= ? AND "day" <= ? GROUP BY "period";`, period), start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer activeRows.Close()
+	for activeRows.Next() {
+		var day, active int64
+		if err := activeRows.Scan(&day, &active); err != nil {
+			return nil, err
+		}
+		get(day).ActiveAddresses = active
+	}
+	return res, activeRows.Err()
+}
@@ -0,0 +1,72 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_NetworkStats(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableNetworkStats())
+
+	// 2020-01-06 is a monday
+	monday := time.Date(2020, 1, 6, 12, 0, 0, 0, time.UTC)
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+
+	for i, day := range []time.Time{monday, monday.Add(24 * time.Hour), monday.Add(7 * 24 * time.Hour)} {
+		height := uint32(100 + i)
+		var hash factom.Bytes32
+		hash[0] = byte(i + 1)
+		batch := &fat2.TransactionBatch{
+			Entry: factom.Entry{Hash: &hash, Timestamp: day},
+			Transactions: []fat2.Transaction{
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+					Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}},
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+					Conversion: fat2.PTickerUSD},
+			},
+		}
+
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, height))
+		require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, int64(height)))
+		require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 1, 20, 4, 1))
+		require.NoError(t, p.InsertNetworkStats(tx, height, day))
+		require.NoError(t, tx.Commit())
+	}
+
+	stats, err := p.SelectNetworkStats(context.Background(), UnixDay(monday), UnixDay(monday)+7, false)
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	assert.Equal(t, monday.Truncate(24*time.Hour), stats[0].Start)
+	assert.Equal(t, int64(2), stats[0].Transactions)
+	assert.Equal(t, int64(1), stats[0].Transfers)
+	assert.Equal(t, int64(1), stats[0].Conversions)
+	assert.Equal(t, int64(2), stats[0].ActiveAddresses)
+	assert.Equal(t, NetworkAssetStats{TransferVolume: 10, ConvertedOut: 5}, stats[0].Assets[fat2.PTickerPEG])
+	assert.Equal(t, NetworkAssetStats{ConvertedIn: 20}, stats[0].Assets[fat2.PTickerUSD])
+
+	stats, err = p.SelectNetworkStats(context.Background(), UnixDay(monday), UnixDay(monday)+7, true)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, monday.Truncate(24*time.Hour), stats[0].Start)
+	assert.Equal(t, int64(4), stats[0].Transactions)
+	assert.Equal(t, int64(2), stats[0].ActiveAddresses)
+	assert.Equal(t, NetworkAssetStats{TransferVolume: 20, ConvertedOut: 10}, stats[0].Assets[fat2.PTickerPEG])
+	assert.Equal(t, int64(2), stats[1].Transactions)
+
+	_, err = p.SelectNetworkStats(context.Background(), 10, 5, false)
+	assert.Error(t, err)
+}
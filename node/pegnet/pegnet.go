@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -19,6 +20,12 @@ type Pegnet struct {
 
 	// This is the sqlite db to store state
 	DB *sql.DB
+
+	// stmts caches the prepared statements of the hot queries, see prepare
+	stmts *statementCache
+
+	// cipher encrypts the private columns, nil if they are not encrypted
+	cipher *fieldCipher
 }
 
 func New(conf *viper.Viper) *Pegnet {
@@ -27,9 +34,11 @@ func New(conf *viper.Viper) *Pegnet {
 	return p
 }
 
-func (p *Pegnet) Init() error {
+// DBPath is the file of the database of the config, the dbpath with its
+// environment variables expanded and the version suffix
+func DBPath(conf *viper.Viper) string {
 	// The path should contain a $HOME env variable.
-	rawpath := p.Config.GetString(config.SqliteDBPath)
+	rawpath := conf.GetString(config.SqliteDBPath)
 	if runtime.GOOS == "windows" {
 		rawpath = strings.Replace(rawpath, "$HOME", "$USERPROFILE", -1)
 	}
@@ -37,7 +46,11 @@ func (p *Pegnet) Init() error {
 	// TODO: Come up with actual migrations.
 	// 		until then, we can just bump this version number
 	//		and make the database reset when we need to.
-	path += ".v4"
+	return path + ".v4"
+}
+
+func (p *Pegnet) Init() error {
+	path := DBPath(p.Config)
 
 	// Ensure the path exists
 	dir := filepath.Dir(path)
@@ -51,13 +64,26 @@ func (p *Pegnet) Init() error {
 	if p.Config.GetBool(config.SQLDBWalMode) {
 		modes += "_journal=WAL&"
 	}
-	modes += p.Config.GetString(config.CustomSQLDBMode)
-	if modes != "" {
-		openmode += "?" + modes
+	custom := p.Config.GetString(config.CustomSQLDBMode)
+	modes += custom
+	// The driver defaults to synchronous=NORMAL, which can corrupt a
+	// database with a rollback journal on power loss
+	query, _ := url.ParseQuery(custom)
+	if query.Get("_sync") == "" && query.Get("_synchronous") == "" {
+		if modes != "" && !strings.HasSuffix(modes, "&") {
+			modes += "&"
+		}
+		modes += "_sync=FULL"
+	}
+	if !crashSafe(query) {
+		log.Warnf("The db mode %q is not crash safe, the database can be corrupted on power loss", custom)
 	}
+	openmode += "?" + modes
+
+	setSlowQuery(p.Config.GetDuration(config.SlowQuery))
 
 	log.Infof("Opening database from '%s'", path)
-	db, err := sql.Open("sqlite3", openmode)
+	db, err := sql.Open(tracedDriverName, openmode)
 	if err != nil {
 		return err
 	}
@@ -66,23 +92,82 @@ func (p *Pegnet) Init() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	key, err := ColumnKey(p.Config)
+	if err != nil {
+		return err
+	}
+	return p.initEncryption(key)
+}
+
+// crashSafe returns false if the sqlite options of the db mode turn off the
+// atomic commit of the transactions on power loss
+func crashSafe(query url.Values) bool {
+	for _, key := range []string{"_sync", "_synchronous"} {
+		if v := strings.ToUpper(query.Get(key)); v == "OFF" || v == "0" {
+			return false
+		}
+	}
+	for _, key := range []string{"_journal", "_journal_mode"} {
+		if v := strings.ToUpper(query.Get(key)); v == "OFF" || v == "MEMORY" {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	buildAssetQueries()
+}
+
+// buildAssetQueries builds the queries that list every asset. They are
+// rebuilt when the database is opened to include the assets registered from
+// the config.
+func buildAssetQueries() {
+	buildAddressQueries()
+	buildBalanceJournalTriggers()
+}
+
+// CreateTables is used to expose all tables and their migrations for unit
+// tests
+func (p *Pegnet) CreateTables() error {
+	return p.createTables()
 }
 
 func (p *Pegnet) createTables() error {
+	buildAssetQueries()
 	for _, sql := range []string{
 		createTableAddresses,
 		createTableGrade,
 		createTableRate,
 		createTableMetadata,
 		createTableWinners,
+		createTableWinnerRates,
+		createTableMinerBlocks,
 		createTableTransactions,
 		createTableTransactionBatchHolding,
 		createTableTxHistoryBatch,
 		createTableTxHistoryTx,
 		createTableTxHistoryLookup,
+		createTableTxHistoryRefund,
+		createTableTxRejection,
 		createTableSyncVersion,
 		createTableBank,
+		createTableSupplyHistory,
+		createTableSupplyTotals,
+		createTableBalanceJournal,
+		createTableAddressStats,
+		createTableNetworkStats,
+		createTableConversionVolume,
+		createTableBlocks,
+		createTableDeposits,
+		createTableAlerts,
+		createTableSchedules,
+		createTableEthAddresses,
+		createTableSendAudit,
+		createTableWatchAddresses,
+		createTablePolicySpends,
+		createTablePriceChecks,
+		createTableMalformedBurns,
 	} {
 		if _, err := p.DB.Exec(sql); err != nil {
 			return fmt.Errorf("createTables: %v", err)
@@ -102,24 +187,46 @@ func (p *Pegnet) migrations() error {
 	// migrate pn_history_lookup alter column
 	txhistoryMigrateLookup1(p)
 
-	v4Migrate, err := p.v4MigrationNeeded()
-	if err != nil {
+	if err := txhistoryMigrateRates(p); err != nil {
 		return err
 	}
-	if v4Migrate {
-		log.Infof("Running v4 migrations")
-		for _, sql := range []string{
-			addressTableV4Migration,
-		} {
-			if _, err := p.DB.Exec(sql); err != nil {
-				return err
-			}
-		}
+	if err := txhistoryMigrateMetadata(p); err != nil {
+		return err
+	}
+	if err := txhistoryMigrateTxID(p); err != nil {
+		return err
+	}
+
+	if err := addressColumnsMigrate(p); err != nil {
+		return err
+	}
+
+	// The journal triggers and balance indexes depend on all balance
+	// columns existing
+	if err := balanceJournalMigrate(p); err != nil {
+		return err
+	}
+	if _, err := p.DB.Exec(addressBalanceIndexes); err != nil {
+		return err
+	}
+
+	if err := supplyTotalsMigrate(p); err != nil {
+		return err
+	}
+
+	if err := privateColumnsMigrate(p); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// columnExists returns true if the column is part of the table
+func (p *Pegnet) columnExists(table, column string) (exists bool, err error) {
+	err = p.DB.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?;`, table, column).Scan(&exists)
+	return
+}
+
 // QueryAble is so we can swap db and tx interactions
 type QueryAble interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
@@ -0,0 +1,293 @@
+package pegnet
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTablePolicySpends is a SQL string that creates the table of the
+// amounts the node submitted for the inputs with a spend limit. The spends
+// of the last day count against the daily limits.
+const createTablePolicySpends = `CREATE TABLE IF NOT EXISTS "pn_policy_spends" (
+	"id"			INTEGER PRIMARY KEY,
+	"time"			INTEGER NOT NULL, -- unix seconds
+	"address"		BLOB NOT NULL,
+	"token"			TEXT NOT NULL,
+	"amount"		INTEGER NOT NULL,
+	"entry_hash"	BLOB NOT NULL,
+	-- with a column key, see privateTable
+	"sealed_address"	TEXT NOT NULL DEFAULT '',
+	"sealed_token"		TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS "idx_policy_spends_address" ON "pn_policy_spends"("address", "token", "time");
+`
+
+// PolicyWindow is the period of the daily spend limits
+const PolicyWindow = 24 * time.Hour
+
+// Policy restricts what the node signs and submits for the addresses of a
+// signing key, eg: "payroll daily=10000:pUSD assets=pUSD to=FA2...,staff".
+// The daily limits are amounts of the input asset per 24 hours, counting
+// transfers and conversions. The destinations only apply to transfers, the
+// assets to both the input and the conversion asset.
+type Policy struct {
+	Rule   string
+	Daily  map[string]uint64 // in factoshis, per asset
+	Assets map[string]bool   // nil allows every asset
+	To     map[factom.FAAddress]bool
+}
+
+// PolicyViolation is the error of a transaction a policy does not allow
+type PolicyViolation struct {
+	Address factom.FAAddress
+	Rule    string
+	Reason  string
+}
+
+func (v PolicyViolation) Error() string {
+	return fmt.Sprintf("the policy %q of %s: %s", v.Rule, v.Address, v.Reason)
+}
+
+// Policies are the policies of the signing keys by address
+type Policies struct {
+	byAddress map[factom.FAAddress]*Policy
+}
+
+// NewPolicies parses the policies of the form
+// "<address|account> [daily=<amount>:<asset>...] [assets=<asset>,...] [to=<address|account>,...]".
+// The policy of an account applies to each of its addresses, an address can
+// only have one policy.
+func NewPolicies(policies []string, accounts *Accounts) (*Policies, error) {
+	p := &Policies{byAddress: make(map[factom.FAAddress]*Policy)}
+	for _, str := range policies {
+		targets, policy, err := parsePolicy(str, accounts)
+		if err != nil {
+			return nil, err
+		}
+		for _, adr := range targets {
+			if other, ok := p.byAddress[adr]; ok {
+				return nil, fmt.Errorf("policy %q: %s has the policy %q already", str, adr, other.Rule)
+			}
+			p.byAddress[adr] = policy
+		}
+	}
+	return p, nil
+}
+
+func parsePolicy(str string, accounts *Accounts) ([]factom.FAAddress, *Policy, error) {
+	fields := strings.Fields(str)
+	if len(fields) < 2 {
+		return nil, nil, fmt.Errorf("policy %q: expected '<address|account> <restriction>...'", str)
+	}
+	targets, err := accounts.addresses(fields[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("policy %q: %v", str, err)
+	}
+
+	policy := &Policy{Rule: strings.Join(fields, " "), Daily: make(map[string]uint64)}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, nil, fmt.Errorf("policy %q: expected key=value, got %q", str, field)
+		}
+		switch kv[0] {
+		case "daily":
+			parts := strings.SplitN(kv[1], ":", 2)
+			if len(parts) != 2 || fat2.StringToTicker(parts[1]) == fat2.PTickerInvalid {
+				return nil, nil, fmt.Errorf("policy %q: expected daily=<amount>:<asset>, got %q", str, field)
+			}
+			amount, err := parseFactoshis(parts[0])
+			if err != nil {
+				return nil, nil, fmt.Errorf("policy %q: %v", str, err)
+			}
+			policy.Daily[fat2.StringToTicker(parts[1]).String()] = amount
+		case "assets":
+			policy.Assets = make(map[string]bool)
+			for _, asset := range strings.Split(kv[1], ",") {
+				ticker := fat2.StringToTicker(asset)
+				if ticker == fat2.PTickerInvalid {
+					return nil, nil, fmt.Errorf("policy %q: unknown asset %q", str, asset)
+				}
+				policy.Assets[ticker.String()] = true
+			}
+		case "to":
+			policy.To = make(map[factom.FAAddress]bool)
+			for _, to := range strings.Split(kv[1], ",") {
+				addresses, err := accounts.addresses(to)
+				if err != nil {
+					return nil, nil, fmt.Errorf("policy %q: %v", str, err)
+				}
+				for _, adr := range addresses {
+					policy.To[adr] = true
+				}
+			}
+		default:
+			return nil, nil, fmt.Errorf("policy %q: unknown restriction %q", str, kv[0])
+		}
+	}
+	return targets, policy, nil
+}
+
+// parseFactoshis parses a decimal amount into factoshis
+func parseFactoshis(str string) (uint64, error) {
+	amount, ok := new(big.Rat).SetString(str)
+	if !ok || amount.Sign() < 0 {
+		return 0, fmt.Errorf("invalid amount %q", str)
+	}
+	amount.Mul(amount, big.NewRat(1e8, 1))
+	if !amount.IsInt() || !amount.Num().IsUint64() {
+		return 0, fmt.Errorf("amount %q has too many decimals", str)
+	}
+	return amount.Num().Uint64(), nil
+}
+
+// Of returns the policy of the address, nil if it has none
+func (p *Policies) Of(adr factom.FAAddress) *Policy {
+	if p == nil {
+		return nil
+	}
+	return p.byAddress[adr]
+}
+
+// Empty is true if no address has a policy
+func (p *Policies) Empty() bool {
+	return p == nil || len(p.byAddress) == 0
+}
+
+// PolicySpend is an amount spent from an address with a policy
+type PolicySpend struct {
+	Address factom.FAAddress
+	Asset   string
+	Amount  uint64
+}
+
+// Check returns a PolicyViolation if a policy does not allow a transaction.
+// The spent func returns what the address spent of the asset during the
+// window. Otherwise the spends that count against the daily limits are
+// returned, to be recorded once the transactions are submitted.
+func (p *Policies) Check(txs []fat2.Transaction, spent func(adr factom.FAAddress, asset string) (uint64, error)) ([]PolicySpend, error) {
+	if p.Empty() {
+		return nil, nil
+	}
+
+	type key struct {
+		adr   factom.FAAddress
+		asset string
+	}
+	totals := make(map[key]uint64)
+	var order []key
+	for _, tx := range txs {
+		policy := p.Of(tx.Input.Address)
+		if policy == nil {
+			continue
+		}
+		violation := func(format string, args ...interface{}) error {
+			return PolicyViolation{Address: tx.Input.Address, Rule: policy.Rule, Reason: fmt.Sprintf(format, args...)}
+		}
+
+		asset := tx.Input.Type.String()
+		if policy.Assets != nil && !policy.Assets[asset] {
+			return nil, violation("%s is not an allowed asset", asset)
+		}
+		if tx.IsConversion() && policy.Assets != nil && !policy.Assets[tx.Conversion.String()] {
+			return nil, violation("%s is not an allowed asset", tx.Conversion)
+		}
+		if policy.To != nil {
+			for _, transfer := range tx.Transfers {
+				if !policy.To[transfer.Address] {
+					return nil, violation("%s is not an allowed destination", transfer.Address)
+				}
+			}
+		}
+
+		if _, ok := policy.Daily[asset]; ok {
+			k := key{tx.Input.Address, asset}
+			if _, ok := totals[k]; !ok {
+				order = append(order, k)
+			}
+			totals[k] += tx.Input.Amount
+		}
+	}
+
+	var spends []PolicySpend
+	for _, k := range order {
+		policy := p.Of(k.adr)
+		before, err := spent(k.adr, k.asset)
+		if err != nil {
+			return nil, err
+		}
+		if limit := policy.Daily[k.asset]; before+totals[k] > limit {
+			return nil, PolicyViolation{Address: k.adr, Rule: policy.Rule,
+				Reason: fmt.Sprintf("the daily limit of %d %s factoshis would be exceeded, %d were spent already", limit, k.asset, before)}
+		}
+		spends = append(spends, PolicySpend{Address: k.adr, Asset: k.asset, Amount: totals[k]})
+	}
+	return spends, nil
+}
+
+// CreateTablePolicySpends is used to expose this table for unit tests
+func (p *Pegnet) CreateTablePolicySpends() error {
+	_, err := p.DB.Exec(createTablePolicySpends)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertPolicySpends records the spends of the submitted entry
+func (p *Pegnet) InsertPolicySpends(q QueryAble, at time.Time, hash factom.Bytes32, spends []PolicySpend) error {
+	if q == nil {
+		q = p.DB
+	}
+	for _, s := range spends {
+		adr, sealedAdr, err := p.index("pn_policy_spends.address", s.Address[:])
+		if err != nil {
+			return err
+		}
+		token, sealedToken, err := p.index("pn_policy_spends.token", s.Asset)
+		if err != nil {
+			return err
+		}
+		private, err := p.sealAll("pn_policy_spends", []string{"amount", "entry_hash"}, s.Amount, hash[:])
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec(`INSERT INTO "pn_policy_spends"
+			("time", "address", "sealed_address", "token", "sealed_token", "amount", "entry_hash") VALUES (?, ?, ?, ?, ?, ?, ?);`,
+			at.Unix(), adr, sealedAdr, token, sealedToken, private[0], private[1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectPolicySpent returns the amount of the asset spent from the address
+// at or after since
+func (p *Pegnet) SelectPolicySpent(q QueryAble, adr factom.FAAddress, asset string, since time.Time) (uint64, error) {
+	if q == nil {
+		q = p.DB
+	}
+	// The amounts can be encrypted, they are summed once decrypted
+	rows, err := q.Query(`SELECT "amount" FROM "pn_policy_spends" WHERE "address" = ? AND "token" = ? AND "time" >= ?;`,
+		p.indexKey("pn_policy_spends.address", adr[:]), p.indexKey("pn_policy_spends.token", asset), since.Unix())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var spent uint64
+	for rows.Next() {
+		var amount uint64
+		if err := rows.Scan(p.sealed("pn_policy_spends.amount", &amount)); err != nil {
+			return 0, err
+		}
+		spent += amount
+	}
+	return spent, rows.Err()
+}
@@ -0,0 +1,122 @@
+package pegnet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPolicies(t *testing.T) {
+	accounts, err := NewAccounts([]string{"payroll " + accountAddrA + " " + accountAddrB, "staff " + accountAddrC}, nil)
+	require.NoError(t, err)
+
+	p, err := NewPolicies([]string{"payroll daily=10.5:pUSD daily=1:PEG assets=pUSD,PEG to=staff"}, accounts)
+	require.NoError(t, err)
+	a, _ := factom.NewFAAddress(accountAddrA)
+	c, _ := factom.NewFAAddress(accountAddrC)
+	policy := p.Of(a)
+	require.NotNil(t, policy)
+	assert.Equal(t, uint64(1050000000), policy.Daily["pUSD"])
+	assert.Equal(t, uint64(100000000), policy.Daily["PEG"])
+	assert.True(t, policy.Assets["PEG"])
+	assert.True(t, policy.To[c])
+	assert.Nil(t, p.Of(c))
+
+	for _, bad := range []string{
+		"payroll",
+		"nobody daily=1:pUSD",
+		"payroll daily=1",
+		"payroll daily=1:pXYZ",
+		"payroll daily=0.000000001:pUSD",
+		"payroll assets=pUSD,FOO",
+		"payroll to=nobody",
+		"payroll limit=5",
+	} {
+		_, err := NewPolicies([]string{bad}, accounts)
+		assert.Error(t, err, bad)
+	}
+
+	// An address can only have one policy
+	_, err = NewPolicies([]string{"payroll assets=pUSD", accountAddrA + " assets=PEG"}, accounts)
+	assert.Error(t, err)
+}
+
+func TestPolicies_Check(t *testing.T) {
+	p, err := NewPolicies([]string{accountAddrA + " daily=10:pUSD assets=pUSD,pEUR to=" + accountAddrB}, nil)
+	require.NoError(t, err)
+	a, _ := factom.NewFAAddress(accountAddrA)
+	b, _ := factom.NewFAAddress(accountAddrB)
+	c, _ := factom.NewFAAddress(accountAddrC)
+
+	transfer := func(from, to factom.FAAddress, ticker fat2.PTicker, amount uint64) fat2.Transaction {
+		return fat2.Transaction{
+			Input:     fat2.TypedAddressAmountTuple{Address: from, Amount: amount, Type: ticker},
+			Transfers: []fat2.AddressAmountTuple{{Address: to, Amount: amount}},
+		}
+	}
+	spent := func(already uint64) func(factom.FAAddress, string) (uint64, error) {
+		return func(factom.FAAddress, string) (uint64, error) { return already, nil }
+	}
+
+	spends, err := p.Check([]fat2.Transaction{transfer(a, b, fat2.PTickerUSD, 4e8), transfer(a, b, fat2.PTickerUSD, 5e8)}, spent(1e8))
+	require.NoError(t, err)
+	assert.Equal(t, []PolicySpend{{Address: a, Asset: "pUSD", Amount: 9e8}}, spends)
+
+	// Assets without a daily limit are not counted
+	spends, err = p.Check([]fat2.Transaction{transfer(a, b, fat2.PTickerEUR, 50e8)}, spent(0))
+	require.NoError(t, err)
+	assert.Empty(t, spends)
+
+	// Addresses without a policy can do anything
+	spends, err = p.Check([]fat2.Transaction{transfer(c, a, fat2.PTickerPEG, 50e8)}, spent(0))
+	require.NoError(t, err)
+	assert.Empty(t, spends)
+
+	conversion := fat2.Transaction{
+		Input:      fat2.TypedAddressAmountTuple{Address: a, Amount: 1e8, Type: fat2.PTickerUSD},
+		Conversion: fat2.PTickerPEG,
+	}
+	for name, txs := range map[string][]fat2.Transaction{
+		"daily limit":        {transfer(a, b, fat2.PTickerUSD, 4e8), transfer(a, b, fat2.PTickerUSD, 5e8)},
+		"asset":              {transfer(a, b, fat2.PTickerPEG, 1)},
+		"conversion asset":   {conversion},
+		"destination":        {transfer(a, c, fat2.PTickerUSD, 1)},
+		"second transaction": {transfer(c, a, fat2.PTickerPEG, 1), transfer(a, c, fat2.PTickerEUR, 1)},
+	} {
+		_, err := p.Check(txs, spent(2e8))
+		require.Error(t, err, name)
+		v, ok := err.(PolicyViolation)
+		require.True(t, ok, name)
+		assert.Equal(t, a, v.Address, name)
+	}
+}
+
+func TestPegnet_PolicySpends(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTablePolicySpends())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	now := time.Now()
+	require.NoError(t, p.InsertPolicySpends(nil, now.Add(-25*time.Hour), factom.Bytes32{1}, []PolicySpend{{Address: a, Asset: "pUSD", Amount: 100}}))
+	require.NoError(t, p.InsertPolicySpends(nil, now.Add(-time.Hour), factom.Bytes32{2}, []PolicySpend{
+		{Address: a, Asset: "pUSD", Amount: 20},
+		{Address: a, Asset: "PEG", Amount: 7},
+		{Address: b, Asset: "pUSD", Amount: 5},
+	}))
+	require.NoError(t, p.InsertPolicySpends(nil, now, factom.Bytes32{3}, []PolicySpend{{Address: a, Asset: "pUSD", Amount: 3}}))
+
+	spent, err := p.SelectPolicySpent(nil, a, "pUSD", now.Add(-PolicyWindow))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(23), spent)
+	spent, err = p.SelectPolicySpent(nil, b, "PEG", now.Add(-PolicyWindow))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), spent)
+}
@@ -0,0 +1,106 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// createTablePriceChecks is a SQL string that creates the table of the
+// comparisons of the graded rates with the external reference prices. The
+// references are fetched when a block is checked, so the rows are not part
+// of the synced state.
+const createTablePriceChecks = `CREATE TABLE IF NOT EXISTS "pn_price_checks" (
+	"height"		INTEGER PRIMARY KEY,
+	"checked"		INTEGER NOT NULL, -- unix seconds
+	"flagged"		BOOLEAN NOT NULL,
+	"max_deviation"	REAL NOT NULL, -- percent
+	"assets"		TEXT NOT NULL -- json of the compared assets
+);
+CREATE INDEX IF NOT EXISTS "idx_price_checks_flagged" ON "pn_price_checks"("flagged", "height");
+`
+
+// PriceChecksLimit is the maximum amount of price checks that can be queried
+// at once
+const PriceChecksLimit = 1000
+
+// PriceCheck is the comparison of the graded rates of a height with the
+// reference prices. The block is flagged if any asset deviates by more than
+// the threshold.
+type PriceCheck struct {
+	Height       uint32            `json:"height"`
+	Checked      time.Time         `json:"checked"`
+	Flagged      bool              `json:"flagged"`
+	MaxDeviation float64           `json:"maxdeviation"`
+	Assets       []AssetPriceCheck `json:"assets"`
+}
+
+// AssetPriceCheck is the graded rate of an asset and its reference price in
+// USD. The deviation is in percent of the reference.
+type AssetPriceCheck struct {
+	Asset     string  `json:"asset"`
+	Rate      float64 `json:"rate"`
+	Reference float64 `json:"reference"`
+	Sources   int     `json:"sources"`
+	Deviation float64 `json:"deviation"`
+	Flagged   bool    `json:"flagged,omitempty"`
+}
+
+// CreateTablePriceChecks is used to expose this table for unit tests
+func (p *Pegnet) CreateTablePriceChecks() error {
+	_, err := p.DB.Exec(createTablePriceChecks)
+	return err
+}
+
+// InsertPriceCheck records the check of a height, a height that is checked
+// again is replaced
+func (p *Pegnet) InsertPriceCheck(q QueryAble, c PriceCheck) error {
+	if q == nil {
+		q = p.DB
+	}
+	assets, err := json.Marshal(c.Assets)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT OR REPLACE INTO "pn_price_checks" ("height", "checked", "flagged", "max_deviation", "assets")
+		VALUES (?, ?, ?, ?, ?);`, c.Height, c.Checked.Unix(), c.Flagged, c.MaxDeviation, string(assets))
+	return err
+}
+
+// SelectPriceChecks returns up to limit checks below the height before,
+// newest first. A before of 0 starts at the latest check.
+func (p *Pegnet) SelectPriceChecks(ctx context.Context, flagged bool, before uint32, limit int) ([]PriceCheck, error) {
+	query := `SELECT "height", "checked", "flagged", "max_deviation", "assets" FROM "pn_price_checks"
+		WHERE (? = 0 OR "height" < ?) AND (NOT ? OR "flagged") ORDER BY "height" DESC LIMIT ?;`
+	rows, err := p.DB.QueryContext(ctx, query, before, before, flagged, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []PriceCheck
+	for rows.Next() {
+		var c PriceCheck
+		var checked int64
+		var assets string
+		if err := rows.Scan(&c.Height, &checked, &c.Flagged, &c.MaxDeviation, &assets); err != nil {
+			return nil, err
+		}
+		c.Checked = time.Unix(checked, 0)
+		if err := json.Unmarshal([]byte(assets), &c.Assets); err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// SelectPriceFlags returns the amount of flagged heights and the latest
+// flagged height, 0 if none was flagged
+func (p *Pegnet) SelectPriceFlags(ctx context.Context) (int, uint32, error) {
+	var count int
+	var latest sql.NullInt64
+	err := p.DB.QueryRowContext(ctx, `SELECT COUNT(*), MAX("height") FROM "pn_price_checks" WHERE "flagged";`).Scan(&count, &latest)
+	return count, uint32(latest.Int64), err
+}
@@ -0,0 +1,61 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_PriceChecks(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTablePriceChecks())
+
+	ctx := context.Background()
+	count, latest, err := p.SelectPriceFlags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, uint32(0), latest)
+
+	checked := time.Unix(1600000000, 0)
+	for h := uint32(100); h < 105; h++ {
+		c := PriceCheck{Height: h, Checked: checked, MaxDeviation: 1, Assets: []AssetPriceCheck{
+			{Asset: "pXBT", Rate: 10000, Reference: 9900, Sources: 2, Deviation: 1.01},
+		}}
+		if h%2 == 1 {
+			c.Flagged, c.MaxDeviation = true, 50
+			c.Assets[0].Flagged = true
+		}
+		require.NoError(t, p.InsertPriceCheck(nil, c))
+	}
+
+	checks, err := p.SelectPriceChecks(ctx, false, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, checks, 5)
+	assert.Equal(t, uint32(104), checks[0].Height, "newest first")
+	assert.Equal(t, checked, checks[0].Checked)
+	assert.Equal(t, "pXBT", checks[0].Assets[0].Asset)
+	assert.Equal(t, 2, checks[0].Assets[0].Sources)
+
+	checks, err = p.SelectPriceChecks(ctx, true, 103, 10)
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	assert.Equal(t, uint32(101), checks[0].Height)
+	assert.True(t, checks[0].Assets[0].Flagged)
+
+	count, latest, err = p.SelectPriceFlags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, uint32(103), latest)
+
+	// A height checked again replaces its check
+	require.NoError(t, p.InsertPriceCheck(nil, PriceCheck{Height: 103, Checked: checked}))
+	count, _, err = p.SelectPriceFlags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
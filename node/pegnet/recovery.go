@@ -0,0 +1,190 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// heightTables are the tables that only receive rows of the height being
+// synced, so the rows of a partially applied height can be deleted
+var heightTables = []string{
+	"pn_grade",
+	"pn_winners",
+	"pn_winner_rates",
+	"pn_miner_blocks",
+	"pn_rate",
+	"pn_bank",
+	"pn_supply_history",
+	"pn_conversion_volume",
+	"pn_blocks",
+	"pn_deposits",
+	"pn_malformed_burns",
+	"pn_alerts",
+	"pn_history_refund",
+	"pn_history_rejection",
+	"pn_eth_addresses",
+	"pn_sync_version",
+}
+
+// PartialHeight is what was found of the heights above the synced height
+type PartialHeight struct {
+	// Rows is the amount of rows above the synced height per table
+	Rows map[string]int
+	// Balances is the amount of balances changed above the synced height
+	Balances int
+}
+
+// Found is true if anything of a height above the synced height was written
+func (p *PartialHeight) Found() bool {
+	return len(p.Rows) > 0 || p.Balances > 0
+}
+
+// SelectPartialHeight looks for the state of heights above the synced
+// height. A block is applied in a single transaction with the synced height,
+// so anything found was left by a crash of a database that was not crash
+// safe, eg: with "_sync=OFF" or "_journal=MEMORY".
+func (p *Pegnet) SelectPartialHeight(ctx context.Context, q QueryAble, synced uint32) (*PartialHeight, error) {
+	if q == nil {
+		q = p.DB
+	}
+	partial := &PartialHeight{Rows: make(map[string]int)}
+	count := func(table, where string, args ...interface{}) error {
+		var n int
+		if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+table+`" WHERE `+where+`;`, args...).Scan(&n); err != nil {
+			return fmt.Errorf("%s: %v", table, err)
+		}
+		if n > 0 {
+			partial.Rows[table] = n
+		}
+		return nil
+	}
+
+	for _, table := range heightTables {
+		if err := count(table, `"height" > ?`, synced); err != nil {
+			return nil, err
+		}
+	}
+	if err := count("pn_history_txbatch", `"height" > ? OR "executed" > ?`, synced, synced); err != nil {
+		return nil, err
+	}
+
+	// Unfinished blocks leave their balance changes at height 0
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM "pn_balance_journal" WHERE "height" = 0 OR "height" > ?;`,
+		synced).Scan(&partial.Balances)
+	if err != nil {
+		return nil, fmt.Errorf("pn_balance_journal: %v", err)
+	}
+	return partial, nil
+}
+
+// RollbackPartialHeight removes everything of the heights above the synced
+// height. The balances are restored from the balance journal, the history
+// of the batches is deleted, and conversions executed above the height are
+// pending again. The daily and total statistics can't be restored and may
+// include the removed heights.
+func (p *Pegnet) RollbackPartialHeight(tx *sql.Tx, synced uint32) error {
+	start, err := p.selectBalanceJournalStart(tx)
+	if err != nil {
+		return err
+	}
+	if start > synced {
+		return fmt.Errorf("the balance journal starts at height %d, above the synced height %d", start, synced)
+	}
+	if err := p.rollbackBalances(tx, synced); err != nil {
+		return err
+	}
+
+	for _, table := range heightTables {
+		if _, err := tx.Exec(`DELETE FROM "`+table+`" WHERE "height" > ?;`, synced); err != nil {
+			return fmt.Errorf("%s: %v", table, err)
+		}
+	}
+
+	// The history of entries that are also in a synced height is kept. The
+	// batches in holding are removed with their entry, time-locked batches
+	// of a synced height are held at a future height.
+	removed := `SELECT "entry_hash" FROM "pn_history_txbatch" WHERE "height" > ?
+		AND "entry_hash" NOT IN (SELECT "entry_hash" FROM "pn_history_txbatch" WHERE "height" <= ?)`
+	for _, table := range []string{"pn_history_lookup", "pn_history_transaction", "pn_address_transactions", "pn_transaction_batch_holding"} {
+		if _, err := tx.Exec(`DELETE FROM "`+table+`" WHERE "entry_hash" IN (`+removed+`);`, synced, synced); err != nil {
+			return fmt.Errorf("%s: %v", table, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM "pn_history_txbatch" WHERE "height" > ?;`, synced); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE "pn_history_txbatch" SET "executed" = 0 WHERE "executed" > ?;`, synced); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rollbackBalances sets every balance changed above the synced height back
+// to its last journaled balance at or below the height
+func (p *Pegnet) rollbackBalances(tx *sql.Tx, synced uint32) error {
+	type change struct {
+		address []byte
+		ticker  fat2.PTicker
+		balance uint64
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT "address", "token" FROM "pn_balance_journal" WHERE "height" = 0 OR "height" > ?;`, synced)
+	if err != nil {
+		return err
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		var token string
+		if err := rows.Scan(&c.address, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		if c.ticker = fat2.StringToTicker(token); c.ticker == fat2.PTickerInvalid {
+			rows.Close()
+			return fmt.Errorf("balance journal: unknown token %q", token)
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range changes {
+		c := &changes[i]
+		err := tx.QueryRow(`SELECT "balance" FROM "pn_balance_journal"
+			WHERE "address" = ? AND "token" = ? AND "height" BETWEEN 1 AND ?
+			ORDER BY "height" DESC LIMIT 1;`, c.address, c.ticker.String(), synced).Scan(&c.balance)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	for _, c := range changes {
+		col := strings.ToLower(c.ticker.String()) + "_balance"
+		if _, err := tx.Exec(`UPDATE "pn_addresses" SET "`+col+`" = ? WHERE "address" = ?;`, c.balance, c.address); err != nil {
+			var adr factom.FAAddress
+			copy(adr[:], c.address)
+			return fmt.Errorf("restore %s balance of %s: %v", c.ticker, adr, err)
+		}
+	}
+
+	// The updates above are journaled at height 0 by the triggers
+	_, err = tx.Exec(`DELETE FROM "pn_balance_journal" WHERE "height" = 0 OR "height" > ?;`, synced)
+	return err
+}
+
+func (p *Pegnet) selectBalanceJournalStart(q QueryAble) (uint32, error) {
+	var start uint32
+	err := q.QueryRow(`SELECT "value" FROM "pn_metadata" WHERE "name" = 'balancejournal';`).Scan(&start)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return start, err
+}
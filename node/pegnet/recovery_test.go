@@ -0,0 +1,107 @@
+package pegnet_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_RollbackPartialHeight(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTables())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var locked, converted, keymr factom.Bytes32
+	locked[0], converted[0] = 1, 2
+	batch := func(hash *factom.Bytes32) *fat2.TransactionBatch {
+		b := &fat2.TransactionBatch{Entry: factom.Entry{Hash: hash, ChainID: new(factom.Bytes32), Timestamp: time.Unix(100, 0)}}
+		b.Transactions = []fat2.Transaction{{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 1, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD}}
+		return b
+	}
+	hold := func(tx *sql.Tx, hash *factom.Bytes32, height, holding uint32) {
+		require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch(hash), height))
+		_, err := p.InsertTransactionBatchHolding(tx, batch(hash), uint64(holding), &keymr)
+		require.NoError(t, err)
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &a, fat2.PTickerPEG, 100)
+	require.NoError(t, err)
+	// A time-locked batch of a synced height is held at a future height
+	hold(tx, &locked, 10, 20)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+	require.NoError(t, p.MarkHeightSynced(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	partial, err := p.SelectPartialHeight(context.Background(), nil, 10)
+	require.NoError(t, err)
+	assert.False(t, partial.Found())
+
+	// Height 11 is left half applied: a balance change that was not
+	// finalized and one that was
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	_, txErr, err := p.SubFromBalance(tx, &a, fat2.PTickerPEG, 40)
+	require.NoError(t, err)
+	require.NoError(t, txErr)
+	_, err = p.AddToBalance(tx, &b, fat2.PTickerUSD, 5)
+	require.NoError(t, err)
+	hold(tx, &converted, 11, 11)
+	require.NoError(t, p.MarkHeightSynced(tx, 11))
+	require.NoError(t, tx.Commit())
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &b, fat2.PTickerPEG, 1)
+	require.NoError(t, err)
+	require.NoError(t, p.FinalizeBalanceJournal(tx, 12))
+	require.NoError(t, tx.Commit())
+
+	partial, err = p.SelectPartialHeight(context.Background(), nil, 10)
+	require.NoError(t, err)
+	require.True(t, partial.Found())
+	assert.Equal(t, 1, partial.Rows["pn_sync_version"])
+	assert.Equal(t, 1, partial.Rows["pn_history_txbatch"])
+	assert.Equal(t, 3, partial.Balances)
+
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.RollbackPartialHeight(tx, 10))
+	require.NoError(t, tx.Commit())
+
+	balances, err := p.SelectBalances(&a)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), balances[fat2.PTickerPEG])
+	balances, err = p.SelectBalances(&b)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), balances[fat2.PTickerPEG])
+	assert.Equal(t, uint64(0), balances[fat2.PTickerUSD])
+
+	var held [][]byte
+	rows, err := p.DB.Query(`SELECT "entry_hash" FROM "pn_transaction_batch_holding";`)
+	require.NoError(t, err)
+	for rows.Next() {
+		var hash []byte
+		require.NoError(t, rows.Scan(&hash))
+		held = append(held, hash)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]byte{locked[:]}, held)
+
+	partial, err = p.SelectPartialHeight(context.Background(), nil, 10)
+	require.NoError(t, err)
+	assert.False(t, partial.Found(), "%+v", partial)
+	synced, err := p.HighestSynced(p.DB)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), synced)
+}
@@ -0,0 +1,196 @@
+package pegnet
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableTxHistoryRefund is a SQL string that creates the
+// "pn_history_refund" table.
+//
+// A refund is the part of a conversion input that was not converted. PEG
+// requests that exceed the conversion limit are refunded the input that was
+// not covered by the bank, or their full input if the prorated yield is below
+// their minimum output. Conversions of batches rejected for a missing rate
+// are refunded their full input, their batch has a negative "executed" code
+// as the input was never subtracted.
+const createTableTxHistoryRefund = `CREATE TABLE IF NOT EXISTS "pn_history_refund" (
+	"entry_hash"	BLOB NOT NULL,
+	"tx_index"		INTEGER NOT NULL,	-- the batch index of the conversion
+	"height"		INTEGER NOT NULL,	-- height the refund happened at
+	"address"		BLOB NOT NULL,
+	"asset"			STRING NOT NULL,
+	"amount"		INTEGER NOT NULL,
+	"reason"		TEXT NOT NULL,
+
+	PRIMARY KEY("entry_hash", "tx_index"),
+	FOREIGN KEY("entry_hash", "tx_index") REFERENCES "pn_history_transaction"
+);
+CREATE INDEX IF NOT EXISTS "idx_history_refund_address" ON "pn_history_refund"("address");
+CREATE INDEX IF NOT EXISTS "idx_history_refund_height" ON "pn_history_refund"("height");
+`
+
+// RefundReasonConversionLimit is the reason of the refunds of PEG requests
+// that exceeded the conversion limit of the block
+const RefundReasonConversionLimit = "the conversion limit of the block was exceeded, the remaining input was refunded"
+
+// RefundReasonMinOutput is the reason of the refunds of PEG requests whose
+// share of the conversion limit is below their minimum output
+const RefundReasonMinOutput = "the conversion limit of the block prorated the yield below the minimum output, the input was refunded"
+
+// RefundReasonMissingRate is the reason of the refunds of conversions whose
+// batch was rejected as an asset of a conversion has no rate
+const RefundReasonMissingRate = "an asset of the conversion has no rate at the execution height, the input was refunded"
+
+// CreateTableTxHistoryRefund is used to expose this table for unit tests
+func (p *Pegnet) CreateTableTxHistoryRefund() error {
+	_, err := p.DB.Exec(createTableTxHistoryRefund)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertTransactionHistoryRefund records the refund of the conversion at the
+// index of the batch
+func (p *Pegnet) InsertTransactionHistoryRefund(tx *sql.Tx, txbatch *fat2.TransactionBatch, index int, height uint32, amount int64, reason string) error {
+	input := txbatch.Transactions[index].Input
+	stmt, err := p.prepare(tx, `INSERT OR REPLACE INTO "pn_history_refund"
+                ("entry_hash", "tx_index", "height", "address", "asset", "amount", "reason") VALUES
+                (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(txbatch.Entry.Hash[:], index, height, input.Address[:], input.Type.String(), amount, reason)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertTransactionHistoryRejectedRefunds refunds the full input of every
+// conversion in a rejected batch
+func (p *Pegnet) InsertTransactionHistoryRejectedRefunds(tx *sql.Tx, txbatch *fat2.TransactionBatch, height uint32, reason string) error {
+	for i, t := range txbatch.Transactions {
+		if !t.IsConversion() {
+			continue
+		}
+		if err := p.InsertTransactionHistoryRefund(tx, txbatch, i, height, int64(t.Input.Amount), reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const refundQueryFields = "batch.history_id, refund.entry_hash, refund.height, batch.timestamp, batch.executed," +
+	"refund.tx_index, refund.address, refund.asset, refund.amount, refund.reason"
+
+// refundQueryBuilder generates a count and data query for the given options,
+// the same way historyQueryBuilder does for the history actions
+func refundQueryBuilder(field string, options HistoryQueryOptions) (string, string, error) {
+	dir := "ASC"
+	if options.Desc {
+		dir = "DESC"
+	}
+	var order string
+	switch options.Sort {
+	case "", SortHeight:
+		order = fmt.Sprintf("ORDER BY batch.history_id %[1]s, refund.tx_index %[1]s", dir)
+	case SortAmount:
+		order = fmt.Sprintf("ORDER BY refund.amount %[1]s, batch.history_id %[1]s, refund.tx_index %[1]s", dir)
+	default:
+		return "", "", fmt.Errorf("invalid sort specified")
+	}
+
+	limit := fmt.Sprintf("LIMIT %d OFFSET %d", QueryLimit, options.Offset)
+
+	from := "pn_history_refund refund, pn_history_txbatch batch"
+	where := "batch.entry_hash = refund.entry_hash"
+	switch field {
+	case "address", "entry_hash":
+		where += fmt.Sprintf(" AND refund.%s = ?", field)
+	case "height":
+		where += " AND batch.height = ?"
+	default:
+		return "", "", fmt.Errorf("developer error - unimplemented refund query builder field")
+	}
+
+	if options.UseTxIndex && field == "entry_hash" {
+		where += fmt.Sprintf(" AND refund.tx_index = %d", options.TxIndex)
+	}
+
+	if options.Asset != "" {
+		if fat2.StringToTicker(options.Asset) == fat2.PTickerInvalid {
+			return "", "", fmt.Errorf("invalid asset specified")
+		}
+		where += fmt.Sprintf(" AND refund.asset = '%s'", options.Asset)
+	}
+	where += amountFilter("refund.amount", options)
+
+	// refunds are only ever received
+	switch options.Direction {
+	case "", DirectionBoth, DirectionReceived:
+	case DirectionSent:
+		where += " AND 0"
+	default:
+		return "", "", fmt.Errorf("invalid direction specified")
+	}
+	if options.Direction != "" && field != "address" {
+		return "", "", fmt.Errorf("a direction can only be used with an address")
+	}
+
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, where),
+		fmt.Sprintf("SELECT %s FROM %s WHERE %s %s %s", refundQueryFields, from, where, order, limit), nil
+}
+
+// refundSelectHelper returns the refunds as history actions. The refunded
+// amount is the single output to the input address.
+func (p *Pegnet) refundSelectHelper(field string, data interface{}, options HistoryQueryOptions) ([]HistoryTransaction, int, error) {
+	countQuery, dataQuery, err := refundQueryBuilder(field, options)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	if err = p.DB.QueryRow(countQuery, data).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+	if options.Offset > count {
+		return nil, 0, fmt.Errorf("offset too big")
+	}
+
+	rows, err := p.DB.Query(dataQuery, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var actions []HistoryTransaction
+	for rows.Next() {
+		tx := HistoryTransaction{TxAction: Refund}
+		var ts, id int64
+		var hash, addr []byte
+		err := rows.Scan(&id, &hash, &tx.Height, &ts, &tx.Executed,
+			&tx.TxIndex, &addr, &tx.FromAsset, &tx.FromAmount, &tx.Reason)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		tx.Hash = new(factom.Bytes32)
+		copy(tx.Hash[:], hash)
+		tx.TxID = FormatTxID(tx.TxIndex, tx.Hash.String())
+		tx.Timestamp = time.Unix(ts, 0)
+		tx.FromAddress = new(factom.FAAddress)
+		copy(tx.FromAddress[:], addr)
+		tx.Outputs = []HistoryTransactionOutput{{Address: *tx.FromAddress, Amount: tx.FromAmount}}
+		actions = append(actions, tx)
+	}
+	return actions, count, rows.Err()
+}
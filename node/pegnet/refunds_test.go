@@ -0,0 +1,132 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_Refunds(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableTxHistoryRefund())
+	require.NoError(t, p.CreateTableTxRejection())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var prorated, rejected factom.Bytes32
+	prorated[0], rejected[0] = 1, 2
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &prorated, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 50, Type: fat2.PTickerUSD},
+			Conversion: fat2.PTickerPEG},
+	}
+	rejectedBatch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &rejected, Timestamp: time.Unix(100, 0)}}
+	rejectedBatch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 1, rejectedBatch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 101))
+	require.NoError(t, p.InsertTransactionHistoryRefund(tx, batch, 0, 101, 20, RefundReasonConversionLimit))
+	require.NoError(t, p.SetTransactionHistoryRejected(tx, rejectedBatch, 101, ZeroRatesErrorInt, ZeroRatesError))
+	require.NoError(t, p.InsertTransactionHistoryRejectedRefunds(tx, rejectedBatch, 101, RefundReasonMissingRate))
+	require.NoError(t, tx.Commit())
+
+	refunds, count, err := p.SelectTransactionHistoryActionsByAddress(&a, HistoryQueryOptions{Refund: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	assert.Equal(t, Refund, refunds[0].TxAction)
+	assert.Equal(t, int32(101), refunds[0].Executed)
+	assert.Equal(t, "pUSD", refunds[0].FromAsset)
+	assert.Equal(t, int64(20), refunds[0].FromAmount)
+	assert.Equal(t, []HistoryTransactionOutput{{Address: a, Amount: 20}}, refunds[0].Outputs)
+	assert.Equal(t, RefundReasonConversionLimit, refunds[0].Reason)
+
+	// only the conversion of the rejected batch is refunded
+	refunds, count, err = p.SelectTransactionHistoryActionsByHash(&rejected, HistoryQueryOptions{Refund: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	assert.Equal(t, 1, refunds[0].TxIndex)
+	assert.Equal(t, int32(ZeroRatesErrorInt), refunds[0].Executed)
+	assert.Equal(t, int64(5), refunds[0].FromAmount)
+	assert.Equal(t, RefundReasonMissingRate, refunds[0].Reason)
+
+	_, count, err = p.SelectTransactionHistoryActionsByHeight(100, HistoryQueryOptions{Refund: true, Asset: "pUSD"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// the actions themselves are unchanged
+	actions, _, err := p.SelectTransactionHistoryActionsByAddress(&a, HistoryQueryOptions{})
+	require.NoError(t, err)
+	assert.Len(t, actions, 2)
+}
+
+func TestPegnet_ConversionResults(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableTxHistoryRefund())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 50, Type: fat2.PTickerUSD},
+			Conversion: fat2.PTickerPEG},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, tx.Commit())
+
+	results, err := p.SelectConversionResults(context.Background(), &hash)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, int32(0), results[0].Executed)
+	assert.Equal(t, int64(0), results[0].ToAmount)
+
+	tx, err = p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 101))
+	require.NoError(t, p.SetTransactionHistoryPEGConvertedRequestAmount(tx, batch, 1, 30, 20, 100, 100))
+	require.NoError(t, p.InsertTransactionHistoryRefund(tx, batch, 1, 101, 20, RefundReasonConversionLimit))
+	require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 2, 25, 500, 100))
+	require.NoError(t, tx.Commit())
+
+	results, err = p.SelectConversionResults(context.Background(), &hash)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ConversionResult{TxID: FormatTxID(1, hash.String()), TxIndex: 1, Executed: 101,
+		FromAsset: "pUSD", FromAmount: 50, ToAsset: "PEG", ToAmount: 30, FromRate: 100, ToRate: 100,
+		Prorated: true, Refunded: 20, RefundReason: RefundReasonConversionLimit}, results[0])
+	assert.Equal(t, ConversionResult{TxID: FormatTxID(2, hash.String()), TxIndex: 2, Executed: 101,
+		FromAsset: "PEG", FromAmount: 5, ToAsset: "pUSD", ToAmount: 25, FromRate: 500, ToRate: 100}, results[1])
+
+	var other factom.Bytes32
+	results, err = p.SelectConversionResults(context.Background(), &other)
+	require.NoError(t, err)
+	assert.Nil(t, results)
+}
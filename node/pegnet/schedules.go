@@ -0,0 +1,176 @@
+package pegnet
+
+import (
+	"database/sql"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableSchedules is a SQL string that creates the table of recurring
+// transfers the node submits on behalf of its wallet.
+const createTableSchedules = `CREATE TABLE IF NOT EXISTS "pn_schedules" (
+	"id"				INTEGER PRIMARY KEY,
+	"input"				BLOB NOT NULL,
+	"token"				TEXT NOT NULL,
+	"amount"			INTEGER NOT NULL,
+	"output"			BLOB NOT NULL,
+	"interval"			INTEGER NOT NULL, -- blocks between submissions
+	"next"				INTEGER NOT NULL, -- height of the next submission
+	"remaining"			INTEGER NOT NULL, -- submissions left, -1 if there is no end
+	"created"			INTEGER NOT NULL, -- height the schedule was added at
+	"last_height"		INTEGER NOT NULL DEFAULT 0,
+	"last_entry_hash"	BLOB,
+	"last_error"		TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS "idx_schedules_next" ON "pn_schedules"("next");
+`
+
+// Schedule is a transfer that is submitted every `Interval` blocks
+type Schedule struct {
+	ID       int64            `json:"id"`
+	Input    factom.FAAddress `json:"input"`
+	Asset    string           `json:"asset"`
+	Amount   uint64           `json:"amount"`
+	Output   factom.FAAddress `json:"output"`
+	Interval uint32           `json:"interval"`
+	Next     uint32           `json:"next"`
+	// Remaining is the amount of submissions left, -1 if there is no end
+	Remaining int64  `json:"remaining"`
+	Created   uint32 `json:"created"`
+
+	// The result of the most recent submission
+	LastHeight    uint32          `json:"lastheight,omitempty"`
+	LastEntryHash *factom.Bytes32 `json:"lastentryhash,omitempty"`
+	LastError     string          `json:"lasterror,omitempty"`
+}
+
+// Done returns true if there are no submissions left
+func (s *Schedule) Done() bool {
+	return s.Remaining == 0
+}
+
+// Advance records a submission at the height and moves Next past it. Missed
+// submissions, eg: while the node was down, are skipped.
+func (s *Schedule) Advance(height uint32) {
+	for s.Next <= height {
+		s.Next += s.Interval
+	}
+	if s.Remaining > 0 {
+		s.Remaining--
+	}
+	s.LastHeight = height
+}
+
+// CreateTableSchedules is used to expose this table for unit tests
+func (p *Pegnet) CreateTableSchedules() error {
+	_, err := p.DB.Exec(createTableSchedules)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertSchedule adds the schedule and returns its id
+func (p *Pegnet) InsertSchedule(q QueryAble, s Schedule) (int64, error) {
+	if q == nil {
+		q = p.DB
+	}
+	private, err := p.sealAll("pn_schedules", []string{"input", "token", "amount", "output", "last_error"},
+		s.Input[:], s.Asset, s.Amount, s.Output[:], "")
+	if err != nil {
+		return -1, err
+	}
+	res, err := q.Exec(`INSERT INTO "pn_schedules"
+		("input", "token", "amount", "output", "interval", "next", "remaining", "created", "last_error") VALUES
+		(?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		private[0], private[1], private[2], private[3], s.Interval, s.Next, s.Remaining, s.Created, private[4])
+	if err != nil {
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateSchedule writes the progress and the result of the most recent
+// submission of the schedule
+func (p *Pegnet) UpdateSchedule(q QueryAble, s Schedule) error {
+	if q == nil {
+		q = p.DB
+	}
+	var hash []byte
+	if s.LastEntryHash != nil {
+		hash = s.LastEntryHash[:]
+	}
+	private, err := p.sealAll("pn_schedules", []string{"last_entry_hash", "last_error"}, hash, s.LastError)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`UPDATE "pn_schedules" SET "next" = ?, "remaining" = ?,
+		"last_height" = ?, "last_entry_hash" = ?, "last_error" = ? WHERE "id" = ?;`,
+		s.Next, s.Remaining, s.LastHeight, private[0], private[1], s.ID)
+	return err
+}
+
+// DeleteSchedule removes the schedule. Returns false if it does not exist.
+func (p *Pegnet) DeleteSchedule(q QueryAble, id int64) (bool, error) {
+	if q == nil {
+		q = p.DB
+	}
+	res, err := q.Exec(`DELETE FROM "pn_schedules" WHERE "id" = ?;`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SelectSchedules returns all schedules, including the finished ones
+func (p *Pegnet) SelectSchedules(q QueryAble) ([]Schedule, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(`SELECT ` + scheduleFields + ` FROM "pn_schedules" ORDER BY "id";`)
+	if err != nil {
+		return nil, err
+	}
+	return p.scanSchedules(rows)
+}
+
+// SelectDueSchedules returns the unfinished schedules with a submission at
+// or before the height
+func (p *Pegnet) SelectDueSchedules(q QueryAble, height uint32) ([]Schedule, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(`SELECT `+scheduleFields+` FROM "pn_schedules"
+		WHERE "next" <= ? AND "remaining" != 0 ORDER BY "next", "id";`, height)
+	if err != nil {
+		return nil, err
+	}
+	return p.scanSchedules(rows)
+}
+
+const scheduleFields = `"id", "input", "token", "amount", "output", "interval", "next", "remaining", "created",
+	"last_height", "last_entry_hash", "last_error"`
+
+func (p *Pegnet) scanSchedules(rows *sql.Rows) ([]Schedule, error) {
+	defer rows.Close()
+
+	var schedules []Schedule
+	for rows.Next() {
+		var s Schedule
+		var input, output, hash []byte
+		if err := rows.Scan(&s.ID, p.sealed("pn_schedules.input", &input), p.sealed("pn_schedules.token", &s.Asset),
+			p.sealed("pn_schedules.amount", &s.Amount), p.sealed("pn_schedules.output", &output), &s.Interval, &s.Next, &s.Remaining, &s.Created,
+			&s.LastHeight, p.sealed("pn_schedules.last_entry_hash", &hash), p.sealed("pn_schedules.last_error", &s.LastError)); err != nil {
+			return nil, err
+		}
+		copy(s.Input[:], input)
+		copy(s.Output[:], output)
+		if len(hash) > 0 {
+			s.LastEntryHash = new(factom.Bytes32)
+			copy(s.LastEntryHash[:], hash)
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
@@ -0,0 +1,67 @@
+package pegnet_test
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_Schedules(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableSchedules())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	id, err := p.InsertSchedule(nil, Schedule{Input: a, Asset: "pUSD", Amount: 5e8, Output: b, Interval: 10, Next: 100, Remaining: 2, Created: 95})
+	require.NoError(t, err)
+	_, err = p.InsertSchedule(nil, Schedule{Input: a, Asset: "PEG", Amount: 1, Output: b, Interval: 144, Next: 200, Remaining: -1, Created: 95})
+	require.NoError(t, err)
+
+	due, err := p.SelectDueSchedules(nil, 99)
+	require.NoError(t, err)
+	assert.Len(t, due, 0)
+
+	due, err = p.SelectDueSchedules(nil, 125)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, id, due[0].ID)
+	assert.Equal(t, "pUSD", due[0].Asset)
+	assert.Equal(t, a, due[0].Input)
+
+	// Missed submissions are skipped
+	s := due[0]
+	s.Advance(125)
+	assert.Equal(t, uint32(130), s.Next)
+	assert.Equal(t, int64(1), s.Remaining)
+	hash := factom.Bytes32{1}
+	s.LastEntryHash = &hash
+	require.NoError(t, p.UpdateSchedule(nil, s))
+
+	s.Advance(130)
+	s.LastEntryHash, s.LastError = nil, "insufficient balance"
+	require.NoError(t, p.UpdateSchedule(nil, s))
+	due, err = p.SelectDueSchedules(nil, 1000)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "the finished schedule is no longer due")
+	assert.Equal(t, int64(-1), due[0].Remaining)
+
+	all, err := p.SelectSchedules(nil)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.True(t, all[0].Done())
+	assert.Equal(t, uint32(130), all[0].LastHeight)
+	assert.Nil(t, all[0].LastEntryHash)
+	assert.Equal(t, "insufficient balance", all[0].LastError)
+
+	removed, err := p.DeleteSchedule(nil, id)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = p.DeleteSchedule(nil, id)
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
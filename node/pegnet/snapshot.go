@@ -0,0 +1,59 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// localTables are the tables of the config and the wallet of a node, like
+// its watched addresses and its send attempts, rather than of the synced
+// chain. They are recreated empty in a snapshot.
+var localTables = []struct {
+	Tables []string
+	Create string
+}{
+	{[]string{"pn_deposit_addresses", "pn_deposits"}, createTableDeposits},
+	{[]string{"pn_alerts"}, createTableAlerts},
+	{[]string{"pn_schedules"}, createTableSchedules},
+	{[]string{"pn_send_audit"}, createTableSendAudit},
+	{[]string{"pn_watch_addresses"}, createTableWatchAddresses},
+	{[]string{"pn_policy_spends"}, createTablePolicySpends},
+	{[]string{"pn_price_checks"}, createTablePriceChecks},
+}
+
+// Snapshot writes a copy of the database to the path without the local
+// tables. The copy is a consistent view of the last committed height, so it
+// can be taken while the node syncs. The path must not exist yet.
+func (p *Pegnet) Snapshot(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if _, err := p.DB.ExecContext(ctx, `VACUUM INTO ?;`, path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open(tracedDriverName, path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, local := range localTables {
+		// The triggers of the append-only tables are dropped with them
+		for _, table := range local.Tables {
+			if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS "`+table+`";`); err != nil {
+				return fmt.Errorf("%s: %v", table, err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, local.Create); err != nil {
+			return fmt.Errorf("%s: %v", local.Tables[0], err)
+		}
+	}
+	// The encrypted columns are all of local tables, the snapshot has none
+	if _, err := db.ExecContext(ctx, `DELETE FROM "pn_metadata" WHERE "name" = 'column_encryption';`); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `VACUUM;`)
+	return err
+}
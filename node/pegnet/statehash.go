@@ -0,0 +1,111 @@
+package pegnet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// stateHashQueries are the rows of a height that are committed to by the
+// state hash, in the order they are hashed. Every query takes the height and
+// orders its rows, so the hash does not depend on the order of insertion.
+var stateHashQueries = []struct {
+	Section string
+	Query   string
+}{
+	{"rates", `SELECT "token", "value" FROM "pn_rate" WHERE "height" = ? ORDER BY "token";`},
+	{"winners", `SELECT "shorthashes" FROM "pn_grade" WHERE "height" = ?;`},
+	{"balances", `SELECT "address", "token", "balance" FROM "pn_balance_journal" WHERE "height" = ? ORDER BY "address", "token";`},
+	{"executed", `SELECT "entry_hash" FROM "pn_history_txbatch" WHERE "executed" = ? ORDER BY "entry_hash";`},
+	{"rejected", `SELECT "entry_hash", "code" FROM "pn_history_rejection" WHERE "height" = ? ORDER BY "entry_hash", "code";`},
+	// Batches are put into holding at the height of their entry, and are
+	// committed to with their holding height at that height. The holding
+	// height of a time-locked batch is a future height, so the pending
+	// batches of a height are committed to before they are executed.
+	{"holding", `SELECT "entry_hash", "entry_data", "height", "eblock_keymr", "unix_timestamp" FROM "pn_transaction_batch_holding"
+		WHERE "entry_hash" IN (SELECT "entry_hash" FROM "pn_history_txbatch" WHERE "height" = ?) ORDER BY "entry_hash";`},
+	{"bank", `SELECT "bank_amount", "bank_used", "total_requested" FROM "pn_bank" WHERE "height" = ?;`},
+}
+
+// SelectStateHash returns the state hash of a synced height. It commits to
+// the rates, the OPR winners, the resulting balances of every address that
+// changed, the executed and rejected transactions, the batches put into
+// holding and the bank of the height, as well as to the state hash of the
+// previous height. Two databases that synced the
+// same heights from the same state have the same hashes, the first height
+// with a different hash is where they diverged.
+func (p *Pegnet) SelectStateHash(q QueryAble, height uint32, prev factom.Bytes32) (factom.Bytes32, error) {
+	if q == nil {
+		q = p.DB
+	}
+	h := sha256.New()
+	h.Write(prev[:])
+	writeUint64(h, uint64(height))
+
+	for _, s := range stateHashQueries {
+		writeBytes(h, []byte(s.Section))
+		rows, err := q.Query(s.Query, height)
+		if err != nil {
+			return factom.Bytes32{}, err
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return factom.Bytes32{}, err
+		}
+		values := make([]interface{}, len(cols))
+		for i := range values {
+			values[i] = new(interface{})
+		}
+		for rows.Next() {
+			if err := rows.Scan(values...); err != nil {
+				rows.Close()
+				return factom.Bytes32{}, err
+			}
+			for _, v := range values {
+				writeValue(h, *v.(*interface{}))
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return factom.Bytes32{}, err
+		}
+	}
+
+	var res factom.Bytes32
+	copy(res[:], h.Sum(nil))
+	return res, nil
+}
+
+// writeValue writes a column of sqlite to the hash, prefixed by its type
+func writeValue(h hash.Hash, v interface{}) {
+	switch v := v.(type) {
+	case int64:
+		h.Write([]byte{'i'})
+		writeUint64(h, uint64(v))
+	case []byte:
+		h.Write([]byte{'b'})
+		writeBytes(h, v)
+	case string:
+		h.Write([]byte{'s'})
+		writeBytes(h, []byte(v))
+	default:
+		h.Write([]byte{'n'})
+	}
+}
+
+func writeUint64(h hash.Hash, x uint64) {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], x)
+	h.Write(data[:])
+}
+
+// writeBytes writes the length and the data, so adjacent values can not be
+// confused
+func writeBytes(h hash.Hash, data []byte) {
+	writeUint64(h, uint64(len(data)))
+	h.Write(data)
+}
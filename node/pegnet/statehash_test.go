@@ -0,0 +1,86 @@
+package pegnet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectStateHash(t *testing.T) {
+	// stateAt builds a database with 100 PEG at height 10 and the balance
+	// of the second address at height 11, and returns the chained hashes
+	stateAt := func(balance uint64, rejected bool, held int32, bankUsed int64) []factom.Bytes32 {
+		p, err := setupPegnet()
+		require.NoError(t, err)
+		defer tearDownPegnet(p)
+		require.NoError(t, p.CreateTables())
+
+		adrs := make([]factom.FAAddress, 2)
+		for i := range adrs {
+			adrs[i][0] = byte(i + 1)
+		}
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		_, err = p.AddToBalance(tx, &adrs[0], fat2.PTickerPEG, 100)
+		require.NoError(t, err)
+		require.NoError(t, p.FinalizeBalanceJournal(tx, 10))
+		_, err = p.AddToBalance(tx, &adrs[1], fat2.PTickerPEG, balance)
+		require.NoError(t, err)
+		if rejected {
+			require.NoError(t, p.InsertTransactionRejection(tx, &factom.Bytes32{1}, 11, pegnet.ReplayErrorInt, "replay"))
+		}
+		if held > 0 {
+			// A time-locked batch of height 11 is held until a later height
+			var hash factom.Bytes32
+			hash[0] = 2
+			batch := &fat2.TransactionBatch{Entry: factom.Entry{ChainID: &hash, Hash: &hash, Timestamp: time.Unix(100, 0)}}
+			require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 11))
+			_, err = p.InsertTransactionBatchHolding(tx, batch, uint64(held), &hash)
+			require.NoError(t, err)
+		}
+		require.NoError(t, p.InsertBankAmount(tx, 11, 5000))
+		if bankUsed >= 0 {
+			require.NoError(t, p.UpdateBankEntry(tx, 11, bankUsed, 100))
+		}
+		require.NoError(t, p.FinalizeBalanceJournal(tx, 11))
+		require.NoError(t, tx.Commit())
+
+		var hashes []factom.Bytes32
+		var prev factom.Bytes32
+		for height := uint32(10); height <= 12; height++ {
+			prev, err = p.SelectStateHash(nil, height, prev)
+			require.NoError(t, err)
+			hashes = append(hashes, prev)
+		}
+		return hashes
+	}
+
+	base := stateAt(5, false, 0, -1)
+	assert.Equal(t, base, stateAt(5, false, 0, -1))
+	assert.NotEqual(t, base[0], base[1])
+	// An empty height still changes the hash of the chain
+	assert.NotEqual(t, base[1], base[2])
+
+	other := stateAt(6, false, 0, -1)
+	assert.Equal(t, base[0], other[0])
+	assert.NotEqual(t, base[1], other[1])
+	assert.NotEqual(t, base[2], other[2], "a difference carries over to the next heights")
+
+	other = stateAt(5, true, 0, -1)
+	assert.Equal(t, base[0], other[0])
+	assert.NotEqual(t, base[1], other[1])
+
+	// The batches put into holding and the bank are committed to at the
+	// height they are recorded at
+	held := stateAt(5, false, 20, -1)
+	assert.NotEqual(t, base[1], held[1])
+	assert.NotEqual(t, held[1], stateAt(5, false, 30, -1)[1])
+	other = stateAt(5, false, 0, 50)
+	assert.NotEqual(t, base[1], other[1])
+	assert.NotEqual(t, other[1], stateAt(5, false, 0, 60)[1])
+}
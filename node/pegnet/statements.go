@@ -0,0 +1,57 @@
+package pegnet
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// statementCache holds the prepared statements of a db by their query
+type statementCache struct {
+	sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// cacheInit guards the lazy creation of the statement caches, so a Pegnet
+// built without New can use them
+var cacheInit sync.Mutex
+
+func (p *Pegnet) statements() *statementCache {
+	cacheInit.Lock()
+	defer cacheInit.Unlock()
+	if p.stmts == nil {
+		p.stmts = &statementCache{stmts: make(map[string]*sql.Stmt)}
+	}
+	return p.stmts
+}
+
+// prepare returns the statement of the query in the context of the pending
+// tx. The statement is prepared on the db the first time the query is used
+// and reused by every later tx, instead of compiling the sql on every call of
+// the hot paths of the sync.
+func (p *Pegnet) prepare(tx *sql.Tx, query string) (*sql.Stmt, error) {
+	cache := p.statements()
+	cache.Lock()
+	defer cache.Unlock()
+
+	stmt, ok := cache.stmts[query]
+	if !ok {
+		var err error
+		if stmt, err = p.DB.Prepare(query); err != nil {
+			return nil, err
+		}
+		cache.stmts[query] = stmt
+	}
+	return tx.Stmt(stmt), nil
+}
+
+// Close closes the cached statements and the db
+func (p *Pegnet) Close() error {
+	cache := p.statements()
+	cache.Lock()
+	for query, stmt := range cache.stmts {
+		_ = stmt.Close()
+		delete(cache.stmts, query)
+	}
+	cache.Unlock()
+	return p.DB.Close()
+}
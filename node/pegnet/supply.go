@@ -0,0 +1,326 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	log "github.com/sirupsen/logrus"
+)
+
+// createTableSupplyHistory is a SQL string that creates the
+// "pn_supply_history" table. The table holds a snapshot of the total
+// issuance of every asset at the end of a synced height, so the supply
+// can be charted without replaying the chain.
+const createTableSupplyHistory = `CREATE TABLE IF NOT EXISTS "pn_supply_history" (
+	"height" INTEGER NOT NULL,
+	"token" TEXT NOT NULL,
+	"value" INTEGER NOT NULL,
+
+	UNIQUE("height", "token")
+);
+CREATE INDEX IF NOT EXISTS "idx_supply_history_height" ON "pn_supply_history"("height");
+`
+
+// createTableSupplyTotals is a SQL string that creates the "pn_supply_totals"
+// table. The table holds how the supply of every asset came to be: the total
+// amount issued and consumed by conversions, minted by FCT burns, and mined by
+// coinbases.
+const createTableSupplyTotals = `CREATE TABLE IF NOT EXISTS "pn_supply_totals" (
+	"token"			TEXT PRIMARY KEY,
+	"converted_in"	INTEGER NOT NULL DEFAULT 0,
+	"converted_out"	INTEGER NOT NULL DEFAULT 0, -- excludes refunds of PEG conversions
+	"burned"		INTEGER NOT NULL DEFAULT 0,
+	"mined"			INTEGER NOT NULL DEFAULT 0
+);
+`
+
+// SupplyHistoryLimit is the maximum amount of heights to return in one query
+const SupplyHistoryLimit = 1000
+
+// SupplyAtHeight is the issuance of every asset at the end of a height
+type SupplyAtHeight struct {
+	Height uint32
+	Supply map[fat2.PTicker]uint64
+}
+
+// CreateTableSupplyHistory is used to expose this table for unit tests
+func (p *Pegnet) CreateTableSupplyHistory() error {
+	_, err := p.DB.Exec(createTableSupplyHistory)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertSupplyHistory records the issuance of all assets as they are in the
+// pending tx at the given height.
+func (p *Pegnet) InsertSupplyHistory(tx *sql.Tx, height uint32) error {
+	issuance, err := p.SelectPendingIssuances(tx)
+	if err != nil {
+		return err
+	}
+
+	batch := newRowBatch(`INSERT INTO "pn_supply_history" ("height", "token", "value") VALUES`, 3, "")
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		batch.add(height, i.String(), issuance[i])
+	}
+	return batch.exec(p, tx)
+}
+
+// SelectSupplyHistory returns the recorded supply for all heights in the
+// range [start, stop], in ascending order. If the ticker is not invalid, only
+// that asset is returned. At most SupplyHistoryLimit heights are returned.
+func (p *Pegnet) SelectSupplyHistory(ctx context.Context, start, stop uint32, ticker fat2.PTicker) ([]SupplyAtHeight, error) {
+	if stop < start {
+		return nil, fmt.Errorf("invalid stop, must be >= start")
+	}
+
+	query := `SELECT "height", "token", "value" FROM "pn_supply_history"
+		WHERE "height" IN (
+			SELECT DISTINCT "height" FROM "pn_supply_history"
+			WHERE "height" >= ? AND "height" <= ? ORDER BY "height" ASC LIMIT ?
+		)`
+	args := []interface{}{start, stop, SupplyHistoryLimit}
+	if ticker != fat2.PTickerInvalid {
+		query += ` AND "token" = ?`
+		args = append(args, ticker.String())
+	}
+	query += ` ORDER BY "height" ASC;`
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []SupplyAtHeight
+	for rows.Next() {
+		var height uint32
+		var token string
+		var value uint64
+		if err := rows.Scan(&height, &token, &value); err != nil {
+			return nil, err
+		}
+
+		if len(res) == 0 || res[len(res)-1].Height != height {
+			res = append(res, SupplyAtHeight{Height: height, Supply: make(map[fat2.PTicker]uint64)})
+		}
+		if t := fat2.StringToTicker(token); t != fat2.PTickerInvalid {
+			res[len(res)-1].Supply[t] = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SupplyTotals are the components of the supply of an asset
+type SupplyTotals struct {
+	ConvertedIn  int64 `json:"convertedin"`
+	ConvertedOut int64 `json:"convertedout"`
+	Burned       int64 `json:"burned"`
+	Mined        int64 `json:"mined"`
+}
+
+// CreateTableSupplyTotals is used to expose this table for unit tests
+func (p *Pegnet) CreateTableSupplyTotals() error {
+	_, err := p.DB.Exec(createTableSupplyTotals)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertSupplyTotals adds the conversions, burns, and coinbases executed at
+// the given height to the supply totals. Must be called after all actions of
+// the height are recorded in the history.
+func (p *Pegnet) InsertSupplyTotals(tx *sql.Tx, height uint32) error {
+	return p.accumulateSupplyTotals(tx, "batch.executed = ?", height)
+}
+
+// supplyTotalsMigrate fills the supply totals from the existing history for
+// databases synced before the table existed
+func supplyTotalsMigrate(p *Pegnet) error {
+	var tmp []byte
+	err := p.DB.QueryRow(`SELECT "value" FROM "pn_metadata" WHERE "name" = 'supplytotals';`).Scan(&tmp)
+	if err == nil {
+		return nil // Already done
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := p.accumulateSupplyTotals(tx, "batch.executed > 0"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO "pn_metadata" ("name", "value") VALUES ('supplytotals', 1);`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Infof("Successful DB Migration supplyTotalsMigrate")
+	return nil
+}
+
+// accumulateSupplyTotals adds all applied history actions matching the
+// condition on the batch to the totals. The refunds of PEG conversions are
+// only known from the outputs, so the totals are summed up here rather than
+// in sql.
+func (p *Pegnet) accumulateSupplyTotals(tx *sql.Tx, condition string, args ...interface{}) error {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT tx.action_type, tx.from_asset, tx.from_amount, tx.to_asset, tx.to_amount, tx.outputs
+		FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND tx.action_type IN (%d, %d, %d) AND %s`,
+		Conversion, Coinbase, FCTBurn, condition), args...)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[string]*SupplyTotals)
+	get := func(token string) *SupplyTotals {
+		if totals[token] == nil {
+			totals[token] = new(SupplyTotals)
+		}
+		return totals[token]
+	}
+	for rows.Next() {
+		var action HistoryAction
+		var fromAsset, toAsset string
+		var fromAmount, toAmount int64
+		var outputs []byte
+		if err := rows.Scan(&action, &fromAsset, &fromAmount, &toAsset, &toAmount, &outputs); err != nil {
+			rows.Close()
+			return err
+		}
+
+		switch action {
+		case Conversion:
+			consumed := fromAmount
+			if toAsset == fat2.PTickerPEG.String() && len(outputs) > 0 {
+				var refund []HistoryTransactionOutput
+				if err := json.Unmarshal(outputs, &refund); err != nil {
+					rows.Close()
+					return err
+				}
+				for _, r := range refund {
+					consumed -= r.Amount
+				}
+			}
+			get(fromAsset).ConvertedOut += consumed
+			get(toAsset).ConvertedIn += toAmount
+		case Coinbase:
+			get(toAsset).Mined += toAmount
+		case FCTBurn:
+			get(toAsset).Burned += toAmount
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for token, t := range totals {
+		_, err := tx.Exec(`INSERT INTO "pn_supply_totals" ("token", "converted_in", "converted_out", "burned", "mined") VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT("token") DO UPDATE SET
+				"converted_in" = "converted_in" + "excluded"."converted_in",
+				"converted_out" = "converted_out" + "excluded"."converted_out",
+				"burned" = "burned" + "excluded"."burned",
+				"mined" = "mined" + "excluded"."mined";`,
+			token, t.ConvertedIn, t.ConvertedOut, t.Burned, t.Mined)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectSupplyTotals returns the supply totals of all assets
+func (p *Pegnet) SelectSupplyTotals() (map[fat2.PTicker]SupplyTotals, error) {
+	return p.selectSupplyTotals(p.DB)
+}
+
+func (Pegnet) selectSupplyTotals(q QueryAble) (map[fat2.PTicker]SupplyTotals, error) {
+	rows, err := q.Query(`SELECT "token", "converted_in", "converted_out", "burned", "mined" FROM "pn_supply_totals";`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[fat2.PTicker]SupplyTotals)
+	for rows.Next() {
+		var token string
+		var t SupplyTotals
+		if err := rows.Scan(&token, &t.ConvertedIn, &t.ConvertedOut, &t.Burned, &t.Mined); err != nil {
+			return nil, err
+		}
+		if ticker := fat2.StringToTicker(token); ticker != fat2.PTickerInvalid {
+			res[ticker] = t
+		}
+	}
+	return res, rows.Err()
+}
+
+// SupplyComposition splits the supply of an asset by how it came into
+// existence. `Conversions` is the amount converted into the asset minus the
+// amount converted out of it, which is negative if more was converted out.
+// `Unaccounted` is the part of the supply the totals do not explain, it is 0
+// unless the balances were changed outside of the history.
+type SupplyComposition struct {
+	Supply      uint64 `json:"supply"`
+	Burned      int64  `json:"burned"`
+	Conversions int64  `json:"conversions"`
+	Mined       int64  `json:"mined"`
+	Unaccounted int64  `json:"unaccounted"`
+}
+
+// SelectSupplyComposition returns the composition of the supply of every
+// asset and the height it is of. The supply and the totals are read in one
+// transaction, so a block that syncs in between does not show up as
+// unaccounted.
+func (p *Pegnet) SelectSupplyComposition(ctx context.Context) (map[fat2.PTicker]SupplyComposition, uint32, error) {
+	tx, err := p.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var height uint32
+	synced, err := p.SelectSynced(ctx, tx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, err
+	}
+	if synced != nil {
+		height = synced.Synced
+	}
+	issuance, err := p.selectIssuances(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	totals, err := p.selectSupplyTotals(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := make(map[fat2.PTicker]SupplyComposition, int(fat2.PTickerMax))
+	for i := fat2.PTickerInvalid + 1; i < fat2.PTickerMax; i++ {
+		t := totals[i]
+		c := SupplyComposition{
+			Supply:      issuance[i],
+			Burned:      t.Burned,
+			Conversions: t.ConvertedIn - t.ConvertedOut,
+			Mined:       t.Mined,
+		}
+		c.Unaccounted = int64(c.Supply) - c.Burned - c.Conversions - c.Mined
+		res[i] = c
+	}
+	return res, height, nil
+}
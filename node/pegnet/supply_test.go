@@ -0,0 +1,137 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SupplyHistory(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableSupplyHistory())
+
+	var adr factom.FAAddress
+	for height := uint32(1); height <= 5; height++ {
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		_, err = p.AddToBalance(tx, &adr, fat2.PTickerUSD, 10)
+		require.NoError(t, err)
+		require.NoError(t, p.InsertSupplyHistory(tx, height))
+		require.NoError(t, tx.Commit())
+	}
+
+	history, err := p.SelectSupplyHistory(context.Background(), 2, 4, fat2.PTickerInvalid)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, h := range history {
+		assert.Equal(t, uint32(i+2), h.Height)
+		assert.Equal(t, uint64(10*(i+2)), h.Supply[fat2.PTickerUSD])
+		assert.Equal(t, uint64(0), h.Supply[fat2.PTickerPEG])
+		assert.Len(t, h.Supply, int(fat2.PTickerMax-1))
+	}
+
+	history, err = p.SelectSupplyHistory(context.Background(), 0, 100, fat2.PTickerUSD)
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+	assert.Len(t, history[4].Supply, 1)
+	assert.Equal(t, uint64(50), history[4].Supply[fat2.PTickerUSD])
+
+	_, err = p.SelectSupplyHistory(context.Background(), 4, 2, fat2.PTickerInvalid)
+	assert.Error(t, err)
+}
+
+func TestPegnet_SupplyTotals(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableSupplyTotals())
+
+	var a factom.FAAddress
+	for i := 0; i < 2; i++ {
+		height := uint32(100 + i)
+		var hash factom.Bytes32
+		hash[0] = byte(i + 1)
+		batch := &fat2.TransactionBatch{
+			Entry: factom.Entry{Hash: &hash, Timestamp: time.Now()},
+			Transactions: []fat2.Transaction{
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG}, Conversion: fat2.PTickerUSD},
+				{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 8, Type: fat2.PTickerUSD}, Conversion: fat2.PTickerPEG},
+			},
+		}
+
+		tx, err := p.DB.Begin()
+		require.NoError(t, err)
+		require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, height))
+		require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, int64(height)))
+		require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 0, 20, 4, 1))
+		require.NoError(t, p.SetTransactionHistoryPEGConvertedRequestAmount(tx, batch, 1, 12, 2, 1, 4))
+		require.NoError(t, p.InsertSupplyTotals(tx, height))
+		require.NoError(t, tx.Commit())
+	}
+
+	totals, err := p.SelectSupplyTotals()
+	require.NoError(t, err)
+	assert.Equal(t, map[fat2.PTicker]SupplyTotals{
+		fat2.PTickerPEG: {ConvertedIn: 24, ConvertedOut: 10},
+		fat2.PTickerUSD: {ConvertedIn: 40, ConvertedOut: 12},
+	}, totals)
+}
+
+func TestPegnet_SupplyComposition(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableMetadata())
+	require.NoError(t, p.CreateTableSyncVersion())
+	require.NoError(t, p.CreateTableTxHistory())
+	require.NoError(t, p.CreateTableSupplyTotals())
+
+	var a factom.FAAddress
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{
+		Entry: factom.Entry{Hash: &hash, Timestamp: time.Now()},
+		Transactions: []fat2.Transaction{
+			{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG}, Conversion: fat2.PTickerUSD},
+		},
+	}
+
+	// 10 FCT burned, 5 PEG converted into 20 pUSD, and 3 pUSD that
+	// appeared outside of the history
+	var id factom.Bytes32
+	id[0] = 2
+	burn := factom.FactoidTransaction{
+		FactoidTransactionHeader: factom.FactoidTransactionHeader{TransactionID: &id, TimestampSalt: time.Now()},
+		FCTInputs:                []factom.FactoidTransactionIO{{Amount: 10, Address: factom.Bytes32{1}}},
+	}
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, batch, 100))
+	require.NoError(t, p.SetTransactionHistoryConvertedAmount(tx, batch, 0, 20, 4, 1))
+	require.NoError(t, p.InsertFCTBurn(tx, &id, burn, 100))
+	require.NoError(t, p.InsertSupplyTotals(tx, 100))
+	_, err = p.AddToBalance(tx, &a, fat2.PTickerFCT, 10)
+	require.NoError(t, err)
+	_, err = p.AddToBalance(tx, &a, fat2.PTickerUSD, 23)
+	require.NoError(t, err)
+	require.NoError(t, p.InsertSynced(tx, &BlockSync{Synced: 100}))
+	require.NoError(t, tx.Commit())
+
+	composition, height, err := p.SelectSupplyComposition(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(100), height)
+	assert.Equal(t, SupplyComposition{Supply: 10, Burned: 10}, composition[fat2.PTickerFCT])
+	assert.Equal(t, SupplyComposition{Conversions: -5, Unaccounted: 5}, composition[fat2.PTickerPEG])
+	assert.Equal(t, SupplyComposition{Supply: 23, Conversions: 20, Unaccounted: 3}, composition[fat2.PTickerUSD])
+	assert.Equal(t, SupplyComposition{}, composition[fat2.PTickerEUR])
+}
@@ -1,6 +1,7 @@
 package pegnet
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -86,6 +87,18 @@ func (p *Pegnet) SelectTransactionBatchesInHoldingAtHeight(height uint64) ([]*fa
 	return txBatches, nil
 }
 
+// SelectUncommittedHoldings returns the amount of batches in holding without
+// a row in "pn_history_txbatch". A batch is committed to by the state hash of
+// the height of its history row, including the batches that wait for a
+// future height, so a database that was not modified outside of the sync has
+// none.
+func (p *Pegnet) SelectUncommittedHoldings(ctx context.Context) (int, error) {
+	var n int
+	err := p.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM "pn_transaction_batch_holding" h
+		WHERE NOT EXISTS (SELECT 1 FROM "pn_history_txbatch" b WHERE b."entry_hash" = h."entry_hash");`).Scan(&n)
+	return n, err
+}
+
 func (p *Pegnet) DoesTransactionExist(entryhash factom.Bytes32) (bool, error) {
 	var found []byte
 	query := `SELECT "entry_hash" FROM "pn_address_transactions" WHERE "entry_hash" == ?;`
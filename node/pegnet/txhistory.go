@@ -1,7 +1,9 @@
 package pegnet
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -29,7 +31,11 @@ type HistoryTransaction struct {
 	FromAmount  int64                      `json:"fromamount"`
 	ToAsset     string                     `json:"toasset,omitempty"`
 	ToAmount    int64                      `json:"toamount,omitempty"`
+	FromRate    uint64                     `json:"fromrate,omitempty"` // the rate of the FromAsset used to execute a conversion
+	ToRate      uint64                     `json:"torate,omitempty"`   // the rate of the ToAsset used to execute a conversion
 	Outputs     []HistoryTransactionOutput `json:"outputs,omitempty"`
+	Metadata    json.RawMessage            `json:"metadata,omitempty"` // the metadata of a transfer or conversion
+	Reason      string                     `json:"reason,omitempty"`   // the reason of a refund
 }
 
 // HistoryTransactionOutput is an entry of a transfer's outputs
@@ -59,6 +65,7 @@ const createTableTxHistoryBatch = `CREATE TABLE IF NOT EXISTS "pn_history_txbatc
 CREATE INDEX IF NOT EXISTS "idx_history_txbatch_entry_hash" ON "pn_history_txbatch"("entry_hash");
 CREATE INDEX IF NOT EXISTS "idx_history_txbatch_timestamp" ON "pn_history_txbatch"("timestamp");
 CREATE INDEX IF NOT EXISTS "idx_history_txbatch_height" ON "pn_history_txbatch"("height");
+CREATE INDEX IF NOT EXISTS "idx_history_txbatch_executed" ON "pn_history_txbatch"("executed");
 `
 
 const createTableTxHistoryTx = `CREATE TABLE IF NOT EXISTS "pn_history_transaction" (
@@ -71,6 +78,10 @@ const createTableTxHistoryTx = `CREATE TABLE IF NOT EXISTS "pn_history_transacti
 	"to_asset"		STRING NOT NULL,	-- used for NOT transfers
 	"to_amount"		INTEGER NOT NULL,	-- used for NOT transfers
 	"outputs"		BLOB NOT NULL,		-- used for transfers only
+	"from_rate"		INTEGER NOT NULL DEFAULT 0,	-- rate used to execute a conversion
+	"to_rate"		INTEGER NOT NULL DEFAULT 0,	-- rate used to execute a conversion
+	"metadata"		BLOB,				-- the json metadata of transfers and conversions
+	"txid"			TEXT,				-- [TxIndex]-[BatchHash]
 
 	PRIMARY KEY("entry_hash", "tx_index"),
 	FOREIGN KEY("entry_hash") REFERENCES "pn_history_txbatch"
@@ -90,6 +101,19 @@ const createTableTxHistoryLookup = `CREATE TABLE IF NOT EXISTS "pn_history_looku
 CREATE INDEX IF NOT EXISTS "idx_history_lookup_address" ON "pn_history_lookup"("address");
 CREATE INDEX IF NOT EXISTS "idx_history_lookup_entry_index" ON "pn_history_lookup"("entry_hash", "tx_index");`
 
+// The txid index is created after txhistoryMigrateTxID added the column
+const createIndexTxHistoryTxID = `CREATE INDEX IF NOT EXISTS "idx_history_transaction_txid" ON "pn_history_transaction"("txid");`
+
+// CreateTableTxHistory is used to expose the history tables for unit tests
+func (p *Pegnet) CreateTableTxHistory() error {
+	for _, sql := range []string{createTableTxHistoryBatch, createTableTxHistoryTx, createTableTxHistoryLookup, createIndexTxHistoryTxID} {
+		if _, err := p.DB.Exec(sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func txhistoryMigrateLookup1(p *Pegnet) {
 	var sql string
 	if err := p.DB.QueryRow(`SELECT sql FROM sqlite_master WHERE name = 'pn_history_lookup'`).Scan(&sql); err != nil {
@@ -114,10 +138,68 @@ COMMIT;
 	}
 }
 
+// txhistoryMigrateRates adds the columns to store the rates used to execute
+// a conversion
+func txhistoryMigrateRates(p *Pegnet) error {
+	for _, column := range []string{"from_rate", "to_rate"} {
+		exists, err := p.columnExists("pn_history_transaction", column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			_, err := p.DB.Exec(fmt.Sprintf(`ALTER TABLE "pn_history_transaction" ADD "%s" INTEGER NOT NULL DEFAULT 0;`, column))
+			if err != nil {
+				return err
+			}
+			log.Infof("Successful DB Migration txhistoryMigrateRates %s", column)
+		}
+	}
+	return nil
+}
+
+// txhistoryMigrateMetadata adds the column to store the metadata of
+// transactions. Transactions synced before the migration have no metadata.
+func txhistoryMigrateMetadata(p *Pegnet) error {
+	exists, err := p.columnExists("pn_history_transaction", "metadata")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := p.DB.Exec(`ALTER TABLE "pn_history_transaction" ADD "metadata" BLOB;`); err != nil {
+			return err
+		}
+		log.Infof("Successful DB Migration txhistoryMigrateMetadata, resync to include the metadata of older transactions")
+	}
+	return nil
+}
+
+// txhistoryMigrateTxID adds the column to store the txid of the actions
+// and fills it in for the actions synced before the migration
+func txhistoryMigrateTxID(p *Pegnet) error {
+	exists, err := p.columnExists("pn_history_transaction", "txid")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := p.DB.Exec(`ALTER TABLE "pn_history_transaction" ADD "txid" TEXT;`); err != nil {
+			return err
+		}
+		if _, err := p.DB.Exec(`UPDATE "pn_history_transaction" SET "txid" = "tx_index" || '-' || LOWER(HEX("entry_hash"));`); err != nil {
+			return err
+		}
+		log.Infof("Successful DB Migration txhistoryMigrateTxID")
+	}
+	_, err = p.DB.Exec(createIndexTxHistoryTxID)
+	return err
+}
+
 // only add a lookup reference if one doesn't already exist
 const insertLookupQuery = `INSERT INTO pn_history_lookup (entry_hash, tx_index, address) VALUES (?, ?, ?) ON CONFLICT DO NOTHING;`
 
 func (p *Pegnet) historySelectHelper(field string, data interface{}, options HistoryQueryOptions) ([]HistoryTransaction, int, error) {
+	if options.Refund {
+		return p.refundSelectHelper(field, data, options)
+	}
 	countQuery, dataQuery, err := historyQueryBuilder(field, options)
 	if err != nil {
 		return nil, 0, err
@@ -153,16 +235,66 @@ func (p *Pegnet) SelectTransactionHistoryActionsByHash(hash *factom.Bytes32, opt
 	return p.historySelectHelper("entry_hash", hash[:], options)
 }
 
+// HashesLimit is the maximum amount of hashes that can be looked up at once,
+// it stays below the host parameter limit of sqlite
+const HashesLimit = 500
+
+// SelectTransactionHistoryActionsByHashes returns the transactions of the
+// hashes in one query, by hash. A hash that is not in the history is not in
+// the map.
+func (p *Pegnet) SelectTransactionHistoryActionsByHashes(ctx context.Context, hashes []*factom.Bytes32) (map[factom.Bytes32][]HistoryTransaction, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	if len(hashes) > HashesLimit {
+		return nil, fmt.Errorf("at most %d hashes can be looked up at once", HashesLimit)
+	}
+	args := make([]interface{}, len(hashes))
+	for i, hash := range hashes {
+		args[i] = hash[:]
+	}
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash IN (?%s)
+		ORDER BY batch.history_id ASC, tx.tx_index ASC`, historyQueryFields, strings.Repeat(", ?", len(hashes)-1)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions, err := turnRowsIntoHistoryTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[factom.Bytes32][]HistoryTransaction)
+	for _, action := range actions {
+		res[*action.Hash] = append(res[*action.Hash], action)
+	}
+	return res, nil
+}
+
 // SelectTransactionHistoryActionsByAddress uses the lookup table to retrieve all transactions that have
 // the specified address in either inputs or outputs
 func (p *Pegnet) SelectTransactionHistoryActionsByAddress(addr *factom.FAAddress, options HistoryQueryOptions) ([]HistoryTransaction, int, error) {
 	return p.historySelectHelper("address", addr[:], options)
 }
 
-// SelectTransactionHistoryActionsByTxID uses the lookup table to retrieve all transactions that have
-// the specified txid. A TxID is an entryhash + a transaction index
-func (p *Pegnet) SelectTransactionHistoryActionsByTxID(hash *factom.Bytes32, options HistoryQueryOptions) ([]HistoryTransaction, int, error) {
-	return p.historySelectHelper("entry_hash", hash[:], options)
+// SelectTransactionHistoryActionsByTxID retrieves the transaction with the
+// specified txid. A TxID is a transaction index + an entryhash, the index may
+// be padded.
+func (p *Pegnet) SelectTransactionHistoryActionsByTxID(txid string, options HistoryQueryOptions) ([]HistoryTransaction, int, error) {
+	index, hash, err := SplitTxID(txid)
+	if err != nil {
+		return nil, 0, err
+	}
+	if options.Refund {
+		batchHash := new(factom.Bytes32)
+		if err := batchHash.Set(hash); err != nil {
+			return nil, 0, err
+		}
+		options.UseTxIndex, options.TxIndex = true, index
+		return p.refundSelectHelper("entry_hash", batchHash[:], options)
+	}
+	return p.historySelectHelper("txid", FormatTxID(index, strings.ToLower(hash)), options)
 }
 
 // SelectTransactionHistoryActionsByHeight returns all transactions that were **entered** at the specified height.
@@ -170,11 +302,75 @@ func (p *Pegnet) SelectTransactionHistoryActionsByHeight(height uint32, options
 	return p.historySelectHelper("height", height, options)
 }
 
+// SelectTransactionHistoryActionsByHeightRange returns all transactions that were **entered**
+// in the height range [start, stop], in the order they were entered.
+func (p *Pegnet) SelectTransactionHistoryActionsByHeightRange(ctx context.Context, start, stop uint32) ([]HistoryTransaction, error) {
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.height >= ? AND batch.height <= ?
+		ORDER BY batch.history_id ASC, tx.tx_index ASC`, historyQueryFields), start, stop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return turnRowsIntoHistoryTransactions(rows)
+}
+
+// SelectTransactionHistoryActionsByAddressExecuted returns all applied actions that have the
+// specified address in either inputs or outputs, in the order they were applied
+func (p *Pegnet) SelectTransactionHistoryActionsByAddressExecuted(ctx context.Context, addr *factom.FAAddress) ([]HistoryTransaction, error) {
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx
+		WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash
+		AND batch.executed > 0
+		ORDER BY batch.executed ASC, batch.history_id ASC, tx.tx_index ASC`, historyQueryFields), addr[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return turnRowsIntoHistoryTransactions(rows)
+}
+
+// SelectTransactionHistoryActionsByAddressPending returns all actions that
+// have the specified address in either inputs or outputs and are in a block,
+// but are not executed yet. These are conversions and time-locked batches
+// waiting in holding.
+func (p *Pegnet) SelectTransactionHistoryActionsByAddressPending(ctx context.Context, addr *factom.FAAddress) ([]HistoryTransaction, error) {
+	rows, err := p.DB.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx
+		WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash
+		AND batch.executed = 0
+		ORDER BY batch.history_id ASC, tx.tx_index ASC`, historyQueryFields), addr[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return turnRowsIntoHistoryTransactions(rows)
+}
+
+// SelectTransactionHistoryActionsExecuted returns all actions that were
+// applied at the given height, in the order they were recorded
+func (p *Pegnet) SelectTransactionHistoryActionsExecuted(q QueryAble, height uint32) ([]HistoryTransaction, error) {
+	if q == nil {
+		q = p.DB
+	}
+	rows, err := q.Query(fmt.Sprintf(`SELECT %s FROM pn_history_txbatch batch, pn_history_transaction tx
+		WHERE batch.entry_hash = tx.entry_hash AND batch.executed = ?
+		ORDER BY batch.history_id ASC, tx.tx_index ASC`, historyQueryFields), height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return turnRowsIntoHistoryTransactions(rows)
+}
+
 // SelectTransactionHistoryStatus returns the status of a transaction:
 // `-1` for a failed transaction, `0` for a pending transactions,
 // `height` for the block in which it was applied otherwise
-func (p *Pegnet) SelectTransactionHistoryStatus(hash *factom.Bytes32) (uint32, uint32, error) {
-	var height, executed uint32
+func (p *Pegnet) SelectTransactionHistoryStatus(hash *factom.Bytes32) (uint32, int32, error) {
+	var height uint32
+	var executed int32
 	err := p.DB.QueryRow("SELECT height, executed FROM pn_history_txbatch WHERE entry_hash = ?", hash[:]).Scan(&height, &executed)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -187,7 +383,7 @@ func (p *Pegnet) SelectTransactionHistoryStatus(hash *factom.Bytes32) (uint32, u
 
 // SetTransactionHistoryExecuted updates a transaction's executed status
 func (p *Pegnet) SetTransactionHistoryExecuted(tx *sql.Tx, txbatch *fat2.TransactionBatch, executed int64) error {
-	stmt, err := tx.Prepare(`UPDATE "pn_history_txbatch" SET executed = ? WHERE entry_hash = ?`)
+	stmt, err := p.prepare(tx, `UPDATE "pn_history_txbatch" SET executed = ? WHERE entry_hash = ?`)
 	if err != nil {
 		return err
 	}
@@ -198,14 +394,15 @@ func (p *Pegnet) SetTransactionHistoryExecuted(tx *sql.Tx, txbatch *fat2.Transac
 	return nil
 }
 
-// SetTransactionHistoryConvertedAmount updates a conversion with the actual conversion value.
+// SetTransactionHistoryConvertedAmount updates a conversion with the actual conversion value
+// and the rates used to calculate it.
 // This is done in the same SQL Transaction as updating its executed status
-func (p *Pegnet) SetTransactionHistoryConvertedAmount(tx *sql.Tx, txbatch *fat2.TransactionBatch, index int, amount int64) error {
-	stmt, err := tx.Prepare(`UPDATE "pn_history_transaction" SET to_amount = ? WHERE entry_hash = ? AND tx_index = ?`)
+func (p *Pegnet) SetTransactionHistoryConvertedAmount(tx *sql.Tx, txbatch *fat2.TransactionBatch, index int, amount int64, fromRate, toRate uint64) error {
+	stmt, err := p.prepare(tx, `UPDATE "pn_history_transaction" SET to_amount = ?, from_rate = ?, to_rate = ? WHERE entry_hash = ? AND tx_index = ?`)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(amount, txbatch.Entry.Hash[:], index)
+	_, err = stmt.Exec(amount, fromRate, toRate, txbatch.Entry.Hash[:], index)
 	if err != nil {
 		return err
 	}
@@ -213,10 +410,10 @@ func (p *Pegnet) SetTransactionHistoryConvertedAmount(tx *sql.Tx, txbatch *fat2.
 }
 
 // SetTransactionHistoryPEGConvertedRequestAmount updates a peg conversion request
-// with the actual amount of PEG received and the refund amount. The refund amount
-// will appear as an output.
+// with the actual amount of PEG received, the refund amount, and the rates used.
+// The refund amount will appear as an output.
 // This is done in the same SQL Transaction as updating its executed status
-func (p *Pegnet) SetTransactionHistoryPEGConvertedRequestAmount(tx *sql.Tx, txbatch *fat2.TransactionBatch, index int, pegAmount, refundAmount int64) error {
+func (p *Pegnet) SetTransactionHistoryPEGConvertedRequestAmount(tx *sql.Tx, txbatch *fat2.TransactionBatch, index int, pegAmount, refundAmount int64, fromRate, toRate uint64) error {
 	outputs := make([]HistoryTransactionOutput, 1)
 	outputs[0] = HistoryTransactionOutput{
 		Address: txbatch.Transactions[index].Input.Address,
@@ -227,11 +424,11 @@ func (p *Pegnet) SetTransactionHistoryPEGConvertedRequestAmount(tx *sql.Tx, txba
 		return err
 	}
 
-	stmt, err := tx.Prepare(`UPDATE "pn_history_transaction" SET to_amount = ?, outputs = ? WHERE entry_hash = ? AND tx_index = ?`)
+	stmt, err := p.prepare(tx, `UPDATE "pn_history_transaction" SET to_amount = ?, outputs = ?, from_rate = ?, to_rate = ? WHERE entry_hash = ? AND tx_index = ?`)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(pegAmount, out, txbatch.Entry.Hash[:], index)
+	_, err = stmt.Exec(pegAmount, out, fromRate, toRate, txbatch.Entry.Hash[:], index)
 	if err != nil {
 		return err
 	}
@@ -240,7 +437,7 @@ func (p *Pegnet) SetTransactionHistoryPEGConvertedRequestAmount(tx *sql.Tx, txba
 
 // InsertTransactionHistoryTxBatch inserts a transaction from the transaction chain into the history system
 func (p *Pegnet) InsertTransactionHistoryTxBatch(tx *sql.Tx, blockorder int, txbatch *fat2.TransactionBatch, height uint32) error {
-	stmt, err := tx.Prepare(`INSERT INTO "pn_history_txbatch"
+	stmt, err := p.prepare(tx, `INSERT INTO "pn_history_txbatch"
                 (entry_hash, height, blockorder, timestamp, executed) VALUES
                 (?, ?, ?, ?, ?)`)
 	if err != nil {
@@ -251,19 +448,14 @@ func (p *Pegnet) InsertTransactionHistoryTxBatch(tx *sql.Tx, blockorder int, txb
 		return err
 	}
 
-	txStatement, err := tx.Prepare(`INSERT INTO "pn_history_transaction"
-                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs) VALUES
-                (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
-	if err != nil {
-		return err
-	}
-
-	lookup, err := tx.Prepare(insertLookupQuery)
-	if err != nil {
-		return err
-	}
+	txs := newRowBatch(`INSERT INTO "pn_history_transaction"
+                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs, metadata, txid) VALUES`, 11, "")
+	lookups := newRowBatch(`INSERT INTO pn_history_lookup (entry_hash, tx_index, address) VALUES`, 3, "ON CONFLICT DO NOTHING")
 
-	for index, action := range txbatch.Transactions {
+	for index := range txbatch.Transactions {
+		// the rows are written after the loop, so the addresses must not
+		// be copies that are reused
+		action := &txbatch.Transactions[index]
 		var typ HistoryAction
 		if action.IsConversion() {
 			typ = Conversion
@@ -271,53 +463,57 @@ func (p *Pegnet) InsertTransactionHistoryTxBatch(tx *sql.Tx, blockorder int, txb
 			typ = Transfer
 		}
 
-		if _, err = lookup.Exec(txbatch.Entry.Hash[:], index, action.Input.Address[:]); err != nil {
-			return err
+		lookups.add(txbatch.Entry.Hash[:], index, action.Input.Address[:])
+
+		var metadata []byte
+		if action.Metadata != nil {
+			if metadata, err = json.Marshal(action.Metadata); err != nil {
+				return err
+			}
 		}
 
 		if action.IsConversion() {
-			_, err = txStatement.Exec(txbatch.Entry.Hash[:], index, typ,
+			txs.add(txbatch.Entry.Hash[:], index, typ,
 				action.Input.Address[:], action.Input.Type.String(), action.Input.Amount, // from
-				action.Conversion.String(), 0, "") // to
-			if err != nil {
-				return err
-			}
+				action.Conversion.String(), 0, "", metadata, // to
+				FormatTxID(index, txbatch.Entry.Hash.String()))
 		} else {
 			// json encode the outputs
 			outputs := make([]HistoryTransactionOutput, len(action.Transfers))
 			for i, transfer := range action.Transfers {
 				outputs[i] = HistoryTransactionOutput{Address: transfer.Address, Amount: int64(transfer.Amount)}
-				if _, err = lookup.Exec(txbatch.Entry.Hash[:], index, transfer.Address[:]); err != nil {
-					return err
-				}
+				lookups.add(txbatch.Entry.Hash[:], index, action.Transfers[i].Address[:])
 			}
 			var outputData []byte
 			if outputData, err = json.Marshal(outputs); err != nil {
 				return err
 			}
 
-			if _, err = txStatement.Exec(txbatch.Entry.Hash[:], index, typ,
+			txs.add(txbatch.Entry.Hash[:], index, typ,
 				action.Input.Address[:], action.Input.Type.String(), action.Input.Amount,
-				"", 0, outputData); err != nil {
-				return err
-			}
+				"", 0, outputData, metadata, FormatTxID(index, txbatch.Entry.Hash.String()))
 		}
 	}
 
+	for _, batch := range []*rowBatch{txs, lookups} {
+		if err := batch.exec(p, tx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // InsertFCTBurn inserts a payout for an FCT burn into the system.
 // Note that from_asset and to_asset are hardcoded
 func (p *Pegnet) InsertFCTBurn(tx *sql.Tx, fBlockHash *factom.Bytes32, burn factom.FactoidTransaction, height uint32) error {
-	stmt, err := tx.Prepare(`INSERT INTO "pn_history_txbatch"
+	stmt, err := p.prepare(tx, `INSERT INTO "pn_history_txbatch"
                 (entry_hash, height, blockorder, timestamp, executed) VALUES
                 (?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 
-	lookup, err := tx.Prepare(insertLookupQuery)
+	lookup, err := p.prepare(tx, insertLookupQuery)
 	if err != nil {
 		return err
 	}
@@ -327,14 +523,14 @@ func (p *Pegnet) InsertFCTBurn(tx *sql.Tx, fBlockHash *factom.Bytes32, burn fact
 		return err
 	}
 
-	burnStatement, err := tx.Prepare(`INSERT INTO "pn_history_transaction"
-                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs) VALUES
-                (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	burnStatement, err := p.prepare(tx, `INSERT INTO "pn_history_transaction"
+                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs, txid) VALUES
+                (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 
-	if _, err = burnStatement.Exec(burn.TransactionID[:], 0, FCTBurn, burn.FCTInputs[0].Address[:], "FCT", burn.FCTInputs[0].Amount, "pFCT", burn.FCTInputs[0].Amount, ""); err != nil {
+	if _, err = burnStatement.Exec(burn.TransactionID[:], 0, FCTBurn, burn.FCTInputs[0].Address[:], "FCT", burn.FCTInputs[0].Amount, "pFCT", burn.FCTInputs[0].Amount, "", FormatTxID(0, burn.TransactionID.String())); err != nil {
 		return err
 	}
 
@@ -348,38 +544,32 @@ func (p *Pegnet) InsertFCTBurn(tx *sql.Tx, fBlockHash *factom.Bytes32, burn fact
 // InsertCoinbase inserts the payouts from mining into the history system.
 // There is one transaction per winning OPR, with the entry hash pointing to that specific opr
 func (p *Pegnet) InsertCoinbase(tx *sql.Tx, winner *grader.GradingOPR, addr []byte, timestamp time.Time) error {
-	stmt, err := tx.Prepare(`INSERT INTO "pn_history_txbatch"
-                (entry_hash, height, blockorder, timestamp, executed) VALUES
-                (?, ?, ?, ?, ?)`)
-	if err != nil {
-		return err
-	}
-
-	lookup, err := tx.Prepare(insertLookupQuery)
-	if err != nil {
-		return err
-	}
-
-	_, err = stmt.Exec(winner.EntryHash, winner.OPR.GetHeight(), 0, timestamp.Unix(), winner.OPR.GetHeight())
-	if err != nil {
-		return err
-	}
-
-	coinbaseStatement, err := tx.Prepare(`INSERT INTO "pn_history_transaction"
-                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs) VALUES
-                (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
-	if err != nil {
-		return err
-	}
-
-	_, err = coinbaseStatement.Exec(winner.EntryHash, 0, Coinbase, addr, "", 0, "PEG", winner.Payout(), "")
-	if err != nil {
-		return err
-	}
+	var adr factom.FAAddress
+	copy(adr[:], addr)
+	return p.InsertCoinbases(tx, []*grader.GradingOPR{winner}, []factom.FAAddress{adr}, timestamp)
+}
 
-	if _, err = lookup.Exec(winner.EntryHash, 0, addr); err != nil {
-		return err
+// InsertCoinbases inserts the payouts of all winners of a block, the address
+// of each winner is at the same index
+func (p *Pegnet) InsertCoinbases(tx *sql.Tx, winners []*grader.GradingOPR, addrs []factom.FAAddress, timestamp time.Time) error {
+	batches := newRowBatch(`INSERT INTO "pn_history_txbatch"
+                (entry_hash, height, blockorder, timestamp, executed) VALUES`, 5, "")
+	coinbases := newRowBatch(`INSERT INTO "pn_history_transaction"
+                (entry_hash, tx_index, action_type, from_address, from_asset, from_amount, to_asset, to_amount, outputs, txid) VALUES`, 10, "")
+	lookups := newRowBatch(`INSERT INTO pn_history_lookup (entry_hash, tx_index, address) VALUES`, 3, "ON CONFLICT DO NOTHING")
+
+	for i, winner := range winners {
+		addr := addrs[i][:]
+		batches.add(winner.EntryHash, winner.OPR.GetHeight(), 0, timestamp.Unix(), winner.OPR.GetHeight())
+		coinbases.add(winner.EntryHash, 0, Coinbase, addr, "", 0, "PEG", winner.Payout(), "",
+			FormatTxID(0, hex.EncodeToString(winner.EntryHash)))
+		lookups.add(winner.EntryHash, 0, addr)
+	}
+
+	for _, batch := range []*rowBatch{batches, coinbases, lookups} {
+		if err := batch.exec(p, tx); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
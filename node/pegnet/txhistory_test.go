@@ -0,0 +1,208 @@
+package pegnet_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_HistoryMetadata(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}},
+			Metadata:  json.RawMessage(`{"memo":"1234"}`)},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, tx.Commit())
+
+	actions, _, err := p.SelectTransactionHistoryActionsByHash(&hash, HistoryQueryOptions{})
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+	require.JSONEq(t, `{"memo":"1234"}`, string(actions[0].Metadata))
+	require.Nil(t, actions[1].Metadata)
+}
+
+func TestPegnet_HistoryPending(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var held, executed factom.Bytes32
+	held[0], executed[0] = 1, 2
+	heldBatch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &held, Timestamp: time.Unix(100, 0)}}
+	heldBatch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+	executedBatch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &executed, Timestamp: time.Unix(100, 0)}}
+	executedBatch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 10}}},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, heldBatch, 100))
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 1, executedBatch, 100))
+	require.NoError(t, p.SetTransactionHistoryExecuted(tx, executedBatch, 100))
+	require.NoError(t, tx.Commit())
+
+	actions, err := p.SelectTransactionHistoryActionsByAddressPending(context.Background(), &a)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	require.Equal(t, held, *actions[0].Hash)
+
+	actions, err = p.SelectTransactionHistoryActionsByAddressPending(context.Background(), &b)
+	require.NoError(t, err)
+	require.Len(t, actions, 0)
+}
+
+func TestPegnet_HistoryTxID(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 0xAB
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, tx.Commit())
+
+	actions, _, err := p.SelectTransactionHistoryActionsByAddress(&b, HistoryQueryOptions{})
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	require.Equal(t, FormatTxID(0, hash.String()), actions[0].TxID)
+
+	// padded indexes and upper case hashes are the same txid
+	actions, count, err := p.SelectTransactionHistoryActionsByTxID("001-"+strings.ToUpper(hash.String()), HistoryQueryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, 1, actions[0].TxIndex)
+	require.Equal(t, FormatTxID(1, hash.String()), actions[0].TxID)
+
+	_, _, err = p.SelectTransactionHistoryActionsByTxID("1", HistoryQueryOptions{})
+	require.Error(t, err)
+}
+
+func TestPegnet_HistoryDirection(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var hash factom.Bytes32
+	hash[0] = 1
+	batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: &hash, Timestamp: time.Unix(100, 0)}}
+	batch.Transactions = []fat2.Transaction{
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: b, Amount: 2, Type: fat2.PTickerPEG},
+			Transfers: []fat2.AddressAmountTuple{{Address: a, Amount: 2}}},
+		{Input: fat2.TypedAddressAmountTuple{Address: a, Amount: 5, Type: fat2.PTickerPEG},
+			Conversion: fat2.PTickerUSD},
+	}
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, 0, batch, 100))
+	require.NoError(t, tx.Commit())
+
+	actions, count, err := p.SelectTransactionHistoryActionsByAddress(&a, HistoryQueryOptions{Direction: DirectionSent})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, 0, actions[0].TxIndex)
+	require.Equal(t, 2, actions[1].TxIndex)
+
+	actions, count, err = p.SelectTransactionHistoryActionsByAddress(&a, HistoryQueryOptions{Direction: DirectionReceived})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Equal(t, 1, actions[0].TxIndex)
+	require.Equal(t, 2, actions[1].TxIndex)
+
+	_, count, err = p.SelectTransactionHistoryActionsByAddress(&a, HistoryQueryOptions{Direction: DirectionBoth})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	_, _, err = p.SelectTransactionHistoryActionsByHash(&hash, HistoryQueryOptions{Direction: DirectionSent})
+	require.Error(t, err)
+}
+
+func TestPegnet_HistoryByHashes(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxHistory())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	var first, second, missing factom.Bytes32
+	first[0], second[0], missing[0] = 1, 2, 3
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	for i, hash := range []*factom.Bytes32{&first, &second} {
+		batch := &fat2.TransactionBatch{Entry: factom.Entry{Hash: hash, Timestamp: time.Unix(100, 0)}}
+		for j := 0; j <= i; j++ {
+			batch.Transactions = append(batch.Transactions, fat2.Transaction{
+				Input:     fat2.TypedAddressAmountTuple{Address: a, Amount: 10, Type: fat2.PTickerPEG},
+				Transfers: []fat2.AddressAmountTuple{{Address: b, Amount: 10}}})
+		}
+		require.NoError(t, p.InsertTransactionHistoryTxBatch(tx, i, batch, 100))
+	}
+	require.NoError(t, tx.Commit())
+
+	found, err := p.SelectTransactionHistoryActionsByHashes(context.Background(), []*factom.Bytes32{&second, &missing, &first})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	require.Len(t, found[first], 1)
+	require.Len(t, found[second], 2)
+	require.Equal(t, 0, found[second][0].TxIndex)
+	require.Equal(t, 1, found[second][1].TxIndex)
+	_, ok := found[missing]
+	require.False(t, ok)
+
+	found, err = p.SelectTransactionHistoryActionsByHashes(context.Background(), nil)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	_, err = p.SelectTransactionHistoryActionsByHashes(context.Background(), make([]*factom.Bytes32, HashesLimit+1))
+	require.Error(t, err)
+}
@@ -26,11 +26,59 @@ const (
 	Coinbase
 	// FCTBurn is a pFCT payout for burning FCT on factom
 	FCTBurn
+	// Refund is the part of a conversion input that was not converted
+	Refund
 )
 
 // QueryLimit is the amount of transactions to return in one query
 const QueryLimit = 50
 
+// The orders the history can be sorted by
+const (
+	SortHeight = "height"
+	SortAmount = "amount"
+)
+
+// The directions of the actions of an address
+const (
+	DirectionBoth     = "both"
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// directionFilter returns the sql condition that only selects the actions of
+// the lookup address in the direction. Transfers are sent by their input and
+// received by their outputs, a transfer to oneself is only sent. Conversions
+// are both sent and received, coinbases and burns only received.
+func directionFilter(direction string) (string, error) {
+	switch direction {
+	case "", DirectionBoth:
+		return "", nil
+	case DirectionSent:
+		return fmt.Sprintf(" AND tx.action_type IN(%d,%d) AND tx.from_address = lookup.address", Transfer, Conversion), nil
+	case DirectionReceived:
+		return fmt.Sprintf(" AND (tx.action_type != %d OR tx.from_address != lookup.address)", Transfer), nil
+	}
+	return "", fmt.Errorf("invalid direction specified")
+}
+
+// historyAmount is the amount of an action used to sort and filter, which is
+// the input except for coinbases that only have an output
+const historyAmount = "(CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END)"
+
+// amountFilter returns the sql condition for the amount range of the options,
+// an empty string if there is no range. A max of 0 means unbounded.
+func amountFilter(column string, options HistoryQueryOptions) string {
+	var filter string
+	if options.MinAmount > 0 {
+		filter += fmt.Sprintf(" AND %s >= %d", column, options.MinAmount)
+	}
+	if options.MaxAmount > 0 {
+		filter += fmt.Sprintf(" AND %s <= %d", column, options.MaxAmount)
+	}
+	return filter
+}
+
 func historyActionPicker(tx, conv, coin, burn bool) []string {
 	if tx == conv && conv == coin && coin == burn {
 		return nil
@@ -62,6 +110,14 @@ type HistoryQueryOptions struct {
 	Coinbase   bool
 	FCTBurn    bool
 	Asset      string
+	// Refund selects the refunds instead of the actions
+	Refund bool
+	// Sort is either SortHeight (default) or SortAmount
+	Sort      string
+	MinAmount int64
+	MaxAmount int64
+	// Direction is one of the Direction constants, only for addresses
+	Direction string
 
 	// UseTxIndex is set if specifying a specific tx in the batch.
 	// Because 0 is a valid tx index, we want the uninitialized value
@@ -72,23 +128,40 @@ type HistoryQueryOptions struct {
 
 const historyQueryFields = "batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed," +
 	"tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs," +
-	"tx.to_asset, tx.to_amount"
+	"tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid"
 
 // historyQueryBuilder generates a count and data query for the given options
 func historyQueryBuilder(field string, options HistoryQueryOptions) (string, string, error) {
-	order := "ORDER BY batch.history_id ASC"
+	dir := "ASC"
 	if options.Desc {
-		order = "ORDER BY batch.history_id DESC"
+		dir = "DESC"
+	}
+	var order string
+	switch options.Sort {
+	case "", SortHeight:
+		order = "ORDER BY batch.history_id " + dir
+	case SortAmount:
+		order = fmt.Sprintf("ORDER BY %s %s, batch.history_id %s", historyAmount, dir, dir)
+	default:
+		return "", "", fmt.Errorf("invalid sort specified")
 	}
 
 	limit := fmt.Sprintf("LIMIT %d OFFSET %d", QueryLimit, options.Offset)
 
 	types := historyActionPicker(options.Transfer, options.Conversion, options.Coinbase, options.FCTBurn)
+	amounts := amountFilter(historyAmount, options)
+	direction, err := directionFilter(options.Direction)
+	if err != nil {
+		return "", "", err
+	}
+	if direction != "" && field != "address" {
+		return "", "", fmt.Errorf("a direction can only be used with an address")
+	}
 
 	var from, where, fromCount, whereCount string
 	switch field {
 	case "address":
-		if types != nil || options.Asset != "" {
+		if types != nil || options.Asset != "" || amounts != "" || direction != "" {
 			fromCount = "pn_history_lookup lookup, pn_history_transaction tx"
 			whereCount = "lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index"
 		} else {
@@ -104,6 +177,11 @@ func historyQueryBuilder(field string, options HistoryQueryOptions) (string, str
 		where = fmt.Sprintf("batch.entry_hash = tx.entry_hash AND batch.%s = ?", field)
 		fromCount = from
 		whereCount = where
+	case "txid":
+		from = "pn_history_txbatch batch, pn_history_transaction tx"
+		where = "batch.entry_hash = tx.entry_hash AND tx.txid = ?"
+		fromCount = from
+		whereCount = where
 	default:
 		return "", "", fmt.Errorf("developer error - unimplemented history query builder field")
 	}
@@ -122,6 +200,9 @@ func historyQueryBuilder(field string, options HistoryQueryOptions) (string, str
 		whereCount += fmt.Sprintf(" AND (tx.from_asset = '%s' OR tx.to_asset = '%s')", options.Asset, options.Asset)
 	}
 
+	where += amounts + direction
+	whereCount += amounts + direction
+
 	if types != nil {
 		where = fmt.Sprintf("(%s) AND tx.action_type IN(%s)", where, strings.Join(types, ","))
 		whereCount = fmt.Sprintf("(%s) AND tx.action_type IN(%s)", whereCount, strings.Join(types, ","))
@@ -137,11 +218,11 @@ func turnRowsIntoHistoryTransactions(rows *sql.Rows) ([]HistoryTransaction, erro
 	for rows.Next() {
 		var tx HistoryTransaction
 		var ts, id int64
-		var hash, from, outputs []byte
+		var hash, from, outputs, metadata []byte
 		err := rows.Scan(
 			&id, &hash, &tx.Height, &ts, &tx.Executed, // history
 			&tx.TxIndex, &tx.TxAction, &from, &tx.FromAsset, &tx.FromAmount, // action
-			&outputs, &tx.ToAsset, &tx.ToAmount) // data
+			&outputs, &tx.ToAsset, &tx.ToAmount, &tx.FromRate, &tx.ToRate, &metadata, &tx.TxID) // data
 		if err != nil {
 			return nil, err
 		}
@@ -153,7 +234,6 @@ func turnRowsIntoHistoryTransactions(rows *sql.Rows) ([]HistoryTransaction, erro
 		copy(from32[:], from)
 
 		tx.Hash = &hash32
-		tx.TxID = FormatTxID(tx.TxIndex, tx.Hash.String())
 		tx.Timestamp = time.Unix(ts, 0)
 		var addr factom.FAAddress
 		addr = factom.FAAddress(from32)
@@ -172,6 +252,9 @@ func turnRowsIntoHistoryTransactions(rows *sql.Rows) ([]HistoryTransaction, erro
 			}
 			tx.Outputs = output
 		}
+		if len(metadata) > 0 {
+			tx.Metadata = metadata
+		}
 
 		actions = append(actions, tx)
 	}
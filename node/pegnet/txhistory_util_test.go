@@ -19,13 +19,16 @@ func TestHistoryQueryBuilder(t *testing.T) {
 	}{ // only a single typed arg suffices since result of types is tested separately below
 		{"empty", args{"", HistoryQueryOptions{}}, "", "", true},
 		{"wrong field", args{"bad", HistoryQueryOptions{}}, "", "", true},
-		{"entry hash, default args", args{"entry_hash", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
-		{"entry hash, offset", args{"entry_hash", HistoryQueryOptions{Offset: 123}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 123", false},
-		{"entry hash, descending", args{"entry_hash", HistoryQueryOptions{Desc: true}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id DESC LIMIT 50 OFFSET 0", false},
-		{"entry hash, typed", args{"entry_hash", HistoryQueryOptions{FCTBurn: true, Coinbase: true}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE (batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?) AND tx.action_type IN(3,4)", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_txbatch batch, pn_history_transaction tx WHERE (batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?) AND tx.action_type IN(3,4) ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
-		{"height, default args", args{"height", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
-		{"address, default args", args{"address", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_lookup WHERE address = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
-		{"address, typed", args{"address", HistoryQueryOptions{Conversion: true, Transfer: true}}, "SELECT COUNT(*) FROM pn_history_lookup lookup, pn_history_transaction tx WHERE (lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index) AND tx.action_type IN(1,2)", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx WHERE (lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash) AND tx.action_type IN(1,2) ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"entry hash, default args", args{"entry_hash", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"entry hash, offset", args{"entry_hash", HistoryQueryOptions{Offset: 123}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 123", false},
+		{"entry hash, descending", args{"entry_hash", HistoryQueryOptions{Desc: true}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.entry_hash = ? ORDER BY batch.history_id DESC LIMIT 50 OFFSET 0", false},
+		{"entry hash, typed", args{"entry_hash", HistoryQueryOptions{FCTBurn: true, Coinbase: true}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE (batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?) AND tx.action_type IN(3,4)", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE (batch.entry_hash = tx.entry_hash AND batch.entry_hash = ?) AND tx.action_type IN(3,4) ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"height, default args", args{"height", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ? ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"address, default args", args{"address", HistoryQueryOptions{}}, "SELECT COUNT(*) FROM pn_history_lookup WHERE address = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"address, typed", args{"address", HistoryQueryOptions{Conversion: true, Transfer: true}}, "SELECT COUNT(*) FROM pn_history_lookup lookup, pn_history_transaction tx WHERE (lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index) AND tx.action_type IN(1,2)", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx WHERE (lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash) AND tx.action_type IN(1,2) ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
+		{"height, sorted by amount", args{"height", HistoryQueryOptions{Sort: SortAmount, Desc: true}}, "SELECT COUNT(*) FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ?", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_txbatch batch, pn_history_transaction tx WHERE batch.entry_hash = tx.entry_hash AND batch.height = ? ORDER BY (CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END) DESC, batch.history_id DESC LIMIT 50 OFFSET 0", false},
+		{"height, bad sort", args{"height", HistoryQueryOptions{Sort: "bad"}}, "", "", true},
+		{"address, amount range", args{"address", HistoryQueryOptions{MinAmount: 10, MaxAmount: 20}}, "SELECT COUNT(*) FROM pn_history_lookup lookup, pn_history_transaction tx WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND (CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END) >= 10 AND (CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END) <= 20", "SELECT batch.history_id, batch.entry_hash, batch.height, batch.timestamp, batch.executed,tx.tx_index, tx.action_type, tx.from_address, tx.from_asset, tx.from_amount, tx.outputs,tx.to_asset, tx.to_amount, tx.from_rate, tx.to_rate, tx.metadata, tx.txid FROM pn_history_lookup lookup, pn_history_txbatch batch, pn_history_transaction tx WHERE lookup.address = ? AND lookup.entry_hash = tx.entry_hash AND lookup.tx_index = tx.tx_index AND batch.entry_hash = tx.entry_hash AND (CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END) >= 10 AND (CASE WHEN tx.action_type = 3 THEN tx.to_amount ELSE tx.from_amount END) <= 20 ORDER BY batch.history_id ASC LIMIT 50 OFFSET 0", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
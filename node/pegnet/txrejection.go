@@ -0,0 +1,114 @@
+package pegnet
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+)
+
+// createTableTxRejection is a SQL string that creates the
+// "pn_history_rejection" table.
+//
+// The table records why an entry on the transaction chain was not applied.
+// This includes entries that never make it into the history system, such
+// as entries that failed to parse or validate and replays.
+// The "code" field uses the same values as the "executed" field in
+// "pn_history_txbatch".
+const createTableTxRejection = `CREATE TABLE IF NOT EXISTS "pn_history_rejection" (
+	"entry_hash"	BLOB NOT NULL,
+	"height"		INTEGER NOT NULL, -- height the entry was rejected at
+	"code"			INTEGER NOT NULL,
+	"reason"		TEXT NOT NULL,
+
+	PRIMARY KEY("entry_hash", "height")
+);
+`
+
+// TransactionRejection is the reason an entry was rejected
+type TransactionRejection struct {
+	Height uint32
+	Code   int64
+	Reason string
+}
+
+// CreateTableTxRejection is used to expose this table for unit tests
+func (p *Pegnet) CreateTableTxRejection() error {
+	_, err := p.DB.Exec(createTableTxRejection)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertTransactionRejection records the reason an entry was rejected at the
+// given height.
+func (p *Pegnet) InsertTransactionRejection(tx *sql.Tx, entryHash *factom.Bytes32, height uint32, code int64, reason string) error {
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO "pn_history_rejection"
+                ("entry_hash", "height", "code", "reason") VALUES
+                (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(entryHash[:], height, code, reason)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetTransactionHistoryRejected updates a transaction's executed status with
+// the rejection code and records the reason for the rejection
+func (p *Pegnet) SetTransactionHistoryRejected(tx *sql.Tx, txbatch *fat2.TransactionBatch, height uint32, code int64, reason error) error {
+	if err := p.SetTransactionHistoryExecuted(tx, txbatch, code); err != nil {
+		return err
+	}
+	return p.InsertTransactionRejection(tx, txbatch.Entry.Hash, height, code, reason.Error())
+}
+
+// SelectTransactionRejection returns the most recent rejection of the entry.
+// If the entry was never rejected, nil is returned.
+func (p *Pegnet) SelectTransactionRejection(entryHash *factom.Bytes32) (*TransactionRejection, error) {
+	rej := new(TransactionRejection)
+	err := p.DB.QueryRow(`SELECT "height", "code", "reason" FROM "pn_history_rejection" WHERE "entry_hash" = ? ORDER BY "height" DESC LIMIT 1;`,
+		entryHash[:]).Scan(&rej.Height, &rej.Code, &rej.Reason)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rej, nil
+}
+
+// RejectedEntry is an entry rejected at a height
+type RejectedEntry struct {
+	EntryHash *factom.Bytes32 `json:"entryhash"`
+	Code      int64           `json:"code"`
+	Reason    string          `json:"reason"`
+}
+
+// SelectTransactionRejectionsAt returns the entries rejected at the height,
+// ordered by entry hash
+func (p *Pegnet) SelectTransactionRejectionsAt(ctx context.Context, height uint32) ([]RejectedEntry, error) {
+	rows, err := p.DB.QueryContext(ctx, `SELECT "entry_hash", "code", "reason" FROM "pn_history_rejection"
+		WHERE "height" = ? ORDER BY "entry_hash";`, height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []RejectedEntry{}
+	for rows.Next() {
+		var rej RejectedEntry
+		var hash []byte
+		if err := rows.Scan(&hash, &rej.Code, &rej.Reason); err != nil {
+			return nil, err
+		}
+		rej.EntryHash = new(factom.Bytes32)
+		copy(rej.EntryHash[:], hash)
+		res = append(res, rej)
+	}
+	return res, rows.Err()
+}
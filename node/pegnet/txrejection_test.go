@@ -0,0 +1,37 @@
+package pegnet_test
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_TransactionRejection(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableTxRejection())
+
+	var hash factom.Bytes32
+	hash[0] = 1
+
+	rej, err := p.SelectTransactionRejection(&hash)
+	require.NoError(t, err)
+	assert.Nil(t, rej)
+
+	tx, err := p.DB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, p.InsertTransactionRejection(tx, &hash, 10, pegnet.InvalidTransactionErrInt, "invalid timestamp"))
+	require.NoError(t, p.InsertTransactionRejection(tx, &hash, 12, pegnet.ReplayErrorInt, pegnet.ReplayError.Error()))
+	require.NoError(t, tx.Commit())
+
+	rej, err = p.SelectTransactionRejection(&hash)
+	require.NoError(t, err)
+	require.NotNil(t, rej)
+	assert.Equal(t, uint32(12), rej.Height)
+	assert.Equal(t, pegnet.ReplayErrorInt, rej.Code)
+	assert.Equal(t, pegnet.ReplayError.Error(), rej.Reason)
+}
@@ -37,7 +37,7 @@ func (p *Pegnet) InsertTransactionRelation(tx *sql.Tx, adr factom.FAAddress, ent
 	// If an address is the sender and the receiver, then we only record the sender side, not the receiver.
 	// This is some loss of information, but for the use case of getting all related transactions,
 	// it is fine.
-	stmt, err := tx.Prepare(`INSERT INTO "pn_address_transactions"
+	stmt, err := p.prepare(tx, `INSERT INTO "pn_address_transactions"
                 ("entry_hash", "address", "tx_index", "to", "conversion") VALUES
                 (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`)
 	if err != nil {
@@ -0,0 +1,132 @@
+package pegnet
+
+import (
+	"bytes"
+	"database/sql"
+	"sort"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// createTableWatchAddresses is a SQL string that creates the table holding the
+// watch-only addresses of the wallet. The node has no key for them, like for
+// cold storage, but they are part of the balances of the wallet accounts.
+const createTableWatchAddresses = `CREATE TABLE IF NOT EXISTS "pn_watch_addresses" (
+	"address"	BLOB PRIMARY KEY,
+	"account"	TEXT NOT NULL,
+	"label"		TEXT NOT NULL,
+	"added"		INTEGER NOT NULL, -- height the address was imported at
+	"sealed_address"	TEXT NOT NULL DEFAULT '' -- with a column key, see privateTable
+);
+`
+
+// WatchAddress is a watch-only address of a wallet account
+type WatchAddress struct {
+	Address factom.FAAddress `json:"address"`
+	Account string           `json:"account"`
+	Label   string           `json:"label,omitempty"`
+	Added   uint32           `json:"added"`
+}
+
+// CreateTableWatchAddresses is used to expose this table for unit tests
+func (p *Pegnet) CreateTableWatchAddresses() error {
+	_, err := p.DB.Exec(createTableWatchAddresses)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InsertWatchAddress imports the watch-only address, or updates the account
+// and the label of an imported address
+func (p *Pegnet) InsertWatchAddress(q QueryAble, w WatchAddress) error {
+	if q == nil {
+		q = p.DB
+	}
+	adr, sealed, err := p.index("pn_watch_addresses.address", w.Address[:])
+	if err != nil {
+		return err
+	}
+	private, err := p.sealAll("pn_watch_addresses", []string{"account", "label"}, w.Account, w.Label)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`INSERT INTO "pn_watch_addresses" ("address", "sealed_address", "account", "label", "added") VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT("address") DO UPDATE SET "account" = "excluded"."account", "label" = "excluded"."label";`,
+		adr, sealed, private[0], private[1], w.Added)
+	return err
+}
+
+// DeleteWatchAddress removes the watch-only address. Returns false if the
+// address was not imported.
+func (p *Pegnet) DeleteWatchAddress(q QueryAble, adr factom.FAAddress) (bool, error) {
+	if q == nil {
+		q = p.DB
+	}
+	res, err := q.Exec(`DELETE FROM "pn_watch_addresses" WHERE "address" = ?;`, p.indexKey("pn_watch_addresses.address", adr[:]))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SelectWatchAddress returns the watch-only address, sql.ErrNoRows if it
+// is not imported
+func (p *Pegnet) SelectWatchAddress(q QueryAble, adr factom.FAAddress) (WatchAddress, error) {
+	if q == nil {
+		q = p.DB
+	}
+	w := WatchAddress{Address: adr}
+	err := q.QueryRow(`SELECT "account", "label", "added" FROM "pn_watch_addresses" WHERE "address" = ?;`,
+		p.indexKey("pn_watch_addresses.address", adr[:])).
+		Scan(p.sealed("pn_watch_addresses.account", &w.Account), p.sealed("pn_watch_addresses.label", &w.Label), &w.Added)
+	return w, err
+}
+
+// SelectWatchAddresses returns the watch-only addresses by account, in the
+// order they were imported
+func (p *Pegnet) SelectWatchAddresses(q QueryAble) ([]WatchAddress, error) {
+	if q == nil {
+		q = p.DB
+	}
+	// The addresses and accounts can be encrypted, they are sorted once
+	// decrypted
+	rows, err := q.Query(`SELECT ` + selectIndexed("address") + `, "account", "label", "added" FROM "pn_watch_addresses";`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []WatchAddress
+	for rows.Next() {
+		var w WatchAddress
+		var adr []byte
+		if err := rows.Scan(p.sealed("pn_watch_addresses.address", &adr), p.sealed("pn_watch_addresses.account", &w.Account),
+			p.sealed("pn_watch_addresses.label", &w.Label), &w.Added); err != nil {
+			return nil, err
+		}
+		copy(w.Address[:], adr)
+		addresses = append(addresses, w)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := addresses[i], addresses[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Added != b.Added {
+			return a.Added < b.Added
+		}
+		return bytes.Compare(a.Address[:], b.Address[:]) < 0
+	})
+	return addresses, rows.Err()
+}
+
+// IsWatchAddress is true if the address is imported as watch-only
+func (p *Pegnet) IsWatchAddress(q QueryAble, adr factom.FAAddress) (bool, error) {
+	_, err := p.SelectWatchAddress(q, adr)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
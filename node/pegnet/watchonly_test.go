@@ -0,0 +1,52 @@
+package pegnet_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	. "github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_WatchAddresses(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableWatchAddresses())
+
+	var a, b factom.FAAddress
+	a[0], b[0] = 1, 2
+	require.NoError(t, p.InsertWatchAddress(nil, WatchAddress{Address: a, Account: "cold", Label: "vault", Added: 10}))
+	require.NoError(t, p.InsertWatchAddress(nil, WatchAddress{Address: b, Account: "cold", Added: 5}))
+
+	// Reimporting updates the account and the label, not the height
+	require.NoError(t, p.InsertWatchAddress(nil, WatchAddress{Address: a, Account: "archive", Label: "old vault", Added: 20}))
+	w, err := p.SelectWatchAddress(nil, a)
+	require.NoError(t, err)
+	assert.Equal(t, WatchAddress{Address: a, Account: "archive", Label: "old vault", Added: 10}, w)
+
+	all, err := p.SelectWatchAddresses(nil)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "archive", all[0].Account)
+	assert.Equal(t, b, all[1].Address)
+
+	watched, err := p.IsWatchAddress(nil, b)
+	require.NoError(t, err)
+	assert.True(t, watched)
+
+	removed, err := p.DeleteWatchAddress(nil, b)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = p.DeleteWatchAddress(nil, b)
+	require.NoError(t, err)
+	assert.False(t, removed)
+
+	_, err = p.SelectWatchAddress(nil, b)
+	assert.Equal(t, sql.ErrNoRows, err)
+	watched, err = p.IsWatchAddress(nil, b)
+	require.NoError(t, err)
+	assert.False(t, watched)
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
 )
 
 type MinerDominanceResult struct {
@@ -95,3 +97,123 @@ func (p *Pegnet) SelectMinerDominance(ctx context.Context, start, stop int) (Min
 
 	return result, nil
 }
+
+// OPRStatsLimit is the maximum amount of heights that can be queried at once
+const OPRStatsLimit = 1000
+
+// OPRWinner is a winning record of a block
+type OPRWinner struct {
+	Position  int            `json:"position"`
+	EntryHash factom.Bytes32 `json:"entryhash"`
+	MinerID   string         `json:"minerid"`
+	Address   string         `json:"address"` // coinbase address
+	Payout    int64          `json:"payout"`
+	Grade     float64        `json:"grade"`
+}
+
+// OPRBlockStats are the records of one graded block. `Submitted` is the
+// amount of valid records that were submitted, `Graded` the amount that were
+// graded.
+type OPRBlockStats struct {
+	Height    uint32      `json:"height"`
+	Version   uint8       `json:"version"`
+	Submitted int         `json:"submitted"`
+	Graded    int         `json:"graded"`
+	Winners   []OPRWinner `json:"winners"`
+}
+
+// OPRMinerStats are the totals of one coinbase address
+type OPRMinerStats struct {
+	Identities []string `json:"identities"`
+	Wins       int      `json:"wins"`
+	Graded     int      `json:"graded"`
+	Payout     int64    `json:"payout"`
+}
+
+// OPRStats are the statistics of all graded blocks in a height range
+type OPRStats struct {
+	Start     uint32                   `json:"startheight"`
+	Stop      uint32                   `json:"stopheight"`
+	Submitted int                      `json:"submitted"`
+	Graded    int                      `json:"graded"`
+	Payout    int64                    `json:"payout"`
+	Blocks    []OPRBlockStats          `json:"blocks"`
+	Miners    map[string]OPRMinerStats `json:"miners"`
+}
+
+// SelectOPRStats returns the winning records, record counts, and miner
+// payouts of all graded blocks in the height range [start, stop]. The range
+// is limited to OPRStatsLimit heights.
+func (p *Pegnet) SelectOPRStats(ctx context.Context, start, stop uint32) (OPRStats, error) {
+	result := OPRStats{Start: start, Stop: stop, Blocks: []OPRBlockStats{}, Miners: make(map[string]OPRMinerStats)}
+	if stop < start {
+		return result, fmt.Errorf("invalid stop, must be >= start")
+	}
+	if stop-start >= OPRStatsLimit {
+		return result, fmt.Errorf("range is limited to %d heights", OPRStatsLimit)
+	}
+
+	rows, err := p.DB.QueryContext(ctx, `SELECT g.height, g.version, g.count,
+		(SELECT COUNT(*) FROM pn_winners w WHERE w.height = g.height)
+		FROM pn_grade g WHERE g.height >= ? AND g.height <= ? ORDER BY g.height ASC;`, start, stop)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	index := make(map[uint32]int)
+	for rows.Next() {
+		var block OPRBlockStats
+		if err := rows.Scan(&block.Height, &block.Version, &block.Submitted, &block.Graded); err != nil {
+			return result, err
+		}
+		block.Winners = []OPRWinner{}
+		index[block.Height] = len(result.Blocks)
+		result.Blocks = append(result.Blocks, block)
+		result.Submitted += block.Submitted
+		result.Graded += block.Graded
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	winners, err := p.DB.QueryContext(ctx, `SELECT height, position, entryhash, minerid, address, payout, grade
+		FROM pn_winners WHERE height >= ? AND height <= ? AND payout > 0 ORDER BY height ASC, position ASC;`, start, stop)
+	if err != nil {
+		return result, err
+	}
+	defer winners.Close()
+	for winners.Next() {
+		var height uint32
+		var hash []byte
+		var w OPRWinner
+		if err := winners.Scan(&height, &w.Position, &hash, &w.MinerID, &w.Address, &w.Payout, &w.Grade); err != nil {
+			return result, err
+		}
+		copy(w.EntryHash[:], hash)
+		if i, ok := index[height]; ok {
+			result.Blocks[i].Winners = append(result.Blocks[i].Winners, w)
+		}
+	}
+	if err := winners.Err(); err != nil {
+		return result, err
+	}
+
+	miners, err := p.DB.QueryContext(ctx, `SELECT address, COUNT(NULLIF(0, payout)), COUNT(*), SUM(payout), group_concat(DISTINCT minerid)
+		FROM pn_winners WHERE height >= ? AND height <= ? GROUP BY address;`, start, stop)
+	if err != nil {
+		return result, err
+	}
+	defer miners.Close()
+	for miners.Next() {
+		var address, ids string
+		var m OPRMinerStats
+		if err := miners.Scan(&address, &m.Wins, &m.Graded, &m.Payout, &ids); err != nil {
+			return result, err
+		}
+		m.Identities = strings.Split(ids, ",")
+		result.Payout += m.Payout
+		result.Miners[address] = m
+	}
+	return result, miners.Err()
+}
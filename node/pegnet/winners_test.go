@@ -0,0 +1,81 @@
+package pegnet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPegnet_SelectOPRStats(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableGrade())
+
+	for height := 100; height < 103; height++ {
+		_, err := p.DB.Exec(`INSERT INTO pn_grade (height, version, cutoff, count) VALUES (?, 2, 2, 5)`, height)
+		require.NoError(t, err)
+		for pos, w := range []struct {
+			id, address string
+			payout      int64
+		}{{"alice", "FA-alice", 800}, {"bob", "FA-bob", 0}} {
+			_, err := p.DB.Exec(`INSERT INTO pn_winners (height, entryhash, payout, grade, position, minerid, address) VALUES (?, ?, ?, 1.5, ?, ?, ?)`,
+				height, []byte{byte(height), byte(pos)}, w.payout, pos, w.id, w.address)
+			require.NoError(t, err)
+		}
+	}
+
+	stats, err := p.SelectOPRStats(context.Background(), 101, 110)
+	require.NoError(t, err)
+	require.Len(t, stats.Blocks, 2)
+	assert.Equal(t, uint32(101), stats.Blocks[0].Height)
+	assert.Equal(t, 5, stats.Blocks[0].Submitted)
+	assert.Equal(t, 2, stats.Blocks[0].Graded)
+	require.Len(t, stats.Blocks[0].Winners, 1)
+	assert.Equal(t, "alice", stats.Blocks[0].Winners[0].MinerID)
+	assert.Equal(t, byte(101), stats.Blocks[0].Winners[0].EntryHash[0])
+
+	assert.Equal(t, 10, stats.Submitted)
+	assert.Equal(t, 4, stats.Graded)
+	assert.Equal(t, int64(1600), stats.Payout)
+	assert.Equal(t, 2, stats.Miners["FA-alice"].Wins)
+	assert.Equal(t, int64(1600), stats.Miners["FA-alice"].Payout)
+	assert.Equal(t, 0, stats.Miners["FA-bob"].Wins)
+	assert.Equal(t, 2, stats.Miners["FA-bob"].Graded)
+	assert.Equal(t, []string{"bob"}, stats.Miners["FA-bob"].Identities)
+
+	_, err = p.SelectOPRStats(context.Background(), 0, 5000)
+	assert.Error(t, err)
+}
+
+func TestPegnet_SelectWinningQuotes(t *testing.T) {
+	p, err := setupPegnet()
+	require.NoError(t, err)
+	defer tearDownPegnet(p)
+	require.NoError(t, p.CreateTableGrade())
+
+	for pos := 0; pos < 3; pos++ {
+		_, err := p.DB.Exec(`INSERT INTO pn_winners (height, entryhash, payout, position) VALUES (100, ?, 1, ?)`, []byte{byte(pos + 1)}, pos)
+		require.NoError(t, err)
+		for token, value := range map[string]int{"PEG": 10 + pos, "pUSD": 100 - pos} {
+			_, err := p.DB.Exec(`INSERT INTO pn_winner_rates (height, position, token, value) VALUES (100, ?, ?, ?)`, pos, token, value)
+			require.NoError(t, err)
+		}
+	}
+
+	quotes, err := p.SelectWinningQuotes(context.Background(), 100)
+	require.NoError(t, err)
+	require.Len(t, quotes.EntryHashes, 3)
+	for i, eh := range quotes.EntryHashes {
+		assert.Equal(t, byte(i+1), eh[0])
+	}
+	assert.Equal(t, []uint64{10, 11, 12}, quotes.Quotes[fat2.PTickerPEG])
+	assert.Equal(t, []uint64{100, 99, 98}, quotes.Quotes[fat2.PTickerUSD])
+
+	quotes, err = p.SelectWinningQuotes(context.Background(), 101)
+	require.NoError(t, err)
+	assert.Empty(t, quotes.EntryHashes)
+}
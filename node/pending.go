@@ -0,0 +1,65 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	log "github.com/sirupsen/logrus"
+)
+
+// pendingCache holds the pending entries of the transaction chain that were
+// fetched at a height. Only the list of pending entries is requested again,
+// the entries themselves are fetched once.
+type pendingCache struct {
+	sync.Mutex
+	height int32
+	// batches is nil for entries that are not valid batches
+	batches map[factom.Bytes32]*fat2.TransactionBatch
+}
+
+// PendingTransactionBatches returns the transaction batches that were
+// revealed to factomd but are not part of a block yet. Entries that fail to
+// parse or validate are left out, they will be rejected once synced.
+//
+// The batches are cached until the next height is synced, so every call only
+// fetches the entries that were revealed since the last one.
+func (d *Pegnetd) PendingTransactionBatches(ctx context.Context) ([]*fat2.TransactionBatch, error) {
+	pending := new(factom.PendingEntries)
+	if err := pending.Get(ctx, d.FactomClient); err != nil {
+		return nil, err
+	}
+
+	// The entries are included in the next block
+	height := int32(d.GetCurrentSync() + 1)
+	cache := &d.pending
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.height != height || cache.batches == nil {
+		cache.height, cache.batches = height, make(map[factom.Bytes32]*fat2.TransactionBatch)
+	}
+
+	var batches []*fat2.TransactionBatch
+	current := make(map[factom.Bytes32]*fat2.TransactionBatch)
+	for _, entry := range pending.Entries(&TransactionChain) {
+		batch, ok := cache.batches[*entry.Hash]
+		if !ok {
+			if err := entry.Get(ctx, d.FactomClient); err != nil {
+				return nil, err
+			}
+			var err error
+			if batch, err = fat2.NewTransactionBatch(entry, height); err != nil {
+				log.WithError(err).WithField("entryhash", entry.Hash).Trace("invalid pending transaction")
+				batch = nil
+			}
+		}
+		current[*entry.Hash] = batch
+		if batch != nil {
+			batches = append(batches, batch)
+		}
+	}
+	// Entries that are no longer pending are dropped
+	cache.batches = current
+	return batches, nil
+}
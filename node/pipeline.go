@@ -0,0 +1,171 @@
+package node
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/metrics"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/tracing"
+	log "github.com/sirupsen/logrus"
+)
+
+// syncedBlock is everything needed from factomd to apply a height, along
+// with the graded OPR block of the height
+type syncedBlock struct {
+	Height             uint32
+	DBlock             *factom.DBlock
+	OPREBlock          *factom.EBlock
+	TransactionsEBlock *factom.EBlock
+	FBlock             *factom.FBlock
+	Graded             grader.GradedBlock
+	Submissions        []pegnet.OPRSubmission
+}
+
+// pipelineResult is a block that passed a stage, or the error that stopped
+// the stage
+type pipelineResult struct {
+	block *syncedBlock
+	err   error
+}
+
+// fetchBlock gathers all entries and factoid transactions of the height
+// from factomd
+func (d *Pegnetd) fetchBlock(ctx context.Context, height uint32) (block *syncedBlock, err error) {
+	if isDone(ctx) {
+		return nil, context.Canceled
+	}
+	ctx, span := tracing.Start(ctx, "sync.fetch", tracing.KindInternal)
+	span.SetAttribute("height", height)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
+	block = &syncedBlock{Height: height, DBlock: new(factom.DBlock)}
+	block.DBlock.Height = height
+	if err := block.DBlock.Get(ctx, d.FactomClient); err != nil {
+		return nil, err
+	}
+
+	block.OPREBlock = block.DBlock.EBlock(OPRChain)
+	if block.OPREBlock != nil {
+		if err := multiFetch(ctx, block.OPREBlock, d.FactomClient); err != nil {
+			return nil, err
+		}
+	}
+	block.TransactionsEBlock = block.DBlock.EBlock(TransactionChain)
+	if block.TransactionsEBlock != nil {
+		if err := multiFetch(ctx, block.TransactionsEBlock, d.FactomClient); err != nil {
+			return nil, err
+		}
+	}
+
+	fblock, err := d.fetchFactoidBlock(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	block.FBlock = fblock
+	return block, nil
+}
+
+// syncPipeline fetches and grades the heights [start, stop] ahead of the
+// caller applying them. One goroutine fetches from factomd, one grades, and
+// up to `buffer` blocks wait between the stages. The previous winners are
+// tracked by the grading stage, so the winners of all heights below start
+// must be committed. The channel is closed after stop, after an error, or
+// if the context is cancelled.
+func (d *Pegnetd) syncPipeline(ctx context.Context, start, stop uint32, buffer int) <-chan pipelineResult {
+	fetched := make(chan pipelineResult, buffer)
+	graded := make(chan pipelineResult, buffer)
+
+	send := func(c chan<- pipelineResult, res pipelineResult) bool {
+		select {
+		case c <- res:
+			return res.err == nil
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(fetched)
+		for height := start; height <= stop; height++ {
+			block, err := d.fetchBlock(ctx, height)
+			if !send(fetched, pipelineResult{block: block, err: err}) {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(graded)
+		prev, err := d.Pegnet.SelectPreviousWinners(ctx, start)
+		if err != nil && err != sql.ErrNoRows {
+			send(graded, pipelineResult{err: err})
+			return
+		}
+		for res := range fetched {
+			if res.err == nil {
+				_, span := tracing.Start(ctx, "sync.grade", tracing.KindInternal)
+				span.SetAttribute("height", res.block.Height)
+				start := time.Now()
+				res.block.Graded, res.block.Submissions, prev, res.err = d.gradeWithWinners(res.block.OPREBlock, prev)
+				span.SetError(res.err)
+				span.Finish()
+				d.checkSlowBlock("grade", res.block, time.Since(start))
+			}
+			if !send(graded, res) {
+				return
+			}
+		}
+	}()
+
+	return graded
+}
+
+// gradeWithWinners grades the OPR block and returns the previous winners of
+// the next block
+func (d *Pegnetd) gradeWithWinners(block *factom.EBlock, prev []string) (grader.GradedBlock, []pegnet.OPRSubmission, []string, error) {
+	graded, submissions, err := d.gradeOPRs(block, prev)
+	if err != nil || graded == nil {
+		return graded, submissions, prev, err
+	}
+	// Round trip the winners like InsertGradeBlock and SelectPreviousWinners
+	// do, so the next block is graded exactly like it is without the pipeline
+	data, err := json.Marshal(graded.WinnersShortHashes())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var next []string
+	if err := json.Unmarshal(data, &next); err != nil {
+		return nil, nil, nil, err
+	}
+	return graded, submissions, next, nil
+}
+
+// checkSlowBlock logs the stage of a block if it took longer than the
+// configured threshold, along with the size of the block
+func (d *Pegnetd) checkSlowBlock(stage string, block *syncedBlock, elapsed time.Duration) {
+	threshold := d.Config.GetDuration(config.SlowBlock)
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+	metrics.GlobalSlow.Inc("blocks")
+	fields := log.Fields{"height": block.Height, "stage": stage, "duration": elapsed}
+	if block.OPREBlock != nil {
+		fields["oprs"] = len(block.OPREBlock.Entries)
+	}
+	if block.TransactionsEBlock != nil {
+		fields["transactions"] = len(block.TransactionsEBlock.Entries)
+	}
+	if block.FBlock != nil {
+		fields["factoidtxs"] = len(block.FBlock.Transactions)
+	}
+	log.WithFields(fields).Warn("slow block")
+}
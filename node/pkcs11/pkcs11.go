@@ -0,0 +1,143 @@
+// Package pkcs11 signs with Ed25519 keys of a PKCS#11 token, like a YubiHSM 2
+// with yubihsm_pkcs11.so or SoftHSM, so the private keys never leave the
+// hardware. The module is loaded at runtime and must export the standard
+// C_* functions, which requires cgo.
+//
+// A key is found by its CKA_LABEL or CKA_ID among the CKK_EC_EDWARDS keys of
+// the token, the public key is read from the CKA_EC_POINT of the public key
+// object with the same label or id. The payloads are signed with CKM_EDDSA.
+package pkcs11
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// KeyRef is the key of an EC or FA address on the token
+type KeyRef struct {
+	// Address is the EC or FA address of the key
+	Address string
+	Label   string
+	ID      []byte
+}
+
+// IsEC is true if the key is an EC key
+func (r KeyRef) IsEC() bool {
+	return strings.HasPrefix(r.Address, "EC")
+}
+
+// ParseKeyRef parses a key of the form "<address> label=<label>" or
+// "<address> id=<hex>"
+func ParseKeyRef(str string) (KeyRef, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return KeyRef{}, fmt.Errorf("key %q: expected '<address> label=<label>' or '<address> id=<hex>'", str)
+	}
+	r := KeyRef{Address: fields[0]}
+	if _, err := factom.NewFAAddress(r.Address); err != nil {
+		var ec factom.ECAddress
+		if ec.Set(r.Address) != nil {
+			return KeyRef{}, fmt.Errorf("key %q: %q is neither an EC nor an FA address", str, r.Address)
+		}
+	}
+	switch {
+	case strings.HasPrefix(fields[1], "label="):
+		r.Label = strings.TrimPrefix(fields[1], "label=")
+	case strings.HasPrefix(fields[1], "id="):
+		id, err := hex.DecodeString(strings.TrimPrefix(fields[1], "id="))
+		if err != nil {
+			return KeyRef{}, fmt.Errorf("key %q: invalid id: %v", str, err)
+		}
+		r.ID = id
+	}
+	if r.Label == "" && len(r.ID) == 0 {
+		return KeyRef{}, fmt.Errorf("key %q: expected label=<label> or id=<hex>", str)
+	}
+	return r, nil
+}
+
+// Check checks that the public key is the key of the address
+func (r KeyRef) Check(pub ed25519.PublicKey) error {
+	var adr string
+	if r.IsEC() {
+		var ec factom.ECAddress
+		copy(ec[:], pub)
+		adr = ec.String()
+	} else {
+		adr = factom.FAAddress(sha256d(append([]byte{factom.RCDType01}, pub...))).String()
+	}
+	if adr != r.Address {
+		return fmt.Errorf("the key %s of the token is the key of %s, not %s", r, adr, r.Address)
+	}
+	return nil
+}
+
+func (r KeyRef) String() string {
+	if r.Label != "" {
+		return fmt.Sprintf("label=%s", r.Label)
+	}
+	return fmt.Sprintf("id=%x", r.ID)
+}
+
+// Key is an Ed25519 key of the token
+type Key struct {
+	token  *Token
+	handle uint
+	pub    ed25519.PublicKey
+}
+
+// PublicKey is the public key of the key
+func (k *Key) PublicKey() ed25519.PublicKey {
+	return k.pub
+}
+
+// decodeECPoint returns the public key of a CKA_EC_POINT, which is either the
+// DER encoded OCTET STRING of the key or the raw key
+func decodeECPoint(point []byte) (ed25519.PublicKey, error) {
+	switch {
+	case len(point) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(point), nil
+	case len(point) == ed25519.PublicKeySize+2 && point[0] == 0x04 && point[1] == ed25519.PublicKeySize:
+		return ed25519.PublicKey(point[2:]), nil
+	}
+	return nil, fmt.Errorf("unsupported CKA_EC_POINT of %d bytes, expected an ed25519 key", len(point))
+}
+
+// rvError is a PKCS#11 return value that is not CKR_OK
+type rvError struct {
+	fn string
+	rv uint
+}
+
+var rvNames = map[uint]string{
+	0x05:  "CKR_GENERAL_ERROR",
+	0x06:  "CKR_FUNCTION_FAILED",
+	0x07:  "CKR_ARGUMENTS_BAD",
+	0x12:  "CKR_ATTRIBUTE_TYPE_INVALID",
+	0x30:  "CKR_DEVICE_ERROR",
+	0x32:  "CKR_DEVICE_REMOVED",
+	0x63:  "CKR_KEY_TYPE_INCONSISTENT",
+	0x70:  "CKR_MECHANISM_INVALID",
+	0xa0:  "CKR_PIN_INCORRECT",
+	0xa4:  "CKR_PIN_LOCKED",
+	0xb3:  "CKR_SESSION_HANDLE_INVALID",
+	0xe0:  "CKR_TOKEN_NOT_PRESENT",
+	0x101: "CKR_USER_NOT_LOGGED_IN",
+}
+
+func (e rvError) Error() string {
+	if name, ok := rvNames[e.rv]; ok {
+		return fmt.Sprintf("pkcs11: %s: %s", e.fn, name)
+	}
+	return fmt.Sprintf("pkcs11: %s: 0x%x", e.fn, e.rv)
+}
+
+func sha256d(data []byte) [32]byte {
+	first := sha256.Sum256(data)
+	return sha256.Sum256(first[:])
+}
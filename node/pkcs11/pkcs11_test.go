@@ -0,0 +1,71 @@
+package pkcs11
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyRef(t *testing.T) {
+	r, err := ParseKeyRef("EC2pG2H2pfZYuvzR5GnLZxoSXRMLbw2G5qmJES1TvTCqroD8ThWi label=pegnet-ec")
+	require.NoError(t, err)
+	assert.True(t, r.IsEC())
+	assert.Equal(t, "pegnet-ec", r.Label)
+	assert.Equal(t, "label=pegnet-ec", r.String())
+
+	r, err = ParseKeyRef("FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q id=0a0B")
+	require.NoError(t, err)
+	assert.False(t, r.IsEC())
+	assert.Equal(t, []byte{0x0a, 0x0b}, r.ID)
+	assert.Equal(t, "id=0a0b", r.String())
+
+	for _, str := range []string{
+		"EC2pG2H2pfZYuvzR5GnLZxoSXRMLbw2G5qmJES1TvTCqroD8ThWi",
+		"EC2pG2H2pfZYuvzR5GnLZxoSXRMLbw2G5qmJES1TvTCqroD8ThWi label=",
+		"EC2pG2H2pfZYuvzR5GnLZxoSXRMLbw2G5qmJES1TvTCqroD8ThWi id=xyz",
+		"EC2pG2H2pfZYuvzR5GnLZxoSXRMLbw2G5qmJES1TvTCqroD8ThWi slot=1",
+		"Es3eGA7ZYkS2TezUH6q1T7vNUWpihUYDLKaCHDYNanX2hDZCFQqp label=secret",
+		"FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q label=a b",
+	} {
+		_, err := ParseKeyRef(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestKeyRefCheck(t *testing.T) {
+	es, err := factom.NewEsAddress("Es3eGA7ZYkS2TezUH6q1T7vNUWpihUYDLKaCHDYNanX2hDZCFQqp")
+	require.NoError(t, err)
+	fs, err := factom.NewFsAddress("Fs3E9gV6DXsYzf7Fqx1fVBQPQXV695eP3k5XbmHEZVRLkMdD9qCK")
+	require.NoError(t, err)
+
+	ec := KeyRef{Address: es.ECAddress().String(), Label: "ec"}
+	assert.NoError(t, ec.Check(es.PublicKey()))
+	assert.Error(t, ec.Check(fs.PublicKey()))
+	fa := KeyRef{Address: fs.FAAddress().String(), Label: "fa"}
+	assert.NoError(t, fa.Check(fs.PublicKey()))
+	assert.Error(t, fa.Check(es.PublicKey()))
+}
+
+func TestDecodeECPoint(t *testing.T) {
+	raw := make([]byte, 32)
+	raw[0] = 1
+	pub, err := decodeECPoint(raw)
+	require.NoError(t, err)
+	assert.Equal(t, raw, []byte(pub))
+
+	pub, err = decodeECPoint(append([]byte{0x04, 0x20}, raw...))
+	require.NoError(t, err)
+	assert.Equal(t, raw, []byte(pub))
+
+	_, err = decodeECPoint(append([]byte{0x04, 0x41}, raw...))
+	assert.Error(t, err)
+	_, err = decodeECPoint(raw[:31])
+	assert.Error(t, err)
+}
+
+func TestRVError(t *testing.T) {
+	assert.EqualError(t, rvError{"C_Login", 0xa0}, "pkcs11: C_Login: CKR_PIN_INCORRECT")
+	assert.EqualError(t, rvError{"C_Sign", 0x1234}, "pkcs11: C_Sign: 0x1234")
+}
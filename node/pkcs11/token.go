@@ -0,0 +1,345 @@
+//go:build cgo
+// +build cgo
+
+package pkcs11
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef unsigned long CK_ULONG;
+typedef CK_ULONG CK_RV;
+
+typedef struct {
+	CK_ULONG type;
+	void *value;
+	CK_ULONG len;
+} p11_attribute;
+
+typedef struct {
+	CK_ULONG mechanism;
+	void *parameter;
+	CK_ULONG len;
+} p11_mechanism;
+
+typedef struct {
+	void *handle;
+	CK_RV (*Initialize)(void *);
+	CK_RV (*Finalize)(void *);
+	CK_RV (*GetSlotList)(unsigned char, CK_ULONG *, CK_ULONG *);
+	CK_RV (*GetTokenInfo)(CK_ULONG, void *);
+	CK_RV (*OpenSession)(CK_ULONG, CK_ULONG, void *, void *, CK_ULONG *);
+	CK_RV (*CloseSession)(CK_ULONG);
+	CK_RV (*Login)(CK_ULONG, CK_ULONG, unsigned char *, CK_ULONG);
+	CK_RV (*FindObjectsInit)(CK_ULONG, p11_attribute *, CK_ULONG);
+	CK_RV (*FindObjects)(CK_ULONG, CK_ULONG *, CK_ULONG, CK_ULONG *);
+	CK_RV (*FindObjectsFinal)(CK_ULONG);
+	CK_RV (*GetAttributeValue)(CK_ULONG, CK_ULONG, p11_attribute *, CK_ULONG);
+	CK_RV (*SignInit)(CK_ULONG, p11_mechanism *, CK_ULONG);
+	CK_RV (*Sign)(CK_ULONG, unsigned char *, CK_ULONG, unsigned char *, CK_ULONG *);
+} p11_module;
+
+// p11_load opens the module and resolves the functions, it returns NULL and
+// the name of the missing function on failure
+static p11_module *p11_load(const char *path, const char **missing) {
+	void *h = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (h == NULL) {
+		*missing = dlerror();
+		return NULL;
+	}
+	p11_module *m = calloc(1, sizeof(p11_module));
+	m->handle = h;
+#define P11_SYM(name) \
+	if ((*(void **)(&m->name) = dlsym(h, "C_" #name)) == NULL) { \
+		*missing = "C_" #name; \
+		dlclose(h); \
+		free(m); \
+		return NULL; \
+	}
+	P11_SYM(Initialize)
+	P11_SYM(Finalize)
+	P11_SYM(GetSlotList)
+	P11_SYM(GetTokenInfo)
+	P11_SYM(OpenSession)
+	P11_SYM(CloseSession)
+	P11_SYM(Login)
+	P11_SYM(FindObjectsInit)
+	P11_SYM(FindObjects)
+	P11_SYM(FindObjectsFinal)
+	P11_SYM(GetAttributeValue)
+	P11_SYM(SignInit)
+	P11_SYM(Sign)
+#undef P11_SYM
+	return m;
+}
+
+static void p11_unload(p11_module *m) {
+	dlclose(m->handle);
+	free(m);
+}
+
+static CK_RV p11_initialize(p11_module *m) { return m->Initialize(NULL); }
+static CK_RV p11_finalize(p11_module *m) { return m->Finalize(NULL); }
+
+static CK_RV p11_slots(p11_module *m, CK_ULONG *slots, CK_ULONG *count) {
+	return m->GetSlotList(1, slots, count);
+}
+
+// p11_token_label copies the 32 byte, blank padded label of the token
+static CK_RV p11_token_label(p11_module *m, CK_ULONG slot, unsigned char *label) {
+	// CK_TOKEN_INFO is 208 bytes on 64 bit platforms, the label comes first
+	unsigned char info[1024];
+	CK_RV rv = m->GetTokenInfo(slot, info);
+	if (rv == 0) {
+		memcpy(label, info, 32);
+	}
+	return rv;
+}
+
+static CK_RV p11_open(p11_module *m, CK_ULONG slot, CK_ULONG *session) {
+	// CKF_SERIAL_SESSION
+	return m->OpenSession(slot, 1UL << 2, NULL, NULL, session);
+}
+
+static CK_RV p11_close(p11_module *m, CK_ULONG session) { return m->CloseSession(session); }
+
+static CK_RV p11_login(p11_module *m, CK_ULONG session, unsigned char *pin, CK_ULONG len) {
+	// CKU_USER
+	return m->Login(session, 1, pin, len);
+}
+
+// p11_find finds up to two ed25519 keys of the class with the attribute
+static CK_RV p11_find(p11_module *m, CK_ULONG session, CK_ULONG class, CK_ULONG attr, void *value, CK_ULONG len,
+	CK_ULONG *objects, CK_ULONG *count) {
+	CK_ULONG keyType = 0x40; // CKK_EC_EDWARDS
+	p11_attribute tmpl[3] = {
+		{0x0, &class, sizeof(class)},      // CKA_CLASS
+		{0x100, &keyType, sizeof(keyType)}, // CKA_KEY_TYPE
+		{attr, value, len},
+	};
+	CK_RV rv = m->FindObjectsInit(session, tmpl, 3);
+	if (rv != 0) {
+		return rv;
+	}
+	rv = m->FindObjects(session, objects, 2, count);
+	m->FindObjectsFinal(session);
+	return rv;
+}
+
+// p11_attribute_value returns the value of the attribute in a buffer that is
+// freed by the caller
+static CK_RV p11_attribute_value(p11_module *m, CK_ULONG session, CK_ULONG object, CK_ULONG type,
+	unsigned char **value, CK_ULONG *len) {
+	p11_attribute attr = {type, NULL, 0};
+	CK_RV rv = m->GetAttributeValue(session, object, &attr, 1);
+	if (rv != 0) {
+		return rv;
+	}
+	attr.value = malloc(attr.len);
+	rv = m->GetAttributeValue(session, object, &attr, 1);
+	if (rv != 0) {
+		free(attr.value);
+		return rv;
+	}
+	*value = attr.value;
+	*len = attr.len;
+	return 0;
+}
+
+static CK_RV p11_sign(p11_module *m, CK_ULONG session, CK_ULONG key, unsigned char *msg, CK_ULONG len,
+	unsigned char *sig, CK_ULONG *sigLen) {
+	p11_mechanism mech = {0x1057, NULL, 0}; // CKM_EDDSA
+	CK_RV rv = m->SignInit(session, &mech, key);
+	if (rv != 0) {
+		return rv;
+	}
+	return m->Sign(session, msg, len, sig, sigLen);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+const (
+	ckoPublicKey  = 2
+	ckoPrivateKey = 3
+	ckaLabel      = 0x3
+	ckaID         = 0x102
+	ckaECPoint    = 0x181
+
+	ckrUserAlreadyLoggedIn        = 0x100
+	ckrCryptokiAlreadyInitialized = 0x191
+)
+
+// Token is a logged in session on a token of a module. The operations of the
+// session are serialized, a session can only sign one payload at a time.
+type Token struct {
+	mu      sync.Mutex
+	mod     *C.p11_module
+	session C.CK_ULONG
+}
+
+// Open loads the module, and logs in to the token with the label, or the
+// first token if the label is empty, with the pin
+func Open(module, label, pin string) (*Token, error) {
+	cpath := C.CString(module)
+	defer C.free(unsafe.Pointer(cpath))
+	var missing *C.char
+	mod := C.p11_load(cpath, &missing)
+	if mod == nil {
+		return nil, fmt.Errorf("pkcs11: loading %s: %s", module, C.GoString(missing))
+	}
+	t := &Token{mod: mod}
+	if rv := C.p11_initialize(mod); rv != 0 && rv != ckrCryptokiAlreadyInitialized {
+		C.p11_unload(mod)
+		return nil, rvError{"C_Initialize", uint(rv)}
+	}
+
+	slot, err := t.slot(label)
+	if err != nil {
+		t.unload()
+		return nil, err
+	}
+	if rv := C.p11_open(mod, slot, &t.session); rv != 0 {
+		t.unload()
+		return nil, rvError{"C_OpenSession", uint(rv)}
+	}
+	cpin := C.CString(pin)
+	defer C.free(unsafe.Pointer(cpin))
+	if rv := C.p11_login(mod, t.session, (*C.uchar)(unsafe.Pointer(cpin)), C.CK_ULONG(len(pin))); rv != 0 && rv != ckrUserAlreadyLoggedIn {
+		t.Close()
+		return nil, rvError{"C_Login", uint(rv)}
+	}
+	return t, nil
+}
+
+// slot returns the slot of the token with the label
+func (t *Token) slot(label string) (C.CK_ULONG, error) {
+	var count C.CK_ULONG
+	if rv := C.p11_slots(t.mod, nil, &count); rv != 0 {
+		return 0, rvError{"C_GetSlotList", uint(rv)}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("pkcs11: no token is present")
+	}
+	slots := make([]C.CK_ULONG, count)
+	if rv := C.p11_slots(t.mod, &slots[0], &count); rv != 0 {
+		return 0, rvError{"C_GetSlotList", uint(rv)}
+	}
+	if label == "" {
+		return slots[0], nil
+	}
+	for _, slot := range slots[:count] {
+		var buf [32]byte
+		if rv := C.p11_token_label(t.mod, slot, (*C.uchar)(unsafe.Pointer(&buf[0]))); rv != 0 {
+			continue
+		}
+		if string(bytes.TrimRight(buf[:], " \x00")) == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("pkcs11: no token with the label %q", label)
+}
+
+// Key returns the ed25519 key of the reference. There must be exactly one
+// private key with the label or id.
+func (t *Token) Key(r KeyRef) (*Key, error) {
+	attr, value := C.CK_ULONG(ckaLabel), []byte(r.Label)
+	if len(r.ID) > 0 {
+		attr, value = ckaID, r.ID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	priv, err := t.find(ckoPrivateKey, attr, value)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: private key %s: %v", r, err)
+	}
+	pubObj, err := t.find(ckoPublicKey, attr, value)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: public key %s: %v", r, err)
+	}
+	var point *C.uchar
+	var n C.CK_ULONG
+	if rv := C.p11_attribute_value(t.mod, t.session, pubObj, ckaECPoint, &point, &n); rv != 0 {
+		return nil, rvError{"C_GetAttributeValue", uint(rv)}
+	}
+	data := C.GoBytes(unsafe.Pointer(point), C.int(n))
+	C.free(unsafe.Pointer(point))
+	pub, err := decodeECPoint(data)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: public key %s: %v", r, err)
+	}
+	return &Key{token: t, handle: uint(priv), pub: pub}, nil
+}
+
+// find returns the only ed25519 key of the class with the attribute, the lock
+// must be held
+func (t *Token) find(class, attr C.CK_ULONG, value []byte) (C.CK_ULONG, error) {
+	if len(value) == 0 {
+		return 0, fmt.Errorf("the label or id is empty")
+	}
+	cvalue := C.CBytes(value)
+	defer C.free(cvalue)
+	var objects [2]C.CK_ULONG
+	var count C.CK_ULONG
+	if rv := C.p11_find(t.mod, t.session, class, attr, cvalue, C.CK_ULONG(len(value)), &objects[0], &count); rv != 0 {
+		return 0, rvError{"C_FindObjects", uint(rv)}
+	}
+	switch count {
+	case 0:
+		return 0, fmt.Errorf("not found")
+	case 1:
+		return objects[0], nil
+	}
+	return 0, fmt.Errorf("more than one key matches")
+}
+
+// Sign returns the ed25519 signature of the payload
+func (k *Key) Sign(payload []byte) ([]byte, error) {
+	t := k.token
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mod == nil {
+		return nil, fmt.Errorf("pkcs11: the token is closed")
+	}
+
+	msg := C.CBytes(payload)
+	defer C.free(msg)
+	sig := (*C.uchar)(C.malloc(ed25519.SignatureSize))
+	defer C.free(unsafe.Pointer(sig))
+	n := C.CK_ULONG(ed25519.SignatureSize)
+	if rv := C.p11_sign(t.mod, t.session, C.CK_ULONG(k.handle), (*C.uchar)(msg), C.CK_ULONG(len(payload)), sig, &n); rv != 0 {
+		return nil, rvError{"C_Sign", uint(rv)}
+	}
+	if n != ed25519.SignatureSize {
+		return nil, fmt.Errorf("pkcs11: C_Sign returned %d bytes, not an ed25519 signature", n)
+	}
+	return C.GoBytes(unsafe.Pointer(sig), C.int(n)), nil
+}
+
+// Close logs out of the token and unloads the module
+func (t *Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mod == nil {
+		return nil
+	}
+	C.p11_close(t.mod, t.session)
+	t.unload()
+	return nil
+}
+
+func (t *Token) unload() {
+	C.p11_finalize(t.mod)
+	C.p11_unload(t.mod)
+	t.mod = nil
+}
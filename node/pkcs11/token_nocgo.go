@@ -0,0 +1,29 @@
+//go:build !cgo
+// +build !cgo
+
+package pkcs11
+
+import "fmt"
+
+// Token is a logged in session on a token of a module, it requires cgo
+type Token struct{}
+
+// Open fails, loading a module requires cgo
+func Open(module, label, pin string) (*Token, error) {
+	return nil, fmt.Errorf("pkcs11: pegnetd was built without cgo, it can't load %s", module)
+}
+
+// Key returns the ed25519 key of the reference
+func (t *Token) Key(r KeyRef) (*Key, error) {
+	return nil, fmt.Errorf("pkcs11: pegnetd was built without cgo")
+}
+
+// Sign returns the ed25519 signature of the payload
+func (k *Key) Sign(payload []byte) ([]byte, error) {
+	return nil, fmt.Errorf("pkcs11: pegnetd was built without cgo")
+}
+
+// Close logs out of the token and unloads the module
+func (t *Token) Close() error {
+	return nil
+}
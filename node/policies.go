@@ -0,0 +1,48 @@
+package node
+
+import (
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+)
+
+// CheckPolicies returns a pegnet.PolicyViolation if the signing policies do
+// not allow the transactions, counting the spends of the last day against
+// the daily limits
+func (d *Pegnetd) CheckPolicies(txs []fat2.Transaction) ([]pegnet.PolicySpend, error) {
+	since := time.Now().Add(-pegnet.PolicyWindow)
+	return d.Policies.Check(txs, func(adr factom.FAAddress, asset string) (uint64, error) {
+		return d.Pegnet.SelectPolicySpent(nil, adr, asset, since)
+	})
+}
+
+// SubmitWithPolicies checks the transactions against the signing policies
+// and only calls submit if they are allowed. The spends of the submitted
+// entry are recorded for the daily limits. Submissions are serialized, so
+// concurrent requests can't exceed a limit together.
+func (d *Pegnetd) SubmitWithPolicies(txs []fat2.Transaction, submit func() (*factom.Bytes32, error)) (*factom.Bytes32, error) {
+	if d.Policies.Empty() {
+		return submit()
+	}
+
+	d.policiesMu.Lock()
+	defer d.policiesMu.Unlock()
+	spends, err := d.CheckPolicies(txs)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := submit()
+	if err != nil {
+		return nil, err
+	}
+	if len(spends) > 0 && hash != nil {
+		// The entry is submitted already, a failure only loosens the limits
+		if err := d.Pegnet.InsertPolicySpends(nil, time.Now(), *hash, spends); err != nil {
+			log.WithError(err).WithField("entryhash", hash).Error("failed to record the policy spends")
+		}
+	}
+	return hash, nil
+}
@@ -0,0 +1,195 @@
+// Package pricecheck compares the graded rates of the OPRs with the USD
+// prices of external apis, as an early warning of a manipulated oracle. An
+// api is configured as a source: the url of a json document and the path of
+// the price in it, so any api that quotes a single price per request works
+// without code for it.
+package pricecheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// Source is an external api that quotes the USD price of an asset
+type Source struct {
+	Asset fat2.PTicker
+	URL   string
+	// Path are the keys and array indexes of the price in the json
+	Path []string
+	// Inverse is set if the api quotes the asset per USD, eg a rate of
+	// EUR per USD for pEUR
+	Inverse bool
+}
+
+// ParseSource parses a source of the form "<asset> <url> <path> [inverse]",
+// the keys and array indexes of the path are separated by dots, eg:
+// "pXBT https://api.coinbase.com/v2/prices/BTC-USD/spot data.amount"
+func ParseSource(str string) (Source, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 3 && len(fields) != 4 {
+		return Source{}, fmt.Errorf("source %q: expected '<asset> <url> <path> [inverse]'", str)
+	}
+	s := Source{Asset: fat2.StringToTicker(fields[0]), URL: fields[1], Path: strings.Split(fields[2], ".")}
+	if s.Asset == fat2.PTickerInvalid {
+		return Source{}, fmt.Errorf("source %q: invalid asset %q", str, fields[0])
+	}
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Source{}, fmt.Errorf("source %q: expected an http or https url", str)
+	}
+	if len(fields) == 4 {
+		if fields[3] != "inverse" {
+			return Source{}, fmt.Errorf("source %q: unknown option %q", str, fields[3])
+		}
+		s.Inverse = true
+	}
+	return s, nil
+}
+
+// Fetch requests the price of the source in USD
+func (s Source) Fetch(ctx context.Context, c *http.Client) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// A price is never more than a few KB, unlike an error page
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: %s", s.URL, resp.Status)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, fmt.Errorf("%s: %v", s.URL, err)
+	}
+	price, err := lookup(doc, s.Path)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", s.URL, err)
+	}
+	if price <= 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+		return 0, fmt.Errorf("%s: invalid price %v", s.URL, price)
+	}
+	if s.Inverse {
+		price = 1 / price
+	}
+	return price, nil
+}
+
+// lookup returns the number at the path of the json, numbers quoted as
+// strings are accepted
+func lookup(doc interface{}, path []string) (float64, error) {
+	for i, key := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return 0, fmt.Errorf("%s not found", strings.Join(path[:i+1], "."))
+			}
+			doc = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return 0, fmt.Errorf("%s is not an index of %d items", strings.Join(path[:i+1], "."), len(v))
+			}
+			doc = v[index]
+		default:
+			return 0, fmt.Errorf("%s is not an object or array", strings.Join(path[:i], "."))
+		}
+	}
+	switch v := doc.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("%s is not a number", strings.Join(path, "."))
+}
+
+// References fetches the prices of all sources concurrently, grouped by
+// asset. The errors are of the sources that failed.
+func References(ctx context.Context, c *http.Client, sources []Source) (map[fat2.PTicker][]float64, []error) {
+	type result struct {
+		asset fat2.PTicker
+		price float64
+		err   error
+	}
+	results := make(chan result, len(sources))
+	for _, s := range sources {
+		go func(s Source) {
+			price, err := s.Fetch(ctx, c)
+			results <- result{s.Asset, price, err}
+		}(s)
+	}
+	refs := make(map[fat2.PTicker][]float64)
+	var errs []error
+	for range sources {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		refs[r.asset] = append(refs[r.asset], r.price)
+	}
+	return refs, errs
+}
+
+// Compare checks the graded rates of the height against the reference
+// prices. Assets without a rate or a reference are left out. The rates are
+// in pUSD with 8 decimals, the threshold and the deviations are in percent.
+func Compare(height uint32, rates map[fat2.PTicker]uint64, refs map[fat2.PTicker][]float64, threshold float64, now time.Time) pegnet.PriceCheck {
+	c := pegnet.PriceCheck{Height: height, Checked: now, Assets: []pegnet.AssetPriceCheck{}}
+	tickers := make([]fat2.PTicker, 0, len(refs))
+	for ticker := range refs {
+		tickers = append(tickers, ticker)
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i] < tickers[j] })
+
+	for _, ticker := range tickers {
+		rate, ok := rates[ticker]
+		prices := refs[ticker]
+		if !ok || len(prices) == 0 {
+			continue
+		}
+		a := pegnet.AssetPriceCheck{
+			Asset:     ticker.String(),
+			Rate:      float64(rate) / 1e8,
+			Reference: median(prices),
+			Sources:   len(prices),
+		}
+		a.Deviation = math.Abs(a.Rate-a.Reference) / a.Reference * 100
+		a.Flagged = a.Deviation > threshold
+		c.Flagged = c.Flagged || a.Flagged
+		c.MaxDeviation = math.Max(c.MaxDeviation, a.Deviation)
+		c.Assets = append(c.Assets, a)
+	}
+	return c
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,126 @@
+package pricecheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	s, err := ParseSource("pXBT https://api.coinbase.com/v2/prices/BTC-USD/spot data.amount")
+	require.NoError(t, err)
+	assert.Equal(t, fat2.PTickerXBT, s.Asset)
+	assert.Equal(t, []string{"data", "amount"}, s.Path)
+	assert.False(t, s.Inverse)
+
+	s, err = ParseSource("pEUR http://localhost/latest rates.EUR inverse")
+	require.NoError(t, err)
+	assert.True(t, s.Inverse)
+
+	for _, str := range []string{
+		"pXBT https://example.com",
+		"XBT https://example.com price",
+		"pXBT ftp://example.com price",
+		"pXBT example.com price",
+		"pEUR https://example.com rates.EUR invert",
+	} {
+		_, err := ParseSource(str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"data": {"amount": "9500.25"}, "list": [{"p": 1.5}], "name": "x"}`), &doc))
+
+	v, err := lookup(doc, []string{"data", "amount"})
+	require.NoError(t, err)
+	assert.Equal(t, 9500.25, v)
+	v, err = lookup(doc, []string{"list", "0", "p"})
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, v)
+
+	for _, path := range [][]string{{"data", "price"}, {"list", "1", "p"}, {"name"}, {"name", "x"}, {"data"}} {
+		_, err := lookup(doc, path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/btc":
+			_, _ = w.Write([]byte(`{"data": {"amount": "10000"}}`))
+		case "/btc2":
+			_, _ = w.Write([]byte(`{"price": 10200}`))
+		case "/eur":
+			_, _ = w.Write([]byte(`{"rates": {"EUR": 0.8}}`))
+		case "/zero":
+			_, _ = w.Write([]byte(`{"price": 0}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	var sources []Source
+	for _, str := range []string{
+		"pXBT " + ts.URL + "/btc data.amount",
+		"pXBT " + ts.URL + "/btc2 price",
+		"pEUR " + ts.URL + "/eur rates.EUR inverse",
+		"pXAU " + ts.URL + "/zero price",
+		"pETH " + ts.URL + "/missing price",
+	} {
+		s, err := ParseSource(str)
+		require.NoError(t, err)
+		sources = append(sources, s)
+	}
+
+	refs, errs := References(context.Background(), ts.Client(), sources)
+	assert.Len(t, errs, 2)
+	assert.ElementsMatch(t, []float64{10000, 10200}, refs[fat2.PTickerXBT])
+	assert.Equal(t, []float64{1.25}, refs[fat2.PTickerEUR])
+	assert.NotContains(t, refs, fat2.PTickerXAU)
+	assert.NotContains(t, refs, fat2.PTickerETH)
+}
+
+func TestCompare(t *testing.T) {
+	rates := map[fat2.PTicker]uint64{
+		fat2.PTickerXBT: 10000e8,
+		fat2.PTickerEUR: 1.1e8,
+		fat2.PTickerETH: 200e8,
+	}
+	refs := map[fat2.PTicker][]float64{
+		fat2.PTickerXBT: {9000, 10100, 9900}, // median 9900
+		fat2.PTickerEUR: {1.25},
+		fat2.PTickerXAU: {1500}, // no rate
+	}
+	now := time.Unix(1600000000, 0)
+	c := Compare(100, rates, refs, 5, now)
+	assert.Equal(t, uint32(100), c.Height)
+	assert.Equal(t, now, c.Checked)
+	assert.True(t, c.Flagged)
+	require.Len(t, c.Assets, 2)
+
+	eur, xbt := c.Assets[0], c.Assets[1]
+	assert.Equal(t, "pEUR", eur.Asset)
+	assert.True(t, eur.Flagged)
+	assert.InDelta(t, 12, eur.Deviation, 1e-9)
+	assert.Equal(t, "pXBT", xbt.Asset)
+	assert.Equal(t, 9900.0, xbt.Reference)
+	assert.Equal(t, 3, xbt.Sources)
+	assert.False(t, xbt.Flagged)
+	assert.InDelta(t, 1.0101, xbt.Deviation, 1e-4)
+	assert.InDelta(t, 12, c.MaxDeviation, 1e-9)
+
+	c = Compare(100, rates, refs, 15, now)
+	assert.False(t, c.Flagged)
+	assert.Equal(t, 10100.0, median([]float64{10200, 10000}))
+}
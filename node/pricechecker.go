@@ -0,0 +1,230 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/notify"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/node/pricecheck"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// PriceCheckTimeout is how long the sources have to respond
+const PriceCheckTimeout = 15 * time.Second
+
+// PriceChecker compares the rates of the latest graded block with the
+// reference prices of the sources. A rate that deviates by more than the
+// Threshold flags the block, which is recorded, logged, and sent to the
+// notifiers with the "pricedeviation" trigger.
+type PriceChecker struct {
+	Sources []pricecheck.Source
+	// Threshold is the deviation in percent that flags a block
+	Threshold float64
+	// MaxAge is how old a block can be to be checked, older blocks are
+	// skipped as the references are the current prices
+	MaxAge time.Duration
+	Client *http.Client
+	// Notifier is nil if no notifiers are configured
+	Notifier *notify.Service
+	// Latest returns the latest height with rates, its rates, and the time of
+	// its block. A height of 0 has no rates yet.
+	Latest func(ctx context.Context) (uint32, map[fat2.PTicker]uint64, time.Time, error)
+	// Record stores the check of a block
+	Record func(c pegnet.PriceCheck) error
+
+	mu      sync.Mutex
+	checked uint32
+	last    *pegnet.PriceCheck
+	err     string
+	flagged bool
+}
+
+// PriceCheckStatus is the state of the price checker. Checked is the latest
+// height that was checked or skipped.
+type PriceCheckStatus struct {
+	Threshold float64            `json:"threshold"`
+	Sources   int                `json:"sources"`
+	MaxAge    string             `json:"maxage"`
+	Checked   uint32             `json:"checked"`
+	Last      *pegnet.PriceCheck `json:"last,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// NewPriceChecker creates the price checker of the sources of the config,
+// nil if there are none
+func NewPriceChecker(conf *viper.Viper) (*PriceChecker, error) {
+	var sources []pricecheck.Source
+	for _, str := range conf.GetStringSlice(config.PriceCheckSources) {
+		s, err := pricecheck.ParseSource(str)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	return &PriceChecker{
+		Sources:   sources,
+		Threshold: conf.GetFloat64(config.PriceCheckThreshold),
+		MaxAge:    conf.GetDuration(config.PriceCheckMaxAge),
+		Client:    &http.Client{Timeout: PriceCheckTimeout},
+	}, nil
+}
+
+// Run checks the latest block every interval until the context is cancelled
+func (c *PriceChecker) Run(ctx context.Context, interval time.Duration) {
+	c.Poll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.Poll(ctx)
+	}
+}
+
+// Poll checks the latest height with rates if it was not checked yet. If no
+// source responds the height is tried again at the next poll.
+func (c *PriceChecker) Poll(ctx context.Context) {
+	height, rates, blockTime, err := c.Latest(ctx)
+	if err != nil {
+		c.setError(err)
+		return
+	}
+	c.mu.Lock()
+	checked := c.checked
+	c.mu.Unlock()
+	if height == 0 || height <= checked {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(blockTime) > c.MaxAge {
+		c.mu.Lock()
+		c.checked = height
+		c.mu.Unlock()
+		log.WithFields(log.Fields{"height": height, "block": blockTime}).Debug("price check: block is too old to compare, skipped")
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, PriceCheckTimeout)
+	refs, errs := pricecheck.References(fetchCtx, c.Client, c.Sources)
+	cancel()
+	for _, err := range errs {
+		log.WithError(err).Debug("price check: source failed")
+	}
+	if len(refs) == 0 {
+		c.setError(fmt.Errorf("no source responded, %d failed", len(errs)))
+		return
+	}
+
+	check := pricecheck.Compare(height, rates, refs, c.Threshold, now)
+	if err := c.Record(check); err != nil {
+		c.setError(err)
+		return
+	}
+	c.mu.Lock()
+	c.checked, c.last, c.err = height, &check, ""
+	if len(errs) > 0 {
+		c.err = fmt.Sprintf("%d of %d sources failed, the last: %v", len(errs), len(c.Sources), errs[len(errs)-1])
+	}
+	changed := check.Flagged != c.flagged
+	c.flagged = check.Flagged
+	c.mu.Unlock()
+
+	c.report(ctx, check, changed)
+}
+
+// report logs a flagged block and notifies when the rates start and stop
+// deviating
+func (c *PriceChecker) report(ctx context.Context, check pegnet.PriceCheck, changed bool) {
+	if !check.Flagged {
+		if changed {
+			log.WithField("height", check.Height).Info("graded rates are back within the price check threshold")
+			if c.notify() {
+				c.Notifier.Resolved(ctx, notify.TriggerPriceDeviation, fmt.Sprintf("pegnetd: the graded rates of height %d are within %.2f%% of the reference prices",
+					check.Height, c.Threshold))
+			}
+		}
+		return
+	}
+
+	var assets []string
+	for _, a := range check.Assets {
+		if !a.Flagged {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"height":    check.Height,
+			"asset":     a.Asset,
+			"rate":      a.Rate,
+			"reference": a.Reference,
+			"deviation": fmt.Sprintf("%.2f%%", a.Deviation),
+		}).Warn("PRICE DEVIATION: the graded rate deviates from the reference price")
+		assets = append(assets, fmt.Sprintf("%s %.2f%% (%g vs %g)", a.Asset, a.Deviation, a.Rate, a.Reference))
+	}
+	if c.notify() {
+		c.Notifier.Send(ctx, notify.TriggerPriceDeviation, fmt.Sprintf("pegnetd: the graded rates of height %d deviate from the reference prices: %s",
+			check.Height, strings.Join(assets, ", ")))
+	}
+}
+
+func (c *PriceChecker) notify() bool {
+	return c.Notifier != nil && c.Notifier.Triggers[notify.TriggerPriceDeviation]
+}
+
+func (c *PriceChecker) setError(err error) {
+	c.mu.Lock()
+	c.err = err.Error()
+	c.mu.Unlock()
+	log.WithError(err).Debug("price check: failed")
+}
+
+// Status returns the state of the last check
+func (c *PriceChecker) Status() PriceCheckStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return PriceCheckStatus{
+		Threshold: c.Threshold,
+		Sources:   len(c.Sources),
+		MaxAge:    c.MaxAge.String(),
+		Checked:   c.checked,
+		Last:      c.last,
+		Error:     c.err,
+	}
+}
+
+// latestRates returns the latest height with rates, its rates, and the time
+// of its block
+func (d *Pegnetd) latestRates(ctx context.Context) (uint32, map[fat2.PTicker]uint64, time.Time, error) {
+	height, err := d.Pegnet.SelectLatestRateHeight(ctx, nil)
+	if err != nil || height == 0 {
+		return 0, nil, time.Time{}, err
+	}
+	rates, err := d.Pegnet.SelectRates(ctx, height)
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	block, err := d.Pegnet.SelectBlock(ctx, height)
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+	// Without a block summary the height is too old to have one
+	var ts time.Time
+	if block != nil {
+		ts = block.Timestamp
+	}
+	return height, rates, ts, nil
+}
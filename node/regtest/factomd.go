@@ -0,0 +1,282 @@
+// Package regtest is an in-process mock of the factomd api for the regtest
+// mode. Blocks are only mined on request, with the OPRs of the injected rates,
+// the entries revealed since the last block, and the burns of the funded
+// addresses.
+package regtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/grader"
+	log "github.com/sirupsen/logrus"
+)
+
+// Balance is the EC and FCT balance of every address, the mock does not
+// track the spending of either
+const Balance = 1e15
+
+var (
+	errBlockNotFound  = jrpc.NewError(-32008, "Block not found", nil)
+	errObjectNotFound = jrpc.NewError(-32008, "Object not found", nil)
+)
+
+// Factomd serves the factomd api from the blocks it mined. It implements
+// http.RoundTripper, so a factom client can use it without a server.
+type Factomd struct {
+	OPRChain         factom.Bytes32
+	TransactionChain factom.Bytes32
+	// BurnRCD is the EC output of the burns made by Fund
+	BurnRCD factom.Bytes32
+
+	handler http.Handler
+
+	mu     sync.Mutex
+	blocks []block
+	// data is the raw data of every block, entry, and transaction by hash
+	data    map[factom.Bytes32][]byte
+	heads   map[factom.Bytes32]chainHead
+	pending []pendingEntry
+	burns   []factom.FactoidTransaction
+	rates   map[string]float64
+	// winners are the shorthashes of the previous OPR winners
+	winners []string
+
+	prevDBlock, prevDBlockFullHash factom.Bytes32
+	prevFBlock, prevFBlockLedger   factom.Bytes32
+	lastTimestamp, lastSalt        time.Time
+}
+
+// block is a mined height
+type block struct {
+	KeyMR  factom.Bytes32
+	DBlock []byte
+	FBlock []byte
+}
+
+// chainHead is the latest eblock of a chain
+type chainHead struct {
+	KeyMR    factom.Bytes32
+	FullHash factom.Bytes32
+	Sequence uint32
+}
+
+// pendingEntry is a revealed entry that goes into the next block
+type pendingEntry struct {
+	ChainID factom.Bytes32
+	Hash    factom.Bytes32
+}
+
+// New returns a mock with the genesis block at height 0 mined. The chains
+// and the burn rcd are those of the network the node syncs.
+func New(oprChain, transactionChain, burnRCD factom.Bytes32) (*Factomd, error) {
+	// The full 30 bit LXR map takes gigabytes of memory, the OPRs are
+	// mined with a small map unless the size is set
+	if os.Getenv("LXRBITSIZE") == "" {
+		_ = os.Setenv("LXRBITSIZE", "10")
+	}
+	grader.InitLX()
+
+	f := &Factomd{
+		OPRChain:         oprChain,
+		TransactionChain: transactionChain,
+		BurnRCD:          burnRCD,
+		data:             make(map[factom.Bytes32][]byte),
+		heads:            make(map[factom.Bytes32]chainHead),
+		rates:            DefaultRates(),
+	}
+	f.handler = jrpc.HTTPRequestHandler(f.methods(), log.WithField("component", "regtest"))
+	if err := f.mineBlock(nil); err != nil {
+		return nil, fmt.Errorf("failed to mine the genesis block: %v", err)
+	}
+	return f, nil
+}
+
+// Height is the height of the latest mined block
+func (f *Factomd) Height() uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return uint32(len(f.blocks) - 1)
+}
+
+// RoundTrip serves the request of a factom client from the mined blocks
+func (f *Factomd) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := &responseWriter{header: make(http.Header), status: http.StatusOK}
+	f.handler.ServeHTTP(w, req)
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          ioutil.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header         { return w.header }
+func (w *responseWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+func (w *responseWriter) WriteHeader(status int)      { w.status = status }
+
+func (f *Factomd) methods() jrpc.MethodMap {
+	return jrpc.MethodMap{
+		"heights":              f.heights,
+		"dblock-by-height":     f.dblockByHeight,
+		"fblock-by-height":     f.fblockByHeight,
+		"raw-data":             f.rawData,
+		"pending-entries":      f.pendingEntries,
+		"commit-entry":         f.commitEntry,
+		"reveal-entry":         f.revealEntry,
+		"entry-credit-balance": f.balance,
+		"factoid-balance":      f.balance,
+		"properties":           f.properties,
+	}
+}
+
+func (f *Factomd) heights(_ context.Context, _ json.RawMessage) interface{} {
+	height := f.Height()
+	return factom.Heights{
+		DirectoryBlock: height,
+		Leader:         height + 1,
+		EntryBlock:     height,
+		Entry:          height,
+	}
+}
+
+type paramsHeight struct {
+	Height *uint32 `json:"height"`
+}
+
+// block returns the mined block of the height in the params
+func (f *Factomd) block(data json.RawMessage) (block, error) {
+	var params paramsHeight
+	if err := json.Unmarshal(data, &params); err != nil || params.Height == nil {
+		return block{}, jrpc.ErrorInvalidParams("expected a height")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if int(*params.Height) >= len(f.blocks) {
+		return block{}, errBlockNotFound
+	}
+	return f.blocks[*params.Height], nil
+}
+
+func (f *Factomd) dblockByHeight(_ context.Context, data json.RawMessage) interface{} {
+	b, err := f.block(data)
+	if err != nil {
+		return err
+	}
+	type dblock struct {
+		KeyMR factom.Bytes32 `json:"keymr"`
+	}
+	return struct {
+		DBlock  dblock       `json:"dblock"`
+		RawData factom.Bytes `json:"rawdata"`
+	}{DBlock: dblock{KeyMR: b.KeyMR}, RawData: b.DBlock}
+}
+
+func (f *Factomd) fblockByHeight(_ context.Context, data json.RawMessage) interface{} {
+	b, err := f.block(data)
+	if err != nil {
+		return err
+	}
+	return struct {
+		RawData factom.Bytes `json:"rawdata"`
+	}{RawData: b.FBlock}
+}
+
+func (f *Factomd) rawData(_ context.Context, data json.RawMessage) interface{} {
+	var params struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil || params.Hash == nil {
+		return jrpc.ErrorInvalidParams("expected a hash")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	raw, ok := f.data[*params.Hash]
+	if !ok {
+		return errObjectNotFound
+	}
+	return struct {
+		Data factom.Bytes `json:"data"`
+	}{Data: raw}
+}
+
+func (f *Factomd) pendingEntries(_ context.Context, _ json.RawMessage) interface{} {
+	type entry struct {
+		ChainID factom.Bytes32 `json:"chainid"`
+		Hash    factom.Bytes32 `json:"entryhash"`
+		Status  string         `json:"status"`
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := make([]entry, len(f.pending))
+	for i, e := range f.pending {
+		res[i] = entry{ChainID: e.ChainID, Hash: e.Hash, Status: "TransactionACK"}
+	}
+	return res
+}
+
+// commitEntry accepts every commit, the entry credits are not tracked
+func (f *Factomd) commitEntry(_ context.Context, _ json.RawMessage) interface{} {
+	return struct {
+		Message string `json:"message"`
+	}{Message: "Entry Commit Success"}
+}
+
+func (f *Factomd) revealEntry(_ context.Context, data json.RawMessage) interface{} {
+	var params struct {
+		Entry factom.Bytes `json:"entry"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	var e factom.Entry
+	if err := e.UnmarshalBinary(params.Entry); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[*e.Hash] = params.Entry
+	f.pending = append(f.pending, pendingEntry{ChainID: *e.ChainID, Hash: *e.Hash})
+	return struct {
+		Message string         `json:"message"`
+		ChainID factom.Bytes32 `json:"chainid"`
+		Hash    factom.Bytes32 `json:"entryhash"`
+	}{Message: "Entry Reveal Success", ChainID: *e.ChainID, Hash: *e.Hash}
+}
+
+func (f *Factomd) balance(_ context.Context, _ json.RawMessage) interface{} {
+	return struct {
+		Balance uint64 `json:"balance"`
+	}{Balance: Balance}
+}
+
+func (f *Factomd) properties(_ context.Context, _ json.RawMessage) interface{} {
+	return struct {
+		FactomdVersion    string `json:"factomdversion"`
+		FactomdAPIVersion string `json:"factomdapiversion"`
+	}{FactomdVersion: "regtest", FactomdAPIVersion: "2.0"}
+}
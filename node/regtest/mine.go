@@ -0,0 +1,393 @@
+package regtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnet/modules/grader"
+	"github.com/pegnet/pegnet/modules/opr"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/node/grading"
+)
+
+// Miners is the number of OPRs in every mined block, enough for all of them
+// to win
+const Miners = 25
+
+// ExchangeRate is the EC rate of the factoid blocks
+const ExchangeRate = 1000
+
+// Clock is the time of the mined blocks and of the burns. Every block is at
+// least a minute after the previous one, a fixed clock mines the same chain
+// every time.
+var Clock = time.Now
+
+// DefaultRates are the OPR rates a mock starts with, in USD per unit
+func DefaultRates() map[string]float64 {
+	return map[string]float64{
+		"PEG": 0.004, "USD": 1, "EUR": 1.1, "JPY": 0.0092, "GBP": 1.3,
+		"CAD": 0.76, "CHF": 1.03, "INR": 0.014, "SGD": 0.73, "CNY": 0.14,
+		"HKD": 0.13, "KRW": 0.00084, "BRL": 0.23, "PHP": 0.02, "MXN": 0.053,
+		"XAU": 1580, "XAG": 17.9, "XBT": 9300, "ETH": 190, "LTC": 65,
+		"RVN": 0.031, "XBC": 380, "FCT": 3.5, "BNB": 18, "XLM": 0.07,
+		"ADA": 0.055, "XMR": 75, "DASH": 110, "ZEC": 60, "DCR": 20,
+		"AUD": 0.67, "NZD": 0.65, "SEK": 0.1, "NOK": 0.11, "RUB": 0.016,
+		"ZAR": 0.067, "TRY": 0.17, "EOS": 4.2, "LINK": 4.3, "ATOM": 4.6,
+		"BAT": 0.25, "XTZ": 2.1,
+	}
+}
+
+// DefaultCoinbase is the address the OPRs pay out to if a block is mined
+// without a coinbase
+func DefaultCoinbase() factom.FAAddress {
+	return factom.FsAddress(sha256.Sum256([]byte("regtest"))).FAAddress()
+}
+
+// assetName is the OPR name of the asset, the pegnet tickers like "pUSD" are
+// accepted as well
+func assetName(name string) (string, bool) {
+	for _, a := range opr.V4Assets {
+		if name == a || name == "p"+a {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// SetRates changes the rates of the OPRs of the next blocks. The rates are
+// in USD per unit, assets that are left out keep their rate.
+func (f *Factomd) SetRates(rates map[string]float64) error {
+	set := make(map[string]float64, len(rates))
+	for name, rate := range rates {
+		asset, ok := assetName(name)
+		if !ok {
+			return fmt.Errorf("%s is not an asset of the oprs", name)
+		}
+		if opr.FloatToUint64(rate) == 0 {
+			return fmt.Errorf("the rate of %s must be at least 0.00000001", name)
+		}
+		set[asset] = rate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for asset, rate := range set {
+		f.rates[asset] = rate
+	}
+	return nil
+}
+
+// Rates returns the rates of the OPRs of the next block
+func (f *Factomd) Rates() map[string]float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rates := make(map[string]float64, len(f.rates))
+	for asset, rate := range f.rates {
+		rates[asset] = rate
+	}
+	return rates
+}
+
+// Fund burns the amount of FCT from the address in the next block, which
+// credits the address with the same amount of pFCT. Returns the id of the
+// burn transaction.
+func (f *Factomd) Fund(adr factom.FAAddress, amount uint64) (factom.Bytes32, error) {
+	if amount == 0 {
+		return factom.Bytes32{}, fmt.Errorf("the amount must be greater than 0")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The timestamp salts the transaction id, so every burn is unique
+	salt := Clock()
+	if !salt.After(f.lastSalt) {
+		salt = f.lastSalt.Add(time.Millisecond)
+	}
+	f.lastSalt = salt
+
+	// The burn is not signed, the node does not check the signatures
+	tx := factom.FactoidTransaction{
+		FactoidTransactionHeader: factom.FactoidTransactionHeader{
+			Version:       2,
+			TimestampSalt: salt,
+		},
+		FCTInputs: []factom.FactoidTransactionIO{{Amount: amount, Address: factom.Bytes32(adr)}},
+		ECOutputs: []factom.FactoidTransactionIO{{Amount: 0, Address: f.BurnRCD}},
+		Signatures: []factom.FactoidTransactionSignature{{
+			SignatureBlock: make(factom.Bytes, 64),
+		}},
+	}
+	id, err := tx.ComputeTransactionID()
+	if err != nil {
+		return factom.Bytes32{}, err
+	}
+	tx.TransactionID = &id
+	f.burns = append(f.burns, tx)
+	return id, nil
+}
+
+// Mine mines count blocks, with the OPRs paying out to the coinbase. Returns
+// the height of the last block.
+func (f *Factomd) Mine(count int, coinbase factom.FAAddress) (uint32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < count; i++ {
+		if err := f.mineBlock(&coinbase); err != nil {
+			return uint32(len(f.blocks) - 1), err
+		}
+	}
+	return uint32(len(f.blocks) - 1), nil
+}
+
+// mineBlock mines the next height with the pending entries and burns. The
+// OPRs are left out if there is no coinbase. The caller holds the lock,
+// except for the genesis block.
+func (f *Factomd) mineBlock(coinbase *factom.FAAddress) error {
+	height := uint32(len(f.blocks))
+	timestamp := Clock().Truncate(time.Minute)
+	if !timestamp.After(f.lastTimestamp) && height > 0 {
+		timestamp = f.lastTimestamp.Add(time.Minute)
+	}
+
+	entries := make(map[factom.Bytes32][]factom.Bytes32)
+	for _, e := range f.pending {
+		entries[e.ChainID] = append(entries[e.ChainID], e.Hash)
+	}
+	if coinbase != nil {
+		oprs, err := f.oprEntries(height, *coinbase)
+		if err != nil {
+			return err
+		}
+		entries[f.OPRChain] = append(entries[f.OPRChain], oprs...)
+	}
+
+	// The dblock lists the admin, ec, and factoid blocks before the
+	// eblocks, all sorted by chain id
+	elements := make([][]byte, 0, len(entries)+3)
+	adminBlock := sha256.Sum256(append([]byte("admin"), uint32Bytes(height)...))
+	ecBlock := sha256.Sum256(append([]byte("ec"), uint32Bytes(height)...))
+	fblockKeyMR, fblock, err := f.fblock(height)
+	if err != nil {
+		return err
+	}
+	for _, e := range [][2]factom.Bytes32{
+		{factom.ABlockChainID(), adminBlock},
+		{factom.ECBlockChainID(), ecBlock},
+		{factom.FBlockChainID(), fblockKeyMR},
+	} {
+		elements = append(elements, append(e[0][:], e[1][:]...))
+	}
+	chains := make([]factom.Bytes32, 0, len(entries))
+	for chain := range entries {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool { return bytes.Compare(chains[i][:], chains[j][:]) < 0 })
+	for _, chain := range chains {
+		keyMR, err := f.eblock(chain, height, entries[chain])
+		if err != nil {
+			return err
+		}
+		elements = append(elements, append(chain[:], keyMR[:]...))
+	}
+
+	bodyMR, err := factom.ComputeDBlockBodyMR(elements)
+	if err != nil {
+		return err
+	}
+	network := factom.LocalnetID()
+	data := []byte{0x00}
+	data = append(data, network[:]...)
+	data = append(data, bodyMR[:]...)
+	data = append(data, f.prevDBlock[:]...)
+	data = append(data, f.prevDBlockFullHash[:]...)
+	data = append(data, uint32Bytes(uint32(timestamp.Unix()/60))...)
+	data = append(data, uint32Bytes(height)...)
+	data = append(data, uint32Bytes(uint32(len(elements)))...)
+	for _, e := range elements {
+		data = append(data, e...)
+	}
+	var db factom.DBlock
+	if err := db.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	f.data[*db.KeyMR] = data
+	f.blocks = append(f.blocks, block{KeyMR: *db.KeyMR, DBlock: data, FBlock: fblock})
+	f.prevDBlock, f.prevDBlockFullHash = *db.KeyMR, *db.FullHash
+	f.lastTimestamp = timestamp
+	f.pending, f.burns = nil, nil
+	return nil
+}
+
+// eblock adds the entries to the chain, returns the KeyMR of the eblock
+func (f *Factomd) eblock(chain factom.Bytes32, height uint32, entries []factom.Bytes32) (factom.Bytes32, error) {
+	// All entries are in the first minute
+	marker := factom.Bytes32{31: 1}
+	objects := make([][]byte, 0, len(entries)+1)
+	for i := range entries {
+		objects = append(objects, entries[i][:])
+	}
+	objects = append(objects, marker[:])
+	bodyMR, err := factom.ComputeEBlockBodyMR(objects)
+	if err != nil {
+		return factom.Bytes32{}, err
+	}
+
+	head := f.heads[chain]
+	data := make([]byte, 0, factom.EBlockHeaderLen+len(objects)*factom.EBlockObjectLen)
+	data = append(data, chain[:]...)
+	data = append(data, bodyMR[:]...)
+	data = append(data, head.KeyMR[:]...)
+	data = append(data, head.FullHash[:]...)
+	data = append(data, uint32Bytes(head.Sequence)...)
+	data = append(data, uint32Bytes(height)...)
+	data = append(data, uint32Bytes(uint32(len(objects)))...)
+	for _, o := range objects {
+		data = append(data, o...)
+	}
+	var eb factom.EBlock
+	if err := eb.UnmarshalBinary(data); err != nil {
+		return factom.Bytes32{}, err
+	}
+
+	f.data[*eb.KeyMR] = data
+	f.heads[chain] = chainHead{KeyMR: *eb.KeyMR, FullHash: *eb.FullHash, Sequence: head.Sequence + 1}
+	return *eb.KeyMR, nil
+}
+
+// fblock builds the factoid block of the pending burns, returns its KeyMR
+// and raw data
+func (f *Factomd) fblock(height uint32) (factom.Bytes32, []byte, error) {
+	var body []byte
+	elements := make([][]byte, 0, len(f.burns)+10)
+	for i := range f.burns {
+		tx, err := f.burns[i].MarshalBinary()
+		if err != nil {
+			return factom.Bytes32{}, nil, err
+		}
+		f.data[*f.burns[i].TransactionID] = tx
+		body = append(body, tx...)
+		elements = append(elements, tx)
+	}
+	// All transactions are in the first minute
+	for i := 0; i < 10; i++ {
+		body = append(body, factom.FBlockMinuteMarker)
+		elements = append(elements, []byte{factom.FBlockMinuteMarker})
+	}
+	bodyMR, err := factom.ComputeFBlockBodyMR(elements)
+	if err != nil {
+		return factom.Bytes32{}, nil, err
+	}
+
+	chain := factom.FBlockChainID()
+	rate := make([]byte, 8)
+	binary.BigEndian.PutUint64(rate, ExchangeRate)
+	data := append([]byte{}, chain[:]...)
+	data = append(data, bodyMR[:]...)
+	data = append(data, f.prevFBlock[:]...)
+	data = append(data, f.prevFBlockLedger[:]...)
+	data = append(data, rate...)
+	data = append(data, uint32Bytes(height)...)
+	data = append(data, 0x00) // No header expansion
+	data = append(data, uint32Bytes(uint32(len(f.burns)))...)
+	data = append(data, uint32Bytes(uint32(len(body)))...)
+	data = append(data, body...)
+	var fb factom.FBlock
+	if err := fb.UnmarshalBinary(data); err != nil {
+		return factom.Bytes32{}, nil, err
+	}
+
+	f.data[*fb.KeyMR] = data
+	f.prevFBlock, f.prevFBlockLedger = *fb.KeyMR, *fb.LedgerKeyMR
+	return *fb.KeyMR, data, nil
+}
+
+// oprEntries mines the OPRs of the height with the current rates, and
+// grades them like the node does to know the winners the OPRs of the next
+// height have to list. The OPRs are of the grading version of the height,
+// from the V2 OPRs on.
+func (f *Factomd) oprEntries(height uint32, coinbase factom.FAAddress) ([]factom.Bytes32, error) {
+	version := activation.GradingVersion(height)
+	if version < 2 {
+		return nil, fmt.Errorf("the mock can not mine the V%d OPRs of height %d", version, height)
+	}
+	// The V2 and V3 OPRs list the V2 assets, the gradings from the V4
+	// grading on take the V4 OPRs
+	names, extVersion := opr.V4Assets, byte(4)
+	if version < 4 {
+		names, extVersion = opr.V2Assets, version
+	}
+	assets := make([]uint64, len(names))
+	for i, asset := range names {
+		assets[i] = opr.FloatToUint64(f.rates[asset])
+	}
+	winners := make([][]byte, Miners)
+	for i, w := range f.winners {
+		short, err := hex.DecodeString(w)
+		if err != nil {
+			return nil, err
+		}
+		winners[i] = short
+	}
+	for i := range winners {
+		if winners[i] == nil {
+			winners[i] = []byte{}
+		}
+	}
+
+	g, err := grading.New(version, int32(height), f.winners)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]factom.Bytes32, 0, Miners)
+	for i := 0; i < Miners; i++ {
+		content, err := (&opr.V2Content{
+			Address: coinbase.String(),
+			ID:      fmt.Sprintf("regtest%d", i+1),
+			Height:  int32(height),
+			Winners: winners,
+			Assets:  assets,
+		}).Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		nonce := uint32Bytes(uint32(i))
+		sha := sha256.Sum256(content)
+		difficulty := grader.LX.Hash(append(sha[:], nonce...))[:8]
+		e := factom.Entry{
+			ChainID: &f.OPRChain,
+			ExtIDs:  []factom.Bytes{nonce, difficulty, {extVersion}},
+			Content: content,
+		}
+		data, err := e.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		hash := factom.ComputeEntryHash(data)
+		f.data[hash] = data
+		hashes = append(hashes, hash)
+
+		extids := [][]byte{e.ExtIDs[0], e.ExtIDs[1], e.ExtIDs[2]}
+		if err := g.AddOPR(hash[:], extids, content); err != nil {
+			return nil, fmt.Errorf("mined an invalid opr: %v", err)
+		}
+	}
+
+	if graded := g.Grade(); len(graded.WinnersShortHashes()) > 0 {
+		f.winners = graded.WinnersShortHashes()
+	}
+	return hashes, nil
+}
+
+func uint32Bytes(x uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, x)
+	return data
+}
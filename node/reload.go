@@ -0,0 +1,198 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync/atomic"
+
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node/events"
+	"github.com/pegnet/pegnetd/node/notify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// reloadable are the config values that are applied by ReloadConfig. The
+// tokens and keys are read on every use, so they take effect as well.
+var reloadable = []string{
+	config.LoggingLevel,
+	config.Server,
+	config.DepositWebhook,
+	config.AlertWebhook,
+	config.NotifyTelegramToken,
+	config.NotifyTelegramChat,
+	config.NotifyDiscordWebhook,
+	config.NotifySMTPServer,
+	config.NotifySMTPUser,
+	config.NotifySMTPPassword,
+	config.NotifyEmailFrom,
+	config.NotifyEmailTo,
+	config.APIAdminToken,
+	config.SchedulerToken,
+	config.ECPrivateKey,
+	config.ECPrivateKeys,
+	config.SignerURL,
+	config.SignerToken,
+	config.SignerECAddresses,
+}
+
+// ReloadConfig reads the config file again and applies the values that can
+// change without a restart: the log level, the factomd server, the webhook
+// urls, the notifiers, and the tokens. The sync is not interrupted. Returns
+// the keys of the values that changed. An invalid config is not applied.
+//
+// The file is read and validated on its own, only the reloadable values are
+// copied into the config of the node. A value is only replaced if the file
+// changed it, so the values of the command line and the startup are kept
+// until then.
+func (d *Pegnetd) ReloadConfig() ([]string, error) {
+	fresh, err := readConfigFile(d.Config.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(fresh); err != nil {
+		return nil, err
+	}
+	level, err := log.ParseLevel(fresh.GetString(config.LoggingLevel))
+	if err != nil {
+		return nil, err
+	}
+	var notifiers *notify.Service
+	if d.Notifier != nil {
+		if notifiers, err = notify.NewFromConfig(fresh); err != nil {
+			return nil, fmt.Errorf("invalid notify config: %v", err)
+		}
+	}
+
+	d.configMu.Lock()
+	var changed []string
+	for _, key := range reloadable {
+		value := fresh.Get(key)
+		if prev, ok := d.configFile[key]; ok && fmt.Sprint(prev) == fmt.Sprint(value) {
+			continue
+		}
+		if fmt.Sprint(d.Config.Get(key)) != fmt.Sprint(value) {
+			changed = append(changed, key)
+			d.Config.Set(key, value)
+		}
+	}
+	d.configFile = reloadableValues(fresh)
+	server := d.Config.GetString(config.Server)
+	urls := map[string]string{
+		events.TypeDeposit: d.Config.GetString(config.DepositWebhook),
+		events.TypeAlert:   d.Config.GetString(config.AlertWebhook),
+	}
+	d.configMu.Unlock()
+	sort.Strings(changed)
+
+	log.SetLevel(level)
+	if err := d.factomd.set(server); err != nil {
+		return changed, fmt.Errorf("factomd server: %v", err)
+	}
+
+	if d.Events != nil {
+		for _, sink := range d.Events.Sinks() {
+			w, ok := sink.(*events.Webhook)
+			if !ok {
+				continue
+			}
+			for typ, u := range urls {
+				if w.Types[typ] && u != "" {
+					if err := w.SetURL(u); err != nil {
+						return changed, err
+					}
+				}
+			}
+		}
+	}
+
+	// The notifiers are only replaced, a node that started without any
+	// needs to be restarted to enable them
+	if d.Notifier != nil {
+		if notifiers != nil {
+			d.Notifier.SetNotifiers(notifiers.Notifiers)
+		} else {
+			d.Notifier.SetNotifiers(nil)
+		}
+	}
+
+	log.WithField("changed", changed).Info("config reloaded")
+	return changed, nil
+}
+
+// readConfigFile reads the config file into a config of its own, with the
+// defaults and the environment overrides
+func readConfigFile(path string) (*viper.Viper, error) {
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.SetConfigFile(path)
+	if err := conf.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// reloadableValues returns the reloadable values of the config
+func reloadableValues(conf *viper.Viper) map[string]interface{} {
+	values := make(map[string]interface{}, len(reloadable))
+	for _, key := range reloadable {
+		values[key] = conf.Get(key)
+	}
+	return values
+}
+
+// ConfigString returns the string of the key of the config. The reloadable
+// values are read with it while the node runs, as ReloadConfig replaces them.
+func (d *Pegnetd) ConfigString(key string) string {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.Config.GetString(key)
+}
+
+// ConfigStrings returns the string slice of the key of the config, see
+// ConfigString
+func (d *Pegnetd) ConfigStrings(key string) []string {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.Config.GetStringSlice(key)
+}
+
+// endpointTransport sends the requests of the factom client to the current
+// factomd server, so the server can change while the node runs
+type endpointTransport struct {
+	base    http.RoundTripper
+	initial string
+	current atomic.Value // *url.URL
+}
+
+func newEndpointTransport(base http.RoundTripper, server string) *endpointTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &endpointTransport{base: base, initial: server}
+}
+
+func (t *endpointTransport) set(server string) error {
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s: must be http:// or https://", server)
+	}
+	t.current.Store(u)
+	return nil
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := t.current.Load().(*url.URL)
+	if u == nil || u.String() == t.initial {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	target := *u
+	req.URL, req.Host = &target, u.Host
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/signer"
+)
+
+// Signer returns the client of the signer daemon, nil if no signer is
+// configured. It is made from the config on every use, so a reload-config
+// applies a changed url or token.
+func (d *Pegnetd) Signer() (*signer.Client, error) {
+	url := d.ConfigString(config.SignerURL)
+	if url == "" {
+		return nil, nil
+	}
+	return signer.NewClient(url, d.ConfigString(config.SignerToken))
+}
+
+// signerECKey returns the EC key of the signer for the address
+func (d *Pegnetd) signerECKey(ctx context.Context, ec factom.ECAddress) (ECKey, error) {
+	c, err := d.Signer()
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%s is required for the key %s", config.SignerURL, ec)
+	}
+	return c.ECKey(ctx, ec)
+}
+
+// signTransactions signs the batch with the key of the input. The key is on
+// the PKCS#11 token if it holds it, otherwise the key is held by the signer
+// if it is configured, or by walletd.
+func (d *Pegnetd) signTransactions(ctx context.Context, batch fat2.TransactionBatch, input factom.FAAddress) (factom.Entry, error) {
+	if k, ok := d.HSM.Key(input.String()); ok {
+		return signWith(batch, signer.NewRCDSigner(ctx, k.PublicKey(), hsmSign(k)))
+	}
+	c, err := d.Signer()
+	if err != nil {
+		return factom.Entry{}, err
+	}
+	if c == nil {
+		priv, err := input.GetFsAddress(nil, d.FactomClient)
+		if err != nil {
+			return factom.Entry{}, fmt.Errorf("unable to get private key: %s", err.Error())
+		}
+		return batch.Sign(priv)
+	}
+
+	rcd, err := c.RCDSigner(ctx, input)
+	if err != nil {
+		return factom.Entry{}, fmt.Errorf("unable to get the key of the signer: %s", err.Error())
+	}
+	return signWith(batch, rcd)
+}
+
+// signWith signs the batch with a signer that can fail
+func signWith(batch fat2.TransactionBatch, rcd *signer.RCDSigner) (factom.Entry, error) {
+	entry, err := batch.Sign(rcd)
+	if err != nil {
+		return factom.Entry{}, err
+	}
+	if err := rcd.Err(); err != nil {
+		return factom.Entry{}, err
+	}
+	return entry, nil
+}
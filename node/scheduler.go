@@ -0,0 +1,133 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+)
+
+// RunScheduler submits the due schedules every period until the context is
+// cancelled. Nothing is submitted while the node is syncing, the balances
+// would not be current.
+func (d *Pegnetd) RunScheduler(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		heights := new(factom.Heights)
+		if err := heights.Get(nil, d.FactomClient); err != nil {
+			log.WithError(err).Debug("scheduler: failed to fetch heights")
+			continue
+		}
+		synced := d.GetCurrentSync()
+		if synced < heights.DirectoryBlock {
+			continue
+		}
+
+		// The entries are included in the next block
+		if err := d.submitSchedules(synced + 1); err != nil {
+			log.WithError(err).Error("scheduler: failed to submit the schedules")
+		}
+	}
+}
+
+// submitSchedules submits every schedule due at the height and records the
+// results. A failed submission is skipped, not retried.
+func (d *Pegnetd) submitSchedules(height uint32) error {
+	due, err := d.Pegnet.SelectDueSchedules(nil, height)
+	if err != nil {
+		return err
+	}
+	for _, s := range due {
+		hash, err := d.submitSchedule(s)
+		s.LastEntryHash, s.LastError = hash, ""
+		if err != nil {
+			s.LastError = err.Error()
+		}
+		if _, denied := err.(pegnet.PolicyViolation); denied {
+			d.auditDenied(fmt.Sprintf("schedule %d", s.ID), err)
+		}
+		s.Advance(height)
+		if err := d.Pegnet.UpdateSchedule(nil, s); err != nil {
+			return err
+		}
+
+		sLog := log.WithFields(log.Fields{"id": s.ID, "height": height, "remaining": s.Remaining})
+		if err != nil {
+			sLog.WithError(err).Warn("scheduler: failed to submit transfer")
+		} else {
+			sLog.WithField("entryhash", hash).Info("scheduler: submitted transfer")
+		}
+	}
+	return nil
+}
+
+// auditDenied records a transfer of the node that a signing policy denied in
+// the audit log
+func (d *Pegnetd) auditDenied(caller string, err error) {
+	a := pegnet.SendAudit{Time: time.Now(), Caller: caller, Outcome: pegnet.AuditDenied, Error: err.Error()}
+	if _, err := d.Pegnet.InsertSendAudit(nil, a); err != nil {
+		log.WithError(err).WithField("caller", caller).Error("failed to write the audit log")
+	}
+}
+
+// submitSchedule composes the transfer of the schedule, signs it with the
+// key in walletd or the signer and submits it. The signing policies are checked before the
+// transfer is signed.
+func (d *Pegnetd) submitSchedule(s pegnet.Schedule) (*factom.Bytes32, error) {
+	asset := fat2.StringToTicker(s.Asset)
+	bals, err := d.Pegnet.SelectBalances(&s.Input)
+	if err != nil {
+		return nil, err
+	}
+	if bals[asset] < s.Amount {
+		return nil, pegnet.InsufficientBalanceErr
+	}
+
+	txs := []fat2.Transaction{{
+		Input:     fat2.TypedAddressAmountTuple{Address: s.Input, Amount: s.Amount, Type: asset},
+		Transfers: []fat2.AddressAmountTuple{{Address: s.Output, Amount: s.Amount}},
+	}}
+	return d.SubmitWithPolicies(txs, func() (*factom.Bytes32, error) {
+		ctx := context.Background()
+		var txBatch fat2.TransactionBatch
+		txBatch.Version = 1
+		txBatch.Transactions = txs
+		txBatch.Entry.ChainID = &TransactionChain
+		entry, err := d.signTransactions(ctx, txBatch, s.Input)
+		if err != nil {
+			return nil, err
+		}
+		txBatch.Entry = entry
+		if err := txBatch.Validate(-1); err != nil {
+			return nil, fmt.Errorf("invalid tx: %s", err.Error())
+		}
+
+		cost, err := entry.Cost()
+		if err != nil {
+			return nil, err
+		}
+		ec, err := d.PickECKey(ctx, cost)
+		if err == ErrECExhausted {
+			return nil, fmt.Errorf("not enough ec balance for the transaction")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ec.ComposeCreate(ctx, d.FactomClient, &entry); err != nil {
+			return nil, fmt.Errorf("failed to submit entry: %s", err.Error())
+		}
+		d.RecordECUse(ec.ECAddress(), cost)
+		return entry.Hash, nil
+	})
+}
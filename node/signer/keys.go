@@ -0,0 +1,192 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// SignFunc returns the ed25519 signature of the payload
+type SignFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// ECKey pays for entries with an EC key that signs outside of the node, like
+// the key of a signer or a hardware token. Every signature is checked against
+// the public key.
+type ECKey struct {
+	address   factom.ECAddress
+	publicKey []byte
+	sign      SignFunc
+}
+
+// NewECKey returns the EC key of the public key that signs with the func
+func NewECKey(publicKey ed25519.PublicKey, sign SignFunc) *ECKey {
+	return &ECKey{address: factom.ECAddress(bytesTo32(publicKey)), publicKey: publicKey, sign: verified(publicKey, sign)}
+}
+
+// ECKey returns the EC key of the address, an error if the signer does not
+// have it
+func (c *Client) ECKey(ctx context.Context, ec factom.ECAddress) (*ECKey, error) {
+	k, err := c.Key(ctx, ec.String())
+	if err != nil {
+		return nil, err
+	}
+	if factom.ECAddress(bytesTo32(k.PublicKey)) != ec {
+		return nil, fmt.Errorf("signer: the public key of %s does not match", ec)
+	}
+	return NewECKey(ed25519.PublicKey(k.PublicKey), func(ctx context.Context, payload []byte) ([]byte, error) {
+		return c.Sign(ctx, ec.String(), KindECCommit, payload, k.PublicKey)
+	}), nil
+}
+
+// ECAddress is the address of the key
+func (k *ECKey) ECAddress() factom.ECAddress {
+	return k.address
+}
+
+// ComposeCreate commits and reveals the entry like factom.Entry.ComposeCreate,
+// the commit is signed by the signer. Only entries of existing chains are
+// supported.
+func (k *ECKey) ComposeCreate(ctx context.Context, c *factom.Client, e *factom.Entry) (factom.Bytes32, error) {
+	commit, reveal, txID, err := k.Compose(ctx, e)
+	if err != nil {
+		return factom.Bytes32{}, err
+	}
+	if err := c.Commit(ctx, commit); err != nil {
+		return txID, fmt.Errorf("factom.Client.Commit(): %w", err)
+	}
+	if err := c.Reveal(ctx, reveal); err != nil {
+		return txID, fmt.Errorf("factom.Client.Reveal(): %w", err)
+	}
+	return txID, nil
+}
+
+// Compose returns the commit and the reveal of the entry like
+// factom.Entry.Compose, in the layout of factom.GenerateCommit:
+//
+//	[Version (0x00)] + [Timestamp in ms (6 bytes BE)] + [Entry Hash] +
+//	[EC Cost (1 byte)] + [EC Public Key (32 bytes)] + [Signature (64 bytes)]
+func (k *ECKey) Compose(ctx context.Context, e *factom.Entry) (commit, reveal []byte, txID factom.Bytes32, err error) {
+	if e.ChainID == nil {
+		err = fmt.Errorf("signer: new chains are not supported")
+		return
+	}
+	reveal, err = e.MarshalBinary()
+	if err != nil {
+		err = fmt.Errorf("factom.Entry.MarshalBinary(): %w", err)
+		return
+	}
+	if e.Hash == nil {
+		e.Hash = new(factom.Bytes32)
+		*e.Hash = factom.ComputeEntryHash(reveal)
+	}
+	cost, err := factom.EntryCost(len(reveal), false)
+	if err != nil {
+		return
+	}
+
+	payload := make([]byte, 1+6+32+1)
+	ms := time.Now().Unix()*1e3 + rand.Int63n(1000)
+	for i := 0; i < 6; i++ {
+		payload[1+i] = byte(ms >> (8 * (5 - i)))
+	}
+	copy(payload[7:], e.Hash[:])
+	payload[39] = cost
+	txID = sha256.Sum256(payload)
+
+	sig, err := k.sign(ctx, payload)
+	if err != nil {
+		return
+	}
+	commit = append(append(payload, k.publicKey...), sig...)
+	return
+}
+
+// RCDSigner signs the transactions of an FA address with a key outside of
+// the node. It implements factom.RCDSigner, which can't fail, so a failed
+// signature is kept in Err and the entry must not be used.
+type RCDSigner struct {
+	ctx  context.Context
+	rcd  []byte
+	sign SignFunc
+	err  error
+}
+
+// NewRCDSigner returns the signer of the RCD type 1 of the public key, it
+// signs with the func
+func NewRCDSigner(ctx context.Context, publicKey ed25519.PublicKey, sign SignFunc) *RCDSigner {
+	return &RCDSigner{ctx: ctx, rcd: append([]byte{factom.RCDType01}, publicKey...), sign: verified(publicKey, sign)}
+}
+
+// RCDSigner returns the signer of the FA address, an error if the signer does
+// not have its key
+func (c *Client) RCDSigner(ctx context.Context, fa factom.FAAddress) (*RCDSigner, error) {
+	k, err := c.Key(ctx, fa.String())
+	if err != nil {
+		return nil, err
+	}
+	rcd := append([]byte{factom.RCDType01}, k.PublicKey...)
+	if !bytes.Equal(k.RCD, rcd) || factom.FAAddress(sha256d(rcd)) != fa {
+		return nil, fmt.Errorf("signer: the rcd of %s does not match", fa)
+	}
+	return NewRCDSigner(ctx, ed25519.PublicKey(k.PublicKey), func(ctx context.Context, payload []byte) ([]byte, error) {
+		return c.Sign(ctx, fa.String(), KindFAT103, payload, k.PublicKey)
+	}), nil
+}
+
+// FAAddress is the address of the RCD
+func (s *RCDSigner) FAAddress() factom.FAAddress {
+	return sha256d(s.rcd)
+}
+
+// RCD is the redeem condition of the address
+func (s *RCDSigner) RCD() []byte {
+	return s.rcd
+}
+
+// Sign returns the signature of the FAT-103 message hash, nil if it failed
+func (s *RCDSigner) Sign(msg []byte) []byte {
+	if s.err != nil {
+		return nil
+	}
+	sig, err := s.sign(s.ctx, msg)
+	if err != nil {
+		s.err = err
+		return nil
+	}
+	return sig
+}
+
+// Err is the error of the first failed signature
+func (s *RCDSigner) Err() error {
+	return s.err
+}
+
+// verified checks the signatures of the func against the public key
+func verified(publicKey ed25519.PublicKey, sign SignFunc) SignFunc {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		sig, err := sign(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, payload, sig) {
+			return nil, fmt.Errorf("invalid signature of the key %x", []byte(publicKey))
+		}
+		return sig, nil
+	}
+}
+
+func bytesTo32(b []byte) (out [32]byte) {
+	copy(out[:], b)
+	return
+}
+
+func sha256d(data []byte) [32]byte {
+	first := sha256.Sum256(data)
+	return sha256.Sum256(first[:])
+}
@@ -0,0 +1,183 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	log "github.com/sirupsen/logrus"
+)
+
+// Server is the signer daemon, it signs with the EC and FA keys it holds.
+// Only the canonical payloads of the kinds are signed, anything else is
+// rejected.
+type Server struct {
+	Token string
+
+	mu    sync.RWMutex
+	signs map[string]func(payload []byte) ([]byte, error)
+	keys  []Key
+}
+
+// NewServer returns a signer without keys, the token is required on every
+// request
+func NewServer(token string) *Server {
+	return &Server{
+		Token: token,
+		signs: make(map[string]func([]byte) ([]byte, error)),
+	}
+}
+
+// AddECKey adds an EC key to the signer
+func (s *Server) AddECKey(es factom.EsAddress) {
+	priv := ed25519.PrivateKey(es.PrivateKey())
+	s.AddKey(KindECCommit, ed25519.PublicKey(es.PublicKey()), func(payload []byte) ([]byte, error) {
+		return ed25519.Sign(priv, payload), nil
+	})
+}
+
+// AddFAKey adds an FA key to the signer
+func (s *Server) AddFAKey(fs factom.FsAddress) {
+	priv := ed25519.PrivateKey(fs.PrivateKey())
+	s.AddKey(KindFAT103, ed25519.PublicKey(fs.PublicKey()), func(payload []byte) ([]byte, error) {
+		return ed25519.Sign(priv, payload), nil
+	})
+}
+
+// AddKey adds a key that signs the payloads of the kind with the func, like a
+// key of a hardware token. The address is the EC address of the public key
+// for KindECCommit, and the FA address of its RCD for KindFAT103.
+func (s *Server) AddKey(kind string, publicKey ed25519.PublicKey, sign func(payload []byte) ([]byte, error)) {
+	k := Key{PublicKey: factom.Bytes(publicKey)}
+	switch kind {
+	case KindECCommit:
+		k.Address = factom.ECAddress(bytesTo32(publicKey)).String()
+	case KindFAT103:
+		k.RCD = append([]byte{factom.RCDType01}, publicKey...)
+		k.Address = factom.FAAddress(sha256d(k.RCD)).String()
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := kind + " " + k.Address
+	if _, ok := s.signs[id]; ok {
+		return
+	}
+	s.signs[id] = sign
+	s.keys = append(s.keys, k)
+	sort.Slice(s.keys, func(i, j int) bool { return s.keys[i].Address < s.keys[j].Address })
+}
+
+// Keys returns the public keys the signer holds
+func (s *Server) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Key{}, s.keys...)
+}
+
+// CheckPayload checks that the payload is the canonical data of the kind:
+// an entry commit up to the EC cost, or the sha512 of a FAT-103 message
+func CheckPayload(kind string, payload []byte) error {
+	switch kind {
+	case KindECCommit:
+		// version + timestamp + [chain id hash + weld] + entry hash + cost
+		if len(payload) != 1+6+32+1 && len(payload) != 1+6+32+32+32+1 {
+			return fmt.Errorf("an ec-commit payload is 40 or 104 bytes, not %d", len(payload))
+		}
+		if payload[0] != 0 {
+			return fmt.Errorf("unknown commit version %d", payload[0])
+		}
+		if cost := payload[len(payload)-1]; cost == 0 || cost > 20 {
+			return fmt.Errorf("invalid entry cost %d", cost)
+		}
+	case KindFAT103:
+		if len(payload) != 64 {
+			return fmt.Errorf("a fat103 payload is 64 bytes, not %d", len(payload))
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	return nil
+}
+
+// Handler serves the api of the signer
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(KeysPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Keys())
+	})
+	mux.HandleFunc(SignPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var req SignRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		res, status, err := s.sign(req)
+		if err != nil {
+			log.WithFields(log.Fields{"address": req.Address, "kind": req.Kind}).WithError(err).Warn("signer: refused to sign")
+			writeError(w, status, err.Error())
+			return
+		}
+		log.WithFields(log.Fields{"address": req.Address, "kind": req.Kind}).Info("signer: signed")
+		writeJSON(w, http.StatusOK, res)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) sign(req SignRequest) (SignResponse, int, error) {
+	if err := CheckPayload(req.Kind, req.Payload); err != nil {
+		return SignResponse{}, http.StatusBadRequest, err
+	}
+
+	s.mu.RLock()
+	sign, ok := s.signs[req.Kind+" "+req.Address]
+	var pub factom.Bytes
+	for _, k := range s.keys {
+		if k.Address == req.Address {
+			pub = k.PublicKey
+		}
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return SignResponse{}, http.StatusNotFound, fmt.Errorf("no %s key for %s", req.Kind, req.Address)
+	}
+	sig, err := sign(req.Payload)
+	if err != nil {
+		return SignResponse{}, http.StatusInternalServerError, err
+	}
+	return SignResponse{Signature: sig, PublicKey: pub}, http.StatusOK, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
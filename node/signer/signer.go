@@ -0,0 +1,179 @@
+// Package signer delegates the signatures of the node to a signer daemon, so
+// the private keys can stay off the host that serves the api. The daemon is
+// an HTTP api on a unix socket or a local port, every request carries the
+// shared token as "Authorization: Bearer <token>".
+//
+//	GET  /v1/keys  the keys of the signer: [{"address", "publickey", "rcd"}]
+//	POST /v1/sign  {"address", "kind", "payload"} -> {"signature", "publickey"}
+//
+// The payload is the canonical data of the signature, hex encoded:
+//
+//	"ec-commit": the commit of an entry up to and including the EC cost,
+//	             signed with ed25519 by the EC key
+//	"fat103":    the sha512 of the salted FAT-103 message of a transaction
+//	             batch, signed by the key of the FA address
+//
+// The node checks every signature against the public key before it is used.
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// The kinds of payloads
+const (
+	KindECCommit = "ec-commit"
+	KindFAT103   = "fat103"
+)
+
+// The paths of the api
+const (
+	KeysPath = "/v1/keys"
+	SignPath = "/v1/sign"
+)
+
+// Key is a key of the signer, the address is an EC or an FA address
+type Key struct {
+	Address   string       `json:"address"`
+	PublicKey factom.Bytes `json:"publickey"`
+	RCD       factom.Bytes `json:"rcd,omitempty"`
+}
+
+// SignRequest asks for the signature of the payload by the key of the address
+type SignRequest struct {
+	Address string       `json:"address"`
+	Kind    string       `json:"kind"`
+	Payload factom.Bytes `json:"payload"`
+}
+
+// SignResponse is the signature of a SignRequest
+type SignResponse struct {
+	Signature factom.Bytes `json:"signature"`
+	PublicKey factom.Bytes `json:"publickey"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Client talks to a signer daemon
+type Client struct {
+	Token string
+
+	base string
+	http *http.Client
+}
+
+// NewClient returns a client of the signer at the url, either
+// "unix:///path/to/socket" or an http(s) url
+func NewClient(rawurl, token string) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{Token: token, http: &http.Client{Timeout: 10 * time.Second}}
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		c.base = "http://signer"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+	case "http", "https":
+		c.base = strings.TrimSuffix(u.String(), "/")
+	default:
+		return nil, fmt.Errorf("unsupported signer url %q, expected unix:// or http(s)://", rawurl)
+	}
+	return c, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("signer: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("signer: %s", e.Error)
+		}
+		return fmt.Errorf("signer: %s", resp.Status)
+	}
+	return json.Unmarshal(data, res)
+}
+
+// Keys returns the keys of the signer
+func (c *Client) Keys(ctx context.Context) ([]Key, error) {
+	var keys []Key
+	err := c.do(ctx, http.MethodGet, KeysPath, nil, &keys)
+	return keys, err
+}
+
+// Key returns the key of the address, an error if the signer does not have it
+func (c *Client) Key(ctx context.Context, address string) (Key, error) {
+	keys, err := c.Keys(ctx)
+	if err != nil {
+		return Key{}, err
+	}
+	for _, k := range keys {
+		if k.Address == address {
+			return k, nil
+		}
+	}
+	return Key{}, fmt.Errorf("signer: no key for %s", address)
+}
+
+// Sign returns the signature of the payload by the key of the address. The
+// signature is checked against the public key, if it is known.
+func (c *Client) Sign(ctx context.Context, address, kind string, payload []byte, publicKey []byte) ([]byte, error) {
+	var res SignResponse
+	if err := c.do(ctx, http.MethodPost, SignPath, SignRequest{Address: address, Kind: kind, Payload: payload}, &res); err != nil {
+		return nil, err
+	}
+	if publicKey == nil {
+		publicKey = res.PublicKey
+	}
+	if len(publicKey) != ed25519.PublicKeySize || !bytes.Equal(publicKey, res.PublicKey) {
+		return nil, fmt.Errorf("signer: the public key of %s does not match", address)
+	}
+	if !ed25519.Verify(publicKey, payload, res.Signature) {
+		return nil, fmt.Errorf("signer: invalid signature by %s", address)
+	}
+	return res.Signature, nil
+}
@@ -0,0 +1,122 @@
+package signer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testServer(t *testing.T) (*Server, factom.EsAddress, factom.FsAddress) {
+	es, err := factom.NewEsAddress("Es3eGA7ZYkS2TezUH6q1T7vNUWpihUYDLKaCHDYNanX2hDZCFQqp")
+	require.NoError(t, err)
+	fs, err := factom.NewFsAddress("Fs3E9gV6DXsYzf7Fqx1fVBQPQXV695eP3k5XbmHEZVRLkMdD9qCK")
+	require.NoError(t, err)
+	s := NewServer("secret")
+	s.AddECKey(es)
+	s.AddFAKey(fs)
+	return s, es, fs
+}
+
+func TestSigner(t *testing.T) {
+	s, es, fs := testServer(t)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, err := NewClient(ts.URL, "secret")
+	require.NoError(t, err)
+	keys, err := c.Keys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	ec, err := c.ECKey(ctx, es.ECAddress())
+	require.NoError(t, err)
+	assert.Equal(t, es.ECAddress(), ec.ECAddress())
+
+	chain := factom.Bytes32{1}
+	e := factom.Entry{ChainID: &chain, Content: factom.Bytes("hello")}
+	commit, reveal, txID, err := ec.Compose(ctx, &e)
+	require.NoError(t, err)
+	assert.Len(t, commit, 1+6+32+1+32+64)
+	assert.Equal(t, factom.ComputeEntryHash(reveal), *e.Hash)
+	assert.Equal(t, factom.Bytes32(sha256.Sum256(commit[:40])), txID)
+	assert.Equal(t, []byte(es.PublicKey()), commit[40:72])
+	assert.True(t, ed25519.Verify(es.PublicKey(), commit[:40], commit[72:]))
+
+	rcd, err := c.RCDSigner(ctx, fs.FAAddress())
+	require.NoError(t, err)
+	var batch fat2.TransactionBatch
+	batch.Entry.ChainID = &chain
+	require.NoError(t, batch.UnmarshalJSON([]byte(`{"version": 1, "transactions": [{
+		"input": {"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "type": "PEG", "amount": 50},
+		"transfers": [{"address": "FA1zT4aFpEvcnPqPCigB3fvGu4Q4mTXY22iiuV69DqE1pNhdF2MC", "amount": 50}]
+	}]}`)))
+	signed, err := batch.Sign(rcd)
+	require.NoError(t, err)
+	require.NoError(t, rcd.Err())
+	_, err = fat2.NewTransactionBatch(signed, -1)
+	assert.NoError(t, err)
+}
+
+func TestSignerRefuses(t *testing.T) {
+	s, es, fs := testServer(t)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, err := NewClient(ts.URL, "wrong")
+	require.NoError(t, err)
+	_, err = c.Keys(ctx)
+	assert.EqualError(t, err, "signer: invalid token")
+
+	c.Token = "secret"
+	valid := append([]byte{0}, make([]byte, 39)...)
+	valid[39] = 1
+	_, err = c.Sign(ctx, es.ECAddress().String(), KindECCommit, valid[:20], nil)
+	assert.EqualError(t, err, "signer: an ec-commit payload is 40 or 104 bytes, not 20")
+	_, err = c.Sign(ctx, es.ECAddress().String(), KindFAT103, make([]byte, 64), nil)
+	assert.Contains(t, err.Error(), "no fat103 key")
+	_, err = c.Sign(ctx, fs.FAAddress().String(), "tx", make([]byte, 64), nil)
+	assert.EqualError(t, err, `signer: unknown kind "tx"`)
+	_, err = c.Sign(ctx, es.ECAddress().String(), KindECCommit, valid, es.PublicKey())
+	assert.NoError(t, err)
+
+	// A signer that answers with another key is caught by the client
+	_, err = c.Sign(ctx, es.ECAddress().String(), KindECCommit, valid, fs.PublicKey())
+	assert.EqualError(t, err, "signer: the public key of "+es.ECAddress().String()+" does not match")
+	_, err = c.ECKey(ctx, factom.ECAddress{1})
+	assert.Error(t, err)
+}
+
+func TestSignerUnixSocket(t *testing.T) {
+	s, es, _ := testServer(t)
+	dir, err := ioutil.TempDir("", "pegnetd-signer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: s.Handler()}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c, err := NewClient("unix://"+path, "secret")
+	require.NoError(t, err)
+	_, err = c.ECKey(context.Background(), es.ECAddress())
+	assert.NoError(t, err)
+
+	_, err = NewClient("ftp://signer", "secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package node
+
+import (
+	"database/sql"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// pendingState is the validator.State of the block being synced
+type pendingState struct {
+	Pegnet *pegnet.Pegnet
+	Tx     *sql.Tx
+}
+
+func (s pendingState) Balances(addr factom.FAAddress) (map[fat2.PTicker]uint64, error) {
+	return s.Pegnet.SelectPendingBalances(s.Tx, &addr)
+}
+
+func (s pendingState) IsReplay(entryHash *factom.Bytes32) (bool, error) {
+	return s.Pegnet.IsReplayTransaction(s.Tx, entryHash)
+}
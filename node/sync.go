@@ -10,9 +10,13 @@ import (
 	"github.com/pegnet/pegnet/modules/conversions"
 	"github.com/pegnet/pegnet/modules/grader"
 	"github.com/pegnet/pegnet/modules/transactionid"
+	"github.com/pegnet/pegnetd/activation"
 	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/fat/fat2/validator"
+	"github.com/pegnet/pegnetd/node/events"
 	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -44,7 +48,7 @@ OuterSyncLoop:
 			// We are currently synced, nothing to do. If we are above it, the factomd could
 			// be rebooted
 			if d.Sync.Synced > heights.DirectoryBlock {
-				log.Debugf("Factom node behind. database height = %d, factom height = %d", d.Sync.Synced, heights.DirectoryBlock)
+				log.WithFields(log.Fields{"height": d.Sync.Synced, "factom-height": heights.DirectoryBlock}).Debug("Factom node behind")
 			}
 
 			if isFirstSync {
@@ -68,29 +72,51 @@ OuterSyncLoop:
 			longSync = true
 		}
 
+		// Fetch and grade the blocks ahead while the previous ones are applied
+		pipelineCtx, cancelPipeline := context.WithCancel(ctx)
+		blocks := d.syncPipeline(pipelineCtx, d.Sync.Synced+1, heights.DirectoryBlock, d.Config.GetInt(config.DBlockSyncPrefetch))
+		var block *syncedBlock
+
 		begin := time.Now()
 		lastReport := begin
 		for d.Sync.Synced < heights.DirectoryBlock {
 			start := time.Now()
 			hLog := log.WithFields(log.Fields{"height": d.Sync.Synced + 1})
 			if isDone(ctx) {
+				cancelPipeline()
 				return
 			}
 
+			// We are not synced, so we need to iterate through the dblocks and sync them
+			// one by one. We can only sync our current synced height +1
+			// TODO: This skips the genesis block. I'm sure that is fine
+			if block == nil {
+				res, ok := <-blocks
+				if !ok {
+					cancelPipeline()
+					return // Only closed early if the context is done
+				}
+				if res.err != nil {
+					hLog.WithError(res.err).Errorf("failed to sync height")
+					time.Sleep(retryPeriod)
+					cancelPipeline()
+					continue OuterSyncLoop
+				}
+				block = res.block
+			}
+
 			// start transaction for all block actions
 			tx, err := d.Pegnet.DB.BeginTx(ctx, nil)
 			if err != nil {
 				hLog.WithError(err).Errorf("failed to start transaction")
 				continue
 			}
-			// We are not synced, so we need to iterate through the dblocks and sync them
-			// one by one. We can only sync our current synced height +1
-			// TODO: This skips the genesis block. I'm sure that is fine
-			if err := d.SyncBlock(ctx, tx, d.Sync.Synced+1); err != nil {
+			if err := d.applyBlock(ctx, tx, block); err != nil {
 				hLog.WithError(err).Errorf("failed to sync height")
 				time.Sleep(retryPeriod)
 				// If we fail, we backout to the outer loop. This allows error handling on factomd state to be a bit
 				// cleaner, such as a rebooted node with a different db. That node would have a new heights response.
+				cancelPipeline()
 				err = tx.Rollback()
 				if err != nil {
 					// TODO evaluate if we can recover from this point or not
@@ -106,6 +132,7 @@ OuterSyncLoop:
 			if err != nil {
 				d.Sync.Synced--
 				hLog.WithError(err).Errorf("unable to update synced metadata")
+				cancelPipeline()
 				err = tx.Rollback()
 				if err != nil {
 					// TODO evaluate if we can recover from this point or not
@@ -114,6 +141,7 @@ OuterSyncLoop:
 				continue OuterSyncLoop
 			}
 
+			d.chaosCrash(d.Sync.Synced)
 			err = tx.Commit()
 			if err != nil {
 				d.Sync.Synced--
@@ -123,10 +151,17 @@ OuterSyncLoop:
 					// TODO evaluate if we can recover from this point or not
 					hLog.WithError(err).Fatal("unable to roll back transaction")
 				}
+			} else {
+				// The block is retried if the commit failed
+				block = nil
+				if d.blockEvents != nil {
+					d.Events.Publish(d.blockEvents)
+				}
 			}
+			d.blockEvents = nil
 
 			elapsed := time.Since(start)
-			hLog.WithFields(log.Fields{"took": elapsed}).Debugf("synced")
+			hLog.WithFields(log.Fields{"duration": elapsed}).Debugf("synced")
 
 			iterations++
 			totalDur += elapsed
@@ -143,6 +178,7 @@ OuterSyncLoop:
 				}).Infof("sync stats")
 			}
 		}
+		cancelPipeline()
 
 		isFirstSync = false
 		if longSync {
@@ -159,41 +195,81 @@ OuterSyncLoop:
 // the whole sync should be rolled back and not applied. An error should then be returned.
 // The context should be respected if it is cancelled
 func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) error {
-	fLog := log.WithFields(log.Fields{"height": height})
-	if isDone(ctx) { // Just an example about how to handle it being cancelled
-		return context.Canceled
+	// First, gather all entries we need from factomd
+	block, err := d.fetchBlock(ctx, height)
+	if err != nil {
+		return err
 	}
 
-	dblock := new(factom.DBlock)
-	dblock.Height = height
-	if err := dblock.Get(nil, d.FactomClient); err != nil {
+	// Then, grade the new OPR Block. The results of this will be used
+	// to execute conversions that are in holding.
+	block.Graded, block.Submissions, err = d.Grade(ctx, block.OPREBlock)
+	if err != nil {
 		return err
 	}
+	return d.applyBlock(ctx, tx, block)
+}
 
-	// First, gather all entries we need from factomd
-	oprEBlock := dblock.EBlock(OPRChain)
-	if oprEBlock != nil {
-		if err := multiFetch(oprEBlock, d.FactomClient); err != nil {
-			return err
-		}
+// SyncNextBlock syncs the height after the synced height with SyncBlock, and
+// commits it along with the new synced height. Unlike DBlockSync, nothing is
+// fetched ahead and a failed height is returned instead of retried.
+func (d *Pegnetd) SyncNextBlock(ctx context.Context) error {
+	tx, err := d.Pegnet.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
-	transactionsEBlock := dblock.EBlock(TransactionChain)
-	if transactionsEBlock != nil {
-		if err := multiFetch(transactionsEBlock, d.FactomClient); err != nil {
-			return err
-		}
+	if err := d.SyncBlock(ctx, tx, d.Sync.Synced+1); err != nil {
+		d.blockEvents = nil
+		_ = tx.Rollback()
+		return err
 	}
 
-	// Then, grade the new OPR Block. The results of this will be used
-	// to execute conversions that are in holding.
-	gradedBlock, err := d.Grade(ctx, oprEBlock)
-	if err != nil {
+	d.Sync.Synced++
+	if err := d.Pegnet.InsertSynced(tx, d.Sync); err != nil {
+		d.Sync.Synced--
+		d.blockEvents = nil
+		_ = tx.Rollback()
 		return err
-	} else if gradedBlock != nil {
-		err = d.Pegnet.InsertGradeBlock(tx, oprEBlock, gradedBlock)
+	}
+	d.chaosCrash(d.Sync.Synced)
+	if err := tx.Commit(); err != nil {
+		d.Sync.Synced--
+		d.blockEvents = nil
+		return err
+	}
+	if d.blockEvents != nil {
+		d.Events.Publish(d.blockEvents)
+	}
+	d.blockEvents = nil
+	return nil
+}
+
+// applyBlock saves a fetched and graded block. If an error is returned, the
+// sql.Tx should be rolled back by the caller.
+func (d *Pegnetd) applyBlock(ctx context.Context, tx *sql.Tx, block *syncedBlock) (err error) {
+	fLog := log.WithFields(log.Fields{"height": block.Height})
+	if isDone(ctx) {
+		return context.Canceled
+	}
+	ctx, span := tracing.Start(ctx, "sync.apply", tracing.KindInternal)
+	span.SetAttribute("height", block.Height)
+	start := time.Now()
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+		d.checkSlowBlock("apply", block, time.Since(start))
+	}()
+
+	height, dblock := block.Height, block.DBlock
+	oprEBlock, transactionsEBlock, gradedBlock := block.OPREBlock, block.TransactionsEBlock, block.Graded
+	if gradedBlock != nil {
+		err := d.Pegnet.InsertGradeBlock(tx, oprEBlock, gradedBlock)
 		if err != nil {
 			return err
 		}
+		if err := d.Pegnet.InsertMinerBlocks(tx, height, block.Submissions, gradedBlock); err != nil {
+			return err
+		}
 		winners := gradedBlock.Winners()
 		if 0 < len(winners) {
 			// PEG has 3 current pricing phases
@@ -201,13 +277,13 @@ func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) erro
 			// 2: Price is determined by equation
 			// 3: Price is determine by miners
 			var phase pegnet.PEGPricingPhase
-			if height < PEGPricingActivation {
+			if !activation.Active(activation.PEGPricing, height) {
 				phase = pegnet.PEGPriceIsZero
 			}
-			if height >= PEGPricingActivation {
+			if activation.Active(activation.PEGPricing, height) {
 				phase = pegnet.PEGPriceIsEquation
 			}
-			if height >= PEGFreeFloatingPriceActivation {
+			if activation.Active(activation.PEGFreeFloatingPrice, height) {
 				phase = pegnet.PEGPriceIsFloating
 			}
 
@@ -222,8 +298,17 @@ func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) erro
 		fLog.WithFields(log.Fields{"section": "grading", "reason": "no graded block"}).Tracef("block not graded")
 	}
 
+	// Rates injected for development replace the graded rates
+	override := d.rateOverride(height)
+	if override != nil {
+		if err := d.Pegnet.InjectRates(tx, height, override); err != nil {
+			return err
+		}
+		fLog.WithFields(log.Fields{"section": "grading", "assets": len(override)}).Info("rates injected")
+	}
+
 	// Only apply transactions if we crossed the activation
-	if height >= TransactionConversionActivation {
+	if activation.Active(activation.TransactionConversion, height) {
 		rates, err := d.Pegnet.SelectPendingRates(ctx, tx, height)
 		if err != nil {
 			return err
@@ -232,7 +317,7 @@ func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) erro
 		// At this point, we start making updates to the database in a specific order:
 		// TODO: ensure we rollback the tx when needed
 		// 1) Apply transaction batches that are in holding (conversions are always applied here)
-		if gradedBlock != nil && 0 < len(gradedBlock.Winners()) {
+		if (gradedBlock != nil && 0 < len(gradedBlock.Winners())) || override != nil {
 			// Before conversions can be run, we have to adjust and discover the bank's value.
 			// We also only sync the bank if the block is a pegnet block
 			if err := d.SyncBank(ctx, tx, height); err != nil {
@@ -255,7 +340,7 @@ func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) erro
 	// 3) Apply FCT --> pFCT burns that happened in this block
 	//    These funds will be available for transactions and conversions executed in the next block
 	// TODO: Check the order of operations on this and what block to add burns from.
-	if err := d.ApplyFactoidBlock(ctx, tx, dblock); err != nil {
+	if err := d.ApplyFactoidBlock(ctx, tx, block.FBlock); err != nil {
 		return err
 	}
 
@@ -266,11 +351,99 @@ func (d *Pegnetd) SyncBlock(ctx context.Context, tx *sql.Tx, height uint32) erro
 			return err
 		}
 	}
+
+	// 5) Record the supply of all assets after all balance changes are applied
+	if interval := d.Config.GetUint32(config.SupplyHistoryInterval); interval > 0 && height%interval == 0 {
+		if err := d.Pegnet.InsertSupplyHistory(tx, height); err != nil {
+			return err
+		}
+	}
+
+	// 6) Add everything executed at this height to the statistics
+	if err := d.Pegnet.InsertNetworkStats(tx, height, dblock.Timestamp); err != nil {
+		return err
+	}
+	if err := d.Pegnet.InsertConversionVolume(tx, height); err != nil {
+		return err
+	}
+	if err := d.Pegnet.InsertBlock(tx, height, dblock.Timestamp); err != nil {
+		return err
+	}
+	if err := d.Pegnet.InsertSupplyTotals(tx, height); err != nil {
+		return err
+	}
+
+	// 7) Assign this height to all balance changes made above
+	if err := d.Pegnet.FinalizeBalanceJournal(tx, height); err != nil {
+		return err
+	}
+	if err := d.Pegnet.InsertBlockStateHash(tx, height, height == activation.Height(activation.Pegnet)+1); err != nil {
+		return err
+	}
+
+	// 8) Record the deposits to watched addresses and confirm pending ones
+	if err := d.Pegnet.InsertDeposits(tx, height); err != nil {
+		return err
+	}
+
+	// 9) Evaluate the alert rules against everything applied at this height
+	if err := d.Pegnet.InsertAlerts(tx, height, d.AlertRules); err != nil {
+		return err
+	}
+
+	// 10) Collect the events of this height, they are published once the
+	// block is committed
+	if d.Events != nil {
+		if err := d.collectBlockEvents(tx, height, dblock.Timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectBlockEvents gathers everything that was applied at the height for
+// the event sinks
+func (d *Pegnetd) collectBlockEvents(tx *sql.Tx, height uint32, timestamp time.Time) error {
+	actions, err := d.Pegnet.SelectTransactionHistoryActionsExecuted(tx, height)
+	if err != nil {
+		return err
+	}
+	rates, err := d.Pegnet.SelectPendingRates(nil, tx, height)
+	if err != nil {
+		return err
+	}
+	deposits, err := d.Pegnet.SelectDepositsConfirmed(tx, height)
+	if err != nil {
+		return err
+	}
+	alerts, err := d.Pegnet.SelectAlertsAt(tx, height)
+	if err != nil {
+		return err
+	}
+	malformed, err := d.Pegnet.SelectMalformedBurnsAt(tx, height)
+	if err != nil {
+		return err
+	}
+
+	evts := &events.BlockEvents{
+		Block:          events.Block{Height: height, Timestamp: timestamp, Actions: len(actions)},
+		Actions:        actions,
+		Deposits:       deposits,
+		Alerts:         alerts,
+		MalformedBurns: malformed,
+	}
+	if len(rates) > 0 {
+		evts.Block.Rates = make(map[string]uint64, len(rates))
+		for ticker, rate := range rates {
+			evts.Block.Rates[ticker.String()] = rate
+		}
+	}
+	d.blockEvents = evts
 	return nil
 }
 
-func multiFetch(eblock *factom.EBlock, c *factom.Client) error {
-	err := eblock.Get(nil, c)
+func multiFetch(ctx context.Context, eblock *factom.EBlock, c *factom.Client) error {
+	err := eblock.Get(ctx, c)
 	if err != nil {
 		return err
 	}
@@ -289,7 +462,7 @@ func multiFetch(eblock *factom.EBlock, c *factom.Client) error {
 			}()
 
 			for j := range work {
-				errs <- eblock.Entries[j].Get(nil, c)
+				errs <- eblock.Entries[j].Get(ctx, c)
 			}
 		}()
 	}
@@ -314,11 +487,11 @@ func multiFetch(eblock *factom.EBlock, c *factom.Client) error {
 	return nil
 }
 
-// SyncBank will input the bank value for all heights >= V4OPRUpdate
+// SyncBank will input the bank value for all heights of the V4 update
 // The bank table helps track the demand for peg at a given height.
 // The bank is the total amount of PEG allowed to be issued for any given height.
 func (d *Pegnetd) SyncBank(ctx context.Context, sqlTx *sql.Tx, currentHeight uint32) error {
-	if currentHeight >= V4OPRUpdate { // V4 forward tracks this
+	if activation.Active(activation.V4OPRUpdate, currentHeight) { // V4 forward tracks this
 		err := d.Pegnet.InsertBankAmount(sqlTx, int32(currentHeight), int64(pegnet.BankBaseAmount))
 		if err != nil {
 			return err
@@ -353,32 +526,40 @@ func (d *Pegnetd) ApplyTransactionBatchesInHolding(ctx context.Context, sqlTx *s
 		for i, txBatch := range txBatches {
 			// Re-validate transaction batch because timestamp might not be valid anymore
 			if err := txBatch.Validate(int32(currentHeight)); err != nil {
-				d.Pegnet.SetTransactionHistoryExecuted(sqlTx, txBatch, -2)
+				d.Pegnet.SetTransactionHistoryRejected(sqlTx, txBatch, currentHeight, pegnet.InvalidTransactionErrInt, err)
 				continue
 			}
 			isReplay, err := d.Pegnet.IsReplayTransaction(sqlTx, txBatch.Entry.Hash)
 			if err != nil {
 				return err
 			} else if isReplay {
+				if err := d.Pegnet.InsertTransactionRejection(sqlTx, txBatch.Entry.Hash, currentHeight, pegnet.ReplayErrorInt, pegnet.ReplayError.Error()); err != nil {
+					return err
+				}
 				continue
 			}
 
 			// This will apply all batche inputs, and all batch outputs except
 			// conversions to PEG if we are above the PegnetConversionLimit Act
-			err = d.applyTransactionBatch(sqlTx, txBatch, rates, currentHeight)
+			txErr := d.applyTransactionBatch(sqlTx, txBatch, rates, currentHeight)
 			// The err needs to be converted to a code. If the err is still
 			// not nil, then the code is 0 and the error is probably db related.
 			// If the code is < 0, the tx is rejected.
 			// If the code is > 0 and the err is nil, the tx is accepted.
-			rejectCode, err := pegnet.IsRejectedTx(err)
+			rejectCode, err := pegnet.IsRejectedTx(txErr)
 			if err != nil { // Likely a db error
 				return err
 			} else if rejectCode < 0 { // Tx rejected
-				d.Pegnet.SetTransactionHistoryExecuted(sqlTx, txBatch, rejectCode)
+				d.Pegnet.SetTransactionHistoryRejected(sqlTx, txBatch, currentHeight, rejectCode, txErr)
+				if rejectCode == pegnet.ZeroRatesErrorInt {
+					if err := d.Pegnet.InsertTransactionHistoryRejectedRefunds(sqlTx, txBatch, currentHeight, pegnet.RefundReasonMissingRate); err != nil {
+						return err
+					}
+				}
 			} else if err == nil { // Tx accepted
 				// If PegnetConversion limits are on, we process conversions to
 				// peg in a second pass.
-				if currentHeight >= PegnetConversionLimitActivation && txBatch.HasPEGRequest() {
+				if activation.Active(activation.ConversionLimit, currentHeight) && txBatch.HasPEGRequest() {
 					// Batch applied, we need to do the PEG conversions at the end
 					pegConversions = append(pegConversions, txBatches[i])
 				}
@@ -393,7 +574,7 @@ func (d *Pegnetd) ApplyTransactionBatchesInHolding(ctx context.Context, sqlTx *s
 		// This is processing each height of conversions as its own block
 		// of conversions. After the v4 update, all pending conversions get
 		// processed together for peg conversions
-		if currentHeight >= PegnetConversionLimitActivation && currentHeight < V4OPRUpdate {
+		if activation.Active(activation.ConversionLimit, currentHeight) && !activation.Active(activation.V4OPRUpdate, currentHeight) {
 			// All heights before v4 use the currentHeight-1 with a 5K PEG bank
 			bank := pegnet.BankBaseAmount
 			err = d.recordPegnetRequests(sqlTx, pegConversions, rates, currentHeight, bank, int32(currentHeight-1))
@@ -405,7 +586,7 @@ func (d *Pegnetd) ApplyTransactionBatchesInHolding(ctx context.Context, sqlTx *s
 	}
 
 	// Process all pending using the same bank
-	if currentHeight >= V4OPRUpdate {
+	if activation.Active(activation.V4OPRUpdate, currentHeight) {
 		// The bank entry should be here from the sync banks called before this function.
 		bentry, err := d.Pegnet.SelectBankEntry(sqlTx, int32(currentHeight))
 		if err != nil {
@@ -426,7 +607,11 @@ func (d *Pegnetd) ApplyTransactionBlock(sqlTx *sql.Tx, eblock *factom.EBlock) er
 	for blockorder, entry := range eblock.Entries {
 		txBatch, err := fat2.NewTransactionBatch(entry, int32(eblock.Height))
 		if err != nil {
-			continue // Bad formatted entry
+			// Bad formatted entry, record why so the user can find out
+			if err := d.Pegnet.InsertTransactionRejection(sqlTx, entry.Hash, eblock.Height, pegnet.InvalidTransactionErrInt, err.Error()); err != nil {
+				return err
+			}
+			continue
 		}
 
 		log.WithFields(log.Fields{
@@ -439,6 +624,9 @@ func (d *Pegnetd) ApplyTransactionBlock(sqlTx *sql.Tx, eblock *factom.EBlock) er
 		if err != nil {
 			return err
 		} else if isReplay {
+			if err := d.Pegnet.InsertTransactionRejection(sqlTx, txBatch.Entry.Hash, eblock.Height, pegnet.ReplayErrorInt, pegnet.ReplayError.Error()); err != nil {
+				return err
+			}
 			continue
 		}
 		// At this point, we know that the transaction batch is valid and able to be executed.
@@ -450,8 +638,14 @@ func (d *Pegnetd) ApplyTransactionBlock(sqlTx *sql.Tx, eblock *factom.EBlock) er
 		// A transaction batch that contains conversions must be put into holding to be executed
 		// in a future block. This prevents gaming of conversions where an actor
 		// can know the exchange rates of the future ahead of time.
-		if txBatch.HasConversions() {
-			_, err = d.Pegnet.InsertTransactionBatchHolding(sqlTx, txBatch, uint64(eblock.Height), eblock.KeyMR)
+		// Time-locked batches are held at the height before they unlock, so they
+		// are executed with the batches in holding of the unlock height.
+		if txBatch.HasConversions() || txBatch.IsTimeLocked(eblock.Height) {
+			holdingHeight := uint64(eblock.Height)
+			if txBatch.IsTimeLocked(eblock.Height) {
+				holdingHeight = uint64(txBatch.NotBefore - 1)
+			}
+			_, err = d.Pegnet.InsertTransactionBatchHolding(sqlTx, txBatch, holdingHeight, eblock.KeyMR)
 			if err != nil {
 				return err
 			}
@@ -463,88 +657,34 @@ func (d *Pegnetd) ApplyTransactionBlock(sqlTx *sql.Tx, eblock *factom.EBlock) er
 			err != pegnet.InsufficientBalanceErr { // Allowed Exception
 			return err
 		} else if err == pegnet.InsufficientBalanceErr {
-			d.Pegnet.SetTransactionHistoryExecuted(sqlTx, txBatch, -1)
+			d.Pegnet.SetTransactionHistoryRejected(sqlTx, txBatch, eblock.Height, pegnet.InsufficientBalanceErrInt, err)
 		}
 	}
 	return nil
 }
 
 // applyTransactionBatch
+//
 //	currentHeight is just for tracing
 func (d *Pegnetd) applyTransactionBatch(sqlTx *sql.Tx, txBatch *fat2.TransactionBatch, rates map[fat2.PTicker]uint64, currentHeight uint32) error {
-	balances := make(map[factom.FAAddress]map[fat2.PTicker]uint64)
-
 	// We need to do all checks up front, then apply the tx
-	for _, tx := range txBatch.Transactions {
-		// First check the input address has the funds
-		bals, err := d.Pegnet.SelectPendingBalances(sqlTx, &tx.Input.Address)
-		if err != nil {
-			return err
-		}
-
-		balances[tx.Input.Address] = bals
-		bal := bals[tx.Input.Type]
-
-		if tx.Input.Amount > bal {
-			return pegnet.InsufficientBalanceErr // This error is safe to pass, and it is handled to skip this batch
-		}
-
-		// conversion checks
-		if tx.IsConversion() {
-			if rates == nil || len(rates) == 0 {
-				// This error will fail the block
-				return fmt.Errorf("rates must exist if TransactionBatch contains conversions")
-			}
-			if rates[tx.Input.Type] == 0 || rates[tx.Conversion] == 0 {
-				// This error will not fail the block, skip the tx
-				return pegnet.ZeroRatesError // 0 rates result in an invalid tx. So we drop it
-			}
-
-			// pXXX -> pFCT conversions are disabled at the activation height
-			if currentHeight >= OneWaypFCTConversions && tx.Conversion == fat2.PTickerFCT {
-				return pegnet.PFCTOneWayError
-			}
-
-			// TODO: For now any bogus amounts will be tossed. Someone can fake an overflow for example,
-			// 		and hold us up forever.
-			_, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
-			if err != nil {
-				return nil
-			}
-		} else {
-			// There are no additional transfer checks
-		}
-	}
-
-	// Now check the batch does not drive an input negative
-	// TODO: A nested tx would be much easier, since we have to literally implement the same loop twice
-	for _, tx := range txBatch.Transactions {
-		if balances[tx.Input.Address][tx.Input.Type] < tx.Input.Amount {
-			return pegnet.InsufficientBalanceErr
-		}
-		balances[tx.Input.Address][tx.Input.Type] -= tx.Input.Amount
-
-		if tx.IsConversion() {
-			outputAmount, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
-			if err != nil {
-				return err
-			}
-			balances[tx.Input.Address][tx.Conversion] += uint64(outputAmount)
-		} else {
-			for _, transfer := range tx.Transfers {
-				// If it is one of our inputs
-				if _, ok := balances[transfer.Address]; ok {
-					balances[transfer.Address][tx.Input.Type] += transfer.Amount
-				}
-			}
-		}
+	err := validator.CheckBalances(pendingState{d.Pegnet, sqlTx}, txBatch, currentHeight, rates)
+	if err == validator.InvalidConversionError {
+		// TODO: For now any bogus amounts will be tossed. Someone can fake an overflow for example,
+		// 		and hold us up forever.
+		return nil
+	} else if err != nil {
+		return err // The rejection errors are safe to pass, and handled to skip this batch
 	}
 
 	// The tx batch should be 100% valid to apply
-	err := d.recordBatch(sqlTx, txBatch, rates, currentHeight)
+	err = d.recordBatch(sqlTx, txBatch, rates, currentHeight)
 	if err != nil {
 		return err
 	}
+	if err = d.Pegnet.InsertEthAddresses(sqlTx, txBatch.Entry, currentHeight); err != nil {
+		return err
+	}
 
 	log.WithFields(log.Fields{
 		"height":     currentHeight, // Just for log traces
@@ -558,31 +698,20 @@ func (d *Pegnetd) applyTransactionBatch(sqlTx *sql.Tx, txBatch *fat2.Transaction
 // recordBatch will submit the batch to the database. We assume the tx is 100%
 // valid at this point.
 func (d *Pegnetd) recordBatch(sqlTx *sql.Tx, txBatch *fat2.TransactionBatch, rates map[fat2.PTicker]uint64, currentHeight uint32) error {
+	changes := pegnet.NewBatchChanges(txBatch, currentHeight)
 	for txIndex, tx := range txBatch.Transactions {
-		_, txErr, err := d.Pegnet.SubFromBalance(sqlTx, &tx.Input.Address, tx.Input.Type, tx.Input.Amount)
-		if err != nil {
-			return err
-		} else if txErr != nil {
-			// This should fail the block
-			return fmt.Errorf("uncaught: %s", txErr.Error())
-		}
-		_, err = d.Pegnet.InsertTransactionRelation(sqlTx, tx.Input.Address, txBatch.Entry.Hash, uint64(txIndex), false, tx.IsConversion())
-		if err != nil {
-			return err
-		}
-
-		if err = d.Pegnet.SetTransactionHistoryExecuted(sqlTx, txBatch, int64(currentHeight)); err != nil {
-			return err
-		}
+		changes.Debit(&tx.Input.Address, tx.Input.Type, tx.Input.Amount)
+		changes.Relate(tx.Input.Address, txIndex, false, tx.IsConversion())
 
 		// All conversions to PEG after the activation height have their
 		// outputs processed later. We only subtract their inputs right now.
-		if currentHeight >= PegnetConversionLimitActivation && tx.IsPEGRequest() {
+		if activation.Active(activation.ConversionLimit, currentHeight) && tx.IsPEGRequest() {
 			// Ensure the output is valid, as we will process it later
 			_, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
 			if err != nil {
 				return err
 			}
+			recordAddressStats(changes, tx)
 			continue // PEG Outputs are handled elsewhere
 		}
 
@@ -593,76 +722,83 @@ func (d *Pegnetd) recordBatch(sqlTx *sql.Tx, txBatch *fat2.TransactionBatch, rat
 				return err
 			}
 
-			if err = d.Pegnet.SetTransactionHistoryConvertedAmount(sqlTx, txBatch, txIndex, outputAmount); err != nil {
-				return err
-			}
-			_, err = d.Pegnet.AddToBalance(sqlTx, &tx.Input.Address, tx.Conversion, uint64(outputAmount))
-			if err != nil {
-				return err
-			}
+			changes.Converted(txIndex, outputAmount, rates[tx.Input.Type], rates[tx.Conversion])
+			changes.Credit(&tx.Input.Address, tx.Conversion, uint64(outputAmount))
+			changes.Volume(&tx.Input.Address, tx.Conversion, pegnet.ConvertedIn, outputAmount)
+			recordAddressStats(changes, tx)
 		} else { // Transfer Outputs
 			for _, transfer := range tx.Transfers {
-				_, err = d.Pegnet.AddToBalance(sqlTx, &transfer.Address, tx.Input.Type, transfer.Amount)
-				if err != nil {
-					return err
-				}
-				_, err = d.Pegnet.InsertTransactionRelation(sqlTx, transfer.Address, txBatch.Entry.Hash, uint64(txIndex), true, false)
-				if err != nil {
-					return err
-				}
+				changes.Credit(&transfer.Address, tx.Input.Type, transfer.Amount)
+				changes.Relate(transfer.Address, txIndex, true, false)
 			}
+			recordAddressStats(changes, tx)
 		}
 	}
+
+	if err := d.Pegnet.ApplyBatchChanges(sqlTx, changes); err == pegnet.InsufficientBalanceErr {
+		// This should fail the block
+		return fmt.Errorf("uncaught: %s", err.Error())
+	} else if err != nil {
+		return err
+	}
 	return nil
 }
 
+// recordAddressStats adds the address aggregates for a transaction that is
+// being applied to the changes of its batch. The output of a conversion is
+// recorded by the caller along with its credit, PEG requests record theirs
+// once it is known.
+func recordAddressStats(changes *pegnet.BatchChanges, tx fat2.Transaction) {
+	changes.Activity(&tx.Input.Address)
+
+	if tx.IsConversion() {
+		changes.Volume(&tx.Input.Address, tx.Input.Type, pegnet.ConvertedOut, int64(tx.Input.Amount))
+		return
+	}
+
+	changes.Volume(&tx.Input.Address, tx.Input.Type, pegnet.TransferOut, int64(tx.Input.Amount))
+	seen := map[factom.FAAddress]bool{tx.Input.Address: true}
+	for _, transfer := range tx.Transfers {
+		if !seen[transfer.Address] {
+			seen[transfer.Address] = true
+			changes.Activity(&transfer.Address)
+		}
+		changes.Volume(&transfer.Address, tx.Input.Type, pegnet.TransferIn, int64(transfer.Amount))
+	}
+}
+
 type pegRequest struct {
-	TxID               string
-	Batch              *fat2.TransactionBatch
-	PegAmountRequested uint64
-	TxIndex            int
+	Batch   *fat2.TransactionBatch
+	TxIndex int
 }
 
 func (d *Pegnetd) recordPegnetRequests(sqlTx *sql.Tx, txBatchs []*fat2.TransactionBatch, rates map[fat2.PTicker]uint64, currentHeight uint32, bank uint64, bankHeight int32) error {
-	limit := conversions.NewConversionSupply(bank)
+	var requests []PEGRequest
 	txData := make(map[string]pegRequest)
 
 	// First we need to extract all the txs that are pegnet requests
 	// The batches we are given might contain 0 or more pegnet requests.
 	for i := range txBatchs {
 		for j := range txBatchs[i].Transactions {
-			// Retrieve each tx individually.
-			tx := txBatchs[i].Transactions[j]
 			// The txid helps determine the order when deciding who
 			// gets the dust
 			txid := transactionid.FormatTxID(j, txBatchs[i].Entry.Hash.String())
-
-			// We caught the error earlier, so we can ignore it here.
-			pegAmt, _ := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
-			txData[txid] = pegRequest{
-				TxID:               txid,
-				Batch:              txBatchs[i],
-				PegAmountRequested: uint64(pegAmt),
-				TxIndex:            j,
-			}
-
-			// limit calculates how much each PEG each tx is allocted
-			err := limit.AddConversion(txid, txData[txid].PegAmountRequested)
-			if err != nil {
-				return err // No recovery
-			}
+			requests = append(requests, PEGRequest{TxID: txid, Tx: txBatchs[i].Transactions[j]})
+			txData[txid] = pegRequest{Batch: txBatchs[i], TxIndex: j}
 		}
 	}
 
 	// Now we have all the PEG amounts and requests in, time to do the payouts.
-	pegPayouts := limit.Payouts()
+	pegPayouts, totalRequested, err := PayoutPEGRequests(requests, rates, bank)
+	if err != nil {
+		return err // No recovery
+	}
 	var totalPaid int64
-	for txid, pegYield := range pegPayouts {
+	for _, payout := range pegPayouts {
+		txid, pegYield, refundAmt := payout.TxID, payout.Yield, payout.Refund
 		totalPaid += int64(pegYield)
 		tx := txData[txid].Batch.Transactions[txData[txid].TxIndex]
 
-		refundAmt := conversions.Refund(int64(tx.Input.Amount), int64(pegYield), rates[tx.Input.Type], rates[tx.Conversion])
-
 		log.WithFields(log.Fields{
 			"batch-entryhash": txData[txid].Batch.Entry.Hash.String(),
 			"height":          currentHeight,
@@ -673,7 +809,7 @@ func (d *Pegnetd) recordPegnetRequests(sqlTx *sql.Tx, txBatchs []*fat2.Transacti
 			"inputtype":       tx.Input.Type.String(),
 		}).Tracef("refund set")
 
-		if err := d.Pegnet.SetTransactionHistoryPEGConvertedRequestAmount(sqlTx, txData[txid].Batch, txData[txid].TxIndex, int64(pegYield), refundAmt); err != nil {
+		if err := d.Pegnet.SetTransactionHistoryPEGConvertedRequestAmount(sqlTx, txData[txid].Batch, txData[txid].TxIndex, int64(pegYield), refundAmt, rates[tx.Input.Type], rates[tx.Conversion]); err != nil {
 			return err
 		}
 
@@ -686,11 +822,28 @@ func (d *Pegnetd) recordPegnetRequests(sqlTx *sql.Tx, txBatchs []*fat2.Transacti
 		if _, err := d.Pegnet.AddToBalance(sqlTx, &tx.Input.Address, tx.Input.Type, uint64(refundAmt)); err != nil {
 			return err
 		}
+		if refundAmt > 0 {
+			reason := pegnet.RefundReasonConversionLimit
+			if payout.BelowMinOutput {
+				reason = pegnet.RefundReasonMinOutput
+			}
+			if err := d.Pegnet.InsertTransactionHistoryRefund(sqlTx, txData[txid].Batch, txData[txid].TxIndex, currentHeight, refundAmt, reason); err != nil {
+				return err
+			}
+		}
+
+		// Record the PEG received. The refund was never consumed by the conversion
+		if err := d.Pegnet.AddAddressVolume(sqlTx, &tx.Input.Address, tx.Conversion, pegnet.ConvertedIn, int64(pegYield)); err != nil {
+			return err
+		}
+		if err := d.Pegnet.AddAddressVolume(sqlTx, &tx.Input.Address, tx.Input.Type, pegnet.ConvertedOut, -refundAmt); err != nil {
+			return err
+		}
 	}
 
 	// The bankheight == currentheight after V4Update fork
-	if bankHeight >= int32(V4OPRUpdate) {
-		err := d.Pegnet.UpdateBankEntry(sqlTx, bankHeight, totalPaid, int64(limit.TotalRequested()))
+	if bankHeight >= 0 && activation.Active(activation.V4OPRUpdate, uint32(bankHeight)) {
+		err := d.Pegnet.UpdateBankEntry(sqlTx, bankHeight, totalPaid, int64(totalRequested))
 		if err != nil {
 			return err
 		}
@@ -699,15 +852,27 @@ func (d *Pegnetd) recordPegnetRequests(sqlTx *sql.Tx, txBatchs []*fat2.Transacti
 	return nil
 }
 
-// ApplyFactoidBlock applies the FCT burns that occurred within the given
-// DBlock. If an error is returned, the sql.Tx should be rolled back by the caller.
-func (d *Pegnetd) ApplyFactoidBlock(ctx context.Context, tx *sql.Tx, dblock *factom.DBlock) error {
+// fetchFactoidBlock gets the FBlock of the height with all its transactions
+func (d *Pegnetd) fetchFactoidBlock(ctx context.Context, height uint32) (*factom.FBlock, error) {
 	fblock := new(factom.FBlock)
-	fblock.Height = dblock.Height
-	if err := fblock.Get(nil, d.FactomClient); err != nil {
-		return err
+	fblock.Height = height
+	if err := fblock.Get(ctx, d.FactomClient); err != nil {
+		return nil, err
+	}
+	for i := range fblock.Transactions {
+		if isDone(ctx) {
+			return nil, context.Canceled
+		}
+		if err := fblock.Transactions[i].Get(ctx, d.FactomClient); err != nil {
+			return nil, err
+		}
 	}
+	return fblock, nil
+}
 
+// ApplyFactoidBlock applies the FCT burns that occurred within the given
+// FBlock. If an error is returned, the sql.Tx should be rolled back by the caller.
+func (d *Pegnetd) ApplyFactoidBlock(ctx context.Context, tx *sql.Tx, fblock *factom.FBlock) error {
 	var totalBurned uint64
 	var burns []factom.FactoidTransaction
 
@@ -715,40 +880,34 @@ func (d *Pegnetd) ApplyFactoidBlock(ctx context.Context, tx *sql.Tx, dblock *fac
 	// - Only 1 output, and that output must be the EC burn address
 	// - The output amount must be 0
 	// - Must only have 1 input
+	// Transactions to the burn address that miss any are recorded as
+	// malformed burns, nothing is credited for them.
 	for i := range fblock.Transactions {
 		if isDone(ctx) {
 			return context.Canceled
 		}
 
-		if err := fblock.Transactions[i].Get(nil, d.FactomClient); err != nil {
-			return err
-		}
-
-		tx := fblock.Transactions[i]
-		// Check number of inputs/outputs
-		if len(tx.ECOutputs) != 1 || len(tx.FCTInputs) != 1 || len(tx.FCTOutputs) > 0 {
-			continue // Wrong number of ins/outs for a burn
-		}
-
-		// Check correct output
-		out := tx.ECOutputs[0]
-		if BurnRCD != out.Address {
-			continue // Wrong EC output for a burn
+		fTx := fblock.Transactions[i]
+		problem, detail, toBurn := checkBurn(fTx)
+		if !toBurn {
+			continue
 		}
-
-		// Check right output amount
-		if out.Amount != 0 {
-			continue // You cannot buy EC and burn
+		if problem != "" {
+			log.WithFields(log.Fields{"height": fblock.Height, "txid": fTx.TransactionID, "reason": problem}).Warnf("malformed burn: %s", detail)
+			if err := d.Pegnet.InsertMalformedBurn(tx, fblock.Height, fTx, problem, detail); err != nil {
+				return err
+			}
+			continue
 		}
 
-		in := tx.FCTInputs[0]
+		in := fTx.FCTInputs[0]
 		totalBurned += in.Amount
-		burns = append(burns, tx)
+		burns = append(burns, fTx)
 	}
 
 	var _ = burns
 	if totalBurned > 0 { // Just some debugging
-		log.WithFields(log.Fields{"height": dblock.Height, "amount": totalBurned, "quantity": len(burns)}).Debug("fct burned")
+		log.WithFields(log.Fields{"height": fblock.Height, "amount": totalBurned, "quantity": len(burns)}).Debug("fct burned")
 	}
 
 	// All burns are FCT inputs
@@ -759,7 +918,11 @@ func (d *Pegnetd) ApplyFactoidBlock(ctx context.Context, tx *sql.Tx, dblock *fac
 			return err
 		}
 
-		if err := d.Pegnet.InsertFCTBurn(tx, fblock.KeyMR, burns[i], dblock.Height); err != nil {
+		if err := d.Pegnet.InsertFCTBurn(tx, fblock.KeyMR, burns[i], fblock.Height); err != nil {
+			return err
+		}
+
+		if err := d.Pegnet.AddAddressActivity(tx, &add, fblock.Height); err != nil {
 			return err
 		}
 	}
@@ -768,9 +931,13 @@ func (d *Pegnetd) ApplyFactoidBlock(ctx context.Context, tx *sql.Tx, dblock *fac
 }
 
 // ApplyGradedOPRBlock pays out PEG to the winners of the given GradedBlock.
+// The payouts of all winners are written together.
 // If an error is returned, the sql.Tx should be rolled back by the caller.
 func (d *Pegnetd) ApplyGradedOPRBlock(tx *sql.Tx, gradedBlock grader.GradedBlock, timestamp time.Time) error {
 	winners := gradedBlock.Winners()
+	var paid []*grader.GradingOPR
+	var addrs []factom.FAAddress
+	var payouts []uint64
 	for i := range winners {
 		addr, err := factom.NewFAAddress(winners[i].OPR.GetAddress())
 		if err != nil {
@@ -783,16 +950,21 @@ func (d *Pegnetd) ApplyGradedOPRBlock(tx *sql.Tx, gradedBlock grader.GradedBlock
 			}).Warnf("failed to reward")
 			continue
 		}
+		paid = append(paid, winners[i])
+		addrs = append(addrs, addr)
+		payouts = append(payouts, uint64(winners[i].Payout()))
+	}
+	if len(paid) == 0 {
+		return nil
+	}
 
-		if _, err := d.Pegnet.AddToBalance(tx, &addr, fat2.PTickerPEG, uint64(winners[i].Payout())); err != nil {
-			return err
-		}
-
-		if err := d.Pegnet.InsertCoinbase(tx, winners[i], addr[:], timestamp); err != nil {
-			return err
-		}
+	if err := d.Pegnet.AddToBalances(tx, addrs, fat2.PTickerPEG, payouts); err != nil {
+		return err
 	}
-	return nil
+	if err := d.Pegnet.InsertCoinbases(tx, paid, addrs, timestamp); err != nil {
+		return err
+	}
+	return d.Pegnet.AddAddressActivities(tx, addrs, uint32(paid[0].OPR.GetHeight()))
 }
 
 func isDone(ctx context.Context) bool {
@@ -0,0 +1,118 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+// Watchdog detects a sync that does not progress while factomd does. A
+// stalled sync is logged, makes the node unhealthy, and is sent to the
+// notifiers with the "stalled" trigger.
+type Watchdog struct {
+	// StalledAfter is how long the sync can stay at the same height while
+	// factomd is ahead
+	StalledAfter time.Duration
+	// Notifier is nil if no notifiers are configured
+	Notifier *notify.Service
+
+	mu           sync.Mutex
+	lastHeight   uint32
+	lastProgress time.Time
+	factomHeight uint32
+	stalled      bool
+}
+
+// WatchdogStatus is the health of the sync as seen by the last check
+type WatchdogStatus struct {
+	Healthy      bool      `json:"healthy"`
+	Height       uint32    `json:"height"`
+	FactomHeight uint32    `json:"factomheight,omitempty"`
+	LastProgress time.Time `json:"lastprogress,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// Run checks the sync every interval until the context is cancelled. An
+// unreachable factomd is not checked, it can't be told apart from a stall.
+func (w *Watchdog) Run(ctx context.Context, interval time.Duration, synced func() uint32, client *factom.Client) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		heights := new(factom.Heights)
+		if err := heights.Get(ctx, client); err != nil {
+			continue
+		}
+		w.Check(ctx, synced(), heights.DirectoryBlock, time.Now())
+	}
+}
+
+// Check compares the sync to the height of factomd. The sync is stalled if
+// it did not progress for StalledAfter while factomd is ahead.
+func (w *Watchdog) Check(ctx context.Context, synced, factomHeight uint32, now time.Time) {
+	w.mu.Lock()
+	w.factomHeight = factomHeight
+	if synced != w.lastHeight || w.lastProgress.IsZero() {
+		resumed := w.stalled
+		w.stalled = false
+		w.lastHeight, w.lastProgress = synced, now
+		w.mu.Unlock()
+
+		if resumed {
+			log.WithField("height", synced).Warn("sync resumed")
+			if w.notify(notify.TriggerStalled) {
+				w.Notifier.Resolved(ctx, notify.TriggerStalled, fmt.Sprintf("pegnetd: sync resumed, now at height %d", synced))
+			}
+		}
+		return
+	}
+	if w.stalled || factomHeight <= synced || now.Sub(w.lastProgress) < w.StalledAfter {
+		w.mu.Unlock()
+		return
+	}
+	w.stalled = true
+	stalledFor := now.Sub(w.lastProgress).Round(time.Second)
+	w.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"height":       synced,
+		"factomheight": factomHeight,
+		"stalled":      stalledFor,
+	}).Error("SYNC STALLED: the height has not advanced while factomd did")
+	if w.notify(notify.TriggerStalled) {
+		w.Notifier.Send(ctx, notify.TriggerStalled, fmt.Sprintf("pegnetd: sync stalled at height %d for %s, factomd is at height %d",
+			synced, stalledFor, factomHeight))
+	}
+}
+
+func (w *Watchdog) notify(trigger string) bool {
+	return w.Notifier != nil && w.Notifier.Triggers[trigger]
+}
+
+// Status returns the result of the last check. The sync is healthy until it
+// is stalled.
+func (w *Watchdog) Status() WatchdogStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := WatchdogStatus{
+		Healthy:      !w.stalled,
+		Height:       w.lastHeight,
+		FactomHeight: w.factomHeight,
+		LastProgress: w.lastProgress,
+	}
+	if w.stalled {
+		status.Reason = fmt.Sprintf("sync stalled at height %d since %s, factomd is at height %d",
+			w.lastHeight, w.lastProgress.UTC().Format(time.RFC3339), w.factomHeight)
+	}
+	return status
+}
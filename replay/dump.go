@@ -0,0 +1,230 @@
+// Package replay captures the factomd data pegnetd syncs into a dump, and
+// replays a dump offline through the sync of the node. The state hash of
+// every replayed height is emitted, so the results of two builds can be
+// compared height by height.
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// DumpVersion is the version of the dump format
+const DumpVersion = 1
+
+// Header is the first line of a dump
+type Header struct {
+	Version int    `json:"version"`
+	Network string `json:"network"`
+	// Assets are the assets registered on the node that exported the dump,
+	// they are registered for the replay as well
+	Assets []string `json:"assets,omitempty"`
+	Start  uint32   `json:"start"`
+	Stop   uint32   `json:"stop"`
+}
+
+// Block is the raw factomd data of a height: the directory and factoid
+// blocks, and the entry blocks, entries, and factoid transactions the sync
+// requests by hash
+type Block struct {
+	Height  uint32       `json:"height"`
+	DBlock  factom.Bytes `json:"dblock"`
+	FBlock  factom.Bytes `json:"fblock"`
+	Objects []Object     `json:"objects"`
+}
+
+// Object is the raw data of a hash
+type Object struct {
+	Hash factom.Bytes32 `json:"hash"`
+	Data factom.Bytes   `json:"data"`
+}
+
+// Fetch returns the block of the height from factomd, with the entry blocks
+// and entries of the chains
+func Fetch(ctx context.Context, c *factom.Client, height uint32, chains ...factom.Bytes32) (*Block, error) {
+	params := struct {
+		Height uint32 `json:"height"`
+	}{Height: height}
+	var res struct {
+		RawData factom.Bytes `json:"rawdata"`
+	}
+
+	b := &Block{Height: height}
+	if err := c.FactomdRequest(ctx, "dblock-by-height", params, &res); err != nil {
+		return nil, err
+	}
+	var dblock factom.DBlock
+	if err := dblock.UnmarshalBinary(res.RawData); err != nil {
+		return nil, fmt.Errorf("dblock %d: %v", height, err)
+	}
+	b.DBlock = res.RawData
+
+	for _, chain := range chains {
+		eblock := dblock.EBlock(chain)
+		if eblock == nil {
+			continue
+		}
+		data, err := b.fetchObject(ctx, c, *eblock.KeyMR)
+		if err != nil {
+			return nil, err
+		}
+		if err := eblock.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("eblock %s: %v", eblock.KeyMR, err)
+		}
+		for _, e := range eblock.Entries {
+			if _, err := b.fetchObject(ctx, c, *e.Hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res.RawData = nil
+	if err := c.FactomdRequest(ctx, "fblock-by-height", params, &res); err != nil {
+		return nil, err
+	}
+	var fblock factom.FBlock
+	if err := fblock.UnmarshalBinary(res.RawData); err != nil {
+		return nil, fmt.Errorf("fblock %d: %v", height, err)
+	}
+	b.FBlock = res.RawData
+	// The sync requests the transactions the fblock does not populate
+	for _, tx := range fblock.Transactions {
+		if tx.IsPopulated() || tx.TransactionID == nil {
+			continue
+		}
+		if _, err := b.fetchObject(ctx, c, *tx.TransactionID); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *Block) fetchObject(ctx context.Context, c *factom.Client, hash factom.Bytes32) (factom.Bytes, error) {
+	params := struct {
+		Hash factom.Bytes32 `json:"hash"`
+	}{Hash: hash}
+	var res struct {
+		Data factom.Bytes `json:"data"`
+	}
+	if err := c.FactomdRequest(ctx, "raw-data", params, &res); err != nil {
+		return nil, fmt.Errorf("raw data of %s: %v", hash, err)
+	}
+	b.Objects = append(b.Objects, Object{Hash: hash, Data: res.Data})
+	return res.Data, nil
+}
+
+// Writer writes a dump as lines of json, compressed if the path ends in
+// ".gz"
+type Writer struct {
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+// Create creates the dump at the path and writes the header
+func Create(path string, header Header) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{file: f}
+	var out io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		w.gz = gzip.NewWriter(f)
+		out = w.gz
+	}
+	w.buf = bufio.NewWriter(out)
+	w.enc = json.NewEncoder(w.buf)
+
+	header.Version = DumpVersion
+	if err := w.enc.Encode(header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends the block to the dump
+func (w *Writer) Write(b *Block) error {
+	return w.enc.Encode(b)
+}
+
+// Close flushes the dump and closes the file
+func (w *Writer) Close() error {
+	err := w.buf.Flush()
+	if w.gz != nil && err == nil {
+		err = w.gz.Close()
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Reader reads the blocks of a dump in order
+type Reader struct {
+	Header Header
+
+	file *os.File
+	gz   *gzip.Reader
+	dec  *json.Decoder
+}
+
+// Open opens the dump at the path and reads the header. Compressed dumps
+// are detected from their content.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: f}
+	buf := bufio.NewReader(f)
+	var in io.Reader = buf
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if r.gz, err = gzip.NewReader(buf); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		in = r.gz
+	}
+	r.dec = json.NewDecoder(in)
+
+	if err := r.dec.Decode(&r.Header); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("invalid dump header: %v", err)
+	}
+	if r.Header.Version != DumpVersion {
+		_ = f.Close()
+		return nil, fmt.Errorf("unsupported dump version %d, expected %d", r.Header.Version, DumpVersion)
+	}
+	return r, nil
+}
+
+// Next returns the next block of the dump, or io.EOF after the last one
+func (r *Reader) Next() (*Block, error) {
+	b := new(Block)
+	if err := r.dec.Decode(b); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid dump block: %v", err)
+	}
+	return b, nil
+}
+
+// Close closes the file of the dump
+func (r *Reader) Close() error {
+	if r.gz != nil {
+		_ = r.gz.Close()
+	}
+	return r.file.Close()
+}
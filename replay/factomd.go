@@ -0,0 +1,143 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errBlockNotFound  = jrpc.NewError(-32008, "Block not found", nil)
+	errObjectNotFound = jrpc.NewError(-32008, "Object not found", nil)
+)
+
+// Factomd serves the factomd api of the block of a dump that is replayed.
+// It implements http.RoundTripper, so a factom client can use it without a
+// server.
+type Factomd struct {
+	handler http.Handler
+
+	mu      sync.Mutex
+	block   *Block
+	keyMR   factom.Bytes32
+	objects map[factom.Bytes32]factom.Bytes
+}
+
+// NewFactomd returns a source without a block
+func NewFactomd() *Factomd {
+	f := new(Factomd)
+	f.handler = jrpc.HTTPRequestHandler(jrpc.MethodMap{
+		"heights":          f.heights,
+		"dblock-by-height": f.dblockByHeight,
+		"fblock-by-height": f.fblockByHeight,
+		"raw-data":         f.rawData,
+	}, log.WithField("component", "replay"))
+	return f
+}
+
+// Load replaces the served block
+func (f *Factomd) Load(b *Block) error {
+	var dblock factom.DBlock
+	if err := dblock.UnmarshalBinary(b.DBlock); err != nil {
+		return fmt.Errorf("dblock %d: %v", b.Height, err)
+	}
+	if dblock.Height != b.Height {
+		return fmt.Errorf("the dblock of height %d is at height %d", b.Height, dblock.Height)
+	}
+	objects := make(map[factom.Bytes32]factom.Bytes, len(b.Objects))
+	for _, o := range b.Objects {
+		objects[o.Hash] = o.Data
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.block, f.keyMR, f.objects = b, *dblock.KeyMR, objects
+	return nil
+}
+
+// RoundTrip serves the request of a factom client from the loaded block
+func (f *Factomd) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, req)
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+func (f *Factomd) heights(_ context.Context, _ json.RawMessage) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var height uint32
+	if f.block != nil {
+		height = f.block.Height
+	}
+	return factom.Heights{DirectoryBlock: height, Leader: height, EntryBlock: height, Entry: height}
+}
+
+// loaded returns the loaded block if it is the height of the params
+func (f *Factomd) loaded(data json.RawMessage) (*Block, factom.Bytes32, error) {
+	var params struct {
+		Height *uint32 `json:"height"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil || params.Height == nil {
+		return nil, factom.Bytes32{}, jrpc.ErrorInvalidParams("expected a height")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.block == nil || f.block.Height != *params.Height {
+		return nil, factom.Bytes32{}, errBlockNotFound
+	}
+	return f.block, f.keyMR, nil
+}
+
+func (f *Factomd) dblockByHeight(_ context.Context, data json.RawMessage) interface{} {
+	b, keyMR, err := f.loaded(data)
+	if err != nil {
+		return err
+	}
+	type dblock struct {
+		KeyMR factom.Bytes32 `json:"keymr"`
+	}
+	return struct {
+		DBlock  dblock       `json:"dblock"`
+		RawData factom.Bytes `json:"rawdata"`
+	}{DBlock: dblock{KeyMR: keyMR}, RawData: b.DBlock}
+}
+
+func (f *Factomd) fblockByHeight(_ context.Context, data json.RawMessage) interface{} {
+	b, _, err := f.loaded(data)
+	if err != nil {
+		return err
+	}
+	return struct {
+		RawData factom.Bytes `json:"rawdata"`
+	}{RawData: b.FBlock}
+}
+
+func (f *Factomd) rawData(_ context.Context, data json.RawMessage) interface{} {
+	var params struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil || params.Hash == nil {
+		return jrpc.ErrorInvalidParams("expected a hash")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	raw, ok := f.objects[*params.Hash]
+	if !ok {
+		return errObjectNotFound
+	}
+	return struct {
+		Data factom.Bytes `json:"data"`
+	}{Data: raw}
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/activation"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/spf13/viper"
+)
+
+// Replay syncs the blocks of the dump into a new database at the dbpath, and
+// calls emit with the state hash of every height after it is committed. The
+// dump has to start at the first height of the pegnet, and be of the network
+// the node is set to. Returns the last replayed height.
+func Replay(ctx context.Context, dump *Reader, dbpath string, emit func(height uint32, hash factom.Bytes32) error) (uint32, error) {
+	if dump.Header.Network != node.ActiveNetwork.Name {
+		return 0, fmt.Errorf("the dump is of %s, the node is set to %s", dump.Header.Network, node.ActiveNetwork.Name)
+	}
+	if start := activation.Height(activation.Pegnet) + 1; dump.Header.Start != start {
+		return 0, fmt.Errorf("the dump starts at height %d, a replay starts at height %d", dump.Header.Start, start)
+	}
+	// The OPRs of the regtest mock are mined with a small LXR map
+	if dump.Header.Network == node.RegTest.Name && os.Getenv("LXRBITSIZE") == "" {
+		_ = os.Setenv("LXRBITSIZE", "10")
+	}
+
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, dbpath)
+	conf.Set(config.Assets, dump.Header.Assets)
+	conf.Set(config.WatchdogStalled, 0)
+
+	d, err := node.NewPegnetd(ctx, conf)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Pegnet.Close()
+	if d.Sync.Synced != activation.Height(activation.Pegnet) {
+		return 0, fmt.Errorf("the database at %s is synced to height %d, a replay needs a new database", dbpath, d.Sync.Synced)
+	}
+	source := NewFactomd()
+	d.FactomClient.Factomd.Transport = source
+
+	var hash factom.Bytes32
+	for {
+		b, err := dump.Next()
+		if err == io.EOF {
+			return d.Sync.Synced, nil
+		}
+		if err != nil {
+			return d.Sync.Synced, err
+		}
+		if b.Height != d.Sync.Synced+1 {
+			return d.Sync.Synced, fmt.Errorf("the dump skips from height %d to %d", d.Sync.Synced, b.Height)
+		}
+		if err := source.Load(b); err != nil {
+			return d.Sync.Synced, err
+		}
+		if err := d.SyncNextBlock(ctx); err != nil {
+			return d.Sync.Synced, fmt.Errorf("height %d: %v", b.Height, err)
+		}
+		if hash, err = d.Pegnet.SelectStateHash(nil, b.Height, hash); err != nil {
+			return d.Sync.Synced, err
+		}
+		if err := emit(b.Height, hash); err != nil {
+			return d.Sync.Synced, err
+		}
+	}
+}
+
+// StateHashes calls emit with the state hash of every height of a database
+// synced from the first height of the pegnet, up to the stop height
+func StateHashes(ctx context.Context, p *pegnet.Pegnet, stop uint32, emit func(height uint32, hash factom.Bytes32) error) error {
+	var hash factom.Bytes32
+	var err error
+	for height := activation.Height(activation.Pegnet) + 1; height <= stop; height++ {
+		if isDone(ctx) {
+			return ctx.Err()
+		}
+		if hash, err = p.SelectStateHash(nil, height, hash); err != nil {
+			return err
+		}
+		if err := emit(height, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
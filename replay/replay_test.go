@@ -0,0 +1,112 @@
+package replay_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fixtures"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	. "github.com/pegnet/pegnetd/replay"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay_Fixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, ok := fixtures.Lookup("conversions")
+	require.True(t, ok)
+	fixture, err := fixtures.Generate(context.Background(), s, dir)
+	require.NoError(t, err)
+
+	// The hashes of the synced fixture
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, fixtures.DBPath(dir, s.Name))
+	p := pegnet.New(conf)
+	require.NoError(t, p.Init())
+	synced := make(map[uint32]factom.Bytes32)
+	err = StateHashes(context.Background(), p, fixture.Height, func(height uint32, hash factom.Bytes32) error {
+		synced[height] = hash
+		return nil
+	})
+	require.NoError(t, err)
+	// The sync records the same hashes with the blocks
+	for h := uint32(1); h <= fixture.Height; h++ {
+		block, err := p.SelectBlock(context.Background(), h)
+		require.NoError(t, err)
+		require.NotNil(t, block, "height %d", h)
+		require.NotNil(t, block.StateHash, "height %d", h)
+		assert.Equal(t, synced[h], *block.StateHash, "height %d", h)
+	}
+	require.NoError(t, p.Close())
+
+	dump, err := Open(fixtures.DumpPath(dir, s.Name))
+	require.NoError(t, err)
+	defer dump.Close()
+	assert.Equal(t, fixture.Height, dump.Header.Stop)
+
+	replayed := make(map[uint32]factom.Bytes32)
+	height, err := Replay(context.Background(), dump, filepath.Join(dir, "replay.db"), func(height uint32, hash factom.Bytes32) error {
+		replayed[height] = hash
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fixture.Height, height)
+	assert.Len(t, replayed, int(fixture.Height))
+	for h := uint32(1); h <= fixture.Height; h++ {
+		assert.Equal(t, synced[h], replayed[h], "height %d", h)
+	}
+	assert.NotEqual(t, replayed[fixture.Height-1], replayed[fixture.Height])
+
+	// A replay needs a new database
+	dump, err = Open(fixtures.DumpPath(dir, s.Name))
+	require.NoError(t, err)
+	defer dump.Close()
+	_, err = Replay(context.Background(), dump, filepath.Join(dir, "replay.db"), func(uint32, factom.Bytes32) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestReplay_Gap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, ok := fixtures.Lookup("prorated")
+	require.True(t, ok)
+	_, err = fixtures.Generate(context.Background(), s, dir)
+	require.NoError(t, err)
+
+	// Copy the dump without its second block, compressed
+	in, err := Open(fixtures.DumpPath(dir, s.Name))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "gap.dump.gz")
+	out, err := Create(path, in.Header)
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		b, err := in.Next()
+		if err != nil {
+			break
+		}
+		if i != 1 {
+			require.NoError(t, out.Write(b))
+		}
+	}
+	require.NoError(t, in.Close())
+	require.NoError(t, out.Close())
+
+	dump, err := Open(path)
+	require.NoError(t, err)
+	defer dump.Close()
+	height, err := Replay(context.Background(), dump, filepath.Join(dir, "replay.db"), func(uint32, factom.Bytes32) error { return nil })
+	assert.EqualError(t, err, "the dump skips from height 1 to 3")
+	assert.Equal(t, uint32(1), height)
+}
@@ -0,0 +1,243 @@
+// Package snapshot publishes and imports copies of a synced database, so a
+// new node starts at the height of a snapshot instead of syncing every height
+// from factomd. A snapshot is only imported if its state hash, which commits
+// to every height up to the snapshot, is recomputed from its rows and matches
+// a trusted hash or the hash other nodes report at that height.
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/replay"
+	"github.com/pegnet/pegnetd/srv"
+)
+
+// Info is the state a snapshot was taken at
+type Info struct {
+	Network   string
+	Height    uint32
+	StateHash factom.Bytes32
+}
+
+// Create writes a snapshot of the database to the path, gzip compressed if
+// the path ends in ".gz". The state hash of the returned info is the value to
+// publish along with the snapshot.
+func Create(ctx context.Context, p *pegnet.Pegnet, path string) (Info, error) {
+	raw := path
+	if strings.HasSuffix(path, ".gz") {
+		raw = strings.TrimSuffix(path, ".gz") + ".tmp"
+		_ = os.Remove(raw)
+		defer os.Remove(raw)
+	}
+	if err := p.Snapshot(ctx, raw); err != nil {
+		return Info{}, err
+	}
+
+	s, err := open(raw)
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := readInfo(ctx, s)
+	s.Close()
+	if err != nil || raw == path {
+		return info, err
+	}
+	return info, compress(raw, path)
+}
+
+// Download fetches the snapshot of the url into the path, and decompresses
+// it if it is gzip compressed. A source that is not an http or https url is
+// the path of a local file.
+func Download(ctx context.Context, c *http.Client, src, path string) error {
+	var r io.ReadCloser
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		req, err := http.NewRequest(http.MethodGet, src, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("%s: %s", src, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	buf := bufio.NewReader(r)
+	var data io.Reader = buf
+	// gzip streams start with the magic bytes 1f 8b
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		data = gz
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Verify checks the snapshot at the path and returns the state it was taken
+// at. The snapshot has to be of the network, and the state hash of every
+// height is recomputed from its rows up to the synced height, which has to
+// match the hash stored at that height. The balances have to match the
+// balance journal the hashes commit to, and every batch in holding has to
+// be committed to by a hash.
+func Verify(ctx context.Context, path, network string) (Info, error) {
+	p, err := open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer p.Close()
+
+	info, err := readInfo(ctx, p)
+	if err != nil {
+		return info, err
+	}
+	if info.Network != network {
+		return info, fmt.Errorf("the snapshot is of the network %s, not %s", info.Network, network)
+	}
+	if partial, err := p.SelectPartialHeight(ctx, nil, info.Height); err != nil {
+		return info, err
+	} else if partial.Found() {
+		return info, fmt.Errorf("the snapshot has rows above its synced height %d", info.Height)
+	}
+	if n, err := p.SelectBalanceMismatches(ctx); err != nil {
+		return info, err
+	} else if n > 0 {
+		return info, fmt.Errorf("%d balances of the snapshot do not match its balance journal", n)
+	}
+	if n, err := p.SelectUncommittedHoldings(ctx); err != nil {
+		return info, err
+	} else if n > 0 {
+		return info, fmt.Errorf("%d batches in holding of the snapshot are not in its transaction history", n)
+	}
+
+	var hash factom.Bytes32
+	err = replay.StateHashes(ctx, p, info.Height, func(_ uint32, h factom.Bytes32) error {
+		hash = h
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+	if hash != info.StateHash {
+		return info, fmt.Errorf("the state hash of height %d is %s, the snapshot recomputes to %s", info.Height, info.StateHash, hash)
+	}
+	return info, nil
+}
+
+// Trust checks the state hash of the snapshot against the trusted hash if it
+// is not nil, and against the hash every pegnetd api of the nodes reports at
+// the height of the snapshot. At least one of them is required.
+func Trust(ctx context.Context, info Info, trusted *factom.Bytes32, nodes []string) error {
+	if trusted == nil && len(nodes) == 0 {
+		return fmt.Errorf("no trusted state hash or node to compare the snapshot to")
+	}
+	if trusted != nil && *trusted != info.StateHash {
+		return fmt.Errorf("the state hash of the snapshot is %s, the trusted hash is %s", info.StateHash, trusted)
+	}
+	for _, u := range nodes {
+		cl := srv.NewClient()
+		cl.PegnetdServer = strings.TrimSuffix(u, "/")
+		var res srv.ResultGetBlocks
+		params := srv.ParamsGetBlocks{Start: int(info.Height), Stop: int(info.Height)}
+		if err := cl.Request("get-blocks", params, &res); err != nil {
+			return fmt.Errorf("%s: %v", u, err)
+		}
+		if len(res.Blocks) == 0 || res.Blocks[0].StateHash == nil {
+			return fmt.Errorf("%s: no state hash at height %d", u, info.Height)
+		}
+		if hash := *res.Blocks[0].StateHash; hash != info.StateHash {
+			return fmt.Errorf("%s: the state hash of height %d is %s, the snapshot has %s", u, info.Height, hash, info.StateHash)
+		}
+	}
+	return nil
+}
+
+// open opens the sqlite file at the path without creating tables on it
+func open(path string) (*pegnet.Pegnet, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	return &pegnet.Pegnet{DB: db}, nil
+}
+
+// readInfo reads the network, the synced height and its stored state hash
+func readInfo(ctx context.Context, p *pegnet.Pegnet) (Info, error) {
+	var info Info
+	err := p.DB.QueryRowContext(ctx, `SELECT "value" FROM "pn_metadata" WHERE "name" = 'network';`).Scan(&info.Network)
+	if err != nil {
+		return info, fmt.Errorf("network: %v", err)
+	}
+	synced, err := p.SelectSynced(ctx, p.DB)
+	if err != nil {
+		return info, fmt.Errorf("synced height: %v", err)
+	}
+	info.Height = synced.Synced
+
+	block, err := p.SelectBlock(ctx, info.Height)
+	if err != nil {
+		return info, err
+	}
+	if block == nil || block.StateHash == nil {
+		return info, fmt.Errorf("no state hash at the synced height %d", info.Height)
+	}
+	info.StateHash = *block.StateHash
+	return info, nil
+}
+
+func compress(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,146 @@
+package snapshot_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/fixtures"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	. "github.com/pegnet/pegnetd/snapshot"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pegnetd-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, ok := fixtures.Lookup("rich")
+	require.True(t, ok)
+	_, err = fixtures.Generate(context.Background(), s, dir)
+	require.NoError(t, err)
+
+	conf := viper.New()
+	config.SetDefaults(conf)
+	conf.Set(config.SqliteDBPath, fixtures.DBPath(dir, s.Name))
+	p := pegnet.New(conf)
+	require.NoError(t, p.Init())
+	synced, err := p.SelectSynced(context.Background(), p.DB)
+	require.NoError(t, err)
+	require.NoError(t, p.InsertPriceCheck(nil, pegnet.PriceCheck{Height: synced.Synced, Checked: time.Now()}))
+
+	published := filepath.Join(dir, "snapshot.db.gz")
+	info, err := Create(context.Background(), p, published)
+	require.NoError(t, err)
+	p.Close()
+	assert.Equal(t, node.RegTest.Name, info.Network)
+	assert.Equal(t, synced.Synced, info.Height)
+
+	path := filepath.Join(dir, "bootstrap.db")
+	require.NoError(t, Download(context.Background(), http.DefaultClient, published, path))
+	verified, err := Verify(context.Background(), path, node.RegTest.Name)
+	require.NoError(t, err)
+	assert.Equal(t, info, verified)
+	_, err = Verify(context.Background(), path, node.MainNet.Name)
+	assert.Error(t, err)
+
+	// The local tables are published empty
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	var checks int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM "pn_price_checks";`).Scan(&checks))
+	db.Close()
+	assert.Equal(t, 0, checks)
+
+	// Trusted by the hash or by the nodes
+	require.Error(t, Trust(context.Background(), info, nil, nil))
+	require.NoError(t, Trust(context.Background(), info, &info.StateHash, nil))
+	var other factom.Bytes32
+	assert.Error(t, Trust(context.Background(), info, &other, nil))
+
+	good, bad := blocksAPI(t, info.StateHash), blocksAPI(t, other)
+	defer good.Close()
+	defer bad.Close()
+	assert.NoError(t, Trust(context.Background(), info, nil, []string{good.URL}))
+	assert.Error(t, Trust(context.Background(), info, nil, []string{good.URL, bad.URL}))
+
+	// A modified balance does not match the journal, and a modified journal
+	// does not match the state hash
+	tampered := filepath.Join(dir, "tampered.db")
+	require.NoError(t, Download(context.Background(), http.DefaultClient, published, tampered))
+	tamper(t, tampered, `DROP TRIGGER "trg_balance_journal_peg_balance";
+		UPDATE "pn_addresses" SET "peg_balance" = "peg_balance" + 1 WHERE "id" = 1;`)
+	_, err = Verify(context.Background(), tampered, node.RegTest.Name)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "balance journal")
+
+	require.NoError(t, os.Remove(tampered))
+	require.NoError(t, Download(context.Background(), http.DefaultClient, published, tampered))
+	tamper(t, tampered, `DROP TRIGGER "trg_balance_journal_peg_balance";
+		UPDATE "pn_addresses" SET "peg_balance" = "peg_balance" + 1 WHERE "address" =
+			(SELECT "address" FROM "pn_balance_journal" WHERE "token" = 'PEG' ORDER BY "height" DESC LIMIT 1);
+		UPDATE "pn_balance_journal" SET "balance" = "balance" + 1 WHERE "rowid" =
+			(SELECT "rowid" FROM "pn_balance_journal" WHERE "token" = 'PEG' ORDER BY "height" DESC LIMIT 1);`)
+	_, err = Verify(context.Background(), tampered, node.RegTest.Name)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "recomputes")
+
+	// A batch in holding is committed to by the hash of its entry height,
+	// and a batch without a history row is not accepted
+	for _, c := range []struct {
+		query, err string
+	}{
+		{`UPDATE "pn_transaction_batch_holding" SET "height" = "height" + 1 WHERE "id" = 1;`, "recomputes"},
+		{`UPDATE "pn_transaction_batch_holding" SET "entry_data" = "entry_data" || x'00' WHERE "id" = 1;`, "recomputes"},
+		{`INSERT INTO "pn_transaction_batch_holding" ("entry_hash", "entry_data", "height", "eblock_keymr", "unix_timestamp")
+			SELECT randomblob(32), "entry_data", "height", "eblock_keymr", "unix_timestamp"
+			FROM "pn_transaction_batch_holding" WHERE "id" = 1;`, "transaction history"},
+	} {
+		require.NoError(t, os.Remove(tampered))
+		require.NoError(t, Download(context.Background(), http.DefaultClient, published, tampered))
+		tamper(t, tampered, c.query)
+		_, err = Verify(context.Background(), tampered, node.RegTest.Name)
+		require.Error(t, err, c.query)
+		assert.Contains(t, err.Error(), c.err)
+	}
+}
+
+func tamper(t *testing.T, path, query string) {
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(query)
+	require.NoError(t, err)
+}
+
+// blocksAPI is a pegnetd api that reports the hash for every block
+func blocksAPI(t *testing.T, hash factom.Bytes32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Start uint32 `json:"start"`
+			} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "get-blocks", req.Method)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"start":%d,"stop":%[2]d,"blocks":[{"height":%[2]d,"statehash":"%s"}]}}`,
+			req.ID, req.Params.Start, hash)
+	}))
+}
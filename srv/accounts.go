@@ -0,0 +1,209 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// checkCanSign returns ErrorAccountDenied if the api key of the request can
+// not sign for the address
+func (s *APIServer) checkCanSign(ctx context.Context, addr factom.FAAddress) error {
+	if err := s.Node.Accounts.CanSign(callerFromContext(ctx).key, addr); err != nil {
+		rerr := ErrorAccountDenied
+		rerr.Data = err.Error()
+		return rerr
+	}
+	return nil
+}
+
+// policyError returns ErrorPolicyViolation for a pegnet.PolicyViolation, any
+// other error is an internal error
+func policyError(err error) jrpc.Error {
+	v, ok := err.(pegnet.PolicyViolation)
+	if !ok {
+		panic(err) // This is an internal error
+	}
+	rerr := ErrorPolicyViolation
+	rerr.Data = v.Error()
+	return rerr
+}
+
+// WalletAddress is an address of a wallet account. The node has no key for
+// the watch-only addresses.
+type WalletAddress struct {
+	Address   factom.FAAddress `json:"address"`
+	Account   string           `json:"account"`
+	Label     string           `json:"label,omitempty"`
+	WatchOnly bool             `json:"watchonly"`
+}
+
+// walletAddresses returns the addresses of the accounts the api key of the
+// request can see, by account. The configured accounts come first, and the
+// addresses of an account before its watch-only addresses. The watch-only
+// addresses of accounts that are not configured are only visible if the
+// signing rpcs are not restricted.
+func (s *APIServer) walletAddresses(ctx context.Context) []WalletAddress {
+	var names []string
+	accounts := make(map[string][]WalletAddress)
+	for _, account := range s.Node.Accounts.Visible(callerFromContext(ctx).key) {
+		names = append(names, account.Name)
+		accounts[account.Name] = []WalletAddress{}
+		for _, adr := range account.Addresses {
+			accounts[account.Name] = append(accounts[account.Name], WalletAddress{Address: adr, Account: account.Name})
+		}
+	}
+
+	// The watch-only addresses are sorted by account
+	watched, err := s.Node.Pegnet.SelectWatchAddresses(nil)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	for _, w := range watched {
+		if _, ok := accounts[w.Account]; !ok {
+			if s.Node.Accounts.Restricted() {
+				continue
+			}
+			names = append(names, w.Account)
+		}
+		accounts[w.Account] = append(accounts[w.Account], WalletAddress{Address: w.Address, Account: w.Account, Label: w.Label, WatchOnly: true})
+	}
+
+	addresses := []WalletAddress{}
+	for _, name := range names {
+		addresses = append(addresses, accounts[name]...)
+	}
+	return addresses
+}
+
+func (s *APIServer) listAddresses(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAccountBalances{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	addresses := []WalletAddress{}
+	for _, a := range s.walletAddresses(ctx) {
+		if params.Account == "" || a.Account == params.Account {
+			addresses = append(addresses, a)
+		}
+	}
+	if params.Account != "" && len(addresses) == 0 {
+		return ErrorNotFound
+	}
+	return addresses
+}
+
+// ResultAccountBalances are the balances of a wallet account, `Total` is the
+// sum of the balances of its addresses and `WatchOnly` the part of it that
+// the node can not spend
+type ResultAccountBalances struct {
+	Name      string                           `json:"name"`
+	Total     ResultPegnetTickerMap            `json:"total"`
+	WatchOnly ResultPegnetTickerMap            `json:"watchonly"`
+	Addresses map[string]ResultPegnetTickerMap `json:"addresses"`
+}
+
+// ResultGetAccountBalances are the balances of the accounts as of `Height`
+type ResultGetAccountBalances struct {
+	Height   uint32                  `json:"height"`
+	Accounts []ResultAccountBalances `json:"accounts"`
+}
+
+func (s *APIServer) getAccountBalances(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAccountBalances{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	res := ResultGetAccountBalances{Height: s.Node.GetCurrentSync(), Accounts: []ResultAccountBalances{}}
+	index := make(map[string]int)
+	for _, a := range s.walletAddresses(ctx) {
+		if params.Account != "" && a.Account != params.Account {
+			continue
+		}
+		i, ok := index[a.Account]
+		if !ok {
+			i = len(res.Accounts)
+			index[a.Account] = i
+			res.Accounts = append(res.Accounts, ResultAccountBalances{
+				Name:      a.Account,
+				Total:     make(ResultPegnetTickerMap),
+				WatchOnly: make(ResultPegnetTickerMap),
+				Addresses: make(map[string]ResultPegnetTickerMap),
+			})
+		}
+		r := &res.Accounts[i]
+		if _, ok := r.Addresses[a.Address.String()]; ok {
+			continue // an address is only counted once per account
+		}
+
+		bals, err := s.Node.Pegnet.SelectBalances(&a.Address)
+		if err == sql.ErrNoRows {
+			bals = make(map[fat2.PTicker]uint64)
+		} else if err != nil {
+			panic(err) // This is an internal error
+		}
+		for ticker, bal := range bals {
+			r.Total[ticker] += bal
+			if a.WatchOnly {
+				r.WatchOnly[ticker] += bal
+			}
+		}
+		r.Addresses[a.Address.String()] = bals
+	}
+	if params.Account != "" && len(res.Accounts) == 0 {
+		return ErrorNotFound
+	}
+	return res
+}
+
+func (s *APIServer) importWatchAddress(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsImportWatchAddress{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+
+	adr, _ := underlyingFA(params.Address)
+	if account, ok := s.Node.Accounts.Of(adr); ok {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("address: %s is a spendable address of the account %q", adr, account))
+	}
+	w := pegnet.WatchAddress{Address: adr, Account: params.Account, Label: params.Label, Added: s.Node.GetCurrentSync()}
+	if err := s.Node.Pegnet.InsertWatchAddress(nil, w); err != nil {
+		panic(err) // This is an internal error
+	}
+	w, err := s.Node.Pegnet.SelectWatchAddress(nil, adr)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	return w
+}
+
+func (s *APIServer) removeWatchAddress(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsWatchAddress{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+
+	adr, _ := underlyingFA(params.Address)
+	removed, err := s.Node.Pegnet.DeleteWatchAddress(nil, adr)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	if !removed {
+		return ErrorAddressNotFound
+	}
+	return true
+}
@@ -1,6 +1,12 @@
 package srv
 
-import "github.com/Factom-Asset-Tokens/factom"
+import (
+	"database/sql"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
 
 // underlyingFA will return the FA address given an input of type
 // FA, Fe, or FE
@@ -22,3 +28,41 @@ func underlyingFA(addr string) (factom.FAAddress, error) {
 	add, err := factom.NewFAAddress(addr)
 	return add, err
 }
+
+// validAddress checks the address is an FA, Fe, FE, or 0x ethereum address
+func validAddress(addr string) error {
+	if len(addr) > 2 && (addr[:2] == "0x" || addr[:2] == "0X") {
+		_, err := pegnet.ParseEthAddress(addr)
+		return err
+	}
+	_, err := underlyingFA(addr)
+	return err
+}
+
+// resolveAddress returns the FA address of any address accepted by
+// validAddress. Ethereum addresses are only known once they signed a
+// transaction with an RCD-e.
+func (s *APIServer) resolveAddress(addr string) (factom.FAAddress, error) {
+	if len(addr) > 2 && (addr[:2] == "0x" || addr[:2] == "0X") {
+		eth, err := pegnet.ParseEthAddress(addr)
+		if err != nil {
+			return factom.FAAddress{}, jrpc.ErrorInvalidParams("address: " + err.Error())
+		}
+		adr, err := s.Node.Pegnet.SelectAddressByEth(eth)
+		if err == sql.ErrNoRows {
+			return adr, ErrorAddressNotFound
+		}
+		return adr, err
+	}
+	return underlyingFA(addr)
+}
+
+// ethAddress returns the linked ethereum address of an address, or an empty
+// string for addresses without one
+func (s *APIServer) ethAddress(adr *factom.FAAddress) string {
+	eth, ok, err := s.Node.Pegnet.SelectEthAddress(adr)
+	if err != nil || !ok {
+		return ""
+	}
+	return eth.String()
+}
@@ -0,0 +1,149 @@
+package srv
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/keystore"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkAdminToken compares the token to the configured admin token
+func (s *APIServer) checkAdminToken(token string) error {
+	return s.checkToken(config.APIAdminToken, ErrorAdminDisabled, token)
+}
+
+// checkToken compares the token to the token configured at the key in
+// constant time. The rpcs of the token are disabled if none is configured.
+func (s *APIServer) checkToken(key string, disabled error, token string) error {
+	expected := s.Node.ConfigString(key)
+	if expected == "" {
+		return disabled
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return ErrorUnauthorized
+	}
+	return nil
+}
+
+// ResultReloadConfig lists the config keys whose values changed
+type ResultReloadConfig struct {
+	Changed []string `json:"changed"`
+}
+
+func (s *APIServer) reloadConfig(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsAdmin{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+
+	changed, err := s.Node.ReloadConfig()
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	return ResultReloadConfig{Changed: changed}
+}
+
+// ResultRateOverrides are the injected rates by height, of the heights that
+// are not synced yet
+type ResultRateOverrides map[uint32]map[string]uint64
+
+func newResultRateOverrides(overrides node.RateOverrides) ResultRateOverrides {
+	res := make(ResultRateOverrides, len(overrides))
+	for height, rates := range overrides {
+		res[height] = make(map[string]uint64, len(rates))
+		for ticker, value := range rates {
+			res[height][ticker.String()] = value
+		}
+	}
+	return res
+}
+
+func (s *APIServer) setRateOverride(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsSetRateOverride{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+	if !node.CanInjectRates() {
+		return ErrorRateInjectionDisabled
+	}
+
+	rates, _ := node.ParseRateOverride(params.Rates)
+	if err := s.Node.SetRateOverride(params.Height, rates); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return newResultRateOverrides(node.RateOverrides{params.Height: rates})
+}
+
+func (s *APIServer) getRateOverrides(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsAdmin{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+	if !node.CanInjectRates() {
+		return ErrorRateInjectionDisabled
+	}
+	return newResultRateOverrides(s.Node.RateOverrides())
+}
+
+// ResultKeystore is the state of the ec keystore
+type ResultKeystore struct {
+	Address string `json:"address"`
+	Locked  bool   `json:"locked"`
+}
+
+func newResultKeystore(ks *keystore.Keystore) ResultKeystore {
+	return ResultKeystore{Address: ks.Address().String(), Locked: ks.Locked()}
+}
+
+func (s *APIServer) unlockKeystore(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsUnlockKeystore{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+	if s.Node.Keystore == nil {
+		return ErrorKeystoreDisabled
+	}
+
+	if err := s.Node.Keystore.Unlock(params.Passphrase); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	log.WithField("address", s.Node.Keystore.Address()).Info("Unlocked the ec keystore")
+	return newResultKeystore(s.Node.Keystore)
+}
+
+func (s *APIServer) lockKeystore(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsAdmin{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+	if s.Node.Keystore == nil {
+		return ErrorKeystoreDisabled
+	}
+
+	s.Node.Keystore.Lock()
+	log.WithField("address", s.Node.Keystore.Address()).Info("Locked the ec keystore")
+	return newResultKeystore(s.Node.Keystore)
+}
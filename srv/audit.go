@@ -0,0 +1,127 @@
+package srv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader identifies the customer a request is made for. The key is
+// recorded, and checked by the signing rpcs if wallet.apikeys is set.
+const APIKeyHeader = "X-Api-Key"
+
+// caller is who made the http request of an rpc
+type caller struct {
+	Addr   string
+	APIKey string // the fingerprint of the key
+	key    string
+}
+
+type callerKey struct{}
+
+// callerHandler puts the caller of the request into the context of the rpcs
+func callerHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := caller{Addr: r.RemoteAddr}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			c.Addr = host
+		}
+		// Only a fingerprint of the key is kept, so the audit log can't leak
+		// the keys of the customers
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			c.APIKey, c.key = hex.EncodeToString(sum[:8]), key
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+func callerFromContext(ctx context.Context) caller {
+	c, _ := ctx.Value(callerKey{}).(caller)
+	return c
+}
+
+// audit records a send-transaction attempt. A failure to write the audit log
+// does not fail the rpc, the transaction may already have been submitted.
+func (s *APIServer) audit(ctx context.Context, a pegnet.SendAudit) {
+	c := callerFromContext(ctx)
+	a.Time, a.Caller, a.APIKey = time.Now(), c.Addr, c.APIKey
+	if _, err := s.Node.Pegnet.InsertSendAudit(nil, a); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"caller":  a.Caller,
+			"outcome": a.Outcome,
+		}).Error("failed to write the audit log")
+	}
+}
+
+// entryHash is the hash the entry will have once it is revealed
+func entryHash(e factom.Entry) *factom.Bytes32 {
+	if e.Hash != nil {
+		return e.Hash
+	}
+	data, err := e.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	hash := factom.ComputeEntryHash(data)
+	return &hash
+}
+
+// auditOutcome sorts the result of send-transaction into an outcome
+func auditOutcome(res interface{}, dryRun bool) (string, string) {
+	switch r := res.(type) {
+	case jrpc.Error:
+		if r.Code == ErrorPolicyViolation.Code {
+			return pegnet.AuditDenied, fmt.Sprintf("%s: %v", r.Message, r.Data)
+		}
+		if r.Data != nil {
+			return pegnet.AuditRejected, fmt.Sprintf("%s: %v", r.Message, r.Data)
+		}
+		return pegnet.AuditRejected, r.Message
+	case error:
+		return pegnet.AuditFailed, r.Error()
+	}
+	if dryRun {
+		return pegnet.AuditDryRun, ""
+	}
+	return pegnet.AuditSubmitted, ""
+}
+
+// ResultGetAuditLog is a page of the send-transaction attempts
+type ResultGetAuditLog struct {
+	Entries []pegnet.SendAudit `json:"entries"`
+	Count   int                `json:"count"`
+}
+
+func (s *APIServer) getAuditLog(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAuditLog{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkAdminToken(params.Token); err != nil {
+		return err
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = pegnet.AuditLimit
+	}
+	entries, total, err := s.Node.Pegnet.SelectSendAudit(nil, time.Unix(params.Since, 0), params.Offset, limit)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if entries == nil {
+		entries = []pegnet.SendAudit{}
+	}
+	return ResultGetAuditLog{Entries: entries, Count: total}
+}
@@ -0,0 +1,116 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+)
+
+// cacheableMethods are the rpcs whose results only change when a new block
+// is synced. Methods that include pending data, touch factomd, or have side
+// effects are never cached.
+var cacheableMethods = map[string]bool{
+	"get-rich-list":          true,
+	"get-global-rich-list":   true,
+	"get-holders":            true,
+	"get-distribution-stats": true,
+	"get-miner-distribution": true,
+	"get-opr-stats":          true,
+	"get-miner":              true,
+	"get-miners":             true,
+	"get-bank":               true,
+	"get-pegnet-issuance":    true,
+	"get-supply":             true,
+	"get-supply-history":     true,
+	"get-supply-composition": true,
+	"get-ledger":             true,
+	"get-network-stats":      true,
+	"get-conversion-volume":  true,
+	"get-burns":              true,
+	"get-malformed-burns":    true,
+	"get-pegnet-rates":       true,
+	"get-rate-gaps":          true,
+	"get-rate-changes":       true,
+}
+
+// responseCache holds rpc results keyed by method and params. All entries
+// belong to the same sync height, the cache is emptied once it advances.
+type responseCache struct {
+	sync.Mutex
+	size    int
+	height  uint32
+	entries map[string]interface{}
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{size: size, entries: make(map[string]interface{})}
+}
+
+// reset empties the cache if the height changed, the caller holds the lock
+func (c *responseCache) reset(height uint32) {
+	if c.height != height {
+		c.height = height
+		c.entries = make(map[string]interface{})
+	}
+}
+
+func (c *responseCache) lookup(height uint32, key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.reset(height)
+	res, ok := c.entries[key]
+	return res, ok
+}
+
+func (c *responseCache) store(height uint32, key string, res interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	if height < c.height {
+		return // computed before the sync advanced
+	}
+	c.reset(height)
+	if len(c.entries) >= c.size {
+		// evict an arbitrary entry, the cache is wiped every block anyway
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = res
+}
+
+// cached serves repeated calls of the method with the same params from the
+// cache until the sync height advances. Errors are not cached.
+func (s *APIServer) cached(name string, method jrpc.MethodFunc) jrpc.MethodFunc {
+	if s.cache == nil || s.cache.size <= 0 {
+		return method
+	}
+	return func(ctx context.Context, params json.RawMessage) interface{} {
+		height := s.Node.GetCurrentSync()
+		key := cacheKey(name, params)
+		if res, ok := s.cache.lookup(height, key); ok {
+			return res
+		}
+
+		res := method(ctx, params)
+		if _, ok := res.(error); !ok {
+			s.cache.store(height, key, res)
+		}
+		return res
+	}
+}
+
+// cacheKey identifies a call, insignificant whitespace in the params is
+// ignored
+func cacheKey(name string, params json.RawMessage) string {
+	var buf bytes.Buffer
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	if err := json.Compact(&buf, params); err != nil {
+		buf.Write(params)
+	}
+	return buf.String()
+}
@@ -0,0 +1,340 @@
+package srv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The response encodings. The format is picked by the "format" query
+// parameter, eg: "/v1?format=cbor", or else the Accept header. Requests are
+// always json.
+//
+// The server still encodes every response to json and then transcodes it,
+// so the binary formats cost the server more than json. They only save on
+// the size of the responses and on the decoding of the client.
+const (
+	FormatJSON    = "json"
+	FormatCBOR    = "cbor"
+	FormatMsgPack = "msgpack"
+)
+
+var formatContentTypes = map[string]string{
+	FormatCBOR:    "application/cbor",
+	FormatMsgPack: "application/msgpack",
+}
+
+// responseFormat returns the negotiated encoding of the response
+func responseFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case FormatJSON, FormatCBOR, FormatMsgPack:
+			return format, nil
+		}
+		return "", fmt.Errorf("unknown format %q, must be one of json, cbor, msgpack", format)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediatype, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediatype {
+		case "application/cbor":
+			return FormatCBOR, nil
+		case "application/msgpack", "application/x-msgpack":
+			return FormatMsgPack, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// encodingHandler transcodes the json responses into the negotiated binary
+// encoding. Every line of json becomes one encoded value, so the streaming
+// endpoints return a sequence of values.
+func encodingHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		format, err := responseFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if format == FormatJSON {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tw := &transcodingResponseWriter{ResponseWriter: w, format: format}
+		next.ServeHTTP(tw, r)
+		tw.finish()
+	})
+}
+
+// transcodingResponseWriter buffers the json written to it until a line is
+// complete and writes it in the binary encoding instead. Responses that are
+// not json, like plain text errors, are passed through.
+type transcodingResponseWriter struct {
+	http.ResponseWriter
+	format string
+
+	wroteHeader bool
+	passthrough bool
+	buf         bytes.Buffer
+}
+
+func (w *transcodingResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	contentType := w.Header().Get("Content-Type")
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	if contentType != "" && mediatype != "application/json" && mediatype != "application/x-ndjson" {
+		w.passthrough = true
+	} else {
+		w.Header().Set("Content-Type", formatContentTypes[w.format])
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *transcodingResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		line := w.buf.Next(i + 1)
+		if err := w.transcode(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush sends the complete values, for the streaming endpoints
+func (w *transcodingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish writes the remainder that did not end in a newline
+func (w *transcodingResponseWriter) finish() {
+	if w.passthrough || w.buf.Len() == 0 {
+		return
+	}
+	_ = w.transcode(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+func (w *transcodingResponseWriter) transcode(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w.ResponseWriter)
+	switch w.format {
+	case FormatCBOR:
+		writeCBOR(out, value)
+	case FormatMsgPack:
+		writeMsgPack(out, value)
+	}
+	return out.Flush()
+}
+
+// numberValue returns the json number as an int64, uint64, or float64
+func numberValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return u
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// sortedKeys returns the keys of a json object in order, so the output of
+// the same value is always the same
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeCBOR encodes a decoded json value as CBOR, RFC 7049
+func writeCBOR(w io.Writer, value interface{}) {
+	head := func(major byte, n uint64) {
+		var buf [9]byte
+		switch {
+		case n < 24:
+			buf[0] = major<<5 | byte(n)
+			_, _ = w.Write(buf[:1])
+		case n <= math.MaxUint8:
+			buf[0], buf[1] = major<<5|24, byte(n)
+			_, _ = w.Write(buf[:2])
+		case n <= math.MaxUint16:
+			buf[0] = major<<5 | 25
+			binary.BigEndian.PutUint16(buf[1:], uint16(n))
+			_, _ = w.Write(buf[:3])
+		case n <= math.MaxUint32:
+			buf[0] = major<<5 | 26
+			binary.BigEndian.PutUint32(buf[1:], uint32(n))
+			_, _ = w.Write(buf[:5])
+		default:
+			buf[0] = major<<5 | 27
+			binary.BigEndian.PutUint64(buf[1:], n)
+			_, _ = w.Write(buf[:9])
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		_, _ = w.Write([]byte{0xf6})
+	case bool:
+		if v {
+			_, _ = w.Write([]byte{0xf5})
+		} else {
+			_, _ = w.Write([]byte{0xf4})
+		}
+	case json.Number:
+		writeCBOR(w, numberValue(v))
+	case int64:
+		if v >= 0 {
+			head(0, uint64(v))
+		} else {
+			head(1, uint64(-(v + 1)))
+		}
+	case uint64:
+		head(0, v)
+	case float64:
+		var buf [9]byte
+		buf[0] = 0xfb
+		binary.BigEndian.PutUint64(buf[1:], math.Float64bits(v))
+		_, _ = w.Write(buf[:])
+	case string:
+		head(3, uint64(len(v)))
+		_, _ = io.WriteString(w, v)
+	case []interface{}:
+		head(4, uint64(len(v)))
+		for _, e := range v {
+			writeCBOR(w, e)
+		}
+	case map[string]interface{}:
+		head(5, uint64(len(v)))
+		for _, k := range sortedKeys(v) {
+			writeCBOR(w, k)
+			writeCBOR(w, v[k])
+		}
+	}
+}
+
+// writeMsgPack encodes a decoded json value as MessagePack
+func writeMsgPack(w io.Writer, value interface{}) {
+	// sized writes the type byte followed by n as a big endian integer of
+	// the given number of bytes
+	sized := func(typ byte, n uint64, size int) {
+		var buf [9]byte
+		buf[0] = typ
+		for i := 0; i < size; i++ {
+			buf[size-i] = byte(n >> (8 * uint(i)))
+		}
+		_, _ = w.Write(buf[:size+1])
+	}
+	// length writes the header of a string, array, or map
+	length := func(fix byte, fixmax int, typ8, typ16, typ32 byte, n int) {
+		switch {
+		case n < fixmax:
+			_, _ = w.Write([]byte{fix | byte(n)})
+		case typ8 != 0 && n <= math.MaxUint8:
+			sized(typ8, uint64(n), 1)
+		case n <= math.MaxUint16:
+			sized(typ16, uint64(n), 2)
+		default:
+			sized(typ32, uint64(n), 4)
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		_, _ = w.Write([]byte{0xc0})
+	case bool:
+		if v {
+			_, _ = w.Write([]byte{0xc3})
+		} else {
+			_, _ = w.Write([]byte{0xc2})
+		}
+	case json.Number:
+		writeMsgPack(w, numberValue(v))
+	case int64:
+		switch {
+		case v >= 0:
+			writeMsgPack(w, uint64(v))
+		case v >= -32:
+			_, _ = w.Write([]byte{byte(int8(v))})
+		case v >= math.MinInt8:
+			sized(0xd0, uint64(v), 1)
+		case v >= math.MinInt16:
+			sized(0xd1, uint64(v), 2)
+		case v >= math.MinInt32:
+			sized(0xd2, uint64(v), 4)
+		default:
+			sized(0xd3, uint64(v), 8)
+		}
+	case uint64:
+		switch {
+		case v < 128:
+			_, _ = w.Write([]byte{byte(v)})
+		case v <= math.MaxUint8:
+			sized(0xcc, v, 1)
+		case v <= math.MaxUint16:
+			sized(0xcd, v, 2)
+		case v <= math.MaxUint32:
+			sized(0xce, v, 4)
+		default:
+			sized(0xcf, v, 8)
+		}
+	case float64:
+		sized(0xcb, math.Float64bits(v), 8)
+	case string:
+		length(0xa0, 32, 0xd9, 0xda, 0xdb, len(v))
+		_, _ = io.WriteString(w, v)
+	case []interface{}:
+		length(0x90, 16, 0, 0xdc, 0xdd, len(v))
+		for _, e := range v {
+			writeMsgPack(w, e)
+		}
+	case map[string]interface{}:
+		length(0x80, 16, 0, 0xde, 0xdf, len(v))
+		for _, k := range sortedKeys(v) {
+			writeMsgPack(w, k)
+			writeMsgPack(w, v[k])
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package srv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonValue decodes json the way the transcoder does, with the numbers as
+// int64, uint64, or float64
+func jsonValue(t *testing.T, data string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	require.NoError(t, dec.Decode(&value))
+	var convert func(v interface{}) interface{}
+	convert = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case json.Number:
+			return numberValue(v)
+		case []interface{}:
+			for i := range v {
+				v[i] = convert(v[i])
+			}
+		case map[string]interface{}:
+			for k := range v {
+				v[k] = convert(v[k])
+			}
+		}
+		return v
+	}
+	return convert(value)
+}
+
+// uintValue returns the unsigned integer as an int64 if it fits, like
+// numberValue
+func uintValue(n uint64) interface{} {
+	if n <= math.MaxInt64 {
+		return int64(n)
+	}
+	return n
+}
+
+// decodeCBOR decodes the subset of CBOR that writeCBOR encodes
+func decodeCBOR(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := b>>5, b&0x1f
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		case 27:
+			var buf [8]byte
+			if _, err := io.ReadFull(r, buf[:]); err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(buf[:])), nil
+		}
+		return nil, fmt.Errorf("unexpected simple value %d", info)
+	}
+
+	n := uint64(info)
+	if info >= 24 {
+		size := 1 << (info - 24)
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		n = 0
+		for _, c := range buf {
+			n = n<<8 | uint64(c)
+		}
+	}
+
+	switch major {
+	case 0:
+		return uintValue(n), nil
+	case 1:
+		return -1 - int64(n), nil
+	case 3:
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	case 4:
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = decodeCBOR(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case 5:
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[k.(string)], err = decodeCBOR(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unexpected major type %d", major)
+}
+
+// decodeMsgPack decodes the subset of MessagePack that writeMsgPack encodes
+func decodeMsgPack(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	read := func(size int) (uint64, error) {
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, c := range buf {
+			n = n<<8 | uint64(c)
+		}
+		return n, nil
+	}
+	str := func(n uint64) (interface{}, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return string(buf), err
+	}
+	arr := func(n uint64) (interface{}, error) {
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = decodeMsgPack(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	obj := func(n uint64) (interface{}, error) {
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := decodeMsgPack(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[k.(string)], err = decodeMsgPack(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	switch {
+	case b < 0x80:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return str(uint64(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return arr(uint64(b & 0x0f))
+	case b&0xf0 == 0x80:
+		return obj(uint64(b & 0x0f))
+	}
+
+	sizes := map[byte]int{0xcc: 1, 0xcd: 2, 0xce: 4, 0xcf: 8, 0xd0: 1, 0xd1: 2, 0xd2: 4, 0xd3: 8, 0xcb: 8,
+		0xd9: 1, 0xda: 2, 0xdb: 4, 0xdc: 2, 0xdd: 4, 0xde: 2, 0xdf: 4}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	}
+	size, ok := sizes[b]
+	if !ok {
+		return nil, fmt.Errorf("unexpected type byte %x", b)
+	}
+	n, err := read(size)
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return uintValue(n), nil
+	case 0xd0:
+		return int64(int8(n)), nil
+	case 0xd1:
+		return int64(int16(n)), nil
+	case 0xd2:
+		return int64(int32(n)), nil
+	case 0xd3:
+		return int64(n), nil
+	case 0xcb:
+		return math.Float64frombits(n), nil
+	case 0xd9, 0xda, 0xdb:
+		return str(n)
+	case 0xdc, 0xdd:
+		return arr(n)
+	default:
+		return obj(n)
+	}
+}
+
+// largeMap is a json object with n entries
+func largeMap(n int) string {
+	fields := make([]string, n)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`"k%03d": %d`, i, i)
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+func TestEncoding_RoundTrip(t *testing.T) {
+	values := []string{
+		`null`, `true`, `false`, `0`, `-1`, `23`, `24`, `-24`, `-25`, `255`, `256`, `65535`, `65536`,
+		`4294967295`, `4294967296`, `9223372036854775807`, `18446744073709551615`, `-9223372036854775808`,
+		`1.5`, `-0.25`, `1e300`, `""`, `"pegnet"`, `"` + strings.Repeat("x", 300) + `"`, `[]`, `{}`,
+		`{"result": {"PEG": 2150000, "pUSD": -12, "rates": [1.1, 2, "three", null, {"nested": [true]}]}}`,
+		largeMap(15), largeMap(16), largeMap(300), `[` + strings.Repeat(`1,`, 70000) + `1]`,
+	}
+	decoders := map[string]func(*bytes.Reader) (interface{}, error){
+		FormatCBOR:    decodeCBOR,
+		FormatMsgPack: decodeMsgPack,
+	}
+	for format, decode := range decoders {
+		for _, value := range values {
+			w := httptest.NewRecorder()
+			handler := encodingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, value+"\n")
+			}))
+			handler.ServeHTTP(w, httptest.NewRequest("POST", "/v1?format="+format, nil))
+			assert.Equal(t, formatContentTypes[format], w.Header().Get("Content-Type"))
+
+			r := bytes.NewReader(w.Body.Bytes())
+			decoded, err := decode(r)
+			require.NoError(t, err, format)
+			assert.Equal(t, jsonValue(t, value), decoded, "%s %.40s", format, value)
+			assert.Zero(t, r.Len(), format)
+		}
+	}
+}
+
+// The examples of RFC 7049, appendix A, and the boundaries of every integer
+// width. Floats are always encoded as doubles.
+func TestWriteCBOR(t *testing.T) {
+	for _, vector := range []struct {
+		JSON string
+		Hex  string
+	}{
+		{`0`, "00"}, {`1`, "01"}, {`10`, "0a"}, {`23`, "17"}, {`24`, "1818"}, {`25`, "1819"},
+		{`100`, "1864"}, {`255`, "18ff"}, {`256`, "190100"}, {`1000`, "1903e8"}, {`65535`, "19ffff"},
+		{`65536`, "1a00010000"}, {`1000000`, "1a000f4240"}, {`4294967295`, "1affffffff"},
+		{`4294967296`, "1b0000000100000000"}, {`1000000000000`, "1b000000e8d4a51000"},
+		{`18446744073709551615`, "1bffffffffffffffff"},
+		{`-1`, "20"}, {`-10`, "29"}, {`-24`, "37"}, {`-25`, "3818"}, {`-100`, "3863"}, {`-256`, "38ff"},
+		{`-257`, "390100"}, {`-1000`, "3903e7"}, {`-65537`, "3a00010000"},
+		{`-9223372036854775808`, "3b7fffffffffffffff"},
+		{`1.1`, "fb3ff199999999999a"}, {`1.0e+300`, "fb7e37e43c8800759c"}, {`-4.1`, "fbc010666666666666"},
+		{`false`, "f4"}, {`true`, "f5"}, {`null`, "f6"},
+		{`""`, "60"}, {`"a"`, "6161"}, {`"IETF"`, "6449455446"}, {`"\"\\"`, "62225c"}, {`"ü"`, "62c3bc"},
+		{`[]`, "80"}, {`[1, 2, 3]`, "83010203"}, {`[1, [2, 3], [4, 5]]`, "8301820203820405"},
+		{`{}`, "a0"}, {`{"a": 1, "b": [2, 3]}`, "a26161016162820203"}, {`["a", {"b": "c"}]`, "826161a161626163"},
+	} {
+		var buf bytes.Buffer
+		writeCBOR(&buf, jsonValue(t, vector.JSON))
+		assert.Equal(t, vector.Hex, hex.EncodeToString(buf.Bytes()), vector.JSON)
+	}
+
+	// The headers of large values
+	for _, vector := range []struct {
+		JSON string
+		Hex  string
+	}{
+		{`"` + strings.Repeat("a", 24) + `"`, "7818"}, {`"` + strings.Repeat("a", 256) + `"`, "790100"},
+		{`[` + strings.Repeat(`1,`, 23) + `1]`, "9818"}, {largeMap(23), "b7"}, {largeMap(24), "b818"},
+		{largeMap(256), "b90100"},
+	} {
+		var buf bytes.Buffer
+		writeCBOR(&buf, jsonValue(t, vector.JSON))
+		assert.Equal(t, vector.Hex, hex.EncodeToString(buf.Bytes()[:len(vector.Hex)/2]), "%.40s", vector.JSON)
+	}
+}
+
+// The formats of the MessagePack spec at the boundaries of every width
+func TestWriteMsgPack(t *testing.T) {
+	for _, vector := range []struct {
+		JSON string
+		Hex  string
+	}{
+		{`0`, "00"}, {`127`, "7f"}, {`128`, "cc80"}, {`255`, "ccff"}, {`256`, "cd0100"}, {`65535`, "cdffff"},
+		{`65536`, "ce00010000"}, {`4294967295`, "ceffffffff"}, {`4294967296`, "cf0000000100000000"},
+		{`18446744073709551615`, "cfffffffffffffffff"},
+		{`-1`, "ff"}, {`-32`, "e0"}, {`-33`, "d0df"}, {`-128`, "d080"}, {`-129`, "d1ff7f"},
+		{`-32768`, "d18000"}, {`-32769`, "d2ffff7fff"}, {`-2147483648`, "d280000000"},
+		{`-2147483649`, "d3ffffffff7fffffff"}, {`-9223372036854775808`, "d38000000000000000"},
+		{`1.1`, "cb3ff199999999999a"}, {`-4.1`, "cbc010666666666666"},
+		{`null`, "c0"}, {`false`, "c2"}, {`true`, "c3"},
+		{`""`, "a0"}, {`"a"`, "a161"}, {`[]`, "90"}, {`[1, 2, 3]`, "93010203"},
+		{`{}`, "80"}, {`{"a": 1, "b": [2, 3]}`, "82a16101a162920203"},
+	} {
+		var buf bytes.Buffer
+		writeMsgPack(&buf, jsonValue(t, vector.JSON))
+		assert.Equal(t, vector.Hex, hex.EncodeToString(buf.Bytes()), vector.JSON)
+	}
+
+	// The headers of large values
+	for _, vector := range []struct {
+		JSON string
+		Hex  string
+	}{
+		{`"` + strings.Repeat("a", 31) + `"`, "bf"}, {`"` + strings.Repeat("a", 32) + `"`, "d920"},
+		{`"` + strings.Repeat("a", 256) + `"`, "da0100"}, {`"` + strings.Repeat("a", 65536) + `"`, "db00010000"},
+		{`[` + strings.Repeat(`1,`, 14) + `1]`, "9f"}, {`[` + strings.Repeat(`1,`, 15) + `1]`, "dc0010"},
+		{`[` + strings.Repeat(`1,`, 65535) + `1]`, "dd00010000"},
+		{largeMap(15), "8f"}, {largeMap(16), "de0010"}, {largeMap(65536), "df00010000"},
+	} {
+		var buf bytes.Buffer
+		writeMsgPack(&buf, jsonValue(t, vector.JSON))
+		assert.Equal(t, vector.Hex, hex.EncodeToString(buf.Bytes()[:len(vector.Hex)/2]), "%.40s", vector.JSON)
+	}
+}
+
+func TestEncoding_Passthrough(t *testing.T) {
+	handler := encodingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, "{\"height\": 1}\n{\"height\"")
+		_, _ = io.WriteString(w, ": 2}\n")
+	}))
+
+	// Errors that are not json are not transcoded
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/error?format=cbor", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "too many requests\n", w.Body.String())
+
+	// Every line of a stream is a value, even if it is written in parts
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/stream", nil)
+	req.Header.Set("Accept", "text/html, application/x-msgpack")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	assert.Equal(t, "81a668656967687401"+"81a668656967687402", hex.EncodeToString(w.Body.Bytes()))
+
+	// json is not touched, unknown formats are refused
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/stream?format=json", nil))
+	assert.Equal(t, "{\"height\": 1}\n{\"height\": 2}\n", w.Body.String())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/stream?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
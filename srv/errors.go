@@ -40,4 +40,30 @@ var (
 		"address may be invalid, or not yet tracked")
 	ErrorNotFound = jrpc.NewError(-32809, "Not Found",
 		"could not find what you were looking for")
+	ErrorUnauthorized = jrpc.NewError(-32810, "Unauthorized",
+		"the token is missing or invalid")
+	ErrorSchedulerDisabled = jrpc.NewError(-32811, "Scheduler Disabled",
+		"pegnetd is not configured with a scheduler token")
+	ErrorServerBusy = jrpc.NewError(-32812, "Server Busy",
+		"all workers for expensive calls are busy, try again later")
+	// ErrorInternal is returned if a method panics, the details are logged
+	ErrorInternal = jrpc.NewError(jrpc.ErrorCodeInternal, jrpc.ErrorMessageInternal,
+		"the call failed unexpectedly")
+	ErrorAdminDisabled = jrpc.NewError(-32813, "Admin Disabled",
+		"pegnetd is not configured with an admin token")
+	ErrorRegtestDisabled = jrpc.NewError(-32814, "Regtest Disabled",
+		"pegnetd is not running in the regtest mode")
+	ErrorRateInjectionDisabled = jrpc.NewError(-32815, "Rate Injection Disabled",
+		"rates can only be injected on networks other than MainNet")
+	ErrorKeystoreDisabled = jrpc.NewError(-32816, "Keystore Disabled",
+		"pegnetd is not configured with an ec keystore")
+	ErrorAccountDenied = jrpc.NewError(-32817, "Account Denied",
+		"the api key can not sign for the address")
+	ErrorPolicyViolation = jrpc.NewError(-32818, "Policy Violation",
+		"a signing policy does not allow the transaction")
+	ErrorPriceCheckDisabled = jrpc.NewError(-32819, "Price Check Disabled",
+		"pegnetd is not configured with price check sources")
+	// ErrorPendingUnavailable has the reason factomd failed as its data
+	ErrorPendingUnavailable = jrpc.NewError(-32820, "Pending Transactions Unavailable",
+		"the pending entries could not be fetched from factomd")
 )
@@ -0,0 +1,29 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pegnet/pegnetd/node"
+)
+
+// HealthPath responds 200 while the sync is healthy, and 503 once the
+// watchdog detected a stalled sync. The body is the status of the watchdog.
+const HealthPath = "/health"
+
+func (s *APIServer) healthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := node.WatchdogStatus{Healthy: true, Height: s.Node.GetCurrentSync()}
+		if s.Node.Watchdog != nil {
+			status = s.Node.Watchdog.Status()
+			status.Height = s.Node.GetCurrentSync()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
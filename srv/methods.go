@@ -28,45 +28,114 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"runtime"
 	"sort"
+	"strings"
+	"time"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
 	"github.com/Factom-Asset-Tokens/factom"
 	sqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/pegnet/pegnet/modules/conversions"
+	"github.com/pegnet/pegnetd/activation"
 	"github.com/pegnet/pegnetd/config"
 	"github.com/pegnet/pegnetd/fat/fat2"
 	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/node/keystore"
 	"github.com/pegnet/pegnetd/node/pegnet"
+	log "github.com/sirupsen/logrus"
 )
 
 func (s *APIServer) jrpcMethods() jrpc.MethodMap {
 	return jrpc.MethodMap{
 		"get-rich-list":          s.getRichList,
 		"get-global-rich-list":   s.getGlobalRichList,
+		"get-holders":            s.getHolders,
+		"get-distribution-stats": s.getDistributionStats,
 		"get-miner-distribution": s.getMiningDominance,
+		"get-opr-stats":          s.getOPRStats,
+		"get-miner":              s.getMiner,
+		"get-miners":             s.getMiners,
 		"get-bank":               s.getBank,
 		"get-transactions":       s.getTransactions(false),
 		"get-transaction-status": s.getTransactionStatus,
 		"get-transaction":        s.getTransactions(true),
+		"get-conversion-result":  s.getConversionResult,
+		"export-statement":       s.exportStatement,
 		"get-pegnet-balances":    s.getPegnetBalances,
+		"get-account-balances":   s.getAccountBalances,
+		"list-addresses":         s.listAddresses,
+		"get-address-stats":      s.getAddressStats,
+		"get-address-events":     s.getAddressEvents,
 		"get-pegnet-issuance":    s.getPegnetIssuance,
+		"get-supply":             s.getSupply,
+		"get-supply-history":     s.getSupplyHistory,
+		"get-supply-composition": s.getSupplyComposition,
+		"get-ledger":             s.getLedger,
+		"get-balance-history":    s.getBalanceHistory,
+		"get-network-stats":      s.getNetworkStats,
+		"get-conversion-volume":  s.getConversionVolume,
+		"get-blocks":             s.getBlocks,
+		"get-block":              s.getBlock,
+		"get-burns":              s.getBurns,
+		"get-malformed-burns":    s.getMalformedBurns,
 		"send-transaction":       s.sendTransaction,
-
-		"get-sync-status": s.getSyncStatus,
-		"properties":      s.properties,
+		"get-audit-log":          s.getAuditLog,
+		"reload-config":          s.reloadConfig,
+		"set-rate-override":      s.setRateOverride,
+		"get-rate-overrides":     s.getRateOverrides,
+		"unlock-keystore":        s.unlockKeystore,
+		"lock-keystore":          s.lockKeystore,
+		"import-watch-address":   s.importWatchAddress,
+		"remove-watch-address":   s.removeWatchAddress,
+
+		"get-transactions-by-hashes": s.getTransactionsByHashes,
+		"search":                     s.search,
+
+		"add-deposit-address":    s.addDepositAddress,
+		"remove-deposit-address": s.removeDepositAddress,
+		"get-deposit-addresses":  s.getDepositAddresses,
+		"list-deposits":          s.listDeposits,
+		"get-alerts":             s.getAlerts,
+
+		"add-schedule":    s.addSchedule,
+		"remove-schedule": s.removeSchedule,
+		"list-schedules":  s.listSchedules,
+
+		"get-sync-status":  s.getSyncStatus,
+		"get-ec-status":    s.getECStatus,
+		"get-price-checks": s.getPriceChecks,
+		"properties":       s.properties,
 
 		"get-pegnet-rates": s.getPegnetRates,
+		"get-rate-gaps":    s.getRateGaps,
+		"get-rate-changes": s.getRateChanges,
+
+		"regtest-mine":      s.regtestMine,
+		"regtest-set-rates": s.regtestSetRates,
+		"regtest-fund":      s.regtestFund,
 	}
 
 }
 
+// MethodNames are the sorted names of the rpcs of the api
+func MethodNames() []string {
+	methods := (&APIServer{}).jrpcMethods()
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type PegnetdProperties struct {
 	BuildVersion  string `json:"buildversion"`
 	BuildCommit   string `json:"buildcommit"`
 	SQLiteVersion string `json:"sqliteversion"`
 	GolangVersion string `json:"golang"`
+	Network       string `json:"network,omitempty"`
 }
 
 func (APIServer) properties(_ context.Context, data json.RawMessage) interface{} {
@@ -76,6 +145,7 @@ func (APIServer) properties(_ context.Context, data json.RawMessage) interface{}
 		BuildCommit:   config.CompiledInBuild,
 		SQLiteVersion: sqliteVersion,
 		GolangVersion: runtime.Version(),
+		Network:       node.ActiveNetwork.Name,
 	}
 }
 
@@ -86,24 +156,92 @@ func (s *APIServer) getBank(ctx context.Context, data json.RawMessage) interface
 		return err
 	}
 
-	if params.Height == 0 {
+	latest := params.Height == 0
+	if latest {
 		synced, err := s.Node.Pegnet.SelectSynced(ctx, s.Node.Pegnet.DB)
+		if err == sql.ErrNoRows {
+			return ErrorNotFound // nothing is synced yet
+		}
 		if err != nil {
 			return err
 		}
 		params.Height = int32(synced.Synced)
 	}
 
-	if params.Height < int32(node.V4OPRUpdate) {
-		return jrpc.ErrorInvalidParams(fmt.Sprintf("the height %d is below the activation height (%d) of this feature", params.Height, node.V4OPRUpdate))
+	if act := activation.Height(activation.V4OPRUpdate); params.Height < 0 || !activation.Active(activation.V4OPRUpdate, uint32(params.Height)) {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("the height %d is below the activation height (%d) of this feature", params.Height, act))
 	}
-	result, err := s.Node.Pegnet.SelectBankEntry(nil, params.Height)
+	entry, err := s.Node.Pegnet.SelectBankEntry(nil, params.Height)
 	if err != nil {
 		return err
 	}
+	result := ResultGetBank{BankEntry: entry}
+	if entry.Height != -1 {
+		result.Remaining = entry.Remaining()
+		result.Proration = entry.Proration()
+		result.Prorated = result.Proration < 1
+	}
+
+	if latest {
+		pending, err := s.pendingPEGRequests(ctx)
+		if err != nil {
+			return err
+		}
+		result.PendingRequested = pending
+		result.ExpectedProration = 1
+		if pending > int64(pegnet.BankBaseAmount) {
+			result.ExpectedProration = float64(pegnet.BankBaseAmount) / float64(pending)
+		}
+	}
 	return result
 }
 
+// ResultGetBank is the bank entry of a height with the proration that was
+// applied to conversions into PEG. For the latest height, the PEG requested
+// by the conversions in holding is included to estimate the proration of the
+// next block. The estimate uses the latest rates, while the conversions are
+// executed at the rates of the next block.
+type ResultGetBank struct {
+	pegnet.BankEntry
+	Remaining int64
+	Prorated  bool
+	Proration float64
+
+	PendingRequested  int64   `json:",omitempty"`
+	ExpectedProration float64 `json:",omitempty"`
+}
+
+// pendingPEGRequests returns the amount of PEG requested by the conversions
+// waiting in holding for the next graded block, valued at the latest rates
+func (s *APIServer) pendingPEGRequests(ctx context.Context) (int64, error) {
+	synced := s.Node.GetCurrentSync()
+	rates, rateHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(ctx, s.Node.Pegnet.DB, synced+1)
+	if err != nil || rateHeight == 0 {
+		return 0, err
+	}
+
+	var total int64
+	for height := rateHeight; height <= synced; height++ {
+		batches, err := s.Node.Pegnet.SelectTransactionBatchesInHoldingAtHeight(uint64(height))
+		if err != nil {
+			return 0, err
+		}
+		for _, batch := range batches {
+			for _, tx := range batch.Transactions {
+				if !tx.IsPEGRequest() {
+					continue
+				}
+				peg, err := conversions.Convert(int64(tx.Input.Amount), rates[tx.Input.Type], rates[tx.Conversion])
+				if err != nil {
+					continue // the conversion will be rejected
+				}
+				total += peg
+			}
+		}
+	}
+	return total, nil
+}
+
 // getMiningDominance returns the representation of rewarded miners for a given
 // block range
 func (s *APIServer) getMiningDominance(ctx context.Context, data json.RawMessage) interface{} {
@@ -117,6 +255,9 @@ func (s *APIServer) getMiningDominance(ctx context.Context, data json.RawMessage
 		// If the start is 0, and stop is negative, then the user is requesting
 		// the last STOP blocks
 		synced, err := s.Node.Pegnet.SelectSynced(ctx, s.Node.Pegnet.DB)
+		if err == sql.ErrNoRows {
+			return ErrorNotFound // nothing is synced yet
+		}
 		if err != nil {
 			return err
 		}
@@ -125,6 +266,9 @@ func (s *APIServer) getMiningDominance(ctx context.Context, data json.RawMessage
 	} else if params.Stop == 0 {
 		// If the stop is 0, then the stop is the end.
 		synced, err := s.Node.Pegnet.SelectSynced(ctx, s.Node.Pegnet.DB)
+		if err == sql.ErrNoRows {
+			return ErrorNotFound // nothing is synced yet
+		}
 		if err != nil {
 			return err
 		}
@@ -138,9 +282,97 @@ func (s *APIServer) getMiningDominance(ctx context.Context, data json.RawMessage
 	return result
 }
 
+// getOPRStats returns the winning records, record counts, and miner payouts
+// of a range of graded blocks. The stop height defaults to the latest synced
+// height.
+func (s *APIServer) getOPRStats(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetOPRStats{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	result, err := s.Node.Pegnet.SelectOPRStats(ctx, uint32(params.Start), uint32(params.Stop))
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return result
+}
+
+// ResultGetMiner are the totals of a miner and its submissions per height,
+// newest first. `Next` is the `before` of the next page, 0 if there is none.
+type ResultGetMiner struct {
+	pegnet.MinerStats
+	History []pegnet.MinerBlock `json:"history"`
+	Next    uint32              `json:"next,omitempty"`
+}
+
+// getMiner returns the submissions, graded placements, and rewards of a
+// coinbase address
+func (s *APIServer) getMiner(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetMiner{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	stats, history, err := s.Node.Pegnet.SelectMiner(ctx, params.Address, uint32(params.Before), limit)
+	if err == sql.ErrNoRows {
+		return ErrorNotFound
+	}
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	res := ResultGetMiner{MinerStats: stats, History: history}
+	if len(history) == limit {
+		res.Next = history[len(history)-1].Height
+	}
+	return res
+}
+
+// ResultGetMiners are the totals of the miners of a range of heights
+type ResultGetMiners struct {
+	Start  uint32              `json:"start"`
+	Stop   uint32              `json:"stop"`
+	Miners []pegnet.MinerStats `json:"miners"`
+}
+
+// getMiners returns the miners of a range of heights, ordered by reward. The
+// stop height defaults to the latest synced height.
+func (s *APIServer) getMiners(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetMiners{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+	limit := params.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	miners, err := s.Node.Pegnet.SelectMiners(ctx, uint32(params.Start), uint32(params.Stop), limit)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	return ResultGetMiners{Start: uint32(params.Start), Stop: uint32(params.Stop), Miners: miners}
+}
+
+// ResultGlobalRichList is the value of all balances of an address. If a quote
+// asset was requested, `QuoteEquiv` is the value in that asset.
 type ResultGlobalRichList struct {
-	Address string `json:"address"`
-	Equiv   uint64 `json:"pusd"`
+	Address    string `json:"address"`
+	EthAddress string `json:"ethaddress,omitempty"`
+	Equiv      uint64 `json:"pusd"`
+	Quote      string `json:"quote,omitempty"`
+	QuoteEquiv uint64 `json:"equiv,omitempty"`
 }
 
 func (s *APIServer) getGlobalRichList(_ context.Context, data json.RawMessage) interface{} {
@@ -165,13 +397,18 @@ func (s *APIServer) getGlobalRichList(_ context.Context, data json.RawMessage) i
 		return res
 	}
 
-	rich, err := s.Node.Pegnet.SelectAllBalances()
+	quote := fat2.StringToTicker(params.Quote) // already validated
+	if params.Quote != "" && rates[quote] == 0 {
+		return jrpc.ErrorInvalidParams("the quote asset has no rate")
+	}
+
+	rich, err := s.Node.Pegnet.SelectGlobalRichList(rates, params.Count)
 	if err != nil {
 		return err
 	}
 
 	for _, r := range rich {
-		var usd uint64
+		var usd, equiv uint64
 
 		for i := fat2.PTicker(1); i < fat2.PTickerMax; i++ {
 			if r.Balances[i] == 0 {
@@ -183,6 +420,14 @@ func (s *APIServer) getGlobalRichList(_ context.Context, data json.RawMessage) i
 			}
 
 			usd += uint64(c)
+
+			if params.Quote != "" {
+				q, err := conversions.Convert(int64(r.Balances[i]), rates[i], rates[quote])
+				if err != nil {
+					return err
+				}
+				equiv += uint64(q)
+			}
 		}
 
 		if usd == 0 {
@@ -191,12 +436,22 @@ func (s *APIServer) getGlobalRichList(_ context.Context, data json.RawMessage) i
 
 		var entry ResultGlobalRichList
 		entry.Address = r.Address.String()
+		if r.EthAddress != nil {
+			entry.EthAddress = r.EthAddress.String()
+		}
 		entry.Equiv = usd
+		if params.Quote != "" {
+			entry.Quote = quote.String()
+			entry.QuoteEquiv = equiv
+		}
 
 		res = append(res, entry)
 	}
 
 	sort.Slice(res, func(i, j int) bool {
+		if params.Quote != "" {
+			return res[i].QuoteEquiv > res[j].QuoteEquiv
+		}
 		return res[i].Equiv > res[j].Equiv
 	})
 
@@ -207,13 +462,18 @@ func (s *APIServer) getGlobalRichList(_ context.Context, data json.RawMessage) i
 	return res
 }
 
+// ResultGetRichList is the balance of an address in the asset. If a quote
+// asset was requested, `QuoteEquiv` is the value in that asset.
 type ResultGetRichList struct {
-	Address string `json:"address"`
-	Amount  uint64 `json:"amount"`
-	Equiv   uint64 `json:"pusd"`
+	Address    string `json:"address"`
+	EthAddress string `json:"ethaddress,omitempty"`
+	Amount     uint64 `json:"amount"`
+	Equiv      uint64 `json:"pusd"`
+	Quote      string `json:"quote,omitempty"`
+	QuoteEquiv uint64 `json:"equiv,omitempty"`
 }
 
-func (s *APIServer) getRichList(_ context.Context, data json.RawMessage) interface{} {
+func (s *APIServer) getRichList(ctx context.Context, data json.RawMessage) interface{} {
 	params := ParamsGetRichList{}
 	_, _, err := validate(data, &params)
 	if err != nil {
@@ -225,15 +485,30 @@ func (s *APIServer) getRichList(_ context.Context, data json.RawMessage) interfa
 	}
 
 	height := s.Node.GetCurrentSync()
+	if params.Height != 0 {
+		if params.Height > height {
+			return jrpc.ErrorInvalidParams("height is not synced yet")
+		}
+		height = params.Height
+	}
 	rates, rateHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(nil, s.Node.Pegnet.DB, height+1)
 	if err != nil {
 		return err
 	}
 
 	ticker := fat2.StringToTicker(params.Asset) // already validated
+	quote := fat2.StringToTicker(params.Quote)
+	if params.Quote != "" && rateHeight > 0 && rates[quote] == 0 {
+		return jrpc.ErrorInvalidParams("the quote asset has no rate")
+	}
 
-	rich, err := s.Node.Pegnet.SelectRichList(ticker, params.Count)
-	if err != nil {
+	var rich []pegnet.BalancePair
+	if params.Height != 0 {
+		rich, err = s.Node.Pegnet.SelectRichListAt(ctx, ticker, height, params.Count)
+		if err != nil {
+			return jrpc.ErrorInvalidParams(err.Error())
+		}
+	} else if rich, err = s.Node.Pegnet.SelectRichList(ticker, params.Count); err != nil {
 		return err
 	}
 
@@ -241,6 +516,7 @@ func (s *APIServer) getRichList(_ context.Context, data json.RawMessage) interfa
 	for _, r := range rich {
 		var entry ResultGetRichList
 		entry.Address = r.Address.String()
+		entry.EthAddress = s.ethAddress(r.Address)
 		entry.Amount = r.Balance
 		if rateHeight > 0 {
 			c, err := conversions.Convert(int64(r.Balance), rates[ticker], rates[fat2.PTickerUSD])
@@ -248,6 +524,15 @@ func (s *APIServer) getRichList(_ context.Context, data json.RawMessage) interfa
 				return err
 			}
 			entry.Equiv = uint64(c)
+
+			if params.Quote != "" {
+				q, err := conversions.Convert(int64(r.Balance), rates[ticker], rates[quote])
+				if err != nil {
+					return err
+				}
+				entry.Quote = quote.String()
+				entry.QuoteEquiv = uint64(q)
+			}
 		}
 
 		res = append(res, entry)
@@ -256,9 +541,113 @@ func (s *APIServer) getRichList(_ context.Context, data json.RawMessage) interfa
 	return res
 }
 
+type ResultHolder struct {
+	Address    string `json:"address"`
+	EthAddress string `json:"ethaddress,omitempty"`
+	Balance    uint64 `json:"balance"`
+}
+
+// ResultGetHolders returns a page of the holders of an asset.
+// `Count` is the total number of addresses holding the asset.
+// `NextOffset` returns the offset to use to get the next set of holders,
+// it is omitted if there are no more holders.
+type ResultGetHolders struct {
+	Count      int            `json:"count"`
+	Holders    []ResultHolder `json:"holders"`
+	NextOffset int            `json:"nextoffset,omitempty"`
+}
+
+func (s *APIServer) getHolders(_ context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetHolders{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	count, holders, err := s.Node.Pegnet.SelectHolders(fat2.StringToTicker(params.Asset), params.Offset, params.Limit)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetHolders{Count: count, Holders: make([]ResultHolder, len(holders))}
+	for i, h := range holders {
+		res.Holders[i] = ResultHolder{Address: h.Address.String(), EthAddress: s.ethAddress(h.Address), Balance: h.Balance}
+	}
+	if next := params.Offset + len(holders); len(holders) > 0 && next < count {
+		res.NextOffset = next
+	}
+	return res
+}
+
+// ResultGetDistributionStats is how the balances of the assets are spread
+// over their holders as of the synced height
+type ResultGetDistributionStats struct {
+	Height uint32                         `json:"height"`
+	Assets map[string]pegnet.Distribution `json:"assets"`
+}
+
+func (s *APIServer) getDistributionStats(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetDistributionStats{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	tickers := []fat2.PTicker{fat2.StringToTicker(params.Asset)}
+	if params.Asset == "" {
+		tickers = tickers[:0]
+		for i := fat2.PTicker(1); i < fat2.PTickerMax; i++ {
+			tickers = append(tickers, i)
+		}
+	}
+
+	res := ResultGetDistributionStats{Height: s.Node.GetCurrentSync(), Assets: make(map[string]pegnet.Distribution, len(tickers))}
+	for _, ticker := range tickers {
+		d, err := s.Node.Pegnet.SelectDistribution(ctx, ticker)
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		res.Assets[ticker.String()] = *d
+	}
+	return res
+}
+
+// ResultGetConversionResult are the results of the conversions in a batch.
+// `Executed` is the status of the batch, see get-transaction-status.
+type ResultGetConversionResult struct {
+	Height      uint32                    `json:"height"`
+	Executed    int32                     `json:"executed"`
+	Conversions []pegnet.ConversionResult `json:"conversions"`
+}
+
+func (s *APIServer) getConversionResult(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetConversionResult{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	height, executed, err := s.Node.Pegnet.SelectTransactionHistoryStatus(params.Hash)
+	if err != nil {
+		return err
+	}
+	if height == 0 {
+		return ErrorTransactionNotFound
+	}
+
+	conversions, err := s.Node.Pegnet.SelectConversionResults(ctx, params.Hash)
+	if err != nil {
+		return err
+	}
+	if len(conversions) == 0 {
+		return jrpc.ErrorInvalidParams("the transaction contains no conversions")
+	}
+	return ResultGetConversionResult{Height: height, Executed: executed, Conversions: conversions}
+}
+
+// ResultGetTransactionStatus is the status of a transaction entry.
+// If the entry was rejected, `Reason` contains the rejection reason.
 type ResultGetTransactionStatus struct {
 	Height   uint32 `json:"height"`
-	Executed uint32 `json:"executed"`
+	Executed int32  `json:"executed"`
+	Reason   string `json:"reason,omitempty"`
 }
 
 func (s *APIServer) getTransactionStatus(_ context.Context, data json.RawMessage) interface{} {
@@ -268,18 +657,42 @@ func (s *APIServer) getTransactionStatus(_ context.Context, data json.RawMessage
 		return err
 	}
 
+	if params.TxID != "" {
+		_, entryhash, _ := pegnet.SplitTxID(params.TxID) // error checked by params.valid
+		params.Hash = new(factom.Bytes32)
+		if err := params.Hash.Set(entryhash); err != nil {
+			return jrpc.ErrorInvalidParams("txid: " + err.Error())
+		}
+	}
+
 	height, executed, err := s.Node.Pegnet.SelectTransactionHistoryStatus(params.Hash)
 	if err != nil {
 		return jrpc.ErrorInvalidParams(err)
 	}
 
-	if height == 0 {
-		return ErrorTransactionNotFound
+	rejection, err := s.Node.Pegnet.SelectTransactionRejection(params.Hash)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err)
 	}
 
 	var res ResultGetTransactionStatus
+	if height == 0 {
+		// Entries that failed to parse or were replays never make it
+		// into the history
+		if rejection == nil {
+			return ErrorTransactionNotFound
+		}
+		res.Height = rejection.Height
+		res.Executed = int32(rejection.Code)
+		res.Reason = rejection.Reason
+		return res
+	}
+
 	res.Height = height
 	res.Executed = executed
+	if executed < 0 && rejection != nil {
+		res.Reason = rejection.Reason
+	}
 
 	return res
 }
@@ -307,45 +720,10 @@ func (s *APIServer) getTransactions(forceTxId bool) func(_ context.Context, data
 			return jrpc.ErrorInvalidParams(fmt.Errorf("expect txid param to be populated"))
 		}
 
-		// using a separate options struct due to golang's circular import restrictions
-		var options pegnet.HistoryQueryOptions
-		options.Offset = params.Offset
-		options.Desc = params.Desc
-		options.Transfer = params.Transfer
-		options.Conversion = params.Conversion
-		options.Coinbase = params.Coinbase
-		options.FCTBurn = params.Burn
-		options.Asset = params.Asset
-
-		// Are we searching by txid?
-		if params.TxID != "" {
-			idx, entryhash, err := pegnet.SplitTxID(params.TxID)
-			if err != nil {
-				return jrpc.ErrorInvalidParams(err.Error())
-			}
-			options.TxIndex = idx
-			options.UseTxIndex = true
-			params.txEntryHash = entryhash
-		}
-
-		var actions []pegnet.HistoryTransaction
-		var count int
-
-		if params.Hash != "" {
-			hash := new(factom.Bytes32)
-			_ = hash.UnmarshalText([]byte(params.Hash)) // error checked by params.valid
-			actions, count, err = s.Node.Pegnet.SelectTransactionHistoryActionsByHash(hash, options)
-		} else if params.Address != "" {
-			addr, _ := underlyingFA(params.Address) // verified in param
-			actions, count, err = s.Node.Pegnet.SelectTransactionHistoryActionsByAddress(&addr, options)
-		} else if params.TxID != "" {
-			hash := new(factom.Bytes32)
-			_ = hash.UnmarshalText([]byte(params.txEntryHash)) // error checked by params.valid
-			actions, count, err = s.Node.Pegnet.SelectTransactionHistoryActionsByTxID(hash, options)
-		} else {
-			actions, count, err = s.Node.Pegnet.SelectTransactionHistoryActionsByHeight(uint32(params.Height), options)
+		actions, count, err := s.selectTransactions(params, historyOptions(params))
+		if jerr, ok := err.(jrpc.Error); ok {
+			return jerr
 		}
-
 		if err != nil {
 			return jrpc.ErrorInvalidParams(err.Error())
 		}
@@ -365,6 +743,78 @@ func (s *APIServer) getTransactions(forceTxId bool) func(_ context.Context, data
 	}
 }
 
+// ResultGetTransactionsByHashes are the actions of the entry hashes, in the
+// order of the hashes, and the hashes that are not in the history
+type ResultGetTransactionsByHashes struct {
+	Actions []pegnet.HistoryTransaction `json:"actions"`
+	Missing []*factom.Bytes32           `json:"missing"`
+}
+
+func (s *APIServer) getTransactionsByHashes(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetTransactionsByHashes{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	found, err := s.Node.Pegnet.SelectTransactionHistoryActionsByHashes(ctx, params.Hashes)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+
+	res := ResultGetTransactionsByHashes{Actions: []pegnet.HistoryTransaction{}, Missing: []*factom.Bytes32{}}
+	seen := make(map[factom.Bytes32]bool, len(params.Hashes))
+	for _, hash := range params.Hashes {
+		if seen[*hash] {
+			continue
+		}
+		seen[*hash] = true
+		actions, ok := found[*hash]
+		if !ok {
+			res.Missing = append(res.Missing, hash)
+			continue
+		}
+		res.Actions = append(res.Actions, actions...)
+	}
+	return res
+}
+
+// historyOptions turns the parameters into the options of the history query
+func historyOptions(params ParamsGetPegnetTransaction) pegnet.HistoryQueryOptions {
+	// using a separate options struct due to golang's circular import restrictions
+	var options pegnet.HistoryQueryOptions
+	options.Offset = params.Offset
+	options.Desc = params.Desc
+	options.Transfer = params.Transfer
+	options.Conversion = params.Conversion
+	options.Coinbase = params.Coinbase
+	options.FCTBurn = params.Burn
+	options.Asset = params.Asset
+	options.Refund = params.Refund
+	options.Sort = params.Sort
+	options.MinAmount = params.MinAmount
+	options.MaxAmount = params.MaxAmount
+	options.Direction = params.Direction
+	return options
+}
+
+// selectTransactions queries the history by the one field set in the params
+func (s *APIServer) selectTransactions(params ParamsGetPegnetTransaction, options pegnet.HistoryQueryOptions) ([]pegnet.HistoryTransaction, int, error) {
+	if params.Hash != "" {
+		hash := new(factom.Bytes32)
+		_ = hash.UnmarshalText([]byte(params.Hash)) // error checked by params.valid
+		return s.Node.Pegnet.SelectTransactionHistoryActionsByHash(hash, options)
+	} else if params.Address != "" {
+		addr, err := s.resolveAddress(params.Address)
+		if err != nil {
+			return nil, 0, err
+		}
+		return s.Node.Pegnet.SelectTransactionHistoryActionsByAddress(&addr, options)
+	} else if params.TxID != "" {
+		return s.Node.Pegnet.SelectTransactionHistoryActionsByTxID(params.TxID, options)
+	}
+	return s.Node.Pegnet.SelectTransactionHistoryActionsByHeight(uint32(params.Height), options)
+}
+
 // TODO: This is incompatible with FAT.
 type ResultPegnetTickerMap map[fat2.PTicker]uint64
 
@@ -394,12 +844,15 @@ func (r *ResultPegnetTickerMap) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (s *APIServer) getPegnetBalances(_ context.Context, data json.RawMessage) interface{} {
+func (s *APIServer) getPegnetBalances(ctx context.Context, data json.RawMessage) interface{} {
 	params := ParamsGetPegnetBalances{}
 	if _, _, err := validate(data, &params); err != nil {
 		return err
 	}
-	add, _ := underlyingFA(params.Address)
+	add, err := s.resolveAddress(params.Address)
+	if err != nil {
+		return err
+	}
 
 	bals, err := s.Node.Pegnet.SelectBalances(&add)
 	if err == sql.ErrNoRows {
@@ -408,75 +861,953 @@ func (s *APIServer) getPegnetBalances(_ context.Context, data json.RawMessage) i
 	if err != nil {
 		panic(err) // This is an internal error
 	}
-	return ResultPegnetTickerMap(bals)
+	if params.IncludeUSD {
+		return s.balancesUSD(ctx, params, add, bals)
+	}
+	if !params.HasIncludePending() {
+		return ResultPegnetTickerMap(bals)
+	}
+
+	pending, err := s.pendingBalances(ctx, add, bals)
+	if err != nil {
+		return err
+	}
+	return ResultPegnetBalancesPending{Confirmed: bals, Pending: pending}
 }
 
-type ResultGetIssuance struct {
-	SyncStatus ResultGetSyncStatus   `json:"syncstatus"`
-	Issuance   ResultPegnetTickerMap `json:"issuance"`
+// ResultBalanceUSD is a balance with its value in pUSD, 0 if the asset has no
+// rate
+type ResultBalanceUSD struct {
+	Balance uint64 `json:"balance"`
+	USD     uint64 `json:"usd"`
 }
 
-func (s *APIServer) getPegnetIssuance(_ context.Context, data json.RawMessage) interface{} {
-	issuance, err := s.Node.Pegnet.SelectIssuances()
-	if err == sql.ErrNoRows {
-		return ErrorAddressNotFound
+// ResultPegnetBalancesUSD are the balances of an address with their pUSD
+// values at the rates of `RateHeight`. `Total` is the value of all confirmed
+// balances. `Pending` is set if the pending balances were requested.
+type ResultPegnetBalancesUSD struct {
+	RateHeight uint32                      `json:"rateheight"`
+	Balances   map[string]ResultBalanceUSD `json:"balances"`
+	Pending    map[string]ResultBalanceUSD `json:"pending,omitempty"`
+	Total      uint64                      `json:"total"`
+}
+
+func (s *APIServer) balancesUSD(ctx context.Context, params ParamsGetPegnetBalances, add factom.FAAddress, bals map[fat2.PTicker]uint64) interface{} {
+	height := s.Node.GetCurrentSync()
+	if params.Height != 0 {
+		if params.Height > height {
+			return jrpc.ErrorInvalidParams("height is not synced yet")
+		}
+		height = params.Height
 	}
+	rates, rateHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(ctx, s.Node.Pegnet.DB, height+1)
 	if err != nil {
 		panic(err) // This is an internal error
 	}
 
-	syncStatus := s.getSyncStatus(context.Background(), nil)
-	return ResultGetIssuance{
-		SyncStatus: syncStatus.(ResultGetSyncStatus),
-		Issuance:   issuance,
-	}
-}
-
-func (s *APIServer) getPegnetRates(ctx context.Context, data json.RawMessage) interface{} {
-	params := ParamsGetPegnetRates{}
-	if _, _, err := validate(data, &params); err != nil {
-		return err
+	value := func(bals map[fat2.PTicker]uint64) (map[string]ResultBalanceUSD, uint64) {
+		res := make(map[string]ResultBalanceUSD, len(bals))
+		var total uint64
+		for ticker, balance := range bals {
+			b := ResultBalanceUSD{Balance: balance}
+			if rates[ticker] != 0 && rates[fat2.PTickerUSD] != 0 {
+				if usd, err := conversions.Convert(int64(balance), rates[ticker], rates[fat2.PTickerUSD]); err == nil {
+					b.USD = uint64(usd)
+				}
+			}
+			res[ticker.String()] = b
+			total += b.USD
+		}
+		return res, total
 	}
 
-	if params.Height == 0 {
-		synced, err := s.Node.Pegnet.SelectSynced(ctx, s.Node.Pegnet.DB)
+	res := ResultPegnetBalancesUSD{RateHeight: rateHeight}
+	res.Balances, res.Total = value(bals)
+	if params.HasIncludePending() {
+		pending, err := s.pendingBalances(ctx, add, bals)
 		if err != nil {
 			return err
 		}
-		params.Height = uint32(synced.Synced)
+		res.Pending, _ = value(pending)
 	}
+	return res
+}
 
-	rates, err := s.Node.Pegnet.SelectRates(ctx, params.Height)
-	if err == sql.ErrNoRows || rates == nil || len(rates) == 0 {
-		return ErrorNotFound
+// ResultPegnetBalancesPending are the balances with and without the
+// transactions that are not executed yet
+type ResultPegnetBalancesPending struct {
+	Confirmed ResultPegnetTickerMap `json:"confirmed"`
+	Pending   ResultPegnetTickerMap `json:"pending"`
+}
+
+// pendingBalances overlays the debits and credits of the batches waiting in
+// holding and of the entries factomd has not included in a block yet.
+// Conversion outputs are not credited, their rates are not known yet.
+func (s *APIServer) pendingBalances(ctx context.Context, add factom.FAAddress, confirmed map[fat2.PTicker]uint64) (ResultPegnetTickerMap, error) {
+	debits := make(map[fat2.PTicker]uint64)
+	pending := make(ResultPegnetTickerMap, len(confirmed))
+	for ticker, balance := range confirmed {
+		pending[ticker] = balance
 	}
+
+	held, err := s.Node.Pegnet.SelectTransactionHistoryActionsByAddressPending(ctx, &add)
 	if err != nil {
-		panic(err) // This is an internal error
+		return nil, err
 	}
+	for _, action := range held {
+		ticker := fat2.StringToTicker(action.FromAsset)
+		if *action.FromAddress == add {
+			debits[ticker] += uint64(action.FromAmount)
+		}
+		if action.TxAction != pegnet.Transfer {
+			continue
+		}
+		for _, out := range action.Outputs {
+			if out.Address == add {
+				pending[ticker] += uint64(out.Amount)
+			}
+		}
+	}
+
+	batches, err := s.Node.PendingTransactionBatches(ctx)
+	if err != nil {
+		rerr := ErrorPendingUnavailable
+		rerr.Data = err.Error()
+		return nil, rerr
+	}
+	for _, batch := range batches {
+		for _, tx := range batch.Transactions {
+			if tx.Input.Address == add {
+				debits[tx.Input.Type] += tx.Input.Amount
+			}
+			for _, transfer := range tx.Transfers {
+				if transfer.Address == add {
+					pending[tx.Input.Type] += transfer.Amount
+				}
+			}
+		}
+	}
+
+	// Batches that overspend will be rejected, but the funds are spoken for
+	for ticker, debit := range debits {
+		if pending[ticker] < debit {
+			pending[ticker] = 0
+		} else {
+			pending[ticker] -= debit
+		}
+	}
+	return pending, nil
+}
 
-	// The balance results actually works for rates too
-	return ResultPegnetTickerMap(rates)
+// ResultGetAddressStats are the aggregates of the activity of an address.
+// `Transactions` counts transactions, conversions, burns, and coinbases the
+// address took part in.
+type ResultGetAddressStats struct {
+	Address      string                              `json:"address"`
+	EthAddress   string                              `json:"ethaddress,omitempty"`
+	Transactions int64                               `json:"transactions"`
+	FirstSeen    uint32                              `json:"firstseen"`
+	LastActive   uint32                              `json:"lastactive"`
+	Assets       map[string]pegnet.AddressAssetStats `json:"assets"`
 }
 
-func (s *APIServer) sendTransaction(_ context.Context, data json.RawMessage) interface{} {
-	params := ParamsSendTransaction{}
-	_, _, err := validate(data, &params)
+func (s *APIServer) getAddressStats(_ context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAddressStats{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	add, err := s.resolveAddress(params.Address)
 	if err != nil {
 		return err
 	}
-	// defer put()
 
-	ecPrivateKeyString := s.Config.GetString(config.ECPrivateKey)
-	var ecPrivateKey factom.EsAddress
-	if err = ecPrivateKey.Set(ecPrivateKeyString); err != nil {
+	stats, err := s.Node.Pegnet.SelectAddressStats(&add)
+	if err != nil {
 		panic(err) // This is an internal error
 	}
+	if stats == nil {
+		return ErrorAddressNotFound
+	}
 
-	entry := params.Entry()
-	entry.ChainID = &node.TransactionChain
-	// TODO: attempt to apply
-	//txErr, err := attemptApplyFAT2TxBatch(chain, entry)
-	//if err != nil {
+	res := ResultGetAddressStats{
+		Address:      add.String(),
+		EthAddress:   s.ethAddress(&add),
+		Transactions: stats.TxCount,
+		FirstSeen:    stats.FirstSeen,
+		LastActive:   stats.LastActive,
+		Assets:       make(map[string]pegnet.AddressAssetStats, len(stats.Assets)),
+	}
+	for ticker, asset := range stats.Assets {
+		res.Assets[ticker.String()] = asset
+	}
+	return res
+}
+
+// ResultGetIssuance is the issuance of every asset. `Height` is set if the
+// issuance of a past height was requested.
+type ResultGetIssuance struct {
+	SyncStatus ResultGetSyncStatus   `json:"syncstatus"`
+	Height     uint32                `json:"height,omitempty"`
+	Issuance   ResultPegnetTickerMap `json:"issuance"`
+}
+
+func (s *APIServer) getPegnetIssuance(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetPegnetIssuance{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Height > 0 {
+		height := uint32(params.Height)
+		if height > s.Node.GetCurrentSync() {
+			return jrpc.ErrorInvalidParams("height is not synced yet")
+		}
+		history, err := s.Node.Pegnet.SelectSupplyHistory(ctx, height, height, fat2.PTickerInvalid)
+		if err != nil {
+			return jrpc.ErrorInvalidParams(err.Error())
+		}
+		if len(history) == 0 {
+			return jrpc.ErrorInvalidParams("no supply history recorded at that height")
+		}
+
+		syncStatus := s.getSyncStatus(ctx, nil)
+		return ResultGetIssuance{
+			SyncStatus: syncStatus.(ResultGetSyncStatus),
+			Height:     height,
+			Issuance:   history[0].Supply,
+		}
+	}
+
+	issuance, err := s.Node.Pegnet.SelectIssuances()
+	if err == sql.ErrNoRows {
+		return ErrorAddressNotFound
+	}
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+
+	syncStatus := s.getSyncStatus(context.Background(), nil)
+	return ResultGetIssuance{
+		SyncStatus: syncStatus.(ResultGetSyncStatus),
+		Issuance:   issuance,
+	}
+}
+
+// ResultAssetSupply is the breakdown of the supply of an asset.
+// `Issued` is the amount created by conversions into the asset, `Consumed`
+// the amount destroyed by conversions out of the asset excluding refunds,
+// `Burned` the amount minted by FCT burns, `Mined` the amount paid out to
+// miners, and `Supply` the net circulating supply.
+type ResultAssetSupply struct {
+	Issued   int64  `json:"issued"`
+	Consumed int64  `json:"consumed"`
+	Burned   int64  `json:"burned"`
+	Mined    int64  `json:"mined"`
+	Supply   uint64 `json:"supply"`
+}
+
+// ResultGetSupply returns the supply breakdown of every asset as well as the
+// totals of all assets in pUSD, using the rates of `RateHeight`. Assets
+// without a rate are not included in the totals.
+type ResultGetSupply struct {
+	SyncStatus ResultGetSyncStatus          `json:"syncstatus"`
+	RateHeight uint32                       `json:"rateheight"`
+	Assets     map[string]ResultAssetSupply `json:"assets"`
+	Totals     ResultAssetSupply            `json:"totals"`
+}
+
+func (s *APIServer) getSupply(ctx context.Context, data json.RawMessage) interface{} {
+	totals, err := s.Node.Pegnet.SelectSupplyTotals()
+	if err != nil {
+		return err
+	}
+	issuance, err := s.Node.Pegnet.SelectIssuances()
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	height := s.Node.GetCurrentSync()
+	rates, rateHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(ctx, s.Node.Pegnet.DB, height+1)
+	if err != nil {
+		return err
+	}
+
+	res := ResultGetSupply{
+		SyncStatus: s.getSyncStatus(ctx, nil).(ResultGetSyncStatus),
+		RateHeight: rateHeight,
+		Assets:     make(map[string]ResultAssetSupply),
+	}
+	for i := fat2.PTicker(1); i < fat2.PTickerMax; i++ {
+		t := totals[i]
+		asset := ResultAssetSupply{
+			Issued:   t.ConvertedIn,
+			Consumed: t.ConvertedOut,
+			Burned:   t.Burned,
+			Mined:    t.Mined,
+			Supply:   issuance[i],
+		}
+		res.Assets[i.String()] = asset
+
+		if rates[i] == 0 || rates[fat2.PTickerUSD] == 0 {
+			continue
+		}
+		convert := func(amount int64) int64 {
+			if err != nil {
+				return 0
+			}
+			var c int64
+			c, err = conversions.Convert(amount, rates[i], rates[fat2.PTickerUSD])
+			return c
+		}
+		res.Totals.Issued += convert(asset.Issued)
+		res.Totals.Consumed += convert(asset.Consumed)
+		res.Totals.Burned += convert(asset.Burned)
+		res.Totals.Mined += convert(asset.Mined)
+		res.Totals.Supply += uint64(convert(int64(asset.Supply)))
+		if err != nil {
+			return err
+		}
+	}
+	return res
+}
+
+type ResultSupplyAtHeight struct {
+	Height uint32                `json:"height"`
+	Supply ResultPegnetTickerMap `json:"supply"`
+}
+
+// ResultGetSupplyHistory returns the recorded supply for a range of heights.
+// `NextHeight` is the start height to use to get the next set of records,
+// it is omitted if there are no more records available.
+type ResultGetSupplyHistory struct {
+	History    []ResultSupplyAtHeight `json:"history"`
+	NextHeight uint32                 `json:"nextheight,omitempty"`
+}
+
+func (s *APIServer) getSupplyHistory(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetSupplyHistory{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	history, err := s.Node.Pegnet.SelectSupplyHistory(ctx, uint32(params.Start), uint32(params.Stop), fat2.StringToTicker(params.Asset))
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetSupplyHistory{History: make([]ResultSupplyAtHeight, len(history))}
+	for i, h := range history {
+		res.History[i] = ResultSupplyAtHeight{Height: h.Height, Supply: h.Supply}
+	}
+	if len(history) == pegnet.SupplyHistoryLimit && history[len(history)-1].Height < uint32(params.Stop) {
+		res.NextHeight = history[len(history)-1].Height + 1
+	}
+	return res
+}
+
+// ResultGetSupplyComposition splits the supply of the assets into the amounts
+// minted by burns, the net of the conversions, and the coinbases, as of
+// `Height`
+type ResultGetSupplyComposition struct {
+	Height uint32                              `json:"height"`
+	Assets map[string]pegnet.SupplyComposition `json:"assets"`
+}
+
+func (s *APIServer) getSupplyComposition(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetSupplyComposition{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	composition, height, err := s.Node.Pegnet.SelectSupplyComposition(ctx)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+
+	res := ResultGetSupplyComposition{Height: height, Assets: make(map[string]pegnet.SupplyComposition)}
+	for ticker, c := range composition {
+		if params.Asset != "" && ticker != fat2.StringToTicker(params.Asset) {
+			continue
+		}
+		res.Assets[ticker.String()] = c
+	}
+	return res
+}
+
+type ResultLedgerEntry struct {
+	Address  string                `json:"address"`
+	Balances ResultPegnetTickerMap `json:"balances"`
+}
+
+// ResultGetAddressEvents returns the balance changes of an address.
+// `Count` is the total number of actions of the address, an action can cause
+// several events.
+// `NextOffset` returns the offset to use to get the next set of events,
+// 0 means no more events available
+type ResultGetAddressEvents struct {
+	Events     []pegnet.AddressEvent `json:"events"`
+	Count      int                   `json:"count"`
+	NextOffset int                   `json:"nextoffset"`
+}
+
+func (s *APIServer) getAddressEvents(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAddressEvents{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	add, err := s.resolveAddress(params.Address)
+	if err != nil {
+		return err
+	}
+
+	events, count, err := s.Node.Pegnet.SelectAddressEvents(ctx, &add, params.Asset, params.Offset, params.Desc)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetAddressEvents{Events: events, Count: count}
+	if res.Events == nil {
+		res.Events = []pegnet.AddressEvent{}
+	}
+	if params.Offset+pegnet.QueryLimit < count {
+		res.NextOffset = params.Offset + pegnet.QueryLimit
+	}
+	return res
+}
+
+// ResultGetLedger returns a page of the non-zero balances of all addresses
+// at a height. `Next` is the address to use as `after` to get the next page,
+// it is omitted if there are no more addresses.
+type ResultGetLedger struct {
+	Height uint32              `json:"height"`
+	Ledger []ResultLedgerEntry `json:"ledger"`
+	Next   string              `json:"next,omitempty"`
+}
+
+func (s *APIServer) getLedger(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetLedger{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	height := uint32(params.Height)
+	if height == 0 {
+		height = s.Node.GetCurrentSync()
+	}
+	if height > s.Node.GetCurrentSync() {
+		return jrpc.ErrorInvalidParams("height is not synced yet")
+	}
+
+	var after *factom.FAAddress
+	if params.After != "" {
+		adr, _ := factom.NewFAAddress(params.After) // Checked by IsValid
+		after = &adr
+	}
+
+	ledger, next, err := s.Node.Pegnet.SelectLedger(ctx, height, fat2.StringToTicker(params.Asset), after, params.Limit)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetLedger{Height: height, Ledger: make([]ResultLedgerEntry, len(ledger))}
+	for i, e := range ledger {
+		res.Ledger[i] = ResultLedgerEntry{Address: e.Address.String(), Balances: e.Balances}
+	}
+	if next != nil {
+		res.Next = next.String()
+	}
+	return res
+}
+
+// The defaults of the balance history, a point a day for 100 days
+const (
+	BalanceHistoryInterval = 144
+	BalanceHistoryDefault  = 100
+)
+
+// ResultGetBalanceHistory is the balance of an address in one asset every
+// `interval` heights in [start, stop]
+type ResultGetBalanceHistory struct {
+	Address  string                `json:"address"`
+	Asset    string                `json:"asset"`
+	Start    uint32                `json:"start"`
+	Stop     uint32                `json:"stop"`
+	Interval uint32                `json:"interval"`
+	Points   []pegnet.BalancePoint `json:"points"`
+}
+
+func (s *APIServer) getBalanceHistory(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetBalanceHistory{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	add, err := s.resolveAddress(params.Address)
+	if err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+	if params.Stop > int(s.Node.GetCurrentSync()) {
+		return jrpc.ErrorInvalidParams("stop is not synced yet")
+	}
+	if params.Interval == 0 {
+		params.Interval = BalanceHistoryInterval
+	}
+	if params.Start == 0 {
+		// The default range does not go below the journal
+		journal, err := s.Node.Pegnet.SelectBalanceJournalStart()
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		params.Start = params.Stop - (BalanceHistoryDefault-1)*params.Interval
+		if params.Start < int(journal) {
+			params.Start = int(journal)
+		}
+	}
+	if params.Stop < params.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+
+	ticker := fat2.StringToTicker(params.Asset)
+	points, err := s.Node.Pegnet.SelectBalanceHistory(ctx, &add, ticker, uint32(params.Start), uint32(params.Stop), uint32(params.Interval))
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return ResultGetBalanceHistory{
+		Address:  add.String(),
+		Asset:    ticker.String(),
+		Start:    points[0].Height,
+		Stop:     uint32(params.Stop),
+		Interval: uint32(params.Interval),
+		Points:   points,
+	}
+}
+
+// ResultGetConversionVolume is the amount of every asset converted in and out
+// for a range of heights
+type ResultGetConversionVolume struct {
+	Start  uint32                             `json:"start"`
+	Stop   uint32                             `json:"stop"`
+	Volume map[string]pegnet.ConversionVolume `json:"volume"`
+}
+
+func (s *APIServer) getConversionVolume(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetConversionVolume{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	volume, err := s.Node.Pegnet.SelectConversionVolume(ctx, uint32(params.Start), uint32(params.Stop), fat2.StringToTicker(params.Asset))
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetConversionVolume{Start: uint32(params.Start), Stop: uint32(params.Stop), Volume: make(map[string]pegnet.ConversionVolume, len(volume))}
+	for ticker, v := range volume {
+		res.Volume[ticker.String()] = v
+	}
+	return res
+}
+
+// BlocksDefault is the amount of blocks listed if no start is given
+const BlocksDefault = 20
+
+// ResultGetBlocks are the summaries of the blocks in [start, stop]
+type ResultGetBlocks struct {
+	Start  uint32         `json:"start"`
+	Stop   uint32         `json:"stop"`
+	Blocks []pegnet.Block `json:"blocks"`
+}
+
+func (s *APIServer) getBlocks(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetBlocks{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+	if params.Start == 0 && params.Stop >= BlocksDefault {
+		params.Start = params.Stop - BlocksDefault + 1
+	}
+	if params.Stop < params.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+
+	blocks, err := s.Node.Pegnet.SelectBlocks(ctx, uint32(params.Start), uint32(params.Stop), params.Desc)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return ResultGetBlocks{Start: uint32(params.Start), Stop: uint32(params.Stop), Blocks: blocks}
+}
+
+// ResultGetBlock is the summary of a block with its rates, the winning OPRs,
+// the actions executed at the height and the entries rejected at it
+type ResultGetBlock struct {
+	Block    pegnet.Block                `json:"block"`
+	Rates    ResultPegnetTickerMap       `json:"rates"`
+	Winners  []string                    `json:"winners"`
+	Actions  []pegnet.HistoryTransaction `json:"actions"`
+	Rejected []pegnet.RejectedEntry      `json:"rejected"`
+}
+
+func (s *APIServer) getBlock(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetBlock{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	height := uint32(params.Height)
+
+	block, err := s.Node.Pegnet.SelectBlock(ctx, height)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	if block == nil {
+		return ErrorNotFound
+	}
+	res := ResultGetBlock{Block: *block, Winners: []string{}}
+
+	if res.Rates, err = s.Node.Pegnet.SelectRates(ctx, height); err != nil {
+		panic(err) // This is an internal error
+	}
+	quotes, err := s.Node.Pegnet.SelectWinningQuotes(ctx, height)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	for _, eh := range quotes.EntryHashes {
+		res.Winners = append(res.Winners, eh.String())
+	}
+	if res.Actions, err = s.Node.Pegnet.SelectTransactionHistoryActionsExecuted(nil, height); err != nil {
+		panic(err) // This is an internal error
+	}
+	if res.Actions == nil {
+		res.Actions = []pegnet.HistoryTransaction{}
+	}
+	if res.Rejected, err = s.Node.Pegnet.SelectTransactionRejectionsAt(ctx, height); err != nil {
+		panic(err) // This is an internal error
+	}
+	return res
+}
+
+// ResultGetBurns returns FCT burns.
+// `Count` is the total number of burns that match the query.
+// `NextOffset` returns the offset to use to get the next set of burns,
+// it is omitted if there are no more burns.
+type ResultGetBurns struct {
+	Burns      []pegnet.Burn `json:"burns"`
+	Count      int           `json:"count"`
+	NextOffset int           `json:"nextoffset,omitempty"`
+}
+
+func (s *APIServer) getBurns(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetBurns{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	var adr *factom.FAAddress
+	if params.Address != "" {
+		add, _ := underlyingFA(params.Address) // Already validated
+		adr = &add
+	}
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	burns, count, err := s.Node.Pegnet.SelectBurns(ctx, adr, uint32(params.Start), uint32(params.Stop), params.Offset)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetBurns{Burns: burns, Count: count}
+	if res.Burns == nil {
+		res.Burns = []pegnet.Burn{}
+	}
+	if next := params.Offset + len(burns); len(burns) > 0 && next < count {
+		res.NextOffset = next
+	}
+	return res
+}
+
+// ResultGetMalformedBurns returns the transactions to the burn address that
+// were not credited, one per input.
+// `Count` is the total number of inputs that match the query.
+// `NextOffset` returns the offset to use to get the next set, it is omitted
+// if there are no more.
+type ResultGetMalformedBurns struct {
+	Burns      []pegnet.MalformedBurn `json:"burns"`
+	Count      int                    `json:"count"`
+	NextOffset int                    `json:"nextoffset,omitempty"`
+}
+
+func (s *APIServer) getMalformedBurns(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetMalformedBurns{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	var adr *factom.FAAddress
+	if params.Address != "" {
+		add, _ := underlyingFA(params.Address) // Already validated
+		adr = &add
+	}
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	burns, count, err := s.Node.Pegnet.SelectMalformedBurns(ctx, adr, uint32(params.Start), uint32(params.Stop), params.Offset)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetMalformedBurns{Burns: burns, Count: count}
+	if res.Burns == nil {
+		res.Burns = []pegnet.MalformedBurn{}
+	}
+	if next := params.Offset + len(burns); len(burns) > 0 && next < count {
+		res.NextOffset = next
+	}
+	return res
+}
+
+// NetworkStatsDateFormat is the format of the dates used by get-network-stats
+const NetworkStatsDateFormat = "2006-01-02"
+
+type ResultNetworkStats struct {
+	Start           string                              `json:"start"`
+	Transactions    int64                               `json:"transactions"`
+	Transfers       int64                               `json:"transfers"`
+	Conversions     int64                               `json:"conversions"`
+	Coinbases       int64                               `json:"coinbases"`
+	Burns           int64                               `json:"burns"`
+	Burned          int64                               `json:"burned"`
+	ActiveAddresses int64                               `json:"activeaddresses"`
+	Assets          map[string]pegnet.NetworkAssetStats `json:"assets"`
+}
+
+func (s *APIServer) getNetworkStats(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetNetworkStats{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	// Already validated
+	start, _ := time.Parse(NetworkStatsDateFormat, params.Start)
+	stop := time.Now()
+	if params.Stop != "" {
+		stop, _ = time.Parse(NetworkStatsDateFormat, params.Stop)
+	}
+
+	stats, err := s.Node.Pegnet.SelectNetworkStats(ctx, pegnet.UnixDay(start), pegnet.UnixDay(stop), params.Interval == "week")
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := make([]ResultNetworkStats, len(stats))
+	for i, st := range stats {
+		res[i] = ResultNetworkStats{
+			Start:           st.Start.Format(NetworkStatsDateFormat),
+			Transactions:    st.Transactions,
+			Transfers:       st.Transfers,
+			Conversions:     st.Conversions,
+			Coinbases:       st.Coinbases,
+			Burns:           st.Burns,
+			Burned:          st.Burned,
+			ActiveAddresses: st.ActiveAddresses,
+			Assets:          make(map[string]pegnet.NetworkAssetStats, len(st.Assets)),
+		}
+		for ticker, asset := range st.Assets {
+			res[i].Assets[ticker.String()] = asset
+		}
+	}
+	return res
+}
+
+func (s *APIServer) getPegnetRates(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetPegnetRates{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Height == 0 {
+		synced, err := s.Node.Pegnet.SelectSynced(ctx, s.Node.Pegnet.DB)
+		if err == sql.ErrNoRows {
+			return ErrorNotFound // nothing is synced yet
+		}
+		if err != nil {
+			return err
+		}
+		params.Height = uint32(synced.Synced)
+	}
+
+	rates, err := s.Node.Pegnet.SelectRates(ctx, params.Height)
+	if err == sql.ErrNoRows || rates == nil || len(rates) == 0 {
+		return ErrorNotFound
+	}
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+
+	quote := fat2.PTickerUSD
+	if params.Quote != "" {
+		quote = fat2.StringToTicker(params.Quote)
+		if rates[quote] == 0 {
+			return jrpc.ErrorInvalidParams("the quote asset has no rate at the height")
+		}
+	}
+
+	if !params.Verbose {
+		// The balance results actually works for rates too
+		return ResultPegnetTickerMap(quoteRates(rates, quote))
+	}
+
+	quotes, err := s.Node.Pegnet.SelectWinningQuotes(ctx, params.Height)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+
+	res := ResultGetPegnetRatesVerbose{
+		Height:  params.Height,
+		Quote:   quote.String(),
+		Winners: make([]string, len(quotes.EntryHashes)),
+		Rates:   make(map[string]ResultRateProvenance, len(rates)),
+	}
+	for i, eh := range quotes.EntryHashes {
+		res.Winners[i] = eh.String()
+	}
+	for ticker, rate := range rates {
+		prov := ResultRateProvenance{Rate: rate, Quotes: quotes.Quotes[ticker]}
+		for i, q := range prov.Quotes {
+			if i == 0 || q < prov.Min {
+				prov.Min = q
+			}
+			if q > prov.Max {
+				prov.Max = q
+			}
+		}
+		if prov.Quotes == nil {
+			prov.Quotes = []uint64{}
+		}
+		if quote != fat2.PTickerUSD {
+			prov.Rate = quoteRate(prov.Rate, rates[quote])
+			prov.Min = quoteRate(prov.Min, rates[quote])
+			prov.Max = quoteRate(prov.Max, rates[quote])
+			for i := range prov.Quotes {
+				prov.Quotes[i] = quoteRate(prov.Quotes[i], rates[quote])
+			}
+		}
+		res.Rates[ticker.String()] = prov
+	}
+	return res
+}
+
+// quoteRates prices the USD rates in the quote asset, crossed with the USD
+// rate of the quote. The rates are returned as is for USD.
+func quoteRates(rates map[fat2.PTicker]uint64, quote fat2.PTicker) map[fat2.PTicker]uint64 {
+	if quote == fat2.PTickerUSD {
+		return rates
+	}
+	res := make(map[fat2.PTicker]uint64, len(rates))
+	for ticker, rate := range rates {
+		res[ticker] = quoteRate(rate, rates[quote])
+	}
+	return res
+}
+
+// quoteRate is the amount of the quote asset that 1 unit of an asset at the
+// USD rate converts to, the same way a conversion rounds. Assets without a
+// rate stay at 0.
+func quoteRate(rate, quoteRate uint64) uint64 {
+	if rate == 0 {
+		return 0
+	}
+	amount, err := conversions.Convert(1e8, rate, quoteRate)
+	if err != nil {
+		return 0
+	}
+	return uint64(amount)
+}
+
+// ResultRateProvenance is the graded rate of an asset along with the quotes
+// of the winning OPRs, in the order of `Winners` in ResultGetPegnetRatesVerbose.
+// `Min` and `Max` are the spread of the quotes.
+type ResultRateProvenance struct {
+	Rate   uint64   `json:"rate"`
+	Min    uint64   `json:"min"`
+	Max    uint64   `json:"max"`
+	Quotes []uint64 `json:"quotes"`
+}
+
+// ResultGetPegnetRatesVerbose is returned by get-pegnet-rates if `verbose` is
+// set. `Quote` is the asset the rates are priced in. `Winners` are the entry
+// hashes of the winning OPRs. Heights synced before the winning quotes were
+// recorded have no winners or quotes.
+type ResultGetPegnetRatesVerbose struct {
+	Height  uint32                          `json:"height"`
+	Quote   string                          `json:"quote"`
+	Winners []string                        `json:"winners"`
+	Rates   map[string]ResultRateProvenance `json:"rates"`
+}
+
+func (s *APIServer) sendTransaction(ctx context.Context, data json.RawMessage) (res interface{}) {
+	// Every attempt is audited, including the invalid and failed ones
+	var record pegnet.SendAudit
+	defer func() {
+		if r := recover(); r != nil {
+			record.Outcome, record.Error = pegnet.AuditFailed, fmt.Sprint(r)
+			s.audit(ctx, record)
+			panic(r)
+		}
+		record.Outcome, record.Error = auditOutcome(res, record.DryRun)
+		s.audit(ctx, record)
+	}()
+
+	params := ParamsSendTransaction{}
+	_, _, err := validate(data, &params)
+	if err != nil {
+		return err
+	}
+	record.DryRun = params.DryRun
+	// defer put()
+
+	entry := params.Entry()
+	entry.ChainID = &node.TransactionChain
+	record.EntryHash = entryHash(entry)
+	if cost, err := entry.Cost(); err == nil {
+		record.ECCost = cost
+	}
+
+	// Only the size of the metadata, the accounts of the inputs, and the
+	// signing policies are checked here, the transactions are validated when
+	// they are synced
+	var txs []fat2.Transaction
+	if batch, err := fat2.NewTransactionBatch(entry, -1); err == nil {
+		txs = batch.Transactions
+		for i, tx := range batch.Transactions {
+			if err := tx.ValidMetadata(); err != nil {
+				rerr := ErrorInvalidTransaction
+				rerr.Data = fmt.Sprintf("transaction at index %d: %v", i, err)
+				return rerr
+			}
+			if err := s.checkCanSign(ctx, tx.Input.Address); err != nil {
+				return err
+			}
+		}
+	}
+	// TODO: attempt to apply
+	//txErr, err := attemptApplyFAT2TxBatch(chain, entry)
+	//if err != nil {
 	//	panic(err)
 	//}
 	//if txErr != nil {
@@ -486,23 +1817,46 @@ func (s *APIServer) sendTransaction(_ context.Context, data json.RawMessage) int
 	//}
 
 	var txID factom.Bytes32
-	if !params.DryRun {
-		balance, err := ecPrivateKey.ECAddress().GetBalance(nil, s.Node.FactomClient)
-		if err != nil {
-			panic(err)
+	if params.DryRun {
+		if _, err := s.Node.CheckPolicies(txs); err != nil {
+			return policyError(err)
 		}
-		cost, err := entry.Cost()
-		if err != nil {
-			rerr := ErrorInvalidTransaction
-			rerr.Data = err.Error()
+	} else {
+		_, err := s.Node.SubmitWithPolicies(txs, func() (*factom.Bytes32, error) {
+			cost, err := entry.Cost()
+			if err != nil {
+				rerr := ErrorInvalidTransaction
+				rerr.Data = err.Error()
+				return nil, rerr
+			}
+			// The pool rotates to the next key once one is exhausted
+			ecKey, err := s.Node.PickECKey(ctx, cost)
+			switch err {
+			case nil:
+			case keystore.ErrLocked:
+				rerr := ErrorNoEC
+				rerr.Data = err.Error()
+				return nil, rerr
+			case node.ErrECExhausted:
+				log.WithField("cost", cost).Error("send-transaction: no ec key has enough entry credits")
+				rerr := ErrorNoEC
+				rerr.Data = err.Error()
+				return nil, rerr
+			default:
+				panic(err) // This is an internal error
+			}
+			txID, err = ecKey.ComposeCreate(ctx, s.Node.FactomClient, &entry)
+			if err != nil {
+				panic(err)
+			}
+			s.Node.RecordECUse(ecKey.ECAddress(), cost)
+			return entry.Hash, nil
+		})
+		if rerr, ok := err.(jrpc.Error); ok {
 			return rerr
 		}
-		if balance < uint64(cost) {
-			return ErrorNoEC
-		}
-		txID, err = entry.ComposeCreate(nil, s.Node.FactomClient, ecPrivateKey)
 		if err != nil {
-			panic(err)
+			return policyError(err)
 		}
 	}
 
@@ -511,7 +1865,6 @@ func (s *APIServer) sendTransaction(_ context.Context, data json.RawMessage) int
 		TxID    *factom.Bytes32 `json:"txid,omitempty"`
 		Hash    *factom.Bytes32 `json:"entryhash"`
 	}{ChainID: entry.ChainID, TxID: &txID, Hash: entry.Hash}
-	return nil
 }
 
 //func attemptApplyFAT2TxBatch(chain *engine.Chain, e factom.Entry) (txErr, err error) {
@@ -534,18 +1887,469 @@ func (s *APIServer) sendTransaction(_ context.Context, data json.RawMessage) int
 //	return
 //}
 
+// RateChangesDefaultRange is the default amount of blocks get-rate-changes
+// looks back, roughly one day
+const RateChangesDefaultRange = 144
+
+type ResultRateChange struct {
+	Asset   string  `json:"asset"`
+	From    uint64  `json:"from"`
+	To      uint64  `json:"to"`
+	Percent float64 `json:"percent"`
+}
+
+// ResultGetRateChanges returns the change of every asset's rate between the
+// two rate heights, sorted by the magnitude of the change. The heights are the
+// most recent heights with rates at or below the requested heights. Assets
+// without a rate at either height are omitted.
+type ResultGetRateChanges struct {
+	FromHeight uint32             `json:"fromheight"`
+	ToHeight   uint32             `json:"toheight"`
+	Changes    []ResultRateChange `json:"changes"`
+}
+
+func (s *APIServer) getRateChanges(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetRateChanges{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.To == 0 {
+		params.To = int(s.Node.GetCurrentSync())
+	}
+	if params.From == 0 {
+		params.From = params.To - RateChangesDefaultRange
+		if params.From < 0 {
+			params.From = 0
+		}
+	}
+
+	to, toHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(ctx, s.Node.Pegnet.DB, uint32(params.To)+1)
+	if err != nil {
+		return err
+	}
+	from, fromHeight, err := s.Node.Pegnet.SelectMostRecentRatesBeforeHeight(ctx, s.Node.Pegnet.DB, uint32(params.From)+1)
+	if err != nil {
+		return err
+	}
+	if toHeight == 0 || fromHeight == 0 {
+		return ErrorNotFound
+	}
+
+	res := ResultGetRateChanges{FromHeight: fromHeight, ToHeight: toHeight, Changes: []ResultRateChange{}}
+	for ticker, rate := range to {
+		if ticker == fat2.PTickerInvalid || from[ticker] == 0 || rate == 0 {
+			continue
+		}
+		res.Changes = append(res.Changes, ResultRateChange{
+			Asset:   ticker.String(),
+			From:    from[ticker],
+			To:      rate,
+			Percent: (float64(rate) - float64(from[ticker])) / float64(from[ticker]) * 100,
+		})
+	}
+	sort.Slice(res.Changes, func(i, j int) bool {
+		a, b := math.Abs(res.Changes[i].Percent), math.Abs(res.Changes[j].Percent)
+		if a == b {
+			return res.Changes[i].Asset < res.Changes[j].Asset
+		}
+		return a > b
+	})
+	return res
+}
+
+// ResultGetSyncStatus returns the sync status of the node.
+// `BlocksSinceRate` is the amount of synced blocks since the most recent
+// rates, -1 if there are no rates yet. Conversions are delayed until the
+// next block with rates.
 type ResultGetSyncStatus struct {
-	Sync    uint32 `json:"syncheight"`
-	Current int32  `json:"factomheight"`
+	Sync            uint32 `json:"syncheight"`
+	Current         int32  `json:"factomheight"`
+	BlocksSinceRate int32  `json:"blockssincerate"`
 }
 
-func (s *APIServer) getSyncStatus(_ context.Context, data json.RawMessage) interface{} {
+func (s *APIServer) getSyncStatus(ctx context.Context, data json.RawMessage) interface{} {
+	sync := s.Node.GetCurrentSync()
+	res := ResultGetSyncStatus{Sync: sync, Current: -1, BlocksSinceRate: -1}
+	if rateHeight, err := s.Node.Pegnet.SelectLatestRateHeight(ctx, nil); err == nil && rateHeight > 0 && rateHeight <= sync {
+		res.BlocksSinceRate = int32(sync - rateHeight)
+	}
+
 	heights := new(factom.Heights)
 	err := heights.Get(nil, s.Node.FactomClient)
 	if err != nil {
-		return ResultGetSyncStatus{Sync: s.Node.GetCurrentSync(), Current: -1}
+		return res
+	}
+	res.Current = int32(heights.DirectoryBlock)
+	return res
+}
+
+func (s *APIServer) getECStatus(ctx context.Context, data json.RawMessage) interface{} {
+	if s.Node.ECMonitor == nil {
+		rerr := ErrorNoEC
+		rerr.Data = "pegnetd has no ec keys"
+		return rerr
+	}
+	status := s.Node.ECMonitor.Status()
+	status.Keys = s.Node.ECKeyStats()
+	return status
+}
+
+// ResultGetPriceChecks are the price checks of the node, newest first.
+// `Flags` is the amount of flagged heights, `LatestFlag` the latest of them.
+// `Next` is the `before` of the next page, 0 if there is none.
+type ResultGetPriceChecks struct {
+	node.PriceCheckStatus
+	Flags      int                 `json:"flags"`
+	LatestFlag uint32              `json:"latestflag,omitempty"`
+	Checks     []pegnet.PriceCheck `json:"checks"`
+	Next       uint32              `json:"next,omitempty"`
+}
+
+func (s *APIServer) getPriceChecks(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetPriceChecks{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if s.Node.PriceChecker == nil {
+		return ErrorPriceCheckDisabled
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	checks, err := s.Node.Pegnet.SelectPriceChecks(ctx, params.Flagged, uint32(params.Before), limit)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	res := ResultGetPriceChecks{PriceCheckStatus: s.Node.PriceChecker.Status(), Checks: checks}
+	res.Flags, res.LatestFlag, err = s.Node.Pegnet.SelectPriceFlags(ctx)
+	if err != nil {
+		panic(err) // This is an internal error
+	}
+	if res.Checks == nil {
+		res.Checks = []pegnet.PriceCheck{}
+	}
+	if len(checks) == limit {
+		res.Next = checks[len(checks)-1].Height
+	}
+	return res
+}
+
+// ResultGetRateGaps returns the heights without rates in a range
+type ResultGetRateGaps struct {
+	Start uint32           `json:"start"`
+	Stop  uint32           `json:"stop"`
+	Gaps  []pegnet.RateGap `json:"gaps"`
+}
+
+func (s *APIServer) getRateGaps(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetRateGaps{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	if params.Stop == 0 {
+		params.Stop = int(s.Node.GetCurrentSync())
+	}
+
+	gaps, err := s.Node.Pegnet.SelectRateGaps(ctx, uint32(params.Start), uint32(params.Stop))
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return ResultGetRateGaps{Start: uint32(params.Start), Stop: uint32(params.Stop), Gaps: gaps}
+}
+
+func (s *APIServer) addDepositAddress(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsDepositAddress{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	adr, _ := underlyingFA(params.Address)
+
+	confirmations := s.Config.GetUint32(config.DepositConfirmations)
+	if params.Confirmations != nil {
+		confirmations = *params.Confirmations
+	}
+
+	// Deposits are recorded starting with the next synced block
+	added := s.Node.GetCurrentSync() + 1
+	if err := s.Node.Pegnet.InsertDepositAddress(nil, adr, confirmations, added); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return pegnet.DepositAddress{Address: adr, Confirmations: confirmations, Added: added}
+}
+
+func (s *APIServer) removeDepositAddress(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsDepositAddress{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	adr, _ := underlyingFA(params.Address)
+
+	removed, err := s.Node.Pegnet.DeleteDepositAddress(nil, adr)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if !removed {
+		return ErrorAddressNotFound
+	}
+	return true
+}
+
+func (s *APIServer) getDepositAddresses(ctx context.Context, data json.RawMessage) interface{} {
+	if _, _, err := validate(data, nil); err != nil {
+		return err
+	}
+
+	addresses, err := s.Node.Pegnet.SelectDepositAddresses(nil)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if addresses == nil {
+		addresses = []pegnet.DepositAddress{}
+	}
+	return addresses
+}
+
+// ResultDeposit is a deposit with its current confirmations
+type ResultDeposit struct {
+	pegnet.Deposit
+	Confirmations uint32 `json:"confirmations"`
+}
+
+// ResultListDeposits is a page of deposits. `Next` is the `after` of the
+// next page, 0 if there are no more deposits.
+type ResultListDeposits struct {
+	Height   uint32          `json:"height"`
+	Deposits []ResultDeposit `json:"deposits"`
+	Next     int64           `json:"next,omitempty"`
+}
+
+func (s *APIServer) listDeposits(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsListDeposits{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	var adr *factom.FAAddress
+	if params.Address != "" {
+		a, _ := underlyingFA(params.Address) // Checked by IsValid
+		adr = &a
+	}
+	limit := params.Limit
+	if limit == 0 {
+		limit = pegnet.DepositsLimit
+	}
+
+	deposits, err := s.Node.Pegnet.SelectDeposits(ctx, uint32(params.Since), adr, params.After, limit)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	height := s.Node.GetCurrentSync()
+	res := ResultListDeposits{Height: height, Deposits: make([]ResultDeposit, len(deposits))}
+	for i, d := range deposits {
+		res.Deposits[i] = ResultDeposit{Deposit: d}
+		if height >= d.Height {
+			res.Deposits[i].Confirmations = height - d.Height + 1
+		}
+	}
+	if len(deposits) == limit {
+		res.Next = deposits[len(deposits)-1].ID
+	}
+	return res
+}
+
+// ResultGetAlerts is a page of alerts. `Next` is the `after` of the next
+// page, 0 if there are no more alerts.
+type ResultGetAlerts struct {
+	Height uint32         `json:"height"`
+	Alerts []pegnet.Alert `json:"alerts"`
+	Next   int64          `json:"next,omitempty"`
+}
+
+func (s *APIServer) getAlerts(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsGetAlerts{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = pegnet.AlertsLimit
+	}
+
+	alerts, err := s.Node.Pegnet.SelectAlerts(ctx, uint32(params.Since), params.After, limit)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	res := ResultGetAlerts{Height: s.Node.GetCurrentSync(), Alerts: alerts}
+	if res.Alerts == nil {
+		res.Alerts = []pegnet.Alert{}
+	}
+	if len(alerts) == limit {
+		res.Next = alerts[len(alerts)-1].ID
+	}
+	return res
+}
+
+// ResultExportStatement is the history of an address as a document that
+// can be imported by accounting software
+type ResultExportStatement struct {
+	Format  string `json:"format"`
+	Count   int    `json:"count"`
+	Content string `json:"content"`
+}
+
+func (s *APIServer) exportStatement(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsExportStatement{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if params.Format == "" {
+		params.Format = StatementCSV
+	}
+
+	addr, _ := underlyingFA(params.Address) // Already validated
+	history, err := s.Node.Pegnet.SelectTransactionHistoryActionsByAddressExecuted(ctx, &addr)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	// The balances are computed over the whole history before filtering
+	var entries []StatementEntry
+	for _, e := range statementEntries(addr, history) {
+		if params.Asset != "" && e.Asset != params.Asset {
+			continue
+		}
+		if int(e.Height) < params.Start || (params.Stop != 0 && int(e.Height) > params.Stop) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	var sb strings.Builder
+	if params.Format == StatementOFX {
+		err = writeStatementOFX(&sb, addr.String(), entries, time.Now())
+	} else {
+		err = writeStatementCSV(&sb, entries)
+	}
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return ResultExportStatement{Format: params.Format, Count: len(entries), Content: sb.String()}
+}
+
+// checkSchedulerToken compares the token to the configured scheduler token
+func (s *APIServer) checkSchedulerToken(token string) error {
+	return s.checkToken(config.SchedulerToken, ErrorSchedulerDisabled, token)
+}
+
+func (s *APIServer) addSchedule(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsAddSchedule{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkSchedulerToken(params.Token); err != nil {
+		return err
+	}
+
+	input, _ := factom.NewFAAddress(params.Input)
+	if err := s.checkCanSign(ctx, input); err != nil {
+		return err
+	}
+	if watched, err := s.Node.Pegnet.IsWatchAddress(nil, input); err != nil {
+		panic(err) // This is an internal error
+	} else if watched {
+		rerr := ErrorAccountDenied
+		rerr.Data = fmt.Sprintf("%s is a watch-only address", input)
+		return rerr
+	}
+	output, _ := underlyingFA(params.Output)
+	asset := fat2.StringToTicker(params.Asset)
+
+	// The daily limits are checked again on every transfer, here a single
+	// transfer must not exceed them
+	tx := fat2.Transaction{
+		Input:     fat2.TypedAddressAmountTuple{Address: input, Amount: params.Amount, Type: asset},
+		Transfers: []fat2.AddressAmountTuple{{Address: output, Amount: params.Amount}},
+	}
+	if _, err := s.Node.Policies.Check([]fat2.Transaction{tx}, func(factom.FAAddress, string) (uint64, error) {
+		return 0, nil
+	}); err != nil {
+		rerr := policyError(err)
+		s.audit(ctx, pegnet.SendAudit{Outcome: pegnet.AuditDenied, Error: fmt.Sprintf("add-schedule: %s: %v", rerr.Message, rerr.Data)})
+		return rerr
+	}
+
+	schedule := pegnet.Schedule{
+		Input:     input,
+		Asset:     asset.String(),
+		Amount:    params.Amount,
+		Output:    output,
+		Interval:  params.Interval,
+		Next:      params.Start,
+		Remaining: params.Count,
+		Created:   s.Node.GetCurrentSync(),
+	}
+	if params.Days > 0 {
+		schedule.Interval = params.Days * BlocksPerDay
+	}
+	// The soonest a transfer can be included is the next block
+	if schedule.Next <= schedule.Created {
+		schedule.Next = schedule.Created + 1
+	}
+	if schedule.Remaining == 0 {
+		schedule.Remaining = -1
+	}
+
+	id, err := s.Node.Pegnet.InsertSchedule(nil, schedule)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	schedule.ID = id
+	return schedule
+}
+
+func (s *APIServer) removeSchedule(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsRemoveSchedule{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkSchedulerToken(params.Token); err != nil {
+		return err
+	}
+
+	removed, err := s.Node.Pegnet.DeleteSchedule(nil, params.ID)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if !removed {
+		return ErrorNotFound
+	}
+	return true
+}
+
+func (s *APIServer) listSchedules(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsSchedules{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkSchedulerToken(params.Token); err != nil {
+		return err
+	}
+
+	schedules, err := s.Node.Pegnet.SelectSchedules(nil)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	if schedules == nil {
+		schedules = []pegnet.Schedule{}
 	}
-	return ResultGetSyncStatus{Sync: s.Node.GetCurrentSync(), Current: int32(heights.DirectoryBlock)}
+	return schedules
 }
 
 // TODO: Re-eval this function. The chain data that is supplied needs to be reimplemented
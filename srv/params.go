@@ -24,11 +24,13 @@ package srv
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
 	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node"
 	"github.com/pegnet/pegnetd/node/pegnet"
 )
 
@@ -59,7 +61,7 @@ func (p ParamsGetMiningDominance) HasIncludePending() bool { return false }
 func (p ParamsGetMiningDominance) IsValid() error {
 	if p.Stop < p.Start {
 		if !(p.Stop < 0 && p.Start == 0 || p.Start > 0 && p.Stop == 0) {
-			return fmt.Errorf("stop must be >= start")
+			return jrpc.ErrorInvalidParams("stop must be >= start")
 		}
 	}
 	return nil
@@ -70,6 +72,9 @@ func (p ParamsGetMiningDominance) ValidChainID() *factom.Bytes32 {
 
 type ParamsGetGlobalRichList struct {
 	Count int `json:"count,omitempty"`
+	// Quote is the asset to rank and denominate the balances in, in
+	// addition to pUSD
+	Quote string `json:"quote,omitempty"`
 }
 
 func (p ParamsGetGlobalRichList) HasIncludePending() bool { return false }
@@ -77,6 +82,9 @@ func (p ParamsGetGlobalRichList) IsValid() error {
 	if p.Count < 0 {
 		return jrpc.ErrorInvalidParams("count must be >= 0")
 	}
+	if p.Quote != "" && fat2.StringToTicker(p.Quote) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid quote asset")
+	}
 	return nil
 }
 func (p ParamsGetGlobalRichList) ValidChainID() *factom.Bytes32 {
@@ -86,6 +94,11 @@ func (p ParamsGetGlobalRichList) ValidChainID() *factom.Bytes32 {
 type ParamsGetRichList struct {
 	Asset string `json:"asset,omitempty"`
 	Count int    `json:"count,omitempty"`
+	// Quote is the asset to denominate the balances in, in addition to pUSD
+	Quote string `json:"quote,omitempty"`
+	// Height returns the rich list as of a past height, valued at the
+	// rates of that height
+	Height uint32 `json:"height,omitempty"`
 }
 
 func (p ParamsGetRichList) HasIncludePending() bool { return false }
@@ -97,12 +110,423 @@ func (p ParamsGetRichList) IsValid() error {
 	if p.Count < 0 {
 		return jrpc.ErrorInvalidParams("count must be >= 0")
 	}
+	if p.Quote != "" && fat2.StringToTicker(p.Quote) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid quote asset")
+	}
 	return nil
 }
 func (p ParamsGetRichList) ValidChainID() *factom.Bytes32 {
 	return nil
 }
 
+type ParamsGetHolders struct {
+	Asset  string `json:"asset,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+func (p ParamsGetHolders) HasIncludePending() bool { return false }
+func (p ParamsGetHolders) IsValid() error {
+	if fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	if p.Offset < 0 {
+		return jrpc.ErrorInvalidParams("offset must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.HoldersLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.HoldersLimit))
+	}
+	return nil
+}
+func (p ParamsGetHolders) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetDistributionStats returns the distribution of every asset, or only
+// of `asset` if it is set
+type ParamsGetDistributionStats struct {
+	Asset string `json:"asset,omitempty"`
+}
+
+func (p ParamsGetDistributionStats) HasIncludePending() bool { return false }
+func (p ParamsGetDistributionStats) IsValid() error {
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	return nil
+}
+func (p ParamsGetDistributionStats) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetPegnetIssuance returns the current issuance, or the issuance at
+// the end of `height` if it is set
+type ParamsGetPegnetIssuance struct {
+	Height int `json:"height,omitempty"`
+}
+
+func (p ParamsGetPegnetIssuance) HasIncludePending() bool { return false }
+func (p ParamsGetPegnetIssuance) IsValid() error {
+	if p.Height < 0 {
+		return jrpc.ErrorInvalidParams("height must be >= 0")
+	}
+	return nil
+}
+func (p ParamsGetPegnetIssuance) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetSupplyHistory struct {
+	Start int    `json:"start,omitempty"`
+	Stop  int    `json:"stop,omitempty"`
+	Asset string `json:"asset,omitempty"`
+}
+
+func (p ParamsGetSupplyHistory) HasIncludePending() bool { return false }
+func (p ParamsGetSupplyHistory) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	return nil
+}
+func (p ParamsGetSupplyHistory) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetSupplyComposition returns the composition of the supply of every
+// asset, or only of `asset` if it is set
+type ParamsGetSupplyComposition struct {
+	Asset string `json:"asset,omitempty"`
+}
+
+func (p ParamsGetSupplyComposition) HasIncludePending() bool { return false }
+func (p ParamsGetSupplyComposition) IsValid() error {
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	return nil
+}
+func (p ParamsGetSupplyComposition) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetNetworkStats takes dates in the format "2006-01-02".
+// The interval is either "day" or "week", where "day" is the default.
+type ParamsGetNetworkStats struct {
+	Start    string `json:"start"`
+	Stop     string `json:"stop,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+func (p ParamsGetNetworkStats) HasIncludePending() bool { return false }
+func (p ParamsGetNetworkStats) IsValid() error {
+	start, err := time.Parse(NetworkStatsDateFormat, p.Start)
+	if err != nil {
+		return jrpc.ErrorInvalidParams("start: " + err.Error())
+	}
+	if p.Stop != "" {
+		stop, err := time.Parse(NetworkStatsDateFormat, p.Stop)
+		if err != nil {
+			return jrpc.ErrorInvalidParams("stop: " + err.Error())
+		}
+		if stop.Before(start) {
+			return jrpc.ErrorInvalidParams("stop must be >= start")
+		}
+	}
+	if p.Interval != "" && p.Interval != "day" && p.Interval != "week" {
+		return jrpc.ErrorInvalidParams(`interval must be "day" or "week"`)
+	}
+	return nil
+}
+func (p ParamsGetNetworkStats) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetConversionVolume struct {
+	Start int    `json:"start,omitempty"`
+	Stop  int    `json:"stop,omitempty"`
+	Asset string `json:"asset,omitempty"`
+}
+
+func (p ParamsGetConversionVolume) HasIncludePending() bool { return false }
+func (p ParamsGetConversionVolume) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	return nil
+}
+func (p ParamsGetConversionVolume) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetBlock returns the contents of the block at the height
+type ParamsGetBlock struct {
+	Height int `json:"height"`
+}
+
+func (p ParamsGetBlock) HasIncludePending() bool { return false }
+func (p ParamsGetBlock) IsValid() error {
+	if p.Height <= 0 {
+		return jrpc.ErrorInvalidParams(`required: "height" > 0`)
+	}
+	return nil
+}
+func (p ParamsGetBlock) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetBlocks lists the blocks in [start, stop]. Stop defaults to the
+// synced height, and start to the 20 blocks before stop.
+type ParamsGetBlocks struct {
+	Start int  `json:"start,omitempty"`
+	Stop  int  `json:"stop,omitempty"`
+	Desc  bool `json:"desc,omitempty"`
+}
+
+func (p ParamsGetBlocks) HasIncludePending() bool { return false }
+func (p ParamsGetBlocks) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	if p.Start != 0 && p.Stop != 0 && p.Stop-p.Start >= pegnet.BlocksLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("range is limited to %d blocks", pegnet.BlocksLimit))
+	}
+	return nil
+}
+func (p ParamsGetBlocks) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetBurns struct {
+	Address string `json:"address,omitempty"`
+	Start   int    `json:"start,omitempty"`
+	Stop    int    `json:"stop,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+}
+
+func (p ParamsGetBurns) HasIncludePending() bool { return false }
+func (p ParamsGetBurns) IsValid() error {
+	if p.Address != "" {
+		if _, err := underlyingFA(p.Address); err != nil {
+			return jrpc.ErrorInvalidParams("address: " + err.Error())
+		}
+	}
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	if p.Offset < 0 {
+		return jrpc.ErrorInvalidParams("offset must be >= 0")
+	}
+	return nil
+}
+func (p ParamsGetBurns) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetMalformedBurns struct {
+	Address string `json:"address,omitempty"`
+	Start   int    `json:"start,omitempty"`
+	Stop    int    `json:"stop,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+}
+
+func (p ParamsGetMalformedBurns) HasIncludePending() bool { return false }
+func (p ParamsGetMalformedBurns) IsValid() error {
+	return ParamsGetBurns(p).IsValid()
+}
+func (p ParamsGetMalformedBurns) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetOPRStats struct {
+	Start int `json:"start"`
+	Stop  int `json:"stop,omitempty"`
+}
+
+func (p ParamsGetOPRStats) HasIncludePending() bool { return false }
+func (p ParamsGetOPRStats) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	return nil
+}
+func (p ParamsGetOPRStats) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetMiner selects the totals of the coinbase address and its heights
+// below the height `Before`
+type ParamsGetMiner struct {
+	Address string `json:"address"`
+	Before  int    `json:"before,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+func (p ParamsGetMiner) HasIncludePending() bool { return false }
+func (p ParamsGetMiner) IsValid() error {
+	if _, err := factom.NewFAAddress(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if p.Before < 0 {
+		return jrpc.ErrorInvalidParams("before must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.MinersLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.MinersLimit))
+	}
+	return nil
+}
+func (p ParamsGetMiner) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetMiners struct {
+	Start int `json:"start"`
+	Stop  int `json:"stop,omitempty"`
+	Limit int `json:"limit,omitempty"`
+}
+
+func (p ParamsGetMiners) HasIncludePending() bool { return false }
+func (p ParamsGetMiners) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.MinersLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.MinersLimit))
+	}
+	return nil
+}
+func (p ParamsGetMiners) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetRateGaps struct {
+	Start int `json:"start"`
+	Stop  int `json:"stop,omitempty"`
+}
+
+func (p ParamsGetRateGaps) HasIncludePending() bool { return false }
+func (p ParamsGetRateGaps) IsValid() error {
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	return nil
+}
+func (p ParamsGetRateGaps) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetRateChanges compares the rates of two heights. `To` defaults to
+// the latest synced height and `From` to RateChangesDefaultRange blocks
+// before `To`.
+type ParamsGetRateChanges struct {
+	From int `json:"from,omitempty"`
+	To   int `json:"to,omitempty"`
+}
+
+func (p ParamsGetRateChanges) HasIncludePending() bool { return false }
+func (p ParamsGetRateChanges) IsValid() error {
+	if p.From < 0 || p.To < 0 {
+		return jrpc.ErrorInvalidParams("from and to must be >= 0")
+	}
+	if p.To != 0 && p.To < p.From {
+		return jrpc.ErrorInvalidParams("to must be >= from")
+	}
+	return nil
+}
+func (p ParamsGetRateChanges) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsGetLedger struct {
+	Height int    `json:"height,omitempty"`
+	Asset  string `json:"asset,omitempty"`
+	After  string `json:"after,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+func (p ParamsGetLedger) HasIncludePending() bool { return false }
+func (p ParamsGetLedger) IsValid() error {
+	if p.Height < 0 {
+		return jrpc.ErrorInvalidParams("height must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.LedgerLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.LedgerLimit))
+	}
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	if p.After != "" {
+		if _, err := factom.NewFAAddress(p.After); err != nil {
+			return jrpc.ErrorInvalidParams(fmt.Sprintf("invalid after address: %v", err))
+		}
+	}
+	return nil
+}
+func (p ParamsGetLedger) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetBalanceHistory samples the balance of an address in one asset
+// every `interval` heights, ending at stop. Stop defaults to the synced
+// height, the interval to a day, and start to 100 points before stop.
+type ParamsGetBalanceHistory struct {
+	Address  string `json:"address"`
+	Asset    string `json:"asset"`
+	Start    int    `json:"start,omitempty"`
+	Stop     int    `json:"stop,omitempty"`
+	Interval int    `json:"interval,omitempty"`
+}
+
+func (p ParamsGetBalanceHistory) HasIncludePending() bool { return false }
+func (p ParamsGetBalanceHistory) IsValid() error {
+	if p.Address == "" {
+		return jrpc.ErrorInvalidParams(`required: "address"`)
+	}
+	if err := validAddress(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	if p.Start < 0 || p.Stop < 0 || p.Interval < 0 {
+		return jrpc.ErrorInvalidParams("start, stop, and interval must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	return nil
+}
+func (p ParamsGetBalanceHistory) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
 // ParamsToken scopes a request down to a single FAT token using either the
 // ChainID or both the TokenID and the IssuerChainID.
 type ParamsToken struct {
@@ -143,8 +567,12 @@ func (p ParamsGetTransaction) IsValid() error {
 	return nil
 }
 
+// ParamsGetPegnetRates returns the rates at the height. If `quote` is set,
+// the rates are priced in that asset instead of USD.
 type ParamsGetPegnetRates struct {
-	Height uint32 `json:"height,omitempty"`
+	Height  uint32 `json:"height,omitempty"`
+	Verbose bool   `json:"verbose,omitempty"`
+	Quote   string `json:"quote,omitempty"`
 }
 
 func (ParamsGetPegnetRates) HasIncludePending() bool { return false }
@@ -153,20 +581,73 @@ func (p ParamsGetPegnetRates) IsValid() error {
 	//if p.Height == nil {
 	//	return jrpc.ErrorInvalidParams(`required: "height"`)
 	//}
+	if p.Quote != "" && fat2.StringToTicker(p.Quote) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid quote asset")
+	}
 	return nil
 }
 func (ParamsGetPegnetRates) ValidChainID() *factom.Bytes32 {
 	return nil
 }
 
+type ParamsGetConversionResult struct {
+	Hash *factom.Bytes32 `json:"entryhash,omitempty"`
+}
+
+func (p ParamsGetConversionResult) HasIncludePending() bool { return false }
+func (p ParamsGetConversionResult) IsValid() error {
+	if p.Hash == nil {
+		return jrpc.ErrorInvalidParams(`required: "entryhash"`)
+	}
+	return nil
+}
+func (p ParamsGetConversionResult) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetTransactionsByHashes looks up the history of many entry hashes
+// at once
+type ParamsGetTransactionsByHashes struct {
+	Hashes []*factom.Bytes32 `json:"entryhashes"`
+}
+
+func (p ParamsGetTransactionsByHashes) HasIncludePending() bool { return false }
+func (p ParamsGetTransactionsByHashes) IsValid() error {
+	if len(p.Hashes) == 0 {
+		return jrpc.ErrorInvalidParams(`required: "entryhashes"`)
+	}
+	if len(p.Hashes) > pegnet.HashesLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("at most %d entryhashes can be looked up at once", pegnet.HashesLimit))
+	}
+	for _, hash := range p.Hashes {
+		if hash == nil {
+			return jrpc.ErrorInvalidParams("entryhashes must not be null")
+		}
+	}
+	return nil
+}
+func (p ParamsGetTransactionsByHashes) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
 type ParamsGetPegnetTransactionStatus struct {
 	Hash *factom.Bytes32 `json:"entryhash,omitempty"`
+	// TxID is in the format #-[Entryhash], the status is the one of the batch
+	TxID string `json:"txid,omitempty"`
 }
 
 func (p ParamsGetPegnetTransactionStatus) HasIncludePending() bool { return false }
 func (p ParamsGetPegnetTransactionStatus) IsValid() error {
-	if p.Hash == nil {
-		return jrpc.ErrorInvalidParams(`required: "entryhash"`)
+	if p.Hash == nil && p.TxID == "" {
+		return jrpc.ErrorInvalidParams(`required: "entryhash" or "txid"`)
+	}
+	if p.Hash != nil && p.TxID != "" {
+		return jrpc.ErrorInvalidParams(`cannot specify both "entryhash" and "txid"`)
+	}
+	if p.TxID != "" {
+		if _, _, err := pegnet.SplitTxID(p.TxID); err != nil {
+			return jrpc.ErrorInvalidParams("txid: " + err.Error())
+		}
 	}
 	return nil
 }
@@ -190,11 +671,17 @@ type ParamsGetPegnetTransaction struct {
 	Coinbase   bool   `json:"coinbase,omitempty"`
 	Burn       bool   `json:"burn,omitempty"`
 	Asset      string `json:"asset,omitempty"`
+	// Refund returns the refunds of conversions instead of the actions
+	Refund bool `json:"refund,omitempty"`
+	// Sort is either "height" (default) or "amount", in the order of `desc`
+	Sort      string `json:"sort,omitempty"`
+	MinAmount int64  `json:"minamount,omitempty"`
+	MaxAmount int64  `json:"maxamount,omitempty"`
+	// Direction is "sent", "received" or "both" (default), only for addresses
+	Direction string `json:"direction,omitempty"`
 
 	// TxID is in the format #-[Entryhash], where '#' == tx index
 	TxID string `json:"txid,omitempty"`
-	// Used by the server to store the entryhash in the txid
-	txEntryHash string
 }
 
 func (p ParamsGetPegnetTransaction) HasIncludePending() bool { return false }
@@ -230,9 +717,27 @@ func (p ParamsGetPegnetTransaction) IsValid() error {
 		}
 	}
 
+	if p.Sort != "" && p.Sort != pegnet.SortHeight && p.Sort != pegnet.SortAmount {
+		return jrpc.ErrorInvalidParams(`sort must be "height" or "amount"`)
+	}
+	if p.MinAmount < 0 || p.MaxAmount < 0 {
+		return jrpc.ErrorInvalidParams(`minamount and maxamount must be >= 0`)
+	}
+	if p.MaxAmount > 0 && p.MaxAmount < p.MinAmount {
+		return jrpc.ErrorInvalidParams(`maxamount must be >= minamount`)
+	}
+	switch p.Direction {
+	case "", pegnet.DirectionBoth, pegnet.DirectionSent, pegnet.DirectionReceived:
+	default:
+		return jrpc.ErrorInvalidParams(`direction must be "sent", "received", or "both"`)
+	}
+	if p.Direction != "" && p.Address == "" {
+		return jrpc.ErrorInvalidParams(`direction can only be used with "address"`)
+	}
+
 	// error check input
 	if p.Address != "" {
-		if _, err := underlyingFA(p.Address); err != nil {
+		if err := validAddress(p.Address); err != nil {
 			return jrpc.ErrorInvalidParams("address: " + err.Error())
 		}
 	}
@@ -257,23 +762,100 @@ func (p ParamsGetPegnetTransaction) ValidChainID() *factom.Bytes32 {
 
 type ParamsGetPegnetBalances struct {
 	Address string `json:"address,omitempty"`
+	// IncludePending adds the balances after the unconfirmed transactions
+	IncludePending bool `json:"includepending,omitempty"`
+	// IncludeUSD adds the pUSD value of every balance at the latest rates
+	// as of `height`, which defaults to the synced height
+	IncludeUSD bool   `json:"includeusd,omitempty"`
+	Height     uint32 `json:"height,omitempty"`
 }
 
-func (p ParamsGetPegnetBalances) HasIncludePending() bool { return false }
+func (p ParamsGetPegnetBalances) HasIncludePending() bool { return p.IncludePending }
 
 func (p ParamsGetPegnetBalances) IsValid() error {
 	if p.Address == "" {
 		return jrpc.ErrorInvalidParams(`required: "address"`)
 	}
-	if _, err := underlyingFA(p.Address); err != nil {
+	if err := validAddress(p.Address); err != nil {
 		return jrpc.ErrorInvalidParams("address: " + err.Error())
 	}
+	if p.Height != 0 && !p.IncludeUSD {
+		return jrpc.ErrorInvalidParams(`"height" requires "includeusd"`)
+	}
 	return nil
 }
 func (p ParamsGetPegnetBalances) ValidChainID() *factom.Bytes32 {
 	return nil
 }
 
+type ParamsGetAddressStats struct {
+	Address string `json:"address,omitempty"`
+}
+
+func (p ParamsGetAddressStats) HasIncludePending() bool { return false }
+
+func (p ParamsGetAddressStats) IsValid() error {
+	if p.Address == "" {
+		return jrpc.ErrorInvalidParams(`required: "address"`)
+	}
+	if err := validAddress(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	return nil
+}
+func (p ParamsGetAddressStats) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetAddressEvents are the parameters for the balance changes of an
+// address. `offset` is the value from a previous query's `nextoffset`.
+type ParamsGetAddressEvents struct {
+	Address string `json:"address,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+	Desc    bool   `json:"desc,omitempty"`
+}
+
+func (p ParamsGetAddressEvents) HasIncludePending() bool { return false }
+func (p ParamsGetAddressEvents) IsValid() error {
+	if p.Address == "" {
+		return jrpc.ErrorInvalidParams(`required: "address"`)
+	}
+	if err := validAddress(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if p.Offset < 0 {
+		return jrpc.ErrorInvalidParams(`offset must be >= 0`)
+	}
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset filter")
+	}
+	return nil
+}
+func (p ParamsGetAddressEvents) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsSearch resolves the query as an address, an entry hash or factoid
+// burn txid, or a height
+type ParamsSearch struct {
+	Query string `json:"query"`
+}
+
+func (p ParamsSearch) HasIncludePending() bool { return false }
+func (p ParamsSearch) IsValid() error {
+	if strings.TrimSpace(p.Query) == "" {
+		return jrpc.ErrorInvalidParams(`required: "query"`)
+	}
+	if searchType(p.Query) == "" {
+		return jrpc.ErrorInvalidParams("query is not an address, entry hash, burn txid, or height")
+	}
+	return nil
+}
+func (p ParamsSearch) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
 type ParamsSendTransaction struct {
 	ParamsToken
 	ExtIDs  []factom.Bytes `json:"extids,omitempty"`
@@ -318,3 +900,400 @@ func (p *ParamsSendTransaction) IsValid() error {
 func (p ParamsSendTransaction) Entry() factom.Entry {
 	return p.entry
 }
+
+// ParamsDepositAddress adds an address to the deposit watch-list, or
+// removes it. `Confirmations` defaults to the configured confirmations.
+type ParamsDepositAddress struct {
+	Address       string  `json:"address,omitempty"`
+	Confirmations *uint32 `json:"confirmations,omitempty"`
+}
+
+func (p ParamsDepositAddress) HasIncludePending() bool { return false }
+func (p ParamsDepositAddress) IsValid() error {
+	if p.Address == "" {
+		return jrpc.ErrorInvalidParams(`required: "address"`)
+	}
+	if _, err := underlyingFA(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	return nil
+}
+func (p ParamsDepositAddress) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsListDeposits selects the deposits applied at or after `Since`,
+// optionally to a single address. Pages continue after the id `After`.
+type ParamsListDeposits struct {
+	Since   int    `json:"since,omitempty"`
+	Address string `json:"address,omitempty"`
+	After   int64  `json:"after,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+func (p ParamsListDeposits) HasIncludePending() bool { return false }
+func (p ParamsListDeposits) IsValid() error {
+	if p.Since < 0 || p.After < 0 {
+		return jrpc.ErrorInvalidParams("since and after must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.DepositsLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.DepositsLimit))
+	}
+	if p.Address != "" {
+		if _, err := underlyingFA(p.Address); err != nil {
+			return jrpc.ErrorInvalidParams("address: " + err.Error())
+		}
+	}
+	return nil
+}
+func (p ParamsListDeposits) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetAlerts selects the alerts of actions applied at or after `Since`.
+// Pages continue after the id `After`.
+type ParamsGetAlerts struct {
+	Since int   `json:"since,omitempty"`
+	After int64 `json:"after,omitempty"`
+	Limit int   `json:"limit,omitempty"`
+}
+
+func (p ParamsGetAlerts) HasIncludePending() bool { return false }
+func (p ParamsGetAlerts) IsValid() error {
+	if p.Since < 0 || p.After < 0 {
+		return jrpc.ErrorInvalidParams("since and after must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.AlertsLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.AlertsLimit))
+	}
+	return nil
+}
+func (p ParamsGetAlerts) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetPriceChecks selects the price checks below the height `Before`,
+// only the flagged ones if `Flagged` is set
+type ParamsGetPriceChecks struct {
+	Flagged bool `json:"flagged,omitempty"`
+	Before  int  `json:"before,omitempty"`
+	Limit   int  `json:"limit,omitempty"`
+}
+
+func (p ParamsGetPriceChecks) HasIncludePending() bool { return false }
+func (p ParamsGetPriceChecks) IsValid() error {
+	if p.Before < 0 {
+		return jrpc.ErrorInvalidParams("before must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.PriceChecksLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.PriceChecksLimit))
+	}
+	return nil
+}
+func (p ParamsGetPriceChecks) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+type ParamsExportStatement struct {
+	Address string `json:"address"`
+	Format  string `json:"format,omitempty"`
+	Asset   string `json:"asset,omitempty"`
+	Start   int    `json:"start,omitempty"`
+	Stop    int    `json:"stop,omitempty"`
+}
+
+func (p ParamsExportStatement) HasIncludePending() bool { return false }
+func (p ParamsExportStatement) IsValid() error {
+	if _, err := underlyingFA(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if p.Format != "" && p.Format != StatementCSV && p.Format != StatementOFX {
+		return jrpc.ErrorInvalidParams("format must be either 'csv' or 'ofx'")
+	}
+	if p.Asset != "" && fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	if p.Start < 0 || p.Stop < 0 {
+		return jrpc.ErrorInvalidParams("start and stop must be >= 0")
+	}
+	if p.Stop != 0 && p.Stop < p.Start {
+		return jrpc.ErrorInvalidParams("stop must be >= start")
+	}
+	return nil
+}
+func (p ParamsExportStatement) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// BlocksPerDay is the amount of blocks of a `Days` interval
+const BlocksPerDay = 144
+
+// ParamsAddSchedule submits a transfer every `Interval` blocks or every
+// `Days` days, starting at the `Start` height. `Count` limits the amount of
+// transfers, 0 means there is no end. The input has to be in the wallet.
+type ParamsAddSchedule struct {
+	Token    string `json:"token"`
+	Input    string `json:"input"`
+	Asset    string `json:"asset"`
+	Amount   uint64 `json:"amount"`
+	Output   string `json:"output"`
+	Interval uint32 `json:"interval,omitempty"`
+	Days     uint32 `json:"days,omitempty"`
+	Start    uint32 `json:"start,omitempty"`
+	Count    int64  `json:"count,omitempty"`
+}
+
+func (p ParamsAddSchedule) HasIncludePending() bool { return false }
+func (p ParamsAddSchedule) IsValid() error {
+	if _, err := factom.NewFAAddress(p.Input); err != nil {
+		return jrpc.ErrorInvalidParams("input: " + err.Error())
+	}
+	if _, err := underlyingFA(p.Output); err != nil {
+		return jrpc.ErrorInvalidParams("output: " + err.Error())
+	}
+	if fat2.StringToTicker(p.Asset) == fat2.PTickerInvalid {
+		return jrpc.ErrorInvalidParams("invalid asset")
+	}
+	if p.Amount == 0 {
+		return jrpc.ErrorInvalidParams("amount must be > 0")
+	}
+	if (p.Interval == 0) == (p.Days == 0) {
+		return jrpc.ErrorInvalidParams(`exactly one of "interval" and "days" is required`)
+	}
+	if p.Count < 0 {
+		return jrpc.ErrorInvalidParams("count must be >= 0")
+	}
+	return nil
+}
+func (p ParamsAddSchedule) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsSchedules lists the schedules
+type ParamsSchedules struct {
+	Token string `json:"token"`
+}
+
+func (p ParamsSchedules) HasIncludePending() bool { return false }
+func (p ParamsSchedules) IsValid() error          { return nil }
+func (p ParamsSchedules) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsRemoveSchedule removes the schedule `ID`
+type ParamsRemoveSchedule struct {
+	Token string `json:"token"`
+	ID    int64  `json:"id"`
+}
+
+func (p ParamsRemoveSchedule) HasIncludePending() bool { return false }
+func (p ParamsRemoveSchedule) IsValid() error {
+	if p.ID <= 0 {
+		return jrpc.ErrorInvalidParams("id must be > 0")
+	}
+	return nil
+}
+func (p ParamsRemoveSchedule) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetAccountBalances selects the wallet account `Account`, or every
+// account the api key of the request can see if it is empty. It is also the
+// params of list-addresses.
+type ParamsGetAccountBalances struct {
+	Account string `json:"account,omitempty"`
+}
+
+func (p ParamsGetAccountBalances) HasIncludePending() bool { return false }
+func (p ParamsGetAccountBalances) IsValid() error          { return nil }
+func (p ParamsGetAccountBalances) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// WatchLabelLimit is the maximum length of the label of a watch-only address
+const WatchLabelLimit = 100
+
+// ParamsImportWatchAddress imports the `Address` as a watch-only address of
+// the wallet account `Account`
+type ParamsImportWatchAddress struct {
+	Token   string `json:"token"`
+	Address string `json:"address"`
+	Account string `json:"account"`
+	Label   string `json:"label,omitempty"`
+}
+
+func (p ParamsImportWatchAddress) HasIncludePending() bool { return false }
+func (p ParamsImportWatchAddress) IsValid() error {
+	if _, err := underlyingFA(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if p.Account == "" {
+		return jrpc.ErrorInvalidParams(`required: "account"`)
+	}
+	if err := pegnet.ValidAccountName(p.Account); err != nil {
+		return jrpc.ErrorInvalidParams("account: " + err.Error())
+	}
+	if len(p.Label) > WatchLabelLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("label must be at most %d characters", WatchLabelLimit))
+	}
+	return nil
+}
+func (p ParamsImportWatchAddress) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsWatchAddress removes the watch-only `Address`
+type ParamsWatchAddress struct {
+	Token   string `json:"token"`
+	Address string `json:"address"`
+}
+
+func (p ParamsWatchAddress) HasIncludePending() bool { return false }
+func (p ParamsWatchAddress) IsValid() error {
+	if _, err := underlyingFA(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	return nil
+}
+func (p ParamsWatchAddress) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsGetAuditLog selects the send-transaction attempts made at or after
+// the unix time `Since`, newest first
+type ParamsGetAuditLog struct {
+	Token  string `json:"token"`
+	Since  int64  `json:"since,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+func (p ParamsGetAuditLog) HasIncludePending() bool { return false }
+func (p ParamsGetAuditLog) IsValid() error {
+	if p.Since < 0 || p.Offset < 0 {
+		return jrpc.ErrorInvalidParams("since and offset must be >= 0")
+	}
+	if p.Limit < 0 || p.Limit > pegnet.AuditLimit {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("limit must be between 0 and %d", pegnet.AuditLimit))
+	}
+	return nil
+}
+func (p ParamsGetAuditLog) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsAdmin authenticates an admin rpc without other params
+type ParamsAdmin struct {
+	Token string `json:"token"`
+}
+
+func (p ParamsAdmin) HasIncludePending() bool { return false }
+func (p ParamsAdmin) IsValid() error          { return nil }
+func (p ParamsAdmin) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsUnlockKeystore decrypts the ec keystore with the `Passphrase`
+type ParamsUnlockKeystore struct {
+	Token      string `json:"token"`
+	Passphrase string `json:"passphrase"`
+}
+
+func (p ParamsUnlockKeystore) HasIncludePending() bool { return false }
+func (p ParamsUnlockKeystore) IsValid() error {
+	if p.Passphrase == "" {
+		return jrpc.ErrorInvalidParams(`required: "passphrase"`)
+	}
+	return nil
+}
+func (p ParamsUnlockKeystore) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsSetRateOverride injects the `Rates` at the `Height`, in the units of
+// get-pegnet-rates. Empty rates remove the override.
+type ParamsSetRateOverride struct {
+	Token  string            `json:"token"`
+	Height uint32            `json:"height"`
+	Rates  map[string]uint64 `json:"rates"`
+}
+
+func (p ParamsSetRateOverride) HasIncludePending() bool { return false }
+func (p ParamsSetRateOverride) IsValid() error {
+	if p.Height == 0 {
+		return jrpc.ErrorInvalidParams(`required: "height"`)
+	}
+	if _, err := node.ParseRateOverride(p.Rates); err != nil {
+		return jrpc.ErrorInvalidParams("rates: " + err.Error())
+	}
+	return nil
+}
+func (p ParamsSetRateOverride) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsRegtestMine mines `Count` blocks, 1 by default, with the OPRs paying
+// out to the `Coinbase`
+type ParamsRegtestMine struct {
+	Token    string `json:"token,omitempty"`
+	Count    int    `json:"count,omitempty"`
+	Coinbase string `json:"coinbase,omitempty"`
+}
+
+func (p ParamsRegtestMine) HasIncludePending() bool { return false }
+func (p ParamsRegtestMine) IsValid() error {
+	if p.Count < 0 || p.Count > RegtestMaxMine {
+		return jrpc.ErrorInvalidParams(fmt.Sprintf("count must be between 0 and %d", RegtestMaxMine))
+	}
+	if p.Coinbase != "" {
+		if _, err := factom.NewFAAddress(p.Coinbase); err != nil {
+			return jrpc.ErrorInvalidParams("coinbase: " + err.Error())
+		}
+	}
+	return nil
+}
+func (p ParamsRegtestMine) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsRegtestSetRates sets the rates of the OPRs of the next blocks, in
+// USD per unit, eg {"XBT": 9300, "pEUR": 1.1}
+type ParamsRegtestSetRates struct {
+	Token string             `json:"token,omitempty"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func (p ParamsRegtestSetRates) HasIncludePending() bool { return false }
+func (p ParamsRegtestSetRates) IsValid() error {
+	if len(p.Rates) == 0 {
+		return jrpc.ErrorInvalidParams(`required: "rates"`)
+	}
+	return nil
+}
+func (p ParamsRegtestSetRates) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsRegtestFund burns `Amount` FCT in factoshis from the `Address`
+type ParamsRegtestFund struct {
+	Token   string `json:"token,omitempty"`
+	Address string `json:"address"`
+	Amount  uint64 `json:"amount"`
+}
+
+func (p ParamsRegtestFund) HasIncludePending() bool { return false }
+func (p ParamsRegtestFund) IsValid() error {
+	if p.Address == "" {
+		return jrpc.ErrorInvalidParams(`required: "address"`)
+	}
+	if _, err := underlyingFA(p.Address); err != nil {
+		return jrpc.ErrorInvalidParams("address: " + err.Error())
+	}
+	if p.Amount == 0 {
+		return jrpc.ErrorInvalidParams("amount must be greater than 0")
+	}
+	return nil
+}
+func (p ParamsRegtestFund) ValidChainID() *factom.Bytes32 {
+	return nil
+}
@@ -0,0 +1,70 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+)
+
+// heavyMethods are the rpcs that scan large parts of the database. They run
+// on the worker pool.
+var heavyMethods = map[string]bool{
+	"get-rich-list":          true,
+	"get-global-rich-list":   true,
+	"get-holders":            true,
+	"get-distribution-stats": true,
+	"get-miner-distribution": true,
+	"get-opr-stats":          true,
+	"get-miners":             true,
+	"export-statement":       true,
+	"get-supply-history":     true,
+	"get-ledger":             true,
+	"get-network-stats":      true,
+	"get-conversion-volume":  true,
+}
+
+// workerPool bounds how many expensive computations run at the same time
+type workerPool struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newWorkerPool(workers int, wait time.Duration) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &workerPool{slots: make(chan struct{}, workers), wait: wait}
+}
+
+// acquire waits for a free worker. The returned func releases it.
+func (p *workerPool) acquire(ctx context.Context) (func(), error) {
+	var timeout <-chan time.Time
+	if p.wait > 0 {
+		timer := time.NewTimer(p.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	case <-timeout:
+		return nil, ErrorServerBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// pooled runs the method on the worker pool
+func (p *workerPool) pooled(method jrpc.MethodFunc) jrpc.MethodFunc {
+	return func(ctx context.Context, params json.RawMessage) interface{} {
+		release, err := p.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return method(ctx, params)
+	}
+}
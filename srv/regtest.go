@@ -0,0 +1,112 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/regtest"
+)
+
+// RegtestMaxMine is the most blocks a single regtest-mine call mines
+const RegtestMaxMine = 1000
+
+// RegtestSyncWait is how long regtest-mine waits for the node to sync the
+// mined blocks
+const RegtestSyncWait = 30 * time.Second
+
+// checkRegtest only allows the regtest rpcs in the regtest mode. The admin
+// token is checked if one is configured.
+func (s *APIServer) checkRegtest(token string) error {
+	if s.Node.Regtest == nil {
+		return ErrorRegtestDisabled
+	}
+	if err := s.checkAdminToken(token); err != nil && err != ErrorAdminDisabled {
+		return err
+	}
+	return nil
+}
+
+// ResultRegtestMine is the height of the last mined block, and the height
+// the node synced up to
+type ResultRegtestMine struct {
+	Height uint32 `json:"height"`
+	Synced uint32 `json:"synced"`
+}
+
+func (s *APIServer) regtestMine(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsRegtestMine{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkRegtest(params.Token); err != nil {
+		return err
+	}
+
+	count, coinbase := params.Count, regtest.DefaultCoinbase()
+	if count == 0 {
+		count = 1
+	}
+	if params.Coinbase != "" {
+		coinbase, _ = factom.NewFAAddress(params.Coinbase)
+	}
+	height, err := s.Node.Regtest.Mine(count, coinbase)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+
+	// Once the call returns, the mined blocks can be queried
+	ctx, cancel := context.WithTimeout(ctx, RegtestSyncWait)
+	defer cancel()
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for s.Node.GetCurrentSync() < height {
+		select {
+		case <-ctx.Done():
+			return ResultRegtestMine{Height: height, Synced: s.Node.GetCurrentSync()}
+		case <-tick.C:
+		}
+	}
+	return ResultRegtestMine{Height: height, Synced: s.Node.GetCurrentSync()}
+}
+
+func (s *APIServer) regtestSetRates(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsRegtestSetRates{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkRegtest(params.Token); err != nil {
+		return err
+	}
+
+	if err := s.Node.Regtest.SetRates(params.Rates); err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return s.Node.Regtest.Rates()
+}
+
+// ResultRegtestFund is the burn that funds the address, and the height of the
+// block it is mined in
+type ResultRegtestFund struct {
+	TxID   factom.Bytes32 `json:"txid"`
+	Height uint32         `json:"height"`
+}
+
+func (s *APIServer) regtestFund(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsRegtestFund{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := s.checkRegtest(params.Token); err != nil {
+		return err
+	}
+
+	adr, _ := underlyingFA(params.Address)
+	txid, err := s.Node.Regtest.Fund(adr, params.Amount)
+	if err != nil {
+		return jrpc.ErrorInvalidParams(err.Error())
+	}
+	return ResultRegtestFund{TxID: txid, Height: s.Node.Regtest.Height() + 1}
+}
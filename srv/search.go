@@ -0,0 +1,163 @@
+package srv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// The types of a search result
+const (
+	SearchAddress   = "address"
+	SearchEntryHash = "entryhash"
+	SearchBurn      = "burn"
+	SearchHeight    = "height"
+)
+
+// searchType returns the type the query looks like, or an empty string if
+// it is none of them. Entry hashes and burn txids look the same, they are
+// told apart by the history.
+func searchType(query string) string {
+	query = strings.TrimSpace(query)
+	if _, err := strconv.ParseUint(query, 10, 32); err == nil {
+		return SearchHeight
+	}
+	if len(query) == 64 {
+		if _, err := hex.DecodeString(query); err == nil {
+			return SearchEntryHash
+		}
+	}
+	if validAddress(query) == nil {
+		return SearchAddress
+	}
+	return ""
+}
+
+// ResultSearch is what the query resolved to. The summary is a
+// SearchAddressSummary, SearchEntrySummary, pegnet.Burn, or pegnet.Block
+// depending on the type.
+type ResultSearch struct {
+	Query   string      `json:"query"`
+	Type    string      `json:"type"`
+	Summary interface{} `json:"summary"`
+}
+
+// SearchAddressSummary are the balances and activity of an address. An
+// address without activity has no balances and no transactions.
+type SearchAddressSummary struct {
+	Address      string                `json:"address"`
+	EthAddress   string                `json:"ethaddress,omitempty"`
+	Balances     ResultPegnetTickerMap `json:"balances"`
+	Transactions int64                 `json:"transactions"`
+	FirstSeen    uint32                `json:"firstseen,omitempty"`
+	LastActive   uint32                `json:"lastactive,omitempty"`
+}
+
+// SearchEntrySummary is the status of a transaction entry and its first
+// actions. `Count` is the total number of actions; entries that were
+// rejected before they made it into the history have none.
+type SearchEntrySummary struct {
+	EntryHash *factom.Bytes32 `json:"entryhash"`
+	ResultGetTransactionStatus
+	Actions []pegnet.HistoryTransaction `json:"actions"`
+	Count   int                         `json:"count"`
+}
+
+func (s *APIServer) search(ctx context.Context, data json.RawMessage) interface{} {
+	params := ParamsSearch{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+	query := strings.TrimSpace(params.Query)
+	res := ResultSearch{Query: query, Type: searchType(query)}
+
+	switch res.Type {
+	case SearchHeight:
+		height, _ := strconv.ParseUint(query, 10, 32) // checked by searchType
+		block, err := s.Node.Pegnet.SelectBlock(ctx, uint32(height))
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		if block == nil {
+			return ErrorNotFound
+		}
+		res.Summary = block
+
+	case SearchEntryHash:
+		hash := new(factom.Bytes32)
+		if err := hash.Set(query); err != nil {
+			panic(err) // checked by searchType
+		}
+		actions, count, err := s.Node.Pegnet.SelectTransactionHistoryActionsByHash(hash, pegnet.HistoryQueryOptions{})
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		// A burn is the only action of the factoid transaction
+		if len(actions) == 1 && actions[0].TxAction == pegnet.FCTBurn {
+			res.Type = SearchBurn
+			res.Summary = pegnet.Burn{
+				TxID:      *hash,
+				Height:    uint32(actions[0].Height),
+				Timestamp: actions[0].Timestamp,
+				Address:   *actions[0].FromAddress,
+				Burned:    actions[0].FromAmount,
+				Credited:  actions[0].ToAmount,
+			}
+			break
+		}
+
+		summary := SearchEntrySummary{EntryHash: hash, Actions: actions, Count: count}
+		if summary.Actions == nil {
+			summary.Actions = []pegnet.HistoryTransaction{}
+		}
+		rejection, err := s.Node.Pegnet.SelectTransactionRejection(hash)
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		if count == 0 {
+			if rejection == nil {
+				return ErrorNotFound
+			}
+			summary.Height = rejection.Height
+			summary.Executed = int32(rejection.Code)
+			summary.Reason = rejection.Reason
+		} else {
+			summary.Height, summary.Executed, err = s.Node.Pegnet.SelectTransactionHistoryStatus(hash)
+			if err != nil {
+				panic(err) // This is an internal error
+			}
+			if summary.Executed < 0 && rejection != nil {
+				summary.Reason = rejection.Reason
+			}
+		}
+		res.Summary = summary
+
+	case SearchAddress:
+		add, err := s.resolveAddress(query)
+		if err != nil {
+			return err
+		}
+		summary := SearchAddressSummary{Address: add.String(), EthAddress: s.ethAddress(&add)}
+		bals, err := s.Node.Pegnet.SelectBalances(&add)
+		if err != nil && err != sql.ErrNoRows {
+			panic(err) // This is an internal error
+		}
+		summary.Balances = bals
+		stats, err := s.Node.Pegnet.SelectAddressStats(&add)
+		if err != nil {
+			panic(err) // This is an internal error
+		}
+		if stats != nil {
+			summary.Transactions = stats.TxCount
+			summary.FirstSeen, summary.LastActive = stats.FirstSeen, stats.LastActive
+		}
+		res.Summary = summary
+	}
+	return res
+}
@@ -23,13 +23,19 @@
 package srv
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
 	"github.com/pegnet/pegnetd/config"
+	"github.com/pegnet/pegnetd/metrics"
 	"github.com/pegnet/pegnetd/node"
+	"github.com/pegnet/pegnetd/tracing"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -44,12 +50,19 @@ var srv http.Server
 type APIServer struct {
 	Node   *node.Pegnetd
 	Config *viper.Viper
+
+	// pool runs the expensive rpcs and streams
+	pool *workerPool
+	// cache holds the results of the read rpcs at the current sync height
+	cache *responseCache
 }
 
 func NewAPIServer(conf *viper.Viper, n *node.Pegnetd) *APIServer {
 	s := new(APIServer)
 	s.Node = n
 	s.Config = conf
+	s.pool = newWorkerPool(conf.GetInt(config.APIWorkers), conf.GetDuration(config.APIWorkerWait))
+	s.cache = newResponseCache(conf.GetInt(config.APICacheSize))
 
 	return s
 }
@@ -61,7 +74,18 @@ func NewAPIServer(conf *viper.Viper, n *node.Pegnetd) *APIServer {
 func (s *APIServer) Start(stop <-chan struct{}) (done <-chan struct{}) {
 	// Set up JSON RPC 2.0 handler with correct headers.
 	jrpc.DebugMethodFunc = true
-	jrpcHandler := jrpc.HTTPRequestHandler(s.jrpcMethods(), nil)
+	methods := s.jrpcMethods()
+	for name, method := range methods {
+		method = recovered(name, method)
+		if heavyMethods[name] {
+			method = s.pool.pooled(method)
+		}
+		if cacheableMethods[name] {
+			method = s.cached(name, method)
+		}
+		methods[name] = traced(name, timed(name, method))
+	}
+	jrpcHandler := jrpc.HTTPRequestHandler(methods, log.WithField("component", "jsonrpc"))
 
 	var handler http.Handler = http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -86,9 +110,19 @@ func (s *APIServer) Start(stop <-chan struct{}) (done <-chan struct{}) {
 
 	srvMux.Handle("/", handler)
 	srvMux.Handle("/v1", handler)
+	srvMux.Handle(StreamPath, s.streamHandler())
+	srvMux.Handle(HealthPath, s.healthHandler())
+
+	var muxHandler http.Handler = callerHandler(encodingHandler(srvMux))
+	if s.Config.GetBool(config.APIGzip) {
+		muxHandler = gzipHandler(muxHandler)
+	}
 
-	cors := cors.New(cors.Options{AllowedOrigins: []string{"*"}})
-	srv = http.Server{Handler: cors.Handler(srvMux)}
+	cors := cors.New(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", APIKeyHeader},
+	})
+	srv = http.Server{Handler: cors.Handler(muxHandler)}
 
 	if strings.Contains(s.Config.GetString(config.APIListen), ":") {
 		// This means the use set the listen address rather than just the port
@@ -100,7 +134,7 @@ func (s *APIServer) Start(stop <-chan struct{}) (done <-chan struct{}) {
 
 	// Start server.
 	_done := make(chan struct{})
-	log.Infof("Listening on %v...", srv.Addr)
+	log.WithField("addr", srv.Addr).Info("Listening")
 	go func() {
 		var err error
 		// TODO: Renable tls
@@ -110,7 +144,7 @@ func (s *APIServer) Start(stop <-chan struct{}) (done <-chan struct{}) {
 		err = srv.ListenAndServe()
 		//}
 		if err != http.ErrServerClosed {
-			log.Errorf("srv.ListenAndServe(): %v", err)
+			log.WithError(err).Error("srv.ListenAndServe()")
 		}
 		close(_done)
 	}()
@@ -119,10 +153,73 @@ func (s *APIServer) Start(stop <-chan struct{}) (done <-chan struct{}) {
 		select {
 		case <-stop:
 			if err := srv.Shutdown(nil); err != nil {
-				log.Errorf("srv.Shutdown(): %v", err)
+				log.WithError(err).Error("srv.Shutdown()")
 			}
 		case <-_done:
 		}
 	}()
 	return _done
 }
+
+// traced records a span for every call of the method. The spans of the
+// database queries made with the context of the call are its children.
+func traced(name string, method jrpc.MethodFunc) jrpc.MethodFunc {
+	return func(ctx context.Context, params json.RawMessage) (res interface{}) {
+		ctx, span := tracing.Start(ctx, name, tracing.KindServer)
+		if span == nil {
+			return method(ctx, params)
+		}
+		span.SetAttribute("rpc.system", "jsonrpc")
+		span.SetAttribute("rpc.method", name)
+		defer func() {
+			if err, ok := res.(error); ok {
+				span.SetError(err)
+			}
+			span.Finish()
+		}()
+		return method(ctx, params)
+	}
+}
+
+// recovered turns a panic of the method into an internal error, so it is
+// reported by the other wrappers like any failed call
+func recovered(name string, method jrpc.MethodFunc) jrpc.MethodFunc {
+	return func(ctx context.Context, params json.RawMessage) (res interface{}) {
+		defer func() {
+			if p := recover(); p != nil {
+				res = panicError(name, params, p)
+			}
+		}()
+		return method(ctx, params)
+	}
+}
+
+// panicError logs the panic of a call with its stack trace and counts it
+func panicError(name string, params json.RawMessage, p interface{}) jrpc.Error {
+	metrics.GlobalPanics.Inc(name)
+	log.WithFields(log.Fields{
+		"method": name,
+		"params": string(params),
+		"panic":  fmt.Sprint(p),
+		"stack":  string(debug.Stack()),
+	}).Error("rpc panicked")
+	return ErrorInternal
+}
+
+// timed records the latency of every call of the method and logs it
+func timed(name string, method jrpc.MethodFunc) jrpc.MethodFunc {
+	return func(ctx context.Context, params json.RawMessage) (res interface{}) {
+		start := time.Now()
+		defer func() {
+			duration := time.Since(start)
+			metrics.GlobalRPC.Observe(name, duration)
+
+			entry := log.WithFields(log.Fields{"method": name, "duration": duration})
+			if err, ok := res.(error); ok {
+				entry = entry.WithError(err)
+			}
+			entry.Debug("rpc")
+		}()
+		return method(ctx, params)
+	}
+}
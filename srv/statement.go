@@ -0,0 +1,184 @@
+package srv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/node/pegnet"
+)
+
+// The supported statement formats
+const (
+	StatementCSV = "csv"
+	StatementOFX = "ofx"
+)
+
+// StatementEntry is an amount of an asset moved in or out of an address.
+// Amount is negative if it left the address.
+type StatementEntry struct {
+	ID           string
+	Height       int32
+	Timestamp    time.Time
+	Type         string
+	Asset        string
+	Amount       int64
+	Balance      int64 // the balance of the asset after the entry
+	Counterparty string
+}
+
+// statementEntries flattens the applied history of an address into entries
+// from the point of view of the address
+func statementEntries(addr factom.FAAddress, history []pegnet.HistoryTransaction) []StatementEntry {
+	var entries []StatementEntry
+	balances := make(map[string]int64)
+	add := func(h pegnet.HistoryTransaction, id, typ, asset string, amount int64, counterparty string) {
+		if amount == 0 {
+			return
+		}
+		balances[asset] += amount
+		entries = append(entries, StatementEntry{
+			ID:           id,
+			Height:       h.Executed,
+			Timestamp:    h.Timestamp,
+			Type:         typ,
+			Asset:        asset,
+			Amount:       amount,
+			Balance:      balances[asset],
+			Counterparty: counterparty,
+		})
+	}
+
+	for _, h := range history {
+		switch h.TxAction {
+		case pegnet.Transfer:
+			for i, out := range h.Outputs {
+				id := h.TxID
+				if len(h.Outputs) > 1 {
+					id = fmt.Sprintf("%s-%d", h.TxID, i)
+				}
+				switch {
+				case *h.FromAddress == out.Address:
+				case *h.FromAddress == addr:
+					add(h, id, "transfer", h.FromAsset, -out.Amount, out.Address.String())
+				case out.Address == addr:
+					add(h, id, "transfer", h.FromAsset, out.Amount, h.FromAddress.String())
+				}
+			}
+		case pegnet.Conversion:
+			// Refunds of PEG conversion requests never left the address
+			amount := h.FromAmount
+			for _, out := range h.Outputs {
+				amount -= out.Amount
+			}
+			add(h, h.TxID, "conversion", h.FromAsset, -amount, "")
+			add(h, h.TxID, "conversion", h.ToAsset, h.ToAmount, "")
+		case pegnet.Coinbase:
+			add(h, h.TxID, "coinbase", h.ToAsset, h.ToAmount, "")
+		case pegnet.FCTBurn:
+			add(h, h.TxID, "burn", h.ToAsset, h.ToAmount, "")
+		}
+	}
+	return entries
+}
+
+// formatAmount formats an amount with 8 decimals
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, amount/1e8, amount%1e8)
+}
+
+// writeStatementCSV writes one row per entry
+func writeStatementCSV(w io.Writer, entries []StatementEntry) error {
+	c := csv.NewWriter(w)
+	_ = c.Write([]string{"id", "height", "date", "type", "asset", "amount", "balance", "counterparty"})
+	for _, e := range entries {
+		_ = c.Write([]string{
+			e.ID,
+			strconv.Itoa(int(e.Height)),
+			e.Timestamp.UTC().Format(time.RFC3339),
+			e.Type,
+			e.Asset,
+			formatAmount(e.Amount),
+			formatAmount(e.Balance),
+			e.Counterparty,
+		})
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// ofxTime is the OFX datetime format
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// ofxCurrency is the ISO 4217 code of a pegged fiat or metal asset, or
+// "XXX" for assets without a currency
+func ofxCurrency(asset string) string {
+	switch asset {
+	case "pUSD", "pEUR", "pJPY", "pGBP", "pCAD", "pCHF", "pINR", "pSGD", "pCNY", "pHKD", "pKRW", "pBRL", "pPHP", "pMXN",
+		"pAUD", "pNZD", "pSEK", "pNOK", "pRUB", "pZAR", "pTRY", "pXAU", "pXAG":
+		return asset[1:]
+	}
+	return "XXX"
+}
+
+// writeStatementOFX writes an OFX 2.2 bank statement with one account per
+// asset, named "<address>-<asset>". The entries must be sorted by time.
+func writeStatementOFX(w io.Writer, addr string, entries []StatementEntry, now time.Time) error {
+	assets := make(map[string][]StatementEntry)
+	for _, e := range entries {
+		assets[e.Asset] = append(assets[e.Asset], e)
+	}
+	names := make([]string, 0, len(assets))
+	for asset := range assets {
+		names = append(names, asset)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	sb.WriteString("<?OFX OFXHEADER=\"200\" VERSION=\"220\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	sb.WriteString("<OFX>\n")
+	fmt.Fprintf(&sb, "<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxTime(now))
+	sb.WriteString("<BANKMSGSRSV1>\n")
+	for i, asset := range names {
+		list := assets[asset]
+		fmt.Fprintf(&sb, "<STMTTRNRS><TRNUID>%d</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", i)
+		fmt.Fprintf(&sb, "<STMTRS><CURDEF>%s</CURDEF>\n", ofxCurrency(asset))
+		fmt.Fprintf(&sb, "<BANKACCTFROM><BANKID>PEGNET</BANKID><ACCTID>%s-%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", addr, ofxEscaper.Replace(asset))
+		fmt.Fprintf(&sb, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxTime(list[0].Timestamp), ofxTime(list[len(list)-1].Timestamp))
+		for _, e := range list {
+			typ := "CREDIT"
+			if e.Amount < 0 {
+				typ = "DEBIT"
+			}
+			memo := fmt.Sprintf("%s at height %d", e.Type, e.Height)
+			if e.Counterparty != "" {
+				memo += " with " + e.Counterparty
+			}
+			fmt.Fprintf(&sb, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+				typ, ofxTime(e.Timestamp), formatAmount(e.Amount), ofxEscaper.Replace(e.ID), e.Type, ofxEscaper.Replace(memo))
+		}
+		sb.WriteString("</BANKTRANLIST>\n")
+		last := list[len(list)-1]
+		fmt.Fprintf(&sb, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", formatAmount(last.Balance), ofxTime(last.Timestamp))
+		sb.WriteString("</STMTRS></STMTTRNRS>\n")
+	}
+	sb.WriteString("</BANKMSGSRSV1>\n</OFX>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,192 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v13"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/pegnet/pegnetd/fat/fat2"
+	"github.com/pegnet/pegnetd/node/pegnet"
+	"github.com/pegnet/pegnetd/tracing"
+	log "github.com/sirupsen/logrus"
+)
+
+// StreamPath is the prefix of the streaming endpoints. The params of the
+// equivalent rpc method are posted as the body, the response is newline
+// delimited json with one record per line. Errors are written as a final
+// line of the form {"error": {...}}.
+const StreamPath = "/v1/stream/"
+
+// streamFunc writes all records of a query, one call of emit per record
+type streamFunc func(ctx context.Context, params json.RawMessage, emit func(interface{}) error) error
+
+func (s *APIServer) streamMethods() map[string]streamFunc {
+	return map[string]streamFunc{
+		"ledger":       s.streamLedger,
+		"holders":      s.streamHolders,
+		"transactions": s.streamTransactions,
+	}
+}
+
+// streamHandler serves the streaming endpoints
+func (s *APIServer) streamHandler() http.Handler {
+	methods := s.streamMethods()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, StreamPath)
+		method, ok := methods[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, span := tracing.Start(r.Context(), "stream "+name, tracing.KindServer)
+		defer span.Finish()
+
+		release, err := s.pool.acquire(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		var written int
+		emit := func(record interface{}) error {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+			// flush in batches so the client can process incrementally
+			if written++; flusher != nil && written%100 == 0 {
+				flusher.Flush()
+			}
+			return r.Context().Err()
+		}
+
+		err = func() (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = panicError("stream "+name, data, p)
+				}
+			}()
+			return method(ctx, data, emit)
+		}()
+		span.SetAttribute("records", written)
+		if err != nil {
+			span.SetError(err)
+			if _, ok := err.(jrpc.Error); !ok {
+				err = jrpc.ErrorInvalidParams(err.Error())
+			}
+			if written == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			if err := enc.Encode(map[string]interface{}{"error": err}); err != nil {
+				log.WithError(err).Debug("failed to write stream error")
+			}
+		}
+	})
+}
+
+// streamLedger streams the ResultLedgerEntry of every address, see get-ledger
+func (s *APIServer) streamLedger(ctx context.Context, data json.RawMessage, emit func(interface{}) error) error {
+	params := ParamsGetLedger{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	height := uint32(params.Height)
+	if height == 0 {
+		height = s.Node.GetCurrentSync()
+	}
+	if height > s.Node.GetCurrentSync() {
+		return jrpc.ErrorInvalidParams("height is not synced yet")
+	}
+
+	var after *factom.FAAddress
+	if params.After != "" {
+		adr, _ := factom.NewFAAddress(params.After) // Checked by IsValid
+		after = &adr
+	}
+
+	ticker := fat2.StringToTicker(params.Asset)
+	for {
+		ledger, next, err := s.Node.Pegnet.SelectLedger(ctx, height, ticker, after, pegnet.LedgerLimit)
+		if err != nil {
+			return err
+		}
+		for _, e := range ledger {
+			if err := emit(ResultLedgerEntry{Address: e.Address.String(), Balances: e.Balances}); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		after = next
+	}
+}
+
+// streamHolders streams the ResultHolder of every holder, see get-holders
+func (s *APIServer) streamHolders(ctx context.Context, data json.RawMessage, emit func(interface{}) error) error {
+	params := ParamsGetHolders{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	ticker := fat2.StringToTicker(params.Asset)
+	for offset := params.Offset; ; offset += pegnet.HoldersLimit {
+		count, holders, err := s.Node.Pegnet.SelectHolders(ticker, offset, pegnet.HoldersLimit)
+		if err != nil {
+			return err
+		}
+		for _, h := range holders {
+			if err := emit(ResultHolder{Address: h.Address.String(), EthAddress: s.ethAddress(h.Address), Balance: h.Balance}); err != nil {
+				return err
+			}
+		}
+		if len(holders) == 0 || offset+len(holders) >= count {
+			return nil
+		}
+	}
+}
+
+// streamTransactions streams every pegnet.HistoryTransaction matching the
+// params, see get-transactions
+func (s *APIServer) streamTransactions(ctx context.Context, data json.RawMessage, emit func(interface{}) error) error {
+	params := ParamsGetPegnetTransaction{}
+	if _, _, err := validate(data, &params); err != nil {
+		return err
+	}
+
+	options := historyOptions(params)
+	for {
+		actions, count, err := s.selectTransactions(params, options)
+		if err != nil {
+			return err
+		}
+		for _, a := range actions {
+			if err := emit(a); err != nil {
+				return err
+			}
+		}
+		options.Offset += len(actions)
+		if len(actions) == 0 || options.Offset >= count {
+			return nil
+		}
+	}
+}
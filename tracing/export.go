@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxBatch is the most spans sent in one export
+const maxBatch = 512
+
+// Tracer buffers finished spans and exports them in batches. Spans are
+// dropped if the collector can't keep up.
+type Tracer struct {
+	Service  string
+	Endpoint string
+	Interval time.Duration
+	Client   *http.Client
+
+	spans   chan *Span
+	dropped uint64
+}
+
+// NewTracer creates a tracer for the traces endpoint of a collector, eg:
+// "http://localhost:4318/v1/traces". Up to queue spans wait to be exported.
+func NewTracer(service, endpoint string, interval time.Duration, queue int) (*Tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("tracing endpoint %s: must be http or https", endpoint)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("tracing interval must be positive")
+	}
+	if queue < 1 {
+		queue = 1
+	}
+	return &Tracer{
+		Service:  service,
+		Endpoint: endpoint,
+		Interval: interval,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *Span, queue),
+	}, nil
+}
+
+func (t *Tracer) record(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// Run exports the spans every interval, or once a batch is full, until the
+// context is cancelled. Failed exports are logged and dropped.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-t.spans:
+			if batch = append(batch, s); len(batch) < maxBatch {
+				continue
+			}
+		case <-ticker.C:
+			if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+				log.WithField("spans", dropped).Warn("tracing queue full, spans dropped")
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := t.Export(ctx, batch); err != nil {
+			log.WithError(err).WithField("spans", len(batch)).Debug("failed to export spans")
+		}
+		batch = nil
+	}
+}
+
+// Export sends the spans to the collector
+func (t *Tracer) Export(ctx context.Context, spans []*Span) error {
+	data, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("collector responded %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// The OTLP/HTTP json messages, ids are hex and times are unix nanoseconds
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string          `json:"traceId"`
+		SpanID       string          `json:"spanId"`
+		ParentSpanID string          `json:"parentSpanId,omitempty"`
+		Name         string          `json:"name"`
+		Kind         int             `json:"kind"`
+		Start        string          `json:"startTimeUnixNano"`
+		End          string          `json:"endTimeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		Status       otlpStatus      `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		out[i] = otlpSpan{
+			TraceID:    hex.EncodeToString(s.TraceID[:]),
+			SpanID:     hex.EncodeToString(s.SpanID[:]),
+			Name:       s.Name,
+			Kind:       s.Kind,
+			Start:      strconv.FormatInt(s.Start.UnixNano(), 10),
+			End:        strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes: attributes(s.Attributes),
+		}
+		if s.ParentID != ([8]byte{}) {
+			out[i].ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != nil {
+			out[i].Status = otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]interface{}{"service.name": t.Service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "pegnetd"}, Spans: out}},
+	}}}
+}
+
+// attributes converts the attributes to OTLP key values, sorted by key
+func attributes(attrs map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attrs[k].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int32:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint32:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case uint64:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: k, Value: value})
+	}
+	return out
+}
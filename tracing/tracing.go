@@ -0,0 +1,118 @@
+// Package tracing records spans of the rpcs, the database queries, and the
+// factomd calls, and exports them to an OpenTelemetry collector with the
+// OTLP/HTTP json encoding.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// The span kinds of OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Global is the tracer that all spans are recorded with. Tracing is disabled
+// while it is nil. It has to be set before the node and the api server are
+// started.
+var Global *Tracer
+
+// Span is a timed operation. The methods of a nil span do nothing, so
+// callers do not have to check if tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Err        error
+}
+
+type spanKey struct{}
+
+// FromContext returns the span of the context, or nil
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start begins a span as the child of the span in the context, or as a new
+// trace if there is none. The returned context carries the new span.
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if Global == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{tracer: Global, Name: name, Kind: kind, Start: time.Now()}
+	if parent := FromContext(ctx); parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.TraceID[:])
+	}
+	_, _ = rand.Read(span.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartChild begins a span only if the context already has one. It is used
+// for operations that are too frequent to be traced on their own, like the
+// database queries.
+func StartChild(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind)
+}
+
+// SetAttribute adds string, bool, integer, or float attributes to the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed, nil errors are ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Err = err
+}
+
+// Finish ends the span and queues it for export
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	s.tracer.record(s)
+}
+
+// TraceParent returns the W3C traceparent header of the span, so the spans of
+// other services can join the trace
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-01"
+}
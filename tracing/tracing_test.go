@@ -0,0 +1,95 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/pegnet/pegnetd/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	Global = nil
+	ctx, span := Start(context.Background(), "disabled", KindInternal)
+	require.Nil(t, span)
+	require.Nil(t, FromContext(ctx))
+	span.SetAttribute("ignored", 1) // nil spans are safe to use
+	span.Finish()
+
+	tracer, err := NewTracer("pegnetd", "http://localhost:4318/v1/traces", time.Second, 10)
+	require.NoError(t, err)
+	Global = tracer
+	defer func() { Global = nil }()
+
+	_, orphan := StartChild(context.Background(), "query", KindClient)
+	require.Nil(t, orphan, "no child without a parent")
+
+	ctx, root := Start(context.Background(), "get-transactions", KindServer)
+	require.NotNil(t, root)
+	require.Equal(t, [8]byte{}, root.ParentID)
+	_, child := StartChild(ctx, "query", KindClient)
+	require.NotNil(t, child)
+	require.Equal(t, root.TraceID, child.TraceID)
+	require.Equal(t, root.SpanID, child.ParentID)
+	require.NotEqual(t, root.SpanID, child.SpanID)
+}
+
+func TestTracer_Export(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		data, _ := ioutil.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(data, &body))
+	}))
+	defer ts.Close()
+
+	tracer, err := NewTracer("pegnetd", ts.URL, time.Second, 10)
+	require.NoError(t, err)
+	span := &Span{Name: "get-rates", Kind: KindServer, Start: time.Unix(1, 0), End: time.Unix(2, 0),
+		Attributes: map[string]interface{}{"rpc.method": "get-rates", "height": uint32(5)}}
+	span.TraceID[0], span.SpanID[0] = 1, 2
+	require.NoError(t, tracer.Export(context.Background(), []*Span{span}))
+
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Len(t, spans, 1)
+	s := spans[0].(map[string]interface{})
+	require.Equal(t, "01000000000000000000000000000000", s["traceId"])
+	require.Equal(t, "0200000000000000", s["spanId"])
+	require.Nil(t, s["parentSpanId"])
+	require.Equal(t, "1000000000", s["startTimeUnixNano"])
+	require.Equal(t, "get-rates", s["name"])
+	attrs := s["attributes"].([]interface{})
+	require.Equal(t, map[string]interface{}{"key": "height", "value": map[string]interface{}{"intValue": "5"}}, attrs[0])
+}
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer ts.Close()
+
+	tracer, err := NewTracer("pegnetd", ts.URL, time.Second, 10)
+	require.NoError(t, err)
+	Global = tracer
+	defer func() { Global = nil }()
+
+	client := &http.Client{Transport: Transport(nil, "factomd")}
+	ctx, root := Start(context.Background(), "sync.fetch", KindInternal)
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, bytes.NewBufferString(`{"jsonrpc":"2.0","method":"dblock-by-height"}`))
+	resp, err := client.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Contains(t, traceparent, hex.EncodeToString(root.TraceID[:]), "the request joins the trace")
+	require.NotContains(t, traceparent, hex.EncodeToString(root.SpanID[:]), "the parent is the client span")
+	root.Finish()
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// transport records a client span for every json-rpc request that is made
+// within a traced context
+type transport struct {
+	base http.RoundTripper
+	peer string
+}
+
+// Transport wraps the round tripper of the http client of a json-rpc service,
+// eg factomd. Spans are named after the rpc method.
+func Transport(base http.RoundTripper, peer string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, peer: peer}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartChild(req.Context(), t.peer, KindClient)
+	if span == nil {
+		return t.base.RoundTrip(req)
+	}
+	defer span.Finish()
+
+	span.SetAttribute("rpc.system", "jsonrpc")
+	span.SetAttribute("peer.service", t.peer)
+	if method := requestMethod(req); method != "" {
+		span.Name = t.peer + " " + method
+		span.SetAttribute("rpc.method", method)
+	}
+
+	req = req.WithContext(ctx)
+	req.Header = req.Header.Clone()
+	req.Header.Set("traceparent", span.TraceParent())
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return resp, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode/100 != 2 {
+		span.SetError(httpError(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// requestMethod peeks at the json-rpc method of the request body
+func requestMethod(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return ""
+	}
+	var rpc struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(data, &rpc)
+	return rpc.Method
+}
+
+type httpError int
+
+func (e httpError) Error() string {
+	return "http status " + strconv.Itoa(int(e)) + " " + http.StatusText(int(e))
+}